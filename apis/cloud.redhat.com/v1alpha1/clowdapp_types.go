@@ -21,10 +21,13 @@ import (
 
 	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	apps "k8s.io/api/apps/v1"
+	autoscaling "k8s.io/api/autoscaling/v2"
 	batch "k8s.io/api/batch/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -52,6 +55,10 @@ type InitContainer struct {
 
 	// A list of environment variables used only by the initContainer.
 	Env []v1.EnvVar `json:"env,omitempty"`
+
+	// If false, only the volume holding the cdappconfig.json is mounted, rather
+	// than all of the volume mounts of the parent pod. Defaults to true.
+	InheritVolumeMounts *bool `json:"inheritVolumeMounts,omitempty"`
 }
 
 // DatabaseSpec is a struct defining a database to be exposed to a ClowdApp.
@@ -148,8 +155,19 @@ type PublicWebService struct {
 
 	// WhitelistPaths define the paths that do not require authentication
 	WhitelistPaths []string `json:"whitelistPaths,omitempty"`
+
+	// Protocol is the protocol served on the public port, one of 'http',
+	// 'http2' or 'grpc', defaults to http. It sets the appProtocol of the
+	// service port, and grpc switches the default probes to the gRPC health
+	// check.
+	Protocol WebProtocol `json:"protocol,omitempty"`
 }
 
+// WebProtocol is the protocol served on the public port of a deployment, one of 'http', 'http2'
+// or 'grpc'.
+// +kubebuilder:validation:Enum={"http", "http2", "grpc"}
+type WebProtocol string
+
 // AppProtocol is used to define an appProtocol for Istio
 // +kubebuilder:validation:Enum={"http", "http2", "https", "tcp", "tls", "grpc", "grpc-web", "mongo", "mysql", "redis"}
 type AppProtocol string
@@ -221,11 +239,141 @@ type Deployment struct {
 
 	AutoScalerSimple *AutoScalerSimple `json:"autoScalerSimple,omitempty"`
 
-	// DeploymentStrategy allows the deployment strategy to be set only if the
-	// deployment has no public service enabled
+	// DeploymentStrategy configures how the pods of the deployment are
+	// replaced on a rollout
 	DeploymentStrategy *DeploymentStrategy `json:"deploymentStrategy,omitempty"`
 
 	Metadata DeploymentMetadata `json:"metadata,omitempty"`
+
+	// ResourcePreset names one of the resource presets defined in the
+	// ClowdEnvironment. The preset's requests/limits are used in place of the
+	// environment's resource defaults, explicit resources set in the PodSpec
+	// still take precedence.
+	ResourcePreset string `json:"resourcePreset,omitempty"`
+
+	// PodAnnotations are merged onto the metadata of the deployment's pod
+	// template, e.g. for service mesh injection. Annotations managed by Clowder
+	// take precedence.
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+
+	// Singleton ensures that no more than one pod of the deployment ever runs,
+	// e.g. for apps holding a cluster-wide lock. Replicas are forced to 1, no
+	// autoscaler is created and the Recreate strategy is used so that two pods
+	// never coexist during a rollout. Setting an autoscaler or more than one
+	// replica alongside it is an error.
+	Singleton bool `json:"singleton,omitempty"`
+
+	// DrainOnRollout scales the deployment to zero and waits for its pods to
+	// terminate before a new version is rolled out, so that consumers finish
+	// their in-flight work before the new version starts consuming. It cannot
+	// be combined with an autoscaler.
+	DrainOnRollout *DrainOnRollout `json:"drainOnRollout,omitempty"`
+
+	// SessionAffinity configures the session affinity of the deployment's
+	// service, defaults to None.
+	SessionAffinity *SessionAffinity `json:"sessionAffinity,omitempty"`
+
+	// ReadinessGates keep the deployment's pods NotReady, and so out of its
+	// service endpoints, while any of the external dependencies they check is
+	// unhealthy.
+	ReadinessGates []DependencyReadinessGate `json:"readinessGates,omitempty"`
+
+	// ServicePolicy controls when a service is created for the deployment, either
+	// 'always' or only when the deployment exposes a web, metrics or extra port
+	// ('exposed-ports'), defaults to exposed-ports.
+	ServicePolicy ServicePolicy `json:"servicePolicy,omitempty"`
+
+	// AntiAffinity configures the pod anti-affinity of the deployment, defaults
+	// to preferring to spread its pods across zones and hosts.
+	AntiAffinity *AntiAffinity `json:"antiAffinity,omitempty"`
+
+	// ExtraPorts are exposed by the container of the deployment and its service
+	// alongside the web and metrics ports, e.g. for gRPC or websockets.
+	ExtraPorts []DeploymentPort `json:"extraPorts,omitempty"`
+
+	// MinAvailable is the number of pods of a public web deployment that must
+	// stay available during voluntary disruptions like node drains, defaults to
+	// 1. A PodDisruptionBudget is only created for deployments with more than
+	// one replica and at least one pod is always allowed to be evicted.
+	// +kubebuilder:validation:Minimum=0
+	MinAvailable *int32 `json:"minAvailable,omitempty"`
+
+	// Kind is the kind of workload rendered for the deployment, either
+	// 'deployment' or 'statefulset', defaults to deployment. A statefulset gives
+	// each pod a stable network identity through a headless service and its own
+	// persistent volumes, created from the VolumeClaimTemplates. It can't be
+	// combined with an autoscaler or drainOnRollout.
+	Kind DeploymentKind `json:"kind,omitempty"`
+
+	// VolumeClaimTemplates are the persistent volumes each pod of a statefulset
+	// deployment is given, they are mounted by naming them in the volumeMounts
+	// of the PodSpec. Only valid for the statefulset kind.
+	VolumeClaimTemplates []VolumeClaimTemplate `json:"volumeClaimTemplates,omitempty"`
+
+	// Labels are added to the resources generated for the deployment, its
+	// Deployment, Service and pods, on top of the labels of the environment.
+	// The labels managed by Clowder, such as app and pod, take precedence.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the resources generated for the deployment, its
+	// Deployment, Service and pods, on top of the annotations of the
+	// environment. Annotations managed by Clowder take precedence.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// DeploymentKind defines the kind of workload rendered for a deployment, one of 'deployment' or
+// 'statefulset'
+// +kubebuilder:validation:Enum={"deployment", "statefulset"}
+type DeploymentKind string
+
+// VolumeClaimTemplate describes a persistent volume claimed for every pod of a statefulset.
+type VolumeClaimTemplate struct {
+	// Name of the claim, which is also the name of the volume in the pod.
+	Name string `json:"name"`
+
+	// Size of the volume requested for each pod.
+	Size resource.Quantity `json:"size"`
+
+	// AccessModes of the volume, defaults to ReadWriteOnce.
+	AccessModes []v1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+
+	// StorageClassName of the volume, defaults to the default storage class of
+	// the cluster.
+	StorageClassName *string `json:"storageClassName,omitempty"`
+}
+
+// DeploymentPort is an additional port exposed by a deployment.
+type DeploymentPort struct {
+	// Name of the port, used for both the container and the service port. It
+	// must be unique within the deployment and can't be one of the names used
+	// by Clowder for the web and metrics ports.
+	// +kubebuilder:validation:MaxLength=15
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// The port the container listens on, the service exposes it on the same
+	// port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	ContainerPort int32 `json:"containerPort"`
+
+	// The protocol of the port, defaults to TCP.
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	Protocol v1.Protocol `json:"protocol,omitempty"`
+}
+
+// IsStatefulSet returns true if the deployment is rendered as a StatefulSet.
+func (d *Deployment) IsStatefulSet() bool {
+	return d.Kind == "statefulset"
+}
+
+// GetPublicProtocol returns the protocol served on the public port of the deployment, defaulting
+// to http.
+func (d *Deployment) GetPublicProtocol() WebProtocol {
+	if d.WebServices.Public.Protocol == "" {
+		return "http"
+	}
+	return d.WebServices.Public.Protocol
 }
 
 func (d *Deployment) GetReplicaCount() *int32 {
@@ -239,16 +387,95 @@ func (d *Deployment) GetReplicaCount() *int32 {
 	return &retVal
 }
 
+// DrainOnRollout configures how a deployment is drained before a rollout.
+type DrainOnRollout struct {
+	// The maximum time in seconds to wait for the old pods to terminate
+	// before the new version is rolled out regardless, defaults to 300.
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+}
+
+// DependencyReadinessGate adds a readiness gate to a deployment's pods that only passes while an
+// external dependency is healthy. Exactly one of tcp or http must be set.
+type DependencyReadinessGate struct {
+	// Name of the gate, the pods are given a readiness gate on the
+	// 'cloud.redhat.com/dependency-<name>' condition.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// TCP is a host:port address that must accept connections for the
+	// dependency to be healthy.
+	TCP string `json:"tcp,omitempty"`
+
+	// HTTP is a URL that must respond to a GET with a status below 400 for
+	// the dependency to be healthy.
+	HTTP string `json:"http,omitempty"`
+}
+
+// ServicePolicy defines when a deployment gets a service, one of 'always' or 'exposed-ports'
+// +kubebuilder:validation:Enum={"always", "exposed-ports"}
+type ServicePolicy string
+
+// AntiAffinityMode defines the pod anti-affinity of a deployment, one of 'preferred', 'required'
+// or 'none'
+// +kubebuilder:validation:Enum={"preferred", "required", "none"}
+type AntiAffinityMode string
+
+// AntiAffinity configures the pod anti-affinity of a deployment.
+type AntiAffinity struct {
+	// Mode is either 'preferred', which prefers spreading the pods across
+	// zones and hosts, 'required', which never schedules two pods into the same
+	// topology domain, or 'none'. Defaults to preferred.
+	Mode AntiAffinityMode `json:"mode,omitempty"`
+
+	// TopologyKey is the node label defining the topology domain in required
+	// mode, defaults to kubernetes.io/hostname.
+	TopologyKey string `json:"topologyKey,omitempty"`
+}
+
+// SessionAffinityType defines the session affinity of a service, one of 'None' or 'ClientIP'
+// +kubebuilder:validation:Enum={"None", "ClientIP"}
+type SessionAffinityType string
+
+// SessionAffinity configures the session affinity of a deployment's service.
+type SessionAffinity struct {
+	// Type is the session affinity of the service, ClientIP routes all of the
+	// requests from a client IP to the same pod.
+	Type SessionAffinityType `json:"type,omitempty"`
+
+	// TimeoutSeconds is how long a ClientIP session sticks to a pod, defaults
+	// to 10800 (3 hours).
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	TimeoutSeconds *int32 `json:"timeoutSeconds,omitempty"`
+}
+
 type DeploymentStrategy struct {
 	// PrivateStrategy allows a deployment that only uses a private port to set
 	// the deployment strategy one of Recreate or Rolling, default for a
 	// private service is Recreate. This is to enable a quicker roll out for
 	// services that do not have public facing endpoints.
 	PrivateStrategy apps.DeploymentStrategyType `json:"privateStrategy,omitempty"`
+
+	// Type sets the deployment strategy of any deployment, one of RollingUpdate
+	// or Recreate. It takes precedence over PrivateStrategy and over the
+	// Recreate strategy used by default for deployments with a ReadWriteOnce
+	// volume. Singleton deployments always use Recreate.
+	// +kubebuilder:validation:Enum=RollingUpdate;Recreate
+	Type apps.DeploymentStrategyType `json:"type,omitempty"`
+
+	// MaxSurge is the number or percentage of pods that can be created above
+	// the desired number of replicas during a rolling update, defaults to 25%.
+	// It can't be set for the Recreate strategy.
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+
+	// MaxUnavailable is the number or percentage of pods that can be
+	// unavailable during a rolling update, defaults to 25%. It can't be set for
+	// the Recreate strategy.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
 }
 
 type Sidecar struct {
-	// The name of the sidecar, only supported names allowed, (token-refresher)
+	// The name of the sidecar, only supported names allowed, (token-refresher, otel-collector)
 	Name string `json:"name"`
 
 	// Defines if the sidecar is enabled, defaults to False
@@ -288,13 +515,15 @@ type PodSpec struct {
 	// A pass-through of a Liveness Probe specification in standard k8s format.
 	// If omitted, a standard probe will be setup point to the webPort defined
 	// in the ClowdEnvironment and a path of /healthz. Ignored if Web is set to
-	// false.
+	// false. For deployments, an httpGet probe must point at the web, private or
+	// metrics port, or one of the extra ports, exposed by the container.
 	LivenessProbe *v1.Probe `json:"livenessProbe,omitempty"`
 
 	// A pass-through of a Readiness Probe specification in standard k8s format.
 	// If omitted, a standard probe will be setup point to the webPort defined
 	// in the ClowdEnvironment and a path of /healthz. Ignored if Web is set to
-	// false.
+	// false. For deployments, an httpGet probe must point at the web, private or
+	// metrics port, or one of the extra ports, exposed by the container.
 	ReadinessProbe *v1.Probe `json:"readinessProbe,omitempty"`
 
 	// A pass-through of a list of Volumes in standa k8s format.
@@ -308,6 +537,51 @@ type PodSpec struct {
 
 	// MachinePool allows the pod to be scheduled to a particular machine pool.
 	MachinePool string `json:"machinePool,omitempty"`
+
+	// Tolerations are added to the tolerations the ClowdEnvironment sets on
+	// every pod of the deployment, job or cronjob.
+	Tolerations []v1.Toleration `json:"tolerations,omitempty"`
+
+	// NodeSelector is merged into the node selector the ClowdEnvironment sets
+	// on every pod of the deployment, job or cronjob, entries here take
+	// precedence on conflicting keys.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// AutomountServiceAccountToken controls whether the service account token
+	// is mounted into the pod, pods that don't talk to the API server can turn
+	// it off. If omitted, the Kubernetes default of mounting it is kept.
+	AutomountServiceAccountToken *bool `json:"automountServiceAccountToken,omitempty"`
+
+	// ProvenanceLabels are added to the labels of the pod template, e.g. to
+	// record the git SHA and build time of the image so that tooling can query
+	// the versions that are running. Labels managed by Clowder take precedence.
+	// Only applied to deployments.
+	ProvenanceLabels map[string]string `json:"provenanceLabels,omitempty"`
+
+	// ConfigMountPath is the directory the config secret is mounted at,
+	// defaults to /cdapp/. It can't overlap with any of the VolumeMounts.
+	// +kubebuilder:validation:Pattern=`^/`
+	ConfigMountPath string `json:"configMountPath,omitempty"`
+
+	// ConfigFileName is the name of the config file in the ConfigMountPath,
+	// defaults to cdappconfig.json. The ACG_CONFIG env var points at the
+	// config file.
+	// +kubebuilder:validation:Pattern=`^[^/]+$`
+	ConfigFileName string `json:"configFileName,omitempty"`
+
+	// ServiceAccountName is the service account the pods run under in place
+	// of the one Clowder creates for the deployment. A service account that
+	// already exists is used as is. Only applied to deployments.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// CreateServiceAccount has Clowder create the ServiceAccountName service
+	// account when it doesn't exist, it is then removed along with the app.
+	CreateServiceAccount bool `json:"createServiceAccount,omitempty"`
+
+	// ServiceAccountAccessLevel is the access to the namespace of the app
+	// that a service account created by Clowder is bound to, one of
+	// 'default', 'view' or 'edit'.
+	ServiceAccountAccessLevel K8sAccessLevel `json:"serviceAccountAccessLevel,omitempty"`
 }
 
 // SimpleAutoScalerMetric defines a metric of either a value or utilization
@@ -343,8 +617,13 @@ type AutoScaler struct {
 	// MaxReplicaCount is the maximum number of replicas the scaler will scale the deployment to.
 	// Default is 10.
 	// +optional
+	// +kubebuilder:validation:Minimum=1
 	MaxReplicaCount *int32 `json:"maxReplicaCount,omitempty"`
 	// MinReplicaCount is the minimum number of replicas the scaler will scale the deployment to.
+	// Default is 1, 0 lets KEDA scale the deployment to zero while its triggers are inactive and
+	// is rejected unless the autoscaler of the environment is in keda mode.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
 	MinReplicaCount *int32 `json:"minReplicaCount,omitempty"`
 	// +optional
 	Advanced *keda.AdvancedConfig `json:"advanced,omitempty"`
@@ -352,11 +631,29 @@ type AutoScaler struct {
 	Triggers []keda.ScaleTriggers `json:"triggers,omitempty"`
 	// +optional
 	Fallback *keda.Fallback `json:"fallback,omitempty"`
+	// Behavior configures the scale up and scale down policies, including the
+	// stabilization windows, of the HPA generated for the deployment. If not set,
+	// the HPA defaults are used.
+	// +optional
+	Behavior *autoscaling.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
 	// ExternalHPA allows replicas on deployments to be controlled by another resource, but will
 	// not be allowed to fall under the minReplicas as set in the ClowdApp.
 	ExternalHPA bool `json:"externalHPA,omitempty"`
 }
 
+// FeatureFlagToggle is a feature toggle seeded into the local FeatureFlags instance.
+type FeatureFlagToggle struct {
+	// The name of the feature toggle.
+	Name string `json:"name"`
+
+	// Whether the feature toggle is enabled when it is created.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// If force is set to true, an existing feature toggle is reset to the
+	// declared state, rather than being left as it is.
+	Force bool `json:"force,omitempty"`
+}
+
 // CyndiSpec is used to indicate whether a ClowdApp needs database syndication configured by the
 // cyndi operator and exposes a limited set of cyndi configuration options
 type CyndiSpec struct {
@@ -381,11 +678,15 @@ type KafkaTopicSpec struct {
 	//   * replicas optional
 	//   * topicName required
 
-	// A key/value pair describing the configuration of a particular topic.
+	// A key/value pair describing the configuration of a particular topic. The supported
+	// keys are retention.ms, retention.bytes, min.compaction.lag.ms, max.message.bytes,
+	// segment.bytes and cleanup.policy. When several ClowdApps request conflicting values
+	// for a topic, the most conservative value is used.
 	// +optional
 	Config map[string]string `json:"config,omitempty"`
 
-	// The requested number of partitions for this topic. If unset, default is '3'
+	// The requested number of partitions for this topic. If unset, default is '3'.
+	// The partitions of an existing topic can be increased but never decreased.
 	// +optional
 	// +kubebuilder:validation:Minimum:=1
 	// +kubebuilder:validation:Maximum:=200000
@@ -402,12 +703,22 @@ type KafkaTopicSpec struct {
 	// +kubebuilder:validation:MaxLength:=249
 	// +kubebuilder:validation:Pattern:="[a-zA-Z0-9\\._\\-]"
 	TopicName string `json:"topicName"`
+
+	// If set, Clowder will create the topic but will not patch an existing
+	// topic back to the declared partitions and config. Use this when the
+	// topic's configuration is managed outside of Clowder.
+	// +optional
+	IgnoreConfigDrift bool `json:"ignoreConfigDrift,omitempty"`
 }
 
 type TestingSpec struct {
 	IqePlugin string `json:"iqePlugin"`
 }
 
+// ConfigSecretUpdateStrategy defines how the config secret is updated, one of 'patch' or 'recreate'
+// +kubebuilder:validation:Enum={"patch", "recreate"}
+type ConfigSecretUpdateStrategy string
+
 // ClowdAppSpec is the main specification for a single Clowder Application
 // it defines n pods along with dependencies that are shared between them.
 type ClowdAppSpec struct {
@@ -426,6 +737,11 @@ type ClowdAppSpec struct {
 	// the pods listed in the ClowdApp.
 	KafkaTopics []KafkaTopicSpec `json:"kafkaTopics,omitempty"`
 
+	// A list of the Kafka consumer groups used by the pods in the ClowdApp. Only used
+	// when the ClowdEnvironment restricts consumer group ACLs, in which case it
+	// overrides the default group named <env>-<app>.
+	KafkaConsumerGroups []string `json:"kafkaConsumerGroups,omitempty"`
+
 	// The database specification defines a single database, the configuration
 	// of which will be made available to all the pods in the ClowdApp.
 	Database DatabaseSpec `json:"database,omitempty"`
@@ -444,6 +760,10 @@ type ClowdAppSpec struct {
 	// instance will be shared between all apps.
 	FeatureFlags bool `json:"featureFlags,omitempty"`
 
+	// A list of feature toggles that are created for the ClowdApp when the
+	// FeatureFlags instance is provided by Clowder. Only used in (*_local_*) mode.
+	FeatureFlagToggles []FeatureFlagToggle `json:"featureFlagToggles,omitempty"`
+
 	// A list of dependencies in the form of the name of the ClowdApps that are
 	// required to be present for this ClowdApp to function.
 	Dependencies []string `json:"dependencies,omitempty"`
@@ -465,17 +785,49 @@ type ClowdAppSpec struct {
 
 	// Disabled turns off reconciliation for this ClowdApp
 	Disabled bool `json:"disabled,omitempty"`
+
+	// ConfigSecretUpdateStrategy controls how the config secret is updated when
+	// the config changes, it is either patched in place ('patch') or deleted and
+	// recreated ('recreate'), defaults to patch.
+	ConfigSecretUpdateStrategy ConfigSecretUpdateStrategy `json:"configSecretUpdateStrategy,omitempty"`
+
+	// A list of resource kinds, for example Service, that are rendered for this ClowdApp
+	// but never applied, in addition to those ignored by the ClowdEnvironment. Any drift
+	// between the rendered and the live resources is only reported.
+	IgnoredKinds []string `json:"ignoredKinds,omitempty"`
 }
 
 const (
 	// Ready means all the deployments are ready
 	DeploymentsReady clusterv1.ConditionType = "DeploymentsReady"
+	// DependenciesReady means every dependency of the ClowdApp is present in the environment
+	DependenciesReady clusterv1.ConditionType = "DependenciesReady"
 	// ReconciliationSuccessful represents status of successful reconciliation
 	ReconciliationSuccessful clusterv1.ConditionType = "ReconciliationSuccessful"
 	// ReconciliationFailed means the reconciliation failed
 	ReconciliationFailed clusterv1.ConditionType = "ReconciliationFailed"
 	// JobInvocationComplete means all the Jobs have finished
 	JobInvocationComplete clusterv1.ConditionType = "JobInvocationComplete"
+	// SecretNotFound means a secret required by one of the providers is missing
+	SecretNotFound clusterv1.ConditionType = "SecretNotFound"
+	// ConfigMapNotFound means a shared ConfigMap required by the environment is missing
+	ConfigMapNotFound clusterv1.ConditionType = "ConfigMapNotFound"
+	// AutoScalerTriggersInvalid means the metadata of an autoscaler trigger is missing or invalid
+	AutoScalerTriggersInvalid clusterv1.ConditionType = "AutoScalerTriggersInvalid"
+	// KafkaTopicPartitionsDecreased means a topic was declared with fewer partitions than it has
+	KafkaTopicPartitionsDecreased clusterv1.ConditionType = "KafkaTopicPartitionsDecreased"
+	// WaitingForKafkaConnect means the environment's KafkaConnect cluster is not yet ready
+	WaitingForKafkaConnect clusterv1.ConditionType = "WaitingForKafkaConnect"
+	// SingletonConflict means a singleton deployment is also configured to run more than one replica
+	SingletonConflict clusterv1.ConditionType = "SingletonConflict"
+	// ProviderFailed means a provider failed the last reconcile of the ClowdApp, the reason tells
+	// whether the failure is transient or permanent and the message names the provider
+	ProviderFailed clusterv1.ConditionType = "ProviderFailed"
+	// KafkaTopicsReady means the topic operator has marked all the topics of the ClowdApp ready
+	KafkaTopicsReady clusterv1.ConditionType = "KafkaTopicsReady"
+	// Paused means the reconciliation of the object is paused by the clowder.redhat.com/paused
+	// annotation, the resources it manages are left as they are
+	Paused clusterv1.ConditionType = "Paused"
 )
 
 // ClowdAppStatus defines the observed state of ClowdApp
@@ -486,6 +838,34 @@ type ClowdAppStatus struct {
 	Deployments AppResourceStatus     `json:"deployments,omitempty"`
 	Ready       bool                  `json:"ready"`
 	Conditions  []clusterv1.Condition `json:"conditions,omitempty"`
+	// LastReconcileTime is when Clowder last reconciled the ClowdApp,
+	// successfully or not.
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+	// LastSuccessfulReconcileTime is when Clowder last successfully
+	// reconciled the ClowdApp.
+	LastSuccessfulReconcileTime *metav1.Time `json:"lastSuccessfulReconcileTime,omitempty"`
+	// KafkaTopics reports the provisioning status of each of the topics
+	// requested by the ClowdApp, only filled in operator mode.
+	KafkaTopics []KafkaTopicStatus `json:"kafkaTopics,omitempty"`
+}
+
+// KafkaTopicStatus reports whether a topic requested by a ClowdApp has been
+// provisioned by the topic operator.
+type KafkaTopicStatus struct {
+	// The name of the topic as requested by the ClowdApp.
+	TopicName string `json:"topicName"`
+
+	// The name of the KafkaTopic created for the topic.
+	Name string `json:"name"`
+
+	// Whether the topic operator has marked the topic Ready.
+	Ready bool `json:"ready"`
+
+	// Why the topic is not ready, taken from the KafkaTopic status if it has one.
+	Message string `json:"message,omitempty"`
+
+	// When the topic was first seen not ready, cleared once it is ready.
+	PendingSince *metav1.Time `json:"pendingSince,omitempty"`
 }
 
 type AppResourceStatus struct {
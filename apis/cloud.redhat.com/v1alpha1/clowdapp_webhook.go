@@ -17,14 +17,23 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
@@ -32,7 +41,16 @@ import (
 // log is for logging in this package.
 var clowdapplog = logf.Log.WithName("clowdapp-resource")
 
+// webhookReader is used by the webhook to read the ClowdEnvironment of the app being validated,
+// the app is not validated against its environment while it is unset. It reads from the API
+// server, as the webhook may serve requests before the cache of the manager has synced.
+var webhookReader client.Reader
+
+// envLookupTimeout bounds the time the webhook waits on the ClowdEnvironment of an app.
+const envLookupTimeout = 5 * time.Second
+
 func (r *ClowdApp) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	webhookReader = mgr.GetAPIReader()
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
@@ -52,19 +70,43 @@ func (r *ClowdApp) ValidateCreate() error {
 		validateSidecars,
 		validateInit,
 		validateDeploymentStrategy,
+		validateAutoScalers,
+		validateStatefulSets,
+		validateServiceAccounts,
+		validateJobs,
+		validateEnvironment,
 	)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
-func (r *ClowdApp) ValidateUpdate(_ runtime.Object) error {
+func (r *ClowdApp) ValidateUpdate(old runtime.Object) error {
 	clowdapplog.Info("validate update", "name", r.Name)
 
-	return r.processValidations(r,
+	validations := []appValidationFunc{
 		validateDatabase,
 		validateSidecars,
 		validateInit,
 		validateDeploymentStrategy,
-	)
+		validateAutoScalers,
+		validateStatefulSets,
+		validateServiceAccounts,
+		validateJobs,
+	}
+
+	oldApp, ok := old.(*ClowdApp)
+	if ok {
+		validations = append(validations, func(r *ClowdApp) field.ErrorList {
+			return validateStatefulSetUpdate(oldApp, r)
+		})
+	}
+
+	// Updates that leave the spec alone, like the removal of a finalizer, must go through even
+	// if the environment has gone.
+	if !ok || !equality.Semantic.DeepEqual(oldApp.Spec, r.Spec) {
+		validations = append(validations, validateEnvironment)
+	}
+
+	return r.processValidations(r, validations...)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
@@ -133,36 +175,128 @@ func validateInit(r *ClowdApp) field.ErrorList {
 	return allErrs
 }
 
-func validateSidecars(r *ClowdApp) field.ErrorList {
+// validateEnvironment checks that the environment of the app exists and can provide the resources
+// the app requests. It fails open, the app is let through if the environment can't be read.
+func validateEnvironment(r *ClowdApp) field.ErrorList {
+	if webhookReader == nil || r.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), envLookupTimeout)
+	defer cancel()
+
+	env := &ClowdEnvironment{}
+	if err := webhookReader.Get(ctx, types.NamespacedName{Name: r.Spec.EnvName}, env); err != nil {
+		if apierrors.IsNotFound(err) {
+			return field.ErrorList{field.NotFound(field.NewPath("spec.EnvName"), r.Spec.EnvName)}
+		}
+		clowdapplog.Info("could not get environment, skipping environment validation", "name", r.Name, "env", r.Spec.EnvName, "err", err)
+		return nil
+	}
+
+	return validateAgainstEnvironment(r, env)
+}
+
+// validateAgainstEnvironment rejects requests for resources the providers of the environment are
+// not configured to provide.
+func validateAgainstEnvironment(r *ClowdApp, env *ClowdEnvironment) field.ErrorList {
 	allErrs := field.ErrorList{}
-	for depIndx, deployment := range r.Spec.Deployments {
-		for carIndx, sidecar := range deployment.PodSpec.Sidecars {
-			if sidecar.Name != "token-refresher" {
-				allErrs = append(
-					allErrs,
-					field.Forbidden(
-						field.NewPath(fmt.Sprintf("spec.Deployment[%d].Sidecars[%d]", depIndx, carIndx)),
-						"Sidecar is of unknown type, must be one of [token-refresher]",
-					),
-				)
+
+	unsupported := func(path string, provider string) {
+		allErrs = append(allErrs, field.Forbidden(
+			field.NewPath(path),
+			fmt.Sprintf("environment %s does not provide %s, its %s provider mode is none", env.Name, provider, provider),
+		))
+	}
+
+	providers := env.Spec.Providers
+	if len(r.Spec.KafkaTopics) > 0 && providers.Kafka.Mode == "none" {
+		unsupported("spec.KafkaTopics", "kafka")
+	}
+	if (r.Spec.Database.Name != "" || r.Spec.Database.SharedDBAppName != "") && providers.Database.Mode == "none" {
+		unsupported("spec.Database", "database")
+	}
+	if len(r.Spec.ObjectStore) > 0 && providers.ObjectStore.Mode == "none" {
+		unsupported("spec.ObjectStore", "objectStore")
+	}
+	if r.Spec.InMemoryDB && providers.InMemoryDB.Mode == "none" {
+		unsupported("spec.InMemoryDB", "inMemoryDb")
+	}
+
+	// Only keda can scale a deployment up from zero, a native HPA refuses a minimum of 0
+	if mode := providers.AutoScaler.Mode; mode != "enabled" && mode != "keda" {
+		for depIndex, deployment := range r.Spec.Deployments {
+			if deployment.AutoScaler == nil || deployment.AutoScaler.MinReplicaCount == nil || *deployment.AutoScaler.MinReplicaCount != 0 {
+				continue
 			}
+			allErrs = append(allErrs, field.Invalid(
+				field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex)).Child("AutoScaler", "MinReplicaCount"), 0,
+				fmt.Sprintf("minReplicaCount can only be 0 when the autoscaler of environment %s is in keda mode, its mode is %q", env.Name, mode),
+			))
 		}
 	}
+
+	return allErrs
+}
+
+// supportedSidecars are the sidecars the sidecar provider knows how to add to a pod.
+var supportedSidecars = []string{"token-refresher", "otel-collector"}
+
+func validateSidecars(r *ClowdApp) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for depIndx, deployment := range r.Spec.Deployments {
+		innerDeployment := deployment
+		allErrs = append(allErrs, validatePodSidecars(
+			fmt.Sprintf("spec.Deployment[%d]", depIndx),
+			r.GetDeploymentNamespacedName(&innerDeployment).Name,
+			deployment.PodSpec.Sidecars,
+		)...)
+	}
 	for jobIndx, job := range r.Spec.Jobs {
 		if job.Schedule == "" {
 			continue
 		}
-		for carIndx, sidecar := range job.PodSpec.Sidecars {
-			if sidecar.Name != "token-refresher" {
-				allErrs = append(
-					allErrs,
-					field.Forbidden(
-						field.NewPath(fmt.Sprintf("spec.Deployment[%d].Sidecars[%d]", jobIndx, carIndx)),
-						"Sidecar is of unknown type, must be one of [token-refresher]",
-					),
-				)
+		innerJob := job
+		allErrs = append(allErrs, validatePodSidecars(
+			fmt.Sprintf("spec.Jobs[%d]", jobIndx),
+			r.GetCronJobNamespacedName(&innerJob).Name,
+			job.PodSpec.Sidecars,
+		)...)
+	}
+	return allErrs
+}
+
+// validatePodSidecars checks that the sidecars of a pod are supported and that their containers
+// don't collide with each other or with the main container of the pod.
+func validatePodSidecars(path string, containerName string, sidecars []Sidecar) field.ErrorList {
+	allErrs := field.ErrorList{}
+	seen := map[string]bool{}
+	for carIndx, sidecar := range sidecars {
+		sidecarPath := field.NewPath(fmt.Sprintf("%s.Sidecars[%d]", path, carIndx))
+
+		supported := false
+		for _, name := range supportedSidecars {
+			if sidecar.Name == name {
+				supported = true
+				break
 			}
 		}
+
+		switch {
+		case !supported:
+			allErrs = append(allErrs, field.Forbidden(
+				sidecarPath,
+				fmt.Sprintf("Sidecar is of unknown type, must be one of [%s]", strings.Join(supportedSidecars, ", ")),
+			))
+		case sidecar.Name == containerName:
+			allErrs = append(allErrs, field.Forbidden(
+				sidecarPath,
+				fmt.Sprintf("Sidecar name collides with the main container %s", containerName),
+			))
+		case seen[sidecar.Name]:
+			allErrs = append(allErrs, field.Duplicate(sidecarPath, sidecar.Name))
+		}
+		seen[sidecar.Name] = true
 	}
 	return allErrs
 }
@@ -170,15 +304,315 @@ func validateSidecars(r *ClowdApp) field.ErrorList {
 func validateDeploymentStrategy(r *ClowdApp) field.ErrorList {
 	allErrs := field.ErrorList{}
 	for depIndex, deployment := range r.Spec.Deployments {
-		if deployment.DeploymentStrategy != nil && deployment.WebServices.Public.Enabled && deployment.DeploymentStrategy.PrivateStrategy == apps.RecreateDeploymentStrategyType {
+		strategy := deployment.DeploymentStrategy
+		if strategy == nil {
+			continue
+		}
+		path := field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex))
+
+		if deployment.WebServices.Public.Enabled && strategy.PrivateStrategy == apps.RecreateDeploymentStrategyType {
 			allErrs = append(
 				allErrs,
 				field.Forbidden(
-					field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex)),
+					path,
 					"privateStrategy cannot be set to recreate for public web enabled deployments",
 				),
 			)
 		}
+
+		if deployment.Singleton && strategy.Type == apps.RollingUpdateDeploymentStrategyType {
+			allErrs = append(allErrs, field.Forbidden(path.Child("DeploymentStrategy", "Type"), "singleton deployments always use the Recreate strategy"))
+		}
+
+		strategyType := strategy.Type
+		if strategyType == "" && !deployment.WebServices.Public.Enabled {
+			strategyType = strategy.PrivateStrategy
+		}
+		if strategyType == apps.RecreateDeploymentStrategyType || deployment.Singleton {
+			if strategy.MaxSurge != nil {
+				allErrs = append(allErrs, field.Forbidden(path.Child("DeploymentStrategy", "MaxSurge"), "maxSurge cannot be set for the Recreate strategy"))
+			}
+			if strategy.MaxUnavailable != nil {
+				allErrs = append(allErrs, field.Forbidden(path.Child("DeploymentStrategy", "MaxUnavailable"), "maxUnavailable cannot be set for the Recreate strategy"))
+			}
+		} else if isZeroIntOrPercent(strategy.MaxSurge) && isZeroIntOrPercent(strategy.MaxUnavailable) {
+			allErrs = append(allErrs, field.Invalid(path.Child("DeploymentStrategy", "MaxUnavailable"), strategy.MaxUnavailable.String(), "maxSurge and maxUnavailable cannot both be 0"))
+		}
+	}
+	return allErrs
+}
+
+func validateAutoScalers(r *ClowdApp) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for depIndex, deployment := range r.Spec.Deployments {
+		autoScaler := deployment.AutoScaler
+		if autoScaler == nil {
+			continue
+		}
+		path := field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex)).Child("AutoScaler")
+
+		// the same defaults as the scaled object generated by the autoscaler provider
+		minReplicas, maxReplicas := int32(1), int32(10)
+		if autoScaler.MinReplicaCount != nil {
+			minReplicas = *autoScaler.MinReplicaCount
+		}
+		if autoScaler.MaxReplicaCount != nil {
+			maxReplicas = *autoScaler.MaxReplicaCount
+		}
+
+		if minReplicas > maxReplicas {
+			allErrs = append(allErrs, field.Invalid(
+				path.Child("MinReplicaCount"), minReplicas,
+				fmt.Sprintf("minReplicaCount cannot be greater than maxReplicaCount (%d)", maxReplicas),
+			))
+		}
+	}
+	return allErrs
+}
+
+func validateStatefulSets(r *ClowdApp) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for depIndex, deployment := range r.Spec.Deployments {
+		path := field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex))
+
+		if !deployment.IsStatefulSet() {
+			if len(deployment.VolumeClaimTemplates) > 0 {
+				allErrs = append(allErrs, field.Forbidden(path.Child("VolumeClaimTemplates"), "volumeClaimTemplates can only be set for the statefulset kind"))
+			}
+			continue
+		}
+
+		if deployment.AutoScaler != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("AutoScaler"), "the keda autoscaler is not supported for the statefulset kind"))
+		}
+		if deployment.AutoScalerSimple != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("AutoScalerSimple"), "the simple autoscaler is not supported for the statefulset kind"))
+		}
+		if deployment.DrainOnRollout != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("DrainOnRollout"), "drainOnRollout is not supported for the statefulset kind"))
+		}
+		if deployment.DeploymentStrategy != nil {
+			allErrs = append(allErrs, field.Forbidden(path.Child("DeploymentStrategy"), "statefulsets always use a rolling update"))
+		}
+
+		names := map[string]bool{}
+		for i, template := range deployment.VolumeClaimTemplates {
+			if names[template.Name] {
+				allErrs = append(allErrs, field.Duplicate(path.Child("VolumeClaimTemplates").Index(i).Child("Name"), template.Name))
+			}
+			names[template.Name] = true
+		}
 	}
 	return allErrs
 }
+
+// validateStatefulSetUpdate rejects the changes to a deployment that can't be applied to the
+// workload rendered for it. The volumeClaimTemplates of a StatefulSet are immutable, and switching
+// the kind of a deployment would leave the workload of the old kind running next to the new one.
+func validateStatefulSetUpdate(old *ClowdApp, r *ClowdApp) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	oldDeployments := map[string]*Deployment{}
+	for i := range old.Spec.Deployments {
+		oldDeployments[old.Spec.Deployments[i].Name] = &old.Spec.Deployments[i]
+	}
+
+	for depIndex, deployment := range r.Spec.Deployments {
+		oldDeployment, ok := oldDeployments[deployment.Name]
+		if !ok {
+			continue
+		}
+		path := field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex))
+
+		if oldDeployment.IsStatefulSet() != deployment.IsStatefulSet() {
+			allErrs = append(allErrs, field.Forbidden(path.Child("Kind"), "the kind of a deployment can't be changed, rename the deployment instead"))
+			continue
+		}
+		if deployment.IsStatefulSet() && !equality.Semantic.DeepEqual(oldDeployment.VolumeClaimTemplates, deployment.VolumeClaimTemplates) {
+			allErrs = append(allErrs, field.Forbidden(path.Child("VolumeClaimTemplates"), "the volumeClaimTemplates of a statefulset can't be changed, rename the deployment instead"))
+		}
+	}
+	return allErrs
+}
+
+// validateServiceAccounts checks that the service accounts named by the deployments don't clash
+// with the ones Clowder manages, and that a service account shared by several deployments is
+// declared the same way by each of them.
+func validateServiceAccounts(r *ClowdApp) field.ErrorList {
+	allErrs := field.ErrorList{}
+
+	reserved := map[string]bool{r.GetClowdSAName(): true}
+	for i := range r.Spec.Deployments {
+		reserved[r.GetDeploymentNamespacedName(&r.Spec.Deployments[i]).Name] = true
+	}
+
+	declared := map[string]PodSpec{}
+	for depIndex, deployment := range r.Spec.Deployments {
+		path := field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex)).Child("PodSpec")
+		podSpec := deployment.PodSpec
+
+		if podSpec.ServiceAccountName == "" {
+			if podSpec.CreateServiceAccount || podSpec.ServiceAccountAccessLevel != "" {
+				allErrs = append(allErrs, field.Required(path.Child("ServiceAccountName"), "a service account name is required to create a service account"))
+			}
+			continue
+		}
+
+		if reserved[podSpec.ServiceAccountName] {
+			allErrs = append(allErrs, field.Invalid(path.Child("ServiceAccountName"), podSpec.ServiceAccountName, "the name is used by a service account that Clowder manages"))
+		}
+		if deployment.K8sAccessLevel != "" && deployment.K8sAccessLevel != "default" {
+			allErrs = append(allErrs, field.Forbidden(field.NewPath(fmt.Sprintf("spec.Deployment[%d]", depIndex)).Child("K8sAccessLevel"), "k8sAccessLevel only applies to the service account Clowder creates for the deployment, use serviceAccountAccessLevel"))
+		}
+		if !podSpec.CreateServiceAccount && podSpec.ServiceAccountAccessLevel != "" {
+			allErrs = append(allErrs, field.Forbidden(path.Child("ServiceAccountAccessLevel"), "an access level can only be set on a service account that Clowder creates"))
+		}
+
+		if prev, ok := declared[podSpec.ServiceAccountName]; ok {
+			if prev.CreateServiceAccount != podSpec.CreateServiceAccount || prev.ServiceAccountAccessLevel != podSpec.ServiceAccountAccessLevel {
+				allErrs = append(allErrs, field.Invalid(path.Child("ServiceAccountName"), podSpec.ServiceAccountName, "the service account is declared differently by another deployment"))
+			}
+		} else {
+			declared[podSpec.ServiceAccountName] = podSpec
+		}
+	}
+
+	for jobIndex, job := range r.Spec.Jobs {
+		path := field.NewPath(fmt.Sprintf("spec.Jobs[%d]", jobIndex)).Child("PodSpec")
+		if job.PodSpec.ServiceAccountName != "" || job.PodSpec.CreateServiceAccount || job.PodSpec.ServiceAccountAccessLevel != "" {
+			allErrs = append(allErrs, field.Forbidden(path.Child("ServiceAccountName"), "service accounts can only be set on deployments"))
+		}
+	}
+	return allErrs
+}
+
+// isZeroIntOrPercent returns true if the value is set to 0 or 0%.
+func isZeroIntOrPercent(value *intstr.IntOrString) bool {
+	if value == nil {
+		return false
+	}
+	if value.Type == intstr.Int {
+		return value.IntVal == 0
+	}
+	return strings.TrimSuffix(value.StrVal, "%") == "0"
+}
+
+func validateJobs(r *ClowdApp) field.ErrorList {
+	allErrs := field.ErrorList{}
+	for jobIndex, job := range r.Spec.Jobs {
+		path := field.NewPath(fmt.Sprintf("spec.Jobs[%d]", jobIndex))
+		if job.Schedule != "" {
+			if err := validateCronSchedule(job.Schedule); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("Schedule"), job.Schedule, err.Error()))
+			}
+		}
+		if job.RestartPolicy != "" && job.RestartPolicy != core.RestartPolicyNever && job.RestartPolicy != core.RestartPolicyOnFailure {
+			allErrs = append(allErrs, field.NotSupported(
+				path.Child("RestartPolicy"), job.RestartPolicy,
+				[]string{string(core.RestartPolicyNever), string(core.RestartPolicyOnFailure)},
+			))
+		}
+	}
+	return allErrs
+}
+
+// cronFieldBounds are the lower and upper bounds of the minute, hour, day of month, month and day
+// of week fields of a cron schedule.
+var cronFieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+var cronMonthNames = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+var cronDayNames = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+var cronDescriptors = []string{"@yearly", "@annually", "@monthly", "@weekly", "@daily", "@midnight", "@hourly"}
+
+// validateCronSchedule checks that the schedule is a standard five field cron schedule, or one of
+// the descriptors, that the CronJob controller can parse.
+func validateCronSchedule(schedule string) error {
+	schedule = strings.TrimSpace(schedule)
+
+	if strings.HasPrefix(schedule, "@") {
+		if strings.HasPrefix(schedule, "@every ") {
+			d, err := time.ParseDuration(strings.TrimPrefix(schedule, "@every "))
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid duration in %q", schedule)
+			}
+			return nil
+		}
+		for _, descriptor := range cronDescriptors {
+			if schedule == descriptor {
+				return nil
+			}
+		}
+		return fmt.Errorf("unrecognized descriptor %q", schedule)
+	}
+
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return fmt.Errorf("expected 5 fields, found %d", len(fields))
+	}
+
+	for i, f := range fields {
+		var names []string
+		switch i {
+		case 3:
+			names = cronMonthNames
+		case 4:
+			names = cronDayNames
+		}
+		if err := validateCronField(f, cronFieldBounds[i][0], cronFieldBounds[i][1], names, i == 2 || i == 4); err != nil {
+			return fmt.Errorf("field %d (%q): %w", i+1, f, err)
+		}
+	}
+	return nil
+}
+
+// validateCronField checks a comma separated list of values, ranges and steps of a cron schedule
+// field, names are matched case insensitively with the first name being the lower bound.
+func validateCronField(f string, min int, max int, names []string, allowQuestion bool) error {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if strings.EqualFold(s, name) {
+				return min + i, nil
+			}
+		}
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, fmt.Errorf("invalid value %q", s)
+		}
+		if v < min || v > max {
+			return 0, fmt.Errorf("value %d out of range [%d, %d]", v, min, max)
+		}
+		return v, nil
+	}
+
+	for _, part := range strings.Split(f, ",") {
+		rangePart, step, hasStep := strings.Cut(part, "/")
+		if hasStep {
+			s, err := strconv.Atoi(step)
+			if err != nil || s <= 0 {
+				return fmt.Errorf("invalid step %q", step)
+			}
+		}
+
+		if rangePart == "*" || (allowQuestion && rangePart == "?") {
+			continue
+		}
+
+		low, high, isRange := strings.Cut(rangePart, "-")
+		start, err := value(low)
+		if err != nil {
+			return err
+		}
+		if !isRange {
+			continue
+		}
+		end, err := value(high)
+		if err != nil {
+			return err
+		}
+		if start > end {
+			return fmt.Errorf("range %q is backwards", rangePart)
+		}
+	}
+	return nil
+}
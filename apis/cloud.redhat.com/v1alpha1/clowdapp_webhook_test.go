@@ -0,0 +1,390 @@
+package v1alpha1
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestValidateCronSchedule(t *testing.T) {
+	for _, schedule := range []string{
+		"*/5 * * * *",
+		"0 3 * * 1-5",
+		"30 2 1,15 * ?",
+		"0 0 * JAN-mar sun",
+		"@daily",
+		"@every 1h30m",
+	} {
+		assert.NoError(t, validateCronSchedule(schedule), schedule)
+	}
+
+	for _, schedule := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* * 0 * *",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"? * * * *",
+		"* * * foo *",
+		"@fortnightly",
+		"@every never",
+	} {
+		assert.Error(t, validateCronSchedule(schedule), schedule)
+	}
+}
+
+func TestValidateJobs(t *testing.T) {
+	app := &ClowdApp{
+		Spec: ClowdAppSpec{
+			Jobs: []Job{
+				{Name: "invoked"},
+				{Name: "nightly", Schedule: "0 2 * * *", RestartPolicy: core.RestartPolicyOnFailure},
+			},
+		},
+	}
+	assert.Empty(t, validateJobs(app))
+
+	app.Spec.Jobs[0].RestartPolicy = core.RestartPolicyAlways
+	app.Spec.Jobs[1].Schedule = "0 25 * * *"
+	errs := validateJobs(app)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "spec.Jobs[0].RestartPolicy", errs[0].Field)
+		assert.Equal(t, "spec.Jobs[1].Schedule", errs[1].Field)
+	}
+}
+
+func TestValidateSidecars(t *testing.T) {
+	app := &ClowdApp{
+		Spec: ClowdAppSpec{
+			Deployments: []Deployment{{
+				Name: "api",
+				PodSpec: PodSpec{Sidecars: []Sidecar{
+					{Name: "token-refresher", Enabled: true},
+					{Name: "otel-collector", Enabled: true},
+				}},
+			}},
+		},
+	}
+	app.Name = "puptoo"
+	assert.Empty(t, validateSidecars(app))
+
+	app.Spec.Deployments = append(app.Spec.Deployments, Deployment{
+		Name: "collector",
+		PodSpec: PodSpec{Sidecars: []Sidecar{
+			{Name: "splunk"},
+			{Name: "otel-collector"},
+			{Name: "otel-collector"},
+		}},
+	})
+	app.Name = "otel"
+	errs := validateSidecars(app)
+	if assert.Len(t, errs, 3) {
+		assert.Equal(t, "spec.Deployment[1].Sidecars[0]", errs[0].Field)
+		assert.Contains(t, errs[1].Detail, "collides with the main container otel-collector")
+		assert.Equal(t, "spec.Deployment[1].Sidecars[2]", errs[2].Field)
+	}
+}
+
+func TestValidateDeploymentStrategy(t *testing.T) {
+	one, zero, zeroPercent := intstr.FromInt(1), intstr.FromInt(0), intstr.FromString("0%")
+
+	app := &ClowdApp{
+		Spec: ClowdAppSpec{
+			Deployments: []Deployment{{
+				Name:               "worker",
+				DeploymentStrategy: &DeploymentStrategy{Type: apps.RecreateDeploymentStrategyType},
+			}, {
+				Name: "api",
+				DeploymentStrategy: &DeploymentStrategy{
+					Type:           apps.RollingUpdateDeploymentStrategyType,
+					MaxSurge:       &one,
+					MaxUnavailable: &zero,
+				},
+				WebServices: WebServices{Public: PublicWebService{Enabled: true}},
+			}},
+		},
+	}
+	assert.Empty(t, validateDeploymentStrategy(app))
+
+	app.Spec.Deployments[0].DeploymentStrategy.MaxUnavailable = &one
+	app.Spec.Deployments[1].DeploymentStrategy.MaxSurge = &zeroPercent
+	app.Spec.Deployments = append(app.Spec.Deployments, Deployment{
+		Name:               "lock",
+		Singleton:          true,
+		DeploymentStrategy: &DeploymentStrategy{Type: apps.RollingUpdateDeploymentStrategyType},
+	}, Deployment{
+		Name:               "private",
+		DeploymentStrategy: &DeploymentStrategy{PrivateStrategy: apps.RecreateDeploymentStrategyType, MaxSurge: &one},
+	})
+	errs := validateDeploymentStrategy(app)
+	if assert.Len(t, errs, 4) {
+		assert.Equal(t, "spec.Deployment[0].DeploymentStrategy.MaxUnavailable", errs[0].Field)
+		assert.Contains(t, errs[1].Detail, "cannot both be 0")
+		assert.Equal(t, "spec.Deployment[2].DeploymentStrategy.Type", errs[2].Field)
+		assert.Equal(t, "spec.Deployment[3].DeploymentStrategy.MaxSurge", errs[3].Field)
+	}
+}
+
+func TestValidateAutoScalers(t *testing.T) {
+	zero, two, twenty := int32(0), int32(2), int32(20)
+
+	app := &ClowdApp{
+		Spec: ClowdAppSpec{
+			Deployments: []Deployment{
+				{Name: "plain"},
+				{Name: "defaults", AutoScaler: &AutoScaler{}},
+				{Name: "idle", AutoScaler: &AutoScaler{MinReplicaCount: &zero, MaxReplicaCount: &two}},
+			},
+		},
+	}
+	assert.Empty(t, validateAutoScalers(app))
+
+	app.Spec.Deployments = append(app.Spec.Deployments, Deployment{
+		Name:       "inverted",
+		AutoScaler: &AutoScaler{MinReplicaCount: &twenty, MaxReplicaCount: &two},
+	}, Deployment{
+		// above the default maximum
+		Name:       "large",
+		AutoScaler: &AutoScaler{MinReplicaCount: &twenty},
+	})
+	errs := validateAutoScalers(app)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "spec.Deployment[3].AutoScaler.MinReplicaCount", errs[0].Field)
+		assert.Contains(t, errs[0].Detail, "maxReplicaCount (2)")
+		assert.Equal(t, "spec.Deployment[4].AutoScaler.MinReplicaCount", errs[1].Field)
+		assert.Contains(t, errs[1].Detail, "maxReplicaCount (10)")
+	}
+}
+
+func TestValidateStatefulSets(t *testing.T) {
+	claim := VolumeClaimTemplate{Name: "data", Size: resource.MustParse("1Gi")}
+
+	app := &ClowdApp{
+		Spec: ClowdAppSpec{
+			Deployments: []Deployment{
+				{Name: "plain", AutoScaler: &AutoScaler{}},
+				{Name: "scheduler", Kind: "statefulset", VolumeClaimTemplates: []VolumeClaimTemplate{claim}},
+			},
+		},
+	}
+	assert.Empty(t, validateStatefulSets(app))
+
+	app.Spec.Deployments = append(app.Spec.Deployments, Deployment{
+		Name:       "scaled",
+		Kind:       "statefulset",
+		AutoScaler: &AutoScaler{},
+	}, Deployment{
+		Name:                 "claims",
+		VolumeClaimTemplates: []VolumeClaimTemplate{claim},
+	}, Deployment{
+		Name:                 "duplicate",
+		Kind:                 "statefulset",
+		VolumeClaimTemplates: []VolumeClaimTemplate{claim, claim},
+	})
+	errs := validateStatefulSets(app)
+	if assert.Len(t, errs, 3) {
+		assert.Equal(t, "spec.Deployment[2].AutoScaler", errs[0].Field)
+		assert.Contains(t, errs[0].Detail, "keda autoscaler")
+		assert.Equal(t, "spec.Deployment[3].VolumeClaimTemplates", errs[1].Field)
+		assert.Equal(t, "spec.Deployment[4].VolumeClaimTemplates[1].Name", errs[2].Field)
+	}
+}
+
+func TestValidateStatefulSetUpdate(t *testing.T) {
+	claim := VolumeClaimTemplate{Name: "data", Size: resource.MustParse("1Gi")}
+
+	old := &ClowdApp{
+		Spec: ClowdAppSpec{
+			Deployments: []Deployment{
+				{Name: "plain"},
+				{Name: "scheduler", Kind: "statefulset", VolumeClaimTemplates: []VolumeClaimTemplate{claim}},
+			},
+		},
+	}
+
+	app := old.DeepCopy()
+	app.Spec.Deployments[0].Kind = "deployment"
+	app.Spec.Deployments[1].PodSpec.Image = "scheduler:v2"
+	app.Spec.Deployments = append(app.Spec.Deployments, Deployment{
+		Name:                 "new",
+		Kind:                 "statefulset",
+		VolumeClaimTemplates: []VolumeClaimTemplate{claim},
+	})
+	assert.Empty(t, validateStatefulSetUpdate(old, app))
+
+	app = old.DeepCopy()
+	app.Spec.Deployments[0].Kind = "statefulset"
+	app.Spec.Deployments[1].VolumeClaimTemplates[0].Size = resource.MustParse("2Gi")
+	errs := validateStatefulSetUpdate(old, app)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "spec.Deployment[0].Kind", errs[0].Field)
+		assert.Equal(t, "spec.Deployment[1].VolumeClaimTemplates", errs[1].Field)
+	}
+
+	app = old.DeepCopy()
+	app.Spec.Deployments[1].Kind = ""
+	app.Spec.Deployments[1].VolumeClaimTemplates = nil
+	errs = validateStatefulSetUpdate(old, app)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.Deployment[1].Kind", errs[0].Field)
+	}
+	assert.Error(t, app.ValidateUpdate(old))
+}
+
+func TestValidateServiceAccounts(t *testing.T) {
+	app := &ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test"},
+		Spec: ClowdAppSpec{
+			Deployments: []Deployment{
+				{Name: "api", PodSpec: PodSpec{ServiceAccountName: "existing"}},
+				{Name: "worker", PodSpec: PodSpec{ServiceAccountName: "reader", CreateServiceAccount: true, ServiceAccountAccessLevel: "view"}},
+				{Name: "processor", PodSpec: PodSpec{ServiceAccountName: "reader", CreateServiceAccount: true, ServiceAccountAccessLevel: "view"}},
+			},
+		},
+	}
+	assert.Empty(t, validateServiceAccounts(app))
+
+	app.Spec.Deployments = append(app.Spec.Deployments, Deployment{
+		Name:    "clash",
+		PodSpec: PodSpec{ServiceAccountName: "puptoo-api"},
+	}, Deployment{
+		Name:    "unnamed",
+		PodSpec: PodSpec{CreateServiceAccount: true},
+	}, Deployment{
+		Name:    "level",
+		PodSpec: PodSpec{ServiceAccountName: "existing", ServiceAccountAccessLevel: "edit"},
+	}, Deployment{
+		Name:    "mismatch",
+		PodSpec: PodSpec{ServiceAccountName: "reader", CreateServiceAccount: true, ServiceAccountAccessLevel: "edit"},
+	}, Deployment{
+		Name:           "access",
+		K8sAccessLevel: "edit",
+		PodSpec:        PodSpec{ServiceAccountName: "existing"},
+	})
+	app.Spec.Jobs = []Job{{Name: "job", PodSpec: PodSpec{ServiceAccountName: "reader"}}}
+
+	errs := validateServiceAccounts(app)
+	if assert.Len(t, errs, 7) {
+		assert.Equal(t, "spec.Deployment[3].PodSpec.ServiceAccountName", errs[0].Field)
+		assert.Contains(t, errs[0].Detail, "Clowder manages")
+		assert.Equal(t, "spec.Deployment[4].PodSpec.ServiceAccountName", errs[1].Field)
+		assert.Equal(t, field.ErrorTypeRequired, errs[1].Type)
+		assert.Equal(t, "spec.Deployment[5].PodSpec.ServiceAccountAccessLevel", errs[2].Field)
+		assert.Equal(t, "spec.Deployment[5].PodSpec.ServiceAccountName", errs[3].Field)
+		assert.Equal(t, "spec.Deployment[6].PodSpec.ServiceAccountName", errs[4].Field)
+		assert.Equal(t, "spec.Deployment[7].K8sAccessLevel", errs[5].Field)
+		assert.Equal(t, "spec.Jobs[0].PodSpec.ServiceAccountName", errs[6].Field)
+	}
+}
+
+// unavailableReader fails every read, like an API server that can't be reached.
+type unavailableReader struct {
+	client.Reader
+}
+
+func (unavailableReader) Get(_ context.Context, _ client.ObjectKey, _ client.Object, _ ...client.GetOption) error {
+	return errors.New("connection refused")
+}
+
+func environmentTestApp() *ClowdApp {
+	return &ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test"},
+		Spec: ClowdAppSpec{
+			EnvName:     "env",
+			KafkaTopics: []KafkaTopicSpec{{TopicName: "inventory"}},
+			Database:    DatabaseSpec{Name: "puptoo"},
+		},
+	}
+}
+
+func TestValidateEnvironment(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, AddToScheme(scheme))
+
+	env := &ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env"},
+		Spec: ClowdEnvironmentSpec{
+			Providers: ProvidersConfig{
+				Kafka:    KafkaConfig{Mode: "operator"},
+				Database: DatabaseConfig{Mode: "local"},
+			},
+		},
+	}
+
+	defer func() { webhookReader = nil }()
+	webhookReader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(env).Build()
+
+	app := environmentTestApp()
+	assert.Empty(t, validateEnvironment(app))
+
+	app.Spec.EnvName = "missing"
+	errs := validateEnvironment(app)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.EnvName", errs[0].Field)
+	}
+
+	// the environment can't be read, the app is let through
+	webhookReader = unavailableReader{}
+	assert.Empty(t, validateEnvironment(app))
+}
+
+func TestValidateAgainstEnvironment(t *testing.T) {
+	env := &ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env"}}
+	env.Spec.Providers.Kafka.Mode = "none"
+	env.Spec.Providers.Database.Mode = "none"
+	env.Spec.Providers.ObjectStore.Mode = "minio"
+
+	errs := validateAgainstEnvironment(environmentTestApp(), env)
+	if assert.Len(t, errs, 2) {
+		assert.Equal(t, "spec.KafkaTopics", errs[0].Field)
+		assert.Contains(t, errs[0].Detail, "environment env does not provide kafka")
+		assert.Equal(t, "spec.Database", errs[1].Field)
+	}
+
+	env.Spec.Providers.Kafka.Mode = "operator"
+	env.Spec.Providers.Database.Mode = "local"
+	assert.Empty(t, validateAgainstEnvironment(environmentTestApp(), env))
+
+	// a deployment can only be scaled to zero by keda
+	zero := int32(0)
+	app := environmentTestApp()
+	app.Spec.Deployments = []Deployment{{Name: "processor", AutoScaler: &AutoScaler{MinReplicaCount: &zero}}}
+
+	env.Spec.Providers.AutoScaler.Mode = "hpa"
+	errs = validateAgainstEnvironment(app, env)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.Deployment[0].AutoScaler.MinReplicaCount", errs[0].Field)
+		assert.Contains(t, errs[0].Detail, `its mode is "hpa"`)
+	}
+
+	env.Spec.Providers.AutoScaler.Mode = "enabled"
+	assert.Empty(t, validateAgainstEnvironment(app, env))
+}
+
+func TestValidateUpdateWithoutEnvironment(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, AddToScheme(scheme))
+
+	defer func() { webhookReader = nil }()
+	webhookReader = fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	old := environmentTestApp()
+	app := environmentTestApp()
+	app.Finalizers = []string{}
+	assert.NoError(t, app.ValidateUpdate(old), "a metadata only update was blocked by the missing environment")
+
+	app.Spec.InMemoryDB = true
+	assert.Error(t, app.ValidateUpdate(old))
+}
@@ -92,10 +92,40 @@ type TLS struct {
 	Enabled     bool  `json:"enabled,omitempty"`
 	Port        int32 `json:"port,omitempty"`
 	PrivatePort int32 `json:"privatePort,omitempty"`
+
+	// The source of the serving certs of the TLS sidecar, either (*_none_*)
+	// where the OpenShift service CA issues them, (*_self-signed_*) where
+	// Clowder signs them with a CA it generates for the environment, or
+	// (*_cert-manager_*) where Clowder requests them from cert-manager with a
+	// Certificate. Defaults to none.
+	Mode TLSMode `json:"mode,omitempty"`
+
+	// The cert-manager issuer of the serving certs, required in
+	// (*_cert-manager_*) mode.
+	IssuerRef *TLSIssuerRef `json:"issuerRef,omitempty"`
+}
+
+// TLSMode details the source of the serving certs of the TLS sidecar
+// +kubebuilder:validation:Enum=none;self-signed;cert-manager
+type TLSMode string
+
+// TLSIssuerRef references the cert-manager Issuer or ClusterIssuer that issues
+// the serving certs of the TLS sidecar.
+type TLSIssuerRef struct {
+	// The name of the issuer.
+	Name string `json:"name"`
+
+	// The kind of the issuer, either Issuer or ClusterIssuer, defaults to
+	// Issuer. An Issuer must be in the namespace of the ClowdApp.
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	Kind string `json:"kind,omitempty"`
+
+	// The API group of the issuer, defaults to cert-manager.io.
+	Group string `json:"group,omitempty"`
 }
 
 // MetricsMode details the mode of operation of the Clowder Metrics Provider
-// +kubebuilder:validation:Enum=none;operator;app-interface
+// +kubebuilder:validation:Enum=none;operator;app-interface;service-monitor
 type MetricsMode string
 
 type PrometheusConfig struct {
@@ -120,16 +150,56 @@ type MetricsConfig struct {
 	//  (*_none_*), which disables metrics service generation, or
 	// (*_operator_*) where services and probes are generated.
 	// (*_app-interface_*) where services and probes are generated for app-interface.
+	// (*_service-monitor_*) where services are generated along with a ServiceMonitor
+	// for each of them, for a Prometheus Operator already running in the cluster.
 	Mode MetricsMode `json:"mode"`
 
 	// Prometheus specific configuration
 	Prometheus PrometheusConfig `json:"prometheus,omitempty"`
+
+	// Configures scraping of metrics endpoints using a projected service account
+	// token issued for a specific audience.
+	ScrapeToken MetricsScrapeTokenConfig `json:"scrapeToken,omitempty"`
+
+	// Generates a Grafana dashboard ConfigMap for each app with metrics services.
+	Dashboards MetricsDashboardsConfig `json:"dashboards,omitempty"`
+}
+
+// MetricsScrapeTokenConfig configures the projected service account token used by
+// Prometheus to scrape secured metrics endpoints.
+type MetricsScrapeTokenConfig struct {
+	// Enables scraping with a projected service account token, defaults to false.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// The audience the projected token is issued for, metrics endpoints should
+	// only accept tokens carrying this audience.
+	// +kubebuilder:validation:Pattern:="^[a-zA-Z0-9][-a-zA-Z0-9_.]*$"
+	Audience string `json:"audience,omitempty"`
+}
+
+// MetricsDashboardsConfig configures the Grafana dashboard ConfigMaps generated for the
+// apps of the environment, which the Grafana dashboard sidecar loads by their label.
+type MetricsDashboardsConfig struct {
+	// Enables the generation of the dashboard ConfigMaps, defaults to false.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// The label the Grafana dashboard sidecar selects ConfigMaps by, defaults to
+	// grafana_dashboard.
+	Label string `json:"label,omitempty"`
+
+	// The value of the label, defaults to "1".
+	LabelValue string `json:"labelValue,omitempty"`
 }
 
 // KafkaMode details the mode of operation of the Clowder Kafka Provider
-// +kubebuilder:validation:Enum=managed-ephem;managed;operator;app-interface;local;none
+// +kubebuilder:validation:Enum=managed-ephem;managed;msk;operator;app-interface;local;none
 type KafkaMode string
 
+// KafkaTopicNamingStrategy details how the topics requested by the apps are named on the Kafka
+// cluster.
+// +kubebuilder:validation:Enum=app-interface;namespaced;sharded
+type KafkaTopicNamingStrategy string
+
 // KafkaClusterConfig defines options related to the Kafka cluster managed/monitored by Clowder
 type KafkaClusterConfig struct {
 	// Defines the kafka cluster name (default: <ClowdEnvironment Name>-<UID>)
@@ -164,6 +234,28 @@ type KafkaClusterConfig struct {
 
 	// Resource Limits
 	Resources strimzi.KafkaSpecKafkaResources `json:"resources,omitempty"`
+
+	// Autoscaling declares the range of brokers the kafka cluster may be scaled
+	// within. When set, the number of brokers is reconciled from its target
+	// rather than from Replicas, which then only applies to zookeeper.
+	Autoscaling *KafkaClusterAutoscaling `json:"autoscaling,omitempty"`
+}
+
+// KafkaClusterAutoscaling defines the desired number of brokers of a Kafka cluster
+// managed by Clowder
+type KafkaClusterAutoscaling struct {
+	// The minimum number of brokers. If unset, default is '1'
+	// +kubebuilder:validation:Minimum:=1
+	MinReplicas int32 `json:"minReplicas,omitempty"`
+
+	// The maximum number of brokers. If unset, the number of brokers is not
+	// bounded from above
+	// +kubebuilder:validation:Minimum:=1
+	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+
+	// The number of brokers the cluster should currently run, it is kept within
+	// minReplicas and maxReplicas. If unset, default is the cluster's replicas
+	TargetReplicas int32 `json:"targetReplicas,omitempty"`
 }
 
 // KafkaConnectClusterConfig defines options related to the Kafka Connect cluster managed/monitored by Clowder
@@ -205,13 +297,28 @@ type KafkaConfig struct {
 	// KafkaTopic CRs and place them in the Kafka cluster's namespace described in the configuration,
 	// (*_app-interface_*) which simply passes the topic names through to the App's
 	// cdappconfig.json and expects app-interface to have created the relevant
-	// topics, and (*_local_*) where a small instance of Kafka is created in the desired cluster namespace
-	// and configured to auto-create topics.
+	// topics, (*_local_*) where a small instance of Kafka is created in the desired cluster namespace
+	// and configured to auto-create topics, and (*_msk_*) which reads the brokers of an Amazon MSK
+	// cluster from a secret and passes the topic names through unchanged.
 	Mode KafkaMode `json:"mode"`
 
 	// EnableLegacyStrimzi disables TLS + user auth
 	EnableLegacyStrimzi bool `json:"enableLegacyStrimzi,omitempty"`
 
+	// ConsumerGroupACLs restricts the group ACL of each app's KafkaUser to the app's consumer
+	// groups rather than allowing all groups. Apps that do not list their consumer groups get a
+	// group named <env>-<app>. Only used in (*_operator_*) mode without legacy Strimzi.
+	ConsumerGroupACLs bool `json:"consumerGroupACLs,omitempty"`
+
+	// TopicNamingStrategy sets how the topics requested by the apps are named on the Kafka
+	// cluster in the (*_operator_*) and (*_managed-ephem_*) modes: (*_app-interface_*) uses the
+	// requested name unchanged, (*_namespaced_*) prefixes it with the name of the environment and
+	// (*_sharded_*) appends a hash of the name of the environment. Topics are shared by the apps of
+	// an environment, so the names never depend on the namespace of an app. If unset, operator
+	// mode uses the requested name, or the complex topic names if that feature is enabled, and
+	// managed-ephem mode prefixes it with the name of the environment.
+	TopicNamingStrategy KafkaTopicNamingStrategy `json:"topicNamingStrategy,omitempty"`
+
 	// If using the (*_local_*) or (*_operator_*) mode and PVC is set to true, this sets the provisioned
 	// Kafka instance to use a PVC instead of emptyDir for its volumes.
 	PVC bool `json:"pvc,omitempty"`
@@ -228,6 +335,10 @@ type KafkaConfig struct {
 	// Managed topic prefix for the managed cluster. Only used in (*_managed_*) mode.
 	ManagedPrefix string `json:"managedPrefix,omitempty"`
 
+	// Defines the secret reference holding the bootstrap brokers and auth type of the MSK
+	// cluster. Only used in (*_msk_*) mode.
+	MSKSecretRef NamespacedName `json:"mskSecretRef,omitempty"`
+
 	// Defines the secret reference for the Ephemeral Managed Kafka mode. Only used in (*_managed-ephem_*) mode.
 	EphemManagedSecretRef NamespacedName `json:"ephemManagedSecretRef,omitempty"`
 
@@ -255,7 +366,7 @@ type KafkaConfig struct {
 }
 
 // DatabaseMode details the mode of operation of the Clowder Database Provider
-// +kubebuilder:validation:Enum=shared;app-interface;local;none
+// +kubebuilder:validation:Enum=shared;app-interface;local;managed;none
 type DatabaseMode string
 
 // DatabaseConfig configures the Clowder provider controlling the creation of
@@ -263,10 +374,16 @@ type DatabaseMode string
 type DatabaseConfig struct {
 	// The mode of operation of the Clowder Database Provider. Valid options are:
 	// (*_app-interface_*) where the provider will pass through database credentials
-	// found in the secret defined by the database name in the ClowdApp, and (*_local_*)
-	// where the provider will spin up a local instance of the database.
+	// found in the secret defined by the database name in the ClowdApp, (*_local_*)
+	// where the provider will spin up a local instance of the database, and (*_managed_*)
+	// where the provider will pass through the credentials of an external database
+	// instance found in the secret referenced by secretRef.
 	Mode DatabaseMode `json:"mode"`
 
+	// Defines the secret reference for the external database instance. The secret must
+	// contain the host, port, user, password and sslmode keys. Only used in (*_managed_*) mode.
+	SecretRef NamespacedName `json:"secretRef,omitempty"`
+
 	// Indicates where Clowder will fetch the database CA certificate bundle from. Currently only used in
 	// (*_app-interface_*) mode. If none is specified, the AWS RDS combined CA bundle is used.
 	// +kubebuilder:validation:Pattern=`^https?:\/\/.+$`
@@ -275,10 +392,38 @@ type DatabaseConfig struct {
 	// If using the (*_local_*) mode and PVC is set to true, this instructs the local
 	// Database instance to use a PVC instead of emptyDir for its volumes.
 	PVC bool `json:"pvc,omitempty"`
+
+	// PgBouncer configures a connection pooler which is injected as a sidecar into
+	// the deployments of apps requesting a database.
+	PgBouncer PgBouncerConfig `json:"pgBouncer,omitempty"`
+}
+
+// PgBouncerPoolMode details when a server connection is released back to the pool
+// +kubebuilder:validation:Enum=session;transaction;statement
+type PgBouncerPoolMode string
+
+// PgBouncerConfig configures the PgBouncer sidecar of the Clowder Database Provider.
+type PgBouncerConfig struct {
+	// If set to true, a PgBouncer sidecar is added to every deployment of an app
+	// requesting a database and the database config of the app points at it. The
+	// sidecar proxies to the database configured by the mode of the provider.
+	// Apps that define jobs are left connecting to the database directly, as their
+	// pods can't run the sidecar.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// The pool mode of PgBouncer, defaults to transaction.
+	PoolMode PgBouncerPoolMode `json:"poolMode,omitempty"`
+
+	// The number of server connections allowed per user and database pair,
+	// defaults to 20.
+	DefaultPoolSize int32 `json:"defaultPoolSize,omitempty"`
+
+	// The number of client connections allowed, defaults to 100.
+	MaxClientConn int32 `json:"maxClientConn,omitempty"`
 }
 
 // LoggingMode details the mode of operation of the Clowder Logging Provider
-// +kubebuilder:validation:Enum=app-interface;null;none
+// +kubebuilder:validation:Enum=app-interface;splunk;null;none
 type LoggingMode string
 
 // LoggingConfig configures the Clowder provider controlling the creation of
@@ -286,8 +431,15 @@ type LoggingMode string
 type LoggingConfig struct {
 	// The mode of operation of the Clowder Logging Provider. Valid options are:
 	// (*_app-interface_*) where the provider will pass through cloudwatch credentials
-	// to the app configuration, and (*_none_*) where no logging will be configured.
+	// to the app configuration, (*_splunk_*) where the provider will pass through the
+	// Splunk HEC credentials found in the secret referenced by secretRef, and (*_none_*)
+	// where no logging will be configured.
 	Mode LoggingMode `json:"mode"`
+
+	// Defines the secret reference for the Splunk HEC credentials. The secret must contain
+	// the endpoint and token keys, and may contain the index and source keys. Only used in
+	// (*_splunk_*) mode.
+	SecretRef NamespacedName `json:"secretRef,omitempty"`
 }
 
 // ServiceMeshMode just determines if we enable or disable the service mesh
@@ -320,6 +472,12 @@ type ObjectStoreConfig struct {
 	// If using the (*_local_*) mode and PVC is set to true, this instructs the local
 	// Database instance to use a PVC instead of emptyDir for its volumes.
 	PVC bool `json:"pvc,omitempty"`
+
+	// If using the (*_minio_*) mode and PrefixBuckets is set to true, the buckets
+	// requested by an app are created with the namespace of the app as a prefix,
+	// so that apps in different namespaces sharing the MinIO instance don't
+	// collide. The requested name is still passed to the app as the requestedName.
+	PrefixBuckets bool `json:"prefixBuckets,omitempty"`
 }
 
 // FeatureFlagsMode details the mode of operation of the Clowder FeatureFlags
@@ -368,16 +526,34 @@ type InMemoryDBConfig struct {
 	// If using the (*_local_*) mode and PVC is set to true, this instructs the local
 	// Database instance to use a PVC instead of emptyDir for its volumes.
 	PVC bool `json:"pvc,omitempty"`
+
+	// MaxMemory is the maxmemory of the Redis instances in (*_redis_*) mode, in
+	// Redis' units, e.g. 256mb. The memory limit of the Redis pods is set a
+	// quarter above it so that Redis evicts keys before the pod is OOM killed.
+	// If unset, Redis' memory is unbounded.
+	// +kubebuilder:validation:Pattern=`^[0-9]+([kKmMgG][bB]?)?$`
+	MaxMemory string `json:"maxMemory,omitempty"`
+
+	// MaxMemoryPolicy is the policy Redis uses to evict keys once MaxMemory is
+	// reached in (*_redis_*) mode, defaults to Redis' default of noeviction.
+	MaxMemoryPolicy RedisMaxMemoryPolicy `json:"maxMemoryPolicy,omitempty"`
 }
 
+// RedisMaxMemoryPolicy is a Redis eviction policy.
+// +kubebuilder:validation:Enum={"noeviction", "allkeys-lru", "allkeys-lfu", "allkeys-random", "volatile-lru", "volatile-lfu", "volatile-random", "volatile-ttl"}
+type RedisMaxMemoryPolicy string
+
 // AutoScaler mode enabled or disabled the autoscaler. The key "keda" is deprecated but preserved for backwards compatibility
-// +kubebuilder:validation:Enum={"none", "enabled", "keda"}
+// +kubebuilder:validation:Enum={"none", "enabled", "keda", "hpa"}
 type AutoScalerMode string
 
 // AutoScalerConfig configures the Clowder provider controlling the creation of
 // AutoScaler configuration.
 type AutoScalerConfig struct {
-	// Enable the autoscaler feature
+	// Enable the autoscaler feature, either (*_enabled_*) where a keda ScaledObject is
+	// created for each deployment with an autoScaler, or (*_hpa_*) where a native HPA is
+	// created instead for clusters that do not run keda, which only supports the cpu and
+	// memory triggers.
 	Mode AutoScalerMode `json:"mode,omitempty"`
 }
 
@@ -399,11 +575,32 @@ type TestingConfig struct {
 	// (*_app_*) -- only the ClowdApp's config is mounted to the pod
 	// (*_environment_*) -- the config for all apps in the env are mounted
 	ConfigAccess ConfigAccessMode `json:"configAccess"`
+
+	// The number of times a failed IQE test run is retried, each retry invokes a
+	// new job. Defaults to 0, failed runs are not retried.
+	// +kubebuilder:validation:Minimum=0
+	Retries int32 `json:"retries,omitempty"`
+
+	// How long an IQE test run waits for the backends provisioned for the app,
+	// like its Kafka topics and local database, to become ready before the
+	// ClowdJobInvocation fails. Defaults to 600 seconds.
+	// +kubebuilder:validation:Minimum=0
+	BackendsTimeoutSeconds int32 `json:"backendsTimeoutSeconds,omitempty"`
 }
 
 type IqeConfig struct {
 	ImageBase string `json:"imageBase"`
 
+	// Defines a full image, e.g. quay.io/org/iqe-tests@sha256:<digest>, used
+	// for IQE jobs in this environment in place of the imageBase tagged with
+	// the app's IQE plugin. An image or imageTag set on a ClowdJobInvocation
+	// still takes precedence.
+	Image string `json:"image,omitempty"`
+
+	// When set, IQE jobs are refused unless their image is pinned by digest
+	// rather than a mutable tag.
+	RequireDigest bool `json:"requireDigest,omitempty"`
+
 	// A pass-through of a resource requirements in k8s ResourceRequirements
 	// format. If omitted, the default resource requirements from the
 	// ClowdEnvironment will be used.
@@ -444,6 +641,14 @@ type ClowdEnvironmentSpec struct {
 	// resources should end up, this is particularly important in (*_local_*) mode.
 	TargetNamespace string `json:"targetNamespace,omitempty"`
 
+	// Labels and annotations that are kept on the target namespace, e.g. for
+	// cost and policy tooling.
+	TargetNamespaceMetadata TargetNamespaceMetadata `json:"targetNamespaceMetadata,omitempty"`
+
+	// A LimitRange kept in the target namespace, which applies container
+	// resource defaults and maximums to every pod in it, including ad-hoc ones.
+	LimitRange LimitRangeConfig `json:"limitRange,omitempty"`
+
 	// A ProvidersConfig object, detailing the setup and configuration of all the
 	// providers used in this ClowdEnvironment.
 	Providers ProvidersConfig `json:"providers"`
@@ -452,24 +657,207 @@ type ClowdEnvironmentSpec struct {
 	// event that they omitted from a PodSpec inside a ClowdApp.
 	ResourceDefaults core.ResourceRequirements `json:"resourceDefaults"`
 
+	// Defines a set of named resource requirements (e.g. small, medium, large)
+	// that a Deployment inside a ClowdApp can reference via its resourcePreset
+	// field instead of specifying requests/limits directly.
+	ResourcePresets map[string]core.ResourceRequirements `json:"resourcePresets,omitempty"`
+
+	// A list of resource defaults matched against the name of each Deployment
+	// inside a ClowdApp. The first rule whose pattern matches is used in place
+	// of the resourceDefaults, explicit resources on the PodSpec still win.
+	ResourceDefaultRules []ResourceDefaultRule `json:"resourceDefaultRules,omitempty"`
+
+	// The number of replicas of the Deployments inside the ClowdApps of the
+	// environment that don't set replicas, defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	DefaultReplicas *int32 `json:"defaultReplicas,omitempty"`
+
+	// The fewest replicas a Deployment inside a ClowdApp of the environment may
+	// run, also applied to the minimum of its autoscaler. Replicas set to 0 for
+	// a manual scale down are always allowed.
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// The most replicas a Deployment inside a ClowdApp of the environment may
+	// run, also applied to the maximum of its autoscaler.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+
+	// Determines how a Deployment whose replicas or autoscaler fall outside of
+	// minReplicas and maxReplicas is handled, either (*_clamp_*) where they are
+	// brought within the range and a warning is logged (default), or
+	// (*_reject_*) where the ClowdApp fails to reconcile until it is fixed.
+	ReplicaPolicy ReplicaPolicy `json:"replicaPolicy,omitempty"`
+
 	ServiceConfig ServiceConfig `json:"serviceConfig,omitempty"`
 
+	// Determines how ClowdApps are reconciled when a secret required by one of
+	// the providers is missing, either (*_block-app_*) where nothing is rendered
+	// for the app until the secret exists (default), or (*_skip-provider_*) where
+	// only the affected provider's config is left out.
+	MissingSecretPolicy MissingSecretPolicy `json:"missingSecretPolicy,omitempty"`
+
+	// A list of ConfigMaps that are copied into the namespace of every
+	// ClowdApp in the environment and mounted into all of their deployments.
+	SharedConfigMaps []SharedConfigMap `json:"sharedConfigMaps,omitempty"`
+
+	// A list of image pull secrets, existing in the namespace of each ClowdApp,
+	// that are referenced by every pod generated for the ClowdApps in the
+	// environment. Secrets living elsewhere can be copied into the namespaces
+	// with the pullSecrets of the providers.
+	ImagePullSecrets []string `json:"imagePullSecrets,omitempty"`
+
+	// A list of resource kinds, for example Service, that are rendered for every
+	// ClowdApp in the environment but never applied. Any drift between the rendered
+	// and the live resources is only reported.
+	IgnoredKinds []string `json:"ignoredKinds,omitempty"`
+
+	// A list of rules that rewrite the images of the containers generated for
+	// the ClowdApps in the environment, for example to pull them from a mirror.
+	// The rules are tried in order and the first one whose prefix matches the
+	// image replaces that prefix, images that match no rule are left alone.
+	ImageRegistryOverrides []ImageRegistryOverride `json:"imageRegistryOverrides,omitempty"`
+
+	// Labels are added to the Deployments, Services and pods generated for the
+	// ClowdApps in the environment, e.g. for monitoring and cost allocation.
+	// The labels managed by Clowder, such as app and pod, take precedence.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to the Deployments, Services and pods generated for
+	// the ClowdApps in the environment. Annotations managed by Clowder take
+	// precedence.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
 	// Disabled turns off reconciliation for this ClowdEnv
 	Disabled bool `json:"disabled,omitempty"`
 }
 
+// ImageRegistryOverride rewrites the images that start with a prefix.
+type ImageRegistryOverride struct {
+	// The start of the images to rewrite, for example quay.io/cloudservices/
+	// +kubebuilder:validation:MinLength=1
+	Prefix string `json:"prefix"`
+
+	// The value the prefix is replaced with, for example
+	// registry.internal/cloudservices/
+	Replacement string `json:"replacement"`
+}
+
+// MissingSecretPolicy details how the reconciler handles a ClowdApp when a
+// secret required by one of the providers is missing.
+// +kubebuilder:validation:Enum=block-app;skip-provider
+type MissingSecretPolicy string
+
+// ReplicaPolicy details how the deployment provider handles a Deployment
+// whose replicas fall outside of the range allowed by the environment.
+// +kubebuilder:validation:Enum=clamp;reject
+type ReplicaPolicy string
+
+// ResourceDefaultRule defines the default resource requirements for the
+// deployments whose name matches the pattern.
+type ResourceDefaultRule struct {
+	// A glob pattern, e.g. "*-worker", matched against the deployment name.
+	Pattern string `json:"pattern"`
+
+	// The default resource requirements in standard k8s format.
+	Resources core.ResourceRequirements `json:"resources"`
+}
+
+// SharedConfigMap references a ConfigMap that is mounted into all app
+// deployments in the environment.
+type SharedConfigMap struct {
+	// Name defines the Name of the ConfigMap.
+	Name string `json:"name"`
+
+	// Namespace defines the Namespace of the ConfigMap, defaults to the
+	// target namespace of the environment.
+	Namespace string `json:"namespace,omitempty"`
+
+	// The path the ConfigMap is mounted at in the app containers.
+	MountPath string `json:"mountPath"`
+}
+
+// TargetNamespaceMetadata defines the labels and annotations that Clowder keeps
+// on the target namespace of the environment.
+type TargetNamespaceMetadata struct {
+	// Labels set on the target namespace.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations set on the target namespace.
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// By default the labels and annotations are only applied to a target
+	// namespace created by Clowder, MergeExisting also merges them onto a
+	// pre-existing namespace given in targetNamespace.
+	MergeExisting bool `json:"mergeExisting,omitempty"`
+}
+
+// LimitRangeConfig defines the container resource defaults and maximums that are
+// enforced in the target namespace of the environment.
+type LimitRangeConfig struct {
+	// Enables the creation of the LimitRange in the target namespace.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Default limits applied to containers that do not set their own.
+	Default core.ResourceList `json:"default,omitempty"`
+
+	// Default requests applied to containers that do not set their own.
+	DefaultRequest core.ResourceList `json:"defaultRequest,omitempty"`
+
+	// The maximum limits a single container may set.
+	Max core.ResourceList `json:"max,omitempty"`
+}
+
 type TokenRefresherConfig struct {
 	// Enables or disables token refresher sidecars
 	Enabled bool `json:"enabled"`
 }
 
+type OtelCollectorConfig struct {
+	// Enables or disables OpenTelemetry collector sidecars
+	Enabled bool `json:"enabled"`
+
+	// The image of the collector, defaults to the upstream contrib distribution
+	Image string `json:"image,omitempty"`
+}
+
 type Sidecars struct {
 	// Sets up Token Refresher configuration
 	TokenRefresher TokenRefresherConfig `json:"tokenRefresher,omitempty"`
+
+	// Sets up OpenTelemetry collector configuration
+	OtelCollector OtelCollectorConfig `json:"otelCollector,omitempty"`
 }
 
 type DeploymentConfig struct {
 	OmitPullPolicy bool `json:"omitPullPolicy,omitempty"`
+
+	// OmitPodIdentityEnv disables the injection of the NAMESPACE and POD_NAME
+	// env vars, sourced from the downward API, into deployment containers.
+	OmitPodIdentityEnv bool `json:"omitPodIdentityEnv,omitempty"`
+
+	// ConfigValidation injects an init container into each deployment that validates the
+	// mounted cdappconfig.json before the app is started.
+	ConfigValidation ConfigValidationConfig `json:"configValidation,omitempty"`
+
+	// Tolerations are set on the pods of every deployment in the environment,
+	// in addition to the tolerations of the deployment itself.
+	Tolerations []core.Toleration `json:"tolerations,omitempty"`
+
+	// NodeSelector is set on the pods of every deployment in the environment,
+	// the node selector of a deployment is merged into it.
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+}
+
+// ConfigValidationConfig configures the init container that validates the mounted app config
+// against the Clowder config schema, failing the pod start if the config is invalid.
+type ConfigValidationConfig struct {
+	// Enables the config validation init container, defaults to false.
+	Enabled bool `json:"enabled,omitempty"`
+
+	// The image of the config validation init container, which runs the validate-config command
+	// of the Clowder manager, defaults to the image in the Clowder config.
+	Image string `json:"image,omitempty"`
 }
 
 // ProvidersConfig defines a group of providers configuration for a ClowdEnvironment.
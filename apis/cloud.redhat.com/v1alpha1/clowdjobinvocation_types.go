@@ -47,9 +47,14 @@ type JobTestingSpec struct {
 
 type IqeJobSpec struct {
 	// By default, Clowder will set the image on the ClowdJob to be the
-	// baseImage:name-of-iqe-plugin, but only the tag can be overridden here
+	// baseImage:name-of-iqe-plugin, but the tag can be overridden here
 	ImageTag string `json:"imageTag,omitempty"`
 
+	// Overrides the whole image used for the ClowdJob, including the
+	// registry, repository and tag or digest, e.g.
+	// quay.io/org/iqe-tests@sha256:<digest>. Takes precedence over imageTag.
+	Image string `json:"image,omitempty"`
+
 	// By default, Clowder will use the plugin name indicated in the ClowdApp's
 	// spec.testing.iqePlugin field. A comma,separated,list of plugins can be supplied
 	// here if you wish you override the plugins.
@@ -129,6 +134,9 @@ type ClowdJobInvocationStatus struct {
 	// JobMap is a map of the job names run by Job invocation and their outcomes
 	JobMap     map[string]JobConditionState `json:"jobMap"`
 	Conditions []clusterv1.Condition        `json:"conditions,omitempty"`
+	// IqeAttempts is the number of times the IQE job was run, it is more
+	// than one when failed runs were retried
+	IqeAttempts int32 `json:"iqeAttempts,omitempty"`
 }
 
 // +kubebuilder:object:root=true
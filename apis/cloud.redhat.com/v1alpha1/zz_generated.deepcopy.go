@@ -23,11 +23,28 @@ package v1alpha1
 
 import (
 	kedav1alpha1 "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"k8s.io/api/autoscaling/v2"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AntiAffinity) DeepCopyInto(out *AntiAffinity) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AntiAffinity.
+func (in *AntiAffinity) DeepCopy() *AntiAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(AntiAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppInfo) DeepCopyInto(out *AppInfo) {
 	*out = *in
@@ -103,6 +120,11 @@ func (in *AutoScaler) DeepCopyInto(out *AutoScaler) {
 		*out = new(kedav1alpha1.Fallback)
 		**out = **in
 	}
+	if in.Behavior != nil {
+		in, out := &in.Behavior, &out.Behavior
+		*out = new(v2.HorizontalPodAutoscalerBehavior)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoScaler.
@@ -231,12 +253,22 @@ func (in *ClowdAppSpec) DeepCopyInto(out *ClowdAppSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.KafkaConsumerGroups != nil {
+		in, out := &in.KafkaConsumerGroups, &out.KafkaConsumerGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	in.Database.DeepCopyInto(&out.Database)
 	if in.ObjectStore != nil {
 		in, out := &in.ObjectStore, &out.ObjectStore
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.FeatureFlagToggles != nil {
+		in, out := &in.FeatureFlagToggles, &out.FeatureFlagToggles
+		*out = make([]FeatureFlagToggle, len(*in))
+		copy(*out, *in)
+	}
 	if in.Dependencies != nil {
 		in, out := &in.Dependencies, &out.Dependencies
 		*out = make([]string, len(*in))
@@ -249,6 +281,11 @@ func (in *ClowdAppSpec) DeepCopyInto(out *ClowdAppSpec) {
 	}
 	out.Testing = in.Testing
 	out.Cyndi = in.Cyndi
+	if in.IgnoredKinds != nil {
+		in, out := &in.IgnoredKinds, &out.IgnoredKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClowdAppSpec.
@@ -272,6 +309,21 @@ func (in *ClowdAppStatus) DeepCopyInto(out *ClowdAppStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulReconcileTime != nil {
+		in, out := &in.LastSuccessfulReconcileTime, &out.LastSuccessfulReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.KafkaTopics != nil {
+		in, out := &in.KafkaTopics, &out.KafkaTopics
+		*out = make([]KafkaTopicStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClowdAppStatus.
@@ -346,9 +398,74 @@ func (in *ClowdEnvironmentList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClowdEnvironmentSpec) DeepCopyInto(out *ClowdEnvironmentSpec) {
 	*out = *in
+	in.TargetNamespaceMetadata.DeepCopyInto(&out.TargetNamespaceMetadata)
+	in.LimitRange.DeepCopyInto(&out.LimitRange)
 	in.Providers.DeepCopyInto(&out.Providers)
 	in.ResourceDefaults.DeepCopyInto(&out.ResourceDefaults)
+	if in.ResourcePresets != nil {
+		in, out := &in.ResourcePresets, &out.ResourcePresets
+		*out = make(map[string]v1.ResourceRequirements, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.ResourceDefaultRules != nil {
+		in, out := &in.ResourceDefaultRules, &out.ResourceDefaultRules
+		*out = make([]ResourceDefaultRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DefaultReplicas != nil {
+		in, out := &in.DefaultReplicas, &out.DefaultReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxReplicas != nil {
+		in, out := &in.MaxReplicas, &out.MaxReplicas
+		*out = new(int32)
+		**out = **in
+	}
 	out.ServiceConfig = in.ServiceConfig
+	if in.SharedConfigMaps != nil {
+		in, out := &in.SharedConfigMaps, &out.SharedConfigMaps
+		*out = make([]SharedConfigMap, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.IgnoredKinds != nil {
+		in, out := &in.IgnoredKinds, &out.IgnoredKinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ImageRegistryOverrides != nil {
+		in, out := &in.ImageRegistryOverrides, &out.ImageRegistryOverrides
+		*out = make([]ImageRegistryOverride, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClowdEnvironmentSpec.
@@ -506,6 +623,21 @@ func (in *ClowdJobInvocationStatus) DeepCopy() *ClowdJobInvocationStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigValidationConfig) DeepCopyInto(out *ConfigValidationConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigValidationConfig.
+func (in *ConfigValidationConfig) DeepCopy() *ConfigValidationConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigValidationConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CyndiSpec) DeepCopyInto(out *CyndiSpec) {
 	*out = *in
@@ -524,6 +656,8 @@ func (in *CyndiSpec) DeepCopy() *CyndiSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DatabaseConfig) DeepCopyInto(out *DatabaseConfig) {
 	*out = *in
+	out.SecretRef = in.SecretRef
+	out.PgBouncer = in.PgBouncer
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseConfig.
@@ -556,6 +690,21 @@ func (in *DatabaseSpec) DeepCopy() *DatabaseSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DependencyReadinessGate) DeepCopyInto(out *DependencyReadinessGate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DependencyReadinessGate.
+func (in *DependencyReadinessGate) DeepCopy() *DependencyReadinessGate {
+	if in == nil {
+		return nil
+	}
+	out := new(DependencyReadinessGate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Deployment) DeepCopyInto(out *Deployment) {
 	*out = *in
@@ -584,9 +733,67 @@ func (in *Deployment) DeepCopyInto(out *Deployment) {
 	if in.DeploymentStrategy != nil {
 		in, out := &in.DeploymentStrategy, &out.DeploymentStrategy
 		*out = new(DeploymentStrategy)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	in.Metadata.DeepCopyInto(&out.Metadata)
+	if in.PodAnnotations != nil {
+		in, out := &in.PodAnnotations, &out.PodAnnotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.DrainOnRollout != nil {
+		in, out := &in.DrainOnRollout, &out.DrainOnRollout
+		*out = new(DrainOnRollout)
+		**out = **in
+	}
+	if in.SessionAffinity != nil {
+		in, out := &in.SessionAffinity, &out.SessionAffinity
+		*out = new(SessionAffinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReadinessGates != nil {
+		in, out := &in.ReadinessGates, &out.ReadinessGates
+		*out = make([]DependencyReadinessGate, len(*in))
+		copy(*out, *in)
+	}
+	if in.AntiAffinity != nil {
+		in, out := &in.AntiAffinity, &out.AntiAffinity
+		*out = new(AntiAffinity)
+		**out = **in
+	}
+	if in.ExtraPorts != nil {
+		in, out := &in.ExtraPorts, &out.ExtraPorts
+		*out = make([]DeploymentPort, len(*in))
+		copy(*out, *in)
+	}
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(int32)
+		**out = **in
+	}
+	if in.VolumeClaimTemplates != nil {
+		in, out := &in.VolumeClaimTemplates, &out.VolumeClaimTemplates
+		*out = make([]VolumeClaimTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Deployment.
@@ -602,6 +809,21 @@ func (in *Deployment) DeepCopy() *Deployment {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeploymentConfig) DeepCopyInto(out *DeploymentConfig) {
 	*out = *in
+	out.ConfigValidation = in.ConfigValidation
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentConfig.
@@ -651,9 +873,34 @@ func (in *DeploymentMetadata) DeepCopy() *DeploymentMetadata {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DeploymentPort) DeepCopyInto(out *DeploymentPort) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentPort.
+func (in *DeploymentPort) DeepCopy() *DeploymentPort {
+	if in == nil {
+		return nil
+	}
+	out := new(DeploymentPort)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeploymentStrategy) DeepCopyInto(out *DeploymentStrategy) {
 	*out = *in
+	if in.MaxSurge != nil {
+		in, out := &in.MaxSurge, &out.MaxSurge
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+	if in.MaxUnavailable != nil {
+		in, out := &in.MaxUnavailable, &out.MaxUnavailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeploymentStrategy.
@@ -666,6 +913,21 @@ func (in *DeploymentStrategy) DeepCopy() *DeploymentStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DrainOnRollout) DeepCopyInto(out *DrainOnRollout) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DrainOnRollout.
+func (in *DrainOnRollout) DeepCopy() *DrainOnRollout {
+	if in == nil {
+		return nil
+	}
+	out := new(DrainOnRollout)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *EnvResourceStatus) DeepCopyInto(out *EnvResourceStatus) {
 	*out = *in
@@ -681,6 +943,21 @@ func (in *EnvResourceStatus) DeepCopy() *EnvResourceStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FeatureFlagToggle) DeepCopyInto(out *FeatureFlagToggle) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FeatureFlagToggle.
+func (in *FeatureFlagToggle) DeepCopy() *FeatureFlagToggle {
+	if in == nil {
+		return nil
+	}
+	out := new(FeatureFlagToggle)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FeatureFlagsConfig) DeepCopyInto(out *FeatureFlagsConfig) {
 	*out = *in
@@ -697,6 +974,21 @@ func (in *FeatureFlagsConfig) DeepCopy() *FeatureFlagsConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageRegistryOverride) DeepCopyInto(out *ImageRegistryOverride) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageRegistryOverride.
+func (in *ImageRegistryOverride) DeepCopy() *ImageRegistryOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageRegistryOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InMemoryDBConfig) DeepCopyInto(out *InMemoryDBConfig) {
 	*out = *in
@@ -732,6 +1024,11 @@ func (in *InitContainer) DeepCopyInto(out *InitContainer) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.InheritVolumeMounts != nil {
+		in, out := &in.InheritVolumeMounts, &out.InheritVolumeMounts
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InitContainer.
@@ -935,6 +1232,21 @@ func (in *JobTestingSpec) DeepCopy() *JobTestingSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaClusterAutoscaling) DeepCopyInto(out *KafkaClusterAutoscaling) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaClusterAutoscaling.
+func (in *KafkaClusterAutoscaling) DeepCopy() *KafkaClusterAutoscaling {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaClusterAutoscaling)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *KafkaClusterConfig) DeepCopyInto(out *KafkaClusterConfig) {
 	*out = *in
@@ -951,6 +1263,11 @@ func (in *KafkaClusterConfig) DeepCopyInto(out *KafkaClusterConfig) {
 	}
 	in.JVMOptions.DeepCopyInto(&out.JVMOptions)
 	in.Resources.DeepCopyInto(&out.Resources)
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(KafkaClusterAutoscaling)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaClusterConfig.
@@ -969,6 +1286,7 @@ func (in *KafkaConfig) DeepCopyInto(out *KafkaConfig) {
 	in.Cluster.DeepCopyInto(&out.Cluster)
 	in.Connect.DeepCopyInto(&out.Connect)
 	out.ManagedSecretRef = in.ManagedSecretRef
+	out.MSKSecretRef = in.MSKSecretRef
 	out.EphemManagedSecretRef = in.EphemManagedSecretRef
 }
 
@@ -1020,9 +1338,65 @@ func (in *KafkaTopicSpec) DeepCopy() *KafkaTopicSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KafkaTopicStatus) DeepCopyInto(out *KafkaTopicStatus) {
+	*out = *in
+	if in.PendingSince != nil {
+		in, out := &in.PendingSince, &out.PendingSince
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KafkaTopicStatus.
+func (in *KafkaTopicStatus) DeepCopy() *KafkaTopicStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(KafkaTopicStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LimitRangeConfig) DeepCopyInto(out *LimitRangeConfig) {
+	*out = *in
+	if in.Default != nil {
+		in, out := &in.Default, &out.Default
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.DefaultRequest != nil {
+		in, out := &in.DefaultRequest, &out.DefaultRequest
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+	if in.Max != nil {
+		in, out := &in.Max, &out.Max
+		*out = make(v1.ResourceList, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LimitRangeConfig.
+func (in *LimitRangeConfig) DeepCopy() *LimitRangeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LimitRangeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LoggingConfig) DeepCopyInto(out *LoggingConfig) {
 	*out = *in
+	out.SecretRef = in.SecretRef
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoggingConfig.
@@ -1039,6 +1413,8 @@ func (in *LoggingConfig) DeepCopy() *LoggingConfig {
 func (in *MetricsConfig) DeepCopyInto(out *MetricsConfig) {
 	*out = *in
 	out.Prometheus = in.Prometheus
+	out.ScrapeToken = in.ScrapeToken
+	out.Dashboards = in.Dashboards
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsConfig.
@@ -1051,6 +1427,36 @@ func (in *MetricsConfig) DeepCopy() *MetricsConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsDashboardsConfig) DeepCopyInto(out *MetricsDashboardsConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsDashboardsConfig.
+func (in *MetricsDashboardsConfig) DeepCopy() *MetricsDashboardsConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsDashboardsConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricsScrapeTokenConfig) DeepCopyInto(out *MetricsScrapeTokenConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricsScrapeTokenConfig.
+func (in *MetricsScrapeTokenConfig) DeepCopy() *MetricsScrapeTokenConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MetricsScrapeTokenConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetricsWebService) DeepCopyInto(out *MetricsWebService) {
 	*out = *in
@@ -1112,6 +1518,36 @@ func (in *ObjectStoreConfig) DeepCopy() *ObjectStoreConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OtelCollectorConfig) DeepCopyInto(out *OtelCollectorConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OtelCollectorConfig.
+func (in *OtelCollectorConfig) DeepCopy() *OtelCollectorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OtelCollectorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PgBouncerConfig) DeepCopyInto(out *PgBouncerConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PgBouncerConfig.
+func (in *PgBouncerConfig) DeepCopy() *PgBouncerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PgBouncerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PodSpec) DeepCopyInto(out *PodSpec) {
 	*out = *in
@@ -1170,6 +1606,32 @@ func (in *PodSpec) DeepCopyInto(out *PodSpec) {
 		*out = make([]Sidecar, len(*in))
 		copy(*out, *in)
 	}
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]v1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.AutomountServiceAccountToken != nil {
+		in, out := &in.AutomountServiceAccountToken, &out.AutomountServiceAccountToken
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ProvenanceLabels != nil {
+		in, out := &in.ProvenanceLabels, &out.ProvenanceLabels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PodSpec.
@@ -1258,7 +1720,7 @@ func (in *ProvidersConfig) DeepCopyInto(out *ProvidersConfig) {
 	out.Logging = in.Logging
 	out.Metrics = in.Metrics
 	out.ObjectStore = in.ObjectStore
-	out.Web = in.Web
+	in.Web.DeepCopyInto(&out.Web)
 	out.FeatureFlags = in.FeatureFlags
 	out.ServiceMesh = in.ServiceMesh
 	if in.PullSecrets != nil {
@@ -1269,7 +1731,7 @@ func (in *ProvidersConfig) DeepCopyInto(out *ProvidersConfig) {
 	in.Testing.DeepCopyInto(&out.Testing)
 	out.Sidecars = in.Sidecars
 	out.AutoScaler = in.AutoScaler
-	out.Deployment = in.Deployment
+	in.Deployment.DeepCopyInto(&out.Deployment)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProvidersConfig.
@@ -1302,6 +1764,22 @@ func (in *PublicWebService) DeepCopy() *PublicWebService {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResourceDefaultRule) DeepCopyInto(out *ResourceDefaultRule) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResourceDefaultRule.
+func (in *ResourceDefaultRule) DeepCopy() *ResourceDefaultRule {
+	if in == nil {
+		return nil
+	}
+	out := new(ResourceDefaultRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceConfig) DeepCopyInto(out *ServiceConfig) {
 	*out = *in
@@ -1332,6 +1810,41 @@ func (in *ServiceMeshConfig) DeepCopy() *ServiceMeshConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SessionAffinity) DeepCopyInto(out *SessionAffinity) {
+	*out = *in
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionAffinity.
+func (in *SessionAffinity) DeepCopy() *SessionAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SharedConfigMap) DeepCopyInto(out *SharedConfigMap) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SharedConfigMap.
+func (in *SharedConfigMap) DeepCopy() *SharedConfigMap {
+	if in == nil {
+		return nil
+	}
+	out := new(SharedConfigMap)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Sidecar) DeepCopyInto(out *Sidecar) {
 	*out = *in
@@ -1351,6 +1864,7 @@ func (in *Sidecar) DeepCopy() *Sidecar {
 func (in *Sidecars) DeepCopyInto(out *Sidecars) {
 	*out = *in
 	out.TokenRefresher = in.TokenRefresher
+	out.OtelCollector = in.OtelCollector
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Sidecars.
@@ -1396,6 +1910,11 @@ func (in *SimpleAutoScalerReplicas) DeepCopy() *SimpleAutoScalerReplicas {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TLS) DeepCopyInto(out *TLS) {
 	*out = *in
+	if in.IssuerRef != nil {
+		in, out := &in.IssuerRef, &out.IssuerRef
+		*out = new(TLSIssuerRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLS.
@@ -1408,6 +1927,50 @@ func (in *TLS) DeepCopy() *TLS {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TLSIssuerRef) DeepCopyInto(out *TLSIssuerRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TLSIssuerRef.
+func (in *TLSIssuerRef) DeepCopy() *TLSIssuerRef {
+	if in == nil {
+		return nil
+	}
+	out := new(TLSIssuerRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetNamespaceMetadata) DeepCopyInto(out *TargetNamespaceMetadata) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetNamespaceMetadata.
+func (in *TargetNamespaceMetadata) DeepCopy() *TargetNamespaceMetadata {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetNamespaceMetadata)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TestingConfig) DeepCopyInto(out *TestingConfig) {
 	*out = *in
@@ -1454,11 +2017,37 @@ func (in *TokenRefresherConfig) DeepCopy() *TokenRefresherConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeClaimTemplate) DeepCopyInto(out *VolumeClaimTemplate) {
+	*out = *in
+	out.Size = in.Size.DeepCopy()
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]v1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeClaimTemplate.
+func (in *VolumeClaimTemplate) DeepCopy() *VolumeClaimTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeClaimTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *WebConfig) DeepCopyInto(out *WebConfig) {
 	*out = *in
 	out.Images = in.Images
-	out.TLS = in.TLS
+	in.TLS.DeepCopyInto(&out.TLS)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebConfig.
@@ -1,14 +1,59 @@
 package controllers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
+// maxPlanRequestBytes bounds the size of the body of a plan request.
+const maxPlanRequestBytes = 1 << 20
+
+// maxReconcileRequestBytes bounds the size of the body of a reconcile request.
+const maxReconcileRequestBytes = 1 << 10
+
+// ReconcileRequest names the ClowdApp to reconcile.
+type ReconcileRequest struct {
+	App       string `json:"app"`
+	Namespace string `json:"namespace"`
+}
+
+var apiMu sync.RWMutex
+var apiClient client.Client
+
+// setAPIClient sets the client used by the API handlers to read from the cluster, it is expected
+// to be the cached client of the manager.
+func setAPIClient(pClient client.Client) {
+	apiMu.Lock()
+	defer apiMu.Unlock()
+	apiClient = pClient
+}
+
+func getAPIClient() client.Client {
+	apiMu.RLock()
+	defer apiMu.RUnlock()
+	return apiClient
+}
+
+// writeJSONError writes an error response with a JSON body describing the error.
+func writeJSONError(w http.ResponseWriter, code int, msg string) {
+	w.WriteHeader(code)
+	jsonString, _ := json.Marshal(map[string]string{"error": msg})
+	fmt.Fprintf(w, "%s", jsonString)
+}
+
 func CreateAPIServer() *http.Server {
 	mux := http.NewServeMux()
 
@@ -36,6 +81,25 @@ func CreateAPIServer() *http.Server {
 		fmt.Fprintf(w, "%s", jsonString)
 	})
 
+	mux.HandleFunc("/clowdapps/health/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(
+			"Content-Type", "application/json",
+		)
+		ident := strings.TrimPrefix(r.URL.Path, "/clowdapps/health/")
+		if ident == "" {
+			jsonString, _ := json.Marshal(GetAppsHealth())
+			fmt.Fprintf(w, "%s", jsonString)
+			return
+		}
+		health, ok := GetAppHealth(ident)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		jsonString, _ := json.Marshal(health)
+		fmt.Fprintf(w, "%s", jsonString)
+	})
+
 	mux.HandleFunc("/clowdenvs/present/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add(
 			"Content-Type", "application/json",
@@ -52,6 +116,108 @@ func CreateAPIServer() *http.Server {
 		fmt.Fprintf(w, "%s", jsonString)
 	})
 
+	mux.HandleFunc("/stats/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(
+			"Content-Type", "application/json",
+		)
+		pClient := getAPIClient()
+		if pClient == nil {
+			writeJSONError(w, http.StatusServiceUnavailable, "manager not started")
+			return
+		}
+
+		envs := []crd.ClowdEnvironment{}
+		if name := r.URL.Query().Get("env"); name != "" {
+			env := crd.ClowdEnvironment{}
+			if err := pClient.Get(r.Context(), types.NamespacedName{Name: name}, &env); err != nil {
+				if k8serr.IsNotFound(err) {
+					writeJSONError(w, http.StatusNotFound, fmt.Sprintf("environment [%s] not found", name))
+				} else {
+					writeJSONError(w, http.StatusInternalServerError, err.Error())
+				}
+				return
+			}
+			envs = append(envs, env)
+		} else {
+			envList := crd.ClowdEnvironmentList{}
+			if err := pClient.List(r.Context(), &envList); err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			envs = envList.Items
+		}
+
+		stats := map[string]EnvStats{}
+		for i := range envs {
+			envStats, err := getEnvStats(r.Context(), pClient, &envs[i])
+			if err != nil {
+				writeJSONError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			stats[envs[i].Name] = envStats
+		}
+
+		jsonString, _ := json.Marshal(stats)
+		fmt.Fprintf(w, "%s", jsonString)
+	})
+
+	mux.HandleFunc("/plan/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(
+			"Content-Type", "application/json",
+		)
+		if r.Method != http.MethodPost {
+			writeJSONError(w, http.StatusMethodNotAllowed, "plan requests must be POSTed")
+			return
+		}
+		pClient := getAPIClient()
+		if pClient == nil {
+			writeJSONError(w, http.StatusServiceUnavailable, "manager not started")
+			return
+		}
+
+		req := PlanRequest{}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPlanRequestBytes)).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid plan request: %s", err))
+			return
+		}
+
+		app := &req.App
+		if app.Name == "" || app.Namespace == "" {
+			writeJSONError(w, http.StatusBadRequest, "app must have a name and a namespace")
+			return
+		}
+
+		env := req.Env
+		if env == nil {
+			env = &crd.ClowdEnvironment{}
+			if err := pClient.Get(r.Context(), types.NamespacedName{Name: app.Spec.EnvName}, env); err != nil {
+				if k8serr.IsNotFound(err) {
+					writeJSONError(w, http.StatusNotFound, fmt.Sprintf("environment [%s] not found", app.Spec.EnvName))
+				} else {
+					writeJSONError(w, http.StatusInternalServerError, err.Error())
+				}
+				return
+			}
+		} else if app.Spec.EnvName == "" {
+			app.Spec.EnvName = env.Name
+		}
+
+		objects, err := planApp(r.Context(), pClient, ctrl.Log.WithName("plan"), app, env)
+		if err != nil {
+			writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+
+		jsonString, _ := json.Marshal(objects)
+		fmt.Fprintf(w, "%s", jsonString)
+	})
+
+	mux.HandleFunc("/reconcile/", reconcileHandler)
+
+	mux.HandleFunc("/clowdapps/", appConfigHandler)
+
+	mux.HandleFunc("/environments/", envManifestHandler)
+
 	srv := http.Server{
 		Addr:              "127.0.0.1:2019",
 		Handler:           mux,
@@ -59,3 +225,155 @@ func CreateAPIServer() *http.Server {
 	}
 	return &srv
 }
+
+// reconcileHandler queues a ClowdApp for an immediate reconcile, e.g. after a change to a resource
+// that Clowder doesn't watch.
+func reconcileHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add(
+		"Content-Type", "application/json",
+	)
+	if r.Method != http.MethodPost {
+		writeJSONError(w, http.StatusMethodNotAllowed, "reconcile requests must be POSTed")
+		return
+	}
+	pClient := getAPIClient()
+	if pClient == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "manager not started")
+		return
+	}
+
+	req := ReconcileRequest{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxReconcileRequestBytes)).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("invalid reconcile request: %s", err))
+		return
+	}
+	if req.App == "" || req.Namespace == "" {
+		writeJSONError(w, http.StatusBadRequest, "app and namespace must be set")
+		return
+	}
+
+	app := &crd.ClowdApp{}
+	if err := pClient.Get(r.Context(), types.NamespacedName{Name: req.App, Namespace: req.Namespace}, app); err != nil {
+		if k8serr.IsNotFound(err) {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("app [%s] not found in namespace [%s]", req.App, req.Namespace))
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	if !requestAppResync(app) {
+		writeJSONError(w, http.StatusTooManyRequests, "too many reconciles are already queued")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	jsonString, _ := json.Marshal(req)
+	fmt.Fprintf(w, "%s", jsonString)
+}
+
+// parseAppConfigPath returns the namespace and name of the app from a path of the form
+// /clowdapps/{namespace}/{name}/config/.
+func parseAppConfigPath(path string) (types.NamespacedName, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/clowdapps/"), "/"), "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] != "config" {
+		return types.NamespacedName{}, false
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, true
+}
+
+// checkRevealToken reports whether the request carries the bearer token of the
+// configRevealTokenFile setting, it is false when the setting is unset.
+func checkRevealToken(r *http.Request) (bool, error) {
+	tokenFile := clowderconfig.LoadedConfig.Settings.ConfigRevealTokenFile
+	if tokenFile == "" {
+		return false, nil
+	}
+
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return false, err
+	}
+
+	expected := strings.TrimSpace(string(token))
+	header := r.Header.Get("Authorization")
+	if expected == "" || !strings.HasPrefix(header, "Bearer ") {
+		return false, nil
+	}
+	given := strings.TrimPrefix(header, "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(given)) == 1, nil
+}
+
+// appConfigHandler returns the cdappconfig.json that a ClowdApp is given, rendered by a dry run of
+// the providers. The credentials in the config are redacted unless reveal=true is passed along
+// with the reveal token.
+func appConfigHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add(
+		"Content-Type", "application/json",
+	)
+	nn, ok := parseAppConfigPath(r.URL.Path)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown path [%s]", r.URL.Path))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "config requests must be a GET")
+		return
+	}
+
+	reveal := r.URL.Query().Get("reveal") == "true"
+	if reveal {
+		allowed, err := checkRevealToken(r)
+		if err != nil {
+			writeJSONError(w, http.StatusInternalServerError, fmt.Sprintf("could not read reveal token: %s", err))
+			return
+		}
+		if !allowed {
+			writeJSONError(w, http.StatusForbidden, "revealing the config requires a valid reveal token")
+			return
+		}
+	}
+
+	pClient := getAPIClient()
+	if pClient == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "manager not started")
+		return
+	}
+
+	app := &crd.ClowdApp{}
+	if err := pClient.Get(r.Context(), nn, app); err != nil {
+		if k8serr.IsNotFound(err) {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("app [%s] not found in namespace [%s]", nn.Name, nn.Namespace))
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	env := &crd.ClowdEnvironment{}
+	if err := pClient.Get(r.Context(), types.NamespacedName{Name: app.Spec.EnvName}, env); err != nil {
+		if k8serr.IsNotFound(err) {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("environment [%s] not found", app.Spec.EnvName))
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	appConfig, err := renderAppConfig(r.Context(), pClient, ctrl.Log.WithName("config"), app, env)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	var doc interface{} = appConfig
+	if !reveal {
+		if doc, err = redactAppConfig(appConfig); err != nil {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	jsonString, _ := json.Marshal(doc)
+	fmt.Fprintf(w, "%s", jsonString)
+}
@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func postReconcile(body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	reconcileHandler(w, httptest.NewRequest(http.MethodPost, "/reconcile/", strings.NewReader(body)))
+	return w
+}
+
+func TestReconcileEndpoint(t *testing.T) {
+	app := &crd.ClowdApp{ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test"}}
+
+	oldClient := getAPIClient()
+	defer setAPIClient(oldClient)
+	setAPIClient(fake.NewClientBuilder().WithScheme(Scheme).WithObjects(app).Build())
+
+	defer func() {
+		for len(appResyncs) > 0 {
+			<-appResyncs
+		}
+		clearAppResync(types.NamespacedName{Name: "puptoo", Namespace: "test"})
+	}()
+
+	// the app is backing off from a permanent failure, which a resync bypasses
+	env := &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env"}}
+	appProviderBackoffs.record(app, env, &errors.ProviderError{Provider: "deployment", Permanent: true, Err: fmt.Errorf("failed")})
+	defer appProviderBackoffs.clear(types.NamespacedName{Name: "puptoo", Namespace: "test"})
+	_, _, backingOff := appProviderBackoffs.remaining(app, env)
+	assert.True(t, backingOff)
+
+	w := postReconcile(`{"app": "puptoo", "namespace": "test"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 1, len(appResyncs))
+
+	_, _, backingOff = appProviderBackoffs.remaining(app, env)
+	assert.False(t, backingOff)
+
+	// a repeated request is accepted but only queued once
+	w = postReconcile(`{"app": "puptoo", "namespace": "test"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 1, len(appResyncs))
+
+	// the app can be queued again once its reconcile started
+	<-appResyncs
+	clearAppResync(types.NamespacedName{Name: "puptoo", Namespace: "test"})
+	w = postReconcile(`{"app": "puptoo", "namespace": "test"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, 1, len(appResyncs))
+
+	w = postReconcile(`{"app": "missing", "namespace": "test"}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "app [missing] not found in namespace [test]"}`, w.Body.String())
+
+	for _, body := range []string{``, `{"app":`, `{"app": "puptoo"}`} {
+		w = postReconcile(body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), `"error"`, body)
+	}
+
+	w = httptest.NewRecorder()
+	reconcileHandler(w, httptest.NewRequest(http.MethodGet, "/reconcile/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
+
+func TestParseAppConfigPath(t *testing.T) {
+	nn, ok := parseAppConfigPath("/clowdapps/test/puptoo/config/")
+	assert.True(t, ok)
+	assert.Equal(t, types.NamespacedName{Name: "puptoo", Namespace: "test"}, nn)
+
+	nn, ok = parseAppConfigPath("/clowdapps/test/puptoo/config")
+	assert.True(t, ok)
+	assert.Equal(t, types.NamespacedName{Name: "puptoo", Namespace: "test"}, nn)
+
+	for _, path := range []string{"/clowdapps/", "/clowdapps/test/", "/clowdapps/test/puptoo/", "/clowdapps//puptoo/config/", "/clowdapps/test/puptoo/secrets/"} {
+		_, ok = parseAppConfigPath(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestAppConfigEndpoint(t *testing.T) {
+	oldClient := getAPIClient()
+	defer setAPIClient(oldClient)
+	setAPIClient(fake.NewClientBuilder().WithScheme(Scheme).Build())
+
+	w := httptest.NewRecorder()
+	appConfigHandler(w, httptest.NewRequest(http.MethodGet, "/clowdapps/test/missing/config/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "app [missing] not found in namespace [test]"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	appConfigHandler(w, httptest.NewRequest(http.MethodPost, "/clowdapps/test/puptoo/config/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	// revealing is refused without a configured token
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/clowdapps/test/puptoo/config/?reveal=true", nil)
+	req.Header.Set("Authorization", "Bearer abc123")
+	appConfigHandler(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCheckRevealToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("abc123\n"), 0600))
+
+	oldTokenFile := clowderconfig.LoadedConfig.Settings.ConfigRevealTokenFile
+	defer func() { clowderconfig.LoadedConfig.Settings.ConfigRevealTokenFile = oldTokenFile }()
+	clowderconfig.LoadedConfig.Settings.ConfigRevealTokenFile = tokenFile
+
+	for header, expected := range map[string]bool{
+		"Bearer abc123": true,
+		"Bearer abc":    false,
+		"abc123":        false,
+		"":              false,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/clowdapps/test/puptoo/config/?reveal=true", nil)
+		if header != "" {
+			req.Header.Set("Authorization", header)
+		}
+		allowed, err := checkRevealToken(req)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, allowed, header)
+	}
+}
+
+func TestParseEnvManifestPath(t *testing.T) {
+	name, ok := parseEnvManifestPath("/environments/env-test/manifest/")
+	assert.True(t, ok)
+	assert.Equal(t, "env-test", name)
+
+	for _, path := range []string{
+		"/environments/",
+		"/environments/env-test/",
+		"/environments//manifest/",
+		"/environments/env-test/stats/",
+		"/environments/env-test/manifest/extra/",
+	} {
+		_, ok := parseEnvManifestPath(path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"gzip":               true,
+		"deflate, gzip":      true,
+		"gzip;q=0.5, br":     true,
+		"gzip;q=0":           false,
+		"gzip; q=0.0, br":    false,
+		"br, deflate":        false,
+		"":                   false,
+		"x-gzip, identity":   false,
+		"identity;q=1, gzip": true,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/environments/env-test/manifest/", nil)
+		if header != "" {
+			req.Header.Set("Accept-Encoding", header)
+		}
+		assert.Equal(t, expected, acceptsGzip(req), header)
+	}
+}
+
+func getManifest(accept, encoding string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/environments/env-test/manifest/", nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if encoding != "" {
+		req.Header.Set("Accept-Encoding", encoding)
+	}
+	envManifestHandler(w, req)
+	return w
+}
+
+func TestEnvManifestEndpoint(t *testing.T) {
+	env := &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env-test", UID: "env-uid"}}
+	env.Status.TargetNamespace = "env-ns"
+	app := &crd.ClowdApp{ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test", UID: "app-uid"}}
+	app.Spec.EnvName = "env-test"
+	otherApp := &crd.ClowdApp{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "test", UID: "other-uid"}}
+	otherApp.Spec.EnvName = "env-other"
+
+	ownedBy := func(name, namespace, uid string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{{Name: "owner", UID: types.UID(uid)}},
+			ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "clowder"}},
+		}
+	}
+
+	dbDeployment := &apps.Deployment{ObjectMeta: ownedBy("puptoo-db", "test", "app-uid")}
+	dbDeployment.Spec.Template.Spec.Containers = []core.Container{{
+		Name: "puptoo-db",
+		Env: []core.EnvVar{
+			{Name: "POSTGRESQL_USER", Value: "user"},
+			{Name: "POSTGRESQL_PASSWORD", Value: "hunter3"},
+			{Name: "PGPASSWORD", Value: "hunter4"},
+			{Name: "POSTGRESQL_MASTER_PASSWORD", Value: "hunter4"},
+		},
+	}}
+
+	objects := []client.Object{
+		env, app, otherApp, dbDeployment,
+		&apps.Deployment{ObjectMeta: ownedBy("puptoo-processor", "test", "app-uid")},
+		&apps.Deployment{ObjectMeta: ownedBy("other-processor", "test", "other-uid")},
+		&core.Service{ObjectMeta: ownedBy("env-test-minio", "env-ns", "env-uid")},
+		&core.Secret{ObjectMeta: ownedBy("puptoo", "test", "app-uid"), Data: map[string][]byte{"cdappconfig.json": []byte(`{"password": "hunter2"}`)}},
+		&core.Secret{ObjectMeta: ownedBy("unowned", "test", "")},
+	}
+
+	oldClient := getAPIClient()
+	defer setAPIClient(oldClient)
+	setAPIClient(fake.NewClientBuilder().WithScheme(Scheme).WithObjects(objects...).Build())
+
+	w := getManifest("application/json", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	manifest := []map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &manifest))
+
+	names := []string{}
+	for _, obj := range manifest {
+		metadata := obj["metadata"].(map[string]interface{})
+		names = append(names, fmt.Sprintf("%s/%s", obj["kind"], metadata["name"]))
+		assert.NotContains(t, metadata, "managedFields")
+	}
+	assert.Equal(t, []string{
+		"ClowdEnvironment/env-test",
+		"ClowdApp/puptoo",
+		"Deployment/puptoo-db",
+		"Deployment/puptoo-processor",
+		"Service/env-test-minio",
+		"Secret/puptoo",
+	}, names)
+
+	secret := manifest[len(manifest)-1]
+	assert.Equal(t, "v1", secret["apiVersion"])
+	assert.Equal(t, map[string]interface{}{"cdappconfig.json": redactedValue}, secret["data"])
+
+	// the passwords of the database deployment are redacted, the other env vars are kept
+	deployment := &apps.Deployment{}
+	assert.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(manifest[2], deployment))
+	assert.Equal(t, []core.EnvVar{
+		{Name: "POSTGRESQL_USER", Value: "user"},
+		{Name: "POSTGRESQL_PASSWORD", Value: redactedValue},
+		{Name: "PGPASSWORD", Value: redactedValue},
+		{Name: "POSTGRESQL_MASTER_PASSWORD", Value: redactedValue},
+	}, deployment.Spec.Template.Spec.Containers[0].Env)
+
+	// the default is a YAML stream, which is gzipped when the client accepts it
+	w = getManifest("", "gzip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/yaml", w.Header().Get("Content-Type"))
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	gz, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, strings.Count(string(body), "---\n"))
+	assert.Contains(t, string(body), "kind: ClowdEnvironment\n")
+	assert.NotContains(t, string(body), "hunter2")
+	assert.NotContains(t, string(body), "hunter3")
+	assert.NotContains(t, string(body), "hunter4")
+
+	w = getManifest("", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.True(t, strings.HasPrefix(w.Body.String(), "---\n"))
+
+	w = httptest.NewRecorder()
+	envManifestHandler(w, httptest.NewRequest(http.MethodGet, "/environments/missing/manifest/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error": "environment [missing] not found"}`, w.Body.String())
+
+	w = httptest.NewRecorder()
+	envManifestHandler(w, httptest.NewRequest(http.MethodGet, "/environments/env-test/", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	envManifestHandler(w, httptest.NewRequest(http.MethodPost, "/environments/env-test/manifest/", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
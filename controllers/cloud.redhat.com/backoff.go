@@ -0,0 +1,117 @@
+package controllers
+
+import (
+	errlib "errors"
+	"sync"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// providerBackoffBase is the delay before the first retry of an app whose provider failed, it
+// doubles with every further failure of the same provider up to providerBackoffMax.
+const providerBackoffBase = 5 * time.Second
+
+const providerBackoffMax = 5 * time.Minute
+
+// permanentProviderRetryDelay is the delay before an app whose provider failed permanently is
+// retried, a change to the app or its environment is retried straight away.
+const permanentProviderRetryDelay = 30 * time.Minute
+
+// providerFailure records the provider that failed the last reconcile of an app.
+type providerFailure struct {
+	provider      string
+	failures      int
+	retryAt       time.Time
+	generation    int64
+	envGeneration int64
+	// waiting is set when the provider is missing a dependency or a secret. Their creation doesn't
+	// change the app or the environment, so the reconciles triggered by it are never skipped.
+	waiting bool
+}
+
+// providerBackoffs tracks the failing provider of each app. The providers of an app share the
+// cache that is applied once they have all run, so a retry runs all of them again. This is safe
+// as the providers pick up the objects that already exist, e.g. the credentials of a database are
+// kept, but it is wasted work while the failing provider is backing off. A reconcile of an app
+// within its backoff is skipped unless the app or its environment have changed since the failure,
+// or the provider is waiting on a missing dependency or secret.
+type providerBackoffs struct {
+	mu       sync.Mutex
+	failures map[types.NamespacedName]*providerFailure
+	now      func() time.Time
+}
+
+func newProviderBackoffs() *providerBackoffs {
+	return &providerBackoffs{
+		failures: map[types.NamespacedName]*providerFailure{},
+		now:      time.Now,
+	}
+}
+
+var appProviderBackoffs = newProviderBackoffs()
+
+// record notes the failure of a provider for the app and returns the delay before the app should
+// be retried. Repeated failures of the same provider back off exponentially, the failure of a
+// different provider starts again from providerBackoffBase.
+func (b *providerBackoffs) record(app *crd.ClowdApp, env *crd.ClowdEnvironment, provErr *errors.ProviderError) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+
+	failure, ok := b.failures[nn]
+	if !ok || failure.provider != provErr.Provider {
+		failure = &providerFailure{provider: provErr.Provider}
+		b.failures[nn] = failure
+	}
+	failure.failures++
+	failure.generation = app.Generation
+	failure.envGeneration = env.Generation
+
+	var missingDeps *errors.MissingDependencies
+	var missingSecrets *errors.MissingSecrets
+	failure.waiting = errlib.As(provErr, &missingDeps) || errlib.As(provErr, &missingSecrets)
+
+	delay := permanentProviderRetryDelay
+	if !provErr.Permanent {
+		delay = providerBackoffBase
+		for i := 1; i < failure.failures && delay < providerBackoffMax; i++ {
+			delay *= 2
+		}
+		if delay > providerBackoffMax {
+			delay = providerBackoffMax
+		}
+	}
+
+	failure.retryAt = b.now().Add(delay)
+	return delay
+}
+
+// remaining returns the failing provider of the app and the time left until it should be retried.
+// It returns false once the backoff has elapsed, if the app or the environment have changed since
+// the failure, or if the provider is waiting on a missing dependency or secret.
+func (b *providerBackoffs) remaining(app *crd.ClowdApp, env *crd.ClowdEnvironment) (string, time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	failure, ok := b.failures[types.NamespacedName{Name: app.Name, Namespace: app.Namespace}]
+	if !ok || failure.waiting || failure.generation != app.Generation || failure.envGeneration != env.Generation {
+		return "", 0, false
+	}
+
+	left := failure.retryAt.Sub(b.now())
+	if left <= 0 {
+		return "", 0, false
+	}
+	return failure.provider, left, true
+}
+
+// clear forgets the failures of the app, it is called once the app reconciles successfully.
+func (b *providerBackoffs) clear(nn types.NamespacedName) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.failures, nn)
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestProviderBackoff(t *testing.T) {
+	now := time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC)
+	backoffs := newProviderBackoffs()
+	backoffs.now = func() time.Time { return now }
+
+	app := &crd.ClowdApp{ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test", Generation: 1}}
+	env := &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env", Generation: 1}}
+	kafkaErr := errors.NewProviderError("kafka", fmt.Errorf("connection refused"))
+	assert.False(t, kafkaErr.Permanent)
+
+	// repeated transient failures of the same provider back off exponentially
+	assert.Equal(t, 5*time.Second, backoffs.record(app, env, kafkaErr))
+	assert.Equal(t, 10*time.Second, backoffs.record(app, env, kafkaErr))
+	assert.Equal(t, 20*time.Second, backoffs.record(app, env, kafkaErr))
+
+	provider, left, ok := backoffs.remaining(app, env)
+	assert.True(t, ok)
+	assert.Equal(t, "kafka", provider)
+	assert.Equal(t, 20*time.Second, left)
+
+	// the app is retried once the backoff has elapsed
+	now = now.Add(20 * time.Second)
+	_, _, ok = backoffs.remaining(app, env)
+	assert.False(t, ok)
+
+	for i := 0; i < 10; i++ {
+		backoffs.record(app, env, kafkaErr)
+	}
+	assert.Equal(t, providerBackoffMax, backoffs.record(app, env, kafkaErr))
+
+	// a change to the app or the environment is retried straight away
+	app.Generation = 2
+	_, _, ok = backoffs.remaining(app, env)
+	assert.False(t, ok)
+	app.Generation = 1
+	env.Generation = 2
+	_, _, ok = backoffs.remaining(app, env)
+	assert.False(t, ok)
+
+	// the failure of another provider starts the backoff again
+	assert.Equal(t, 5*time.Second, backoffs.record(app, env, errors.NewProviderError("database", fmt.Errorf("timeout"))))
+
+	// invalid config is not retried aggressively
+	permanentErr := errors.NewProviderError("deployment", &errors.InvalidProbePort{Deployment: "processor", Probe: "liveness", Port: "9000"})
+	assert.True(t, permanentErr.Permanent)
+	assert.Equal(t, permanentProviderRetryDelay, backoffs.record(app, env, permanentErr))
+
+	// a missing dependency or secret is retried as soon as it is created, but still backs off
+	missingDeps := errors.MakeMissingDependencies(errors.MissingDependency{Source: "service", Details: "inventory"})
+	depsErr := errors.NewProviderError("dependencies", errors.Wrap("runapp", &missingDeps))
+	assert.Equal(t, 5*time.Second, backoffs.record(app, env, depsErr))
+	_, _, ok = backoffs.remaining(app, env)
+	assert.False(t, ok)
+	missingSecrets := errors.MakeMissingSecrets(errors.MissingSecret{Provider: "logging", Name: "cloudwatch", Namespace: "test"})
+	assert.Equal(t, 5*time.Second, backoffs.record(app, env, errors.NewProviderError("logging", &missingSecrets)))
+	_, _, ok = backoffs.remaining(app, env)
+	assert.False(t, ok)
+
+	backoffs.clear(types.NamespacedName{Name: "puptoo", Namespace: "test"})
+	_, _, ok = backoffs.remaining(app, env)
+	assert.False(t, ok)
+}
+
+func TestIsPermanent(t *testing.T) {
+	assert.True(t, errors.IsPermanent(&errors.TopicPartitionDecrease{Topic: "inventory", Live: 3, Declared: 1}))
+	assert.True(t, errors.IsPermanent(errors.Wrap("deployment", &errors.InvalidSingleton{Deployment: "scheduler"})))
+	assert.True(t, errors.IsPermanent(k8serr.NewInvalid(schema.GroupKind{Kind: "Deployment"}, "puptoo", field.ErrorList{})))
+
+	assert.False(t, errors.IsPermanent(fmt.Errorf("connection refused")))
+	assert.False(t, errors.IsPermanent(k8serr.NewConflict(schema.GroupResource{Resource: "deployments"}, "puptoo", fmt.Errorf("conflict"))))
+	assert.False(t, errors.IsPermanent(&errors.MissingDependencies{}))
+}
+
+var testDatabaseSecret = rc.NewSingleResourceIdent("test", "database_secret", &core.Secret{})
+
+// testDatabaseProvider generates the password of the database secret unless it exists already,
+// as the database provider does.
+type testDatabaseProvider struct {
+	providers.Provider
+	passwords *int
+}
+
+func (p *testDatabaseProvider) EnvProvide() error {
+	return nil
+}
+
+func (p *testDatabaseProvider) Provide(app *crd.ClowdApp) error {
+	nn := types.NamespacedName{Name: fmt.Sprintf("%s-db", app.Name), Namespace: app.Namespace}
+	secret := &core.Secret{}
+	if err := p.Cache.Create(testDatabaseSecret, nn, secret); err != nil {
+		return err
+	}
+	if secret.Data == nil {
+		*p.passwords++
+		secret.Data = map[string][]byte{"password": []byte(fmt.Sprintf("password-%d", *p.passwords))}
+	}
+	secret.Name = nn.Name
+	secret.Namespace = nn.Namespace
+	return p.Cache.Update(testDatabaseSecret, secret)
+}
+
+// testKafkaProvider fails while kafkaErr is set.
+type testKafkaProvider struct {
+	providers.Provider
+	kafkaErr *error
+}
+
+func (p *testKafkaProvider) EnvProvide() error {
+	return nil
+}
+
+func (p *testKafkaProvider) Provide(_ *crd.ClowdApp) error {
+	return *p.kafkaErr
+}
+
+func TestProviderRetryKeepsDatabase(t *testing.T) {
+	ctx := context.Background()
+	log := logr.Discard()
+
+	passwords := 0
+	var kafkaErr error
+
+	registry := providers.ProvidersRegistration.Registry
+	defer func() { providers.ProvidersRegistration.Registry = registry }()
+	providers.ProvidersRegistration.Registry = nil
+	providers.ProvidersRegistration.Register(func(p *providers.Provider) (providers.ClowderProvider, error) {
+		return &testDatabaseProvider{Provider: *p, passwords: &passwords}, nil
+	}, 1, "database")
+	providers.ProvidersRegistration.Register(func(p *providers.Provider) (providers.ClowderProvider, error) {
+		return &testKafkaProvider{Provider: *p, kafkaErr: &kafkaErr}, nil
+	}, 2, "kafka")
+
+	app := &crd.ClowdApp{ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test"}}
+	env := &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env"}}
+	cl := fake.NewClientBuilder().WithScheme(Scheme).Build()
+
+	reconcile := func() *errors.ProviderError {
+		cache := rc.NewObjectCache(ctx, cl, &log, rc.NewCacheConfig(Scheme, nil, nil))
+		r := &ClowdAppReconciliation{ctx: ctx, client: cl, log: &log, app: app, env: env, cache: &cache, config: &config.AppConfig{}}
+		provider := &providers.Provider{Client: cl, Ctx: ctx, Env: env, Cache: &cache, Log: log, Config: r.config}
+		if provErr := r.runProvidersImplementation(provider); provErr != nil {
+			return provErr
+		}
+		assert.NoError(t, cache.ApplyAll())
+		return nil
+	}
+
+	assert.Nil(t, reconcile())
+	secret := &core.Secret{}
+	assert.NoError(t, cl.Get(ctx, types.NamespacedName{Name: "puptoo-db", Namespace: "test"}, secret))
+	assert.Equal(t, "password-1", string(secret.Data["password"]))
+
+	// a transient kafka failure is reported against the kafka provider and nothing is applied
+	kafkaErr = fmt.Errorf("connection refused")
+	provErr := reconcile()
+	if assert.NotNil(t, provErr) {
+		assert.Equal(t, "kafka", provErr.Provider)
+		assert.False(t, provErr.Permanent)
+		assert.Equal(t, "provider [kafka] failed: connection refused", provErr.Error())
+	}
+
+	backoffs := newProviderBackoffs()
+	assert.Equal(t, providerBackoffBase, backoffs.record(app, env, provErr))
+	assert.Equal(t, 2*providerBackoffBase, backoffs.record(app, env, reconcile()))
+
+	// once kafka recovers, the database provider runs again but keeps the provisioned password
+	kafkaErr = nil
+	assert.Nil(t, reconcile())
+	assert.NoError(t, cl.Get(ctx, types.NamespacedName{Name: "puptoo-db", Namespace: "test"}, secret))
+	assert.Equal(t, "password-1", string(secret.Data["password"]))
+	assert.Equal(t, 1, passwords)
+}
@@ -16,10 +16,13 @@ package controllers
 
 import (
 	"context"
+	errlib "errors"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
@@ -30,6 +33,7 @@ import (
 
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -41,6 +45,7 @@ import (
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/hashcache"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/web"
 
 	// These imports are to register the providers with the provider registration system
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/autoscaler"
@@ -50,6 +55,7 @@ import (
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/dependencies"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/featureflags"
+	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/imageregistry"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/inmemorydb"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/iqe"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/kafka"
@@ -101,22 +107,26 @@ func (rm *ReconciliationMetrics) stop() {
 
 // +kubebuilder:rbac:groups=cloud.redhat.com,resources=clowdapps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cloud.redhat.com,resources=clowdapps/status,verbs=get;update;patch
-// +kubebuilder:rbac:groups="",resources=serviceaccounts;configmaps;services;persistentvolumeclaims;secrets;events;namespaces,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=serviceaccounts;configmaps;services;persistentvolumeclaims;secrets;events;namespaces;limitranges,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=cronjobs;jobs,verbs=get;list;create;update;watch;patch;delete
-// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kafka.strimzi.io,resources=kafkatopics,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kafka.strimzi.io,resources=kafkas,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kafka.strimzi.io,resources=kafkausers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kafka.strimzi.io,resources=kafkaconnects,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=keda.sh,resources=scaledobjects,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cyndi.cloud.redhat.com,resources=cyndipipelines,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings;roles,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=monitoring.coreos.com,resources=prometheuses;servicemonitors,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=operators.coreos.com,resources=subscriptions,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=kafka.strimzi.io,resources=kafkaconnectors,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=endpoints;pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=pods/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses;networkpolicies,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=config.openshift.io,resources=ingresses,verbs=get;list
+// +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
 
 // ClowdAppReconciler reconciles a ClowdApp object
 type ClowdAppReconciler struct {
@@ -125,17 +135,24 @@ type ClowdAppReconciler struct {
 	Scheme    *runtime.Scheme
 	Recorder  record.EventRecorder
 	HashCache *hashcache.HashCache
+	// ReferencedSecrets, when set, holds the secrets labelled as read by the apps
+	ReferencedSecrets cache.Cache
 }
 
 // Reconcile fn
-func (r *ClowdAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ClowdAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, "ClowdApp.Reconcile", attribute.String("app", req.Name), attribute.String("namespace", req.Namespace))
+	defer func() { endSpan(span, err) }()
+
 	log := r.Log.WithValues("app", req.Name).WithValues("rid", utils.RandString(5)).WithValues("namespace", req.Namespace)
 	ctx = context.WithValue(ctx, errors.ClowdKey("log"), &log)
 	ctx = context.WithValue(ctx, errors.ClowdKey("recorder"), &r.Recorder)
 	app := crd.ClowdApp{}
 
+	clearAppResync(req.NamespacedName)
+
 	defer func() {
-		managedAppsMetric.Set(float64(len(managedApps)))
+		managedAppsMetric.Set(float64(managedApps.len()))
 	}()
 
 	log.Info("Reconciliation started")
@@ -151,16 +168,29 @@ func (r *ClowdAppReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		hashCache: r.HashCache,
 	}
 	res, err := reconciliation.Reconcile()
+
+	if metricErr := setAppEnvReadyMetric(ctx, r.Client, &app); metricErr != nil {
+		log.Info("could not update the environment ready metric", "err", metricErr)
+	}
+
 	if err != nil {
 		if shouldSkipReconciliation(err) {
 			log.Info("skipping", "error", err.Error(), "skipping", "true", "requeue", res.Requeue)
 			return res, nil
 		}
+		countReconcileError("clowdapp", err)
+		var provErr *errors.ProviderError
+		if errlib.As(err, &provErr) {
+			// The app is retried after the backoff of the provider rather than that of the
+			// controller, which only applies to reconciles that return an error
+			log.Error(err, "error in reconciliation", "skipping", "false", "requeueAfter", res.RequeueAfter.String())
+			return res, nil
+		}
 		log.Error(err, "error in reconciliation", "skipping", "false", "requeue", res.Requeue)
 		return res, err
 	}
 
-	return ctrl.Result{}, nil
+	return res, nil
 }
 
 // SetupWithManager sets up with Manager
@@ -185,11 +215,31 @@ func (r *ClowdAppReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		builder.WithPredicates(environmentPredicate(r.Log, "app")),
 	)
 	ctrlr.Watches(&source.Kind{Type: &apps.Deployment{}}, createNewHandler(deploymentFilter, r.Log, "app", &crd.ClowdApp{}, r.HashCache))
+	ctrlr.Watches(&source.Kind{Type: &apps.StatefulSet{}}, createNewHandler(alwaysFilter, r.Log, "app", &crd.ClowdApp{}, r.HashCache))
 	ctrlr.Watches(&source.Kind{Type: &core.Service{}}, createNewHandler(generationOnlyFilter, r.Log, "app", &crd.ClowdApp{}, r.HashCache))
 	ctrlr.Watches(&source.Kind{Type: &core.ConfigMap{}}, createNewHandler(generationOnlyFilter, r.Log, "app", &crd.ClowdApp{}, r.HashCache))
 	ctrlr.Watches(&source.Kind{Type: &core.Secret{}}, createNewHandler(alwaysFilter, r.Log, "app", &crd.ClowdApp{}, r.HashCache))
+	ctrlr.Watches(&source.Channel{Source: appResyncs}, &handler.EnqueueRequestForObject{})
+
+	// cert-manager updates a Certificate once it has issued the serving cert, the app is then
+	// reconciled to pick up the CA of the cert
+	certManagerInstalled, err := web.CertManagerInstalled(mgr.GetRESTMapper())
+	if err != nil {
+		return err
+	}
+	if certManagerInstalled {
+		ctrlr.Watches(&source.Kind{Type: web.WebServingCertificate.GetType()}, createNewHandler(alwaysFilter, r.Log, "app", &crd.ClowdApp{}, r.HashCache))
+	}
+	if r.ReferencedSecrets != nil {
+		ctrlr.Watches(
+			source.NewKindWithCache(&core.Secret{}, r.ReferencedSecrets),
+			handler.EnqueueRequestsFromMapFunc(appsReadingSecret),
+			builder.WithPredicates(referencedSecretPredicate()),
+		)
+	}
 	ctrlr.WithOptions(controller.Options{
-		RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(time.Duration(500*time.Millisecond), time.Duration(60*time.Second)),
+		MaxConcurrentReconciles: maxConcurrentReconciles(clowderconfig.LoadedConfig.Settings.MaxConcurrentAppReconciles),
+		RateLimiter:             workqueue.NewItemExponentialFailureRateLimiter(time.Duration(500*time.Millisecond), time.Duration(60*time.Second)),
 	})
 	return ctrlr.Complete(r)
 }
@@ -257,6 +307,7 @@ func updateMetadata(app *crd.ClowdApp, appConfig *config.AppConfig) {
 			Name:  deployment.Name,
 			Image: deployment.PodSpec.Image,
 		}
+		deploymentMetadata.ImageTag, deploymentMetadata.ImageDigest = parseImageReference(deployment.PodSpec.Image)
 		metadata.Deployments = append(metadata.Deployments, deploymentMetadata)
 	}
 
@@ -264,3 +315,21 @@ func updateMetadata(app *crd.ClowdApp, appConfig *config.AppConfig) {
 	appConfig.Metadata.Name = &app.Name
 	appConfig.Metadata.EnvName = &app.Spec.EnvName
 }
+
+// parseImageReference returns the tag and the digest of an image reference, either is nil if the
+// reference does not have one.
+func parseImageReference(image string) (*string, *string) {
+	var tag, digest *string
+
+	if i := strings.LastIndex(image, "@"); i != -1 {
+		digest = utils.StringPtr(image[i+1:])
+		image = image[:i]
+	}
+
+	// a colon before the last slash separates the registry host from its port
+	if i := strings.LastIndex(image, ":"); i != -1 && i > strings.LastIndex(image, "/") {
+		tag = utils.StringPtr(image[i+1:])
+	}
+
+	return tag, digest
+}
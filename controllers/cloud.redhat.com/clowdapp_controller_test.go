@@ -37,3 +37,21 @@ func TestReconcileMetricsEndEnabled(_ *testing.T) {
 	reconciler.start()
 	reconciler.stop()
 }
+
+func TestParseImageReference(t *testing.T) {
+	tag, digest := parseImageReference("quay.io/cloudservices/app:1a2b3c")
+	assert.Equal(t, "1a2b3c", *tag)
+	assert.Nil(t, digest)
+
+	tag, digest = parseImageReference("registry.local:5000/app@sha256:abcdef")
+	assert.Nil(t, tag)
+	assert.Equal(t, "sha256:abcdef", *digest)
+
+	tag, digest = parseImageReference("registry.local:5000/app:v1@sha256:abcdef")
+	assert.Equal(t, "v1", *tag)
+	assert.Equal(t, "sha256:abcdef", *digest)
+
+	tag, digest = parseImageReference("registry.local:5000/app")
+	assert.Nil(t, tag)
+	assert.Nil(t, digest)
+}
@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"context"
+	errlib "errors"
 	"fmt"
+	"strings"
 	"time"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
@@ -11,19 +13,31 @@ import (
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/hashcache"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/drain"
 	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
+	cond "sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// drainRequeueDelay is how often an app with a draining deployment is checked on
+const drainRequeueDelay = 10 * time.Second
+
+// readinessGateRequeueDelay is how often the dependencies of an app's readiness gates are checked
+const readinessGateRequeueDelay = 30 * time.Second
+
 type ClowdAppReconciliation struct {
 	cache                 *rc.ObjectCache
 	recorder              record.EventRecorder
@@ -37,6 +51,10 @@ type ClowdAppReconciliation struct {
 	config                *config.AppConfig
 	oldStatus             *crd.ClowdAppStatus
 	hashCache             *hashcache.HashCache
+	missingSecrets        []errors.MissingSecret
+	draining              bool
+	ignoring              *ignoringClient
+	topicsRequeueDelay    time.Duration
 }
 
 func (r *ClowdAppReconciliation) steps() []func() (ctrl.Result, error) {
@@ -46,6 +64,7 @@ func (r *ClowdAppReconciliation) steps() []func() (ctrl.Result, error) {
 		r.startMetrics,
 		r.isAppMarkedForDeletion,
 		r.addFinalizer,
+		r.isAppPaused,
 		r.isEnvLocked,
 		r.isAppDisabled,
 		r.isAppNamespaceDeleted,
@@ -53,11 +72,16 @@ func (r *ClowdAppReconciliation) steps() []func() (ctrl.Result, error) {
 		r.isEnvNamespaceDeleted,
 		r.isClowdEnvReconciled,
 		r.isEnvReady,
+		r.isProviderBackingOff,
 		r.createCache,
 		r.runProviders,
 		r.applyCache,
+		r.isMissingSecrets,
 		r.setAppResourceStatus,
+		r.setKafkaTopicStatus,
 		r.deletedUnusedResources,
+		r.reportDrift,
+		r.isDraining,
 		r.setReconciliationSuccessful,
 		r.stopMetrics,
 	}
@@ -70,6 +94,15 @@ func (r *ClowdAppReconciliation) Reconcile() (ctrl.Result, error) {
 			return result, err
 		}
 	}
+	if r.draining {
+		return ctrl.Result{RequeueAfter: drainRequeueDelay}, nil
+	}
+	if hasReadinessGates(r.app) {
+		return ctrl.Result{RequeueAfter: readinessGateRequeueDelay}, nil
+	}
+	if r.topicsRequeueDelay > 0 {
+		return ctrl.Result{RequeueAfter: r.topicsRequeueDelay}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
@@ -90,11 +123,11 @@ func (r *ClowdAppReconciliation) stopMetrics() (ctrl.Result, error) {
 }
 
 func (r *ClowdAppReconciliation) setPresentAndManagedApps() (ctrl.Result, error) {
-	presentApps[r.app.GetIdent()] = true
+	presentApps.add(r.app.GetIdent())
 
-	presentAppsMetric.Set(float64(len(presentApps)))
+	presentAppsMetric.Set(float64(presentApps.len()))
 
-	delete(managedApps, r.app.GetIdent())
+	managedApps.remove(r.app.GetIdent())
 
 	return ctrl.Result{}, nil
 }
@@ -141,17 +174,63 @@ func (r *ClowdAppReconciliation) isAppMarkedForDeletion() (ctrl.Result, error) {
 }
 
 func (r *ClowdAppReconciliation) finalizeApp() error {
+	if err := r.finalizeAppProviders(); err != nil {
+		return err
+	}
+
 	// We remove it from the managed list because it may have been managed before, but it may not be after this reconcile.
-	delete(managedApps, r.app.GetIdent())
-	managedAppsMetric.Set(float64(len(managedApps)))
+	managedApps.remove(r.app.GetIdent())
+	managedAppsMetric.Set(float64(managedApps.len()))
+
+	presentApps.remove(r.app.GetIdent())
+	presentAppsMetric.Set(float64(presentApps.len()))
+
+	pausedApps.remove(r.app.GetIdent())
+	setPausedMetrics()
 
-	delete(presentApps, r.app.GetIdent())
-	presentAppsMetric.Set(float64(len(presentApps)))
+	deleteAppHealth(r.app.GetIdent())
+	appProviderBackoffs.clear(types.NamespacedName{Name: r.app.Name, Namespace: r.app.Namespace})
+	referencedSecrets.setApp(types.NamespacedName{Name: r.app.Name, Namespace: r.app.Namespace}, nil)
+	deleteAppConditionMetrics(r.app.GetIdent())
 
 	r.log.Info("Successfully finalized ClowdApp")
 	return nil
 }
 
+func (r *ClowdAppReconciliation) finalizeAppProviders() error {
+	env := &crd.ClowdEnvironment{}
+	if err := r.client.Get(r.ctx, types.NamespacedName{Name: r.app.Spec.EnvName}, env); err != nil {
+		if k8serr.IsNotFound(err) {
+			// Without an environment there is nothing the providers can clean up
+			return nil
+		}
+		return err
+	}
+
+	provider := providers.Provider{
+		Client: r.client,
+		Ctx:    r.ctx,
+		Env:    env,
+		Log:    *r.log,
+	}
+
+	return runProvidersForAppFinalize(*r.log, provider, r.app)
+}
+
+func runProvidersForAppFinalize(log logr.Logger, provider providers.Provider, app *crd.ClowdApp) error {
+	for _, provAcc := range providers.ProvidersRegistration.Registry {
+		if provAcc.FinalizeApp != nil {
+			provutils.DebugLog(log, "running provider app finalize:", "name", provAcc.Name, "order", provAcc.Order)
+			err := provAcc.FinalizeApp(&provider, app)
+			if err != nil {
+				return errors.Wrap(fmt.Sprintf("prov app finalize: %s", provAcc.Name), err)
+			}
+			provutils.DebugLog(log, "running provider app finalize: complete", "name", provAcc.Name, "order", provAcc.Order)
+		}
+	}
+	return nil
+}
+
 func (r *ClowdAppReconciliation) addFinalizer() (ctrl.Result, error) {
 	if !contains(r.app.GetFinalizers(), appFinalizer) {
 		if addFinalizeErr := r.addFinalizerImplementation(); addFinalizeErr != nil {
@@ -175,8 +254,34 @@ func (r *ClowdAppReconciliation) addFinalizerImplementation() error {
 	return nil
 }
 
+// isAppPaused skips all the provider work for an app carrying the pause annotation, so that its
+// resources are neither updated nor deleted. The app is checked on periodically until unpaused.
+func (r *ClowdAppReconciliation) isAppPaused() (ctrl.Result, error) {
+	paused := isPaused(r.app)
+	if paused {
+		pausedApps.add(r.app.GetIdent())
+	} else {
+		pausedApps.remove(r.app.GetIdent())
+	}
+	setPausedMetrics()
+
+	wasPaused := cond.IsTrue(r.app, crd.Paused)
+	if err := setPausedCondition(r.ctx, r.client, r.app, paused); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.oldStatus = r.app.Status.DeepCopy()
+
+	if paused {
+		if !wasPaused {
+			r.recorder.Eventf(r.app, "Normal", "ReconciliationPaused", "Reconciliation is paused by the %s annotation", PausedAnnotation)
+		}
+		return ctrl.Result{RequeueAfter: pausedRequeueDelay}, NewSkippedError("app is paused")
+	}
+	return ctrl.Result{}, nil
+}
+
 func (r *ClowdAppReconciliation) isEnvLocked() (ctrl.Result, error) {
-	if ReadEnv() == r.app.Spec.EnvName {
+	if IsEnvLocked(r.app.Spec.EnvName) {
 		r.recorder.Eventf(r.app, "Warning", "ClowdEnvLocked", "Clowder Environment [%s] is locked", r.app.Spec.EnvName)
 		return ctrl.Result{Requeue: true}, NewSkippedError("env is locked")
 	}
@@ -249,9 +354,25 @@ func (r *ClowdAppReconciliation) isEnvReady() (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+// isProviderBackingOff skips the reconcile of an app whose provider failed recently, unless the
+// app or its environment have changed since, the app is requeued for when the backoff ends.
+func (r *ClowdAppReconciliation) isProviderBackingOff() (ctrl.Result, error) {
+	if provider, left, ok := appProviderBackoffs.remaining(r.app, r.env); ok {
+		return ctrl.Result{RequeueAfter: left}, NewSkippedError(fmt.Sprintf("provider [%s] is backing off", provider))
+	}
+	return ctrl.Result{}, nil
+}
+
 func (r *ClowdAppReconciliation) createCache() (ctrl.Result, error) {
+	var cacheClient client.Client = r.client
+
+	r.ignoring = newIgnoringClient(r.client, *r.log, r.env.Spec.IgnoredKinds, r.app.Spec.IgnoredKinds)
+	if r.ignoring != nil {
+		cacheClient = r.ignoring
+	}
+
 	cacheConfig := rc.NewCacheConfig(Scheme, nil, ProtectedGVKs, rc.Options{StrictGVK: true, DebugOptions: DebugOptions})
-	cache := rc.NewObjectCache(r.ctx, r.client, r.log, cacheConfig)
+	cache := rc.NewObjectCache(r.ctx, cacheClient, r.log, cacheConfig)
 	r.cache = &cache
 	return ctrl.Result{}, nil
 }
@@ -261,45 +382,70 @@ func (r *ClowdAppReconciliation) runProviders() (ctrl.Result, error) {
 	r.hashCache.RemoveClowdObjectFromObjects(r.app)
 
 	provider := providers.Provider{
-		Client:    r.client,
-		Ctx:       r.ctx,
-		Env:       r.env,
-		Cache:     r.cache,
-		Log:       *r.log,
-		Config:    r.config,
-		HashCache: r.hashCache,
+		Client:     r.client,
+		Ctx:        r.ctx,
+		Env:        r.env,
+		Cache:      r.cache,
+		Log:        *r.log,
+		Config:     r.config,
+		HashCache:  r.hashCache,
+		EnvCache:   sharedEnvObjects,
+		SecretRefs: providers.NewSecretRefs(),
 	}
 
-	if provErr := r.runProvidersImplementation(&provider); provErr != nil {
-		r.recorder.Eventf(r.app, "Warning", "FailedReconciliation", "Clowdapp requeued [%s]", r.app.GetClowdName())
+	// the secrets are recorded even if a provider failed, a change to a missing secret may fix it
+	provErr := r.runProvidersImplementation(&provider)
+	referencedSecrets.setApp(types.NamespacedName{Name: r.app.Name, Namespace: r.app.Namespace}, provider.SecretRefs.List())
+	if clowderconfig.LoadedConfig.Features.WatchReferencedSecrets {
+		if err := labelReferencedSecrets(r.ctx, r.client, provider.SecretRefs.List()); err != nil {
+			r.log.Info("Couldn't label referenced secrets", "err", err)
+		}
+	}
+
+	if provErr != nil {
+		delay := appProviderBackoffs.record(r.app, r.env, provErr)
+		r.recorder.Eventf(r.app, "Warning", "FailedReconciliation", "Clowdapp requeued [%s] after %s: %s", r.app.GetClowdName(), delay, provErr.Error())
 		if setClowdStatusErr := SetClowdAppConditions(r.ctx, r.client, r.app, crd.ReconciliationFailed, r.oldStatus, provErr); setClowdStatusErr != nil {
 			r.log.Info("Set status error", "err", setClowdStatusErr)
 			return ctrl.Result{Requeue: true}, setClowdStatusErr
 		}
-		r.log.Info("Provider error", "err", provErr)
-		return ctrl.Result{Requeue: true}, provErr
+		r.log.Info("Provider error", "provider", provErr.Provider, "permanent", provErr.Permanent, "retryAfter", delay.String(), "err", provErr.Err)
+		return ctrl.Result{RequeueAfter: delay}, provErr
 	}
 	return ctrl.Result{}, nil
 }
 
-func (r *ClowdAppReconciliation) runProvidersImplementation(provider *providers.Provider) error {
+// runProvidersImplementation runs the providers in order and stops at the first one that fails,
+// returning its error as a ProviderError.
+func (r *ClowdAppReconciliation) runProvidersImplementation(provider *providers.Provider) *errors.ProviderError {
 	// Update app metadata
 	updateMetadata(r.app, r.config)
 
+	ctx := provider.Ctx
+	defer func() { provider.Ctx = ctx }()
+
 	for _, provAcc := range providers.ProvidersRegistration.Registry {
 		provutils.DebugLog(*r.log, "running provider:", "name", provAcc.Name, "order", provAcc.Order)
+		var span trace.Span
+		provider.Ctx, span = startProviderSpan(ctx, provAcc.Name, "clowdapp")
 		prov, err := provAcc.SetupProvider(provider)
 		if err != nil {
-			return errors.Wrap(fmt.Sprintf("getprov: %s", provAcc.Name), err)
+			endSpan(span, err)
+			return errors.NewProviderError(provAcc.Name, errors.Wrap("getprov", err))
 		}
 		start := time.Now()
 		err = prov.Provide(r.app)
 		elapsed := time.Since(start).Seconds()
 		providerMetrics.With(prometheus.Labels{"provider": provAcc.Name, "source": "clowdapp"}).Observe(elapsed)
+		endSpan(span, err)
 		if err != nil {
-			reterr := errors.Wrap(fmt.Sprintf("runapp: %s", provAcc.Name), err)
-			reterr.Requeue = true
-			return reterr
+			var missingSecrets *errors.MissingSecrets
+			if errlib.As(err, &missingSecrets) && r.env.Spec.MissingSecretPolicy == "skip-provider" {
+				r.log.Info("Skipping provider with missing secrets", "name", provAcc.Name, "err", missingSecrets.Error())
+				r.missingSecrets = append(r.missingSecrets, missingSecrets.MissingSecrets...)
+				continue
+			}
+			return errors.NewProviderError(provAcc.Name, err)
 		}
 		provutils.DebugLog(*r.log, "running provider: complete", "name", provAcc.Name, "order", provAcc.Order, "elapsed", fmt.Sprintf("%f", elapsed))
 	}
@@ -324,6 +470,22 @@ func (r *ClowdAppReconciliation) applyCache() (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+// isMissingSecrets fails the reconciliation, after the remaining providers have been applied, if
+// any providers were skipped because of missing secrets.
+func (r *ClowdAppReconciliation) isMissingSecrets() (ctrl.Result, error) {
+	if len(r.missingSecrets) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	secretErr := &errors.MissingSecrets{MissingSecrets: r.missingSecrets}
+	r.recorder.Eventf(r.app, "Warning", "FailedReconciliation", "Clowdapp requeued [%s]", r.app.GetClowdName())
+	if setClowdStatusErr := SetClowdAppConditions(r.ctx, r.client, r.app, crd.ReconciliationFailed, r.oldStatus, secretErr); setClowdStatusErr != nil {
+		r.log.Info("Set status error", "err", setClowdStatusErr)
+		return ctrl.Result{Requeue: true}, setClowdStatusErr
+	}
+	return ctrl.Result{Requeue: true}, secretErr
+}
+
 func (r *ClowdAppReconciliation) setAppResourceStatus() (ctrl.Result, error) {
 	if statusErr := SetAppResourceStatus(r.ctx, r.client, r.app); statusErr != nil {
 		r.log.Info("Set status error", "err", statusErr)
@@ -332,6 +494,18 @@ func (r *ClowdAppReconciliation) setAppResourceStatus() (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+func (r *ClowdAppReconciliation) setKafkaTopicStatus() (ctrl.Result, error) {
+	now := metav1.Now()
+	statuses, err := GetAppKafkaTopicStatus(r.ctx, r.client, r.app, r.env, r.app.Status.KafkaTopics, now)
+	if err != nil {
+		r.log.Info("Set kafka topic status error", "err", err)
+		return ctrl.Result{Requeue: true}, err
+	}
+	r.app.Status.KafkaTopics = statuses
+	r.topicsRequeueDelay = kafkaTopicRequeueDelay(statuses, now)
+	return ctrl.Result{}, nil
+}
+
 func (r *ClowdAppReconciliation) deletedUnusedResources() (ctrl.Result, error) {
 	opts := []client.ListOption{
 		client.MatchingLabels{r.app.GetPrimaryLabel(): r.app.GetClowdName()},
@@ -347,12 +521,53 @@ func (r *ClowdAppReconciliation) deletedUnusedResources() (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
+// reportDrift raises an event listing the resources of ignored kinds that were not written
+// because they differ from what was rendered.
+func (r *ClowdAppReconciliation) reportDrift() (ctrl.Result, error) {
+	if r.ignoring == nil {
+		return ctrl.Result{}, nil
+	}
+
+	if drift := r.ignoring.Drift(); len(drift) > 0 {
+		r.recorder.Eventf(r.app, "Warning", "ResourceDrift", "Resources of ignored kinds were not applied: %s", strings.Join(drift, ", "))
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// isDraining checks whether any of the deployments is being drained ahead of a rollout. The
+// app is revisited until the drain finishes, terminating pods do not always trigger a reconcile.
+func (r *ClowdAppReconciliation) isDraining() (ctrl.Result, error) {
+	dList := apps.DeploymentList{}
+	if err := r.cache.List(deployProvider.CoreDeployment, &dList); err != nil {
+		return ctrl.Result{Requeue: true}, err
+	}
+
+	for _, d := range dList.Items {
+		if _, ok := d.GetAnnotations()[drain.DrainStartedAnnotation]; ok {
+			r.log.Info("Deployment is draining before rollout", "deployment", d.Name)
+			r.draining = true
+		}
+	}
+	return ctrl.Result{}, nil
+}
+
+func hasReadinessGates(app *crd.ClowdApp) bool {
+	for _, deployment := range app.Spec.Deployments {
+		if len(deployment.ReadinessGates) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *ClowdAppReconciliation) setReconciliationSuccessful() (ctrl.Result, error) {
 	if setClowdStatusErr := SetClowdAppConditions(r.ctx, r.client, r.app, crd.ReconciliationSuccessful, r.oldStatus, nil); setClowdStatusErr != nil {
 		r.log.Info("Set status error", "err", setClowdStatusErr)
 		return ctrl.Result{Requeue: true}, setClowdStatusErr
 	}
-	managedApps[r.app.GetIdent()] = true
+	managedApps.add(r.app.GetIdent())
+	appProviderBackoffs.clear(types.NamespacedName{Name: r.app.Name, Namespace: r.app.Namespace})
 
 	r.recorder.Eventf(r.app, "Normal", "SuccessfulReconciliation", "Clowdapp reconciled [%s]", r.app.GetClowdName())
 	r.log.Info("Reconciliation successful")
@@ -26,14 +26,18 @@ import (
 	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
+	cond "sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
@@ -47,7 +51,9 @@ import (
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/database"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/dependencies"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/drain"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/featureflags"
+	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/imageregistry"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/inmemorydb"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/iqe"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/kafka"
@@ -56,6 +62,7 @@ import (
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/namespace"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/objectstore"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/pullsecrets"
+	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/readinessgate"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/serviceaccount"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/servicemesh"
 	_ "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/sidecar"
@@ -75,7 +82,7 @@ import (
 )
 
 var mu sync.RWMutex
-var cEnv = ""
+var lockedEnvs = map[string]bool{}
 
 const (
 	envFinalizer = "finalizer.env.cloud.redhat.com"
@@ -89,27 +96,31 @@ type ClowdEnvironmentReconciler struct {
 	Scheme    *runtime.Scheme
 	Recorder  record.EventRecorder
 	HashCache *hashcache.HashCache
+	// ReferencedSecrets, when set, holds the secrets labelled as read by the environments
+	ReferencedSecrets cache.Cache
 }
 
 // +kubebuilder:rbac:groups=cloud.redhat.com,resources=clowdenvironments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cloud.redhat.com,resources=clowdenvironments/status,verbs=get;update;patch
 
+// SetEnv locks the environment while it is reconciled, environments are reconciled in parallel so
+// each is locked separately.
 func SetEnv(name string) {
 	mu.Lock()
 	defer mu.Unlock()
-	cEnv = name
+	lockedEnvs[name] = true
 }
 
-func ReleaseEnv() {
+func ReleaseEnv(name string) {
 	mu.Lock()
 	defer mu.Unlock()
-	cEnv = ""
+	delete(lockedEnvs, name)
 }
 
-func ReadEnv() string {
+func IsEnvLocked(name string) bool {
 	mu.RLock()
 	defer mu.RUnlock()
-	return cEnv
+	return lockedEnvs[name]
 }
 
 // Acts as a guard for a reconciliation cycle, as well as initatizes a bunch of required objects
@@ -124,16 +135,21 @@ func (r *ClowdEnvironmentReconciler) getEnv(ctx context.Context, req ctrl.Reques
 }
 
 func (r *ClowdEnvironmentReconciler) initMetrics(env crd.ClowdEnvironment) {
-	if _, ok := presentEnvironments[env.Name]; !ok {
-		presentEnvironments[env.Name] = true
-	}
-	presentEnvsMetric.Set(float64(len(presentEnvironments)))
+	presentEnvironments.add(env.Name)
+	presentEnvsMetric.Set(float64(presentEnvironments.len()))
 
-	delete(managedEnvironments, env.Name)
+	managedEnvironments.remove(env.Name)
 }
 
+// kafkaConnectRequeueDelay is how long to wait before checking on a KafkaConnect cluster that is
+// not yet ready
+const kafkaConnectRequeueDelay = 30 * time.Second
+
 // Reconcile fn
-func (r *ClowdEnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *ClowdEnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	ctx, span := startSpan(ctx, "ClowdEnvironment.Reconcile", attribute.String("env", req.Name))
+	defer func() { endSpan(span, err) }()
+
 	log := r.Log.WithValues("env", req.Name).WithValues("rid", utils.RandString(5))
 	ctx = context.WithValue(ctx, errors.ClowdKey("log"), &log)
 	ctx = context.WithValue(ctx, errors.ClowdKey("recorder"), &r.Recorder)
@@ -155,7 +171,7 @@ func (r *ClowdEnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 	r.initMetrics(env)
 
 	defer func() {
-		managedEnvsMetric.Set(float64(len(managedEnvironments)))
+		managedEnvsMetric.Set(float64(managedEnvironments.len()))
 	}()
 
 	reconciliation := ClowdEnvironmentReconciliation{
@@ -174,25 +190,42 @@ func (r *ClowdEnvironmentReconciler) Reconcile(ctx context.Context, req ctrl.Req
 			log.Info("skipping", "error", resErr.Error(), "skipping", "true", "requeue", result.Requeue)
 			return result, nil
 		}
+		countReconcileError("clowdenvironment", resErr)
 		log.Error(err, "error in reconciliation", "skipping", "false", "requeue", result.Requeue)
 		return result, resErr
 	}
-	managedEnvironments[env.Name] = true
+	managedEnvironments.add(env.Name)
+
+	if err := setEnvReadyMetric(ctx, r.Client, &env, nil); err != nil {
+		log.Info("could not update the environment ready metric", "err", err)
+	}
+
+	// KafkaConnect is not watched unless strimzi resources are, so check back on it until it
+	// becomes ready rather than relying on an event
+	if cond.IsTrue(&env, crd.WaitingForKafkaConnect) {
+		log.Info("Waiting for KafkaConnect cluster to become ready", "requeueAfter", kafkaConnectRequeueDelay)
+		return ctrl.Result{RequeueAfter: kafkaConnectRequeueDelay}, nil
+	}
 
 	return ctrl.Result{}, nil
 }
 
 func runProvidersForEnv(log logr.Logger, provider providers.Provider) error {
+	ctx := provider.Ctx
 	for _, provAcc := range providers.ProvidersRegistration.Registry {
 		provutils.DebugLog(log, "running provider:", "name", provAcc.Name, "order", provAcc.Order)
+		var span trace.Span
+		provider.Ctx, span = startProviderSpan(ctx, provAcc.Name, "clowdenv")
 		start := time.Now()
 		prov, err := provAcc.SetupProvider(&provider)
 		if err != nil {
+			endSpan(span, err)
 			return errors.Wrap(fmt.Sprintf("getprov: %s", provAcc.Name), err)
 		}
 		err = prov.EnvProvide()
 		elapsed := time.Since(start).Seconds()
 		providerMetrics.With(prometheus.Labels{"provider": provAcc.Name, "source": "clowdenv"}).Observe(elapsed)
+		endSpan(span, err)
 		if err != nil {
 			return errors.Wrap(fmt.Sprintf("runprov: %s", provAcc.Name), err)
 		}
@@ -230,6 +263,14 @@ func (r *ClowdEnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		builder.WithPredicates(predicate.GenerationChangedPredicate{}),
 	)
 
+	if r.ReferencedSecrets != nil {
+		ctrlr.Watches(
+			source.NewKindWithCache(&core.Secret{}, r.ReferencedSecrets),
+			handler.EnqueueRequestsFromMapFunc(envsReadingSecret),
+			builder.WithPredicates(referencedSecretPredicate()),
+		)
+	}
+
 	if clowderconfig.LoadedConfig.Features.WatchStrimziResources {
 		ctrlr.Watches(&source.Kind{Type: &strimzi.Kafka{}}, createNewHandler(kafkaFilter, r.Log, "env", &crd.ClowdEnvironment{}, r.HashCache))
 		ctrlr.Watches(&source.Kind{Type: &strimzi.KafkaConnect{}}, createNewHandler(alwaysFilter, r.Log, "env", &crd.ClowdEnvironment{}, r.HashCache))
@@ -238,7 +279,8 @@ func (r *ClowdEnvironmentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	}
 
 	ctrlr.WithOptions(controller.Options{
-		RateLimiter: workqueue.NewItemExponentialFailureRateLimiter(time.Duration(500*time.Millisecond), time.Duration(60*time.Second)),
+		MaxConcurrentReconciles: maxConcurrentReconciles(clowderconfig.LoadedConfig.Settings.MaxConcurrentEnvReconciles),
+		RateLimiter:             workqueue.NewItemExponentialFailureRateLimiter(time.Duration(500*time.Millisecond), time.Duration(60*time.Second)),
 	})
 	return ctrlr.Complete(r)
 }
@@ -9,9 +9,11 @@ import (
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 	"github.com/go-logr/logr"
 	"github.com/prometheus/client_golang/prometheus"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	cond "sigs.k8s.io/cluster-api/util/conditions"
@@ -64,10 +66,12 @@ func (r *ClowdEnvironmentReconciliation) steps() []func() (ctrl.Result, error) {
 	return []func() (ctrl.Result, error){
 		r.markedForDeletion,
 		r.addFinalizerIfRequired,
+		r.isPaused,
 		r.perProviderMetrics,
 		r.setToBeDisabled,
 		r.initTargetNamespace,
 		r.isTargetNamespaceMarkedForDeletion,
+		r.setTargetNamespaceMetadata,
 		r.runProviders,
 		r.applyCache,
 		r.setAppInfo,
@@ -88,7 +92,7 @@ func (r *ClowdEnvironmentReconciliation) Reconcile() (ctrl.Result, error) {
 	// This is a slight change from the previous implementation
 	// where the lock wasn't initated until the target namespace had been initialized
 	SetEnv(r.env.Name)
-	defer ReleaseEnv()
+	defer ReleaseEnv(r.env.Name)
 	for _, step := range r.steps() {
 		result, err := step()
 		if err != nil {
@@ -147,11 +151,18 @@ func (r *ClowdEnvironmentReconciliation) finalizeEnvironmentImplementation() err
 		r.recorder.Eventf(r.env, "Warning", "NamespaceDeletion", "Clowder Environment [%s] had no targetNamespace, deleting generated namespace", r.env.Name)
 		_ = r.client.Delete(context.TODO(), namespace)
 	}
-	delete(managedEnvironments, r.env.Name)
-	managedEnvsMetric.Set(float64(len(managedEnvironments)))
+	managedEnvironments.remove(r.env.Name)
+	managedEnvsMetric.Set(float64(managedEnvironments.len()))
 
-	delete(presentEnvironments, r.env.Name)
-	presentEnvsMetric.Set(float64(len(presentEnvironments)))
+	presentEnvironments.remove(r.env.Name)
+	presentEnvsMetric.Set(float64(presentEnvironments.len()))
+
+	pausedEnvironments.remove(r.env.Name)
+	setPausedMetrics()
+
+	deleteEnvReadyMetric(r.env.Name)
+	sharedEnvObjects.Invalidate(r.env.Name)
+	referencedSecrets.setEnv(r.env.Name, nil)
 
 	r.log.Info("Successfully finalized ClowdEnvironment")
 	return nil
@@ -183,6 +194,33 @@ func (r *ClowdEnvironmentReconciliation) addFinalizerImplementation() error {
 	return nil
 }
 
+// Skips all the provider work for an environment carrying the pause annotation, so that its
+// resources are neither updated nor deleted. The environment is checked on periodically until
+// unpaused.
+func (r *ClowdEnvironmentReconciliation) isPaused() (ctrl.Result, error) {
+	paused := isPaused(r.env)
+	if paused {
+		pausedEnvironments.add(r.env.Name)
+	} else {
+		pausedEnvironments.remove(r.env.Name)
+	}
+	setPausedMetrics()
+
+	wasPaused := cond.IsTrue(r.env, crd.Paused)
+	if err := setPausedCondition(r.ctx, r.client, r.env, paused); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.oldStatus = r.env.Status.DeepCopy()
+
+	if paused {
+		if !wasPaused {
+			r.recorder.Eventf(r.env, "Normal", "ReconciliationPaused", "Reconciliation is paused by the %s annotation", PausedAnnotation)
+		}
+		return ctrl.Result{RequeueAfter: pausedRequeueDelay}, NewSkippedError("env is paused")
+	}
+	return ctrl.Result{}, nil
+}
+
 // Request per provider methods if the config calls for it
 func (r *ClowdEnvironmentReconciliation) perProviderMetrics() (ctrl.Result, error) {
 	if clowderconfig.LoadedConfig.Features.PerProviderMetrics {
@@ -279,15 +317,58 @@ func (r *ClowdEnvironmentReconciliation) isTargetNamespaceMarkedForDeletion() (c
 	return ctrl.Result{}, nil
 }
 
+// Keep the configured labels and annotations on the target namespace, a pre-existing namespace is
+// only changed when the environment opts in to merging them
+func (r *ClowdEnvironmentReconciliation) setTargetNamespaceMetadata() (ctrl.Result, error) {
+	metadata := r.env.Spec.TargetNamespaceMetadata
+	if len(metadata.Labels) == 0 && len(metadata.Annotations) == 0 {
+		return ctrl.Result{}, nil
+	}
+
+	if r.env.Spec.TargetNamespace != "" && !metadata.MergeExisting {
+		return ctrl.Result{}, nil
+	}
+
+	ens := &core.Namespace{}
+	if getNSErr := r.client.Get(r.ctx, types.NamespacedName{Name: r.env.Status.TargetNamespace}, ens); getNSErr != nil {
+		return ctrl.Result{Requeue: true}, getNSErr
+	}
+
+	oldMeta := ens.ObjectMeta.DeepCopy()
+	utils.UpdateLabels(ens, metadata.Labels)
+	utils.UpdateAnnotations(ens, metadata.Annotations)
+
+	if equality.Semantic.DeepEqual(*oldMeta, ens.ObjectMeta) {
+		return ctrl.Result{}, nil
+	}
+
+	if nsErr := r.client.Update(r.ctx, ens); nsErr != nil {
+		r.log.Info("Namespace update error", "err", nsErr)
+		if setClowdStatusErr := SetClowdEnvConditions(r.ctx, r.client, r.env, crd.ReconciliationFailed, r.oldStatus, nsErr); setClowdStatusErr != nil {
+			r.log.Info("Set status error", "err", setClowdStatusErr)
+			return ctrl.Result{Requeue: true}, setClowdStatusErr
+		}
+		return ctrl.Result{Requeue: true}, nsErr
+	}
+	return ctrl.Result{}, nil
+}
+
 func (r *ClowdEnvironmentReconciliation) runProviders() (ctrl.Result, error) {
 	provider := providers.Provider{
-		Ctx:    r.ctx,
-		Client: r.client,
-		Env:    r.env,
-		Cache:  r.cache,
-		Log:    *r.log,
+		Ctx:        r.ctx,
+		Client:     r.client,
+		Env:        r.env,
+		Cache:      r.cache,
+		Log:        *r.log,
+		SecretRefs: providers.NewSecretRefs(),
 	}
 	provErr := runProvidersForEnv(*r.log, provider)
+	referencedSecrets.setEnv(r.env.Name, provider.SecretRefs.List())
+	if clowderconfig.LoadedConfig.Features.WatchReferencedSecrets {
+		if err := labelReferencedSecrets(r.ctx, r.client, provider.SecretRefs.List()); err != nil {
+			r.log.Info("Couldn't label referenced secrets", "err", err)
+		}
+	}
 
 	if provErr != nil {
 		r.log.Info("Prov err", "err", provErr)
@@ -311,6 +392,10 @@ func (r *ClowdEnvironmentReconciliation) applyCache() (ctrl.Result, error) {
 		}
 		return ctrl.Result{Requeue: true}, cacheErr
 	}
+
+	// The objects shared by the apps, like the Kafka cluster, may have changed
+	sharedEnvObjects.Invalidate(r.env.Name)
+
 	return ctrl.Result{}, nil
 }
 
@@ -8,11 +8,13 @@ import (
 
 type ClowderConfig struct {
 	Images struct {
-		MBOP           string `json:"mbop"`
-		Caddy          string `json:"caddy"`
-		Keycloak       string `json:"Keycloak"`
-		Mocktitlements string `json:"mocktitlements"`
-		Envoy          string `json:"envoy"`
+		MBOP            string `json:"mbop"`
+		Caddy           string `json:"caddy"`
+		Keycloak        string `json:"Keycloak"`
+		Mocktitlements  string `json:"mocktitlements"`
+		Envoy           string `json:"envoy"`
+		ConfigValidator string `json:"configValidator"`
+		PgBouncer       string `json:"pgBouncer"`
 	} `json:"images"`
 	DebugOptions struct {
 		Logging struct {
@@ -43,11 +45,45 @@ type ClowderConfig struct {
 		DisableCloudWatchLogging    bool `json:"disableCloudWatchLogging"`
 		EnableExternalStrimzi       bool `json:"enableExternalStrimzi"`
 		DisableRandomRoutes         bool `json:"disableRandomRoutes"`
+		// ValidateAppConfig checks every generated cdappconfig.json against the app config
+		// schema before it is written, failing the reconcile of an app whose config does not
+		// match.
+		ValidateAppConfig bool `json:"validateAppConfig"`
+		// WatchReferencedSecrets reconciles the apps and environments reading a secret that
+		// Clowder doesn't own, such as the cloudwatch secret, as soon as it changes rather than
+		// at the next resync.
+		WatchReferencedSecrets bool `json:"watchReferencedSecrets"`
 	} `json:"features"`
 	Settings struct {
-		ManagedKafkaEphemDeleteRegex string `json:"managedKafkaEphemDeleteRegex"`
-		RestarterAnnotationName      string `json:"restarterAnnotation"`
+		ManagedKafkaEphemDeleteRegex       string `json:"managedKafkaEphemDeleteRegex"`
+		ManagedKafkaEphemDeleteConcurrency int    `json:"managedKafkaEphemDeleteConcurrency"`
+		RestarterAnnotationName            string `json:"restarterAnnotation"`
+		TracingEndpoint                    string `json:"tracingEndpoint"`
+		// MaxConcurrentAppReconciles and MaxConcurrentEnvReconciles are the number of different
+		// objects that may be reconciled in parallel, a single object is never reconciled twice at
+		// the same time. Both default to 1.
+		MaxConcurrentAppReconciles int `json:"maxConcurrentAppReconciles"`
+		MaxConcurrentEnvReconciles int `json:"maxConcurrentEnvReconciles"`
+		// ClientQPS and ClientBurst rate limit the requests to the API server, the defaults of 20
+		// and 30 are used when unset.
+		ClientQPS   float32 `json:"clientQPS"`
+		ClientBurst int     `json:"clientBurst"`
+		// ConfigRevealTokenFile is the path of a file holding the bearer token that is required
+		// to read the unredacted config of an app from the API, revealing is disabled when unset.
+		// The token is kept in a file, usually a mounted Secret, as the clowder config is itself
+		// served by the API.
+		ConfigRevealTokenFile string `json:"configRevealTokenFile"`
+		// ResyncPeriodSeconds is how often every object is reconciled regardless of changes, the
+		// controller-runtime default of 10 hours is used when unset.
+		ResyncPeriodSeconds int `json:"resyncPeriodSeconds"`
 	} `json:"settings"`
+	// Defaults are applied to ClowdEnvironments that leave the corresponding field unset.
+	Defaults struct {
+		Testing struct {
+			K8SAccessLevel string `json:"k8sAccessLevel"`
+			ConfigAccess   string `json:"configAccess"`
+		} `json:"testing"`
+	} `json:"defaults"`
 }
 
 func getConfig() ClowderConfig {
@@ -83,6 +119,16 @@ func getConfig() ClowderConfig {
 
 var LoadedConfig ClowderConfig
 
+// TracingEndpoint returns the Jaeger collector endpoint that the traces of reconciles are exported
+// to, the OTEL_EXPORTER_JAEGER_ENDPOINT environment variable is used when the setting is unset.
+// Tracing is disabled when neither is set.
+func TracingEndpoint() string {
+	if LoadedConfig.Settings.TracingEndpoint != "" {
+		return LoadedConfig.Settings.TracingEndpoint
+	}
+	return os.Getenv("OTEL_EXPORTER_JAEGER_ENDPOINT")
+}
+
 func init() {
 	LoadedConfig = getConfig()
 }
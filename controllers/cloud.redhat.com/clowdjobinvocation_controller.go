@@ -110,6 +110,13 @@ func (r *ClowdJobInvocationReconciler) Reconcile(ctx context.Context, req ctrl.R
 	// CJI has already invoked a job, we'll update the status. The Job map must have entries
 	// because it can exist to update the status without having done any work.
 	if cji.Status.JobMap != nil && len(cji.Status.JobMap) > 0 {
+		if err := r.retryIqeJob(ctx, &cache, &cji); err != nil {
+			r.Log.Error(err, "Iqe Job could not be retried", "jobinvocation", cji.Name)
+			if condErr := SetClowdJobInvocationConditions(ctx, r.Client, &cji, crd.ReconciliationFailed, err); condErr != nil {
+				return ctrl.Result{}, condErr
+			}
+			return ctrl.Result{Requeue: true}, err
+		}
 		if condErr := SetClowdJobInvocationConditions(ctx, r.Client, &cji, crd.ReconciliationSuccessful, nil); condErr != nil {
 			return ctrl.Result{}, condErr
 		}
@@ -201,45 +208,19 @@ func (r *ClowdJobInvocationReconciler) Reconcile(ctx context.Context, req ctrl.R
 	var emptyTesting crd.IqeJobSpec
 	if cji.Spec.Testing.Iqe != emptyTesting {
 
-		nn := types.NamespacedName{
-			Name:      cji.GenerateJobName(),
-			Namespace: cji.Namespace,
-		}
-
-		j := batchv1.Job{}
-		if err := cache.Create(iqe.IqeClowdJob, nn, &j); err != nil {
-			r.Log.Error(err, "Iqe Job could not be created via cache", "jobinvocation", nn.Name)
-			if condErr := SetClowdJobInvocationConditions(ctx, r.Client, &cji, crd.ReconciliationFailed, err); condErr != nil {
-				return ctrl.Result{}, condErr
-			}
-			return ctrl.Result{}, err
-		}
-
-		if err := iqe.CreateIqeJobResource(ctx, &cache, &cji, &env, &app, nn, &j, r.Log, r.Client); err != nil {
-			r.Log.Error(err, "Iqe Job creation encountered an error", "jobinvocation", nn.Name)
-			r.Recorder.Eventf(&cji, "Warning", "IQEJobFailure", "Job [%s] failed to invoke", j.ObjectMeta.Name)
-			if condErr := SetClowdJobInvocationConditions(ctx, r.Client, &cji, crd.ReconciliationFailed, err); condErr != nil {
-				return ctrl.Result{}, condErr
-			}
-			return ctrl.Result{}, err
+		// Tests started before the backends of the app are ready only flake, so the invocation
+		// waits on them. Nothing has been applied yet, the jobs above are invoked along with the
+		// tests once the backends are ready.
+		if waiting, result, err := r.waitForBackends(ctx, &cji, &app, &env); waiting {
+			return result, err
 		}
 
-		if err := cache.Update(iqe.IqeClowdJob, &j); err != nil {
-			r.Log.Error(err, "Iqe Job could not update via cache", "jobinvocation", nn.Name)
+		if err := r.invokeIqeJob(ctx, &cache, &cji, &env, &app); err != nil {
 			if condErr := SetClowdJobInvocationConditions(ctx, r.Client, &cji, crd.ReconciliationFailed, err); condErr != nil {
 				return ctrl.Result{}, condErr
 			}
 			return ctrl.Result{}, err
-
 		}
-		r.Log.Info("Iqe Job Invoked Successfully", "jobinvocation", nn.Name, "namespace", app.Namespace)
-		if cji.Status.JobMap != nil {
-			cji.Status.JobMap[nn.Name] = crd.JobInvoked
-		} else {
-			cji.Status.JobMap = map[string]crd.JobConditionState{nn.Name: crd.JobInvoked}
-		}
-
-		r.Recorder.Eventf(&cji, "Normal", "IQEJobInvoked", "Job [%s] was invoked successfully", j.ObjectMeta.Name)
 	}
 
 	if cacheErr := cache.ApplyAll(); cacheErr != nil {
@@ -300,6 +281,130 @@ func (r *ClowdJobInvocationReconciler) InvokeJob(cache *rc.ObjectCache, job *crd
 	return nil
 }
 
+// invokeIqeJob creates a new run of the IQE job of the CJI and records it in the status
+func (r *ClowdJobInvocationReconciler) invokeIqeJob(ctx context.Context, cache *rc.ObjectCache, cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, app *crd.ClowdApp) error {
+	nn := types.NamespacedName{
+		Name:      cji.GenerateJobName(),
+		Namespace: cji.Namespace,
+	}
+
+	j := batchv1.Job{}
+	if err := cache.Create(iqe.IqeClowdJob, nn, &j); err != nil {
+		r.Log.Error(err, "Iqe Job could not be created via cache", "jobinvocation", nn.Name)
+		return err
+	}
+
+	if err := iqe.CreateIqeJobResource(ctx, cache, cji, env, app, nn, &j, r.Log, r.Client); err != nil {
+		r.Log.Error(err, "Iqe Job creation encountered an error", "jobinvocation", nn.Name)
+		r.Recorder.Eventf(cji, "Warning", "IQEJobFailure", "Job [%s] failed to invoke", j.ObjectMeta.Name)
+		return err
+	}
+
+	if err := cache.Update(iqe.IqeClowdJob, &j); err != nil {
+		r.Log.Error(err, "Iqe Job could not update via cache", "jobinvocation", nn.Name)
+		return err
+	}
+
+	r.Log.Info("Iqe Job Invoked Successfully", "jobinvocation", nn.Name, "namespace", app.Namespace)
+	if cji.Status.JobMap != nil {
+		cji.Status.JobMap[nn.Name] = crd.JobInvoked
+	} else {
+		cji.Status.JobMap = map[string]crd.JobConditionState{nn.Name: crd.JobInvoked}
+	}
+	cji.Status.IqeAttempts++
+
+	r.Recorder.Eventf(cji, "Normal", "IQEJobInvoked", "Job [%s] was invoked successfully", j.ObjectMeta.Name)
+	return nil
+}
+
+// retryIqeJob invokes the IQE job of the CJI again when every run of it so far has failed and
+// the environment allows for more retries. Each retry is a new job, the jobs keep a backoffLimit
+// of 0 so that a failed run stays visible on its own job.
+func (r *ClowdJobInvocationReconciler) retryIqeJob(ctx context.Context, cache *rc.ObjectCache, cji *crd.ClowdJobInvocation) error {
+	var emptyTesting crd.IqeJobSpec
+	if cji.Spec.Testing.Iqe == emptyTesting || cji.Status.IqeAttempts == 0 {
+		return nil
+	}
+
+	jobs, err := cji.GetInvokedJobs(ctx, r.Client)
+	if err != nil {
+		return err
+	}
+	if countFailedIqeJobs(jobs, cji) < cji.Status.IqeAttempts {
+		return nil
+	}
+
+	app := crd.ClowdApp{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: cji.Spec.AppName, Namespace: cji.Namespace}, &app); err != nil {
+		return err
+	}
+
+	env := crd.ClowdEnvironment{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: app.Spec.EnvName}, &env); err != nil {
+		return err
+	}
+
+	if cji.Status.IqeAttempts > env.Spec.Providers.Testing.Retries {
+		return nil
+	}
+
+	r.Log.Info("Retrying failed Iqe Job", "jobinvocation", cji.Name, "attempt", cji.Status.IqeAttempts+1)
+	if err := r.invokeIqeJob(ctx, cache, cji, &env, &app); err != nil {
+		return err
+	}
+
+	if err := cache.ApplyAll(); err != nil {
+		return err
+	}
+
+	// the conditions are unchanged by a retry, so the status would not be written when they are set
+	return r.Client.Status().Update(ctx, cji)
+}
+
+// waitForBackends returns true while the backends of the app are not ready, along with the result
+// of the reconcile waiting on them. The invocation gives up once it waited longer than the timeout
+// of the environment.
+func (r *ClowdJobInvocationReconciler) waitForBackends(ctx context.Context, cji *crd.ClowdJobInvocation, app *crd.ClowdApp, env *crd.ClowdEnvironment) (bool, ctrl.Result, error) {
+	ready, msg, err := GetAppBackendStatus(ctx, r.Client, app, env)
+	if err == nil && ready {
+		return false, ctrl.Result{}, nil
+	}
+
+	// the jobs walked before the tests were never applied
+	cji.Status.JobMap = map[string]crd.JobConditionState{}
+
+	if err != nil {
+		if condErr := SetClowdJobInvocationConditions(ctx, r.Client, cji, crd.ReconciliationFailed, err); condErr != nil {
+			return true, ctrl.Result{}, condErr
+		}
+		return true, ctrl.Result{}, err
+	}
+
+	backendErr := errors.NewClowderError(msg)
+	if time.Since(cji.CreationTimestamp.Time) > getBackendsTimeout(env) {
+		r.Recorder.Eventf(cji, "Warning", "BackendsNotReady", "Backends of ClowdApp [%s] did not become ready in time; Job cannot be invoked", app.Name)
+		if condErr := SetClowdJobInvocationConditions(ctx, r.Client, cji, crd.ReconciliationFailed, backendErr); condErr != nil {
+			return true, ctrl.Result{}, condErr
+		}
+		return true, ctrl.Result{}, nil
+	}
+
+	r.Log.Info("Backends not yet ready, requeue", "jobinvocation", cji.Name, "namespace", app.Namespace, "msg", msg)
+	if condErr := SetClowdJobInvocationConditions(ctx, r.Client, cji, crd.ReconciliationFailed, backendErr); condErr != nil {
+		return true, ctrl.Result{}, condErr
+	}
+	// the backends are not watched, so their status is polled
+	return true, ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+}
+
+// getBackendsTimeout returns how long an invocation waits on the backends of its app.
+func getBackendsTimeout(env *crd.ClowdEnvironment) time.Duration {
+	if env.Spec.Providers.Testing.BackendsTimeoutSeconds == 0 {
+		return 600 * time.Second
+	}
+	return time.Duration(env.Spec.Providers.Testing.BackendsTimeoutSeconds) * time.Second
+}
+
 // getJobFromName matches a CJI job name to an App's job definition
 func getJobFromName(jobName string, app *crd.ClowdApp) (job crd.Job, err error) {
 	for _, j := range app.Spec.Jobs {
@@ -354,7 +459,12 @@ func GetJobsStatus(jobs *batchv1.JobList, cji *crd.ClowdJobInvocation) bool {
 	jobsRequired := len(cji.Spec.Jobs)
 	var emptyTesting crd.IqeJobSpec
 	if cji.Spec.Testing.Iqe != emptyTesting {
-		jobsRequired++
+		// every retried run of the IQE job has failed and counts as completed
+		if cji.Status.IqeAttempts > 1 {
+			jobsRequired += int(cji.Status.IqeAttempts)
+		} else {
+			jobsRequired++
+		}
 	}
 	jobsCompleted := countCompletedJobs(jobs, cji)
 	return jobsCompleted == jobsRequired
@@ -382,3 +492,19 @@ func countCompletedJobs(jobs *batchv1.JobList, cji *crd.ClowdJobInvocation) int
 	}
 	return jobsCompleted
 }
+
+// countFailedIqeJobs counts the runs of the IQE job of the CJI that have failed
+func countFailedIqeJobs(jobs *batchv1.JobList, cji *crd.ClowdJobInvocation) int32 {
+
+	failed := int32(0)
+
+	for _, j := range jobs.Items {
+		if _, ok := cji.Status.JobMap[j.ObjectMeta.Name]; !ok || j.ObjectMeta.Labels["job"] != cji.GetIQEName() {
+			continue
+		}
+		if len(j.Status.Conditions) > 0 && j.Status.Conditions[0].Type == batchv1.JobFailed {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// failIqeJobs marks every run of the IQE job of the CJI that is still running as failed.
+func failIqeJobs(t *testing.T, ctx context.Context, cl client.Client, cji *crd.ClowdJobInvocation) {
+	jobs, err := cji.GetInvokedJobs(ctx, cl)
+	assert.NoError(t, err)
+	for i := range jobs.Items {
+		j := &jobs.Items[i]
+		if len(j.Status.Conditions) > 0 {
+			continue
+		}
+		j.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: core.ConditionTrue}}
+		assert.NoError(t, cl.Status().Update(ctx, j))
+	}
+}
+
+func TestIqeJobRetries(t *testing.T) {
+	ctx := context.Background()
+
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Testing: crd.TestingConfig{
+					Iqe:     crd.IqeConfig{ImageBase: "quay.io/cloudservices/iqe-tests"},
+					Retries: 2,
+				},
+			},
+		},
+	}
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: crd.ClowdAppSpec{
+			EnvName: "env",
+			Testing: crd.TestingSpec{IqePlugin: "plugin"},
+		},
+	}
+	cji := &crd.ClowdJobInvocation{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "cloud.redhat.com/v1alpha1", Kind: "ClowdJobInvocation"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cji", Namespace: "default"},
+		Spec: crd.ClowdJobInvocationSpec{
+			AppName: "app",
+			Testing: crd.JobTestingSpec{Iqe: crd.IqeJobSpec{Marker: "smoke"}},
+		},
+	}
+
+	cl := fake.NewClientBuilder().WithScheme(Scheme).WithObjects(env, app, cji).Build()
+	nn := types.NamespacedName{Name: "cji", Namespace: "default"}
+	assert.NoError(t, cl.Get(ctx, nn, cji))
+	r := &ClowdJobInvocationReconciler{
+		Client:   cl,
+		Log:      logr.Discard(),
+		Scheme:   Scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}
+	newCache := func() *rc.ObjectCache {
+		log := logr.Discard()
+		cache := rc.NewObjectCache(ctx, cl, &log, rc.NewCacheConfig(Scheme, nil, nil))
+		return &cache
+	}
+
+	cache := newCache()
+	assert.NoError(t, r.invokeIqeJob(ctx, cache, cji, env, app))
+	assert.NoError(t, cache.ApplyAll())
+	assert.NoError(t, cl.Status().Update(ctx, cji))
+
+	// a running job is not retried
+	assert.NoError(t, r.retryIqeJob(ctx, newCache(), cji))
+	assert.Equal(t, int32(1), cji.Status.IqeAttempts)
+
+	// every failed run is retried until the retries of the environment are used up
+	for i := 0; i < 4; i++ {
+		failIqeJobs(t, ctx, cl, cji)
+		assert.NoError(t, r.retryIqeJob(ctx, newCache(), cji))
+	}
+
+	assert.Equal(t, int32(3), cji.Status.IqeAttempts)
+	jobs, err := cji.GetInvokedJobs(ctx, cl)
+	assert.NoError(t, err)
+	assert.Len(t, jobs.Items, 3)
+	assert.Equal(t, int32(3), countFailedIqeJobs(jobs, cji))
+	assert.True(t, GetJobsStatus(jobs, cji), "the invocation did not complete after the last retry failed")
+
+	fetched := &crd.ClowdJobInvocation{}
+	assert.NoError(t, cl.Get(ctx, nn, fetched))
+	assert.Equal(t, int32(3), fetched.Status.IqeAttempts)
+	assert.Len(t, fetched.Status.JobMap, 3)
+}
+
+func TestIqeJobStatusWithRetries(t *testing.T) {
+	cji := &crd.ClowdJobInvocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "cji", Namespace: "default"},
+		Spec: crd.ClowdJobInvocationSpec{
+			Testing: crd.JobTestingSpec{Iqe: crd.IqeJobSpec{Marker: "smoke"}},
+		},
+		Status: crd.ClowdJobInvocationStatus{
+			IqeAttempts: 2,
+			JobMap:      map[string]crd.JobConditionState{"cji-iqe-a": crd.JobFailed, "cji-iqe-b": crd.JobInvoked},
+		},
+	}
+	job := func(name string, conditions ...batchv1.JobConditionType) batchv1.Job {
+		j := batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"job": "cji-iqe"}}}
+		for _, c := range conditions {
+			j.Status.Conditions = append(j.Status.Conditions, batchv1.JobCondition{Type: c})
+		}
+		return j
+	}
+
+	// the failed first run does not complete the invocation while the retry is running
+	jobs := &batchv1.JobList{Items: []batchv1.Job{job("cji-iqe-a", batchv1.JobFailed), job("cji-iqe-b")}}
+	assert.Equal(t, int32(1), countFailedIqeJobs(jobs, cji))
+	assert.False(t, GetJobsStatus(jobs, cji))
+
+	jobs.Items[1] = job("cji-iqe-b", batchv1.JobComplete)
+	assert.True(t, GetJobsStatus(jobs, cji))
+}
+
+func TestIqeJobWaitsForKafka(t *testing.T) {
+	ctx := context.Background()
+
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{
+					Mode:    "operator",
+					Cluster: crd.KafkaClusterConfig{Name: "kafka", Namespace: "kafka"},
+				},
+				Testing: crd.TestingConfig{
+					Iqe: crd.IqeConfig{ImageBase: "quay.io/cloudservices/iqe-tests"},
+				},
+			},
+		},
+	}
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: crd.ClowdAppSpec{
+			EnvName:     "env",
+			Testing:     crd.TestingSpec{IqePlugin: "plugin"},
+			KafkaTopics: []crd.KafkaTopicSpec{{TopicName: "inventory"}},
+		},
+		Status: crd.ClowdAppStatus{
+			Ready:      true,
+			Conditions: []clusterv1.Condition{{Type: crd.ReconciliationSuccessful, Status: core.ConditionTrue}},
+		},
+	}
+	cji := &crd.ClowdJobInvocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "cji", Namespace: "default", CreationTimestamp: metav1.Now()},
+		Spec: crd.ClowdJobInvocationSpec{
+			AppName: "app",
+			Testing: crd.JobTestingSpec{Iqe: crd.IqeJobSpec{Marker: "smoke"}},
+		},
+	}
+	cluster := &strimzi.Kafka{ObjectMeta: metav1.ObjectMeta{Name: "kafka", Namespace: "kafka"}}
+	topic := &strimzi.KafkaTopic{ObjectMeta: metav1.ObjectMeta{
+		Name:      "inventory",
+		Namespace: "kafka",
+		Labels:    map[string]string{"app": "app", "app-namespace": "default"},
+	}}
+
+	cl := fake.NewClientBuilder().WithScheme(Scheme).WithObjects(env, app, cji, cluster, topic).Build()
+	r := &ClowdJobInvocationReconciler{
+		Client:   cl,
+		Log:      logr.Discard(),
+		Scheme:   Scheme,
+		Recorder: record.NewFakeRecorder(100),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "cji", Namespace: "default"}}
+
+	result, err := r.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.NotZero(t, result.RequeueAfter, "the invocation was not requeued while waiting on kafka")
+
+	jobs := &batchv1.JobList{}
+	assert.NoError(t, cl.List(ctx, jobs))
+	assert.Empty(t, jobs.Items, "the test job was created before kafka was ready")
+
+	fetched := &crd.ClowdJobInvocation{}
+	assert.NoError(t, cl.Get(ctx, req.NamespacedName, fetched))
+	for _, c := range fetched.Status.Conditions {
+		if c.Type == crd.ReconciliationFailed {
+			assert.Contains(t, c.Reason, "kafka kafka/kafka")
+			assert.Contains(t, c.Reason, "topic inventory/kafka")
+		}
+	}
+
+	ready := []strimzi.KafkaStatusConditionsElem{{Type: utils.StringPtr("Ready"), Status: utils.StringPtr("True")}}
+	cluster.Status = &strimzi.KafkaStatus{Conditions: ready}
+	assert.NoError(t, cl.Status().Update(ctx, cluster))
+	topic.Status = &strimzi.KafkaTopicStatus{Conditions: []strimzi.KafkaTopicStatusConditionsElem{{Type: utils.StringPtr("Ready"), Status: utils.StringPtr("True")}}}
+	assert.NoError(t, cl.Status().Update(ctx, topic))
+
+	result, err = r.Reconcile(ctx, req)
+	assert.NoError(t, err)
+	assert.Zero(t, result.RequeueAfter)
+
+	assert.NoError(t, cl.List(ctx, jobs))
+	if assert.Len(t, jobs.Items, 1) {
+		assert.Equal(t, "cji-iqe", jobs.Items[0].Labels["job"])
+	}
+}
+
+func TestIqeJobBackendsTimeout(t *testing.T) {
+	env := &crd.ClowdEnvironment{}
+	assert.Equal(t, 600*time.Second, getBackendsTimeout(env))
+
+	env.Spec.Providers.Testing.BackendsTimeoutSeconds = 30
+	assert.Equal(t, 30*time.Second, getBackendsTimeout(env))
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -26,3 +27,57 @@ func TestDbasePopulate(t *testing.T) {
 	assert.Equal(t, inputData["pgPass"], "pgPass", "they should be equal")
 	assert.Equal(t, inputData["name"], "name", "they should be equal")
 }
+
+func TestValidate(t *testing.T) {
+	publicPort := 8000
+	appConfig := AppConfig{
+		PublicPort:  &publicPort,
+		MetricsPort: 9000,
+		MetricsPath: "/metrics",
+		Logging:     LoggingConfig{Type: "null"},
+		Kafka: &KafkaConfig{
+			Brokers: []BrokerConfig{{Hostname: "kafka"}},
+			Topics:  []TopicConfig{{Name: "inventory", RequestedName: "inventory"}},
+		},
+	}
+
+	jsonData, err := json.Marshal(appConfig)
+	assert.NoError(t, err)
+	assert.NoError(t, Validate(jsonData))
+
+	err = Validate([]byte(`{
+		"publicPort": "8000",
+		"metricsPort": 9000,
+		"metricsPath": "/metrics",
+		"logging": {"type": 3},
+		"kafka": {"brokers": [{"hostname": "kafka", "port": 9092.5}]}
+	}`))
+
+	validationErr := &ValidationError{}
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		"$.publicPort: expected integer, got string",
+		"$.logging.type: expected string, got integer",
+		"$.kafka: missing required property topics",
+		"$.kafka.brokers[0].port: expected integer, got number",
+	}, validationErr.Problems)
+}
+
+func TestResolveRefs(t *testing.T) {
+	schema := &schemaNode{}
+	assert.NoError(t, json.Unmarshal([]byte(`{
+		"properties": {"port": {"$ref": "#/definitions/Port"}},
+		"definitions": {"Port": {"$ref": "#/definitions/Integer"}, "Integer": {"type": "integer"}}
+	}`), schema))
+	assert.NoError(t, resolveRefs(schema, schema))
+	assert.Equal(t, schema.Definitions["Integer"], schema.Properties["port"].target)
+
+	// a bad reference is reported when the schema is loaded, not when a config is validated
+	schema = &schemaNode{}
+	assert.NoError(t, json.Unmarshal([]byte(`{"properties": {"port": {"$ref": "#/definitions/Port"}}}`), schema))
+	assert.EqualError(t, resolveRefs(schema, schema), "unresolved reference #/definitions/Port")
+
+	v := &validator{}
+	v.validate("$", schema, map[string]interface{}{"port": json.Number("8000")})
+	assert.Equal(t, []string{"$.port: unresolved reference #/definitions/Port in the app config schema"}, v.problems)
+}
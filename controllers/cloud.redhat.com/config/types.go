@@ -77,6 +77,9 @@ type AppConfig struct {
 	// Endpoints corresponds to the JSON schema field "endpoints".
 	Endpoints []DependencyEndpoint `json:"endpoints,omitempty"`
 
+	// ExtraPorts corresponds to the JSON schema field "extraPorts".
+	ExtraPorts []ExtraPortConfig `json:"extraPorts,omitempty"`
+
 	// FeatureFlags corresponds to the JSON schema field "featureFlags".
 	FeatureFlags *FeatureFlagsConfig `json:"featureFlags,omitempty"`
 
@@ -120,8 +123,17 @@ type AppConfig struct {
 	// Defines the port CA path
 	TlsCAPath *string `json:"tlsCAPath,omitempty"`
 
+	// Defines the port the TLS sidecar serves the public API on
+	TlsPort *int `json:"tlsPort,omitempty"`
+
+	// Defines the port the TLS sidecar serves the private API on
+	TlsPrivatePort *int `json:"tlsPrivatePort,omitempty"`
+
 	// Deprecated: Use 'publicPort' instead.
 	WebPort *int `json:"webPort,omitempty"`
+
+	// WebProtocols corresponds to the JSON schema field "webProtocols".
+	WebProtocols []WebProtocolConfig `json:"webProtocols,omitempty"`
 }
 
 // UnmarshalJSON implements json.Unmarshaler.
@@ -178,6 +190,54 @@ func (j *PrivateDependencyEndpoint) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *ExtraPortConfig) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if v, ok := raw["deployment"]; !ok || v == nil {
+		return fmt.Errorf("field deployment: required")
+	}
+	if v, ok := raw["name"]; !ok || v == nil {
+		return fmt.Errorf("field name: required")
+	}
+	if v, ok := raw["port"]; !ok || v == nil {
+		return fmt.Errorf("field port: required")
+	}
+	if v, ok := raw["protocol"]; !ok || v == nil {
+		return fmt.Errorf("field protocol: required")
+	}
+	type Plain ExtraPortConfig
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = ExtraPortConfig(plain)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (j *WebProtocolConfig) UnmarshalJSON(b []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if v, ok := raw["deployment"]; !ok || v == nil {
+		return fmt.Errorf("field deployment: required")
+	}
+	if v, ok := raw["protocol"]; !ok || v == nil {
+		return fmt.Errorf("field protocol: required")
+	}
+	type Plain WebProtocolConfig
+	var plain Plain
+	if err := json.Unmarshal(b, &plain); err != nil {
+		return err
+	}
+	*j = WebProtocolConfig(plain)
+	return nil
+}
+
 // UnmarshalJSON implements json.Unmarshaler.
 func (j *ObjectStoreConfig) UnmarshalJSON(b []byte) error {
 	var raw map[string]interface{}
@@ -510,6 +570,10 @@ type DependencyEndpoint struct {
 	// The port of the dependent service.
 	Port int `json:"port"`
 
+	// The protocol served on the port of the dependent service, one of http, http2 or
+	// grpc.
+	Protocol *string `json:"protocol,omitempty"`
+
 	// The TLS port of the dependent service.
 	TlsPort *int `json:"tlsPort,omitempty"`
 }
@@ -519,8 +583,41 @@ type DeploymentMetadata struct {
 	// Image used by deployment
 	Image string `json:"image"`
 
+	// Digest of the image used by deployment, if it is pinned by digest
+	ImageDigest *string `json:"imageDigest,omitempty"`
+
+	// Tag of the image used by deployment, if it has one
+	ImageTag *string `json:"imageTag,omitempty"`
+
 	// Name of deployment
 	Name string `json:"name"`
+
+	// Resources corresponds to the JSON schema field "resources".
+	Resources *DeploymentResources `json:"resources,omitempty"`
+}
+
+// Resource requests and limits applied to a deployment
+type DeploymentResources struct {
+	// Resource limits of the deployment, null if no limits are set
+	Limits *ResourceValues `json:"limits"`
+
+	// Resource requests of the deployment, null if no requests are set
+	Requests *ResourceValues `json:"requests"`
+}
+
+// An additional port exposed by a deployment of the app
+type ExtraPortConfig struct {
+	// The PodSpec name of the deployment exposing the port.
+	Deployment string `json:"deployment"`
+
+	// The name of the port.
+	Name string `json:"name"`
+
+	// The port the deployment listens on, its service exposes the same port.
+	Port int `json:"port"`
+
+	// The protocol of the port, one of TCP, UDP or SCTP.
+	Protocol string `json:"protocol"`
 }
 
 // Feature Flags Configuration
@@ -565,6 +662,10 @@ type KafkaConfig struct {
 
 	// Defines a list of the topic configurations available to the application.
 	Topics []TopicConfig `json:"topics"`
+
+	// Defines the consumer groups the application is permitted to use, when
+	// the consumer groups are restricted.
+	ConsumerGroups []string `json:"consumerGroups,omitempty"`
 }
 
 // SASL Configuration for Kafka
@@ -587,6 +688,9 @@ type LoggingConfig struct {
 	// Cloudwatch corresponds to the JSON schema field "cloudwatch".
 	Cloudwatch *CloudWatchConfig `json:"cloudwatch,omitempty"`
 
+	// Splunk corresponds to the JSON schema field "splunk".
+	Splunk *SplunkConfig `json:"splunk,omitempty"`
+
 	// Defines the type of logging configuration
 	Type string `json:"type"`
 }
@@ -648,6 +752,30 @@ type PrivateDependencyEndpoint struct {
 	TlsPort *int `json:"tlsPort,omitempty"`
 }
 
+// CPU and memory resource values
+type ResourceValues struct {
+	// CPU resource value, null if not set
+	Cpu *string `json:"cpu"`
+
+	// Memory resource value, null if not set
+	Memory *string `json:"memory"`
+}
+
+// Splunk HEC configuration
+type SplunkConfig struct {
+	// Defines the URL of the HEC endpoint that the app should send its logs to.
+	Endpoint string `json:"endpoint"`
+
+	// Defines the index that the app should send its logs to.
+	Index *string `json:"index,omitempty"`
+
+	// Defines the source that the app should tag its logs with.
+	Source string `json:"source"`
+
+	// Defines the HEC token that the app should use for configuring Splunk.
+	Token string `json:"token"`
+}
+
 // Topic Configuration
 type TopicConfig struct {
 	// The name of the actual topic on the Kafka server.
@@ -657,6 +785,15 @@ type TopicConfig struct {
 	RequestedName string `json:"requestedName"`
 }
 
+// The protocol served on the public port of a deployment of the app
+type WebProtocolConfig struct {
+	// The PodSpec name of the deployment.
+	Deployment string `json:"deployment"`
+
+	// The protocol served on the public port, one of http, http2 or grpc.
+	Protocol string `json:"protocol"`
+}
+
 var enumValues_BrokerConfigAuthtype = []interface{}{
 	"mtls",
 	"sasl",
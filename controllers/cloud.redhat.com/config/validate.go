@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// schemaJSON is the schema the app config types are generated from.
+//
+//go:embed schema.json
+var schemaJSON []byte
+
+// schemaNode holds the subset of JSON schema keywords used by schema.json.
+type schemaNode struct {
+	Ref         string                 `json:"$ref"`
+	Type        schemaTypes            `json:"type"`
+	Properties  map[string]*schemaNode `json:"properties"`
+	Required    []string               `json:"required"`
+	Items       *schemaNode            `json:"items"`
+	Enum        []interface{}          `json:"enum"`
+	OneOf       []*schemaNode          `json:"oneOf"`
+	Definitions map[string]*schemaNode `json:"definitions"`
+
+	// target is the definition that Ref points to, it is set by resolveRefs.
+	target *schemaNode
+}
+
+// schemaTypes is the type keyword, which is either a single type or a list of them.
+type schemaTypes []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *schemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = schemaTypes{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(b, &list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// ValidationError lists every place where a document does not match the app config schema.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("app config does not match the schema: %s", strings.Join(e.Problems, "; "))
+}
+
+var appConfigSchema *schemaNode
+
+func init() {
+	appConfigSchema = &schemaNode{}
+	if err := json.Unmarshal(schemaJSON, appConfigSchema); err != nil {
+		panic(fmt.Sprintf("invalid app config schema: %s", err))
+	}
+	if err := resolveRefs(appConfigSchema, appConfigSchema); err != nil {
+		panic(fmt.Sprintf("invalid app config schema: %s", err))
+	}
+}
+
+// resolveRefs points every reference in the schema below node at its definition in the root, so
+// that the schema is only checked once rather than on every validation.
+func resolveRefs(root *schemaNode, node *schemaNode) error {
+	if node == nil {
+		return nil
+	}
+
+	if node.Ref != "" {
+		target := node
+		for i := 0; target.Ref != ""; i++ {
+			if i > len(root.Definitions) {
+				return fmt.Errorf("circular reference %s", node.Ref)
+			}
+			def, ok := root.Definitions[strings.TrimPrefix(target.Ref, "#/definitions/")]
+			if !ok {
+				return fmt.Errorf("unresolved reference %s", target.Ref)
+			}
+			target = def
+		}
+		node.target = target
+	}
+
+	children := []*schemaNode{node.Items}
+	children = append(children, node.OneOf...)
+	for _, prop := range node.Properties {
+		children = append(children, prop)
+	}
+	for _, def := range node.Definitions {
+		children = append(children, def)
+	}
+	for _, child := range children {
+		if err := resolveRefs(root, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks a marshalled app config against the app config schema and returns a
+// *ValidationError listing all the mismatches.
+func Validate(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return err
+	}
+
+	v := &validator{}
+	v.validate("$", appConfigSchema, doc)
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+	return nil
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(path string, format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) validate(path string, node *schemaNode, doc interface{}) {
+	if node.Ref != "" {
+		if node.target == nil {
+			v.fail(path, "unresolved reference %s in the app config schema", node.Ref)
+			return
+		}
+		node = node.target
+	}
+
+	if len(node.OneOf) > 0 {
+		matches := 0
+		for _, option := range node.OneOf {
+			inner := &validator{}
+			inner.validate(path, option, doc)
+			if len(inner.problems) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			v.fail(path, "matches %d of the oneOf schemas instead of exactly one", matches)
+		}
+	}
+
+	if len(node.Type) > 0 && !matchesType(node.Type, doc) {
+		v.fail(path, "expected %s, got %s", strings.Join(node.Type, " or "), typeOf(doc))
+		return
+	}
+
+	if len(node.Enum) > 0 && !inEnum(node.Enum, doc) {
+		v.fail(path, "value %v is not one of %v", doc, node.Enum)
+	}
+
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		for _, key := range node.Required {
+			if _, ok := value[key]; !ok {
+				v.fail(path, "missing required property %s", key)
+			}
+		}
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if prop, ok := node.Properties[key]; ok {
+				v.validate(path+"."+key, prop, value[key])
+			}
+		}
+	case []interface{}:
+		if node.Items != nil {
+			for i, item := range value {
+				v.validate(fmt.Sprintf("%s[%d]", path, i), node.Items, item)
+			}
+		}
+	}
+}
+
+func typeOf(doc interface{}) string {
+	switch value := doc.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func matchesType(types schemaTypes, doc interface{}) bool {
+	actual := typeOf(doc)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, doc interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(doc) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,114 @@
+// Package envcache holds read-only objects that are shared by all the ClowdApps of an environment,
+// such as the Strimzi Kafka resource or the cloudwatch secret. During a rollout of an environment
+// with many apps, each reconcile would otherwise fetch the same objects again.
+package envcache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultWindow is how long objects are reused, the apps reconciled within the window make up a
+// single reconcile cycle of the environment.
+const DefaultWindow = 5 * time.Second
+
+type key struct {
+	kind string
+	nn   types.NamespacedName
+}
+
+func keyFor(nn types.NamespacedName, obj runtime.Object) key {
+	return key{kind: fmt.Sprintf("%T", obj), nn: nn}
+}
+
+type entry struct {
+	resourceVersion string
+	expires         time.Time
+	objects         map[key]runtime.Object
+}
+
+// Cache keeps the shared objects of each environment for one reconcile cycle. A cycle ends when
+// the window expires, when the environment changes or when the cycle is invalidated, e.g. because
+// one of the cached objects changed.
+type Cache struct {
+	window time.Duration
+	now    func() time.Time
+	envs   map[string]*entry
+	lock   sync.Mutex
+}
+
+// NewCache returns a Cache reusing objects for the given window.
+func NewCache(window time.Duration) *Cache {
+	return &Cache{
+		window: window,
+		now:    time.Now,
+		envs:   map[string]*entry{},
+	}
+}
+
+// entryFor returns the entry of the current cycle of the environment, starting a new one if the
+// environment changed or the window expired. The lock must be held.
+func (c *Cache) entryFor(env *crd.ClowdEnvironment) *entry {
+	now := c.now()
+	e, ok := c.envs[env.Name]
+	if !ok || e.resourceVersion != env.ResourceVersion || now.After(e.expires) {
+		e = &entry{
+			resourceVersion: env.ResourceVersion,
+			expires:         now.Add(c.window),
+			objects:         map[key]runtime.Object{},
+		}
+		c.envs[env.Name] = e
+	}
+	return e
+}
+
+// Get reads the object with the client unless it was already read in the current cycle of the
+// environment. Errors, including missing objects, are not cached.
+func (c *Cache) Get(ctx context.Context, cl client.Client, env *crd.ClowdEnvironment, nn types.NamespacedName, obj client.Object) error {
+	k := keyFor(nn, obj)
+
+	c.lock.Lock()
+	cached, ok := c.entryFor(env).objects[k]
+	c.lock.Unlock()
+
+	if ok {
+		reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(cached.DeepCopyObject()).Elem())
+		return nil
+	}
+
+	if err := cl.Get(ctx, nn, obj); err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entryFor(env).objects[k] = obj.DeepCopyObject()
+	return nil
+}
+
+// Invalidate ends the current cycle of the environment.
+func (c *Cache) Invalidate(envName string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.envs, envName)
+}
+
+// InvalidateObject drops a changed object from the current cycle of every environment.
+func (c *Cache) InvalidateObject(obj client.Object) {
+	k := keyFor(types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}, obj)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, e := range c.envs {
+		delete(e.objects, k)
+	}
+}
@@ -0,0 +1,162 @@
+package envcache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// countingClient counts the reads that reach the API server.
+type countingClient struct {
+	client.Client
+	gets int
+}
+
+func (c *countingClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	c.gets++
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+var (
+	kafkaNN      = types.NamespacedName{Name: "kafka", Namespace: "kafka"}
+	kafkaCANN    = types.NamespacedName{Name: "kafka-cluster-ca-cert", Namespace: "kafka"}
+	cloudwatchNN = types.NamespacedName{Name: "cloudwatch", Namespace: "apps"}
+)
+
+func testClient(t testing.TB) *countingClient {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, strimzi.AddToScheme(scheme))
+
+	return &countingClient{Client: fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&strimzi.Kafka{ObjectMeta: metav1.ObjectMeta{Name: kafkaNN.Name, Namespace: kafkaNN.Namespace}},
+			&core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: kafkaCANN.Name, Namespace: kafkaCANN.Namespace},
+				Data:       map[string][]byte{"ca.crt": []byte("cert")},
+			},
+			&core.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: cloudwatchNN.Name, Namespace: cloudwatchNN.Namespace},
+				Data:       map[string][]byte{"aws_region": []byte("us-east-1")},
+			},
+		).
+		Build()}
+}
+
+func testEnv(resourceVersion string) *crd.ClowdEnvironment {
+	return &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env", ResourceVersion: resourceVersion}}
+}
+
+func TestCacheReusesObjects(t *testing.T) {
+	ctx := context.Background()
+	cl := testClient(t)
+	cache := NewCache(DefaultWindow)
+	env := testEnv("1")
+
+	first := &core.Secret{}
+	assert.NoError(t, cache.Get(ctx, cl, env, kafkaCANN, first))
+	first.Data["ca.crt"] = []byte("modified")
+
+	second := &core.Secret{}
+	assert.NoError(t, cache.Get(ctx, cl, env, kafkaCANN, second))
+	assert.Equal(t, "cert", string(second.Data["ca.crt"]), "a modified copy leaked into the cache")
+	assert.Equal(t, 1, cl.gets)
+
+	// objects of different kinds and names are cached apart
+	assert.NoError(t, cache.Get(ctx, cl, env, kafkaNN, &strimzi.Kafka{}))
+	assert.NoError(t, cache.Get(ctx, cl, env, cloudwatchNN, &core.Secret{}))
+	assert.Equal(t, 3, cl.gets)
+
+	// missing objects are read again, they may be created at any time
+	missing := types.NamespacedName{Name: "missing", Namespace: "apps"}
+	assert.True(t, k8serr.IsNotFound(cache.Get(ctx, cl, env, missing, &core.Secret{})))
+	assert.True(t, k8serr.IsNotFound(cache.Get(ctx, cl, env, missing, &core.Secret{})))
+	assert.Equal(t, 5, cl.gets)
+}
+
+func TestCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	cl := testClient(t)
+	cache := NewCache(DefaultWindow)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	get := func(env *crd.ClowdEnvironment) {
+		assert.NoError(t, cache.Get(ctx, cl, env, kafkaCANN, &core.Secret{}))
+	}
+
+	get(testEnv("1"))
+	get(testEnv("1"))
+	assert.Equal(t, 1, cl.gets)
+
+	// an environment that changed mid-cycle starts a new cycle
+	get(testEnv("2"))
+	get(testEnv("2"))
+	assert.Equal(t, 2, cl.gets)
+
+	// as does the end of the window
+	now = now.Add(DefaultWindow + time.Second)
+	get(testEnv("2"))
+	assert.Equal(t, 3, cl.gets)
+
+	cache.Invalidate("env")
+	get(testEnv("2"))
+	assert.Equal(t, 4, cl.gets)
+
+	// a changed object is read again, the others are kept
+	assert.NoError(t, cache.Get(ctx, cl, testEnv("2"), cloudwatchNN, &core.Secret{}))
+	cache.InvalidateObject(&core.Secret{ObjectMeta: metav1.ObjectMeta{Name: kafkaCANN.Name, Namespace: kafkaCANN.Namespace}})
+	get(testEnv("2"))
+	assert.NoError(t, cache.Get(ctx, cl, testEnv("2"), cloudwatchNN, &core.Secret{}))
+	assert.Equal(t, 6, cl.gets)
+}
+
+// BenchmarkSharedReads compares the reads made for the shared objects of an environment when 50
+// apps are reconciled, without and with the cache.
+func BenchmarkSharedReads(b *testing.B) {
+	const apps = 50
+	ctx := context.Background()
+	env := testEnv("1")
+
+	reconcileApps := func(get func(nn types.NamespacedName, obj client.Object) error) {
+		for i := 0; i < apps; i++ {
+			_ = get(kafkaNN, &strimzi.Kafka{})
+			_ = get(kafkaCANN, &core.Secret{})
+			_ = get(cloudwatchNN, &core.Secret{})
+		}
+	}
+
+	b.Run("direct", func(b *testing.B) {
+		cl := testClient(b)
+		for i := 0; i < b.N; i++ {
+			reconcileApps(func(nn types.NamespacedName, obj client.Object) error {
+				return cl.Get(ctx, nn, obj)
+			})
+		}
+		b.ReportMetric(float64(cl.gets)/float64(b.N), "gets/op")
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		cl := testClient(b)
+		for i := 0; i < b.N; i++ {
+			cache := NewCache(DefaultWindow)
+			reconcileApps(func(nn types.NamespacedName, obj client.Object) error {
+				return cache.Get(ctx, cl, env, nn, obj)
+			})
+		}
+		b.ReportMetric(float64(cl.gets)/float64(b.N), "gets/op")
+	})
+}
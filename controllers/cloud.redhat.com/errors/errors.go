@@ -108,6 +108,178 @@ func (e *MissingDependencies) Error() string {
 	return fmt.Sprintf("Missing dependencies: [%s]", body)
 }
 
+// MissingSecret is a struct that holds information about a secret required by a provider that
+// could not be found
+type MissingSecret struct {
+	Provider  string
+	Name      string
+	Namespace string
+}
+
+// ToString returns a string representation of the missing secret
+func (m *MissingSecret) ToString() string {
+	return fmt.Sprintf("provider: %s, secret: %s/%s", m.Provider, m.Namespace, m.Name)
+}
+
+func MakeMissingSecrets(missingSecret MissingSecret) MissingSecrets {
+	return MissingSecrets{
+		MissingSecrets: []MissingSecret{missingSecret},
+	}
+}
+
+// MissingSecrets is a struct that holds a list of MissingSecret structs
+type MissingSecrets struct {
+	MissingSecrets []MissingSecret
+}
+
+// Error returns a string representation of the missing secrets
+func (e *MissingSecrets) Error() string {
+	typeList := []string{}
+
+	for _, missingSecret := range e.MissingSecrets {
+		typeList = append(typeList, missingSecret.ToString())
+	}
+
+	body := strings.Join(typeList, "; ")
+
+	return fmt.Sprintf("Missing secrets: [%s]", body)
+}
+
+// MissingConfigMaps is a struct that holds the shared ConfigMaps, as namespace/name, required by
+// the environment that could not be found
+type MissingConfigMaps struct {
+	MissingConfigMaps []string
+}
+
+// Error returns a string representation of the missing configmaps
+func (e *MissingConfigMaps) Error() string {
+	return fmt.Sprintf("Missing configmaps: [%s]", strings.Join(e.MissingConfigMaps, "; "))
+}
+
+// InvalidAutoScalerTriggers is a struct that holds the problems found in the autoscaler triggers
+// of a ClowdApp
+type InvalidAutoScalerTriggers struct {
+	Problems []string
+}
+
+// Error returns a string representation of the invalid triggers
+func (e *InvalidAutoScalerTriggers) Error() string {
+	return fmt.Sprintf("Invalid autoscaler triggers: [%s]", strings.Join(e.Problems, "; "))
+}
+
+// TopicPartitionDecrease is returned when a topic is declared with fewer partitions than it
+// already has, Kafka can only ever increase the partitions of a topic
+type TopicPartitionDecrease struct {
+	Topic    string
+	Live     int
+	Declared int
+}
+
+// Error returns a string representation of the refused partition decrease
+func (e *TopicPartitionDecrease) Error() string {
+	return fmt.Sprintf("Topic [%s] has %d partitions and cannot be decreased to %d", e.Topic, e.Live, e.Declared)
+}
+
+// InvalidSingleton is returned when a singleton deployment is also configured in a way that would
+// run more than one replica of it
+type InvalidSingleton struct {
+	Deployment string
+	Problems   []string
+}
+
+// Error returns a string representation of the conflicting singleton config
+func (e *InvalidSingleton) Error() string {
+	return fmt.Sprintf("Singleton deployment [%s] has conflicting config: [%s]", e.Deployment, strings.Join(e.Problems, "; "))
+}
+
+// InvalidProbePort is returned when an httpGet probe of a deployment points at a port that the
+// container does not expose
+type InvalidProbePort struct {
+	Deployment string
+	Probe      string
+	Port       string
+}
+
+// Error returns a string representation of the probe and the port it points at
+func (e *InvalidProbePort) Error() string {
+	return fmt.Sprintf("Deployment [%s] has a %s probe on port [%s] which is not exposed by the container", e.Deployment, e.Probe, e.Port)
+}
+
+// InvalidTLSConfig is returned when the TLS config of the web provider of an environment is
+// incomplete for its mode
+type InvalidTLSConfig struct {
+	Mode   string
+	Reason string
+}
+
+// Error returns a string representation of the mode and what it is missing
+func (e *InvalidTLSConfig) Error() string {
+	return fmt.Sprintf("web TLS mode [%s] %s", e.Mode, e.Reason)
+}
+
+// InvalidReplicas is returned when the replicas of a deployment fall outside of the range allowed
+// by its environment, which rejects them
+type InvalidReplicas struct {
+	Deployment string
+	Problems   []string
+}
+
+// Error returns a string representation of the replicas outside of the allowed range
+func (e *InvalidReplicas) Error() string {
+	return fmt.Sprintf("Deployment [%s] has replicas outside of the range allowed by the environment: [%s]", e.Deployment, strings.Join(e.Problems, "; "))
+}
+
+// ProviderError is returned when a provider fails to run for a ClowdApp, it records the provider
+// and whether the failure is permanent, that is whether only a change to the ClowdApp or the
+// ClowdEnvironment can fix it.
+type ProviderError struct {
+	Provider  string
+	Permanent bool
+	Err       error
+}
+
+// NewProviderError wraps the error of a provider and classifies it.
+func NewProviderError(provider string, err error) *ProviderError {
+	return &ProviderError{
+		Provider:  provider,
+		Permanent: IsPermanent(err),
+		Err:       err,
+	}
+}
+
+// Error returns a string representation of the provider failure
+func (e *ProviderError) Error() string {
+	return fmt.Sprintf("provider [%s] failed: %s", e.Provider, e.Err.Error())
+}
+
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent returns true for errors that come from invalid config, retrying won't fix them
+// until the config changes.
+func IsPermanent(err error) bool {
+	var triggerErr *InvalidAutoScalerTriggers
+	var partitionErr *TopicPartitionDecrease
+	var singletonErr *InvalidSingleton
+	var probePortErr *InvalidProbePort
+	var tlsErr *InvalidTLSConfig
+	var replicasErr *InvalidReplicas
+
+	switch {
+	case errlib.As(err, &triggerErr),
+		errlib.As(err, &partitionErr),
+		errlib.As(err, &singletonErr),
+		errlib.As(err, &probePortErr),
+		errlib.As(err, &tlsErr),
+		errlib.As(err, &replicasErr):
+		return true
+	}
+
+	root := RootCause(err)
+	return k8serr.IsInvalid(root) || k8serr.IsBadRequest(root)
+}
+
 // RootCause takes an error an unwraps it, if it is nil, it calls RootCause on the returned err,
 // this will recursively find an error that has an unwrapped value.
 func RootCause(err error) error {
@@ -154,12 +326,49 @@ func HandleError(ctx context.Context, err error) bool {
 
 	if err != nil {
 		var depErr *MissingDependencies
+		var secretErr *MissingSecrets
+		var configMapErr *MissingConfigMaps
+		var triggerErr *InvalidAutoScalerTriggers
+		var partitionErr *TopicPartitionDecrease
+		var singletonErr *InvalidSingleton
+		var probePortErr *InvalidProbePort
 		var clowderError *ClowderError
 		if errlib.As(err, &depErr) {
 			msg := depErr.Error()
 			recorder.Event(obj, "Warning", "MissingDependencies", msg)
 			log.Info(msg)
 			return true
+		} else if errlib.As(err, &secretErr) {
+			msg := secretErr.Error()
+			recorder.Event(obj, "Warning", "SecretNotFound", msg)
+			log.Info(msg)
+			return true
+		} else if errlib.As(err, &configMapErr) {
+			msg := configMapErr.Error()
+			recorder.Event(obj, "Warning", "ConfigMapNotFound", msg)
+			log.Info(msg)
+			return true
+		} else if errlib.As(err, &triggerErr) {
+			// Only a change to the ClowdApp can fix the triggers, so there is no point requeuing
+			msg := triggerErr.Error()
+			recorder.Event(obj, "Warning", "InvalidAutoScalerTriggers", msg)
+			log.Info(msg)
+			return false
+		} else if errlib.As(err, &partitionErr) {
+			msg := partitionErr.Error()
+			recorder.Event(obj, "Warning", "TopicPartitionDecrease", msg)
+			log.Info(msg)
+			return false
+		} else if errlib.As(err, &singletonErr) {
+			msg := singletonErr.Error()
+			recorder.Event(obj, "Warning", "InvalidSingleton", msg)
+			log.Info(msg)
+			return false
+		} else if errlib.As(err, &probePortErr) {
+			msg := probePortErr.Error()
+			recorder.Event(obj, "Warning", "InvalidProbePort", msg)
+			log.Info(msg)
+			return false
 		} else if errlib.As(err, &clowderError) {
 			msg := clowderError.Error()
 			recorder.Event(obj, "Warning", "ClowdError", msg)
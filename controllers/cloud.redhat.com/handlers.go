@@ -189,6 +189,8 @@ func (e *enqueueRequestForObjectCustom) reconcileAllAppsUsingObject(obj client.O
 }
 
 func (e *enqueueRequestForObjectCustom) Update(evt event.UpdateEvent, q workqueue.RateLimitingInterface) {
+	sharedEnvObjects.InvalidateObject(evt.ObjectNew)
+
 	if evt.ObjectNew.GetAnnotations()[clowderconfig.LoadedConfig.Settings.RestarterAnnotationName] == "true" {
 		shouldUpdate, err := e.updateHashCacheForConfigMapAndSecret(evt.ObjectNew)
 		e.logMessage(evt.ObjectNew, "debug", fmt.Sprintf("shouldUpdate %s %v", e.ctrlName, shouldUpdate), getNamespacedName(evt.ObjectNew))
@@ -228,6 +230,7 @@ func (e *enqueueRequestForObjectCustom) Update(evt event.UpdateEvent, q workqueu
 
 func (e *enqueueRequestForObjectCustom) Delete(evt event.DeleteEvent, q workqueue.RateLimitingInterface) {
 	e.hashCache.Delete(evt.Object)
+	sharedEnvObjects.InvalidateObject(evt.Object)
 
 	if own, toKind := e.getOwner(evt.Object); own != nil {
 		if doRequest, msg := e.HandlerFuncs.DeleteFunc(evt); doRequest {
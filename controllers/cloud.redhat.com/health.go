@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	apps "k8s.io/api/apps/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// AppHealthStatus is the aggregated health of all of a ClowdApp's deployments.
+type AppHealthStatus string
+
+const (
+	// AppHealthy means every deployment of the ClowdApp is available.
+	AppHealthy AppHealthStatus = "healthy"
+	// AppDegraded means only some of the deployments of the ClowdApp are available.
+	AppDegraded AppHealthStatus = "degraded"
+	// AppUnavailable means none of the deployments of the ClowdApp are available.
+	AppUnavailable AppHealthStatus = "unavailable"
+)
+
+// AppHealth is the health of a ClowdApp as exposed by the internal API.
+type AppHealth struct {
+	Status      AppHealthStatus `json:"status"`
+	Deployments map[string]bool `json:"deployments"`
+}
+
+var healthMu sync.RWMutex
+var appHealth = map[string]AppHealth{}
+
+// GetAppHealth returns the last aggregated health of the ClowdApp with the given ident.
+func GetAppHealth(ident string) (AppHealth, bool) {
+	healthMu.RLock()
+	defer healthMu.RUnlock()
+	health, ok := appHealth[ident]
+	return health, ok
+}
+
+// GetAppsHealth returns the last aggregated health of every ClowdApp, keyed by ident.
+func GetAppsHealth() map[string]AppHealth {
+	healthMu.RLock()
+	defer healthMu.RUnlock()
+	apps := map[string]AppHealth{}
+	for ident, health := range appHealth {
+		apps[ident] = health
+	}
+	return apps
+}
+
+func deleteAppHealth(ident string) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	delete(appHealth, ident)
+}
+
+// aggregateHealth rolls the readiness of the individual deployments up into a single status.
+func aggregateHealth(deployments map[string]bool) AppHealthStatus {
+	ready := 0
+	for _, ok := range deployments {
+		if ok {
+			ready++
+		}
+	}
+	switch {
+	case ready == len(deployments):
+		return AppHealthy
+	case ready == 0:
+		return AppUnavailable
+	default:
+		return AppDegraded
+	}
+}
+
+// setAppHealth records the availability of each of the ClowdApp's deployments, a deployment
+// that has not been created yet is counted as unavailable.
+func setAppHealth(ctx context.Context, pClient client.Client, app *crd.ClowdApp) error {
+	deployments := map[string]bool{}
+	for i := range app.Spec.Deployments {
+		deployment := &app.Spec.Deployments[i]
+		if deployment.IsStatefulSet() {
+			s := apps.StatefulSet{}
+			err := pClient.Get(ctx, app.GetDeploymentNamespacedName(deployment), &s)
+			if err != nil && !k8serr.IsNotFound(err) {
+				return err
+			}
+			deployments[deployment.Name] = err == nil && statefulSetStatusChecker(s)
+			continue
+		}
+		d := apps.Deployment{}
+		err := pClient.Get(ctx, app.GetDeploymentNamespacedName(deployment), &d)
+		if err != nil && !k8serr.IsNotFound(err) {
+			return err
+		}
+		deployments[deployment.Name] = err == nil && deploymentStatusChecker(d)
+	}
+
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	appHealth[app.GetIdent()] = AppHealth{
+		Status:      aggregateHealth(deployments),
+		Deployments: deployments,
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ignoringClient wraps the client handed to the resource cache so that objects of the ignored
+// kinds are still rendered, and can be read back from the cache, but are never written. Every
+// write that was skipped is recorded as drift between the rendered and the live object.
+type ignoringClient struct {
+	client.Client
+	log   logr.Logger
+	kinds map[string]bool
+	mu    sync.Mutex
+	drift []string
+}
+
+// newIgnoringClient returns a client that ignores the union of the given kinds, or nil when
+// there is nothing to ignore.
+func newIgnoringClient(cl client.Client, log logr.Logger, kindLists ...[]string) *ignoringClient {
+	kinds := map[string]bool{}
+	for _, list := range kindLists {
+		for _, kind := range list {
+			kinds[kind] = true
+		}
+	}
+
+	if len(kinds) == 0 {
+		return nil
+	}
+
+	return &ignoringClient{Client: cl, log: log, kinds: kinds}
+}
+
+func (c *ignoringClient) ignore(obj client.Object, verb string) bool {
+	gvk, err := utils.GetKindFromObj(Scheme, obj)
+	if err != nil || !c.kinds[gvk.Kind] {
+		return false
+	}
+
+	c.log.Info("Ignoring write to resource", "kind", gvk.Kind, "namespace", obj.GetNamespace(), "name", obj.GetName(), "verb", verb)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.drift = append(c.drift, fmt.Sprintf("%s %s/%s (%s)", gvk.Kind, obj.GetNamespace(), obj.GetName(), verb))
+	return true
+}
+
+// Drift returns the writes that were skipped because their kind is ignored.
+func (c *ignoringClient) Drift() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.drift...)
+}
+
+func (c *ignoringClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if c.ignore(obj, "create") {
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *ignoringClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if c.ignore(obj, "update") {
+		return nil
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *ignoringClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if c.ignore(obj, "patch") {
+		return nil
+	}
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c *ignoringClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if c.ignore(obj, "delete") {
+		return nil
+	}
+	return c.Client.Delete(ctx, obj, opts...)
+}
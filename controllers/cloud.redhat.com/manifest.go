@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+// manifestLists are the kinds of the resources provisioned for an environment and its apps that
+// are exported in its manifest, in the order they are written out.
+func manifestLists() []client.ObjectList {
+	return []client.ObjectList{
+		&apps.DeploymentList{},
+		&core.ServiceList{},
+		&core.SecretList{},
+		&strimzi.KafkaTopicList{},
+		&keda.ScaledObjectList{},
+	}
+}
+
+// getEnvManifest returns the ClowdEnvironment, its ClowdApps and every object owned by either of
+// them as unstructured objects. The values of Secrets and the credentials in the env of pod
+// templates are redacted and the managed fields are dropped, the apps and the owned objects of
+// each kind are sorted by namespace and name.
+func getEnvManifest(ctx context.Context, pClient client.Client, env *crd.ClowdEnvironment) ([]map[string]interface{}, error) {
+	envApps, namespaces, owners, err := getEnvScope(ctx, pClient, env)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := []map[string]interface{}{}
+
+	envObj, err := manifestObject(env)
+	if err != nil {
+		return nil, err
+	}
+	manifest = append(manifest, envObj)
+
+	sort.Slice(envApps, func(i, j int) bool {
+		return objectKey(&envApps[i]) < objectKey(&envApps[j])
+	})
+	for i := range envApps {
+		appObj, err := manifestObject(&envApps[i])
+		if err != nil {
+			return nil, err
+		}
+		manifest = append(manifest, appObj)
+	}
+
+	for _, list := range manifestLists() {
+		owned := []client.Object{}
+		err := eachOwned(ctx, pClient, list, namespaces, owners, func(o client.Object) error {
+			owned = append(owned, o)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		sort.Slice(owned, func(i, j int) bool {
+			return objectKey(owned[i]) < objectKey(owned[j])
+		})
+		for _, o := range owned {
+			obj, err := manifestObject(o)
+			if err != nil {
+				return nil, err
+			}
+			manifest = append(manifest, obj)
+		}
+	}
+
+	return manifest, nil
+}
+
+// objectKey sorts objects by namespace and then by name.
+func objectKey(o client.Object) string {
+	return o.GetNamespace() + "/" + o.GetName()
+}
+
+// manifestObject converts an object to an unstructured object with its apiVersion and kind set,
+// which the typed objects read from a client lack.
+func manifestObject(o client.Object) (map[string]interface{}, error) {
+	gvk, err := utils.GetKindFromObj(Scheme, o)
+	if err != nil {
+		return nil, err
+	}
+
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+	if err != nil {
+		return nil, err
+	}
+	obj["apiVersion"], obj["kind"] = gvk.ToAPIVersionAndKind()
+
+	if metadata, ok := obj["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "managedFields")
+	}
+
+	redactObject(gvk, obj)
+	return obj, nil
+}
+
+// parseEnvManifestPath returns the name of the environment from a path of the form
+// /environments/{name}/manifest/.
+func parseEnvManifestPath(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(path, "/environments/"), "/"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] != "manifest" {
+		return "", false
+	}
+	return parts[0], true
+}
+
+// acceptsGzip reports whether the Accept-Encoding header of the request lists gzip, an encoding
+// given a quality of zero is refused.
+func acceptsGzip(r *http.Request) bool {
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, encoding := range strings.Split(header, ",") {
+			params := strings.Split(encoding, ";")
+			if strings.TrimSpace(params[0]) != "gzip" {
+				continue
+			}
+			refused := false
+			for _, param := range params[1:] {
+				param = strings.ReplaceAll(param, " ", "")
+				if strings.HasPrefix(param, "q=") && strings.Trim(strings.TrimPrefix(param, "q="), "0.") == "" {
+					refused = true
+				}
+			}
+			if !refused {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// envManifestHandler returns every object that Clowder manages for a ClowdEnvironment, so that
+// the state of an environment can be attached to a support ticket. The manifest is a multi
+// document YAML stream, or a JSON array when JSON is accepted, and is gzipped when the client
+// accepts it.
+func envManifestHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add(
+		"Content-Type", "application/json",
+	)
+	name, ok := parseEnvManifestPath(r.URL.Path)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown path [%s]", r.URL.Path))
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeJSONError(w, http.StatusMethodNotAllowed, "manifest requests must be a GET")
+		return
+	}
+	pClient := getAPIClient()
+	if pClient == nil {
+		writeJSONError(w, http.StatusServiceUnavailable, "manager not started")
+		return
+	}
+
+	env := &crd.ClowdEnvironment{}
+	if err := pClient.Get(r.Context(), types.NamespacedName{Name: name}, env); err != nil {
+		if k8serr.IsNotFound(err) {
+			writeJSONError(w, http.StatusNotFound, fmt.Sprintf("environment [%s] not found", name))
+		} else {
+			writeJSONError(w, http.StatusInternalServerError, err.Error())
+		}
+		return
+	}
+
+	manifest, err := getEnvManifest(r.Context(), pClient, env)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	var body []byte
+	if strings.Contains(r.Header.Get("Accept"), "application/json") {
+		body, err = json.Marshal(manifest)
+	} else {
+		w.Header().Set("Content-Type", "application/yaml")
+		body, err = manifestYAML(manifest)
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if !acceptsGzip(r) {
+		_, _ = w.Write(body)
+		return
+	}
+
+	w.Header().Set("Content-Encoding", "gzip")
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	_, _ = gz.Write(body)
+}
+
+// manifestYAML writes the objects out as a multi document YAML stream.
+func manifestYAML(manifest []map[string]interface{}) ([]byte, error) {
+	docs := []string{}
+	for _, obj := range manifest {
+		doc, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, "---\n"+string(doc))
+	}
+	return []byte(strings.Join(docs, "")), nil
+}
@@ -1,45 +1,80 @@
 package controllers
 
 import (
+	"context"
+	errlib "errors"
+	"sync"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	cond "sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
-var managedApps = map[string]bool{}
-var managedEnvironments = map[string]bool{}
-var presentApps = map[string]bool{}
-var presentEnvironments = map[string]bool{}
+// nameSet is a set of object names that is safe to be updated by concurrent reconciles.
+type nameSet struct {
+	mu    sync.RWMutex
+	names map[string]bool
+}
 
-func GetManagedApps() []string {
-	var apps []string
-	for app := range managedApps {
-		apps = append(apps, app)
+func newNameSet() *nameSet {
+	return &nameSet{names: map[string]bool{}}
+}
+
+func (s *nameSet) add(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.names[name] = true
+}
+
+func (s *nameSet) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.names, name)
+}
+
+func (s *nameSet) len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.names)
+}
+
+func (s *nameSet) list() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var names []string
+	for name := range s.names {
+		names = append(names, name)
 	}
-	return apps
+	return names
+}
+
+var managedApps = newNameSet()
+var managedEnvironments = newNameSet()
+var presentApps = newNameSet()
+var presentEnvironments = newNameSet()
+
+func GetManagedApps() []string {
+	return managedApps.list()
 }
 
 func GetPresentApps() []string {
-	var apps []string
-	for app := range presentApps {
-		apps = append(apps, app)
-	}
-	return apps
+	return presentApps.list()
 }
 
 func GetManagedEnvs() []string {
-	var apps []string
-	for app := range managedEnvironments {
-		apps = append(apps, app)
-	}
-	return apps
+	return managedEnvironments.list()
 }
 
 func GetPresentEnvs() []string {
-	var apps []string
-	for app := range presentEnvironments {
-		apps = append(apps, app)
-	}
-	return apps
+	return presentEnvironments.list()
 }
 
 var (
@@ -95,6 +130,34 @@ var (
 		},
 		[]string{"app", "env"},
 	)
+	appConditionMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "clowder_clowdapp_condition",
+			Help: "ClowdApp status conditions, 1 if the condition has the status, 0 if not",
+		},
+		[]string{"app", "type", "status"},
+	)
+	envReadyMetrics = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "clowder_environment_ready",
+			Help: "ClowdEnvironment readiness, 1 if all of its apps reconciled successfully, 0 if not",
+		},
+		[]string{"env"},
+	)
+	reconcileErrorMetrics = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "clowder_reconcile_errors_total",
+			Help: "Clowder reconciliation errors by controller and reason",
+		},
+		[]string{"controller", "reason"},
+	)
+	pausedObjectsMetric = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "clowder_paused_objects",
+			Help: "Number of ClowdApps and ClowdEnvironments whose reconciliation is paused",
+		},
+		[]string{"type"},
+	)
 )
 
 func init() {
@@ -108,5 +171,145 @@ func init() {
 		presentAppsMetric,
 		presentEnvsMetric,
 		reconciliationMetrics,
+		appConditionMetrics,
+		envReadyMetrics,
+		reconcileErrorMetrics,
+		pausedObjectsMetric,
 	)
 }
+
+// setAppConditionMetrics exports the status conditions of the app, each condition has a series for
+// both the True and False statuses so that alerts can match on either.
+func setAppConditionMetrics(app *crd.ClowdApp) {
+	for _, condition := range app.Status.Conditions {
+		for _, status := range []core.ConditionStatus{core.ConditionTrue, core.ConditionFalse} {
+			value := 0.0
+			if condition.Status == status {
+				value = 1.0
+			}
+			appConditionMetrics.With(prometheus.Labels{
+				"app":    app.GetIdent(),
+				"type":   string(condition.Type),
+				"status": string(status),
+			}).Set(value)
+		}
+	}
+}
+
+// deleteAppConditionMetrics removes the condition series of a deleted app.
+func deleteAppConditionMetrics(ident string) {
+	appConditionMetrics.DeletePartialMatch(prometheus.Labels{"app": ident})
+}
+
+// deleteAppConditionMetric removes the series of a condition that was dropped from the status of
+// an app.
+func deleteAppConditionMetric(ident string, conditionType clusterv1.ConditionType) {
+	appConditionMetrics.DeletePartialMatch(prometheus.Labels{"app": ident, "type": string(conditionType)})
+}
+
+// setEnvReadyMetric sets the readiness of the environment from the ReconciliationSuccessful
+// condition of its apps. The listed apps may lag behind the status just written by an app
+// reconcile, so the app being reconciled, if any, is taken as given.
+func setEnvReadyMetric(ctx context.Context, c client.Client, env *crd.ClowdEnvironment, current *crd.ClowdApp) error {
+	appList, err := env.GetAppsInEnv(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	apps := []crd.ClowdApp{}
+	for _, app := range appList.Items {
+		if current != nil && app.Name == current.Name && app.Namespace == current.Namespace {
+			continue
+		}
+		apps = append(apps, app)
+	}
+	if current != nil {
+		apps = append(apps, *current)
+	}
+
+	ready := 1.0
+	for i := range apps {
+		if apps[i].GetDeletionTimestamp() != nil {
+			continue
+		}
+		if !cond.IsTrue(&apps[i], crd.ReconciliationSuccessful) {
+			ready = 0.0
+			break
+		}
+	}
+
+	envReadyMetrics.With(prometheus.Labels{"env": env.Name}).Set(ready)
+	return nil
+}
+
+// setAppEnvReadyMetric updates the readiness of the environment of the app after it was
+// reconciled, the series of an environment that is gone or being deleted is left alone.
+func setAppEnvReadyMetric(ctx context.Context, c client.Client, app *crd.ClowdApp) error {
+	if app.Name == "" || app.Spec.EnvName == "" {
+		return nil
+	}
+
+	env := &crd.ClowdEnvironment{}
+	if err := c.Get(ctx, types.NamespacedName{Name: app.Spec.EnvName}, env); err != nil {
+		if k8serr.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if env.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	return setEnvReadyMetric(ctx, c, env, app)
+}
+
+// deleteEnvReadyMetric removes the readiness series of a deleted environment.
+func deleteEnvReadyMetric(name string) {
+	envReadyMetrics.Delete(prometheus.Labels{"env": name})
+}
+
+// reconcileErrorReason returns the reason an error is counted under, the type of the Clowder
+// errors reported in the status conditions or the reason of API errors, so that the number of
+// series stays bounded.
+func reconcileErrorReason(err error) string {
+	var missingSecrets *errors.MissingSecrets
+	var missingConfigMaps *errors.MissingConfigMaps
+	var missingDeps *errors.MissingDependencies
+	var invalidTriggers *errors.InvalidAutoScalerTriggers
+	var partitionDecrease *errors.TopicPartitionDecrease
+	var invalidSingleton *errors.InvalidSingleton
+	var invalidProbePort *errors.InvalidProbePort
+	var invalidTLSConfig *errors.InvalidTLSConfig
+	var invalidReplicas *errors.InvalidReplicas
+
+	switch {
+	case errlib.As(err, &missingSecrets):
+		return "MissingSecrets"
+	case errlib.As(err, &missingConfigMaps):
+		return "MissingConfigMaps"
+	case errlib.As(err, &missingDeps):
+		return "MissingDependencies"
+	case errlib.As(err, &invalidTriggers):
+		return "InvalidAutoScalerTriggers"
+	case errlib.As(err, &partitionDecrease):
+		return "TopicPartitionDecrease"
+	case errlib.As(err, &invalidSingleton):
+		return "InvalidSingleton"
+	case errlib.As(err, &invalidProbePort):
+		return "InvalidProbePort"
+	case errlib.As(err, &invalidTLSConfig):
+		return "InvalidTLSConfig"
+	case errlib.As(err, &invalidReplicas):
+		return "InvalidReplicas"
+	}
+
+	if reason := k8serr.ReasonForError(err); reason != metav1.StatusReasonUnknown {
+		return string(reason)
+	}
+	return "Unknown"
+}
+
+// countReconcileError counts an error that failed a reconcile of the controller.
+func countReconcileError(controller string, err error) {
+	reconcileErrorMetrics.With(prometheus.Labels{"controller": controller, "reason": reconcileErrorReason(err)}).Inc()
+}
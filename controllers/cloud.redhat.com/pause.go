@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	cond "sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PausedAnnotation freezes the reconciliation of a ClowdApp or ClowdEnvironment when set to
+// "true", e.g. to hand-edit a managed Deployment during an incident without Clowder reverting it.
+const PausedAnnotation = "clowder.redhat.com/paused"
+
+// PausedReason is the reason of the Paused condition of an object paused by the annotation.
+const PausedReason = "PausedByAnnotation"
+
+// pausedRequeueDelay is how often a paused object is checked on, removing the annotation also
+// triggers a reconcile straight away.
+const pausedRequeueDelay = 5 * time.Minute
+
+var pausedApps = newNameSet()
+var pausedEnvironments = newNameSet()
+
+// isPaused returns true if the object carries the pause annotation.
+func isPaused(o metav1.Object) bool {
+	return o.GetAnnotations()[PausedAnnotation] == "true"
+}
+
+// setPausedMetrics exports the number of paused apps and environments.
+func setPausedMetrics() {
+	pausedObjectsMetric.With(prometheus.Labels{"type": "app"}).Set(float64(pausedApps.len()))
+	pausedObjectsMetric.With(prometheus.Labels{"type": "env"}).Set(float64(pausedEnvironments.len()))
+}
+
+// setPausedCondition records whether the object is paused in its status. The Paused condition is
+// only present on objects that are paused, it is removed again once they are unpaused. The status
+// is only written if the condition changed.
+func setPausedCondition(ctx context.Context, c client.Client, o interface {
+	client.Object
+	cond.Setter
+}, paused bool) error {
+	if paused == cond.IsTrue(o, crd.Paused) {
+		return nil
+	}
+
+	if paused {
+		cond.Set(o, &clusterv1.Condition{
+			Type:    crd.Paused,
+			Status:  core.ConditionTrue,
+			Reason:  PausedReason,
+			Message: "Reconciliation is paused by the " + PausedAnnotation + " annotation",
+		})
+	} else {
+		cond.Delete(o, crd.Paused)
+	}
+
+	return c.Status().Update(ctx, o)
+}
@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/hashcache"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/go-logr/logr"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// redactedValue replaces every value of the Secrets returned by a plan, and the credentials set
+// in the env of its pod templates.
+const redactedValue = "REDACTED"
+
+// PlanRequest is the body of a request to the plan endpoint of the internal API. The environment
+// is read from the cluster when it is not given.
+type PlanRequest struct {
+	App crd.ClowdApp          `json:"app"`
+	Env *crd.ClowdEnvironment `json:"env,omitempty"`
+}
+
+// PlannedObject is an object that the reconciliation of an app would write, the action is one of
+// create, update or delete.
+type PlannedObject struct {
+	Action string                 `json:"action"`
+	Object map[string]interface{} `json:"object"`
+}
+
+type planKey struct {
+	gvk schema.GroupVersionKind
+	nn  types.NamespacedName
+}
+
+type plannedWrite struct {
+	action string
+	obj    client.Object
+}
+
+// planClient wraps the client handed to the providers when planning an app. Every write is
+// recorded rather than sent to the cluster, reads are passed through unless they are for an
+// object that was written during the plan, in which case the recorded object is returned.
+type planClient struct {
+	client.Client
+	mu     sync.Mutex
+	order  []planKey
+	writes map[planKey]*plannedWrite
+}
+
+func newPlanClient(cl client.Client) *planClient {
+	return &planClient{Client: cl, writes: map[planKey]*plannedWrite{}}
+}
+
+func (c *planClient) key(obj client.Object) (planKey, error) {
+	gvk, err := utils.GetKindFromObj(Scheme, obj)
+	if err != nil {
+		return planKey{}, err
+	}
+	return planKey{gvk: gvk, nn: types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}}, nil
+}
+
+func (c *planClient) record(obj client.Object, action string) error {
+	key, err := c.key(obj)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	write, ok := c.writes[key]
+	if !ok {
+		c.order = append(c.order, key)
+		c.writes[key] = &plannedWrite{action: action, obj: obj.DeepCopyObject().(client.Object)}
+		return nil
+	}
+
+	// an object created during the plan is still a create when it is later updated
+	if write.action != "create" || action != "update" {
+		write.action = action
+	}
+	write.obj = obj.DeepCopyObject().(client.Object)
+	return nil
+}
+
+func (c *planClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	if pKey, err := c.key(obj); err == nil {
+		pKey.nn = key
+
+		c.mu.Lock()
+		write, ok := c.writes[pKey]
+		c.mu.Unlock()
+
+		if ok {
+			if write.action == "delete" {
+				return k8serr.NewNotFound(schema.GroupResource{Group: pKey.gvk.Group, Resource: pKey.gvk.Kind}, key.Name)
+			}
+			if reflect.TypeOf(write.obj) == reflect.TypeOf(obj) {
+				reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(write.obj.DeepCopyObject()).Elem())
+				return nil
+			}
+		}
+	}
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c *planClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return c.record(obj, "create")
+}
+
+func (c *planClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return c.record(obj, "update")
+}
+
+func (c *planClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.record(obj, "update")
+}
+
+func (c *planClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return c.record(obj, "delete")
+}
+
+func (c *planClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return nil
+}
+
+// Status returns a writer that drops status writes, the status is not part of a plan.
+func (c *planClient) Status() client.StatusWriter {
+	return planStatusWriter{}
+}
+
+type planStatusWriter struct{}
+
+func (planStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return nil
+}
+
+func (planStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return nil
+}
+
+// Objects returns the recorded writes in the order they were first made, the values of Secrets
+// and the credentials set as literal env vars of pod templates are redacted.
+func (c *planClient) Objects() ([]PlannedObject, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	objects := []PlannedObject{}
+	for _, key := range c.order {
+		write := c.writes[key]
+
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(write.obj)
+		if err != nil {
+			return nil, err
+		}
+		obj["apiVersion"], obj["kind"] = key.gvk.ToAPIVersionAndKind()
+
+		redactObject(key.gvk, obj)
+
+		objects = append(objects, PlannedObject{Action: write.action, Object: obj})
+	}
+	return objects, nil
+}
+
+// redactObject redacts the values of an unstructured Secret and the credentials set as literal
+// env vars in the pod templates of any other object, before it is returned by the API.
+func redactObject(gvk schema.GroupVersionKind, obj map[string]interface{}) {
+	if gvk.Group == "" && gvk.Kind == "Secret" {
+		redactSecret(obj)
+		return
+	}
+	redactContainerEnv(obj)
+}
+
+// redactSecret replaces the value of every key of an unstructured Secret.
+func redactSecret(obj map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		values, ok := obj[field].(map[string]interface{})
+		if !ok {
+			delete(obj, field)
+			continue
+		}
+		for k := range values {
+			values[k] = redactedValue
+		}
+	}
+}
+
+// credentialEnvNames are the substrings of the names of env vars whose literal values are taken
+// to be credentials, such as the POSTGRESQL_PASSWORD of the local database deployments.
+var credentialEnvNames = []string{"PASS", "SECRET", "TOKEN", "KEY", "CREDENTIAL"}
+
+// redactContainerEnv replaces the literal value of every env var whose name looks like a
+// credential, in the containers of any pod template found in an unstructured object. Env vars
+// read from a Secret have no literal value and are left as they are.
+func redactContainerEnv(obj interface{}) {
+	switch value := obj.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if containers, ok := v.([]interface{}); ok && (k == "containers" || k == "initContainers") {
+				for _, container := range containers {
+					if container, ok := container.(map[string]interface{}); ok {
+						redactEnvValues(container["env"])
+					}
+				}
+				continue
+			}
+			redactContainerEnv(v)
+		}
+	case []interface{}:
+		for _, v := range value {
+			redactContainerEnv(v)
+		}
+	}
+}
+
+func redactEnvValues(env interface{}) {
+	envVars, ok := env.([]interface{})
+	if !ok {
+		return
+	}
+	for _, envVar := range envVars {
+		envVar, ok := envVar.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := envVar["value"]; !ok {
+			continue
+		}
+		name, _ := envVar["name"].(string)
+		for _, credential := range credentialEnvNames {
+			if strings.Contains(strings.ToUpper(name), credential) {
+				envVar["value"] = redactedValue
+				break
+			}
+		}
+	}
+}
+
+// planApp runs the providers for the app with a client that records the writes rather than
+// sending them to the cluster, and returns the objects that the reconciliation of the app would
+// write. The resources of the environment are expected to exist already.
+func planApp(ctx context.Context, pClient client.Client, log logr.Logger, app *crd.ClowdApp, env *crd.ClowdEnvironment) ([]PlannedObject, error) {
+	planner, _, err := dryRunApp(ctx, pClient, log, app, env)
+	if err != nil {
+		return nil, err
+	}
+	return planner.Objects()
+}
+
+// renderAppConfig returns the cdappconfig.json that the reconciliation of the app would write,
+// it is rendered by the same dry run of the providers as a plan.
+func renderAppConfig(ctx context.Context, pClient client.Client, log logr.Logger, app *crd.ClowdApp, env *crd.ClowdEnvironment) (*config.AppConfig, error) {
+	_, appConfig, err := dryRunApp(ctx, pClient, log, app, env)
+	return appConfig, err
+}
+
+// dryRunApp runs the providers for the app against a planClient and returns the client, holding
+// the recorded writes, along with the app config built by the providers.
+func dryRunApp(ctx context.Context, pClient client.Client, log logr.Logger, app *crd.ClowdApp, env *crd.ClowdEnvironment) (*planClient, *config.AppConfig, error) {
+	planner := newPlanClient(pClient)
+
+	cacheConfig := rc.NewCacheConfig(Scheme, nil, ProtectedGVKs, rc.Options{StrictGVK: true})
+	cache := rc.NewObjectCache(ctx, planner, &log, cacheConfig)
+	hashCache := hashcache.NewHashCache()
+
+	provider := providers.Provider{
+		Client:    planner,
+		Ctx:       ctx,
+		Env:       env,
+		Cache:     &cache,
+		Log:       log,
+		Config:    &config.AppConfig{},
+		HashCache: &hashCache,
+		DryRun:    true,
+	}
+
+	updateMetadata(app, provider.Config)
+
+	for _, provAcc := range providers.ProvidersRegistration.Registry {
+		prov, err := provAcc.SetupProvider(&provider)
+		if err != nil {
+			return nil, nil, errors.NewProviderError(provAcc.Name, errors.Wrap("getprov", err))
+		}
+		if err := prov.Provide(app); err != nil {
+			return nil, nil, errors.NewProviderError(provAcc.Name, err)
+		}
+	}
+
+	if err := cache.ApplyAll(); err != nil {
+		return nil, nil, err
+	}
+
+	return planner, provider.Config, nil
+}
+
+// secretConfigFields are the keys of the app config whose values are credentials.
+var secretConfigFields = map[string]bool{
+	"password":          true,
+	"adminPassword":     true,
+	"accessKey":         true,
+	"secretKey":         true,
+	"accessKeyId":       true,
+	"secretAccessKey":   true,
+	"clientAccessToken": true,
+	"token":             true,
+}
+
+// redactAppConfig returns the app config as unstructured JSON with the values of the credentials
+// replaced.
+func redactAppConfig(appConfig *config.AppConfig) (interface{}, error) {
+	jsonData, err := json.Marshal(appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(jsonData, &doc); err != nil {
+		return nil, err
+	}
+
+	redactConfigValues(doc)
+	return doc, nil
+}
+
+func redactConfigValues(doc interface{}) {
+	switch value := doc.(type) {
+	case map[string]interface{}:
+		for k, v := range value {
+			if secretConfigFields[k] && v != nil && v != "" {
+				value[k] = redactedValue
+				continue
+			}
+			redactConfigValues(v)
+		}
+	case []interface{}:
+		for _, v := range value {
+			redactConfigValues(v)
+		}
+	}
+}
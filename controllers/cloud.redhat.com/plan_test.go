@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPlanClientRecordsWrites(t *testing.T) {
+	ctx := context.Background()
+	cl := fake.NewClientBuilder().WithScheme(Scheme).Build()
+	planner := newPlanClient(cl)
+
+	nn := types.NamespacedName{Name: "puptoo", Namespace: "test"}
+	dep := &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+	assert.NoError(t, planner.Create(ctx, dep))
+
+	dep.Labels = map[string]string{"app": "puptoo"}
+	assert.NoError(t, planner.Update(ctx, dep))
+
+	// the recorded object is read back, but nothing was sent to the cluster
+	fetched := &apps.Deployment{}
+	assert.NoError(t, planner.Get(ctx, nn, fetched))
+	assert.Equal(t, "puptoo", fetched.Labels["app"])
+	assert.True(t, k8serr.IsNotFound(cl.Get(ctx, nn, &apps.Deployment{})))
+
+	objects, err := planner.Objects()
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 1) {
+		assert.Equal(t, "create", objects[0].Action)
+		assert.Equal(t, "Deployment", objects[0].Object["kind"])
+		assert.Equal(t, "apps/v1", objects[0].Object["apiVersion"])
+	}
+}
+
+func TestPlanClientRedactsSecrets(t *testing.T) {
+	ctx := context.Background()
+	planner := newPlanClient(fake.NewClientBuilder().WithScheme(Scheme).Build())
+
+	assert.NoError(t, planner.Create(ctx, &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test"},
+		Data: map[string][]byte{
+			"cdappconfig.json": []byte(`{"kafka": {"password": "hunter2"}}`),
+		},
+		StringData: map[string]string{
+			"token": "abc123",
+		},
+	}))
+
+	objects, err := planner.Objects()
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 1) {
+		assert.Equal(t, map[string]interface{}{"cdappconfig.json": redactedValue}, objects[0].Object["data"])
+		assert.Equal(t, map[string]interface{}{"token": redactedValue}, objects[0].Object["stringData"])
+	}
+}
+
+func TestPlanClientRedactsContainerEnv(t *testing.T) {
+	ctx := context.Background()
+	planner := newPlanClient(fake.NewClientBuilder().WithScheme(Scheme).Build())
+
+	dep := &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "puptoo-db", Namespace: "test"}}
+	dep.Spec.Template.Spec.InitContainers = []core.Container{{
+		Name: "init",
+		Env:  []core.EnvVar{{Name: "API_TOKEN", Value: "abc123"}},
+	}}
+	dep.Spec.Template.Spec.Containers = []core.Container{{
+		Name: "puptoo-db",
+		Env: []core.EnvVar{
+			{Name: "POSTGRESQL_USER", Value: "user"},
+			{Name: "POSTGRESQL_PASSWORD", Value: "hunter2"},
+			{Name: "PGPASSWORD", Value: "hunter3"},
+			{Name: "AWS_SECRET_ACCESS_KEY", ValueFrom: &core.EnvVarSource{
+				SecretKeyRef: &core.SecretKeySelector{Key: "secret"},
+			}},
+		},
+	}}
+	assert.NoError(t, planner.Create(ctx, dep))
+
+	objects, err := planner.Objects()
+	assert.NoError(t, err)
+	if !assert.Len(t, objects, 1) {
+		return
+	}
+
+	planned := &apps.Deployment{}
+	assert.NoError(t, runtime.DefaultUnstructuredConverter.FromUnstructured(objects[0].Object, planned))
+	assert.Equal(t, redactedValue, planned.Spec.Template.Spec.InitContainers[0].Env[0].Value)
+
+	env := planned.Spec.Template.Spec.Containers[0].Env
+	assert.Equal(t, "user", env[0].Value)
+	assert.Equal(t, redactedValue, env[1].Value)
+	assert.Equal(t, redactedValue, env[2].Value)
+	assert.Empty(t, env[3].Value)
+	assert.Equal(t, "secret", env[3].ValueFrom.SecretKeyRef.Key)
+}
+
+func TestRedactAppConfig(t *testing.T) {
+	appConfig := &config.AppConfig{
+		Database: &config.DatabaseConfig{
+			Hostname:      "db.test.svc",
+			Username:      "user",
+			Password:      "hunter2",
+			AdminUsername: "postgres",
+			AdminPassword: "hunter3",
+		},
+		ObjectStore: &config.ObjectStoreConfig{
+			Hostname:  "minio.test.svc",
+			AccessKey: utils.StringPtr("access"),
+			SecretKey: utils.StringPtr("secret"),
+			Buckets: []config.ObjectStoreBucket{{
+				Name:      "bucket",
+				AccessKey: utils.StringPtr("bucket-access"),
+			}},
+		},
+	}
+
+	doc, err := redactAppConfig(appConfig)
+	assert.NoError(t, err)
+
+	redacted := doc.(map[string]interface{})
+	database := redacted["database"].(map[string]interface{})
+	assert.Equal(t, "db.test.svc", database["hostname"])
+	assert.Equal(t, "user", database["username"])
+	assert.Equal(t, redactedValue, database["password"])
+	assert.Equal(t, redactedValue, database["adminPassword"])
+
+	objectStore := redacted["objectStore"].(map[string]interface{})
+	assert.Equal(t, redactedValue, objectStore["accessKey"])
+	assert.Equal(t, redactedValue, objectStore["secretKey"])
+	bucket := objectStore["buckets"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "bucket", bucket["name"])
+	assert.Equal(t, redactedValue, bucket["accessKey"])
+
+	// the config itself is left untouched
+	assert.Equal(t, "hunter2", appConfig.Database.Password)
+}
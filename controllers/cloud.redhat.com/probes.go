@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// cacheSyncTimeout bounds how long the readiness check waits for the informer caches.
+const cacheSyncTimeout = time.Second
+
+// probeCheck is a named check of the operator's liveness or readiness.
+type probeCheck struct {
+	name  string
+	check healthz.Checker
+}
+
+// probeCheckResult is the result of a single check in the body of the probe endpoints.
+type probeCheckResult struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// probeReport is the body of the probe endpoints.
+type probeReport struct {
+	Status string             `json:"status"`
+	Checks []probeCheckResult `json:"checks"`
+}
+
+func livenessChecks() []probeCheck {
+	return []probeCheck{{name: "ping", check: healthz.Ping}}
+}
+
+// readinessChecks verify that the Clowder CRDs are installed and the informer caches have synced.
+// The Strimzi and KEDA CRDs are only required while an environment uses a provider mode that
+// needs them.
+func readinessChecks(mgr manager.Manager) []probeCheck {
+	scheme := mgr.GetScheme()
+	mapper := mgr.GetRESTMapper()
+	reader := mgr.GetAPIReader()
+
+	return []probeCheck{
+		{name: "ping", check: healthz.Ping},
+		{name: "crds", check: crdsInstalledCheck(scheme, mapper, &crd.ClowdApp{}, &crd.ClowdEnvironment{}, &crd.ClowdJobInvocation{})},
+		{name: "cache", check: cacheSyncedCheck(mgr.GetCache())},
+		{name: "strimzi", check: providerCRDsCheck(scheme, mapper, reader, envNeedsStrimzi, &strimzi.Kafka{}, &strimzi.KafkaTopic{})},
+		{name: "keda", check: providerCRDsCheck(scheme, mapper, reader, envNeedsKeda, &keda.ScaledObject{})},
+	}
+}
+
+// addProbes registers the checks with the probe endpoints of the manager, and serves them with a
+// JSON body listing the result of each check on the /healthz and /readyz paths of the metrics
+// server.
+func addProbes(mgr manager.Manager) error {
+	liveness, readiness := livenessChecks(), readinessChecks(mgr)
+
+	for _, c := range liveness {
+		if err := mgr.AddHealthzCheck(c.name, c.check); err != nil {
+			return err
+		}
+	}
+	for _, c := range readiness {
+		if err := mgr.AddReadyzCheck(c.name, c.check); err != nil {
+			return err
+		}
+	}
+
+	if err := mgr.AddMetricsExtraHandler("/healthz", probeHandler(liveness)); err != nil {
+		return err
+	}
+	return mgr.AddMetricsExtraHandler("/readyz", probeHandler(readiness))
+}
+
+// probeHandler runs all of the checks and reports their results, the status code is 503 if any of
+// them failed.
+func probeHandler(checks []probeCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := probeReport{Status: "ok", Checks: []probeCheckResult{}}
+		for _, c := range checks {
+			result := probeCheckResult{Name: c.name, OK: true}
+			if err := c.check(r); err != nil {
+				result.OK = false
+				result.Error = err.Error()
+				report.Status = "failed"
+			}
+			report.Checks = append(report.Checks, result)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if report.Status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
+
+// kindsInstalled returns an error naming the first kind of the objects that is not served by the
+// cluster.
+func kindsInstalled(scheme *runtime.Scheme, mapper meta.RESTMapper, objs ...client.Object) error {
+	for _, obj := range objs {
+		gvk, err := utils.GetKindFromObj(scheme, obj)
+		if err != nil {
+			return err
+		}
+		if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+			if meta.IsNoMatchError(err) {
+				return fmt.Errorf("the %s CRD is not installed", gvk.GroupKind())
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func crdsInstalledCheck(scheme *runtime.Scheme, mapper meta.RESTMapper, objs ...client.Object) healthz.Checker {
+	return func(_ *http.Request) error {
+		return kindsInstalled(scheme, mapper, objs...)
+	}
+}
+
+func cacheSyncedCheck(c cache.Cache) healthz.Checker {
+	return func(r *http.Request) error {
+		ctx, cancel := context.WithTimeout(r.Context(), cacheSyncTimeout)
+		defer cancel()
+		if !c.WaitForCacheSync(ctx) {
+			return fmt.Errorf("the informer caches have not synced")
+		}
+		return nil
+	}
+}
+
+// providerCRDsCheck requires the CRDs of an optional provider only while any environment needs
+// them, a cluster without Strimzi is ready as long as no environment runs kafka on it.
+func providerCRDsCheck(scheme *runtime.Scheme, mapper meta.RESTMapper, reader client.Reader, needs func(env *crd.ClowdEnvironment) bool, objs ...client.Object) healthz.Checker {
+	return func(r *http.Request) error {
+		envs := crd.ClowdEnvironmentList{}
+		if err := reader.List(r.Context(), &envs); err != nil {
+			return err
+		}
+		for i := range envs.Items {
+			if needs(&envs.Items[i]) {
+				if err := kindsInstalled(scheme, mapper, objs...); err != nil {
+					return fmt.Errorf("environment %s: %w", envs.Items[i].Name, err)
+				}
+				return nil
+			}
+		}
+		return nil
+	}
+}
+
+// envNeedsStrimzi reports whether the kafka mode of the environment reads or creates Strimzi
+// resources.
+func envNeedsStrimzi(env *crd.ClowdEnvironment) bool {
+	switch env.Spec.Providers.Kafka.Mode {
+	case "operator", "app-interface", "managed-ephem":
+		return true
+	}
+	return false
+}
+
+// envNeedsKeda reports whether the environment creates KEDA ScaledObjects for autoscaled
+// deployments.
+func envNeedsKeda(env *crd.ClowdEnvironment) bool {
+	switch env.Spec.Providers.AutoScaler.Mode {
+	case "enabled", "keda":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+func TestProbeHandler(t *testing.T) {
+	failing := func(_ *http.Request) error { return fmt.Errorf("the informer caches have not synced") }
+
+	w := httptest.NewRecorder()
+	probeHandler([]probeCheck{{name: "ping", check: healthz.Ping}})(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	report := probeReport{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, probeReport{Status: "ok", Checks: []probeCheckResult{{Name: "ping", OK: true}}}, report)
+
+	w = httptest.NewRecorder()
+	probeHandler([]probeCheck{{name: "ping", check: healthz.Ping}, {name: "cache", check: failing}})(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	report = probeReport{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, probeReport{Status: "failed", Checks: []probeCheckResult{
+		{Name: "ping", OK: true},
+		{Name: "cache", OK: false, Error: "the informer caches have not synced"},
+	}}, report)
+}
+
+func TestProviderCRDsCheck(t *testing.T) {
+	// a cluster without the Strimzi CRDs
+	mapper := meta.NewDefaultRESTMapper(nil)
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+	env := &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env"}}
+	env.Spec.Providers.Kafka.Mode = "none"
+	cl := fake.NewClientBuilder().WithScheme(Scheme).WithObjects(env).Build()
+
+	check := providerCRDsCheck(Scheme, mapper, cl, envNeedsStrimzi, &strimzi.Kafka{})
+	assert.NoError(t, check(req), "strimzi is required although no environment needs it")
+
+	env.Spec.Providers.Kafka.Mode = "operator"
+	assert.NoError(t, cl.Update(req.Context(), env))
+	assert.EqualError(t, check(req), "environment env: the Kafka.kafka.strimzi.io CRD is not installed")
+
+	gvk := strimzi.GroupVersion.WithKind("Kafka")
+	mapper.Add(gvk, meta.RESTScopeNamespace)
+	assert.NoError(t, check(req))
+}
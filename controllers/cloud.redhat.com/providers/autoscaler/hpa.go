@@ -0,0 +1,167 @@
+package autoscaler
+
+import (
+	"fmt"
+	"strconv"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	apps "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/autoscaling/v2"
+	core "k8s.io/api/core/v1"
+	res "k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// hpaTriggerResources maps the trigger types that have a native HPA equivalent onto the
+// resource they scale on.
+var hpaTriggerResources = map[string]core.ResourceName{
+	"cpu":    core.ResourceCPU,
+	"memory": core.ResourceMemory,
+}
+
+// validateHPATriggers checks the deployment's autoscaler triggers can be rendered as a native
+// HPA, returning an InvalidAutoScalerTriggers error describing every problem found.
+// validateHPAReplicas rejects a minReplicaCount of 0, which only keda can scale up from. The
+// apiserver refuses an HPA with no minimum replicas unless the HPAScaleToZero feature gate is on.
+func validateHPAReplicas(deployment *crd.Deployment) error {
+	if minReplicas := deployment.AutoScaler.MinReplicaCount; minReplicas != nil && *minReplicas == 0 {
+		return &errors.InvalidReplicas{
+			Deployment: deployment.Name,
+			Problems:   []string{"minReplicaCount 0 requires the autoscaler to be in keda mode"},
+		}
+	}
+	return nil
+}
+
+func validateHPATriggers(deployment *crd.Deployment) error {
+	problems := []string{}
+
+	for i, trigger := range deployment.AutoScaler.Triggers {
+		if _, ok := hpaTriggerResources[trigger.Type]; !ok {
+			prefix := fmt.Sprintf("deployment [%s] trigger [%d] (%s)", deployment.Name, i, trigger.Type)
+			problems = append(problems, fmt.Sprintf("%s: has no HPA equivalent and requires the autoscaler to be in keda mode", prefix))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &errors.InvalidAutoScalerTriggers{Problems: problems}
+	}
+
+	if err := validateTriggers(deployment); err != nil {
+		return err
+	}
+
+	for i, trigger := range deployment.AutoScaler.Triggers {
+		if _, err := makeHPAMetricSpec(trigger.Type, trigger.Metadata); err != nil {
+			prefix := fmt.Sprintf("deployment [%s] trigger [%d] (%s)", deployment.Name, i, trigger.Type)
+			problems = append(problems, fmt.Sprintf("%s: %s", prefix, err.Error()))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &errors.InvalidAutoScalerTriggers{Problems: problems}
+	}
+	return nil
+}
+
+// ProvideHPAAutoScaler creates a native HPA in the resource cache from the deployment's autoscaler
+// config, for clusters that do not run keda.
+func ProvideHPAAutoScaler(app *crd.ClowdApp, asp *providers.Provider, deployment crd.Deployment) error {
+	if err := validateHPAReplicas(&deployment); err != nil {
+		return err
+	}
+	if err := validateHPATriggers(&deployment); err != nil {
+		return err
+	}
+
+	hpa := &v2.HorizontalPodAutoscaler{}
+	nn := app.GetDeploymentNamespacedName(&deployment)
+	if err := asp.Cache.Create(HPAAutoScaler, nn, hpa); err != nil {
+		return err
+	}
+
+	d := &apps.Deployment{}
+	if err := asp.Cache.Get(deployProvider.CoreDeployment, d, nn); err != nil {
+		return err
+	}
+
+	initHPA(app, d, hpa, nn, &deployment)
+
+	return asp.Cache.Update(HPAAutoScaler, hpa)
+}
+
+// initHPA renders the HPA, the triggers are expected to have been validated already.
+func initHPA(app *crd.ClowdApp, d *apps.Deployment, hpa *v2.HorizontalPodAutoscaler, nn types.NamespacedName, deployment *crd.Deployment) {
+	labels := app.GetLabels()
+	labels["pod"] = nn.Name
+	app.SetObjectMeta(hpa, crd.Name(nn.Name), crd.Labels(labels))
+
+	hpaSpec := v2.HorizontalPodAutoscalerSpec{
+		ScaleTargetRef: v2.CrossVersionObjectReference{
+			APIVersion: DeploymentAPIVersion,
+			Kind:       DeploymentKind,
+			Name:       d.Name,
+		},
+		Behavior: deployment.AutoScaler.Behavior,
+	}
+
+	if deployment.AutoScaler.MinReplicaCount == nil {
+		hpaSpec.MinReplicas = new(int32)
+		*hpaSpec.MinReplicas = 1
+	} else {
+		hpaSpec.MinReplicas = deployment.AutoScaler.MinReplicaCount
+	}
+	if deployment.AutoScaler.MaxReplicaCount == nil {
+		hpaSpec.MaxReplicas = 10
+	} else {
+		hpaSpec.MaxReplicas = *deployment.AutoScaler.MaxReplicaCount
+	}
+
+	metrics := []v2.MetricSpec{}
+	for _, trigger := range deployment.AutoScaler.Triggers {
+		if ms, err := makeHPAMetricSpec(trigger.Type, trigger.Metadata); err == nil {
+			metrics = append(metrics, ms)
+		}
+	}
+	hpaSpec.Metrics = metrics
+
+	hpa.Spec = hpaSpec
+}
+
+// makeHPAMetricSpec converts a cpu or memory trigger into the equivalent resource metric, the
+// target type defaults to Utilization as it does in keda.
+func makeHPAMetricSpec(triggerType string, metadata map[string]string) (v2.MetricSpec, error) {
+	ms := v2.MetricSpec{
+		Type: v2.ResourceMetricSourceType,
+		Resource: &v2.ResourceMetricSource{
+			Name: hpaTriggerResources[triggerType],
+		},
+	}
+
+	value := metadata["value"]
+
+	switch metadata["type"] {
+	case string(v2.AverageValueMetricType):
+		quantity, err := res.ParseQuantity(value)
+		if err != nil {
+			return ms, fmt.Errorf("metadata key [value] is not a valid quantity")
+		}
+		ms.Resource.Target.Type = v2.AverageValueMetricType
+		ms.Resource.Target.AverageValue = &quantity
+	case string(v2.UtilizationMetricType), "":
+		utilization, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return ms, fmt.Errorf("metadata key [value] must be a whole percentage")
+		}
+		target := int32(utilization)
+		ms.Resource.Target.Type = v2.UtilizationMetricType
+		ms.Resource.Target.AverageUtilization = &target
+	default:
+		return ms, fmt.Errorf("metadata key [type] must be Utilization or AverageValue")
+	}
+
+	return ms, nil
+}
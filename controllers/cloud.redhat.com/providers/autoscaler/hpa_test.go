@@ -0,0 +1,135 @@
+package autoscaler
+
+import (
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/autoscaling/v2"
+	core "k8s.io/api/core/v1"
+	res "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func hpaTestHPA(app *crd.ClowdApp, deployment *crd.Deployment) *v2.HorizontalPodAutoscaler {
+	nn := types.NamespacedName{Name: "app-processor", Namespace: "default"}
+	d := &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+	hpa := &v2.HorizontalPodAutoscaler{}
+	initHPA(app, d, hpa, nn, deployment)
+	return hpa
+}
+
+func TestHPA(t *testing.T) {
+	minReplicas := int32(2)
+	maxReplicas := int32(6)
+	window := int32(600)
+	behavior := &v2.HorizontalPodAutoscalerBehavior{
+		ScaleDown: &v2.HPAScalingRules{StabilizationWindowSeconds: &window},
+	}
+	app, deployment := behaviorTestApp(&crd.AutoScaler{
+		MinReplicaCount: &minReplicas,
+		MaxReplicaCount: &maxReplicas,
+		Behavior:        behavior,
+		Triggers: []keda.ScaleTriggers{
+			{Type: "cpu", Metadata: map[string]string{"value": "50"}},
+			{Type: "memory", Metadata: map[string]string{"type": "AverageValue", "value": "512Mi"}},
+		},
+	})
+	app.UID = "uid"
+
+	assert.NoError(t, validateHPATriggers(deployment))
+
+	hpa := hpaTestHPA(app, deployment)
+
+	assert.Equal(t, "app-processor", hpa.Name)
+	assert.Equal(t, []metav1.OwnerReference{app.MakeOwnerReference()}, hpa.OwnerReferences)
+	assert.Equal(t, "app-processor", hpa.Spec.ScaleTargetRef.Name)
+	assert.Equal(t, int32(2), *hpa.Spec.MinReplicas)
+	assert.Equal(t, int32(6), hpa.Spec.MaxReplicas)
+	assert.Equal(t, behavior, hpa.Spec.Behavior)
+
+	utilization := int32(50)
+	memory := res.MustParse("512Mi")
+	assert.Equal(t, []v2.MetricSpec{{
+		Type: v2.ResourceMetricSourceType,
+		Resource: &v2.ResourceMetricSource{
+			Name:   core.ResourceCPU,
+			Target: v2.MetricTarget{Type: v2.UtilizationMetricType, AverageUtilization: &utilization},
+		},
+	}, {
+		Type: v2.ResourceMetricSourceType,
+		Resource: &v2.ResourceMetricSource{
+			Name:   core.ResourceMemory,
+			Target: v2.MetricTarget{Type: v2.AverageValueMetricType, AverageValue: &memory},
+		},
+	}}, hpa.Spec.Metrics)
+}
+
+func TestHPADefaultReplicas(t *testing.T) {
+	app, deployment := behaviorTestApp(&crd.AutoScaler{
+		Triggers: []keda.ScaleTriggers{
+			{Type: "cpu", Metadata: map[string]string{"value": "50"}},
+		},
+	})
+
+	hpa := hpaTestHPA(app, deployment)
+
+	assert.Equal(t, int32(1), *hpa.Spec.MinReplicas)
+	assert.Equal(t, int32(10), hpa.Spec.MaxReplicas)
+}
+
+func TestValidateHPAReplicas(t *testing.T) {
+	zero, one := int32(0), int32(1)
+
+	_, deployment := behaviorTestApp(&crd.AutoScaler{MinReplicaCount: &one})
+	assert.NoError(t, validateHPAReplicas(deployment))
+
+	_, deployment = behaviorTestApp(&crd.AutoScaler{})
+	assert.NoError(t, validateHPAReplicas(deployment))
+
+	_, deployment = behaviorTestApp(&crd.AutoScaler{MinReplicaCount: &zero})
+	err := validateHPAReplicas(deployment)
+
+	invalid := &errors.InvalidReplicas{}
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []string{"minReplicaCount 0 requires the autoscaler to be in keda mode"}, invalid.Problems)
+}
+
+func TestValidateHPATriggersRequiresKeda(t *testing.T) {
+	_, deployment := behaviorTestApp(&crd.AutoScaler{
+		Triggers: []keda.ScaleTriggers{
+			{Type: "cpu", Metadata: map[string]string{"value": "50"}},
+			{Type: "kafka", Metadata: map[string]string{"consumerGroup": "my-group"}},
+			{Type: "prometheus", Metadata: map[string]string{}},
+		},
+	})
+
+	err := validateHPATriggers(deployment)
+
+	invalid := &errors.InvalidAutoScalerTriggers{}
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []string{
+		"deployment [processor] trigger [1] (kafka): has no HPA equivalent and requires the autoscaler to be in keda mode",
+		"deployment [processor] trigger [2] (prometheus): has no HPA equivalent and requires the autoscaler to be in keda mode",
+	}, invalid.Problems)
+}
+
+func TestValidateHPATriggersValues(t *testing.T) {
+	_, deployment := behaviorTestApp(&crd.AutoScaler{
+		Triggers: []keda.ScaleTriggers{
+			{Type: "cpu", Metadata: map[string]string{"type": "Utilization", "value": "50.5"}},
+		},
+	})
+
+	err := validateHPATriggers(deployment)
+
+	invalid := &errors.InvalidAutoScalerTriggers{}
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []string{
+		"deployment [processor] trigger [0] (cpu): metadata key [value] must be a whole percentage",
+	}, invalid.Problems)
+}
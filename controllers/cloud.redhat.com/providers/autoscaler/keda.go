@@ -2,17 +2,75 @@ package autoscaler
 
 import (
 	"fmt"
+	"strconv"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	apps "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/api/autoscaling/v2beta2"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// requiredTriggerMetadata lists the metadata keys keda needs for the trigger types we know about,
+// leaving out those Clowder fills in itself. Other trigger types are passed through unchecked.
+var requiredTriggerMetadata = map[string][]string{
+	"cpu":        {"value"},
+	"memory":     {"value"},
+	"cron":       {"timezone", "start", "end", "desiredReplicas"},
+	"kafka":      {"consumerGroup"},
+	"prometheus": {"metricName", "query", "threshold"},
+}
+
+// validateTriggers checks the metadata of the deployment's autoscaler triggers, returning an
+// InvalidAutoScalerTriggers error describing every problem found.
+func validateTriggers(deployment *crd.Deployment) error {
+	problems := []string{}
+
+	for i, trigger := range deployment.AutoScaler.Triggers {
+		required, ok := requiredTriggerMetadata[trigger.Type]
+		if !ok {
+			continue
+		}
+
+		prefix := fmt.Sprintf("deployment [%s] trigger [%d] (%s)", deployment.Name, i, trigger.Type)
+
+		for _, key := range required {
+			if trigger.Metadata[key] == "" {
+				problems = append(problems, fmt.Sprintf("%s: missing metadata key [%s]", prefix, key))
+			}
+		}
+
+		if trigger.Type != "cpu" && trigger.Type != "memory" {
+			continue
+		}
+
+		if value := trigger.Metadata["value"]; value != "" {
+			if _, err := strconv.ParseFloat(value, 64); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: metadata key [value] is not a number", prefix))
+			}
+		}
+
+		if metricType, ok := trigger.Metadata["type"]; ok && metricType != string(v2.UtilizationMetricType) && metricType != string(v2.AverageValueMetricType) {
+			problems = append(problems, fmt.Sprintf("%s: metadata key [type] must be Utilization or AverageValue", prefix))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &errors.InvalidAutoScalerTriggers{Problems: problems}
+	}
+	return nil
+}
+
 func makeAutoScalers(deployment *crd.Deployment, app *crd.ClowdApp, c *config.AppConfig, asp *providers.Provider) error {
+	if err := validateTriggers(deployment); err != nil {
+		return err
+	}
+
 	s := &keda.ScaledObject{}
 	nn := app.GetDeploymentNamespacedName(deployment)
 	if err := asp.Cache.Create(CoreAutoScaler, nn, s); err != nil {
@@ -73,9 +131,59 @@ func initAutoScaler(env *crd.ClowdEnvironment, app *crd.ClowdApp, d *apps.Deploy
 	}
 	scalerSpec.Triggers = triggers
 
+	// Render the behavior policies onto the HPA that keda generates, unless the
+	// advanced config already carries its own.
+	if deployment.AutoScaler.Behavior != nil {
+		advanced := &keda.AdvancedConfig{}
+		if scalerSpec.Advanced != nil {
+			advanced = scalerSpec.Advanced.DeepCopy()
+		}
+		if advanced.HorizontalPodAutoscalerConfig == nil {
+			advanced.HorizontalPodAutoscalerConfig = &keda.HorizontalPodAutoscalerConfig{}
+		}
+		if advanced.HorizontalPodAutoscalerConfig.Behavior == nil {
+			advanced.HorizontalPodAutoscalerConfig.Behavior = convertBehavior(deployment.AutoScaler.Behavior)
+		}
+		scalerSpec.Advanced = advanced
+	}
+
 	s.Spec = scalerSpec
 }
 
+// convertBehavior converts the autoscaling/v2 behavior used in the ClowdApp to the
+// autoscaling/v2beta2 behavior used by keda.
+func convertBehavior(behavior *v2.HorizontalPodAutoscalerBehavior) *v2beta2.HorizontalPodAutoscalerBehavior {
+	return &v2beta2.HorizontalPodAutoscalerBehavior{
+		ScaleUp:   convertScalingRules(behavior.ScaleUp),
+		ScaleDown: convertScalingRules(behavior.ScaleDown),
+	}
+}
+
+func convertScalingRules(rules *v2.HPAScalingRules) *v2beta2.HPAScalingRules {
+	if rules == nil {
+		return nil
+	}
+
+	converted := &v2beta2.HPAScalingRules{
+		StabilizationWindowSeconds: rules.StabilizationWindowSeconds,
+	}
+
+	if rules.SelectPolicy != nil {
+		selectPolicy := v2beta2.ScalingPolicySelect(*rules.SelectPolicy)
+		converted.SelectPolicy = &selectPolicy
+	}
+
+	for _, policy := range rules.Policies {
+		converted.Policies = append(converted.Policies, v2beta2.HPAScalingPolicy{
+			Type:          v2beta2.HPAScalingPolicyType(policy.Type),
+			Value:         policy.Value,
+			PeriodSeconds: policy.PeriodSeconds,
+		})
+	}
+
+	return converted
+}
+
 func getTriggerRoute(triggerType string, c *config.AppConfig, env *crd.ClowdEnvironment) map[string]string {
 	result := map[string]string{}
 	switch triggerType {
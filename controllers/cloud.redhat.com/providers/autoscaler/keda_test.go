@@ -0,0 +1,150 @@
+package autoscaler
+
+import (
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	v2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/api/autoscaling/v2beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func behaviorTestApp(autoScaler *crd.AutoScaler) (*crd.ClowdApp, *crd.Deployment) {
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+		},
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{{
+				Name:       "processor",
+				AutoScaler: autoScaler,
+			}},
+		},
+	}
+	return app, &app.Spec.Deployments[0]
+}
+
+func behaviorTestScaledObject(app *crd.ClowdApp, deployment *crd.Deployment) *keda.ScaledObject {
+	nn := types.NamespacedName{Name: "app-processor", Namespace: "default"}
+	d := &apps.Deployment{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+	s := &keda.ScaledObject{}
+	initAutoScaler(&crd.ClowdEnvironment{}, app, d, s, nn, deployment, &config.AppConfig{})
+	return s
+}
+
+func TestAutoScalerBehavior(t *testing.T) {
+	window := int32(600)
+	selectPolicy := v2.MinChangePolicySelect
+	app, deployment := behaviorTestApp(&crd.AutoScaler{
+		Behavior: &v2.HorizontalPodAutoscalerBehavior{
+			ScaleDown: &v2.HPAScalingRules{
+				StabilizationWindowSeconds: &window,
+				SelectPolicy:               &selectPolicy,
+				Policies: []v2.HPAScalingPolicy{{
+					Type:          v2.PodsScalingPolicy,
+					Value:         2,
+					PeriodSeconds: 60,
+				}},
+			},
+		},
+	})
+
+	s := behaviorTestScaledObject(app, deployment)
+
+	assert.NotNil(t, s.Spec.Advanced)
+	assert.NotNil(t, s.Spec.Advanced.HorizontalPodAutoscalerConfig)
+	behavior := s.Spec.Advanced.HorizontalPodAutoscalerConfig.Behavior
+	assert.NotNil(t, behavior)
+	assert.Nil(t, behavior.ScaleUp)
+	assert.Equal(t, int32(600), *behavior.ScaleDown.StabilizationWindowSeconds)
+	assert.Equal(t, v2beta2.MinPolicySelect, *behavior.ScaleDown.SelectPolicy)
+	assert.Equal(t, []v2beta2.HPAScalingPolicy{{
+		Type:          v2beta2.PodsScalingPolicy,
+		Value:         2,
+		PeriodSeconds: 60,
+	}}, behavior.ScaleDown.Policies)
+
+	// The app's own advanced config must not be modified
+	assert.Nil(t, deployment.AutoScaler.Advanced)
+}
+
+func TestAutoScalerBehaviorUnset(t *testing.T) {
+	app, deployment := behaviorTestApp(&crd.AutoScaler{})
+
+	s := behaviorTestScaledObject(app, deployment)
+
+	assert.Nil(t, s.Spec.Advanced)
+}
+
+func TestAutoScalerBehaviorAdvancedWins(t *testing.T) {
+	window := int32(30)
+	advancedWindow := int32(120)
+	app, deployment := behaviorTestApp(&crd.AutoScaler{
+		Advanced: &keda.AdvancedConfig{
+			HorizontalPodAutoscalerConfig: &keda.HorizontalPodAutoscalerConfig{
+				Behavior: &v2beta2.HorizontalPodAutoscalerBehavior{
+					ScaleUp: &v2beta2.HPAScalingRules{StabilizationWindowSeconds: &advancedWindow},
+				},
+			},
+		},
+		Behavior: &v2.HorizontalPodAutoscalerBehavior{
+			ScaleUp: &v2.HPAScalingRules{StabilizationWindowSeconds: &window},
+		},
+	})
+
+	s := behaviorTestScaledObject(app, deployment)
+
+	assert.Equal(t, int32(120), *s.Spec.Advanced.HorizontalPodAutoscalerConfig.Behavior.ScaleUp.StabilizationWindowSeconds)
+}
+
+func TestValidateTriggers(t *testing.T) {
+	_, deployment := behaviorTestApp(&crd.AutoScaler{
+		Triggers: []keda.ScaleTriggers{
+			{Type: "cpu", Metadata: map[string]string{"type": "Utilization", "value": "50"}},
+			{Type: "cpu", Metadata: map[string]string{"type": "Utilization"}},
+			{Type: "memory", Metadata: map[string]string{"type": "Percent", "value": "lots"}},
+			{Type: "some-future-scaler", Metadata: map[string]string{}},
+		},
+	})
+
+	err := validateTriggers(deployment)
+
+	invalid := &errors.InvalidAutoScalerTriggers{}
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []string{
+		"deployment [processor] trigger [1] (cpu): missing metadata key [value]",
+		"deployment [processor] trigger [2] (memory): metadata key [value] is not a number",
+		"deployment [processor] trigger [2] (memory): metadata key [type] must be Utilization or AverageValue",
+	}, invalid.Problems)
+}
+
+func TestValidateTriggersValid(t *testing.T) {
+	_, deployment := behaviorTestApp(&crd.AutoScaler{
+		Triggers: []keda.ScaleTriggers{
+			{Type: "cpu", Metadata: map[string]string{"type": "Utilization", "value": "50"}},
+			{Type: "kafka", Metadata: map[string]string{"consumerGroup": "my-group", "topic": "topicone"}},
+		},
+	})
+
+	assert.NoError(t, validateTriggers(deployment))
+}
+
+func TestAutoScalerReplicaCounts(t *testing.T) {
+	app, deployment := behaviorTestApp(&crd.AutoScaler{})
+	s := behaviorTestScaledObject(app, deployment)
+	assert.Equal(t, int32(1), *s.Spec.MinReplicaCount)
+	assert.Equal(t, int32(10), *s.Spec.MaxReplicaCount)
+
+	zero, three := int32(0), int32(3)
+	app, deployment = behaviorTestApp(&crd.AutoScaler{MinReplicaCount: &zero, MaxReplicaCount: &three})
+	s = behaviorTestScaledObject(app, deployment)
+	assert.Equal(t, int32(0), *s.Spec.MinReplicaCount)
+	assert.Equal(t, int32(3), *s.Spec.MaxReplicaCount)
+}
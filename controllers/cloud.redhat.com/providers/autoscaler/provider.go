@@ -2,9 +2,13 @@ package autoscaler
 
 import (
 	p "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
 	v2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // ProvName sets the provider name identifier
@@ -12,21 +16,36 @@ var ProvName = "autoscaler"
 
 const ENABLED = "enabled"
 const KEDA = "keda"
+const HPA = "hpa"
 
 // CoreAutoScaler is the config that is presented as the cdappconfig.json file.
 var CoreAutoScaler = rc.NewMultiResourceIdent(ProvName, "core_autoscaler", &keda.ScaledObject{})
 var SimpleAutoScaler = rc.NewMultiResourceIdent(ProvName, "simple_hpa", &v2.HorizontalPodAutoscaler{})
 
+// HPAAutoScaler is the native HPA rendered from the autoscaler config in hpa mode.
+var HPAAutoScaler = rc.NewMultiResourceIdent(ProvName, "native_hpa", &v2.HorizontalPodAutoscaler{})
+
 // GetAutoscaler returns the correct end provider.
 func GetAutoScaler(c *p.Provider) (p.ClowderProvider, error) {
 	mode := c.Env.Spec.Providers.AutoScaler.Mode
 	// Keda is preserved as a synonym of enabled for backwards compatibility
-	if mode == ENABLED || mode == KEDA {
+	if mode == ENABLED || mode == KEDA || mode == HPA {
 		return NewAutoScaleProviderRouter(c)
 	}
 	return NewNoneAutoScalerProvider(c)
 }
 
+// scaledObjectsInstalled reports whether the ScaledObject CRD of keda is installed in the cluster,
+// clusters without it would reject any request for ScaledObjects.
+func scaledObjectsInstalled(pClient client.Client, scheme *runtime.Scheme) (bool, error) {
+	gvk, err := utils.GetKindFromObj(scheme, &keda.ScaledObject{})
+	if err != nil {
+		return false, err
+	}
+
+	return provutils.KindInstalled(pClient.RESTMapper(), gvk)
+}
+
 func init() {
 	p.ProvidersRegistration.Register(GetAutoScaler, 10, ProvName)
 }
@@ -0,0 +1,33 @@
+package autoscaler
+
+import (
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	keda "github.com/kedacore/keda/v2/apis/keda/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestScaledObjectsInstalled(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, crd.AddToScheme(scheme))
+	assert.NoError(t, keda.AddToScheme(scheme))
+
+	// the default mapper of the fake client knows no kinds at all
+	installed, err := scaledObjectsInstalled(fake.NewClientBuilder().WithScheme(scheme).Build(), scheme)
+	assert.NoError(t, err)
+	assert.False(t, installed)
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{keda.SchemeGroupVersion})
+	mapper.Add(keda.SchemeGroupVersion.WithKind("ScaledObject"), meta.RESTScopeNamespace)
+
+	installed, err = scaledObjectsInstalled(fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).Build(), scheme)
+	assert.NoError(t, err)
+	assert.True(t, installed)
+}
@@ -1,8 +1,12 @@
 package autoscaler
 
 import (
+	"fmt"
+
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
 )
 
 // autoScaleProviderRouter is a wrapper for the different autoscaler providers.
@@ -11,10 +15,16 @@ type autoScaleProviderRouter struct {
 }
 
 func NewAutoScaleProviderRouter(p *providers.Provider) (providers.ClowderProvider, error) {
-	p.Cache.AddPossibleGVKFromIdent(
-		SimpleAutoScaler,
-		CoreAutoScaler,
-	)
+	p.Cache.AddPossibleGVKFromIdent(SimpleAutoScaler, HPAAutoScaler)
+	// ScaledObjects are cleaned up in hpa mode too, so that those rendered before a switch from
+	// keda mode are removed, but they can only be listed on clusters that run keda
+	installed, err := scaledObjectsInstalled(p.Client, p.Cache.GetScheme())
+	if err != nil {
+		return nil, err
+	}
+	if installed || p.Env.Spec.Providers.AutoScaler.Mode != HPA {
+		p.Cache.AddPossibleGVKFromIdent(CoreAutoScaler)
+	}
 	return &autoScaleProviderRouter{Provider: *p}, nil
 }
 
@@ -23,18 +33,45 @@ func (asp *autoScaleProviderRouter) EnvProvide() error {
 }
 
 func (asp *autoScaleProviderRouter) Provide(app *crd.ClowdApp) error {
-	var err error
-	for _, deployment := range app.Spec.Deployments {
+	for _, innerDeployment := range app.Spec.Deployments {
+		// Singletons must never be scaled beyond a single replica
+		if innerDeployment.Singleton {
+			continue
+		}
+		// The minimum and maximum of the autoscalers are kept within the replica guardrails of
+		// the environment, the deployment provider has already logged any clamping
+		guarded, _, err := provutils.ApplyReplicaGuardrails(asp.Env, &innerDeployment)
+		if err != nil {
+			return err
+		}
+		deployment := *guarded
+		// The autoscalers only target Deployments for now
+		if deployment.IsStatefulSet() && (deployment.AutoScaler != nil || deployment.AutoScalerSimple != nil) {
+			return errors.NewClowderError(
+				fmt.Sprintf("deployment [%s] cannot use an autoscaler with the statefulset kind", deployment.Name),
+			)
+		}
 		// If we find a SimpleAutoScaler config create one
 		if deployment.AutoScalerSimple != nil {
-			err = ProvideSimpleAutoScaler(app, asp.GetConfig(), &asp.Provider, deployment)
+			if err := ProvideSimpleAutoScaler(app, asp.GetConfig(), &asp.Provider, deployment); err != nil {
+				return err
+			}
+			continue
+		}
+		// In hpa mode the autoscaler config is rendered as a native HPA instead
+		if deployment.AutoScaler != nil && asp.Env.Spec.Providers.AutoScaler.Mode == HPA {
+			if err := ProvideHPAAutoScaler(app, &asp.Provider, deployment); err != nil {
+				return err
+			}
 			continue
 		}
 		// If we find a Keda autoscaler config create one
 		if deployment.AutoScaler != nil {
-			err = ProvideKedaAutoScaler(app, asp.GetConfig(), &asp.Provider, deployment)
+			if err := ProvideKedaAutoScaler(app, asp.GetConfig(), &asp.Provider, deployment); err != nil {
+				return err
+			}
 			continue
 		}
 	}
-	return err
+	return nil
 }
@@ -6,12 +6,15 @@ import (
 	"fmt"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -105,8 +108,8 @@ func (ch *confighashProvider) volSecret(app *crd.ClowdApp, volume core.Volume) e
 	return ch.HashCache.AddClowdObjectToObject(app, sec)
 }
 
-func (ch *confighashProvider) iterateEnvVars(app *crd.ClowdApp, deployment apps.Deployment) error {
-	for _, cont := range deployment.Spec.Template.Spec.Containers {
+func (ch *confighashProvider) iterateEnvVars(app *crd.ClowdApp, template core.PodTemplateSpec) error {
+	for _, cont := range template.Spec.Containers {
 		for _, env := range cont.Env {
 			if err := ch.envConfigMap(app, env); err != nil {
 				return err
@@ -120,8 +123,8 @@ func (ch *confighashProvider) iterateEnvVars(app *crd.ClowdApp, deployment apps.
 	return nil
 }
 
-func (ch *confighashProvider) iterateVolumes(app *crd.ClowdApp, deployment apps.Deployment) error {
-	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+func (ch *confighashProvider) iterateVolumes(app *crd.ClowdApp, template core.PodTemplateSpec) error {
+	for _, volume := range template.Spec.Volumes {
 		if err := ch.volConfigMap(app, volume); err != nil {
 			return err
 		}
@@ -133,13 +136,12 @@ func (ch *confighashProvider) iterateVolumes(app *crd.ClowdApp, deployment apps.
 	return nil
 }
 
-func (ch *confighashProvider) updateHashCache(dList *apps.DeploymentList, app *crd.ClowdApp) error {
-	for _, deployment := range dList.Items {
-		deploy := deployment
-		if err := ch.iterateEnvVars(app, deploy); err != nil {
+func (ch *confighashProvider) updateHashCache(templates []core.PodTemplateSpec, app *crd.ClowdApp) error {
+	for _, template := range templates {
+		if err := ch.iterateEnvVars(app, template); err != nil {
 			return err
 		}
-		if err := ch.iterateVolumes(app, deploy); err != nil {
+		if err := ch.iterateVolumes(app, template); err != nil {
 			return err
 		}
 	}
@@ -161,7 +163,20 @@ func (ch *confighashProvider) persistConfig(app *crd.ClowdApp) (string, error) {
 		return "", err
 	}
 
-	if err := ch.updateHashCache(&dList, app); err != nil {
+	sList := apps.StatefulSetList{}
+	if err := ch.Cache.List(deployProvider.CoreStatefulSet, &sList); err != nil {
+		return "", err
+	}
+
+	templates := []core.PodTemplateSpec{}
+	for _, d := range dList.Items {
+		templates = append(templates, d.Spec.Template)
+	}
+	for _, s := range sList.Items {
+		templates = append(templates, s.Spec.Template)
+	}
+
+	if err := ch.updateHashCache(templates, app); err != nil {
 		return "", err
 	}
 
@@ -178,16 +193,28 @@ func (ch *confighashProvider) persistConfig(app *crd.ClowdApp) (string, error) {
 		return "", errors.Wrap("Failed to marshal config JSON", err)
 	}
 
+	if err := validateConfig(jsonData); err != nil {
+		return "", err
+	}
+
 	h := sha256.New()
 	h.Write([]byte(jsonData))
 	hash := fmt.Sprintf("%x", h.Sum(nil))
 
+	changed := secret.ResourceVersion != "" && string(secret.Data["cdappconfig.json"]) != string(jsonData)
+
 	secret.StringData = map[string]string{
 		"cdappconfig.json": string(jsonData),
 	}
 
 	app.SetObjectMeta(secret)
 
+	if changed && app.Spec.ConfigSecretUpdateStrategy == "recreate" {
+		if secret, err = ch.recreateSecret(secret); err != nil {
+			return "", err
+		}
+	}
+
 	err = ch.Cache.Update(CoreConfigSecret, secret)
 
 	if err != nil {
@@ -196,3 +223,42 @@ func (ch *confighashProvider) persistConfig(app *crd.ClowdApp) (string, error) {
 
 	return hash, err
 }
+
+// validateConfig checks the generated config against the app config schema, catching a provider
+// that sets a field of the wrong type before the config reaches the app.
+func validateConfig(jsonData []byte) error {
+	if !clowderconfig.LoadedConfig.Features.ValidateAppConfig {
+		return nil
+	}
+	if err := config.Validate(jsonData); err != nil {
+		return errors.Wrap("Generated config failed schema validation", err)
+	}
+	return nil
+}
+
+// recreateSecret deletes the config secret and creates it afresh with the new config, instead of
+// updating it in place, for apps whose secret reloaders only pick up new secrets. The recreated
+// secret is returned so that the cache carries its new resource version.
+func (ch *confighashProvider) recreateSecret(secret *core.Secret) (*core.Secret, error) {
+	if err := ch.Client.Delete(ch.Ctx, secret); err != nil && !k8serr.IsNotFound(err) {
+		return nil, errors.Wrap("Failed to delete config secret", err)
+	}
+
+	recreated := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secret.Name,
+			Namespace:       secret.Namespace,
+			Labels:          secret.Labels,
+			Annotations:     secret.Annotations,
+			OwnerReferences: secret.OwnerReferences,
+		},
+		Type:       secret.Type,
+		StringData: secret.StringData,
+	}
+
+	if err := ch.Client.Create(ch.Ctx, recreated); err != nil {
+		return nil, errors.Wrap("Failed to recreate config secret", err)
+	}
+
+	return recreated, nil
+}
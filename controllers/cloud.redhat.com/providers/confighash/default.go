@@ -5,7 +5,6 @@ import (
 	p "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	cronjobProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/cronjob"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
-	apps "k8s.io/api/apps/v1"
 	batch "k8s.io/api/batch/v1"
 	core "k8s.io/api/core/v1"
 
@@ -38,19 +37,13 @@ func (ch *confighashProvider) Provide(app *crd.ClowdApp) error {
 		return err
 	}
 
-	dList := apps.DeploymentList{}
-	if err := ch.Cache.List(deployProvider.CoreDeployment, &dList); err != nil {
-		return err
-	}
-
-	for _, deployment := range dList.Items {
-		depInner := deployment
+	err = deployProvider.EditPodTemplates(ch.Cache, func(t *core.PodTemplateSpec) error {
 		annotations := map[string]string{"configHash": hash}
-		utils.UpdateAnnotations(&depInner.Spec.Template, annotations)
-
-		if err := ch.Cache.Update(deployProvider.CoreDeployment, &depInner); err != nil {
-			return err
-		}
+		utils.UpdateAnnotations(t, annotations)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	jList := batch.CronJobList{}
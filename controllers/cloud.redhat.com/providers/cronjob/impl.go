@@ -49,18 +49,7 @@ func buildPodTemplate(app *crd.ClowdApp, env *crd.ClowdEnvironment, pt *core.Pod
 
 	pt.ObjectMeta.Labels = labels
 
-	envvar := pod.Env
-	envvar = append(envvar, core.EnvVar{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"})
-
-	for _, env := range envvar {
-		if env.ValueFrom != nil {
-			if env.ValueFrom.FieldRef != nil {
-				if env.ValueFrom.FieldRef.APIVersion == "" {
-					env.ValueFrom.FieldRef.APIVersion = "v1"
-				}
-			}
-		}
-	}
+	envvar := deployProvider.LoadEnvVars(pod)
 
 	var livenessProbe core.Probe
 	var readinessProbe core.Probe
@@ -97,16 +86,10 @@ func buildPodTemplate(app *crd.ClowdApp, env *crd.ClowdEnvironment, pt *core.Pod
 		c.ImagePullPolicy = core.PullIfNotPresent
 	}
 
-	if pod.MachinePool != "" {
-		pt.Spec.Tolerations = []core.Toleration{{
-			Key:      pod.MachinePool,
-			Effect:   core.TaintEffectNoSchedule,
-			Operator: core.TolerationOpEqual,
-			Value:    "true",
-		}}
-	} else {
-		pt.Spec.Tolerations = []core.Toleration{}
-	}
+	deployProvider.SetScheduling(pt, &pod, env)
+	deployProvider.SetImagePullSecrets(pt, env)
+
+	pt.Spec.AutomountServiceAccountToken = pod.AutomountServiceAccountToken
 
 	// set service account for pod
 	pt.Spec.ServiceAccountName = app.GetClowdSAName()
@@ -123,10 +106,14 @@ func buildPodTemplate(app *crd.ClowdApp, env *crd.ClowdEnvironment, pt *core.Pod
 	}
 
 	c.VolumeMounts = append(c.VolumeMounts, core.VolumeMount{
-		Name:      "config-secret",
-		MountPath: "/cdapp/",
+		Name:      deployProvider.ConfigSecretVolumeName,
+		MountPath: deployProvider.ConfigMountPath(pod),
 	})
 
+	if err := deployProvider.ValidateConfigMount(&c); err != nil {
+		return err
+	}
+
 	pt.Spec.Containers = []core.Container{c}
 
 	ics, err := deployProvider.ProcessInitContainers(nn, &c, pod.InitContainers)
@@ -138,11 +125,12 @@ func buildPodTemplate(app *crd.ClowdApp, env *crd.ClowdEnvironment, pt *core.Pod
 
 	pt.Spec.Volumes = pod.Volumes
 	pt.Spec.Volumes = append(pt.Spec.Volumes, core.Volume{
-		Name: "config-secret",
+		Name: deployProvider.ConfigSecretVolumeName,
 		VolumeSource: core.VolumeSource{
 			Secret: &core.SecretVolumeSource{
 				DefaultMode: utils.Int32Ptr(420),
 				SecretName:  app.ObjectMeta.Name,
+				Items:       deployProvider.ConfigSecretItems(pod),
 			},
 		},
 	})
@@ -8,6 +8,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
@@ -19,6 +20,19 @@ import (
 )
 
 var rdsCaBundles = make(map[string]string)
+var rdsCaBundlesMu sync.RWMutex
+
+func getRdsCaBundle(caURL string) string {
+	rdsCaBundlesMu.RLock()
+	defer rdsCaBundlesMu.RUnlock()
+	return rdsCaBundles[caURL]
+}
+
+func setRdsCaBundle(caURL string, bundle string) {
+	rdsCaBundlesMu.Lock()
+	defer rdsCaBundlesMu.Unlock()
+	rdsCaBundles[caURL] = bundle
+}
 
 const defaultCaBundleURL string = "https://s3.amazonaws.com/rds-downloads/rds-combined-ca-bundle.pem"
 
@@ -65,14 +79,14 @@ func (a *appInterface) EnvProvide() error {
 		caURL = defaultCaBundleURL
 	}
 
-	if rdsCaBundles[caURL] == "" {
+	if getRdsCaBundle(caURL) == "" {
 		_rdsCa, err := fetchCa(caURL)
 
 		if err != nil {
 			return errors.Wrap("Failed to fetch RDS CA bundle", err)
 		}
 
-		rdsCaBundles[caURL] = _rdsCa
+		setRdsCaBundle(caURL, _rdsCa)
 	}
 
 	return nil
@@ -174,7 +188,7 @@ func GetDbConfig(
 	if rdsCaBundleURL == "" {
 		rdsCaBundleURL = defaultCaBundleURL
 	}
-	bundle := rdsCaBundles[rdsCaBundleURL]
+	bundle := getRdsCaBundle(rdsCaBundleURL)
 	matched.Config.RdsCa = &bundle
 
 	return &matched, nil
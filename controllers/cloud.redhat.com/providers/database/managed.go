@@ -0,0 +1,140 @@
+package database
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// managedDbKeys are the keys that must be present in the secret describing the external instance.
+var managedDbKeys = []string{"host", "port", "user", "password", "sslmode"}
+
+// managedDbNameKey optionally names the default database of the external instance.
+const managedDbNameKey = "dbname"
+
+type managedDbProvider struct {
+	providers.Provider
+}
+
+// NewManagedDBProvider creates a new managed DB provider object, which passes through the
+// credentials of an externally managed database instance.
+func NewManagedDBProvider(p *providers.Provider) (providers.ClowderProvider, error) {
+	return &managedDbProvider{Provider: *p}, nil
+}
+
+func (db *managedDbProvider) EnvProvide() error {
+	return nil
+}
+
+func (db *managedDbProvider) Provide(app *crd.ClowdApp) error {
+	if app.Spec.Database.Name == "" && app.Spec.Database.SharedDBAppName == "" {
+		return nil
+	}
+
+	if app.Spec.Database.Name != "" && app.Spec.Database.SharedDBAppName != "" {
+		return errors.NewClowderError("Cannot set dbName & shared db app name")
+	}
+
+	dbName := app.Spec.Database.Name
+
+	if app.Spec.Database.SharedDBAppName != "" {
+		err := checkDependency(app)
+		if err != nil {
+			return err
+		}
+
+		refApp, err := crd.GetAppForDBInSameEnv(db.Ctx, db.Client, app)
+
+		if err != nil {
+			return err
+		}
+
+		dbName = refApp.Spec.Database.Name
+	}
+
+	ref := db.Env.Spec.Providers.Database.SecretRef
+	name := types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+
+	secret := core.Secret{}
+	err := db.Client.Get(db.Ctx, name, &secret)
+
+	if k8serr.IsNotFound(err) {
+		missingSecrets := errors.MakeMissingSecrets(errors.MissingSecret{
+			Provider:  ProvName,
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		})
+		return &missingSecrets
+	} else if err != nil {
+		return errors.Wrap("Failed to fetch managed database secret", err)
+	}
+
+	dbConfig, err := genManagedDbConfig(&secret, dbName)
+
+	if err != nil {
+		return err
+	}
+
+	db.Config.Database = dbConfig
+
+	return nil
+}
+
+// genManagedDbConfig builds the database config for the given database name from the secret
+// describing the external instance. The database itself is expected to already exist.
+func genManagedDbConfig(secret *core.Secret, dbName string) (*config.DatabaseConfig, error) {
+	missing := []string{}
+	for _, key := range managedDbKeys {
+		if _, ok := secret.Data[key]; !ok {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		msg := fmt.Sprintf(
+			"Managed database secret [%s/%s] is missing required keys: %s",
+			secret.Namespace, secret.Name, strings.Join(missing, ", "),
+		)
+		return nil, errors.NewClowderError(msg)
+	}
+
+	if defaultDb, ok := secret.Data[managedDbNameKey]; ok && string(defaultDb) != dbName {
+		msg := fmt.Sprintf(
+			"Managed database secret [%s/%s] is for database [%s], not the requested [%s]",
+			secret.Namespace, secret.Name, defaultDb, dbName,
+		)
+		return nil, errors.NewClowderError(msg)
+	}
+
+	port, err := strconv.ParseUint(string(secret.Data["port"]), 10, 16)
+
+	if err != nil {
+		return nil, errors.Wrap("Failed to parse DB port", err)
+	}
+
+	username := string(secret.Data["user"])
+	password := string(secret.Data["password"])
+
+	// The creds for the external instance have elevated privileges
+	return &config.DatabaseConfig{
+		Hostname:      string(secret.Data["host"]),
+		Port:          int(port),
+		Username:      username,
+		Password:      password,
+		AdminUsername: username,
+		AdminPassword: password,
+		Name:          dbName,
+		SslMode:       string(secret.Data["sslmode"]),
+	}, nil
+}
@@ -0,0 +1,71 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func managedDbSecret(data map[string]string) *core.Secret {
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "rds", Namespace: "clowder"},
+		Data:       map[string][]byte{},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func TestManagedDb(t *testing.T) {
+	secret := managedDbSecret(map[string]string{
+		"host":     "test-db.amazing.aws.amazon.com",
+		"port":     "5432",
+		"user":     "user",
+		"password": "password",
+		"sslmode":  "verify-full",
+	})
+
+	dbConfig, err := genManagedDbConfig(secret, "test-db")
+	assert.NoError(t, err)
+	assert.Equal(t, "test-db.amazing.aws.amazon.com", dbConfig.Hostname)
+	assert.Equal(t, 5432, dbConfig.Port)
+	assert.Equal(t, "user", dbConfig.Username)
+	assert.Equal(t, "password", dbConfig.Password)
+	assert.Equal(t, "user", dbConfig.AdminUsername)
+	assert.Equal(t, "password", dbConfig.AdminPassword)
+	assert.Equal(t, "test-db", dbConfig.Name)
+	assert.Equal(t, "verify-full", dbConfig.SslMode)
+
+	// a matching default database is fine
+	secret.Data["dbname"] = []byte("test-db")
+	_, err = genManagedDbConfig(secret, "test-db")
+	assert.NoError(t, err)
+}
+
+func TestManagedDbMissingKeys(t *testing.T) {
+	secret := managedDbSecret(map[string]string{
+		"host": "test-db.amazing.aws.amazon.com",
+		"port": "5432",
+		"user": "user",
+	})
+
+	_, err := genManagedDbConfig(secret, "test-db")
+	assert.ErrorContains(t, err, "Managed database secret [clowder/rds] is missing required keys: password, sslmode")
+}
+
+func TestManagedDbMismatchedName(t *testing.T) {
+	secret := managedDbSecret(map[string]string{
+		"host":     "test-db.amazing.aws.amazon.com",
+		"port":     "5432",
+		"user":     "user",
+		"password": "password",
+		"sslmode":  "verify-full",
+		"dbname":   "other-db",
+	})
+
+	_, err := genManagedDbConfig(secret, "test-db")
+	assert.ErrorContains(t, err, "is for database [other-db], not the requested [test-db]")
+}
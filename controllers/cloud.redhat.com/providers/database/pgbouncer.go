@@ -0,0 +1,207 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+)
+
+// PgBouncerSecret is the ident referring to the secret holding the PgBouncer config of an app.
+var PgBouncerSecret = rc.NewSingleResourceIdent(ProvName, "pgbouncer_secret", &core.Secret{})
+
+// PgBouncerPort is the port the PgBouncer sidecar listens on, only on the loopback interface.
+const PgBouncerPort = 6432
+
+const pgBouncerContainerName = "pgbouncer"
+const pgBouncerVolumeName = "pgbouncer-config"
+const pgBouncerConfigPath = "/etc/pgbouncer"
+const pgBouncerCAFile = "rds-ca.crt"
+
+// pgBouncerProvider wraps the provider of the configured database mode, pointing the database
+// config it generates at a PgBouncer sidecar which proxies to the real database.
+type pgBouncerProvider struct {
+	providers.Provider
+	inner providers.ClowderProvider
+}
+
+// NewPgBouncerProvider returns a new PgBouncer provider object wrapping the given database provider.
+func NewPgBouncerProvider(p *providers.Provider, inner providers.ClowderProvider) (providers.ClowderProvider, error) {
+	return &pgBouncerProvider{Provider: *p, inner: inner}, nil
+}
+
+func (db *pgBouncerProvider) EnvProvide() error {
+	return db.inner.EnvProvide()
+}
+
+func (db *pgBouncerProvider) Provide(app *crd.ClowdApp) error {
+	if err := db.inner.Provide(app); err != nil {
+		return err
+	}
+
+	// Job pods would never complete with the sidecar running alongside them, so apps with
+	// jobs keep talking to the database directly
+	if db.Config.Database == nil || len(app.Spec.Jobs) > 0 {
+		return nil
+	}
+
+	nn := types.NamespacedName{
+		Name:      fmt.Sprintf("%s-pgbouncer", app.Name),
+		Namespace: app.Namespace,
+	}
+
+	secret := &core.Secret{}
+	if err := db.Cache.Create(PgBouncerSecret, nn, secret); err != nil {
+		return err
+	}
+
+	app.SetObjectMeta(secret, crd.Name(nn.Name))
+	secret.Type = core.SecretTypeOpaque
+	secret.StringData = map[string]string{
+		"pgbouncer.ini": pgBouncerIni(db.Config.Database, db.Env.Spec.Providers.Database.PgBouncer),
+		"userlist.txt":  pgBouncerUserlist(db.Config.Database),
+	}
+	if hasRdsCa(db.Config.Database) {
+		secret.StringData[pgBouncerCAFile] = *db.Config.Database.RdsCa
+	}
+
+	if err := db.Cache.Update(PgBouncerSecret, secret); err != nil {
+		return err
+	}
+
+	for _, deployment := range app.Spec.Deployments {
+		innerDeployment := deployment
+
+		err := deployProvider.EditPodTemplate(db.Cache, app, &innerDeployment, func(t *core.PodTemplateSpec) error {
+			injectPgBouncer(t, nn.Name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	db.Config.Database.Hostname = "localhost"
+	db.Config.Database.Port = PgBouncerPort
+	db.Config.Database.SslMode = "disable"
+
+	return nil
+}
+
+// hasRdsCa returns true if the database config carries the CA bundle of the database.
+func hasRdsCa(dbConfig *config.DatabaseConfig) bool {
+	return dbConfig.RdsCa != nil && *dbConfig.RdsCa != ""
+}
+
+// pgBouncerIni renders the PgBouncer config proxying to the database of the given config, the
+// connection to the database keeps the ssl mode of the config. The certificate of the database is
+// verified against the RDS CA mounted next to the config, without a CA the verifying ssl modes are
+// lowered to require, as PgBouncer would fail every connection.
+func pgBouncerIni(dbConfig *config.DatabaseConfig, settings crd.PgBouncerConfig) string {
+	poolMode := string(settings.PoolMode)
+	if poolMode == "" {
+		poolMode = "transaction"
+	}
+	defaultPoolSize := settings.DefaultPoolSize
+	if defaultPoolSize == 0 {
+		defaultPoolSize = 20
+	}
+	maxClientConn := settings.MaxClientConn
+	if maxClientConn == 0 {
+		maxClientConn = 100
+	}
+	sslMode := dbConfig.SslMode
+	if sslMode == "" {
+		sslMode = "prefer"
+	}
+	if !hasRdsCa(dbConfig) && (sslMode == "verify-ca" || sslMode == "verify-full") {
+		sslMode = "require"
+	}
+
+	lines := []string{
+		"[databases]",
+		fmt.Sprintf("* = host=%s port=%d", dbConfig.Hostname, dbConfig.Port),
+		"",
+		"[pgbouncer]",
+		"listen_addr = 127.0.0.1",
+		fmt.Sprintf("listen_port = %d", PgBouncerPort),
+		"auth_type = scram-sha-256",
+		fmt.Sprintf("auth_file = %s/userlist.txt", pgBouncerConfigPath),
+		fmt.Sprintf("pool_mode = %s", poolMode),
+		fmt.Sprintf("default_pool_size = %d", defaultPoolSize),
+		fmt.Sprintf("max_client_conn = %d", maxClientConn),
+		fmt.Sprintf("server_tls_sslmode = %s", sslMode),
+	}
+	if hasRdsCa(dbConfig) {
+		lines = append(lines, fmt.Sprintf("server_tls_ca_file = %s/%s", pgBouncerConfigPath, pgBouncerCAFile))
+	}
+	lines = append(lines, "ignore_startup_parameters = extra_float_digits", "")
+	return strings.Join(lines, "\n")
+}
+
+// pgBouncerUserlist renders the auth file of PgBouncer holding the standard and admin users.
+func pgBouncerUserlist(dbConfig *config.DatabaseConfig) string {
+	users := pgBouncerUser(dbConfig.Username, dbConfig.Password)
+	if dbConfig.AdminUsername != "" && dbConfig.AdminUsername != dbConfig.Username {
+		users += pgBouncerUser(dbConfig.AdminUsername, dbConfig.AdminPassword)
+	}
+	return users
+}
+
+// pgBouncerUser renders a line of the auth file, double quotes are escaped by doubling them.
+func pgBouncerUser(username string, password string) string {
+	quote := func(s string) string {
+		return fmt.Sprintf("\"%s\"", strings.ReplaceAll(s, "\"", "\"\""))
+	}
+	return fmt.Sprintf("%s %s\n", quote(username), quote(password))
+}
+
+// injectPgBouncer adds the PgBouncer sidecar, reading its config from the named secret, to the
+// pod template.
+func injectPgBouncer(t *core.PodTemplateSpec, secretName string) {
+	defaultMode := int32(0400)
+
+	t.Spec.Volumes = append(t.Spec.Volumes, core.Volume{
+		Name: pgBouncerVolumeName,
+		VolumeSource: core.VolumeSource{
+			Secret: &core.SecretVolumeSource{
+				SecretName:  secretName,
+				DefaultMode: &defaultMode,
+			},
+		},
+	})
+
+	t.Spec.Containers = append(t.Spec.Containers, core.Container{
+		Name:    pgBouncerContainerName,
+		Image:   provutils.GetPgBouncerImage(),
+		Command: []string{"pgbouncer", fmt.Sprintf("%s/pgbouncer.ini", pgBouncerConfigPath)},
+		VolumeMounts: []core.VolumeMount{{
+			Name:      pgBouncerVolumeName,
+			MountPath: pgBouncerConfigPath,
+			ReadOnly:  true,
+		}},
+		Resources: core.ResourceRequirements{
+			Limits: core.ResourceList{
+				"cpu":    resource.MustParse("200m"),
+				"memory": resource.MustParse("128Mi"),
+			},
+			Requests: core.ResourceList{
+				"cpu":    resource.MustParse("20m"),
+				"memory": resource.MustParse("32Mi"),
+			},
+		},
+		TerminationMessagePath:   "/dev/termination-log",
+		TerminationMessagePolicy: core.TerminationMessageReadFile,
+		ImagePullPolicy:          core.PullIfNotPresent,
+	})
+}
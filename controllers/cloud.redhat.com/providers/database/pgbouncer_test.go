@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func pgBouncerTestProvider(t *testing.T, app *crd.ClowdApp) *providers.Provider {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, crd.AddToScheme(scheme))
+
+	secret := managedDbSecret(map[string]string{
+		"host":     "test-db.amazing.aws.amazon.com",
+		"port":     "5432",
+		"user":     "user",
+		"password": "pass\"word",
+		"sslmode":  "verify-full",
+	})
+	pClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(secret).Build()
+
+	p := &providers.Provider{
+		Client: pClient,
+		Ctx:    context.Background(),
+		Log:    logr.Discard(),
+		Config: &config.AppConfig{},
+		Env: &crd.ClowdEnvironment{
+			Spec: crd.ClowdEnvironmentSpec{
+				Providers: crd.ProvidersConfig{
+					Database: crd.DatabaseConfig{
+						Mode:      "managed",
+						SecretRef: crd.NamespacedName{Name: "rds", Namespace: "clowder"},
+						PgBouncer: crd.PgBouncerConfig{Enabled: true, PoolMode: "session"},
+					},
+				},
+			},
+		},
+	}
+	cache := rc.NewObjectCache(p.Ctx, pClient, &p.Log, rc.NewCacheConfig(scheme, nil, nil))
+	p.Cache = &cache
+
+	// the deployment provider runs first and leaves the deployments in the cache
+	for _, deployment := range app.Spec.Deployments {
+		innerDeployment := deployment
+		d := &apps.Deployment{}
+		nn := app.GetDeploymentNamespacedName(&innerDeployment)
+		assert.NoError(t, p.Cache.Create(deployProvider.CoreDeployment, nn, d))
+		d.Name, d.Namespace = nn.Name, nn.Namespace
+		d.Spec.Template.Spec.Containers = []core.Container{{Name: nn.Name, Image: "test:test"}}
+		assert.NoError(t, p.Cache.Update(deployProvider.CoreDeployment, d))
+	}
+
+	return p
+}
+
+func pgBouncerTestApp() *crd.ClowdApp {
+	return &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "app-ns"},
+		Spec: crd.ClowdAppSpec{
+			Database:    crd.DatabaseSpec{Name: "test-db"},
+			Deployments: []crd.Deployment{{Name: "api"}},
+		},
+	}
+}
+
+func TestPgBouncer(t *testing.T) {
+	app := pgBouncerTestApp()
+	p := pgBouncerTestProvider(t, app)
+
+	prov, err := GetDatabase(p)
+	assert.NoError(t, err)
+	assert.NoError(t, prov.Provide(app))
+
+	assert.Equal(t, "localhost", p.Config.Database.Hostname)
+	assert.Equal(t, PgBouncerPort, p.Config.Database.Port)
+	assert.Equal(t, "disable", p.Config.Database.SslMode)
+	assert.Equal(t, "test-db", p.Config.Database.Name)
+
+	d := &apps.Deployment{}
+	assert.NoError(t, p.Cache.Get(deployProvider.CoreDeployment, d, types.NamespacedName{Name: "app-api", Namespace: "app-ns"}))
+	containers := d.Spec.Template.Spec.Containers
+	if assert.Len(t, containers, 2) {
+		assert.Equal(t, "pgbouncer", containers[1].Name)
+		assert.Equal(t, pgBouncerConfigPath, containers[1].VolumeMounts[0].MountPath)
+	}
+	if assert.Len(t, d.Spec.Template.Spec.Volumes, 1) {
+		assert.Equal(t, "app-pgbouncer", d.Spec.Template.Spec.Volumes[0].Secret.SecretName)
+	}
+
+	secret := &core.Secret{}
+	assert.NoError(t, p.Cache.Get(PgBouncerSecret, secret, types.NamespacedName{Name: "app-pgbouncer", Namespace: "app-ns"}))
+	ini := secret.StringData["pgbouncer.ini"]
+	assert.True(t, strings.Contains(ini, "* = host=test-db.amazing.aws.amazon.com port=5432\n"))
+	assert.True(t, strings.Contains(ini, "pool_mode = session\n"))
+	assert.True(t, strings.Contains(ini, "default_pool_size = 20\n"))
+	// without the RDS CA the cert of the database can't be verified
+	assert.True(t, strings.Contains(ini, "server_tls_sslmode = require\n"))
+	assert.False(t, strings.Contains(ini, "server_tls_ca_file"))
+	assert.Equal(t, "\"user\" \"pass\"\"word\"\n", secret.StringData["userlist.txt"])
+}
+
+func TestPgBouncerIniRdsCa(t *testing.T) {
+	ca := "rds-ca"
+	dbConfig := &config.DatabaseConfig{Hostname: "db", Port: 5432, SslMode: "verify-full", RdsCa: &ca}
+
+	ini := pgBouncerIni(dbConfig, crd.PgBouncerConfig{})
+	assert.True(t, strings.Contains(ini, "server_tls_sslmode = verify-full\n"))
+	assert.True(t, strings.Contains(ini, "server_tls_ca_file = /etc/pgbouncer/rds-ca.crt\n"))
+
+	// an empty bundle, e.g. before it was fetched, is no CA
+	empty := ""
+	dbConfig.RdsCa = &empty
+	ini = pgBouncerIni(dbConfig, crd.PgBouncerConfig{})
+	assert.True(t, strings.Contains(ini, "server_tls_sslmode = require\n"))
+	assert.False(t, strings.Contains(ini, "server_tls_ca_file"))
+
+	// modes that don't verify the cert are kept
+	dbConfig.SslMode = "prefer"
+	ini = pgBouncerIni(dbConfig, crd.PgBouncerConfig{})
+	assert.True(t, strings.Contains(ini, "server_tls_sslmode = prefer\n"))
+}
+
+func TestPgBouncerSkipsAppsWithJobs(t *testing.T) {
+	app := pgBouncerTestApp()
+	app.Spec.Jobs = []crd.Job{{Name: "migrate"}}
+	p := pgBouncerTestProvider(t, app)
+
+	prov, err := GetDatabase(p)
+	assert.NoError(t, err)
+	assert.NoError(t, prov.Provide(app))
+
+	assert.Equal(t, "test-db.amazing.aws.amazon.com", p.Config.Database.Hostname)
+	assert.Equal(t, 5432, p.Config.Database.Port)
+
+	d := &apps.Deployment{}
+	assert.NoError(t, p.Cache.Get(deployProvider.CoreDeployment, d, types.NamespacedName{Name: "app-api", Namespace: "app-ns"}))
+	assert.Len(t, d.Spec.Template.Spec.Containers, 1)
+}
@@ -19,8 +19,23 @@ var ProvName = "database"
 
 var imageList map[int32]string
 
-// GetDatabase returns the correct database provider based on the environment.
+// GetDatabase returns the correct database provider based on the environment, wrapped in the
+// PgBouncer provider when pooling is enabled.
 func GetDatabase(c *p.Provider) (p.ClowderProvider, error) {
+	c.Cache.AddPossibleGVKFromIdent(PgBouncerSecret)
+
+	dbProvider, err := getDatabaseForMode(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Env.Spec.Providers.Database.PgBouncer.Enabled {
+		return NewPgBouncerProvider(c, dbProvider)
+	}
+	return dbProvider, nil
+}
+
+func getDatabaseForMode(c *p.Provider) (p.ClowderProvider, error) {
 	dbMode := c.Env.Spec.Providers.Database.Mode
 	switch dbMode {
 	case "shared":
@@ -29,6 +44,8 @@ func GetDatabase(c *p.Provider) (p.ClowderProvider, error) {
 		return NewLocalDBProvider(c)
 	case "app-interface":
 		return NewAppInterfaceDBProvider(c)
+	case "managed":
+		return NewManagedDBProvider(c)
 	case "none", "":
 		return NewNoneDBProvider(c)
 	default:
@@ -253,6 +253,50 @@ func (db *sharedDbProvider) Provide(app *crd.ClowdApp) error {
 	dbCfg.Port = int(port)
 	dbCfg.SslMode = "disable"
 
+	// Planning an app must not create its database on the shared server
+	if !db.DryRun {
+		if err := db.ensureDatabase(app, &dbCfg); err != nil {
+			return err
+		}
+	}
+
+	nn := types.NamespacedName{
+		Name:      fmt.Sprintf("%v-db", app.Name),
+		Namespace: app.Namespace,
+	}
+
+	secret := &core.Secret{}
+	if err := db.Cache.Create(SharedDBAppSecret, nn, secret); err != nil {
+		return err
+	}
+
+	secret.StringData = map[string]string{
+		"hostname": dbCfg.Hostname,
+		"port":     "5432",
+		"username": dbCfg.Username,
+		"password": dbCfg.Password,
+		"pgPass":   dbCfg.AdminPassword,
+		"name":     app.Spec.Database.Name,
+	}
+
+	secret.Name = nn.Name
+	secret.Namespace = nn.Namespace
+	secret.ObjectMeta.OwnerReferences = []metav1.OwnerReference{app.MakeOwnerReference()}
+	secret.Type = core.SecretTypeOpaque
+
+	if err := db.Cache.Update(SharedDBAppSecret, secret); err != nil {
+		return err
+	}
+
+	dbCfg.Name = app.Spec.Database.Name
+	db.Config.Database = &dbCfg
+
+	return nil
+}
+
+// ensureDatabase creates the database of the app on the shared server when it doesn't exist yet.
+func (db *sharedDbProvider) ensureDatabase(app *crd.ClowdApp, dbCfg *config.DatabaseConfig) error {
+	port := dbCfg.Port
 	host := dbCfg.Hostname
 	user := dbCfg.AdminUsername
 	password := dbCfg.AdminPassword
@@ -297,37 +341,6 @@ func (db *sharedDbProvider) Provide(app *crd.ClowdApp) error {
 		}
 	}
 
-	nn := types.NamespacedName{
-		Name:      fmt.Sprintf("%v-db", app.Name),
-		Namespace: app.Namespace,
-	}
-
-	secret := &core.Secret{}
-	if err := db.Cache.Create(SharedDBAppSecret, nn, secret); err != nil {
-		return err
-	}
-
-	secret.StringData = map[string]string{
-		"hostname": host,
-		"port":     "5432",
-		"username": dbCfg.Username,
-		"password": dbCfg.Password,
-		"pgPass":   dbCfg.AdminPassword,
-		"name":     app.Spec.Database.Name,
-	}
-
-	secret.Name = nn.Name
-	secret.Namespace = nn.Namespace
-	secret.ObjectMeta.OwnerReferences = []metav1.OwnerReference{app.MakeOwnerReference()}
-	secret.Type = core.SecretTypeOpaque
-
-	if err := db.Cache.Update(SharedDBAppSecret, secret); err != nil {
-		return err
-	}
-
-	dbCfg.Name = app.Spec.Database.Name
-	db.Config.Database = &dbCfg
-
 	return nil
 }
 
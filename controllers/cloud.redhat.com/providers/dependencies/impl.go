@@ -130,6 +130,7 @@ func processAppEndpoints(
 					Name:     innerDeployment.Name,
 					App:      depApp.Name,
 					TlsPort:  utils.IntPtr(int(tlsPort)),
+					Protocol: utils.StringPtr(string(innerDeployment.GetPublicProtocol())),
 				})
 			}
 			if innerDeployment.WebServices.Private.Enabled {
@@ -3,8 +3,10 @@ package deployment
 import (
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
 	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
 )
 
 type deploymentProvider struct {
@@ -14,8 +16,17 @@ type deploymentProvider struct {
 // CoreDeployment is the deployment for the apps deployments.
 var CoreDeployment = rc.NewMultiResourceIdent(ProvName, "core_deployment", &apps.Deployment{})
 
+// CoreStatefulSet is the statefulset for the apps deployments of the statefulset kind.
+var CoreStatefulSet = rc.NewMultiResourceIdent(ProvName, "core_statefulset", &apps.StatefulSet{})
+
+// CoreHeadlessService is the headless service giving the pods of a statefulset their identity.
+var CoreHeadlessService = rc.NewMultiResourceIdent(ProvName, "core_headless_service", &core.Service{})
+
+// CoreSharedConfigMaps are the copies of the environment's shared configmaps in the app namespace.
+var CoreSharedConfigMaps = rc.NewMultiResourceIdent(ProvName, "core_shared_configmaps", &core.ConfigMap{})
+
 func NewDeploymentProvider(p *providers.Provider) (providers.ClowderProvider, error) {
-	p.Cache.AddPossibleGVKFromIdent(CoreDeployment)
+	p.Cache.AddPossibleGVKFromIdent(CoreDeployment, CoreStatefulSet, CoreHeadlessService, CoreSharedConfigMaps)
 	return &deploymentProvider{Provider: *p}, nil
 }
 
@@ -25,9 +36,30 @@ func (dp *deploymentProvider) EnvProvide() error {
 
 func (dp *deploymentProvider) Provide(app *crd.ClowdApp) error {
 
-	for _, deployment := range app.Spec.Deployments {
+	if len(app.Spec.Deployments) > 0 {
+		if err := dp.copySharedConfigMaps(app); err != nil {
+			return err
+		}
+	}
+
+	for _, innerDeployment := range app.Spec.Deployments {
+
+		deployment, warnings, err := provutils.ApplyReplicaGuardrails(dp.Env, &innerDeployment)
+		if err != nil {
+			return err
+		}
+		for _, warning := range warnings {
+			dp.Log.Info("Clamping replicas to the range allowed by the environment", "app", app.Name, "deployment", deployment.Name, "warning", warning)
+		}
+
+		if deployment.IsStatefulSet() {
+			if err := dp.makeStatefulSet(*deployment, app); err != nil {
+				return err
+			}
+			continue
+		}
 
-		if err := dp.makeDeployment(deployment, app); err != nil {
+		if err := dp.makeDeployment(*deployment, app); err != nil {
 			return err
 		}
 	}
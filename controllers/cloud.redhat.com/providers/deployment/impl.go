@@ -2,12 +2,18 @@ package deployment
 
 import (
 	"fmt"
+	"path"
+	"sort"
 	"strconv"
 	"strings"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
@@ -19,8 +25,92 @@ import (
 
 const (
 	TerminationLogPath = "/dev/termination-log"
+
+	// ConfigEnvVar is the env var holding the path of the mounted cdappconfig.json.
+	ConfigEnvVar = "ACG_CONFIG"
+
+	// PodNamespaceEnvVar is the env var exposing the pod's namespace via the downward API.
+	PodNamespaceEnvVar = "NAMESPACE"
+	// PodNameEnvVar is the env var exposing the pod's name via the downward API.
+	PodNameEnvVar = "POD_NAME"
+
+	// ConfigValidationContainerName is the name of the init container validating the app config.
+	ConfigValidationContainerName = "clowder-config-validation"
+	// ConfigValidationCommand runs the config validation of the Clowder manager binary.
+	ConfigValidationCommand = "validate-config"
+
+	// ConfigSecretVolumeName is the name of the volume holding the config secret.
+	ConfigSecretVolumeName = "config-secret"
+	// DefaultConfigMountPath is where the config secret is mounted unless the pod overrides it.
+	DefaultConfigMountPath = "/cdapp/"
+	// DefaultConfigFileName is the name of the config file, and the key of the config secret.
+	DefaultConfigFileName = "cdappconfig.json"
+
+	// CustomAnnotationsAnnotation lists the keys of the custom annotations applied to an object,
+	// so that they can be told apart from the Clowder managed ones on the next reconcile.
+	CustomAnnotationsAnnotation = "clowder/custom-annotations"
 )
 
+// ConfigMountPath returns the directory the config secret is mounted at in the pod.
+func ConfigMountPath(pod crd.PodSpec) string {
+	if pod.ConfigMountPath != "" {
+		return pod.ConfigMountPath
+	}
+	return DefaultConfigMountPath
+}
+
+// ConfigPath returns the path of the config file in the pod.
+func ConfigPath(pod crd.PodSpec) string {
+	fileName := DefaultConfigFileName
+	if pod.ConfigFileName != "" {
+		fileName = pod.ConfigFileName
+	}
+	return path.Join(ConfigMountPath(pod), fileName)
+}
+
+// ConfigSecretItems returns the items of the config secret volume. A renamed config file is
+// projected alongside cdappconfig.json, which Clowder's own containers sharing the volume read.
+func ConfigSecretItems(pod crd.PodSpec) []core.KeyToPath {
+	if pod.ConfigFileName == "" || pod.ConfigFileName == DefaultConfigFileName {
+		return nil
+	}
+	return []core.KeyToPath{
+		{Key: DefaultConfigFileName, Path: DefaultConfigFileName},
+		{Key: DefaultConfigFileName, Path: pod.ConfigFileName},
+	}
+}
+
+// pathsOverlap returns true if the cleaned paths are the same or one is nested in the other.
+func pathsOverlap(a, b string) bool {
+	return a == b || strings.HasPrefix(a, strings.TrimSuffix(b, "/")+"/") || strings.HasPrefix(b, strings.TrimSuffix(a, "/")+"/")
+}
+
+// ValidateConfigMount returns an error if the config secret mount of the container overlaps with
+// one of its other volume mounts.
+func ValidateConfigMount(c *core.Container) error {
+	configPath := ""
+	for _, mount := range c.VolumeMounts {
+		if mount.Name == ConfigSecretVolumeName {
+			configPath = path.Clean(mount.MountPath)
+		}
+	}
+	if configPath == "" {
+		return nil
+	}
+
+	for _, mount := range c.VolumeMounts {
+		if mount.Name == ConfigSecretVolumeName {
+			continue
+		}
+		if pathsOverlap(configPath, path.Clean(mount.MountPath)) {
+			return errors.NewClowderError(
+				fmt.Sprintf("container [%s] config mount path [%s] collides with volume mount [%s] at [%s]", c.Name, configPath, mount.Name, mount.MountPath),
+			)
+		}
+	}
+	return nil
+}
+
 func (dp *deploymentProvider) makeDeployment(deployment crd.Deployment, app *crd.ClowdApp) error {
 
 	d := &apps.Deployment{}
@@ -34,9 +124,110 @@ func (dp *deploymentProvider) makeDeployment(deployment crd.Deployment, app *crd
 		return err
 	}
 
+	if err := dp.setVolumeDeploymentStrategy(&deployment, d); err != nil {
+		return err
+	}
+
+	setMetadataResources(dp.Config, deployment.Name, d.Spec.Template.Spec.Containers[0].Resources)
+
 	return dp.Cache.Update(CoreDeployment, d)
 }
 
+// setMetadataResources exposes the resources computed for a deployment in its metadata in the
+// app config.
+func setMetadataResources(c *config.AppConfig, name string, resources core.ResourceRequirements) {
+	if c.Metadata == nil {
+		return
+	}
+	for i := range c.Metadata.Deployments {
+		if c.Metadata.Deployments[i].Name == name {
+			c.Metadata.Deployments[i].Resources = &config.DeploymentResources{
+				Limits:   metadataResourceValues(resources.Limits),
+				Requests: metadataResourceValues(resources.Requests),
+			}
+			return
+		}
+	}
+}
+
+// metadataResourceValues returns the cpu and memory of the resource list, values that are unset
+// are nil rather than zero and if neither is set the result is nil.
+func metadataResourceValues(list core.ResourceList) *config.ResourceValues {
+	values := &config.ResourceValues{}
+	if cpu, ok := list[core.ResourceCPU]; ok && !cpu.IsZero() {
+		values.Cpu = utils.StringPtr(cpu.String())
+	}
+	if memory, ok := list[core.ResourceMemory]; ok && !memory.IsZero() {
+		values.Memory = utils.StringPtr(memory.String())
+	}
+	if values.Cpu == nil && values.Memory == nil {
+		return nil
+	}
+	return values
+}
+
+// SharedConfigMapName returns the name of the copy of a shared configmap in an app namespace.
+func SharedConfigMapName(env *crd.ClowdEnvironment, name string) string {
+	return fmt.Sprintf("%s-%s-shared", env.Name, name)
+}
+
+func sharedConfigMapVolumeName(name string) string {
+	return fmt.Sprintf("shared-%s", name)
+}
+
+// copySharedConfigMaps copies the environment's shared configmaps into the app namespace so that
+// they can be mounted by the app deployments.
+func (dp *deploymentProvider) copySharedConfigMaps(app *crd.ClowdApp) error {
+	missing := []string{}
+
+	for _, sharedConfigMap := range dp.Env.Spec.SharedConfigMaps {
+		sourceNN := types.NamespacedName{
+			Name:      sharedConfigMap.Name,
+			Namespace: sharedConfigMap.Namespace,
+		}
+		if sourceNN.Namespace == "" {
+			sourceNN.Namespace = dp.Env.Status.TargetNamespace
+		}
+
+		source := &core.ConfigMap{}
+		if err := dp.GetShared(sourceNN, source); err != nil {
+			if k8serr.IsNotFound(err) {
+				missing = append(missing, sourceNN.String())
+				continue
+			}
+			return err
+		}
+
+		nn := types.NamespacedName{
+			Name:      SharedConfigMapName(dp.Env, sharedConfigMap.Name),
+			Namespace: app.Namespace,
+		}
+
+		cm := &core.ConfigMap{}
+		if err := dp.Cache.Create(CoreSharedConfigMaps, nn, cm); err != nil {
+			return err
+		}
+
+		cm.Data = source.Data
+		cm.BinaryData = source.BinaryData
+
+		labeler := utils.GetCustomLabeler(map[string]string{}, nn, dp.Env)
+		labeler(cm)
+
+		cm.Name = nn.Name
+		cm.Namespace = nn.Namespace
+
+		if err := dp.Cache.Update(CoreSharedConfigMaps, cm); err != nil {
+			return err
+		}
+	}
+
+	if len(missing) > 0 {
+		return &errors.MissingConfigMaps{MissingConfigMaps: missing}
+	}
+	return nil
+}
+
 func setLocalAnnotations(env *crd.ClowdEnvironment, deployment *crd.Deployment, d *apps.Deployment, app *crd.ClowdApp) {
 	if env.Spec.Providers.Web.Mode == "local" && (deployment.WebServices.Public.Enabled || bool(deployment.Web)) {
 		annotations := map[string]string{
@@ -63,6 +254,12 @@ func setMinReplicas(deployment *crd.Deployment, d *apps.Deployment) {
 		return
 	}
 
+	// A deployment that KEDA scaled to zero is left for KEDA to scale back up once its triggers
+	// become active again
+	if d.Spec.Replicas != nil && scalesToZero(deployment) {
+		return
+	}
+
 	// No sense in running all these conditionals if desired state and observed state match
 	if d.Spec.Replicas != nil && (*d.Spec.Replicas >= *replicaCount) {
 		return
@@ -77,32 +274,179 @@ func setMinReplicas(deployment *crd.Deployment, d *apps.Deployment) {
 
 }
 
+// capReplicas brings live replicas above the maxReplicas of the environment back down, these are
+// otherwise kept by setMinReplicas as they may have been set by an autoscaler.
+func capReplicas(env *crd.ClowdEnvironment, d *apps.Deployment) {
+	if env.Spec.MaxReplicas != nil && d.Spec.Replicas != nil && *d.Spec.Replicas > *env.Spec.MaxReplicas {
+		d.Spec.Replicas = utils.Int32Ptr(int(*env.Spec.MaxReplicas))
+	}
+}
+
+// scalesToZero returns true if the autoscaler of the deployment may scale it down to no replicas.
+func scalesToZero(deployment *crd.Deployment) bool {
+	return deployment.AutoScaler != nil && deployment.AutoScaler.MinReplicaCount != nil && *deployment.AutoScaler.MinReplicaCount == 0
+}
+
+// setDeploymentStrategy sets the strategy of the deployment. Public deployments keep the rolling
+// update set up by initDeployment, private ones use their privateStrategy and a type set on the
+// deployment overrides both. MaxSurge and MaxUnavailable are applied to rolling updates.
 func setDeploymentStrategy(deployment *crd.Deployment, d *apps.Deployment) {
-	if !deployment.WebServices.Public.Enabled {
-		if deployment.DeploymentStrategy != nil && deployment.DeploymentStrategy.PrivateStrategy != "" {
-			d.Spec.Strategy = apps.DeploymentStrategy{
-				Type: deployment.DeploymentStrategy.PrivateStrategy,
+	strategy := deployment.DeploymentStrategy
+	if strategy == nil {
+		strategy = &crd.DeploymentStrategy{}
+	}
+
+	switch {
+	case strategy.Type == apps.RecreateDeploymentStrategyType:
+		d.Spec.Strategy = apps.DeploymentStrategy{
+			Type: apps.RecreateDeploymentStrategyType,
+		}
+	case strategy.Type == apps.RollingUpdateDeploymentStrategyType:
+		d.Spec.Strategy = defaultRollingUpdate()
+	case !deployment.WebServices.Public.Enabled && strategy.PrivateStrategy != "":
+		d.Spec.Strategy = apps.DeploymentStrategy{
+			Type: strategy.PrivateStrategy,
+		}
+	case !deployment.WebServices.Public.Enabled:
+		d.Spec.Strategy = apps.DeploymentStrategy{
+			Type: apps.RollingUpdateDeploymentStrategyType,
+		}
+	}
+
+	if d.Spec.Strategy.Type != apps.RollingUpdateDeploymentStrategyType || (strategy.MaxSurge == nil && strategy.MaxUnavailable == nil) {
+		return
+	}
+
+	if d.Spec.Strategy.RollingUpdate == nil {
+		d.Spec.Strategy.RollingUpdate = &apps.RollingUpdateDeployment{}
+	}
+	if strategy.MaxSurge != nil {
+		maxSurge := *strategy.MaxSurge
+		d.Spec.Strategy.RollingUpdate.MaxSurge = &maxSurge
+	}
+	if strategy.MaxUnavailable != nil {
+		maxUnavailable := *strategy.MaxUnavailable
+		d.Spec.Strategy.RollingUpdate.MaxUnavailable = &maxUnavailable
+	}
+}
+
+func defaultRollingUpdate() apps.DeploymentStrategy {
+	return apps.DeploymentStrategy{
+		Type: apps.RollingUpdateDeploymentStrategyType,
+		RollingUpdate: &apps.RollingUpdateDeployment{
+			MaxSurge:       &intstr.IntOrString{Type: intstr.String, StrVal: string("25%")},
+			MaxUnavailable: &intstr.IntOrString{Type: intstr.String, StrVal: string("25%")},
+		},
+	}
+}
+
+// usesReadWriteOnceClaim returns true if the pod template mounts a PVC that can only be attached to
+// a single node. A claim that can't be found yet is assumed to be ReadWriteOnce, the most common
+// access mode.
+func (dp *deploymentProvider) usesReadWriteOnceClaim(t *core.PodTemplateSpec, namespace string) (bool, error) {
+	for _, vol := range t.Spec.Volumes {
+		if vol.PersistentVolumeClaim == nil {
+			continue
+		}
+
+		pvc := &core.PersistentVolumeClaim{}
+		nn := types.NamespacedName{Name: vol.PersistentVolumeClaim.ClaimName, Namespace: namespace}
+		if err := dp.Client.Get(dp.Ctx, nn, pvc); err != nil {
+			if k8serr.IsNotFound(err) {
+				return true, nil
 			}
-		} else {
-			d.Spec.Strategy = apps.DeploymentStrategy{
-				Type: apps.RollingUpdateDeploymentStrategyType,
+			return false, err
+		}
+
+		for _, mode := range pvc.Spec.AccessModes {
+			if mode == core.ReadWriteOnce || mode == core.ReadWriteOncePod {
+				return true, nil
 			}
 		}
 	}
+	return false, nil
 }
 
-func makeBaseProbe(env *crd.ClowdEnvironment) core.Probe {
-	return core.Probe{
-		ProbeHandler: core.ProbeHandler{
-			HTTPGet: &core.HTTPGetAction{
-				Path:   "/healthz",
-				Scheme: "HTTP",
-				Port: intstr.IntOrString{
-					Type:   intstr.Int,
-					IntVal: env.Spec.Providers.Web.Port,
-				},
+// setVolumeDeploymentStrategy switches a deployment with a ReadWriteOnce volume to the Recreate
+// strategy, whatever its number of replicas and whether it is autoscaled, as the new pods of a
+// rolling update can't attach the volume while the old ones hold it. An explicitly set strategy
+// type is left alone.
+func (dp *deploymentProvider) setVolumeDeploymentStrategy(deployment *crd.Deployment, d *apps.Deployment) error {
+	if deployment.DeploymentStrategy != nil && deployment.DeploymentStrategy.Type != "" {
+		return nil
+	}
+
+	readWriteOnce, err := dp.usesReadWriteOnceClaim(&d.Spec.Template, d.Namespace)
+	if err != nil || !readWriteOnce {
+		return err
+	}
+
+	d.Spec.Strategy = apps.DeploymentStrategy{
+		Type: apps.RecreateDeploymentStrategyType,
+	}
+	return nil
+}
+
+// validateSingleton returns an InvalidSingleton error listing every part of the deployment that
+// would have it run more than one replica.
+func validateSingleton(deployment *crd.Deployment) error {
+	if !deployment.Singleton {
+		return nil
+	}
+
+	problems := []string{}
+	if deployment.AutoScaler != nil {
+		problems = append(problems, "autoScaler is set")
+	}
+	if deployment.AutoScalerSimple != nil {
+		problems = append(problems, "autoScalerSimple is set")
+	}
+	if replicas := deployment.GetReplicaCount(); *replicas > 1 {
+		problems = append(problems, fmt.Sprintf("replicas is set to %d", *replicas))
+	}
+
+	if len(problems) > 0 {
+		return &errors.InvalidSingleton{Deployment: deployment.Name, Problems: problems}
+	}
+	return nil
+}
+
+// setSingleton pins a singleton deployment to a single replica and makes sure the old pod is gone
+// before the new one starts. A replica count of 0 is still honoured for manual scale down.
+func setSingleton(deployment *crd.Deployment, d *apps.Deployment) {
+	if !deployment.Singleton {
+		return
+	}
+
+	if *deployment.GetReplicaCount() != 0 {
+		d.Spec.Replicas = utils.Int32Ptr(1)
+	}
+	d.Spec.Strategy = apps.DeploymentStrategy{
+		Type: apps.RecreateDeploymentStrategyType,
+	}
+}
+
+// makeBaseProbe returns the default probe of a public web deployment, a GET of /healthz on the web
+// port, or the gRPC health check of the web port for deployments serving grpc.
+func makeBaseProbe(deployment *crd.Deployment, env *crd.ClowdEnvironment) core.Probe {
+	handler := core.ProbeHandler{
+		HTTPGet: &core.HTTPGetAction{
+			Path:   "/healthz",
+			Scheme: "HTTP",
+			Port: intstr.IntOrString{
+				Type:   intstr.Int,
+				IntVal: env.Spec.Providers.Web.Port,
 			},
 		},
+	}
+	if deployment.GetPublicProtocol() == "grpc" {
+		handler = core.ProbeHandler{
+			GRPC: &core.GRPCAction{Port: env.Spec.Providers.Web.Port},
+		}
+	}
+
+	return core.Probe{
+		ProbeHandler:        handler,
 		FailureThreshold:    3,
 		InitialDelaySeconds: 10,
 		PeriodSeconds:       30,
@@ -111,6 +455,98 @@ func makeBaseProbe(env *crd.ClowdEnvironment) core.Probe {
 	}
 }
 
+// reservedPortNames are the names of the container and service ports managed by Clowder.
+var reservedPortNames = []string{"web", "public", "private", "auth", "tls", "tls-private", "metrics"}
+
+// clowderPorts returns the ports that Clowder exposes on the container of the deployment, keyed by
+// name. The web port is only exposed when the public service is enabled, the private port when
+// the private service is, the metrics port always is.
+func clowderPorts(deployment *crd.Deployment, env *crd.ClowdEnvironment) map[string]int32 {
+	ports := map[string]int32{"metrics": env.Spec.Providers.Metrics.Port}
+	if bool(deployment.Web) || deployment.WebServices.Public.Enabled {
+		ports["web"] = env.Spec.Providers.Web.Port
+	}
+	if deployment.WebServices.Private.Enabled {
+		privatePort := env.Spec.Providers.Web.PrivatePort
+		if privatePort == 0 {
+			privatePort = 10000
+		}
+		ports["private"] = privatePort
+	}
+	return ports
+}
+
+// validateExtraPorts makes sure that the extra ports of the deployment share neither a name nor a
+// port number with each other or with the ports managed by Clowder.
+func validateExtraPorts(deployment *crd.Deployment, env *crd.ClowdEnvironment) error {
+	ports := clowderPorts(deployment, env)
+	problems := []string{}
+
+	for _, port := range deployment.ExtraPorts {
+		if utils.Contains(reservedPortNames, port.Name) {
+			problems = append(problems, fmt.Sprintf("name %s is reserved", port.Name))
+			continue
+		}
+		if _, ok := ports[port.Name]; ok {
+			problems = append(problems, fmt.Sprintf("name %s is used more than once", port.Name))
+			continue
+		}
+		for name, number := range ports {
+			if number == port.ContainerPort {
+				problems = append(problems, fmt.Sprintf("port %d of %s is already used by %s", number, port.Name, name))
+			}
+		}
+		ports[port.Name] = port.ContainerPort
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.NewClowderError(fmt.Sprintf("Deployment [%s] has conflicting extra ports: [%s]", deployment.Name, strings.Join(problems, "; ")))
+	}
+	return nil
+}
+
+// validateProbePorts makes sure that the httpGet and grpc probes supplied in the PodSpec point at a
+// port that the container exposes, either by number or by name.
+func validateProbePorts(pod *crd.PodSpec, deployment *crd.Deployment, env *crd.ClowdEnvironment) error {
+	ports := clowderPorts(deployment, env)
+	for _, port := range deployment.ExtraPorts {
+		ports[port.Name] = port.ContainerPort
+	}
+
+	probes := []struct {
+		name  string
+		probe *core.Probe
+	}{
+		{"liveness", pod.LivenessProbe},
+		{"readiness", pod.ReadinessProbe},
+	}
+
+	for _, p := range probes {
+		if p.probe == nil || (p.probe.HTTPGet == nil && p.probe.GRPC == nil) {
+			continue
+		}
+
+		var port intstr.IntOrString
+		if p.probe.HTTPGet != nil {
+			port = p.probe.HTTPGet.Port
+		} else {
+			port = intstr.FromInt(int(p.probe.GRPC.Port))
+		}
+		found := false
+		for name, number := range ports {
+			if (port.Type == intstr.Int && port.IntVal == number) || (port.Type == intstr.String && port.StrVal == name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &errors.InvalidProbePort{Deployment: deployment.Name, Probe: p.name, Port: port.String()}
+		}
+	}
+	return nil
+}
+
 func setLivenessProbe(pod *crd.PodSpec, deployment *crd.Deployment, env *crd.ClowdEnvironment, c *core.Container) {
 	livenessProbe := core.Probe{}
 
@@ -131,7 +567,7 @@ func setLivenessProbe(pod *crd.PodSpec, deployment *crd.Deployment, env *crd.Clo
 		}
 		c.LivenessProbe = &livenessProbe
 	} else if bool(deployment.Web) || deployment.WebServices.Public.Enabled {
-		livenessProbe = makeBaseProbe(env)
+		livenessProbe = makeBaseProbe(deployment, env)
 		c.LivenessProbe = &livenessProbe
 	}
 }
@@ -156,7 +592,7 @@ func setReadinessProbe(pod *crd.PodSpec, deployment *crd.Deployment, env *crd.Cl
 
 		c.ReadinessProbe = &readinessProbe
 	} else if bool(deployment.Web) || deployment.WebServices.Public.Enabled {
-		readinessProbe := makeBaseProbe(env)
+		readinessProbe := makeBaseProbe(deployment, env)
 		readinessProbe.InitialDelaySeconds = 45
 		c.ReadinessProbe = &readinessProbe
 	}
@@ -180,24 +616,79 @@ func setImagePullPolicy(env *crd.ClowdEnvironment, c *core.Container) {
 
 }
 
-func loadEnvVars(pod crd.PodSpec) []core.EnvVar {
-	envvars := pod.Env
-	envvars = append(envvars, core.EnvVar{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"})
+// LoadEnvVars returns the env of the container of the pod, the env vars of the pod followed by
+// ACG_CONFIG. Clowder's ACG_CONFIG always points at the mounted config, so an ACG_CONFIG set on the
+// pod is dropped.
+func LoadEnvVars(pod crd.PodSpec) []core.EnvVar {
+	envvars := []core.EnvVar{}
 
-	for _, envvar := range envvars {
-		if envvar.ValueFrom != nil {
-			if envvar.ValueFrom.FieldRef != nil {
-				if envvar.ValueFrom.FieldRef.APIVersion == "" {
-					envvar.ValueFrom.FieldRef.APIVersion = "v1"
-				}
-			}
+	for _, envvar := range pod.Env {
+		if envvar.Name == ConfigEnvVar {
+			continue
 		}
+
+		innerEnvVar := envvar.DeepCopy()
+		if innerEnvVar.ValueFrom != nil && innerEnvVar.ValueFrom.FieldRef != nil && innerEnvVar.ValueFrom.FieldRef.APIVersion == "" {
+			innerEnvVar.ValueFrom.FieldRef.APIVersion = "v1"
+		}
+		envvars = append(envvars, *innerEnvVar)
+	}
+
+	return append(envvars, core.EnvVar{Name: ConfigEnvVar, Value: ConfigPath(pod)})
+}
+
+// insertAfterConfigEnvVar inserts the env vars straight after ACG_CONFIG, or first when it is
+// missing.
+func insertAfterConfigEnvVar(envvars []core.EnvVar, inserted []core.EnvVar) []core.EnvVar {
+	at := 0
+	for i, envvar := range envvars {
+		if envvar.Name == ConfigEnvVar {
+			at = i + 1
+			break
+		}
+	}
+	result := append([]core.EnvVar{}, envvars[:at]...)
+	result = append(result, inserted...)
+	return append(result, envvars[at:]...)
+}
+
+func podIdentityEnvVars() []core.EnvVar {
+	return []core.EnvVar{{
+		Name: PodNamespaceEnvVar,
+		ValueFrom: &core.EnvVarSource{
+			FieldRef: &core.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.namespace"},
+		},
+	}, {
+		Name: PodNameEnvVar,
+		ValueFrom: &core.EnvVarSource{
+			FieldRef: &core.ObjectFieldSelector{APIVersion: "v1", FieldPath: "metadata.name"},
+		},
+	}}
+}
+
+// checkPodIdentityEnvVars rejects user supplied env vars that would collide with the pod
+// identity env vars injected by Clowder.
+func checkPodIdentityEnvVars(deployment *crd.Deployment) error {
+	envvars := append([]core.EnvVar{}, deployment.PodSpec.Env...)
+	for _, ic := range deployment.PodSpec.InitContainers {
+		envvars = append(envvars, ic.Env...)
 	}
 
-	return envvars
+	for _, envvar := range envvars {
+		if envvar.Name == PodNamespaceEnvVar || envvar.Name == PodNameEnvVar {
+			return errors.NewClowderError(
+				fmt.Sprintf("deployment [%s] env var [%s] collides with an env var injected by Clowder", deployment.Name, envvar.Name),
+			)
+		}
+	}
+	return nil
 }
 
 func initDeployment(app *crd.ClowdApp, env *crd.ClowdEnvironment, d *apps.Deployment, nn types.NamespacedName, deployment *crd.Deployment) error {
+	if err := validateSingleton(deployment); err != nil {
+		return err
+	}
+
 	labels := app.GetLabels()
 	labels["pod"] = nn.Name
 	app.SetObjectMeta(d, crd.Name(nn.Name), crd.Labels(labels))
@@ -208,47 +699,81 @@ func initDeployment(app *crd.ClowdApp, env *crd.ClowdEnvironment, d *apps.Deploy
 
 	utils.UpdateAnnotations(d, app.ObjectMeta.Annotations, deployment.Metadata.Annotations)
 
+	// Pod annotations go on first so that the Clowder managed annotations win
+	utils.UpdateAnnotations(&d.Spec.Template, deployment.PodAnnotations)
+
 	setLocalAnnotations(env, deployment, d, app)
 
 	setMinReplicas(deployment, d)
 
+	capReplicas(env, d)
+
 	d.Spec.Selector = &metav1.LabelSelector{MatchLabels: labels}
 	d.Spec.Template.ObjectMeta.Labels = labels
-	d.Spec.Strategy = apps.DeploymentStrategy{
-		Type: apps.RollingUpdateDeploymentStrategyType,
-		RollingUpdate: &apps.RollingUpdateDeployment{
-			MaxSurge:       &intstr.IntOrString{Type: intstr.String, StrVal: string("25%")},
-			MaxUnavailable: &intstr.IntOrString{Type: intstr.String, StrVal: string("25%")},
-		},
-	}
+	d.Spec.Strategy = defaultRollingUpdate()
 	d.Spec.ProgressDeadlineSeconds = utils.Int32Ptr(600)
 
 	utils.UpdateAnnotations(&d.Spec.Template, pod.Metadata.Annotations)
 
 	setDeploymentStrategy(deployment, d)
 
+	setSingleton(deployment, d)
+
+	resources, err := ProcessDeploymentResources(deployment, env)
+	if err != nil {
+		return err
+	}
+
 	c := core.Container{
 		Name:                     nn.Name,
 		Image:                    pod.Image,
 		Command:                  pod.Command,
 		Args:                     pod.Args,
-		Env:                      loadEnvVars(pod),
-		Resources:                ProcessResources(&pod, env),
+		Env:                      LoadEnvVars(pod),
+		Resources:                resources,
 		VolumeMounts:             pod.VolumeMounts,
 		TerminationMessagePath:   TerminationLogPath,
 		TerminationMessagePolicy: core.TerminationMessageReadFile,
 		ImagePullPolicy:          core.PullIfNotPresent,
 	}
 
+	injectPodIdentity := !env.Spec.Providers.Deployment.OmitPodIdentityEnv
+	if injectPodIdentity {
+		if err := checkPodIdentityEnvVars(deployment); err != nil {
+			return err
+		}
+		c.Env = append(c.Env, podIdentityEnvVars()...)
+	}
+
+	if err := validateExtraPorts(deployment, env); err != nil {
+		return err
+	}
+
+	if err := validateProbePorts(&pod, deployment, env); err != nil {
+		return err
+	}
+
 	setLivenessProbe(&pod, deployment, env, &c)
 	setReadinessProbe(&pod, deployment, env, &c)
 	setImagePullPolicy(env, &c)
 
 	c.VolumeMounts = append(c.VolumeMounts, core.VolumeMount{
-		Name:      "config-secret",
-		MountPath: "/cdapp/",
+		Name:      ConfigSecretVolumeName,
+		MountPath: ConfigMountPath(pod),
 	})
 
+	for _, sharedConfigMap := range env.Spec.SharedConfigMaps {
+		c.VolumeMounts = append(c.VolumeMounts, core.VolumeMount{
+			Name:      sharedConfigMapVolumeName(sharedConfigMap.Name),
+			MountPath: sharedConfigMap.MountPath,
+			ReadOnly:  true,
+		})
+	}
+
+	if err := ValidateConfigMount(&c); err != nil {
+		return err
+	}
+
 	d.Spec.Template.Spec.Containers = []core.Container{c}
 
 	ics, err := ProcessInitContainers(nn, &c, pod.InitContainers)
@@ -257,49 +782,238 @@ func initDeployment(app *crd.ClowdApp, env *crd.ClowdEnvironment, d *apps.Deploy
 		return err
 	}
 
-	if pod.MachinePool != "" {
-		d.Spec.Template.Spec.Tolerations = []core.Toleration{{
-			Key:      pod.MachinePool,
-			Effect:   core.TaintEffectNoSchedule,
-			Operator: core.TolerationOpEqual,
-			Value:    "true",
-		}}
-	} else {
-		d.Spec.Template.Spec.Tolerations = []core.Toleration{}
+	// Init containers inheriting the env already carry the pod identity env vars, the
+	// others get them straight after ACG_CONFIG.
+	if injectPodIdentity {
+		for i, ic := range pod.InitContainers {
+			if ic.InheritEnv {
+				continue
+			}
+			ics[i].Env = insertAfterConfigEnvVar(ics[i].Env, podIdentityEnvVars())
+		}
+	}
+
+	SetScheduling(&d.Spec.Template, &pod, env)
+	SetImagePullSecrets(&d.Spec.Template, env)
+
+	d.Spec.Template.Spec.AutomountServiceAccountToken = pod.AutomountServiceAccountToken
+
+	// The config is validated before any of the app's own init containers run
+	if env.Spec.Providers.Deployment.ConfigValidation.Enabled {
+		validator, err := makeConfigValidationContainer(env)
+		if err != nil {
+			return err
+		}
+		ics = append([]core.Container{validator}, ics...)
 	}
 
 	d.Spec.Template.Spec.InitContainers = ics
 
 	d.Spec.Template.Spec.Volumes = pod.Volumes
 	d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, core.Volume{
-		Name: "config-secret",
+		Name: ConfigSecretVolumeName,
 		VolumeSource: core.VolumeSource{
 			Secret: &core.SecretVolumeSource{
 				DefaultMode: utils.Int32Ptr(420),
 				SecretName:  app.ObjectMeta.Name,
+				Items:       ConfigSecretItems(pod),
 			},
 		},
 	})
 
+	for _, sharedConfigMap := range env.Spec.SharedConfigMaps {
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, core.Volume{
+			Name: sharedConfigMapVolumeName(sharedConfigMap.Name),
+			VolumeSource: core.VolumeSource{
+				ConfigMap: &core.ConfigMapVolumeSource{
+					LocalObjectReference: core.LocalObjectReference{
+						Name: SharedConfigMapName(env, sharedConfigMap.Name),
+					},
+					DefaultMode: utils.Int32Ptr(420),
+				},
+			},
+		})
+	}
+
 	for _, vol := range d.Spec.Template.Spec.Volumes {
 		v := vol
-		setRecreateDeploymentStrategyForPVCs(vol, d)
 		setVolumeSourceConfigMapDefaultMode(&v)
 		setVolumeSourceSecretDefaultMode(&v)
 	}
 
-	ApplyPodAntiAffinity(&d.Spec.Template)
+	applyDeploymentAntiAffinity(&d.Spec.Template, deployment.AntiAffinity)
+
+	setProvenanceLabels(&d.Spec.Template, pod.ProvenanceLabels)
+
+	SetCustomMetadata(d, env, deployment)
+	SetCustomMetadata(&d.Spec.Template, env, deployment)
 
 	return nil
 }
 
-func setRecreateDeploymentStrategyForPVCs(vol core.Volume, d *apps.Deployment) {
-	if vol.VolumeSource.PersistentVolumeClaim == nil {
+// SetImagePullSecrets references the image pull secrets of the environment from the pod template.
+func SetImagePullSecrets(t *core.PodTemplateSpec, env *crd.ClowdEnvironment) {
+	if len(env.Spec.ImagePullSecrets) == 0 {
+		t.Spec.ImagePullSecrets = nil
 		return
 	}
-	d.Spec.Strategy = apps.DeploymentStrategy{
-		Type: apps.RecreateDeploymentStrategyType,
+
+	secrets := []core.LocalObjectReference{}
+	for _, name := range env.Spec.ImagePullSecrets {
+		secrets = append(secrets, core.LocalObjectReference{Name: name})
 	}
+	t.Spec.ImagePullSecrets = secrets
+}
+
+// SetScheduling sets the tolerations and node selector of the pod template. The tolerations of the
+// environment come first, followed by the machine pool toleration and the tolerations of the pod,
+// duplicates are dropped. The node selector of the pod is merged into that of the environment,
+// the pod's entries taking precedence.
+func SetScheduling(t *core.PodTemplateSpec, pod *crd.PodSpec, env *crd.ClowdEnvironment) {
+	tolerations := append([]core.Toleration{}, env.Spec.Providers.Deployment.Tolerations...)
+
+	if pod.MachinePool != "" {
+		tolerations = append(tolerations, core.Toleration{
+			Key:      pod.MachinePool,
+			Effect:   core.TaintEffectNoSchedule,
+			Operator: core.TolerationOpEqual,
+			Value:    "true",
+		})
+	}
+
+	for _, toleration := range pod.Tolerations {
+		duplicate := false
+		for _, existing := range tolerations {
+			if equality.Semantic.DeepEqual(existing, toleration) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			tolerations = append(tolerations, toleration)
+		}
+	}
+
+	t.Spec.Tolerations = tolerations
+
+	if len(env.Spec.Providers.Deployment.NodeSelector) == 0 && len(pod.NodeSelector) == 0 {
+		t.Spec.NodeSelector = nil
+		return
+	}
+
+	nodeSelector := map[string]string{}
+	for k, v := range env.Spec.Providers.Deployment.NodeSelector {
+		nodeSelector[k] = v
+	}
+	for k, v := range pod.NodeSelector {
+		nodeSelector[k] = v
+	}
+	t.Spec.NodeSelector = nodeSelector
+}
+
+// setProvenanceLabels adds the provenance labels to the pod template, the Clowder managed labels
+// take precedence. The template gets a new map so that the selectors sharing the Clowder labels,
+// like those of the deployment and its anti affinity rules, don't pick up the provenance labels.
+func setProvenanceLabels(t *core.PodTemplateSpec, provenanceLabels map[string]string) {
+	if len(provenanceLabels) == 0 {
+		return
+	}
+	labels := t.Labels
+	t.Labels = map[string]string{}
+	utils.UpdateLabels(t, provenanceLabels, labels)
+}
+
+// SetCustomMetadata adds the custom labels and annotations of the environment and the deployment
+// to an object generated for the deployment. Those of the deployment take precedence over those of
+// the environment and the Clowder managed ones, like the app and pod labels, over both. The object
+// gets new maps so that the selectors sharing the Clowder labels don't pick up the custom labels.
+//
+// The labels of the object are rebuilt on every reconcile, but its annotations are kept, so the
+// custom annotations applied by the previous reconcile, listed in CustomAnnotationsAnnotation, are
+// dropped before the current ones are applied. A changed or removed entry of the environment or
+// the deployment is therefore picked up.
+func SetCustomMetadata(o metav1.Object, env *crd.ClowdEnvironment, deployment *crd.Deployment) {
+	if len(env.Spec.Labels) > 0 || len(deployment.Labels) > 0 {
+		labels := o.GetLabels()
+		o.SetLabels(map[string]string{})
+		utils.UpdateLabels(o, env.Spec.Labels, deployment.Labels, labels)
+	}
+
+	previous, ok := o.GetAnnotations()[CustomAnnotationsAnnotation]
+	if !ok && len(env.Spec.Annotations) == 0 && len(deployment.Annotations) == 0 {
+		return
+	}
+
+	annotations := map[string]string{}
+	for k, v := range o.GetAnnotations() {
+		annotations[k] = v
+	}
+	for _, key := range strings.Split(previous, ",") {
+		delete(annotations, key)
+	}
+	delete(annotations, CustomAnnotationsAnnotation)
+
+	custom := map[string]string{}
+	for k, v := range env.Spec.Annotations {
+		custom[k] = v
+	}
+	for k, v := range deployment.Annotations {
+		custom[k] = v
+	}
+
+	applied := []string{}
+	for k, v := range custom {
+		if _, managed := annotations[k]; managed {
+			continue
+		}
+		annotations[k] = v
+		applied = append(applied, k)
+	}
+	if len(applied) > 0 {
+		sort.Strings(applied)
+		annotations[CustomAnnotationsAnnotation] = strings.Join(applied, ",")
+	}
+	o.SetAnnotations(annotations)
+}
+
+// makeConfigValidationContainer returns an init container that runs the validate-config command of
+// the Clowder manager, which validates the mounted cdappconfig.json and exits non-zero if it does
+// not match the Clowder config schema.
+func makeConfigValidationContainer(env *crd.ClowdEnvironment) (core.Container, error) {
+	image := provutils.GetConfigValidatorImage(env)
+	if image == "" {
+		return core.Container{}, errors.NewClowderError(
+			"config validation is enabled but no validator image is set in the environment or the Clowder config",
+		)
+	}
+
+	c := core.Container{
+		Name:    ConfigValidationContainerName,
+		Image:   image,
+		Command: []string{"/manager", ConfigValidationCommand},
+		Env: []core.EnvVar{
+			{Name: ConfigEnvVar, Value: path.Join(DefaultConfigMountPath, DefaultConfigFileName)},
+		},
+		Resources: core.ResourceRequirements{
+			Limits: core.ResourceList{
+				core.ResourceCPU:    resource.MustParse("100m"),
+				core.ResourceMemory: resource.MustParse("64Mi"),
+			},
+			Requests: core.ResourceList{
+				core.ResourceCPU:    resource.MustParse("10m"),
+				core.ResourceMemory: resource.MustParse("16Mi"),
+			},
+		},
+		VolumeMounts: []core.VolumeMount{{
+			Name:      ConfigSecretVolumeName,
+			MountPath: DefaultConfigMountPath,
+			ReadOnly:  true,
+		}},
+		TerminationMessagePath:   TerminationLogPath,
+		TerminationMessagePolicy: core.TerminationMessageFallbackToLogsOnError,
+	}
+	setImagePullPolicy(env, &c)
+	return c, nil
 }
 
 func setVolumeSourceConfigMapDefaultMode(vol *core.Volume) {
@@ -349,7 +1063,7 @@ func ProcessInitContainers(nn types.NamespacedName, c *core.Container, ics []crd
 			Command:                  ic.Command,
 			Args:                     ic.Args,
 			Resources:                c.Resources,
-			VolumeMounts:             c.VolumeMounts,
+			VolumeMounts:             initContainerVolumeMounts(c, ic),
 			ImagePullPolicy:          c.ImagePullPolicy,
 			TerminationMessagePath:   TerminationLogPath,
 			TerminationMessagePolicy: core.TerminationMessageReadFile,
@@ -381,7 +1095,7 @@ func ProcessInitContainers(nn types.NamespacedName, c *core.Container, ics []crd
 		} else {
 
 			icStruct.Env = append(
-				icStruct.Env, core.EnvVar{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"},
+				icStruct.Env, core.EnvVar{Name: ConfigEnvVar, Value: configPathFromEnv(c)},
 			)
 
 			for _, envvar := range ic.Env {
@@ -398,6 +1112,32 @@ func ProcessInitContainers(nn types.NamespacedName, c *core.Container, ics []crd
 	return containerList, nil
 }
 
+// configPathFromEnv returns the config path the container was given in ACG_CONFIG.
+func configPathFromEnv(c *core.Container) string {
+	for _, envvar := range c.Env {
+		if envvar.Name == ConfigEnvVar {
+			return envvar.Value
+		}
+	}
+	return path.Join(DefaultConfigMountPath, DefaultConfigFileName)
+}
+
+// initContainerVolumeMounts returns the volume mounts of the parent container that the init
+// container inherits, an init container that doesn't inherit them still gets the config secret.
+func initContainerVolumeMounts(c *core.Container, ic crd.InitContainer) []core.VolumeMount {
+	if ic.InheritVolumeMounts == nil || *ic.InheritVolumeMounts {
+		return c.VolumeMounts
+	}
+
+	mounts := []core.VolumeMount{}
+	for _, mount := range c.VolumeMounts {
+		if mount.Name == ConfigSecretVolumeName {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
 // ApplyPodAntiAffinity applies pod anti affinity rules to a pod template
 func ApplyPodAntiAffinity(t *core.PodTemplateSpec) {
 	labelSelector := &metav1.LabelSelector{MatchLabels: t.Labels}
@@ -418,9 +1158,78 @@ func ApplyPodAntiAffinity(t *core.PodTemplateSpec) {
 	}}
 }
 
+// applyDeploymentAntiAffinity applies the anti affinity configured on the deployment to its pod
+// template, deployments that don't configure one get the rules of ApplyPodAntiAffinity.
+func applyDeploymentAntiAffinity(t *core.PodTemplateSpec, antiAffinity *crd.AntiAffinity) {
+	if antiAffinity == nil {
+		ApplyPodAntiAffinity(t)
+		return
+	}
+
+	switch antiAffinity.Mode {
+	case "none":
+		t.Spec.Affinity = nil
+	case "required":
+		topologyKey := antiAffinity.TopologyKey
+		if topologyKey == "" {
+			topologyKey = "kubernetes.io/hostname"
+		}
+		t.Spec.Affinity = &core.Affinity{PodAntiAffinity: &core.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []core.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{MatchLabels: t.Labels},
+				TopologyKey:   topologyKey,
+			}},
+		}}
+	default:
+		ApplyPodAntiAffinity(t)
+	}
+}
+
+// ProcessDeploymentResources returns the resource requirements for a deployment. If the deployment
+// references a resource preset, the preset is used in place of the environment's resource defaults.
+func ProcessDeploymentResources(deployment *crd.Deployment, env *crd.ClowdEnvironment) (core.ResourceRequirements, error) {
+	if deployment.ResourcePreset == "" {
+		defaults, err := matchResourceDefaults(deployment, env)
+		if err != nil {
+			return core.ResourceRequirements{}, err
+		}
+		return processResources(&deployment.PodSpec, defaults), nil
+	}
+
+	preset, ok := env.Spec.ResourcePresets[deployment.ResourcePreset]
+	if !ok {
+		return core.ResourceRequirements{}, errors.NewClowderError(
+			fmt.Sprintf("deployment [%s] requests unknown resource preset [%s]", deployment.Name, deployment.ResourcePreset),
+		)
+	}
+
+	return processResources(&deployment.PodSpec, preset), nil
+}
+
+// matchResourceDefaults returns the resources of the first default rule matching the deployment
+// name, or the environment's resource defaults if none match.
+func matchResourceDefaults(deployment *crd.Deployment, env *crd.ClowdEnvironment) (core.ResourceRequirements, error) {
+	for _, rule := range env.Spec.ResourceDefaultRules {
+		matched, err := path.Match(rule.Pattern, deployment.Name)
+		if err != nil {
+			return core.ResourceRequirements{}, errors.Wrap(
+				fmt.Sprintf("invalid resource default rule pattern [%s]", rule.Pattern), err,
+			)
+		}
+		if matched {
+			return rule.Resources, nil
+		}
+	}
+	return env.Spec.ResourceDefaults, nil
+}
+
 // ProcessResources takes a pod spec and a clowd environment and returns the resource requirements
 // object.
 func ProcessResources(pod *crd.PodSpec, env *crd.ClowdEnvironment) core.ResourceRequirements {
+	return processResources(pod, env.Spec.ResourceDefaults)
+}
+
+func processResources(pod *crd.PodSpec, defaults core.ResourceRequirements) core.ResourceRequirements {
 	var lcpu, lmemory, rcpu, rmemory resource.Quantity
 	nullCPU := resource.Quantity{Format: resource.DecimalSI}
 	nullMemory := resource.Quantity{Format: resource.BinarySI}
@@ -428,25 +1237,25 @@ func ProcessResources(pod *crd.PodSpec, env *crd.ClowdEnvironment) core.Resource
 	if *pod.Resources.Limits.Cpu() != nullCPU {
 		lcpu = pod.Resources.Limits["cpu"]
 	} else {
-		lcpu = env.Spec.ResourceDefaults.Limits["cpu"]
+		lcpu = defaults.Limits["cpu"]
 	}
 
 	if *pod.Resources.Limits.Memory() != nullMemory {
 		lmemory = pod.Resources.Limits["memory"]
 	} else {
-		lmemory = env.Spec.ResourceDefaults.Limits["memory"]
+		lmemory = defaults.Limits["memory"]
 	}
 
 	if *pod.Resources.Requests.Cpu() != nullCPU {
 		rcpu = pod.Resources.Requests["cpu"]
 	} else {
-		rcpu = env.Spec.ResourceDefaults.Requests["cpu"]
+		rcpu = defaults.Requests["cpu"]
 	}
 
 	if *pod.Resources.Requests.Memory() != nullMemory {
 		rmemory = pod.Resources.Requests["memory"]
 	} else {
-		rmemory = env.Spec.ResourceDefaults.Requests["memory"]
+		rmemory = defaults.Requests["memory"]
 	}
 
 	return core.ResourceRequirements{
@@ -0,0 +1,877 @@
+package deployment
+
+import (
+	"encoding/json"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func presetTestEnv() *crd.ClowdEnvironment {
+	return &crd.ClowdEnvironment{
+		Spec: crd.ClowdEnvironmentSpec{
+			ResourceDefaults: createResourceRequirements(Params{
+				"limits":   {"cpu": "300m", "memory": "1024Mi"},
+				"requests": {"cpu": "30m", "memory": "512Mi"},
+			}),
+			ResourcePresets: map[string]core.ResourceRequirements{
+				"medium": createResourceRequirements(Params{
+					"limits":   {"cpu": "1", "memory": "2Gi"},
+					"requests": {"cpu": "500m", "memory": "1Gi"},
+				}),
+			},
+		},
+	}
+}
+
+func presetTestApp(preset string, params Params) *crd.ClowdApp {
+	return &crd.ClowdApp{
+		ObjectMeta: defaultMetaObject(),
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{{
+				Name:           "reqapp",
+				ResourcePreset: preset,
+				PodSpec: crd.PodSpec{
+					Image:     "test:test",
+					Resources: createResourceRequirements(params),
+				},
+			}},
+		},
+	}
+}
+
+func TestResourcePreset(t *testing.T) {
+	env := presetTestEnv()
+	app := presetTestApp("medium", Params{})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	res := d.Spec.Template.Spec.Containers[0].Resources
+	assert.Equal(t, resource.MustParse("1"), res.Limits["cpu"])
+	assert.Equal(t, resource.MustParse("2Gi"), res.Limits["memory"])
+	assert.Equal(t, resource.MustParse("500m"), res.Requests["cpu"])
+	assert.Equal(t, resource.MustParse("1Gi"), res.Requests["memory"])
+}
+
+func TestResourcePresetExplicitOverride(t *testing.T) {
+	env := presetTestEnv()
+	app := presetTestApp("medium", Params{
+		"limits": {"memory": "4Gi"},
+	})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	res := d.Spec.Template.Spec.Containers[0].Resources
+	assert.Equal(t, resource.MustParse("1"), res.Limits["cpu"])
+	assert.Equal(t, resource.MustParse("4Gi"), res.Limits["memory"])
+}
+
+func TestMetadataResources(t *testing.T) {
+	env := presetTestEnv()
+	app := presetTestApp("", Params{
+		"limits": {"memory": "4Gi"},
+	})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	c := &config.AppConfig{Metadata: &config.AppMetadata{
+		Deployments: []config.DeploymentMetadata{{Name: "reqapp", Image: "test:test"}},
+	}}
+	setMetadataResources(c, deployment.Name, d.Spec.Template.Spec.Containers[0].Resources)
+
+	// the override is merged with the environment's defaults
+	res := c.Metadata.Deployments[0].Resources
+	assert.Equal(t, &config.ResourceValues{Cpu: utils.StringPtr("300m"), Memory: utils.StringPtr("4Gi")}, res.Limits)
+	assert.Equal(t, &config.ResourceValues{Cpu: utils.StringPtr("30m"), Memory: utils.StringPtr("512Mi")}, res.Requests)
+}
+
+func TestMetadataResourcesNoLimits(t *testing.T) {
+	app := presetTestApp("", Params{
+		"requests": {"cpu": "100m"},
+	})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, &crd.ClowdEnvironment{}, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	c := &config.AppConfig{Metadata: &config.AppMetadata{
+		Deployments: []config.DeploymentMetadata{{Name: "reqapp", Image: "test:test"}},
+	}}
+	setMetadataResources(c, deployment.Name, d.Spec.Template.Spec.Containers[0].Resources)
+
+	jsonData, err := json.Marshal(c.Metadata.Deployments[0].Resources)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"limits": null, "requests": {"cpu": "100m", "memory": null}}`, string(jsonData))
+}
+
+func TestResourcePresetUnknown(t *testing.T) {
+	env := presetTestEnv()
+	app := presetTestApp("enormous", Params{})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.ErrorContains(t, err, "unknown resource preset [enormous]")
+}
+
+func TestResourceDefaultRules(t *testing.T) {
+	env := presetTestEnv()
+	env.Spec.ResourceDefaultRules = []crd.ResourceDefaultRule{{
+		Pattern: "*-worker",
+		Resources: createResourceRequirements(Params{
+			"limits":   {"cpu": "2", "memory": "4Gi"},
+			"requests": {"cpu": "1", "memory": "2Gi"},
+		}),
+	}, {
+		Pattern: "web*",
+		Resources: createResourceRequirements(Params{
+			"limits":   {"cpu": "500m", "memory": "512Mi"},
+			"requests": {"cpu": "100m", "memory": "256Mi"},
+		}),
+	}, {
+		// never reached for the worker, the first matching rule wins
+		Pattern: "*",
+		Resources: createResourceRequirements(Params{
+			"limits": {"cpu": "8", "memory": "16Gi"},
+		}),
+	}}
+
+	app := presetTestApp("", Params{})
+	app.Spec.Deployments[0].Name = "kafka-worker"
+	app.Spec.Deployments = append(app.Spec.Deployments, crd.Deployment{
+		Name: "web-api",
+		PodSpec: crd.PodSpec{
+			Image: "test:test",
+			Resources: createResourceRequirements(Params{
+				"limits": {"memory": "1Gi"},
+			}),
+		},
+	})
+
+	worker, err := ProcessDeploymentResources(&app.Spec.Deployments[0], env)
+	assert.NoError(t, err)
+	assert.Equal(t, resource.MustParse("2"), worker.Limits["cpu"])
+	assert.Equal(t, resource.MustParse("4Gi"), worker.Limits["memory"])
+	assert.Equal(t, resource.MustParse("1"), worker.Requests["cpu"])
+	assert.Equal(t, resource.MustParse("2Gi"), worker.Requests["memory"])
+
+	web, err := ProcessDeploymentResources(&app.Spec.Deployments[1], env)
+	assert.NoError(t, err)
+	assert.Equal(t, resource.MustParse("500m"), web.Limits["cpu"])
+	assert.Equal(t, resource.MustParse("1Gi"), web.Limits["memory"], "explicit resources were overridden")
+	assert.Equal(t, resource.MustParse("100m"), web.Requests["cpu"])
+	assert.Equal(t, resource.MustParse("256Mi"), web.Requests["memory"])
+}
+
+func TestResourceDefaultRulesNoMatch(t *testing.T) {
+	env := presetTestEnv()
+	env.Spec.ResourceDefaultRules = []crd.ResourceDefaultRule{{
+		Pattern:   "*-worker",
+		Resources: createResourceRequirements(Params{"limits": {"cpu": "2"}}),
+	}}
+
+	app := presetTestApp("", Params{})
+
+	res, err := ProcessDeploymentResources(&app.Spec.Deployments[0], env)
+	assert.NoError(t, err)
+	assert.Equal(t, resource.MustParse("300m"), res.Limits["cpu"])
+	assert.Equal(t, resource.MustParse("1024Mi"), res.Limits["memory"])
+}
+
+func podIdentityTestApp(env []core.EnvVar, ics []crd.InitContainer) *crd.ClowdApp {
+	return &crd.ClowdApp{
+		ObjectMeta: defaultMetaObject(),
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{{
+				Name: "reqapp",
+				PodSpec: crd.PodSpec{
+					Image:          "test:test",
+					Env:            env,
+					InitContainers: ics,
+				},
+			}},
+		},
+	}
+}
+
+func envVarFieldPath(envvars []core.EnvVar, name string) string {
+	for _, envvar := range envvars {
+		if envvar.Name == name && envvar.ValueFrom != nil && envvar.ValueFrom.FieldRef != nil {
+			return envvar.ValueFrom.FieldRef.FieldPath
+		}
+	}
+	return ""
+}
+
+func TestPodIdentityEnvVars(t *testing.T) {
+	app := podIdentityTestApp(
+		[]core.EnvVar{{Name: "ENV_VAR_1", Value: "env_var_1"}},
+		[]crd.InitContainer{
+			{Name: "inherit", InheritEnv: true},
+			{Name: "own", Env: []core.EnvVar{{Name: "ENV_VAR_2", Value: "env_var_2"}}},
+		},
+	)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+
+	spec := d.Spec.Template.Spec
+	for _, envvars := range [][]core.EnvVar{spec.Containers[0].Env, spec.InitContainers[0].Env, spec.InitContainers[1].Env} {
+		assert.Equal(t, "metadata.namespace", envVarFieldPath(envvars, PodNamespaceEnvVar))
+		assert.Equal(t, "metadata.name", envVarFieldPath(envvars, PodNameEnvVar))
+	}
+
+	// the init container with its own env gets them straight after ACG_CONFIG
+	names := []string{}
+	for _, envvar := range spec.InitContainers[1].Env {
+		names = append(names, envvar.Name)
+	}
+	assert.Equal(t, []string{ConfigEnvVar, PodNamespaceEnvVar, PodNameEnvVar, "ENV_VAR_2"}, names)
+}
+
+func TestPodIdentityEnvVarsOmitted(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Spec.Providers.Deployment.OmitPodIdentityEnv = true
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []core.EnvVar{{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"}}, d.Spec.Template.Spec.Containers[0].Env)
+}
+
+func TestPodIdentityEnvVarsCollision(t *testing.T) {
+	app := podIdentityTestApp([]core.EnvVar{{Name: "POD_NAME", Value: "mine"}}, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.ErrorContains(t, err, "env var [POD_NAME] collides")
+}
+
+func TestUserEnvVars(t *testing.T) {
+	secretRef := &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+		LocalObjectReference: core.LocalObjectReference{Name: "creds"},
+		Key:                  "password",
+	}}
+	app := podIdentityTestApp([]core.EnvVar{
+		{Name: "LOG_LEVEL", Value: "debug"},
+		{Name: "DB_PASSWORD", ValueFrom: secretRef},
+		{Name: "ACG_CONFIG", Value: "/tmp/mine.json"},
+	}, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Spec.Providers.Deployment.OmitPodIdentityEnv = true
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []core.EnvVar{
+		{Name: "LOG_LEVEL", Value: "debug"},
+		{Name: "DB_PASSWORD", ValueFrom: secretRef},
+		{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"},
+	}, d.Spec.Template.Spec.Containers[0].Env)
+}
+
+func configSecretVolume(d *apps.Deployment) *core.Volume {
+	for i, vol := range d.Spec.Template.Spec.Volumes {
+		if vol.Name == ConfigSecretVolumeName {
+			return &d.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	return nil
+}
+
+func TestConfigMount(t *testing.T) {
+	app := podIdentityTestApp(nil, []crd.InitContainer{{Name: "init"}})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Spec.Providers.Deployment.OmitPodIdentityEnv = true
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	c := d.Spec.Template.Spec.Containers[0]
+	assert.Contains(t, c.VolumeMounts, core.VolumeMount{Name: "config-secret", MountPath: "/cdapp/"})
+	assert.Equal(t, []core.EnvVar{{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"}}, c.Env)
+	if volume := configSecretVolume(d); assert.NotNil(t, volume) {
+		assert.Nil(t, volume.Secret.Items)
+	}
+
+	deployment.PodSpec.ConfigMountPath = "/etc/app"
+	deployment.PodSpec.ConfigFileName = "config.json"
+	deployment.PodSpec.VolumeMounts = []core.VolumeMount{{Name: "data", MountPath: "/etc/application"}}
+
+	d = &apps.Deployment{}
+	err = initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	c = d.Spec.Template.Spec.Containers[0]
+	assert.Contains(t, c.VolumeMounts, core.VolumeMount{Name: "config-secret", MountPath: "/etc/app"})
+	assert.Equal(t, []core.EnvVar{{Name: "ACG_CONFIG", Value: "/etc/app/config.json"}}, c.Env)
+	assert.Equal(t, []core.EnvVar{{Name: "ACG_CONFIG", Value: "/etc/app/config.json"}}, d.Spec.Template.Spec.InitContainers[0].Env)
+
+	// cdappconfig.json is still projected for Clowder's own containers
+	if volume := configSecretVolume(d); assert.NotNil(t, volume) {
+		assert.Equal(t, []core.KeyToPath{
+			{Key: "cdappconfig.json", Path: "cdappconfig.json"},
+			{Key: "cdappconfig.json", Path: "config.json"},
+		}, volume.Secret.Items)
+	}
+}
+
+func TestConfigMountCollision(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	for _, mountPath := range []string{"/cdapp", "/cdapp/certs", "/"} {
+		deployment.PodSpec.VolumeMounts = []core.VolumeMount{{Name: "data", MountPath: mountPath}}
+
+		d := &apps.Deployment{}
+		err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+		assert.ErrorContains(t, err, "config mount path [/cdapp] collides with volume mount [data]", mountPath)
+	}
+
+	deployment.PodSpec.ConfigMountPath = "/etc/app/config"
+	deployment.PodSpec.VolumeMounts = []core.VolumeMount{{Name: "data", MountPath: "/etc/app"}}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.ErrorContains(t, err, "config mount path [/etc/app/config] collides with volume mount [data] at [/etc/app]")
+}
+
+func TestPodAnnotations(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.PodAnnotations = map[string]string{
+		"sidecar.istio.io/inject":     "true",
+		"clowder/authsidecar-enabled": "false",
+	}
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Spec.Providers.Web.Mode = "local"
+	deployment.WebServices.Public.Enabled = true
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	annotations := d.Spec.Template.ObjectMeta.Annotations
+	assert.Equal(t, "true", annotations["sidecar.istio.io/inject"])
+	assert.Equal(t, "true", annotations["clowder/authsidecar-enabled"], "clowder managed annotation was overridden")
+	assert.NotContains(t, d.ObjectMeta.Annotations, "sidecar.istio.io/inject")
+}
+
+func TestSharedConfigMaps(t *testing.T) {
+	app := podIdentityTestApp(nil, []crd.InitContainer{{Name: "init", InheritEnv: true}})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Name = "env"
+	env.Spec.SharedConfigMaps = []crd.SharedConfigMap{{
+		Name:      "feature-config",
+		Namespace: "shared",
+		MountPath: "/etc/feature-config",
+	}}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	wantMount := core.VolumeMount{
+		Name:      "shared-feature-config",
+		MountPath: "/etc/feature-config",
+		ReadOnly:  true,
+	}
+	assert.Contains(t, d.Spec.Template.Spec.Containers[0].VolumeMounts, wantMount)
+	assert.Contains(t, d.Spec.Template.Spec.InitContainers[0].VolumeMounts, wantMount)
+
+	var volume *core.Volume
+	for i, vol := range d.Spec.Template.Spec.Volumes {
+		if vol.Name == "shared-feature-config" {
+			volume = &d.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	assert.NotNil(t, volume)
+	assert.NotNil(t, volume.ConfigMap)
+	assert.Equal(t, "env-feature-config-shared", volume.ConfigMap.Name)
+}
+
+func TestImagePullSecrets(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Spec.ImagePullSecrets = []string{"private-registry", "mirror"}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.LocalObjectReference{
+		{Name: "private-registry"},
+		{Name: "mirror"},
+	}, d.Spec.Template.Spec.ImagePullSecrets)
+
+	// secrets removed from the environment are dropped from the existing deployment
+	env.Spec.ImagePullSecrets = nil
+	err = initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Empty(t, d.Spec.Template.Spec.ImagePullSecrets)
+}
+
+func TestInitStatefulSet(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.Kind = "statefulset"
+	storageClass := "fast"
+	deployment.VolumeClaimTemplates = []crd.VolumeClaimTemplate{{
+		Name:             "data",
+		Size:             resource.MustParse("5Gi"),
+		AccessModes:      []core.PersistentVolumeAccessMode{core.ReadWriteOncePod},
+		StorageClassName: &storageClass,
+	}}
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+
+	s := &apps.StatefulSet{}
+	initStatefulSet(app, s, d, nn, &deployment)
+
+	assert.Equal(t, "StatefulSet", s.Kind)
+	assert.Equal(t, nn.Name, s.Name)
+	assert.Equal(t, d.Spec.Template, s.Spec.Template)
+	assert.Equal(t, d.Spec.Selector, s.Spec.Selector)
+	assert.Equal(t, "reqapp-reqapp-headless", s.Spec.ServiceName)
+
+	if assert.Len(t, s.Spec.VolumeClaimTemplates, 1) {
+		claim := s.Spec.VolumeClaimTemplates[0]
+		assert.Equal(t, "data", claim.Name)
+		assert.Equal(t, []core.PersistentVolumeAccessMode{core.ReadWriteOncePod}, claim.Spec.AccessModes)
+		assert.Equal(t, &storageClass, claim.Spec.StorageClassName)
+		assert.Equal(t, resource.MustParse("5Gi"), claim.Spec.Resources.Requests[core.ResourceStorage])
+	}
+}
+
+func TestSingletonDeployment(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.Singleton = true
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	// even a deployment that was scaled up out of band is brought back to a single replica
+	d := &apps.Deployment{}
+	d.Spec.Replicas = utils.Int32Ptr(3)
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+
+	assert.Equal(t, int32(1), *d.Spec.Replicas)
+	assert.Equal(t, apps.RecreateDeploymentStrategyType, d.Spec.Strategy.Type)
+	assert.Nil(t, d.Spec.Strategy.RollingUpdate)
+}
+
+func TestSingletonDeploymentConflict(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.Singleton = true
+	deployment.Replicas = utils.Int32Ptr(2)
+	deployment.AutoScaler = &crd.AutoScaler{}
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+
+	invalid := &errors.InvalidSingleton{}
+	assert.ErrorAs(t, err, &invalid)
+	assert.Equal(t, []string{"autoScaler is set", "replicas is set to 2"}, invalid.Problems)
+}
+
+func TestScaleToZeroReplicas(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.AutoScaler = &crd.AutoScaler{MinReplicaCount: utils.Int32Ptr(0)}
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	// a new deployment starts with the replica count of the app
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *d.Spec.Replicas)
+
+	// and is not scaled back up once KEDA scaled it to zero
+	d.Spec.Replicas = utils.Int32Ptr(0)
+	err = initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), *d.Spec.Replicas)
+
+	// an autoscaler with a minimum keeps the deployment at the replica count of the app
+	deployment.AutoScaler.MinReplicaCount = utils.Int32Ptr(1)
+	err = initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *d.Spec.Replicas)
+}
+
+func TestLiveReplicasCappedByEnv(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Spec.MaxReplicas = utils.Int32Ptr(5)
+
+	// live replicas above the app's count are kept up to the maximum of the environment
+	d := &apps.Deployment{}
+	d.Spec.Replicas = utils.Int32Ptr(4)
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), *d.Spec.Replicas)
+
+	d.Spec.Replicas = utils.Int32Ptr(3200)
+	err = initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), *d.Spec.Replicas)
+}
+
+func TestAutomountServiceAccountToken(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	// unset keeps the Kubernetes default
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Nil(t, d.Spec.Template.Spec.AutomountServiceAccountToken)
+
+	deployment.PodSpec.AutomountServiceAccountToken = utils.FalsePtr()
+	err = initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, utils.FalsePtr(), d.Spec.Template.Spec.AutomountServiceAccountToken)
+}
+
+func TestConfigValidationInitContainer(t *testing.T) {
+	app := podIdentityTestApp(nil, []crd.InitContainer{{Name: "init", InheritEnv: true}})
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Len(t, d.Spec.Template.Spec.InitContainers, 1)
+
+	env.Spec.Providers.Deployment.ConfigValidation = crd.ConfigValidationConfig{
+		Enabled: true,
+		Image:   "validator:test",
+	}
+
+	d = &apps.Deployment{}
+	err = initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	ics := d.Spec.Template.Spec.InitContainers
+	assert.Len(t, ics, 2)
+
+	validator := ics[0]
+	assert.Equal(t, ConfigValidationContainerName, validator.Name)
+	assert.Equal(t, "validator:test", validator.Image)
+	assert.Equal(t, []string{"/manager", "validate-config"}, validator.Command)
+	assert.Contains(t, validator.Env, core.EnvVar{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"})
+	assert.Equal(t, []core.VolumeMount{{
+		Name:      "config-secret",
+		MountPath: "/cdapp/",
+		ReadOnly:  true,
+	}}, validator.VolumeMounts)
+
+	assert.Equal(t, "init-init", ics[1].Name)
+
+	env.Spec.Providers.Deployment.ConfigValidation.Image = ""
+
+	d = &apps.Deployment{}
+	err = initDeployment(app, env, d, nn, &deployment)
+	assert.ErrorContains(t, err, "no validator image is set")
+}
+
+func TestProvenanceLabels(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.PodSpec.ProvenanceLabels = map[string]string{
+		"build.git-sha": "0123abc",
+		"build.time":    "20260101T000000Z",
+		"pod":           "mine",
+	}
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, presetTestEnv(), d, nn, &deployment)
+	assert.NoError(t, err)
+
+	labels := d.Spec.Template.ObjectMeta.Labels
+	assert.Equal(t, "0123abc", labels["build.git-sha"])
+	assert.Equal(t, "20260101T000000Z", labels["build.time"])
+	assert.Equal(t, "test", labels["app"])
+	assert.Equal(t, nn.Name, labels["pod"], "clowder managed label was overridden")
+
+	// the selectors only match on the Clowder labels
+	assert.NotContains(t, d.Spec.Selector.MatchLabels, "build.git-sha")
+	for _, term := range d.Spec.Template.Spec.Affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		assert.NotContains(t, term.PodAffinityTerm.LabelSelector.MatchLabels, "build.git-sha")
+	}
+}
+
+func TestCustomMetadata(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.Labels = map[string]string{"cost-center": "1234", "pod": "mine"}
+	deployment.Annotations = map[string]string{"owner": "deployment"}
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	env := presetTestEnv()
+	env.Spec.Labels = map[string]string{"team": "platform", "cost-center": "0000", "app": "mine"}
+	env.Spec.Annotations = map[string]string{"owner": "env", "team-channel": "#platform"}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+
+	s := &core.Service{}
+	utils.MakeService(s, nn, map[string]string{"pod": nn.Name}, []core.ServicePort{}, app, false)
+	SetCustomMetadata(s, env, &deployment)
+
+	for name, meta := range map[string]metav1.Object{"deployment": d, "pod template": &d.Spec.Template, "service": s} {
+		labels := meta.GetLabels()
+		assert.Equal(t, "platform", labels["team"], name)
+		assert.Equal(t, "1234", labels["cost-center"], name)
+		assert.Equal(t, "test", labels["app"], "clowder managed label was overridden on the %s", name)
+		assert.Equal(t, nn.Name, labels["pod"], "clowder managed label was overridden on the %s", name)
+
+		annotations := meta.GetAnnotations()
+		assert.Equal(t, "deployment", annotations["owner"], name)
+		assert.Equal(t, "#platform", annotations["team-channel"], name)
+	}
+
+	// the selectors only match on the Clowder labels
+	assert.Equal(t, map[string]string{"app": "test", "pod": nn.Name}, d.Spec.Selector.MatchLabels)
+	assert.Equal(t, map[string]string{"pod": nn.Name}, s.Spec.Selector)
+
+	// a Clowder managed annotation still wins over the custom ones
+	s.Annotations["managed"] = "clowder"
+	env.Spec.Annotations["managed"] = "env"
+
+	// the annotations applied by a previous reconcile are updated and removed along with the
+	// entries of the environment and the deployment
+	env.Spec.Annotations["team-channel"] = "#platform-team"
+	deployment.Annotations = nil
+	SetCustomMetadata(s, env, &deployment)
+
+	annotations := s.GetAnnotations()
+	assert.Equal(t, "env", annotations["owner"])
+	assert.Equal(t, "#platform-team", annotations["team-channel"])
+	assert.Equal(t, "clowder", annotations["managed"])
+	assert.Equal(t, "owner,team-channel", annotations[CustomAnnotationsAnnotation])
+
+	env.Spec.Annotations = nil
+	SetCustomMetadata(s, env, &deployment)
+	assert.Equal(t, map[string]string{"managed": "clowder"}, s.GetAnnotations())
+}
+
+func TestScheduling(t *testing.T) {
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	envToleration := core.Toleration{Key: "dedicated", Operator: core.TolerationOpEqual, Value: "clowder", Effect: core.TaintEffectNoSchedule}
+	env := presetTestEnv()
+	env.Spec.Providers.Deployment.Tolerations = []core.Toleration{envToleration}
+	env.Spec.Providers.Deployment.NodeSelector = map[string]string{"node-role": "apps", "zone": "a"}
+
+	d := &apps.Deployment{}
+	err := initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.Toleration{envToleration}, d.Spec.Template.Spec.Tolerations)
+	assert.Equal(t, map[string]string{"node-role": "apps", "zone": "a"}, d.Spec.Template.Spec.NodeSelector)
+
+	// the deployment adds to the environment's tolerations and wins on node selector conflicts
+	podToleration := core.Toleration{Key: "gpu", Operator: core.TolerationOpExists, Effect: core.TaintEffectNoSchedule}
+	deployment.PodSpec.MachinePool = "memory"
+	deployment.PodSpec.Tolerations = []core.Toleration{envToleration, podToleration}
+	deployment.PodSpec.NodeSelector = map[string]string{"zone": "b", "disk": "ssd"}
+
+	d = &apps.Deployment{}
+	err = initDeployment(app, env, d, nn, &deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, []core.Toleration{
+		envToleration,
+		{Key: "memory", Operator: core.TolerationOpEqual, Value: "true", Effect: core.TaintEffectNoSchedule},
+		podToleration,
+	}, d.Spec.Template.Spec.Tolerations)
+	assert.Equal(t, map[string]string{"node-role": "apps", "zone": "b", "disk": "ssd"}, d.Spec.Template.Spec.NodeSelector)
+
+	// the environment's node selector is not modified by the merge
+	assert.Equal(t, "a", env.Spec.Providers.Deployment.NodeSelector["zone"])
+
+	d = &apps.Deployment{}
+	err = initDeployment(app, presetTestEnv(), d, nn, &app.Spec.Deployments[0])
+	assert.NoError(t, err)
+	assert.Empty(t, d.Spec.Template.Spec.Tolerations)
+	assert.Nil(t, d.Spec.Template.Spec.NodeSelector)
+}
+
+func TestProbePorts(t *testing.T) {
+	env := presetTestEnv()
+	env.Spec.Providers.Web.Port = 8000
+	env.Spec.Providers.Metrics.Port = 9000
+
+	httpProbe := func(port intstr.IntOrString) *core.Probe {
+		return &core.Probe{
+			ProbeHandler: core.ProbeHandler{
+				HTTPGet: &core.HTTPGetAction{Path: "/healthz", Port: port},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		probe     *core.Probe
+		public    bool
+		private   bool
+		wantError bool
+	}{
+		{"web port", httpProbe(intstr.FromInt(8000)), true, false, false},
+		{"named web port", httpProbe(intstr.FromString("web")), true, false, false},
+		{"web port without public service", httpProbe(intstr.FromInt(8000)), false, false, true},
+		{"default private port", httpProbe(intstr.FromInt(10000)), false, true, false},
+		{"metrics port", httpProbe(intstr.FromString("metrics")), false, false, false},
+		{"unknown port", httpProbe(intstr.FromInt(8123)), true, true, true},
+		{"extra port", httpProbe(intstr.FromString("grpc")), false, false, false},
+		{"tcp probe", &core.Probe{ProbeHandler: core.ProbeHandler{TCPSocket: &core.TCPSocketAction{Port: intstr.FromInt(8123)}}}, false, false, false},
+		{"grpc probe", &core.Probe{ProbeHandler: core.ProbeHandler{GRPC: &core.GRPCAction{Port: 9090}}}, false, false, false},
+		{"grpc probe on unknown port", &core.Probe{ProbeHandler: core.ProbeHandler{GRPC: &core.GRPCAction{Port: 8123}}}, true, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := podIdentityTestApp(nil, nil)
+			deployment := app.Spec.Deployments[0]
+			deployment.PodSpec.ReadinessProbe = tt.probe
+			deployment.WebServices.Public.Enabled = tt.public
+			deployment.WebServices.Private.Enabled = tt.private
+			deployment.ExtraPorts = []crd.DeploymentPort{{Name: "grpc", ContainerPort: 9090}}
+			nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+			d := &apps.Deployment{}
+			err := initDeployment(app, env, d, nn, &deployment)
+
+			if tt.wantError {
+				invalid := &errors.InvalidProbePort{}
+				assert.ErrorAs(t, err, &invalid)
+				assert.Equal(t, "readiness", invalid.Probe)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.probe.ProbeHandler, d.Spec.Template.Spec.Containers[0].ReadinessProbe.ProbeHandler)
+		})
+	}
+}
+
+func TestWebProtocolProbes(t *testing.T) {
+	env := presetTestEnv()
+	env.Spec.Providers.Web.Port = 8000
+
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.WebServices.Public.Enabled = true
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	d := &apps.Deployment{}
+	assert.NoError(t, initDeployment(app, env, d, nn, &deployment))
+	c := d.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, &core.HTTPGetAction{Path: "/healthz", Scheme: "HTTP", Port: intstr.FromInt(8000)}, c.LivenessProbe.HTTPGet)
+	assert.Equal(t, &core.HTTPGetAction{Path: "/healthz", Scheme: "HTTP", Port: intstr.FromInt(8000)}, c.ReadinessProbe.HTTPGet)
+	assert.Nil(t, c.LivenessProbe.GRPC)
+
+	deployment.WebServices.Public.Protocol = "http2"
+	d = &apps.Deployment{}
+	assert.NoError(t, initDeployment(app, env, d, nn, &deployment))
+	assert.NotNil(t, d.Spec.Template.Spec.Containers[0].LivenessProbe.HTTPGet)
+
+	deployment.WebServices.Public.Protocol = "grpc"
+	d = &apps.Deployment{}
+	assert.NoError(t, initDeployment(app, env, d, nn, &deployment))
+	c = d.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, &core.GRPCAction{Port: 8000}, c.LivenessProbe.GRPC)
+	assert.Equal(t, &core.GRPCAction{Port: 8000}, c.ReadinessProbe.GRPC)
+	assert.Nil(t, c.LivenessProbe.HTTPGet)
+	assert.Equal(t, int32(45), c.ReadinessProbe.InitialDelaySeconds)
+}
+
+func TestExtraPortsConflicts(t *testing.T) {
+	env := presetTestEnv()
+	env.Spec.Providers.Web.Port = 8000
+	env.Spec.Providers.Metrics.Port = 9000
+
+	app := podIdentityTestApp(nil, nil)
+	deployment := app.Spec.Deployments[0]
+	deployment.WebServices.Public.Enabled = true
+	nn := types.NamespacedName{Name: "reqapp-reqapp", Namespace: app.Namespace}
+
+	deployment.ExtraPorts = []crd.DeploymentPort{
+		{Name: "grpc", ContainerPort: 9090},
+		{Name: "ws", ContainerPort: 9091},
+	}
+	assert.NoError(t, initDeployment(app, env, &apps.Deployment{}, nn, &deployment))
+
+	deployment.ExtraPorts = []crd.DeploymentPort{
+		{Name: "grpc", ContainerPort: 9090},
+		{Name: "grpc", ContainerPort: 9091},
+		{Name: "metrics", ContainerPort: 9092},
+		{Name: "ws", ContainerPort: 8000},
+	}
+	err := initDeployment(app, env, &apps.Deployment{}, nn, &deployment)
+	assert.ErrorContains(t, err, "Deployment [reqapp] has conflicting extra ports: [name grpc is used more than once; name metrics is reserved; port 8000 of ws is already used by web]")
+}
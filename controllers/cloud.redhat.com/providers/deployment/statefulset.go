@@ -0,0 +1,163 @@
+package deployment
+
+import (
+	"fmt"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// HeadlessServiceName returns the name of the headless service of a statefulset deployment.
+func HeadlessServiceName(nn types.NamespacedName) string {
+	return fmt.Sprintf("%s-headless", nn.Name)
+}
+
+// makeStatefulSet renders a deployment of the statefulset kind. The pod template is built by
+// initDeployment, as it is for a Deployment, so the config secret, env vars and anti-affinity of
+// the pods are the same for both kinds.
+func (dp *deploymentProvider) makeStatefulSet(deployment crd.Deployment, app *crd.ClowdApp) error {
+
+	s := &apps.StatefulSet{}
+	nn := app.GetDeploymentNamespacedName(&deployment)
+
+	if err := dp.Cache.Create(CoreStatefulSet, nn, s); err != nil {
+		return err
+	}
+
+	// The replicas of the live statefulset are kept, the same as for a live deployment
+	d := &apps.Deployment{}
+	d.Spec.Replicas = s.Spec.Replicas
+
+	if err := initDeployment(app, dp.Env, d, nn, &deployment); err != nil {
+		return err
+	}
+
+	initStatefulSet(app, s, d, nn, &deployment)
+
+	if err := dp.makeHeadlessService(app, &deployment, nn); err != nil {
+		return err
+	}
+
+	setMetadataResources(dp.Config, deployment.Name, s.Spec.Template.Spec.Containers[0].Resources)
+
+	return dp.Cache.Update(CoreStatefulSet, s)
+}
+
+// initStatefulSet copies the metadata, replicas and pod template of the rendered deployment d into
+// the statefulset and adds the volume claim templates.
+func initStatefulSet(app *crd.ClowdApp, s *apps.StatefulSet, d *apps.Deployment, nn types.NamespacedName, deployment *crd.Deployment) {
+	app.SetObjectMeta(s, crd.Name(nn.Name), crd.Labels(d.Labels))
+	utils.UpdateAnnotations(s, d.Annotations)
+
+	s.Kind = "StatefulSet"
+
+	s.Spec.Replicas = d.Spec.Replicas
+	s.Spec.Selector = d.Spec.Selector
+	s.Spec.Template = d.Spec.Template
+	s.Spec.ServiceName = HeadlessServiceName(nn)
+	s.Spec.UpdateStrategy = apps.StatefulSetUpdateStrategy{
+		Type: apps.RollingUpdateStatefulSetStrategyType,
+	}
+
+	claims := []core.PersistentVolumeClaim{}
+	for _, template := range deployment.VolumeClaimTemplates {
+		claim := core.PersistentVolumeClaim{}
+		claim.Name = template.Name
+		claim.Spec.AccessModes = template.AccessModes
+		if len(claim.Spec.AccessModes) == 0 {
+			claim.Spec.AccessModes = []core.PersistentVolumeAccessMode{core.ReadWriteOnce}
+		}
+		claim.Spec.Resources.Requests = core.ResourceList{
+			core.ResourceStorage: template.Size,
+		}
+		claim.Spec.StorageClassName = template.StorageClassName
+		claims = append(claims, claim)
+	}
+	s.Spec.VolumeClaimTemplates = claims
+}
+
+// makeHeadlessService creates the headless service that the pods of the statefulset get their
+// stable DNS names from, e.g. <app>-<deployment>-0.<app>-<deployment>-headless.
+func (dp *deploymentProvider) makeHeadlessService(app *crd.ClowdApp, deployment *crd.Deployment, nn types.NamespacedName) error {
+	s := &core.Service{}
+	snn := types.NamespacedName{
+		Name:      HeadlessServiceName(nn),
+		Namespace: nn.Namespace,
+	}
+
+	if err := dp.Cache.Create(CoreHeadlessService, snn, s); err != nil {
+		return err
+	}
+
+	utils.MakeService(s, snn, map[string]string{"pod": nn.Name}, []core.ServicePort{}, app, false)
+	s.Spec.ClusterIP = core.ClusterIPNone
+	SetCustomMetadata(s, dp.Env, deployment)
+
+	return dp.Cache.Update(CoreHeadlessService, s)
+}
+
+// EditPodTemplate applies edit to the pod template rendered for the deployment, which belongs to a
+// StatefulSet for the statefulset kind and to a Deployment otherwise, and updates the cache.
+func EditPodTemplate(cache *rc.ObjectCache, app *crd.ClowdApp, deployment *crd.Deployment, edit func(t *core.PodTemplateSpec) error) error {
+	nn := app.GetDeploymentNamespacedName(deployment)
+
+	if deployment.IsStatefulSet() {
+		s := &apps.StatefulSet{}
+		if err := cache.Get(CoreStatefulSet, s, nn); err != nil {
+			return err
+		}
+		if err := edit(&s.Spec.Template); err != nil {
+			return err
+		}
+		return cache.Update(CoreStatefulSet, s)
+	}
+
+	d := &apps.Deployment{}
+	if err := cache.Get(CoreDeployment, d, nn); err != nil {
+		return err
+	}
+	if err := edit(&d.Spec.Template); err != nil {
+		return err
+	}
+	return cache.Update(CoreDeployment, d)
+}
+
+// EditPodTemplates applies edit to the pod templates of all the deployments and statefulsets in
+// the cache.
+func EditPodTemplates(cache *rc.ObjectCache, edit func(t *core.PodTemplateSpec) error) error {
+	dList := apps.DeploymentList{}
+	if err := cache.List(CoreDeployment, &dList); err != nil {
+		return err
+	}
+
+	for _, deployment := range dList.Items {
+		innerDeployment := deployment
+		if err := edit(&innerDeployment.Spec.Template); err != nil {
+			return err
+		}
+		if err := cache.Update(CoreDeployment, &innerDeployment); err != nil {
+			return err
+		}
+	}
+
+	sList := apps.StatefulSetList{}
+	if err := cache.List(CoreStatefulSet, &sList); err != nil {
+		return err
+	}
+
+	for _, statefulSet := range sList.Items {
+		innerStatefulSet := statefulSet
+		if err := edit(&innerStatefulSet.Spec.Template); err != nil {
+			return err
+		}
+		if err := cache.Update(CoreStatefulSet, &innerStatefulSet); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
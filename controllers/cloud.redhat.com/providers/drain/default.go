@@ -0,0 +1,86 @@
+package drain
+
+import (
+	"fmt"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type drainProvider struct {
+	providers.Provider
+}
+
+func NewDrainProvider(p *providers.Provider) (providers.ClowderProvider, error) {
+	return &drainProvider{Provider: *p}, nil
+}
+
+func (dp *drainProvider) EnvProvide() error {
+	return nil
+}
+
+func (dp *drainProvider) Provide(app *crd.ClowdApp) error {
+	for _, deployment := range app.Spec.Deployments {
+		innerDeployment := deployment
+		if innerDeployment.DrainOnRollout == nil {
+			continue
+		}
+		if err := dp.drainDeployment(app, &innerDeployment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dp *drainProvider) drainDeployment(app *crd.ClowdApp, deployment *crd.Deployment) error {
+	if deployment.AutoScaler != nil || deployment.AutoScalerSimple != nil {
+		return errors.NewClowderError(
+			fmt.Sprintf("deployment [%s] cannot use drainOnRollout together with an autoscaler", deployment.Name),
+		)
+	}
+	if deployment.IsStatefulSet() {
+		return errors.NewClowderError(
+			fmt.Sprintf("deployment [%s] cannot use drainOnRollout with the statefulset kind", deployment.Name),
+		)
+	}
+
+	nn := app.GetDeploymentNamespacedName(deployment)
+
+	d := &apps.Deployment{}
+	if err := dp.Cache.Get(deployProvider.CoreDeployment, d, nn); err != nil {
+		return err
+	}
+
+	live := &apps.Deployment{}
+	pods := 0
+	if err := dp.Client.Get(dp.Ctx, nn, live); err != nil {
+		if !k8serr.IsNotFound(err) {
+			return err
+		}
+		live = nil
+	} else {
+		podList := core.PodList{}
+		if err := dp.Client.List(dp.Ctx, &podList, client.InNamespace(nn.Namespace), client.MatchingLabels(live.Spec.Selector.MatchLabels)); err != nil {
+			return err
+		}
+		pods = len(podList.Items)
+	}
+
+	timeout := defaultDrainTimeout
+	if deployment.DrainOnRollout.TimeoutSeconds > 0 {
+		timeout = time.Duration(deployment.DrainOnRollout.TimeoutSeconds) * time.Second
+	}
+
+	if err := applyDrain(d, live, pods, timeout, time.Now()); err != nil {
+		return err
+	}
+
+	return dp.Cache.Update(deployProvider.CoreDeployment, d)
+}
@@ -0,0 +1,88 @@
+package drain
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+)
+
+// TemplateHashAnnotation records the hash of the last pod template that was rolled out.
+const TemplateHashAnnotation = "clowder/drain-template-hash"
+
+// DrainStartedAnnotation is set on a deployment while it is being drained, it holds the time the
+// drain started.
+const DrainStartedAnnotation = "clowder/drain-started"
+
+const defaultDrainTimeout = 300 * time.Second
+
+func templateHash(template *core.PodTemplateSpec) (string, error) {
+	jsonData, err := json.Marshal(template)
+	if err != nil {
+		return "", errors.Wrap("Failed to marshal pod template", err)
+	}
+
+	h := sha256.New()
+	h.Write(jsonData)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// applyDrain holds back a new pod template until the old version of the deployment is drained.
+// desired is the deployment as rendered by the other providers, live the deployment in the
+// cluster, nil if it does not exist yet, and pods the number of pods of the live deployment that
+// are still running or terminating.
+//
+// When the template changes the old template is kept and the deployment scaled to zero. Once
+// all of the pods are gone, or the timeout has passed, the new template is rolled out with the
+// desired replicas.
+func applyDrain(desired *apps.Deployment, live *apps.Deployment, pods int, timeout time.Duration, now time.Time) error {
+	hash, err := templateHash(&desired.Spec.Template)
+	if err != nil {
+		return err
+	}
+
+	if live == nil {
+		markRolledOut(desired, hash)
+		return nil
+	}
+
+	rolledOut := live.GetAnnotations()[TemplateHashAnnotation]
+	if rolledOut == "" || rolledOut == hash {
+		// Either draining was only just enabled, or there is nothing new to roll out, which
+		// also covers a change that was reverted mid-drain
+		markRolledOut(desired, hash)
+		return nil
+	}
+
+	started := now
+	if value, ok := live.GetAnnotations()[DrainStartedAnnotation]; ok {
+		if started, err = time.Parse(time.RFC3339, value); err != nil {
+			return errors.Wrap(fmt.Sprintf("invalid %s annotation", DrainStartedAnnotation), err)
+		}
+	}
+
+	if pods == 0 || now.Sub(started) >= timeout {
+		markRolledOut(desired, hash)
+		return nil
+	}
+
+	desired.Spec.Template = *live.Spec.Template.DeepCopy()
+	desired.Spec.Replicas = utils.Int32Ptr(0)
+	utils.UpdateAnnotations(desired, map[string]string{
+		TemplateHashAnnotation: rolledOut,
+		DrainStartedAnnotation: started.Format(time.RFC3339),
+	})
+	return nil
+}
+
+func markRolledOut(desired *apps.Deployment, hash string) {
+	utils.UpdateAnnotations(desired, map[string]string{TemplateHashAnnotation: hash})
+	annotations := desired.GetAnnotations()
+	delete(annotations, DrainStartedAnnotation)
+	desired.SetAnnotations(annotations)
+}
@@ -0,0 +1,107 @@
+package drain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/stretchr/testify/assert"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// desiredDeployment mimics the deployment rendered by the deployment provider, which always asks
+// for the configured replicas.
+func desiredDeployment(live *apps.Deployment, image string) *apps.Deployment {
+	d := &apps.Deployment{}
+	if live != nil {
+		d = live.DeepCopy()
+	}
+	d.ObjectMeta.Name = "app-consumer"
+	d.Spec.Replicas = utils.Int32Ptr(3)
+	d.Spec.Template.Spec.Containers = []core.Container{{Name: "app-consumer", Image: image}}
+	return d
+}
+
+func TestDrainAndRoll(t *testing.T) {
+	timeout := 5 * time.Minute
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	replicas := []int32{}
+	images := []string{}
+	step := func(live *apps.Deployment, image string, pods int, now time.Time) *apps.Deployment {
+		d := desiredDeployment(live, image)
+		assert.NoError(t, applyDrain(d, live, pods, timeout, now))
+		replicas = append(replicas, *d.Spec.Replicas)
+		images = append(images, d.Spec.Template.Spec.Containers[0].Image)
+		return d
+	}
+
+	// initial creation rolls out straight away
+	live := step(nil, "consumer:v1", 0, start)
+	// nothing changed
+	live = step(live, "consumer:v1", 3, start.Add(time.Minute))
+	// new version requested, the old one is scaled down first
+	live = step(live, "consumer:v2", 3, start.Add(2*time.Minute))
+	assert.Contains(t, live.Annotations, DrainStartedAnnotation)
+	// pods are still finishing their in-flight work
+	live = step(live, "consumer:v2", 1, start.Add(3*time.Minute))
+	// all old pods are gone, the new version is rolled out and scaled back up
+	live = step(live, "consumer:v2", 0, start.Add(4*time.Minute))
+	assert.NotContains(t, live.Annotations, DrainStartedAnnotation)
+	// and it stays rolled out
+	step(live, "consumer:v2", 3, start.Add(5*time.Minute))
+
+	assert.Equal(t, []int32{3, 3, 0, 0, 3, 3}, replicas)
+	assert.Equal(t, []string{"consumer:v1", "consumer:v1", "consumer:v1", "consumer:v1", "consumer:v2", "consumer:v2"}, images)
+}
+
+func TestDrainTimeout(t *testing.T) {
+	timeout := 5 * time.Minute
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	live := desiredDeployment(nil, "consumer:v1")
+	assert.NoError(t, applyDrain(live, nil, 0, timeout, start))
+
+	d := desiredDeployment(live, "consumer:v2")
+	assert.NoError(t, applyDrain(d, live, 3, timeout, start))
+	assert.Equal(t, int32(0), *d.Spec.Replicas)
+
+	// a pod that never terminates does not hold up the rollout forever
+	live = d
+	d = desiredDeployment(live, "consumer:v2")
+	assert.NoError(t, applyDrain(d, live, 1, timeout, start.Add(timeout)))
+	assert.Equal(t, int32(3), *d.Spec.Replicas)
+	assert.Equal(t, "consumer:v2", d.Spec.Template.Spec.Containers[0].Image)
+}
+
+func TestDrainReverted(t *testing.T) {
+	timeout := 5 * time.Minute
+	start := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	live := desiredDeployment(nil, "consumer:v1")
+	assert.NoError(t, applyDrain(live, nil, 0, timeout, start))
+
+	d := desiredDeployment(live, "consumer:v2")
+	assert.NoError(t, applyDrain(d, live, 3, timeout, start))
+
+	// going back to the running version mid-drain simply scales it back up
+	live = d
+	d = desiredDeployment(live, "consumer:v1")
+	assert.NoError(t, applyDrain(d, live, 2, timeout, start.Add(time.Minute)))
+	assert.Equal(t, int32(3), *d.Spec.Replicas)
+	assert.NotContains(t, d.Annotations, DrainStartedAnnotation)
+}
+
+func TestDrainAdopted(t *testing.T) {
+	// a deployment that existed before draining was enabled is not drained for its first change
+	live := desiredDeployment(nil, "consumer:v1")
+	live.ObjectMeta = metav1.ObjectMeta{Name: "app-consumer"}
+
+	d := desiredDeployment(live, "consumer:v2")
+	assert.NoError(t, applyDrain(d, live, 3, time.Minute, time.Now()))
+	assert.Equal(t, int32(3), *d.Spec.Replicas)
+	assert.Equal(t, "consumer:v2", d.Spec.Template.Spec.Containers[0].Image)
+	assert.NotEmpty(t, d.Annotations[TemplateHashAnnotation])
+}
@@ -0,0 +1,18 @@
+package drain
+
+import (
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+)
+
+// ProvName sets the provider name identifier
+var ProvName = "drain"
+
+// GetDrain returns the drain provider, it runs after every other provider so that it sees the
+// final pod templates of the deployments.
+func GetDrain(c *providers.Provider) (providers.ClowderProvider, error) {
+	return NewDrainProvider(c)
+}
+
+func init() {
+	providers.ProvidersRegistration.Register(GetDrain, 100, ProvName)
+}
@@ -51,8 +51,9 @@ type localFeatureFlagsProvider struct {
 // NewLocalFeatureFlagsProvider returns a new local featureflags provider object.
 func NewLocalFeatureFlagsProvider(p *providers.Provider) (providers.ClowderProvider, error) {
 	p.Cache.AddPossibleGVKFromIdent(
-		LocalFFDBDeployment,
+		LocalFFDeployment,
 		LocalFFService,
+		LocalFFSecret,
 		LocalFFDBDeployment,
 		LocalFFDBService,
 		LocalFFDBPVC,
@@ -189,7 +190,7 @@ func createDefaultFFSecMap() map[string]string {
 
 // CreateDatabase ensures a database is created for the given app.  The
 // namespaced name passed in must be the actual name of the db resources
-func (ff *localFeatureFlagsProvider) Provide(_ *crd.ClowdApp) error {
+func (ff *localFeatureFlagsProvider) Provide(app *crd.ClowdApp) error {
 
 	secret := &core.Secret{}
 	nn := providers.GetNamespacedName(ff.Env, "featureflags")
@@ -205,6 +206,16 @@ func (ff *localFeatureFlagsProvider) Provide(_ *crd.ClowdApp) error {
 		ClientAccessToken: utils.StringPtr(string(secret.Data["clientAccessToken"])),
 	}
 
+	if len(app.Spec.FeatureFlagToggles) > 0 && !ff.DryRun {
+		unleashURL := fmt.Sprintf("http://%s:%d", ff.Config.FeatureFlags.Hostname, ff.Config.FeatureFlags.Port)
+		unleash := newUnleashClient(unleashURL, string(secret.Data["adminAccessToken"]))
+		if err := seedToggles(ff.Ctx, unleash, app.Spec.FeatureFlagToggles); err != nil {
+			raisedErr := errors.Wrap("Couldn't seed feature flag toggles", err)
+			raisedErr.Requeue = true
+			return raisedErr
+		}
+	}
+
 	return nil
 }
 
@@ -0,0 +1,137 @@
+package featureflags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+)
+
+// unleashProject is the project that toggles are created in, the open source Unleash only has
+// the default project.
+const unleashProject = "default"
+
+// unleashEnvironment is the environment that the client access token of the local instance is
+// scoped to, and so the one in which toggles are enabled.
+const unleashEnvironment = "development"
+
+// unleashTimeout bounds each request to the Unleash admin API so that an instance which is not
+// up yet can't hold up the reconciliation of the app.
+const unleashTimeout = 5 * time.Second
+
+// unleashClient talks to the admin API of an Unleash instance.
+type unleashClient struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func newUnleashClient(url string, token string) *unleashClient {
+	return &unleashClient{
+		url:        url,
+		token:      token,
+		httpClient: &http.Client{Timeout: unleashTimeout},
+	}
+}
+
+func (u *unleashClient) do(ctx context.Context, method string, path string, body interface{}) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%s%s", u.url, path), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", u.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := u.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+		defer resp.Body.Close()
+		bodyErr, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unleash responded to %s %s with status %d - %s", method, path, resp.StatusCode, bodyErr)
+	}
+	return resp, nil
+}
+
+func (u *unleashClient) toggleExists(ctx context.Context, name string) (bool, error) {
+	resp, err := u.do(ctx, http.MethodGet, fmt.Sprintf("/api/admin/projects/%s/features/%s", unleashProject, name), nil)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode != http.StatusNotFound, nil
+}
+
+func (u *unleashClient) createToggle(ctx context.Context, name string) error {
+	resp, err := u.do(ctx, http.MethodPost, fmt.Sprintf("/api/admin/projects/%s/features", unleashProject), map[string]string{
+		"name": name,
+		"type": "release",
+	})
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("unleash project %s not found", unleashProject)
+	}
+	return nil
+}
+
+func (u *unleashClient) setToggleState(ctx context.Context, name string, enabled bool) error {
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+	path := fmt.Sprintf("/api/admin/projects/%s/features/%s/environments/%s/%s", unleashProject, name, unleashEnvironment, state)
+	resp, err := u.do(ctx, http.MethodPost, path, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("unleash toggle %s not found", name)
+	}
+	return nil
+}
+
+// seedToggles creates the toggles that don't exist yet in the declared state, existing toggles are
+// only reset to the declared state when forced.
+func seedToggles(ctx context.Context, u *unleashClient, toggles []crd.FeatureFlagToggle) error {
+	for _, toggle := range toggles {
+		exists, err := u.toggleExists(ctx, toggle.Name)
+		if err != nil {
+			return err
+		}
+
+		if exists && !toggle.Force {
+			continue
+		}
+
+		if !exists {
+			if err := u.createToggle(ctx, toggle.Name); err != nil {
+				return err
+			}
+		}
+
+		if err := u.setToggleState(ctx, toggle.Name, toggle.Enabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package featureflags
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+)
+
+// mockUnleash serves the parts of the Unleash admin API used to seed toggles, keeping the state
+// of each toggle in the development environment.
+func mockUnleash(t *testing.T, toggles map[string]bool) *httptest.Server {
+	prefix := "/api/admin/projects/default/features"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "admin-token", r.Header.Get("Authorization"))
+
+		if r.Method == http.MethodPost && r.URL.Path == prefix {
+			body := map[string]string{}
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			toggles[body["name"]] = false
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, prefix+"/"), "/")
+		if _, ok := toggles[parts[0]]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodGet && len(parts) == 1:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodPost && len(parts) == 4 && parts[1] == "environments" && parts[2] == "development":
+			toggles[parts[0]] = parts[3] == "on"
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+}
+
+func TestSeedToggles(t *testing.T) {
+	toggles := map[string]bool{
+		"existing": false,
+		"forced":   false,
+	}
+	server := mockUnleash(t, toggles)
+	defer server.Close()
+
+	err := seedToggles(context.Background(), newUnleashClient(server.URL, "admin-token"), []crd.FeatureFlagToggle{
+		{Name: "new-enabled", Enabled: true},
+		{Name: "new-disabled"},
+		{Name: "existing", Enabled: true},
+		{Name: "forced", Enabled: true, Force: true},
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]bool{
+		"new-enabled":  true,
+		"new-disabled": false,
+		"existing":     false,
+		"forced":       true,
+	}, toggles)
+}
+
+func TestSeedTogglesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	err := seedToggles(context.Background(), newUnleashClient(server.URL, "bad-token"), []crd.FeatureFlagToggle{
+		{Name: "toggle"},
+	})
+	assert.ErrorContains(t, err, "status 401")
+}
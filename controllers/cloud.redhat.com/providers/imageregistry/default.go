@@ -0,0 +1,56 @@
+package imageregistry
+
+import (
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/cronjob"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+)
+
+type imageRegistryProvider struct {
+	providers.Provider
+}
+
+// NewImageRegistryProvider returns a provider that applies the image registry overrides of the
+// environment to the containers of the app.
+func NewImageRegistryProvider(p *providers.Provider) (providers.ClowderProvider, error) {
+	return &imageRegistryProvider{Provider: *p}, nil
+}
+
+func (ir *imageRegistryProvider) EnvProvide() error {
+	return nil
+}
+
+// Provide rewrites the images of the main, init and sidecar containers of the deployments and
+// jobs of the app.
+func (ir *imageRegistryProvider) Provide(_ *crd.ClowdApp) error {
+	if len(ir.Env.Spec.ImageRegistryOverrides) == 0 {
+		return nil
+	}
+
+	err := deployment.EditPodTemplates(ir.Cache, func(t *core.PodTemplateSpec) error {
+		provutils.RewriteImages(ir.Env, &t.Spec)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	cjList := batch.CronJobList{}
+	if err := ir.Cache.List(cronjob.CoreCronJob, &cjList); err != nil {
+		return err
+	}
+
+	for _, cj := range cjList.Items {
+		innerCronJob := cj
+		provutils.RewriteImages(ir.Env, &innerCronJob.Spec.JobTemplate.Spec.Template.Spec)
+		if err := ir.Cache.Update(cronjob.CoreCronJob, &innerCronJob); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package imageregistry
+
+import (
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+)
+
+// ProvName sets the provider name identifier
+var ProvName = "imageregistry"
+
+// GetImageRegistry returns the correct end provider.
+func GetImageRegistry(c *providers.Provider) (providers.ClowderProvider, error) {
+	return NewImageRegistryProvider(c)
+}
+
+func init() {
+	// Runs after the sidecar provider so that every container of the app has been added
+	providers.ProvidersRegistration.Register(GetImageRegistry, 99, ProvName)
+}
@@ -1,15 +1,20 @@
 package inmemorydb
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"strconv"
+	"strings"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	obj "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/object"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
@@ -66,7 +71,8 @@ func (r *localRedis) Provide(app *crd.ClowdApp) error {
 	labeler := utils.MakeLabeler(nn, nil, app)
 	labeler(configMap)
 
-	configMap.Data = map[string]string{"redis.conf": "stop-writes-on-bgsave-error no\n"}
+	redisConfig := makeRedisConf(r.Env.Spec.Providers.InMemoryDB)
+	configMap.Data = map[string]string{"redis.conf": redisConfig}
 
 	err = r.Provider.Cache.Update(RedisConfigMap, configMap)
 
@@ -81,7 +87,89 @@ func (r *localRedis) Provide(app *crd.ClowdApp) error {
 		RedisService,
 	}
 
-	return providers.CachedMakeComponent(r.Provider.Cache, objList, app, "redis", makeLocalRedis, false, r.Env.IsNodePort())
+	if err := providers.CachedMakeComponent(r.Provider.Cache, objList, app, "redis", makeLocalRedis, false, r.Env.IsNodePort()); err != nil {
+		return err
+	}
+
+	d := &apps.Deployment{}
+	if err := r.Provider.Cache.Get(RedisDeployment, d); err != nil {
+		return err
+	}
+
+	if err := setRedisMemory(d, r.Env.Spec.Providers.InMemoryDB, redisConfig); err != nil {
+		return err
+	}
+
+	return r.Provider.Cache.Update(RedisDeployment, d)
+}
+
+// makeRedisConf renders the redis.conf of the Redis instances of the environment.
+func makeRedisConf(config crd.InMemoryDBConfig) string {
+	conf := "stop-writes-on-bgsave-error no\n"
+	if config.MaxMemory != "" {
+		conf += fmt.Sprintf("maxmemory %s\n", config.MaxMemory)
+	}
+	if config.MaxMemoryPolicy != "" {
+		conf += fmt.Sprintf("maxmemory-policy %s\n", config.MaxMemoryPolicy)
+	}
+	return conf
+}
+
+var redisMemoryUnits = map[string]int64{
+	"":   1,
+	"k":  1000,
+	"kb": 1024,
+	"m":  1000 * 1000,
+	"mb": 1024 * 1024,
+	"g":  1000 * 1000 * 1000,
+	"gb": 1024 * 1024 * 1024,
+}
+
+// parseRedisMemory parses a memory size in Redis' units into bytes.
+func parseRedisMemory(value string) (int64, error) {
+	lower := strings.ToLower(value)
+	digits := strings.TrimRight(lower, "kmgb")
+
+	multiplier, ok := redisMemoryUnits[lower[len(digits):]]
+	if !ok {
+		return 0, errors.NewClowderError(fmt.Sprintf("invalid redis memory size [%s]", value))
+	}
+
+	size, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil || size < 0 {
+		return 0, errors.NewClowderError(fmt.Sprintf("invalid redis memory size [%s]", value))
+	}
+	return size * multiplier, nil
+}
+
+// setRedisMemory sizes the memory of the Redis container for its maxmemory, the limit is a quarter
+// above it to leave room for Redis' own overhead. The pods are rolled when the config changes as
+// Redis only reads it at startup.
+func setRedisMemory(d *apps.Deployment, config crd.InMemoryDBConfig, redisConfig string) error {
+	utils.UpdateAnnotations(&d.Spec.Template, map[string]string{
+		"clowder/redis-config-hash": fmt.Sprintf("%x", sha256.Sum256([]byte(redisConfig)))[:16],
+	})
+
+	c := &d.Spec.Template.Spec.Containers[0]
+	if config.MaxMemory == "" {
+		c.Resources = core.ResourceRequirements{}
+		return nil
+	}
+
+	maxMemory, err := parseRedisMemory(config.MaxMemory)
+	if err != nil {
+		return err
+	}
+
+	c.Resources = core.ResourceRequirements{
+		Requests: core.ResourceList{
+			core.ResourceMemory: *resource.NewQuantity(maxMemory, resource.BinarySI),
+		},
+		Limits: core.ResourceList{
+			core.ResourceMemory: *resource.NewQuantity(maxMemory+maxMemory/4, resource.BinarySI),
+		},
+	}
+	return nil
 }
 
 func makeLocalRedis(o obj.ClowdObject, objMap providers.ObjectMap, _ bool, nodePort bool) {
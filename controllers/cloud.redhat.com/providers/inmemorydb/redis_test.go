@@ -43,3 +43,60 @@ func TestLocalRedis(t *testing.T) {
 	assert.Len(t, svc.Spec.Ports, 1, "number of ports specified is wrong")
 	assert.Equal(t, int32(6379), svc.Spec.Ports[0].Port, "port number is incorrect")
 }
+
+func TestRedisConf(t *testing.T) {
+	config := crd.InMemoryDBConfig{Mode: "redis"}
+	assert.Equal(t, "stop-writes-on-bgsave-error no\n", makeRedisConf(config))
+
+	config.MaxMemory = "256mb"
+	config.MaxMemoryPolicy = crd.RedisMaxMemoryPolicy("allkeys-lru")
+	assert.Equal(t, "stop-writes-on-bgsave-error no\nmaxmemory 256mb\nmaxmemory-policy allkeys-lru\n", makeRedisConf(config))
+}
+
+func TestParseRedisMemory(t *testing.T) {
+	for value, expected := range map[string]int64{
+		"1024":  1024,
+		"1k":    1000,
+		"1kb":   1024,
+		"256mb": 256 * 1024 * 1024,
+		"2G":    2 * 1000 * 1000 * 1000,
+	} {
+		size, err := parseRedisMemory(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, size, value)
+	}
+
+	for _, value := range []string{"", "mb", "1tb", "1bk", "-1"} {
+		_, err := parseRedisMemory(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestRedisMemory(t *testing.T) {
+	env := getRedisTestEnv()
+	env.Spec.Providers.InMemoryDB.MaxMemory = "100mb"
+
+	dd, svc := apps.Deployment{}, core.Service{}
+	objMap := providers.ObjectMap{
+		RedisDeployment: &dd,
+		RedisService:    &svc,
+	}
+	makeLocalRedis(&env, objMap, true, false)
+
+	conf := makeRedisConf(env.Spec.Providers.InMemoryDB)
+	assert.NoError(t, setRedisMemory(&dd, env.Spec.Providers.InMemoryDB, conf))
+
+	resources := dd.Spec.Template.Spec.Containers[0].Resources
+	assert.Equal(t, int64(100*1024*1024), resources.Requests.Memory().Value())
+	assert.Equal(t, int64(125*1024*1024), resources.Limits.Memory().Value())
+	hash := dd.Spec.Template.Annotations["clowder/redis-config-hash"]
+	assert.Len(t, hash, 16)
+
+	env.Spec.Providers.InMemoryDB.MaxMemoryPolicy = crd.RedisMaxMemoryPolicy("allkeys-lru")
+	conf = makeRedisConf(env.Spec.Providers.InMemoryDB)
+	assert.NoError(t, setRedisMemory(&dd, env.Spec.Providers.InMemoryDB, conf))
+	assert.NotEqual(t, hash, dd.Spec.Template.Annotations["clowder/redis-config-hash"], "config change does not roll the pods")
+
+	env.Spec.Providers.InMemoryDB.MaxMemory = "lots"
+	assert.Error(t, setRedisMemory(&dd, env.Spec.Providers.InMemoryDB, conf))
+}
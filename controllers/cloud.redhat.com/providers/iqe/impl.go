@@ -14,6 +14,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
 
@@ -37,7 +38,7 @@ func joinNullableSlice(s *[]string) string {
 	return ""
 }
 
-func createIqeContainer(j *batchv1.Job, nn types.NamespacedName, cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, app *crd.ClowdApp) *core.Container {
+func createIqeContainer(j *batchv1.Job, nn types.NamespacedName, cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, app *crd.ClowdApp) (*core.Container, error) {
 	// create env vars
 	iqePlugins := app.Spec.Testing.IqePlugin
 	if cji.Spec.Testing.Iqe.IqePlugins != "" {
@@ -65,17 +66,16 @@ func createIqeContainer(j *batchv1.Job, nn types.NamespacedName, cji *crd.ClowdJ
 		{Name: "IQE_PARALLEL_WORKER_COUNT", Value: cji.Spec.Testing.Iqe.ParallelWorkerCount},
 	}
 
-	// set image tag
-	iqeImage := env.Spec.Providers.Testing.Iqe.ImageBase
-
-	tag := "latest"
-	if app.Spec.Testing.IqePlugin != "" {
-		// ClowdApp has an IQE Plugin defined, use that image tag by default
-		tag = app.Spec.Testing.IqePlugin
+	image, err := getIqeImage(cji, env, app)
+	if err != nil {
+		return nil, err
 	}
-	if cji.Spec.Testing.Iqe.ImageTag != "" {
-		// this CJI has specified an image tag override
-		tag = cji.Spec.Testing.Iqe.ImageTag
+
+	// Because the tags on iqe plugins are not commit based, we need to pull everytime we run.
+	// A leftover tag from a previous run is never guaranteed to be up to date
+	pullPolicy := core.PullAlways
+	if isPinnedByDigest(image) {
+		pullPolicy = core.PullIfNotPresent
 	}
 
 	args := []string{"clowder"}
@@ -87,20 +87,53 @@ func createIqeContainer(j *batchv1.Job, nn types.NamespacedName, cji *crd.ClowdJ
 	pod := crd.PodSpec{Resources: env.Spec.Providers.Testing.Iqe.Resources}
 
 	c := core.Container{
-		Name:         j.Name,
-		Image:        fmt.Sprintf("%s:%s", iqeImage, tag),
-		Env:          envVars,
-		Resources:    deployProvider.ProcessResources(&pod, env),
-		VolumeMounts: []core.VolumeMount{},
-		Args:         args,
-		// Because the tags on iqe plugins are not commit based, we need to pull everytime we run.
-		// A leftover tag from a previous run is never guaranteed to be up to date
-		ImagePullPolicy:          core.PullAlways,
+		Name:                     j.Name,
+		Image:                    image,
+		Env:                      envVars,
+		Resources:                deployProvider.ProcessResources(&pod, env),
+		VolumeMounts:             []core.VolumeMount{},
+		Args:                     args,
+		ImagePullPolicy:          pullPolicy,
 		TerminationMessagePath:   "/dev/termination-log",
 		TerminationMessagePolicy: core.TerminationMessageReadFile,
 	}
 
-	return &c
+	return &c, nil
+}
+
+// getIqeImage works out the image of the IQE container. A full image on the CJI wins, followed by
+// a tag override on the CJI, the environment's default image and finally the environment's image
+// base tagged with the app's IQE plugin.
+func getIqeImage(cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, app *crd.ClowdApp) (string, error) {
+	iqeConfig := env.Spec.Providers.Testing.Iqe
+
+	var image string
+	switch {
+	case cji.Spec.Testing.Iqe.Image != "":
+		image = cji.Spec.Testing.Iqe.Image
+	case cji.Spec.Testing.Iqe.ImageTag != "":
+		// this CJI has specified an image tag override
+		image = fmt.Sprintf("%s:%s", iqeConfig.ImageBase, cji.Spec.Testing.Iqe.ImageTag)
+	case iqeConfig.Image != "":
+		image = iqeConfig.Image
+	case app.Spec.Testing.IqePlugin != "":
+		// ClowdApp has an IQE Plugin defined, use that image tag by default
+		image = fmt.Sprintf("%s:%s", iqeConfig.ImageBase, app.Spec.Testing.IqePlugin)
+	default:
+		image = fmt.Sprintf("%s:latest", iqeConfig.ImageBase)
+	}
+
+	image = provutils.RewriteImage(env, image)
+
+	if iqeConfig.RequireDigest && !isPinnedByDigest(image) {
+		return "", errors.NewClowderError(fmt.Sprintf("iqe image [%s] must be pinned by digest", image))
+	}
+
+	return image, nil
+}
+
+func isPinnedByDigest(image string) bool {
+	return strings.Contains(image, "@")
 }
 
 func createSeleniumContainer(j *batchv1.Job, cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment) *core.Container {
@@ -124,7 +157,7 @@ func createSeleniumContainer(j *batchv1.Job, cji *crd.ClowdJobInvocation, env *c
 
 	c := core.Container{
 		Name:                     fmt.Sprintf("%s-%s", j.Name, "sel"),
-		Image:                    fmt.Sprintf("%s:%s", image, tag),
+		Image:                    provutils.RewriteImage(env, fmt.Sprintf("%s:%s", image, tag)),
 		Resources:                deployProvider.ProcessResources(&pod, env),
 		ImagePullPolicy:          core.PullIfNotPresent,
 		TerminationMessagePath:   "/dev/termination-log",
@@ -148,7 +181,7 @@ func createSeleniumContainer(j *batchv1.Job, cji *crd.ClowdJobInvocation, env *c
 func attachConfigVolumes(ctx context.Context, c *core.Container, cache *rc.ObjectCache, cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, app *crd.ClowdApp, j *batchv1.Job, logger logr.Logger, client client.Client) error {
 	j.Spec.Template.Spec.Volumes = []core.Volume{}
 
-	configAccess := env.Spec.Providers.Testing.ConfigAccess
+	configAccess := provutils.GetTestingConfigAccess(env)
 
 	switch configAccess {
 	// Build cdenvconfig.json and mount it
@@ -220,7 +253,10 @@ func CreateIqeJobResource(ctx context.Context, cache *rc.ObjectCache, cji *crd.C
 	j.Spec.Template.Spec.ServiceAccountName = fmt.Sprintf("iqe-%s", app.Spec.EnvName)
 
 	// build IQE container config
-	iqeContainer := createIqeContainer(j, nn, cji, env, app)
+	iqeContainer, err := createIqeContainer(j, nn, cji, env, app)
+	if err != nil {
+		return err
+	}
 
 	// apply vault env vars to container if vaultSecretRef exists in environment
 	nullSecretRef := crd.NamespacedName{}
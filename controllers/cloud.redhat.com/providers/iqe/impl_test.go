@@ -0,0 +1,93 @@
+package iqe
+
+import (
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const pinnedImage = "quay.io/org/iqe-tests@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+func iqeTestObjects(envIqe crd.IqeConfig, cjiIqe crd.IqeJobSpec) (*crd.ClowdJobInvocation, *crd.ClowdEnvironment, *crd.ClowdApp) {
+	envIqe.ImageBase = "quay.io/cloudservices/iqe-tests"
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Testing: crd.TestingConfig{Iqe: envIqe},
+			},
+		},
+	}
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: crd.ClowdAppSpec{
+			EnvName: "env",
+			Testing: crd.TestingSpec{IqePlugin: "plugin"},
+		},
+	}
+	cji := &crd.ClowdJobInvocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "cji", Namespace: "default"},
+		Spec: crd.ClowdJobInvocationSpec{
+			AppName: "app",
+			Testing: crd.JobTestingSpec{Iqe: cjiIqe},
+		},
+	}
+	return cji, env, app
+}
+
+func iqeTestContainer(envIqe crd.IqeConfig, cjiIqe crd.IqeJobSpec) (*core.Container, error) {
+	cji, env, app := iqeTestObjects(envIqe, cjiIqe)
+	nn := types.NamespacedName{Name: "cji-iqe", Namespace: "default"}
+	j := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace}}
+	return createIqeContainer(j, nn, cji, env, app)
+}
+
+func TestIqeImageDefault(t *testing.T) {
+	c, err := iqeTestContainer(crd.IqeConfig{}, crd.IqeJobSpec{})
+	assert.NoError(t, err)
+	assert.Equal(t, "quay.io/cloudservices/iqe-tests:plugin", c.Image)
+	assert.Equal(t, core.PullAlways, c.ImagePullPolicy)
+}
+
+func TestIqeImageOverride(t *testing.T) {
+	// the environment's default image replaces the image base and plugin tag...
+	c, err := iqeTestContainer(crd.IqeConfig{Image: pinnedImage}, crd.IqeJobSpec{})
+	assert.NoError(t, err)
+	assert.Equal(t, pinnedImage, c.Image)
+	assert.Equal(t, core.PullIfNotPresent, c.ImagePullPolicy)
+
+	// ...a tag override on the CJI still applies to the image base...
+	c, err = iqeTestContainer(crd.IqeConfig{Image: pinnedImage}, crd.IqeJobSpec{ImageTag: "pr-123"})
+	assert.NoError(t, err)
+	assert.Equal(t, "quay.io/cloudservices/iqe-tests:pr-123", c.Image)
+
+	// ...and a full image on the CJI wins over everything
+	c, err = iqeTestContainer(crd.IqeConfig{Image: "quay.io/org/other:latest"}, crd.IqeJobSpec{Image: pinnedImage, ImageTag: "pr-123"})
+	assert.NoError(t, err)
+	assert.Equal(t, pinnedImage, c.Image)
+}
+
+func TestIqeImageRequireDigest(t *testing.T) {
+	_, err := iqeTestContainer(crd.IqeConfig{RequireDigest: true}, crd.IqeJobSpec{})
+	assert.ErrorContains(t, err, "iqe image [quay.io/cloudservices/iqe-tests:plugin] must be pinned by digest")
+
+	c, err := iqeTestContainer(crd.IqeConfig{RequireDigest: true}, crd.IqeJobSpec{Image: pinnedImage})
+	assert.NoError(t, err)
+	assert.Equal(t, pinnedImage, c.Image)
+}
+
+func TestIqeImageRegistryOverride(t *testing.T) {
+	cji, env, app := iqeTestObjects(crd.IqeConfig{}, crd.IqeJobSpec{})
+	env.Spec.ImageRegistryOverrides = []crd.ImageRegistryOverride{
+		{Prefix: "quay.io/cloudservices/", Replacement: "registry.internal/cloudservices/"},
+	}
+
+	image, err := getIqeImage(cji, env, app)
+	assert.NoError(t, err)
+	assert.Equal(t, "registry.internal/cloudservices/iqe-tests:plugin", image)
+}
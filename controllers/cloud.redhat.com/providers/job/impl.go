@@ -42,8 +42,7 @@ func CreateJobResource(cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, a
 		j.Spec.Completions = job.Completions
 	}
 
-	envvar := pod.Env
-	envvar = append(envvar, core.EnvVar{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"})
+	envvar := deployProvider.LoadEnvVars(pod)
 
 	var livenessProbe core.Probe
 	var readinessProbe core.Probe
@@ -77,16 +76,10 @@ func CreateJobResource(cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, a
 		ImagePullPolicy:          core.PullIfNotPresent,
 	}
 
-	if pod.MachinePool != "" {
-		j.Spec.Template.Spec.Tolerations = []core.Toleration{{
-			Key:      pod.MachinePool,
-			Effect:   core.TaintEffectNoSchedule,
-			Operator: core.TolerationOpEqual,
-			Value:    "true",
-		}}
-	} else {
-		j.Spec.Template.Spec.Tolerations = []core.Toleration{}
-	}
+	deployProvider.SetScheduling(&j.Spec.Template, &pod, env)
+	deployProvider.SetImagePullSecrets(&j.Spec.Template, env)
+
+	j.Spec.Template.Spec.AutomountServiceAccountToken = pod.AutomountServiceAccountToken
 
 	if !env.Spec.Providers.Deployment.OmitPullPolicy {
 		c.ImagePullPolicy = core.PullIfNotPresent
@@ -113,10 +106,14 @@ func CreateJobResource(cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, a
 	j.Spec.Template.Spec.ServiceAccountName = app.GetClowdSAName()
 
 	c.VolumeMounts = append(c.VolumeMounts, core.VolumeMount{
-		Name:      "config-secret",
-		MountPath: "/cdapp/",
+		Name:      deployProvider.ConfigSecretVolumeName,
+		MountPath: deployProvider.ConfigMountPath(pod),
 	})
 
+	if err := deployProvider.ValidateConfigMount(&c); err != nil {
+		return err
+	}
+
 	j.Spec.Template.Spec.Containers = []core.Container{c}
 
 	ics, err := deployProvider.ProcessInitContainers(nn, &c, pod.InitContainers)
@@ -129,18 +126,23 @@ func CreateJobResource(cji *crd.ClowdJobInvocation, env *crd.ClowdEnvironment, a
 
 	j.Spec.Template.Spec.Volumes = pod.Volumes
 	j.Spec.Template.Spec.Volumes = append(j.Spec.Template.Spec.Volumes, core.Volume{
-		Name: "config-secret",
+		Name: deployProvider.ConfigSecretVolumeName,
 		VolumeSource: core.VolumeSource{
 			Secret: &core.SecretVolumeSource{
 				SecretName: cji.Spec.AppName,
+				Items:      deployProvider.ConfigSecretItems(pod),
 			},
 		},
 	})
 
 	if env.Spec.Providers.Web.TLS.Enabled {
-		provutils.AddCertVolume(&j.Spec.Template.Spec, nn.Name)
+		provutils.AddCertVolume(&j.Spec.Template.Spec, nn.Name, provutils.TLSCAConfigMapName(env, cji.Spec.AppName))
 	}
 
+	provutils.RewriteImages(env, &j.Spec.Template.Spec)
+
+	deployProvider.ApplyPodAntiAffinity(&j.Spec.Template)
+
 	utils.UpdateAnnotations(&j.Spec.Template, provutils.KubeLinterAnnotations, cji.Annotations)
 	utils.UpdateAnnotations(j, provutils.KubeLinterAnnotations, app.ObjectMeta.Annotations)
 
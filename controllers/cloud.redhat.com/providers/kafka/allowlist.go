@@ -0,0 +1,84 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+)
+
+// TopicAllowListProvName is the name/ident of the topic allow-list provider
+var TopicAllowListProvName = "kafka-topic-allow-list"
+
+// TopicAllowListKey is the key in the allow-list ConfigMap holding the JSON list of topic names.
+var TopicAllowListKey = "topics.json"
+
+// TopicAllowList identifies the ConfigMap listing the topics a ClowdApp has declared.
+var TopicAllowList = rc.NewSingleResourceIdent(TopicAllowListProvName, "topic_allow_list", &core.ConfigMap{})
+
+type topicAllowListProvider struct {
+	providers.Provider
+}
+
+// GetTopicAllowList returns the provider generating the producer topic allow-list. It runs after
+// the kafka provider so that the topic names have already been resolved for the app config.
+func GetTopicAllowList(c *providers.Provider) (providers.ClowderProvider, error) {
+	c.Cache.AddPossibleGVKFromIdent(
+		TopicAllowList,
+	)
+	return &topicAllowListProvider{Provider: *c}, nil
+}
+
+func (t *topicAllowListProvider) EnvProvide() error {
+	return nil
+}
+
+func (t *topicAllowListProvider) Provide(app *crd.ClowdApp) error {
+	if t.Config.Kafka == nil || len(t.Config.Kafka.Topics) == 0 {
+		return nil
+	}
+
+	nn := GetTopicAllowListName(app)
+
+	cm := &core.ConfigMap{}
+	if err := t.Cache.Create(TopicAllowList, nn, cm); err != nil {
+		return err
+	}
+
+	app.SetObjectMeta(cm, crd.Name(nn.Name))
+
+	data, err := makeTopicAllowList(t.Config.Kafka.Topics)
+	if err != nil {
+		return err
+	}
+	cm.Data = map[string]string{TopicAllowListKey: data}
+
+	return t.Cache.Update(TopicAllowList, cm)
+}
+
+// GetTopicAllowListName returns the name of the topic allow-list ConfigMap for the app.
+func GetTopicAllowListName(app *crd.ClowdApp) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("%s-kafka-topics", app.Name),
+		Namespace: app.Namespace,
+	}
+}
+
+func makeTopicAllowList(topics []config.TopicConfig) (string, error) {
+	names := []string{}
+	for _, topic := range topics {
+		names = append(names, topic.Name)
+	}
+
+	data, err := json.Marshal(names)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
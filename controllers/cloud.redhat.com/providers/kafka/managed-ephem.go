@@ -7,13 +7,16 @@ import (
 	"net/http"
 	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 
 	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"golang.org/x/oauth2/clientcredentials"
 
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
@@ -23,8 +26,10 @@ import (
 
 	core "k8s.io/api/core/v1"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
@@ -100,11 +105,13 @@ func (mep *managedEphemProvider) Provide(app *crd.ClowdApp) error {
 		return err
 	}
 
-	if len(app.Spec.KafkaTopics) == 0 {
-		return nil
+	if len(app.Spec.KafkaTopics) > 0 {
+		if err := mep.processTopics(app, httpClient, adminHostname); err != nil {
+			return err
+		}
 	}
 
-	return mep.processTopics(app, httpClient, adminHostname)
+	return mep.deleteOrphanedTopics(app, httpClient, adminHostname)
 }
 
 func NewManagedEphemKafkaFinalizer(p *providers.Provider) error {
@@ -127,7 +134,12 @@ func NewManagedEphemKafkaFinalizer(p *providers.Provider) error {
 		return err
 	}
 
-	err = deleteTopics(topicList, rClient, adminHostname, p)
+	managed, err := getManagedTopics(p)
+	if err != nil {
+		return err
+	}
+
+	err = deleteTopics(topicList, managed, rClient, adminHostname, p)
 
 	return err
 }
@@ -147,6 +159,9 @@ var ClientCreator func(provider *providers.Provider, clientCred clientcredential
 func init() {
 	ClientCreator = func(provider *providers.Provider, clientCred clientcredentials.Config) HTTPClient {
 		client := clientCred.Client(provider.Ctx)
+		if clowderconfig.TracingEndpoint() != "" {
+			client.Transport = otelhttp.NewTransport(client.Transport)
+		}
 		return client
 	}
 }
@@ -172,7 +187,13 @@ type TopicsList struct {
 }
 
 type Topic struct {
-	Name string `json:"name"`
+	Name       string           `json:"name"`
+	Partitions []TopicPartition `json:"partitions,omitempty"`
+	Config     []Config         `json:"config,omitempty"`
+}
+
+type TopicPartition struct {
+	Partition int `json:"partition"`
 }
 
 // Mutex protected cache of HTTP clients
@@ -183,52 +204,361 @@ const ReplicaNumCeiling = 0
 const PartitionNumFloor = 3
 const PartitionNumCeiling = 3
 
-func deleteTopics(topicList *TopicsList, rClient HTTPClient, adminHostname string, p *providers.Provider) error {
-	// "(env-)?ephemeral-.*"
+// defaultDeleteConcurrency is how many topics are deleted at once when the concurrency is not
+// configured.
+const defaultDeleteConcurrency = 10
 
-	reg, err := regexp.Compile(fmt.Sprintf(".*%s.*", strings.ReplaceAll(p.Env.Name, "-", "[-\\.]")))
+// deleteTopics deletes the topics that Clowder created for the environment, topics that were
+// not created by Clowder are never touched, whatever their name.
+func deleteTopics(topicList *TopicsList, managed map[string]bool, rClient HTTPClient, adminHostname string, p *providers.Provider) error {
+	toDelete, err := deletableTopics(topicList, managed)
 	if err != nil {
 		return err
 	}
 
-	var regProtect *regexp.Regexp
+	return deleteTopicNames(toDelete, rClient, adminHostname, p)
+}
 
-	regProtect, err = regexp.Compile(clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex)
+// deletableTopics returns the topics of the list that were created by Clowder and match the global
+// topic protector.
+func deletableTopics(topicList *TopicsList, managed map[string]bool) ([]string, error) {
+	regProtect, err := regexp.Compile(clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	toDelete := []string{}
 	for _, topic := range topicList.Items {
-		// The name of the environment must be in the topic names
-		fmt.Print(reg.String())
-		if reg.Find([]byte(topic.Name)) == nil {
+		// Only topics marked as created by Clowder are deleted
+		if !managed[topic.Name] {
 			continue
 		}
 
 		// The name must also match the global topic protector
-		if regProtect == nil || (regProtect != nil && regProtect.Find([]byte(topic.Name)) == nil) {
+		if regProtect.Find([]byte(topic.Name)) == nil {
 			continue
 		}
 
-		req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/topics/%s", adminHostname, topic.Name), nil)
+		toDelete = append(toDelete, topic.Name)
+	}
+	return toDelete, nil
+}
+
+// deleteTopicNames deletes the named topics with a bounded pool of workers, the first error
+// encountered is returned once all of them are done.
+func deleteTopicNames(toDelete []string, rClient HTTPClient, adminHostname string, p *providers.Provider) error {
+	concurrency := clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteConcurrency
+	if concurrency <= 0 {
+		concurrency = defaultDeleteConcurrency
+	}
+
+	names := make(chan string)
+	errs := make(chan error, len(toDelete))
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency && i < len(toDelete); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				errs <- deleteTopic(name, rClient, adminHostname, p)
+			}
+		}()
+	}
+
+	for _, name := range toDelete {
+		names <- name
+	}
+	close(names)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
 		if err != nil {
 			return err
 		}
-		resp, err := rClient.Do(req)
-		if err != nil {
+	}
+	return nil
+}
+
+func deleteTopic(name string, rClient HTTPClient, adminHostname string, p *providers.Provider) error {
+	p.Log.Info("Deleting managed topic", "topic", name)
+
+	req, err := http.NewRequest("DELETE", fmt.Sprintf("%s/api/v1/topics/%s", adminHostname, name), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := rClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != 204 && resp.StatusCode != 200 {
+		return fmt.Errorf("error in delete %s", body)
+	}
+	return nil
+}
+
+// The topics of the managed kafka admin API can't carry labels, instead the topics that Clowder
+// creates are marked in a ConfigMap kept alongside the managed kafka secret, each key of which is
+// the name of a topic.
+func getManagedTopicsNamespacedName(env *crd.ClowdEnvironment) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      fmt.Sprintf("%s-managed-topics", env.Name),
+		Namespace: env.Spec.Providers.Kafka.EphemManagedSecretRef.Namespace,
+	}
+}
+
+// getManagedTopics returns the topics that Clowder created for the environment.
+func getManagedTopics(p *providers.Provider) (map[string]bool, error) {
+	managed := map[string]bool{}
+
+	cm := &core.ConfigMap{}
+	if err := p.Client.Get(p.Ctx, getManagedTopicsNamespacedName(p.Env), cm); err != nil {
+		if k8serr.IsNotFound(err) {
+			return managed, nil
+		}
+		return nil, err
+	}
+
+	for name := range cm.Data {
+		managed[name] = true
+	}
+	return managed, nil
+}
+
+// markManagedTopic records that a topic is requested by an app of the environment, so that it is
+// deleted when the environment is torn down. Topics are marked before they are created, whether or
+// not they exist already, so that a topic is never left behind unmarked. The ConfigMap is written
+// by the concurrent reconciles of the apps, so the write is retried on a conflict.
+func markManagedTopic(p *providers.Provider, topicName string) error {
+	nn := getManagedTopicsNamespacedName(p.Env)
+
+	return retry.OnError(retry.DefaultRetry, isManagedTopicsConflict, func() error {
+		cm := &core.ConfigMap{}
+		err := p.Client.Get(p.Ctx, nn, cm)
+		if err != nil && !k8serr.IsNotFound(err) {
 			return err
 		}
-		defer resp.Body.Close()
 
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
+		if k8serr.IsNotFound(err) {
+			cm = &core.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            nn.Name,
+					Namespace:       nn.Namespace,
+					Labels:          providers.Labels{"env": p.Env.Name},
+					OwnerReferences: []metav1.OwnerReference{p.Env.MakeOwnerReference()},
+				},
+				Data: map[string]string{topicName: ""},
+			}
+			return p.Client.Create(p.Ctx, cm)
+		}
+
+		if _, ok := cm.Data[topicName]; ok {
+			return nil
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[topicName] = ""
+		return p.Client.Update(p.Ctx, cm)
+	})
+}
+
+// unmarkManagedTopics forgets the given topics once they have been deleted.
+func unmarkManagedTopics(p *providers.Provider, topicNames []string) error {
+	if len(topicNames) == 0 {
+		return nil
+	}
+
+	return retry.OnError(retry.DefaultRetry, isManagedTopicsConflict, func() error {
+		cm := &core.ConfigMap{}
+		if err := p.Client.Get(p.Ctx, getManagedTopicsNamespacedName(p.Env), cm); err != nil {
+			if k8serr.IsNotFound(err) {
+				return nil
+			}
 			return err
 		}
-		if resp.StatusCode != 204 && resp.StatusCode != 200 {
-			return fmt.Errorf("error in delete %s", body)
+
+		changed := false
+		for _, name := range topicNames {
+			if _, ok := cm.Data[name]; ok {
+				delete(cm.Data, name)
+				changed = true
+			}
 		}
+		if !changed {
+			return nil
+		}
+		return p.Client.Update(p.Ctx, cm)
+	})
+}
+
+// isManagedTopicsConflict reports whether the ConfigMap of the managed topics was written by
+// another reconcile in the meantime, either updated or created.
+func isManagedTopicsConflict(err error) bool {
+	return k8serr.IsConflict(err) || k8serr.IsAlreadyExists(err)
+}
+
+// deleteOrphanedTopics deletes the topics that Clowder created for the environment but that no
+// app in it requests any more, which happens when a topic or the app requesting it is renamed or
+// removed. The marked topics are read before the apps are listed, so that a topic created by a
+// concurrent reconcile is always requested by one of the listed apps. As for the teardown of the
+// environment, nothing is deleted unless the delete regex is configured.
+func (mep *managedEphemProvider) deleteOrphanedTopics(app *crd.ClowdApp, httpClient HTTPClient, adminHostname string) error {
+	if mep.DryRun || clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex == "" {
+		return nil
 	}
-	return nil
+
+	managed, err := getManagedTopics(&mep.Provider)
+	if err != nil {
+		return err
+	}
+	if len(managed) == 0 {
+		return nil
+	}
+
+	appList, err := mep.Env.GetAppsInEnv(mep.Ctx, mep.Client)
+	if err != nil {
+		return errors.Wrap("Topic cleanup failed: Error listing apps", err)
+	}
+
+	// the app being reconciled may be newer than the listed copy
+	requested := map[string]bool{}
+	for _, topic := range app.Spec.KafkaTopics {
+		requested[ephemGetTopicName(topic, *mep.Env)] = true
+	}
+	for _, iapp := range appList.Items {
+		if iapp.Name == app.Name && iapp.Namespace == app.Namespace {
+			continue
+		}
+		if iapp.GetDeletionTimestamp() != nil {
+			continue
+		}
+		for _, topic := range iapp.Spec.KafkaTopics {
+			requested[ephemGetTopicName(topic, *mep.Env)] = true
+		}
+	}
+
+	orphaned := map[string]bool{}
+	for name := range managed {
+		if !requested[name] {
+			orphaned[name] = true
+		}
+	}
+	return deleteManagedTopics(&mep.Provider, orphaned, httpClient, adminHostname)
+}
+
+// deleteManagedTopics deletes the given topics that Clowder created, subject to the delete regex,
+// and forgets them. Topics that are already gone from kafka are forgotten too, so a retry after a
+// partial failure only deletes what is left.
+func deleteManagedTopics(p *providers.Provider, topics map[string]bool, httpClient HTTPClient, adminHostname string) error {
+	if len(topics) == 0 {
+		return nil
+	}
+
+	topicList, err := getTopicList(httpClient, adminHostname, p)
+	if err != nil {
+		return err
+	}
+
+	toDelete, err := deletableTopics(topicList, topics)
+	if err != nil {
+		return err
+	}
+
+	if err := deleteTopicNames(toDelete, httpClient, adminHostname, p); err != nil {
+		return err
+	}
+
+	// topics kept by the delete regex stay marked
+	live := map[string]bool{}
+	for _, topic := range topicList.Items {
+		live[topic.Name] = true
+	}
+	forget := toDelete
+	for name := range topics {
+		if !live[name] {
+			forget = append(forget, name)
+		}
+	}
+
+	return unmarkManagedTopics(p, forget)
+}
+
+// ephemAppFinalizeTimeout bounds how long the topics of a deleted app are retried for, once it has
+// passed the app is deleted regardless and the topics left behind are cleaned up as orphans.
+const ephemAppFinalizeTimeout = 10 * time.Minute
+
+// finalizeManagedEphemApp deletes the topics that Clowder created for a deleted app and that no
+// other app in the environment requests.
+func finalizeManagedEphemApp(p *providers.Provider, app *crd.ClowdApp) error {
+	err := deleteAppTopics(p, app)
+	if err == nil {
+		return nil
+	}
+
+	deleting := app.GetDeletionTimestamp()
+	if deleting != nil && time.Since(deleting.Time) > ephemAppFinalizeTimeout {
+		p.Log.Error(err, "Giving up on deleting the managed kafka topics of the app", "app", app.Name, "namespace", app.Namespace)
+		return nil
+	}
+	return err
+}
+
+func deleteAppTopics(p *providers.Provider, app *crd.ClowdApp) error {
+	if p.DryRun || clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex == "" || len(app.Spec.KafkaTopics) == 0 {
+		return nil
+	}
+
+	managed, err := getManagedTopics(p)
+	if err != nil {
+		return err
+	}
+
+	appList, err := p.Env.GetAppsInEnv(p.Ctx, p.Client)
+	if err != nil {
+		return errors.Wrap("Topic cleanup failed: Error listing apps", err)
+	}
+
+	requested := map[string]bool{}
+	for _, iapp := range appList.Items {
+		if iapp.Name == app.Name && iapp.Namespace == app.Namespace {
+			continue
+		}
+		if iapp.GetDeletionTimestamp() != nil {
+			continue
+		}
+		for _, topic := range iapp.Spec.KafkaTopics {
+			requested[ephemGetTopicName(topic, *p.Env)] = true
+		}
+	}
+
+	owned := map[string]bool{}
+	for _, topic := range app.Spec.KafkaTopics {
+		name := ephemGetTopicName(topic, *p.Env)
+		if managed[name] && !requested[name] {
+			owned[name] = true
+		}
+	}
+	if len(owned) == 0 {
+		return nil
+	}
+
+	sec, err := getSecret(p)
+	if err != nil {
+		return err
+	}
+
+	username, password, _, adminHostname, tokenURL, _ := destructureSecret(sec)
+	httpClient := upsertClientCache(username, password, tokenURL, adminHostname, p)
+
+	return deleteManagedTopics(p, owned, httpClient, adminHostname)
 }
 
 func destructureSecret(sec *core.Secret) (string, string, string, string, string, string) {
@@ -246,7 +576,12 @@ func destructureSecret(sec *core.Secret) (string, string, string, string, string
 	return username, password, hostname, adminHostname, tokenURL, cacert
 }
 
+// ephemGetTopicName returns the name of the topic created in managed-ephem mode for a topic
+// requested by an app.
 func ephemGetTopicName(topic crd.KafkaTopicSpec, env crd.ClowdEnvironment) string {
+	if name, ok := strategyTopicName(topic, env); ok {
+		return name
+	}
 	return fmt.Sprintf("%s-%s", env.Name, topic.TopicName)
 }
 
@@ -257,7 +592,7 @@ func getSecret(p *providers.Provider) (*core.Secret, error) {
 		Namespace: p.Env.Spec.Providers.Kafka.EphemManagedSecretRef.Namespace,
 	}
 
-	err := p.Client.Get(p.Ctx, nn, sec)
+	err := p.GetShared(nn, sec)
 
 	return sec, err
 }
@@ -388,6 +723,7 @@ func (mep *managedEphemProvider) processTopics(app *crd.ClowdApp, httpClient HTT
 	if err != nil {
 		return errors.Wrap("Topic creation failed: Error listing apps", err)
 	}
+	appList = withCurrentApp(appList, app)
 
 	for _, topic := range app.Spec.KafkaTopics {
 		topicName := ephemGetTopicName(topic, *mep.Env)
@@ -436,23 +772,25 @@ func (mep *managedEphemProvider) getAppTopicTopology(appList *crd.ClowdAppList,
 	return keys, replicaValList, partitionValList
 }
 
-func (mep *managedEphemProvider) getTopicConfigs(keys map[string][]string) ([]Config, error) {
-	topicConfig := []Config{}
+func (mep *managedEphemProvider) getTopicConfigs(topicName string, keys map[string][]string) ([]Config, error) {
+	merged, err := mergeTopicConfig(mep.Log, topicName, keys)
+	if err != nil {
+		return nil, err
+	}
 
-	for key, valList := range keys {
-		f, ok := conversionMap[key]
-		if ok {
-			out, _ := f(valList)
-
-			topicConfig = append(topicConfig, Config{
-				Key:   key,
-				Value: out,
-			})
-		} else {
-			return topicConfig, errors.NewClowderError(fmt.Sprintf("no conversion type for %s", key))
-		}
+	topicConfig := []Config{}
+	for key, value := range merged {
+		topicConfig = append(topicConfig, Config{
+			Key:   key,
+			Value: value,
+		})
 	}
 
+	// keep the payload stable across reconciles
+	sort.Slice(topicConfig, func(i, j int) bool {
+		return topicConfig[i].Key < topicConfig[j].Key
+	})
+
 	return topicConfig, nil
 }
 
@@ -482,7 +820,7 @@ func (mep *managedEphemProvider) getTopicSettings(appList *crd.ClowdAppList, top
 	settings := Settings{}
 	keys, replicaValList, partitionValList := mep.getAppTopicTopology(appList, topic)
 
-	topicConfig, err := mep.getTopicConfigs(keys)
+	topicConfig, err := mep.getTopicConfigs(topic.TopicName, keys)
 	if err != nil {
 		return settings, err
 	}
@@ -512,13 +850,56 @@ func (mep *managedEphemProvider) getTopicSettings(appList *crd.ClowdAppList, top
 	return settings, nil
 }
 
-func (mep *managedEphemProvider) getTopicFromKafka(newTopicName string, httpClient HTTPClient, adminHostname string) (*http.Response, error) {
+// getTopicFromKafka fetches the live topic from the managed kafka admin API. The returned bool
+// reports whether the topic exists. If the topic exists but its body can't be decoded, a nil topic
+// is returned and the topic is treated as having drifted.
+func (mep *managedEphemProvider) getTopicFromKafka(newTopicName string, httpClient HTTPClient, adminHostname string) (*Topic, bool, error) {
 	resp, err := httpClient.Get(fmt.Sprintf("%s/api/v1/topics/%s", adminHostname, newTopicName))
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
-	resp.Body.Close()
-	return resp, nil
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, false, nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, true, nil
+	}
+
+	topic := &Topic{}
+	if err := json.Unmarshal(body, topic); err != nil {
+		return nil, true, nil
+	}
+
+	return topic, true, nil
+}
+
+// topicSettingsDrifted compares the live topic against the declared settings, only the partition
+// count and the declared config keys are considered as these are the values we PATCH.
+func topicSettingsDrifted(live *Topic, settings Settings) bool {
+	if live == nil {
+		return true
+	}
+
+	if settings.NumPartitions > 0 && len(live.Partitions) != settings.NumPartitions {
+		return true
+	}
+
+	liveConfig := map[string]string{}
+	for _, c := range live.Config {
+		liveConfig[c.Key] = c.Value
+	}
+
+	for _, c := range settings.Config {
+		if val, ok := liveConfig[c.Key]; !ok || val != c.Value {
+			return true
+		}
+	}
+
+	return false
 }
 
 func (mep *managedEphemProvider) createTopicOnKafka(newTopicName string, settings Settings, httpClient HTTPClient, adminHostname string) error {
@@ -542,7 +923,6 @@ func (mep *managedEphemProvider) createTopicOnKafka(newTopicName string, setting
 	resp.Body.Close()
 
 	return mep.handleKafkaHTTPError(resp, "bad error status code creating")
-
 }
 
 func (mep *managedEphemProvider) updateTopicOnKafka(newTopicName string, settings Settings, httpClient HTTPClient, adminHostname string) error {
@@ -592,19 +972,35 @@ func (mep *managedEphemProvider) ephemProcessTopicValues(
 		return err
 	}
 
-	resp, err := mep.getTopicFromKafka(newTopicName, httpClient, adminHostname)
+	live, exists, err := mep.getTopicFromKafka(newTopicName, httpClient, adminHostname)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode == 404 {
-		err = mep.createTopicOnKafka(newTopicName, settings, httpClient, adminHostname)
-	} else {
-		err = mep.updateTopicOnKafka(newTopicName, settings, httpClient, adminHostname)
+	if mep.DryRun {
+		return nil
 	}
 
-	return err
+	if err := markManagedTopic(&mep.Provider, newTopicName); err != nil {
+		return err
+	}
+
+	if !exists {
+		return mep.createTopicOnKafka(newTopicName, settings, httpClient, adminHostname)
+	}
+
+	// Kafka can add partitions to a topic but never remove them, so a topic keeps the partitions
+	// it has once the largest request of the apps falls below them
+	if live != nil && len(live.Partitions) > settings.NumPartitions {
+		settings.NumPartitions = len(live.Partitions)
+	}
+
+	if topic.IgnoreConfigDrift || !topicSettingsDrifted(live, settings) {
+		return nil
+	}
+
+	mep.Log.Info("Patching drifted topic", "topic", newTopicName)
+	return mep.updateTopicOnKafka(newTopicName, settings, httpClient, adminHostname)
 }
 
 // Client cache provides a mutex protected cache of http clients
@@ -1,10 +1,28 @@
 package kafka
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 )
 
 func TestHTTPClientCacheSet(_ *testing.T) {
@@ -29,3 +47,561 @@ func TestHTTPClientCacheRemove(_ *testing.T) {
 	hcc.Set("test", &client)
 	hcc.Remove("test")
 }
+
+type mockDriftHTTPClient struct {
+	live    Topic
+	patches []Settings
+}
+
+func (m *mockDriftHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method == "PATCH" {
+		settings := Settings{}
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &settings); err != nil {
+			return nil, err
+		}
+		m.patches = append(m.patches, settings)
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func (m *mockDriftHTTPClient) Get(_ string) (*http.Response, error) {
+	body, err := json.Marshal(m.live)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+func (m *mockDriftHTTPClient) Post(_, _ string, _ io.Reader) (*http.Response, error) {
+	return &http.Response{StatusCode: 201, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func driftTestLiveTopic(retention string) Topic {
+	return Topic{
+		Name:       "env-inventory",
+		Partitions: []TopicPartition{{Partition: 0}, {Partition: 1}, {Partition: 2}},
+		Config:     []Config{{Key: "retention.ms", Value: retention}},
+	}
+}
+
+func runDriftTest(t *testing.T, live Topic, ignoreDrift bool) *mockDriftHTTPClient {
+	mock, err := runDriftTestErr(live, ignoreDrift)
+	assert.NoError(t, err)
+	return mock
+}
+
+func runDriftTestErr(live Topic, ignoreDrift bool) (*mockDriftHTTPClient, error) {
+	topic := crd.KafkaTopicSpec{
+		TopicName:         "inventory",
+		Partitions:        3,
+		IgnoreConfigDrift: ignoreDrift,
+		Config:            map[string]string{"retention.ms": "86400000"},
+	}
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{Cluster: crd.KafkaClusterConfig{Replicas: 3}},
+			},
+		},
+	}
+	appList := &crd.ClowdAppList{Items: []crd.ClowdApp{{
+		Spec: crd.ClowdAppSpec{KafkaTopics: []crd.KafkaTopicSpec{topic}},
+	}}}
+
+	mock := &mockDriftHTTPClient{live: live}
+	mep := &managedEphemProvider{Provider: providers.Provider{
+		Client: fake.NewClientBuilder().Build(),
+		Ctx:    context.Background(),
+		Env:    env,
+		Log:    logr.Discard(),
+	}}
+
+	err := mep.ephemProcessTopicValues(env, appList, topic, "env-inventory", mock, "https://admin.url")
+	return mock, err
+}
+
+func TestEphemTopicDriftIsPatched(t *testing.T) {
+	mock := runDriftTest(t, driftTestLiveTopic("1000"), false)
+	assert.Len(t, mock.patches, 1)
+	assert.Equal(t, 3, mock.patches[0].NumPartitions)
+	assert.Contains(t, mock.patches[0].Config, Config{Key: "retention.ms", Value: "86400000"})
+}
+
+func TestEphemTopicNoDriftIsNotPatched(t *testing.T) {
+	mock := runDriftTest(t, driftTestLiveTopic("86400000"), false)
+	assert.Len(t, mock.patches, 0)
+}
+
+func TestEphemTopicDriftIgnored(t *testing.T) {
+	mock := runDriftTest(t, driftTestLiveTopic("1000"), true)
+	assert.Len(t, mock.patches, 0)
+}
+
+func TestEphemTopicPartitionIncreaseIsPatched(t *testing.T) {
+	live := driftTestLiveTopic("86400000")
+	live.Partitions = []TopicPartition{{Partition: 0}}
+
+	mock := runDriftTest(t, live, false)
+	assert.Len(t, mock.patches, 1)
+	assert.Equal(t, 3, mock.patches[0].NumPartitions)
+}
+
+func TestEphemTopicKeepsLivePartitions(t *testing.T) {
+	live := driftTestLiveTopic("86400000")
+	live.Partitions = append(live.Partitions, TopicPartition{Partition: 3}, TopicPartition{Partition: 4})
+
+	// a topic with more partitions than requested is not shrunk, nor reported as drifted
+	mock, err := runDriftTestErr(live, false)
+	assert.NoError(t, err)
+	assert.Len(t, mock.patches, 0)
+
+	// and a patch for another drift keeps its partitions
+	live = driftTestLiveTopic("1000")
+	live.Partitions = append(live.Partitions, TopicPartition{Partition: 3}, TopicPartition{Partition: 4})
+
+	mock, err = runDriftTestErr(live, false)
+	assert.NoError(t, err)
+	assert.Len(t, mock.patches, 1)
+	assert.Equal(t, 5, mock.patches[0].NumPartitions)
+}
+
+func TestEphemExistingTopicIsMarked(t *testing.T) {
+	env := &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env"}}
+	p := ephemTestProvider(t, env)
+	mep := &managedEphemProvider{Provider: *p}
+
+	topic := crd.KafkaTopicSpec{TopicName: "inventory", Partitions: 3}
+	appList := &crd.ClowdAppList{Items: []crd.ClowdApp{{
+		Spec: crd.ClowdAppSpec{KafkaTopics: []crd.KafkaTopicSpec{topic}},
+	}}}
+
+	// the topic exists already and has not drifted, it is marked all the same
+	mock := &mockDriftHTTPClient{live: driftTestLiveTopic("86400000")}
+	assert.NoError(t, mep.ephemProcessTopicValues(env, appList, topic, "env-inventory", mock, "https://admin.url"))
+	assert.Len(t, mock.patches, 0)
+
+	managed, err := getManagedTopics(p)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"env-inventory": true}, managed)
+}
+
+// conflictingClient fails the first updates it is given with a conflict, as if another reconcile
+// had written the object in the meantime.
+type conflictingClient struct {
+	client.Client
+	conflicts int
+}
+
+func (c *conflictingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if c.conflicts > 0 {
+		c.conflicts--
+		return k8serr.NewConflict(schema.GroupResource{Resource: "configmaps"}, obj.GetName(), fmt.Errorf("modified"))
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func TestEphemMarkManagedTopicRetriesConflicts(t *testing.T) {
+	p := ephemTestProvider(t, &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env-ephemeral"}})
+	assert.NoError(t, markManagedTopic(p, "env-ephemeral-inventory"))
+
+	cl := &conflictingClient{Client: p.Client, conflicts: 2}
+	p.Client = cl
+	assert.NoError(t, markManagedTopic(p, "env-ephemeral-ingress"))
+	assert.Equal(t, 0, cl.conflicts)
+
+	cl.conflicts = 2
+	assert.NoError(t, unmarkManagedTopics(p, []string{"env-ephemeral-inventory"}))
+	assert.Equal(t, 0, cl.conflicts)
+
+	managed, err := getManagedTopics(p)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"env-ephemeral-ingress": true}, managed)
+}
+
+type mockDeleteHTTPClient struct {
+	mockDriftHTTPClient
+	mu      sync.Mutex
+	latency time.Duration
+	deleted []string
+}
+
+func (m *mockDeleteHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	if req.Method == "DELETE" {
+		time.Sleep(m.latency)
+		m.mu.Lock()
+		m.deleted = append(m.deleted, strings.TrimPrefix(req.URL.Path, "/api/v1/topics/"))
+		m.mu.Unlock()
+	}
+	return &http.Response{StatusCode: 204, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func ephemTestProvider(t *testing.T, env *crd.ClowdEnvironment) *providers.Provider {
+	scheme := strimziTestScheme(t)
+	assert.NoError(t, core.AddToScheme(scheme))
+	return &providers.Provider{
+		Client: fake.NewClientBuilder().WithScheme(scheme).Build(),
+		Ctx:    context.Background(),
+		Log:    logr.Discard(),
+		Env:    env,
+	}
+}
+
+func TestEphemTeardownOnlyDeletesMarkedTopics(t *testing.T) {
+	oldRegex := clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex
+	clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = ".*"
+	defer func() { clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = oldRegex }()
+
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env-ephemeral"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{
+					EphemManagedSecretRef: crd.NamespacedName{Name: "managed-kafka", Namespace: "kafka-secrets"},
+				},
+			},
+		},
+	}
+	p := ephemTestProvider(t, env)
+
+	// topics created by Clowder are marked, marking twice is harmless
+	assert.NoError(t, markManagedTopic(p, "env-ephemeral-inventory"))
+	assert.NoError(t, markManagedTopic(p, "env-ephemeral-ingress"))
+	assert.NoError(t, markManagedTopic(p, "env-ephemeral-inventory"))
+
+	managed, err := getManagedTopics(p)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"env-ephemeral-inventory": true, "env-ephemeral-ingress": true}, managed)
+
+	topicList := &TopicsList{Items: []Topic{
+		{Name: "env-ephemeral-inventory"},
+		{Name: "env-ephemeral-ingress"},
+		// matches the environment's name but was not created by Clowder
+		{Name: "env-ephemeral-dont-delete"},
+		{Name: "ephemeral-dont-delete"},
+	}}
+
+	mock := &mockDeleteHTTPClient{}
+	assert.NoError(t, deleteTopics(topicList, managed, mock, "https://admin.url", p))
+	assert.ElementsMatch(t, []string{"env-ephemeral-inventory", "env-ephemeral-ingress"}, mock.deleted)
+}
+
+func TestEphemTeardownDeletesConcurrently(t *testing.T) {
+	oldSettings := clowderconfig.LoadedConfig.Settings
+	clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = ".*"
+	clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteConcurrency = 20
+	defer func() { clowderconfig.LoadedConfig.Settings = oldSettings }()
+
+	p := ephemTestProvider(t, &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env-ephemeral"}})
+
+	managed := map[string]bool{}
+	topicList := &TopicsList{Items: []Topic{{Name: "ephemeral-dont-delete"}}}
+	expected := []string{}
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("env-ephemeral-topic-%d", i)
+		managed[name] = true
+		topicList.Items = append(topicList.Items, Topic{Name: name})
+		expected = append(expected, name)
+	}
+
+	// one at a time the deletes would take two seconds
+	mock := &mockDeleteHTTPClient{latency: 10 * time.Millisecond}
+	start := time.Now()
+	assert.NoError(t, deleteTopics(topicList, managed, mock, "https://admin.url", p))
+	assert.Less(t, time.Since(start), time.Second)
+
+	assert.ElementsMatch(t, expected, mock.deleted)
+	assert.NotContains(t, mock.deleted, "ephemeral-dont-delete")
+}
+
+func TestEphemTeardownWithoutMarkedTopics(t *testing.T) {
+	p := ephemTestProvider(t, &crd.ClowdEnvironment{ObjectMeta: metav1.ObjectMeta{Name: "env-ephemeral"}})
+
+	managed, err := getManagedTopics(p)
+	assert.NoError(t, err)
+	assert.Empty(t, managed)
+}
+
+type mockOrphanHTTPClient struct {
+	mockDeleteHTTPClient
+	topics []Topic
+}
+
+func (m *mockOrphanHTTPClient) Get(_ string) (*http.Response, error) {
+	body, err := json.Marshal(TopicsList{Items: m.topics})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: 200, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+func TestEphemOrphanedTopicsDeleted(t *testing.T) {
+	oldRegex := clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex
+	clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = ".*"
+	defer func() { clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = oldRegex }()
+
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env-ephemeral"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{
+					EphemManagedSecretRef: crd.NamespacedName{Name: "managed-kafka", Namespace: "kafka-secrets"},
+				},
+			},
+		},
+	}
+	p := ephemTestProvider(t, env)
+
+	// the listed copy of the app still requests the topic it had before the rename
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "ns"},
+		Spec: crd.ClowdAppSpec{
+			EnvName:     env.Name,
+			KafkaTopics: []crd.KafkaTopicSpec{{TopicName: "inventory"}},
+		},
+	}
+	other := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "ns"},
+		Spec: crd.ClowdAppSpec{
+			EnvName:     env.Name,
+			KafkaTopics: []crd.KafkaTopicSpec{{TopicName: "ingress"}},
+		},
+	}
+	assert.NoError(t, p.Client.Create(p.Ctx, app))
+	assert.NoError(t, p.Client.Create(p.Ctx, other))
+
+	for _, name := range []string{"env-ephemeral-inventory", "env-ephemeral-inventory-v2", "env-ephemeral-ingress", "env-ephemeral-gone"} {
+		assert.NoError(t, markManagedTopic(p, name))
+	}
+
+	mock := &mockOrphanHTTPClient{topics: []Topic{
+		{Name: "env-ephemeral-inventory"},
+		{Name: "env-ephemeral-inventory-v2"},
+		{Name: "env-ephemeral-ingress"},
+		// not created by Clowder
+		{Name: "env-ephemeral-dont-delete"},
+	}}
+
+	app.Spec.KafkaTopics = []crd.KafkaTopicSpec{{TopicName: "inventory-v2"}}
+	mep := &managedEphemProvider{Provider: *p}
+	assert.NoError(t, mep.deleteOrphanedTopics(app, mock, "https://admin.url"))
+	assert.Equal(t, []string{"env-ephemeral-inventory"}, mock.deleted)
+
+	// the deleted topic and the one already gone from kafka are forgotten
+	managed, err := getManagedTopics(p)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"env-ephemeral-inventory-v2": true, "env-ephemeral-ingress": true}, managed)
+}
+
+func TestEphemOrphanedTopicsKeptWithoutRegex(t *testing.T) {
+	oldRegex := clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex
+	clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = ""
+	defer func() { clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = oldRegex }()
+
+	p := ephemTestProvider(t, &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env-ephemeral"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{
+					EphemManagedSecretRef: crd.NamespacedName{Name: "managed-kafka", Namespace: "kafka-secrets"},
+				},
+			},
+		},
+	})
+	assert.NoError(t, markManagedTopic(p, "env-ephemeral-inventory"))
+
+	mock := &mockOrphanHTTPClient{topics: []Topic{{Name: "env-ephemeral-inventory"}}}
+	mep := &managedEphemProvider{Provider: *p}
+	assert.NoError(t, mep.deleteOrphanedTopics(&crd.ClowdApp{}, mock, "https://admin.url"))
+	assert.Empty(t, mock.deleted)
+}
+
+type mockUnreachableHTTPClient struct {
+	mockDeleteHTTPClient
+}
+
+func (m *mockUnreachableHTTPClient) Get(_ string) (*http.Response, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func ephemFinalizeTestProvider(t *testing.T, adminURL string, client HTTPClient) *providers.Provider {
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env-ephemeral"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{
+					Mode:                  "managed-ephem",
+					EphemManagedSecretRef: crd.NamespacedName{Name: "managed-kafka", Namespace: "kafka-secrets"},
+				},
+			},
+		},
+	}
+	p := ephemTestProvider(t, env)
+
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "managed-kafka", Namespace: "kafka-secrets"},
+		Data:       map[string][]byte{"admin.url": []byte(adminURL)},
+	}
+	assert.NoError(t, p.Client.Create(p.Ctx, secret))
+
+	ClientCache.Set(adminURL, client)
+	t.Cleanup(func() { ClientCache.Remove(adminURL) })
+	return p
+}
+
+func TestEphemAppFinalizeDeletesOwnedTopics(t *testing.T) {
+	oldRegex := clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex
+	clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = ".*"
+	defer func() { clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = oldRegex }()
+
+	mock := &mockOrphanHTTPClient{topics: []Topic{
+		{Name: "env-ephemeral-inventory"},
+		{Name: "env-ephemeral-ingress"},
+		{Name: "env-ephemeral-shared"},
+		// requested by the app but not created by Clowder
+		{Name: "env-ephemeral-precreated"},
+	}}
+	p := ephemFinalizeTestProvider(t, "https://admin-finalize.url", mock)
+
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "ns"},
+		Spec: crd.ClowdAppSpec{
+			EnvName: p.Env.Name,
+			KafkaTopics: []crd.KafkaTopicSpec{
+				{TopicName: "inventory"}, {TopicName: "shared"}, {TopicName: "precreated"},
+			},
+		},
+	}
+	other := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingress", Namespace: "ns"},
+		Spec: crd.ClowdAppSpec{
+			EnvName:     p.Env.Name,
+			KafkaTopics: []crd.KafkaTopicSpec{{TopicName: "ingress"}, {TopicName: "shared"}},
+		},
+	}
+	assert.NoError(t, p.Client.Create(p.Ctx, app))
+	assert.NoError(t, p.Client.Create(p.Ctx, other))
+
+	for _, name := range []string{"env-ephemeral-inventory", "env-ephemeral-ingress", "env-ephemeral-shared"} {
+		assert.NoError(t, markManagedTopic(p, name))
+	}
+
+	assert.NoError(t, GetKafkaAppFinalize(p, app))
+	assert.Equal(t, []string{"env-ephemeral-inventory"}, mock.deleted)
+
+	managed, err := getManagedTopics(p)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"env-ephemeral-ingress": true, "env-ephemeral-shared": true}, managed)
+
+	// finalizing again deletes nothing more
+	mock.topics = mock.topics[1:]
+	assert.NoError(t, GetKafkaAppFinalize(p, app))
+	assert.Equal(t, []string{"env-ephemeral-inventory"}, mock.deleted)
+}
+
+func TestEphemAppFinalizeGivesUp(t *testing.T) {
+	oldRegex := clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex
+	clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = ".*"
+	defer func() { clowderconfig.LoadedConfig.Settings.ManagedKafkaEphemDeleteRegex = oldRegex }()
+
+	p := ephemFinalizeTestProvider(t, "https://admin-unreachable.url", &mockUnreachableHTTPClient{})
+	assert.NoError(t, markManagedTopic(p, "env-ephemeral-inventory"))
+
+	deleting := metav1.NewTime(time.Now())
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "ns", DeletionTimestamp: &deleting},
+		Spec: crd.ClowdAppSpec{
+			EnvName:     p.Env.Name,
+			KafkaTopics: []crd.KafkaTopicSpec{{TopicName: "inventory"}},
+		},
+	}
+
+	// the deletion is retried while the admin API is unreachable
+	assert.EqualError(t, GetKafkaAppFinalize(p, app), "connection refused")
+
+	// until the timeout, after which the app is let go and the topic stays marked as an orphan
+	deleting = metav1.NewTime(time.Now().Add(-ephemAppFinalizeTimeout - time.Minute))
+	assert.NoError(t, GetKafkaAppFinalize(p, app))
+
+	managed, err := getManagedTopics(p)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"env-ephemeral-inventory": true}, managed)
+}
+
+func TestEphemTopicConfigSorted(t *testing.T) {
+	mep := &managedEphemProvider{Provider: providers.Provider{Log: logr.Discard()}}
+	topicConfig, err := mep.getTopicConfigs("inventory", map[string][]string{
+		"segment.bytes":     {"1073741824"},
+		"cleanup.policy":    {"delete", "compact"},
+		"max.message.bytes": {"524288", "1048576"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Config{
+		{Key: "cleanup.policy", Value: "compact,delete"},
+		{Key: "max.message.bytes", Value: "1048576"},
+		{Key: "segment.bytes", Value: "1073741824"},
+	}, topicConfig)
+}
+
+type mockCreateHTTPClient struct {
+	mockDriftHTTPClient
+	created []string
+}
+
+func (m *mockCreateHTTPClient) Get(_ string) (*http.Response, error) {
+	return &http.Response{StatusCode: 404, Body: io.NopCloser(strings.NewReader(""))}, nil
+}
+
+func (m *mockCreateHTTPClient) Post(_, _ string, body io.Reader) (*http.Response, error) {
+	payload := JSONPayload{}
+	if err := json.NewDecoder(body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	m.created = append(m.created, payload.Name)
+	return &http.Response{StatusCode: 201, Body: io.NopCloser(strings.NewReader("{}"))}, nil
+}
+
+func TestEphemTopicNamingStrategy(t *testing.T) {
+	for strategy, expected := range map[crd.KafkaTopicNamingStrategy]string{
+		"":              "env-inventory",
+		"app-interface": "inventory",
+		"namespaced":    "env-inventory",
+		"sharded":       "inventory-b77349bf",
+	} {
+		env := &crd.ClowdEnvironment{
+			ObjectMeta: metav1.ObjectMeta{Name: "env"},
+			Spec: crd.ClowdEnvironmentSpec{
+				Providers: crd.ProvidersConfig{
+					Kafka: crd.KafkaConfig{
+						Mode:                  "managed-ephem",
+						TopicNamingStrategy:   strategy,
+						EphemManagedSecretRef: crd.NamespacedName{Name: "managed-kafka", Namespace: "kafka-secrets"},
+					},
+				},
+			},
+		}
+		p := ephemTestProvider(t, env)
+		p.Config = &config.AppConfig{Kafka: &config.KafkaConfig{}}
+
+		app := &crd.ClowdApp{
+			ObjectMeta: metav1.ObjectMeta{Name: "inventory", Namespace: "ns"},
+			Spec: crd.ClowdAppSpec{
+				EnvName:     env.Name,
+				KafkaTopics: []crd.KafkaTopicSpec{{TopicName: "inventory"}},
+			},
+		}
+		assert.NoError(t, p.Client.Create(p.Ctx, app), strategy)
+
+		mock := &mockCreateHTTPClient{}
+		mep := &managedEphemProvider{Provider: *p}
+		assert.NoError(t, mep.processTopics(app, mock, "https://admin.url"), strategy)
+
+		assert.Equal(t, []string{expected}, mock.created, strategy)
+		assert.Equal(t, []config.TopicConfig{{Name: expected, RequestedName: "inventory"}}, p.Config.Kafka.Topics, strategy)
+
+		managed, err := getManagedTopics(p)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]bool{expected: true}, managed, strategy)
+	}
+}
@@ -0,0 +1,174 @@
+package kafka
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	mskAuthTypeSaslSsl = "sasl_ssl"
+	mskAuthTypeIAM     = "iam"
+)
+
+// mskSaslMechanisms are the SASL mechanisms supported for each auth type of an MSK cluster, the
+// first one is used when the secret does not name one.
+var mskSaslMechanisms = map[string][]string{
+	mskAuthTypeSaslSsl: {"SCRAM-SHA-512"},
+	mskAuthTypeIAM:     {"AWS_MSK_IAM", "OAUTHBEARER"},
+}
+
+type mskKafkaProvider struct {
+	providers.Provider
+}
+
+// NewMSKKafka returns a new msk kafka provider object.
+func NewMSKKafka(p *providers.Provider) (providers.ClowderProvider, error) {
+	return &mskKafkaProvider{Provider: *p}, nil
+}
+
+func (k *mskKafkaProvider) EnvProvide() error {
+	return nil
+}
+
+func (k *mskKafkaProvider) Provide(app *crd.ClowdApp) error {
+	if len(app.Spec.KafkaTopics) == 0 {
+		return nil
+	}
+
+	secret, err := k.getSecret()
+	if err != nil {
+		return err
+	}
+
+	brokers, err := getMSKBrokerConfigs(secret)
+	if err != nil {
+		return err
+	}
+
+	kafkaConfig := &config.KafkaConfig{
+		Brokers: brokers,
+		Topics:  []config.TopicConfig{},
+	}
+
+	for _, topic := range app.Spec.KafkaTopics {
+		kafkaConfig.Topics = append(kafkaConfig.Topics, config.TopicConfig{
+			Name:          topic.TopicName,
+			RequestedName: topic.TopicName,
+		})
+	}
+
+	k.Config.Kafka = kafkaConfig
+
+	return nil
+}
+
+func (k *mskKafkaProvider) getSecret() (*core.Secret, error) {
+	ref := k.Env.Spec.Providers.Kafka.MSKSecretRef
+	name := types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+	if name == (types.NamespacedName{}) {
+		return nil, errors.NewClowderError("no secret ref defined for MSK Kafka")
+	}
+
+	secret := &core.Secret{}
+	err := k.Client.Get(k.Ctx, name, secret)
+
+	if k8serr.IsNotFound(err) {
+		missingSecrets := errors.MakeMissingSecrets(errors.MissingSecret{
+			Provider:  "kafka",
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		})
+		return nil, &missingSecrets
+	} else if err != nil {
+		return nil, errors.Wrap("Failed to fetch msk secret", err)
+	}
+
+	return secret, nil
+}
+
+// getMSKBrokerConfigs builds a broker config for each of the comma separated bootstrap brokers
+// in the secret, all sharing the auth settings of the secret.
+func getMSKBrokerConfigs(secret *core.Secret) ([]config.BrokerConfig, error) {
+	authType := strings.ToLower(string(secret.Data["authType"]))
+	supported, ok := mskSaslMechanisms[authType]
+	if !ok {
+		return nil, errors.NewClowderError(fmt.Sprintf("unsupported msk auth type %q, must be %s or %s", authType, mskAuthTypeSaslSsl, mskAuthTypeIAM))
+	}
+
+	saslMechanism := supported[0]
+	if val, ok := secret.Data["saslMechanism"]; ok {
+		saslMechanism = string(val)
+	}
+	if !utils.Contains(supported, saslMechanism) {
+		return nil, errors.NewClowderError(fmt.Sprintf("unsupported sasl mechanism %q for msk auth type %s, must be one of %s", saslMechanism, authType, strings.Join(supported, ", ")))
+	}
+
+	sasl := &config.KafkaSASLConfig{
+		SecurityProtocol: utils.StringPtr("SASL_SSL"),
+		SaslMechanism:    utils.StringPtr(saslMechanism),
+	}
+	if authType == mskAuthTypeSaslSsl {
+		username := string(secret.Data["username"])
+		password := string(secret.Data["password"])
+		if username == "" || password == "" {
+			return nil, errors.NewClowderError("msk secret must contain a username and password for sasl_ssl auth")
+		}
+		sasl.Username = &username
+		sasl.Password = &password
+	}
+
+	var cacert *string
+	if val, ok := secret.Data["cacert"]; ok && len(val) > 0 {
+		cacert = utils.StringPtr(string(val))
+	}
+
+	brokers := []config.BrokerConfig{}
+	for _, address := range strings.Split(string(secret.Data["bootstrapBrokers"]), ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+
+		host, portStr, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, errors.Wrap(fmt.Sprintf("invalid msk bootstrap broker %q", address), err)
+		}
+		port, err := strconv.ParseUint(portStr, 10, 16)
+		if err != nil {
+			return nil, errors.Wrap(fmt.Sprintf("invalid port in msk bootstrap broker %q", address), err)
+		}
+
+		saslType := config.BrokerConfigAuthtypeSasl
+		brokerPort := int(port)
+		brokerSasl := *sasl
+		brokers = append(brokers, config.BrokerConfig{
+			Hostname:         host,
+			Port:             &brokerPort,
+			Authtype:         &saslType,
+			Cacert:           cacert,
+			Sasl:             &brokerSasl,
+			SecurityProtocol: utils.StringPtr("SASL_SSL"),
+		})
+	}
+
+	if len(brokers) == 0 {
+		return nil, errors.NewClowderError("msk secret contains no bootstrap brokers")
+	}
+
+	return brokers, nil
+}
@@ -0,0 +1,76 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func mskSecret(data map[string]string) *core.Secret {
+	secret := &core.Secret{Data: map[string][]byte{}}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func TestMSKBrokerConfigsSaslSsl(t *testing.T) {
+	brokers, err := getMSKBrokerConfigs(mskSecret(map[string]string{
+		"bootstrapBrokers": "b-1.msk.example.com:9096, b-2.msk.example.com:9096",
+		"authType":         "sasl_ssl",
+		"username":         "user",
+		"password":         "pass",
+		"cacert":           "cert",
+	}))
+	assert.NoError(t, err)
+	assert.Len(t, brokers, 2)
+
+	assert.Equal(t, "b-1.msk.example.com", brokers[0].Hostname)
+	assert.Equal(t, "b-2.msk.example.com", brokers[1].Hostname)
+	for _, broker := range brokers {
+		assert.Equal(t, 9096, *broker.Port)
+		assert.Equal(t, config.BrokerConfigAuthtypeSasl, *broker.Authtype)
+		assert.Equal(t, "SASL_SSL", *broker.SecurityProtocol)
+		assert.Equal(t, "cert", *broker.Cacert)
+		assert.Equal(t, "SCRAM-SHA-512", *broker.Sasl.SaslMechanism)
+		assert.Equal(t, "user", *broker.Sasl.Username)
+		assert.Equal(t, "pass", *broker.Sasl.Password)
+	}
+}
+
+func TestMSKBrokerConfigsIAM(t *testing.T) {
+	brokers, err := getMSKBrokerConfigs(mskSecret(map[string]string{
+		"bootstrapBrokers": "b-1.msk.example.com:9098",
+		"authType":         "iam",
+	}))
+	assert.NoError(t, err)
+	assert.Len(t, brokers, 1)
+	assert.Equal(t, 9098, *brokers[0].Port)
+	assert.Equal(t, "AWS_MSK_IAM", *brokers[0].Sasl.SaslMechanism)
+	assert.Nil(t, brokers[0].Sasl.Username)
+	assert.Nil(t, brokers[0].Sasl.Password)
+	assert.Nil(t, brokers[0].Cacert)
+}
+
+func TestMSKBrokerConfigsInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]string
+		err  string
+	}{
+		{"no brokers", map[string]string{"authType": "iam", "bootstrapBrokers": " , "}, "no bootstrap brokers"},
+		{"bad broker", map[string]string{"authType": "iam", "bootstrapBrokers": "b-1.msk.example.com"}, "invalid msk bootstrap broker"},
+		{"bad auth type", map[string]string{"authType": "mtls", "bootstrapBrokers": "b-1:9094"}, "unsupported msk auth type"},
+		{"bad mechanism", map[string]string{"authType": "sasl_ssl", "saslMechanism": "PLAIN", "bootstrapBrokers": "b-1:9096", "username": "u", "password": "p"}, "unsupported sasl mechanism"},
+		{"no credentials", map[string]string{"authType": "sasl_ssl", "bootstrapBrokers": "b-1:9096"}, "username and password"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := getMSKBrokerConfigs(mskSecret(tt.data))
+			assert.ErrorContains(t, err, tt.err)
+		})
+	}
+}
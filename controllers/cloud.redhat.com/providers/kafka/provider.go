@@ -1,12 +1,15 @@
 package kafka
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strings"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	cyndi "github.com/RedHatInsights/cyndi-operator/api/v1alpha1"
 	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
@@ -28,6 +31,50 @@ var CyndiAppSecret = rc.NewSingleResourceIdent(ProvName, "cyndi_app_secret", &co
 // CyndiHostInventoryAppSecret identifies the cyndi host-inventory app secret object.
 var CyndiHostInventoryAppSecret = rc.NewSingleResourceIdent(ProvName, "cyndi_host_inventory_secret", &core.Secret{})
 
+// TopicAppLabel and TopicAppNamespaceLabel record the ClowdApp that requested a KafkaTopic. Topics
+// usually live outside of the ClowdApp's namespace and so cannot carry an owner reference to it,
+// these labels are used instead to find the topics to clean up when the ClowdApp is deleted.
+const (
+	TopicAppLabel          = "app"
+	TopicAppNamespaceLabel = "app-namespace"
+)
+
+// PreviousNamesAnnotation lists, comma separated, the names a ClowdApp was previously known by in
+// the same namespace. The KafkaTopics of a previous identity are adopted by the renamed app rather
+// than being cleaned up when the old ClowdApp is deleted, so the renamed app must be created
+// before the old one is removed.
+const PreviousNamesAnnotation = "clowder/previous-names"
+
+// withCurrentApp returns the apps of the environment with the listed copy of the app replaced by
+// the app being reconciled, which may be newer, so that its latest topic requests are counted when
+// the requests of all the apps for a topic are merged.
+func withCurrentApp(appList *crd.ClowdAppList, app *crd.ClowdApp) *crd.ClowdAppList {
+	merged := &crd.ClowdAppList{Items: []crd.ClowdApp{*app}}
+	for _, iapp := range appList.Items {
+		if iapp.Name == app.Name && iapp.Namespace == app.Namespace {
+			continue
+		}
+		merged.Items = append(merged.Items, iapp)
+	}
+	return merged
+}
+
+// strategyTopicName returns the name of the topic on the Kafka cluster under the topic naming
+// strategy of the environment, and false if the environment does not set one so that the mode's
+// own naming applies.
+func strategyTopicName(topic crd.KafkaTopicSpec, env crd.ClowdEnvironment) (string, bool) {
+	switch env.Spec.Providers.Kafka.TopicNamingStrategy {
+	case "app-interface":
+		return topic.TopicName, true
+	case "namespaced":
+		return fmt.Sprintf("%s-%s", env.Name, topic.TopicName), true
+	case "sharded":
+		h := sha256.Sum256([]byte(env.Name))
+		return fmt.Sprintf("%s-%s", topic.TopicName, hex.EncodeToString(h[:])[:8]), true
+	}
+	return "", false
+}
+
 // CyndiConfigMap is the resource ident for a CyndiConfigMap object.
 var CyndiConfigMap = rc.NewSingleResourceIdent(ProvName, "cyndi_config_map", &core.ConfigMap{}, rc.ResourceOptions{WriteNow: true})
 
@@ -42,6 +89,8 @@ func GetKafka(c *providers.Provider) (providers.ClowderProvider, error) {
 		return NewAppInterface(c)
 	case "managed":
 		return NewManagedKafka(c)
+	case "msk":
+		return NewMSKKafka(c)
 	case "managed-ephem":
 		return NewManagedEphemKafka(c)
 	case "none", "":
@@ -67,10 +116,61 @@ func GetKafkaFinalize(c *providers.Provider) error {
 	}
 }
 
+// GetKafkaAppFinalize returns the correct kafka app finalizer based on the environment.
+func GetKafkaAppFinalize(c *providers.Provider, app *crd.ClowdApp) error {
+	c.Env.ConvertDeprecatedKafkaSpec()
+	kafkaMode := c.Env.Spec.Providers.Kafka.Mode
+	switch kafkaMode {
+	case "operator":
+		return finalizeStrimziApp(c, app)
+	case "managed-ephem":
+		return finalizeManagedEphemApp(c, app)
+	default:
+		return nil
+	}
+}
+
+// hasPreviousName reports whether the app was previously known by the given name and namespace.
+func hasPreviousName(app *crd.ClowdApp, name string, namespace string) bool {
+	if name == "" || app.Namespace != namespace || app.Name == name {
+		return false
+	}
+	for _, previous := range strings.Split(app.GetAnnotations()[PreviousNamesAnnotation], ",") {
+		if strings.TrimSpace(previous) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// getAdopter returns the ClowdApp in the list that the given app was renamed to, or nil if there
+// is none.
+func getAdopter(app *crd.ClowdApp, appList *crd.ClowdAppList) *crd.ClowdApp {
+	for i := range appList.Items {
+		iapp := &appList.Items[i]
+		if iapp.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if hasPreviousName(iapp, app.Name, app.Namespace) {
+			return iapp
+		}
+	}
+	return nil
+}
+
 func getKafkaUsername(env *crd.ClowdEnvironment, app *crd.ClowdApp) string {
 	return fmt.Sprintf("%s-%s", env.Name, app.Name)
 }
 
+// getConsumerGroups returns the consumer groups of the app, which default to a single group named
+// after its Kafka user.
+func getConsumerGroups(env *crd.ClowdEnvironment, app *crd.ClowdApp) []string {
+	if len(app.Spec.KafkaConsumerGroups) > 0 {
+		return app.Spec.KafkaConsumerGroups
+	}
+	return []string{getKafkaUsername(env, app)}
+}
+
 func getKafkaName(e *crd.ClowdEnvironment) string {
 	if e.Spec.Providers.Kafka.Cluster.Name == "" {
 		// generate a unique name based on the ClowdEnvironment's UID
@@ -92,6 +192,15 @@ func getKafkaNamespace(e *crd.ClowdEnvironment) string {
 	return e.Spec.Providers.Kafka.Cluster.Namespace
 }
 
+// ClusterNamespacedName returns the name and namespace of the Kafka cluster of the environment in
+// operator mode.
+func ClusterNamespacedName(e *crd.ClowdEnvironment) types.NamespacedName {
+	return types.NamespacedName{
+		Name:      getKafkaName(e),
+		Namespace: getKafkaNamespace(e),
+	}
+}
+
 func getConnectNamespace(env *crd.ClowdEnvironment) string {
 	if env.Spec.Providers.Kafka.Connect.Namespace == "" {
 		return getKafkaNamespace(env)
@@ -112,4 +221,6 @@ func getConnectClusterUserName(env *crd.ClowdEnvironment) string {
 
 func init() {
 	providers.ProvidersRegistration.Register(GetKafka, 6, ProvName, GetKafkaFinalize)
+	providers.ProvidersRegistration.RegisterAppFinalizer(ProvName, GetKafkaAppFinalize)
+	providers.ProvidersRegistration.Register(GetTopicAllowList, 7, TopicAllowListProvName)
 }
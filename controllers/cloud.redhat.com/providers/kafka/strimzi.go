@@ -14,12 +14,15 @@ import (
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/go-logr/logr"
 	core "k8s.io/api/core/v1"
 	networking "k8s.io/api/networking/v1"
 	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
 )
@@ -81,7 +84,7 @@ func (s *strimziProvider) Provide(app *crd.ClowdApp) error {
 		Name:      getKafkaName(s.Env),
 	}
 	kafkaResource := strimzi.Kafka{}
-	if err := s.Client.Get(s.Ctx, clusterNN, &kafkaResource); err != nil {
+	if err := s.GetShared(clusterNN, &kafkaResource); err != nil {
 		return err
 	}
 
@@ -90,7 +93,7 @@ func (s *strimziProvider) Provide(app *crd.ClowdApp) error {
 		Namespace: getKafkaNamespace(s.Env),
 	}
 	kafkaCASecret := core.Secret{}
-	if _, err := utils.UpdateOrErr(s.Client.Get(s.Ctx, kafkaCASecName, &kafkaCASecret)); err != nil {
+	if _, err := utils.UpdateOrErr(s.GetShared(kafkaCASecName, &kafkaCASecret)); err != nil {
 		return err
 	}
 
@@ -128,6 +131,10 @@ func (s *strimziProvider) Provide(app *crd.ClowdApp) error {
 			return err
 		}
 
+		if s.Env.Spec.Providers.Kafka.ConsumerGroupACLs {
+			s.Config.Kafka.ConsumerGroups = getConsumerGroups(s.Env, app)
+		}
+
 		if err := s.setBrokerCredentials(app, s.Config.Kafka); err != nil {
 			return err
 		}
@@ -137,12 +144,59 @@ func (s *strimziProvider) Provide(app *crd.ClowdApp) error {
 }
 
 var conversionMap = map[string]func([]string) (string, error){
-	"retention.ms":          utils.IntMax,
-	"retention.bytes":       utils.IntMax,
+	"retention.ms":          retentionMax,
+	"retention.bytes":       retentionMax,
 	"min.compaction.lag.ms": utils.IntMax,
+	"max.message.bytes":     utils.IntMax,
+	"segment.bytes":         utils.IntMax,
 	"cleanup.policy":        utils.ListMerge,
 }
 
+// retentionMax returns the largest of the retentions, where -1 is an unlimited retention and wins
+// over any finite one.
+func retentionMax(vals []string) (string, error) {
+	out, err := utils.IntMax(vals)
+	if err != nil {
+		return "", err
+	}
+	for _, val := range vals {
+		if n, err := strconv.Atoi(val); err == nil && n == -1 {
+			return "-1", nil
+		}
+	}
+	return out, nil
+}
+
+// mergeTopicConfig merges the config values that the apps in the environment request for a topic
+// into a single value per key. Conflicting values resolve to the most conservative one, e.g. the
+// larger retention, whatever the order the apps are listed in.
+func mergeTopicConfig(log logr.Logger, topicName string, keys map[string][]string) (map[string]string, error) {
+	topicConfig := map[string]string{}
+
+	for key, valList := range keys {
+		f, ok := conversionMap[key]
+		if !ok {
+			return nil, errors.NewClowderError(fmt.Sprintf("no conversion type for %s", key))
+		}
+
+		out, err := f(valList)
+		if err != nil {
+			return nil, errors.Wrap(fmt.Sprintf("invalid value for %s on topic %s", key, topicName), err)
+		}
+
+		for _, val := range valList {
+			if val != valList[0] {
+				log.Info("Apps request conflicting topic config, using the most conservative value", "topic", topicName, "key", key, "values", valList, "value", out)
+				break
+			}
+		}
+
+		topicConfig[key] = out
+	}
+
+	return topicConfig, nil
+}
+
 func (s *strimziProvider) configureKafkaCluster() error {
 	clusterNN := types.NamespacedName{
 		Namespace: getKafkaNamespace(s.Env),
@@ -174,6 +228,17 @@ func (s *strimziProvider) configureKafkaCluster() error {
 		replicas = int32(1)
 	}
 
+	brokerReplicas, err := getKafkaBrokerReplicas(&s.Env.Spec.Providers.Kafka.Cluster)
+	if err != nil {
+		return err
+	}
+
+	// the offsets topic can't be replicated across more brokers than there are
+	replicationFactor := replicas
+	if brokerReplicas < replicationFactor {
+		replicationFactor = brokerReplicas
+	}
+
 	storageSize := s.Env.Spec.Providers.Kafka.Cluster.StorageSize
 	if storageSize == "" {
 		storageSize = "1Gi"
@@ -194,7 +259,7 @@ func (s *strimziProvider) configureKafkaCluster() error {
 
 	err = kafConfig.UnmarshalJSON([]byte(fmt.Sprintf(`{
 		"offsets.topic.replication.factor": %s
-	}`, strconv.Itoa(int(replicas)))))
+	}`, strconv.Itoa(int(replicationFactor)))))
 	if err != nil {
 		return fmt.Errorf("could not unmarshal kConfig: %w", err)
 	}
@@ -291,7 +356,7 @@ func (s *strimziProvider) configureKafkaCluster() error {
 		Kafka: strimzi.KafkaSpecKafka{
 			Config:   &kafConfig,
 			Version:  &version,
-			Replicas: replicas,
+			Replicas: brokerReplicas,
 			Resources: &strimzi.KafkaSpecKafkaResources{
 				Requests: &kafRequests,
 				Limits:   &kafLimits,
@@ -438,6 +503,68 @@ func (s *strimziProvider) configureKafkaCluster() error {
 	return s.Cache.Update(KafkaInstance, k)
 }
 
+// getTopicMaxReplicas returns the number of replicas a topic can be placed on, the cluster's
+// replicas or, with autoscaling, the minimum number of brokers so that topics remain placeable
+// when the cluster is scaled down.
+func getTopicMaxReplicas(cluster *crd.KafkaClusterConfig) int32 {
+	replicas := cluster.Replicas
+	if replicas < int32(1) {
+		replicas = int32(1)
+	}
+
+	if autoscaling := cluster.Autoscaling; autoscaling != nil {
+		minReplicas := autoscaling.MinReplicas
+		if minReplicas < int32(1) {
+			minReplicas = int32(1)
+		}
+		if minReplicas < replicas {
+			replicas = minReplicas
+		}
+	}
+
+	return replicas
+}
+
+// getKafkaBrokerReplicas returns the number of brokers the kafka cluster should run. Without
+// autoscaling this is the cluster's replicas, otherwise the autoscaling target kept within the
+// declared minimum and maximum.
+func getKafkaBrokerReplicas(cluster *crd.KafkaClusterConfig) (int32, error) {
+	replicas := cluster.Replicas
+	if replicas < int32(1) {
+		replicas = int32(1)
+	}
+
+	autoscaling := cluster.Autoscaling
+	if autoscaling == nil {
+		return replicas, nil
+	}
+
+	minReplicas := autoscaling.MinReplicas
+	if minReplicas < int32(1) {
+		minReplicas = int32(1)
+	}
+
+	if autoscaling.MaxReplicas != 0 && autoscaling.MaxReplicas < minReplicas {
+		return 0, errors.NewClowderError(fmt.Sprintf(
+			"kafka autoscaling maxReplicas %d is less than minReplicas %d", autoscaling.MaxReplicas, minReplicas,
+		))
+	}
+
+	target := autoscaling.TargetReplicas
+	if target == 0 {
+		target = replicas
+	}
+
+	if target < minReplicas {
+		target = minReplicas
+	}
+	if autoscaling.MaxReplicas != 0 && target > autoscaling.MaxReplicas {
+		target = autoscaling.MaxReplicas
+	}
+
+	return target, nil
+}
+
 func (s *strimziProvider) createKafkaMetricsConfigMap() (types.NamespacedName, error) {
 	cm := &core.ConfigMap{}
 	nn := types.NamespacedName{
@@ -892,7 +1019,7 @@ func (s *strimziProvider) createKafkaUser(app *crd.ClowdApp) error {
 	patternType := strimzi.KafkaUserSpecAuthorizationAclsElemResourcePatternTypeLiteral
 
 	for _, topic := range app.Spec.KafkaTopics {
-		topicName := getTopicName(topic, *s.Env, app.Namespace)
+		topicName := GetTopicName(topic, *s.Env, app.Namespace)
 
 		ku.Spec.Authorization.Acls = append(ku.Spec.Authorization.Acls, strimzi.KafkaUserSpecAuthorizationAclsElem{
 			Host:      &address,
@@ -905,16 +1032,23 @@ func (s *strimziProvider) createKafkaUser(app *crd.ClowdApp) error {
 		})
 	}
 
-	group := "*"
-	ku.Spec.Authorization.Acls = append(ku.Spec.Authorization.Acls, strimzi.KafkaUserSpecAuthorizationAclsElem{
-		Host:      &address,
-		Operation: strimzi.KafkaUserSpecAuthorizationAclsElemOperationAll,
-		Resource: strimzi.KafkaUserSpecAuthorizationAclsElemResource{
-			Name:        &group,
-			PatternType: &patternType,
-			Type:        strimzi.KafkaUserSpecAuthorizationAclsElemResourceTypeGroup,
-		},
-	})
+	groups := []string{"*"}
+	if s.Env.Spec.Providers.Kafka.ConsumerGroupACLs {
+		groups = getConsumerGroups(s.Env, app)
+	}
+
+	for i := range groups {
+		group := groups[i]
+		ku.Spec.Authorization.Acls = append(ku.Spec.Authorization.Acls, strimzi.KafkaUserSpecAuthorizationAclsElem{
+			Host:      &address,
+			Operation: strimzi.KafkaUserSpecAuthorizationAclsElemOperationAll,
+			Resource: strimzi.KafkaUserSpecAuthorizationAclsElemResource{
+				Name:        &group,
+				PatternType: &patternType,
+				Type:        strimzi.KafkaUserSpecAuthorizationAclsElemResourceTypeGroup,
+			},
+		})
+	}
 
 	return s.Cache.Update(KafkaUser, ku)
 }
@@ -927,11 +1061,14 @@ func (s *strimziProvider) processTopics(app *crd.ClowdApp, c *config.KafkaConfig
 	if err != nil {
 		return errors.Wrap("Topic creation failed: Error listing apps", err)
 	}
+	appList = withCurrentApp(appList, app)
+
+	adopter := getAdopter(app, appList)
 
 	for _, topic := range app.Spec.KafkaTopics {
 		k := &strimzi.KafkaTopic{}
 
-		topicName := getTopicName(topic, *s.Env, app.Namespace)
+		topicName := GetTopicName(topic, *s.Env, app.Namespace)
 		knn := types.NamespacedName{
 			Namespace: getKafkaNamespace(s.Env),
 			Name:      topicName,
@@ -942,10 +1079,20 @@ func (s *strimziProvider) processTopics(app *crd.ClowdApp, c *config.KafkaConfig
 		}
 
 		labels := providers.Labels{
-			"strimzi.io/cluster": getKafkaName(s.Env),
-			"env":                app.Spec.EnvName,
-			// If we label it with the app name, since app names should be
-			// unique? can we use for delete selector?
+			"strimzi.io/cluster":   getKafkaName(s.Env),
+			"env":                  app.Spec.EnvName,
+			TopicAppLabel:          app.Name,
+			TopicAppNamespaceLabel: app.Namespace,
+		}
+
+		// A topic owned by a previous identity of this app is adopted, while a topic that has
+		// already been adopted by the app this one was renamed to is left with its new owner
+		owner, ownerNamespace := k.GetLabels()[TopicAppLabel], k.GetLabels()[TopicAppNamespaceLabel]
+		if hasPreviousName(app, owner, ownerNamespace) {
+			s.Log.Info("Adopting topic from previous app identity", "topic", topicName, "app", app.Name, "previousApp", owner)
+		} else if adopter != nil && adopter.Name == owner && adopter.Namespace == ownerNamespace {
+			labels[TopicAppLabel] = owner
+			labels[TopicAppNamespaceLabel] = ownerNamespace
 		}
 
 		k.SetName(topicName)
@@ -954,12 +1101,22 @@ func (s *strimziProvider) processTopics(app *crd.ClowdApp, c *config.KafkaConfig
 		k.SetOwnerReferences([]metav1.OwnerReference{s.Env.MakeOwnerReference()})
 		k.SetLabels(labels)
 
-		k.Spec = &strimzi.KafkaTopicSpec{}
+		// The topic operator syncs changes made on the cluster back into the KafkaTopic spec, so
+		// rewriting the spec from the declared values each reconcile patches any drift back.
+		// Unless the topic opts out, in which case an existing spec is left untouched.
+		if !topic.IgnoreConfigDrift || k.Spec == nil || k.GetResourceVersion() == "" {
+			var livePartitions *int32
+			if k.Spec != nil && k.GetResourceVersion() != "" {
+				livePartitions = k.Spec.Partitions
+			}
 
-		err := processTopicValues(k, s.Env, appList, topic)
+			k.Spec = &strimzi.KafkaTopicSpec{}
 
-		if err != nil {
-			return err
+			if err := processTopicValues(s.Log, k, s.Env, appList, topic); err != nil {
+				return err
+			}
+
+			k.Spec.Partitions = keepLivePartitions(livePartitions, k.Spec.Partitions)
 		}
 
 		if err := s.Cache.Update(KafkaTopic, k); err != nil {
@@ -977,14 +1134,119 @@ func (s *strimziProvider) processTopics(app *crd.ClowdApp, c *config.KafkaConfig
 	return nil
 }
 
-func getTopicName(topic crd.KafkaTopicSpec, env crd.ClowdEnvironment, namespace string) string {
+// finalizeStrimziApp removes the KafkaTopics that were requested by a ClowdApp that is being
+// deleted. Topics requested by the app, either in its spec or via the app labels, are deleted
+// unless another ClowdApp in the environment still requests them, or they have been adopted by the
+// app this one was renamed to. The KafkaUser of the app is deleted as well.
+func finalizeStrimziApp(p *providers.Provider, app *crd.ClowdApp) error {
+	appList, err := p.Env.GetAppsInEnv(p.Ctx, p.Client)
+	if err != nil {
+		return errors.Wrap("Topic cleanup failed: Error listing apps", err)
+	}
+
+	stillRequested := map[string]bool{}
+	for _, iapp := range appList.Items {
+		if iapp.Name == app.Name && iapp.Namespace == app.Namespace {
+			continue
+		}
+		if iapp.GetDeletionTimestamp() != nil {
+			continue
+		}
+		for _, topic := range iapp.Spec.KafkaTopics {
+			stillRequested[GetTopicName(topic, *p.Env, iapp.Namespace)] = true
+		}
+	}
+
+	requestedByApp := map[string]bool{}
+	for _, topic := range app.Spec.KafkaTopics {
+		requestedByApp[GetTopicName(topic, *p.Env, app.Namespace)] = true
+	}
+
+	topicList := strimzi.KafkaTopicList{}
+	if err := p.Client.List(
+		p.Ctx,
+		&topicList,
+		client.InNamespace(getKafkaNamespace(p.Env)),
+		client.MatchingLabels{"env": p.Env.Name},
+	); err != nil {
+		return errors.Wrap("Topic cleanup failed: Error listing topics", err)
+	}
+
+	adopter := getAdopter(app, appList)
+
+	for _, topic := range topicList.Items {
+		labels := topic.GetLabels()
+		labelledForApp := labels[TopicAppLabel] == app.Name && labels[TopicAppNamespaceLabel] == app.Namespace
+
+		if !labelledForApp && !requestedByApp[topic.Name] {
+			continue
+		}
+
+		if adopter != nil && labels[TopicAppLabel] == adopter.Name && labels[TopicAppNamespaceLabel] == adopter.Namespace {
+			continue
+		}
+
+		if stillRequested[topic.Name] {
+			continue
+		}
+
+		innerTopic := topic
+		p.Log.Info("Deleting topic for removed app", "topic", topic.Name, "app", app.Name, "namespace", app.Namespace)
+		if err := p.Client.Delete(p.Ctx, &innerTopic); err != nil && !k8serr.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return deleteKafkaUser(p, app, appList)
+}
+
+// deleteKafkaUser removes the KafkaUser of a ClowdApp that is being deleted, Strimzi then removes
+// the secret holding its credentials. The KafkaUser lives in the kafka namespace so it can't be
+// owned by the app. Apps with the same name in other namespaces of the environment share the
+// user, which is kept while one of them remains.
+func deleteKafkaUser(p *providers.Provider, app *crd.ClowdApp, appList *crd.ClowdAppList) error {
+	for _, iapp := range appList.Items {
+		if iapp.Name == app.Name && iapp.Namespace != app.Namespace && iapp.GetDeletionTimestamp() == nil {
+			return nil
+		}
+	}
+
+	ku := &strimzi.KafkaUser{}
+	ku.Name = getKafkaUsername(p.Env, app)
+	ku.Namespace = getKafkaNamespace(p.Env)
+
+	p.Log.Info("Deleting kafka user for removed app", "user", ku.Name, "app", app.Name, "namespace", app.Namespace)
+	if err := p.Client.Delete(p.Ctx, ku); err != nil && !k8serr.IsNotFound(err) {
+		return errors.Wrap("User cleanup failed", err)
+	}
+
+	return nil
+}
+
+// GetTopicName returns the name of the KafkaTopic created in operator mode for a topic requested
+// by an app in the given namespace.
+func GetTopicName(topic crd.KafkaTopicSpec, env crd.ClowdEnvironment, namespace string) string {
+	if name, ok := strategyTopicName(topic, env); ok {
+		return name
+	}
 	if clowderconfig.LoadedConfig.Features.UseComplexStrimziTopicNames {
 		return fmt.Sprintf("%s-%s-%s", topic.TopicName, env.Name, namespace)
 	}
 	return topic.TopicName
 }
 
+// keepLivePartitions returns the larger of the live and the declared partitions of a topic. Kafka
+// can't remove the partitions of a topic, so a topic keeps the partitions it has once the largest
+// request of the apps falls below them, increases are left for the topic operator to apply.
+func keepLivePartitions(live *int32, declared *int32) *int32 {
+	if live == nil || (declared != nil && *declared >= *live) {
+		return declared
+	}
+	return live
+}
+
 func processTopicValues(
+	log logr.Logger,
 	k *strimzi.KafkaTopic,
 	env *crd.ClowdEnvironment,
 	appList *crd.ClowdAppList,
@@ -1014,30 +1276,21 @@ func processTopicValues(
 		}
 	}
 
-	jsonData := "{"
-
-	for key, valList := range keys {
-		f, ok := conversionMap[key]
-		if ok {
-			out, _ := f(valList)
-			jsonData = fmt.Sprintf("%s\"%s\":\"%s\",", jsonData, key, out)
-		} else {
-			return errors.NewClowderError(fmt.Sprintf("no conversion type for %s", key))
-		}
+	topicConfig, err := mergeTopicConfig(log, topic.TopicName, keys)
+	if err != nil {
+		return err
 	}
 
-	if len(jsonData) > 1 {
-		jsonData = jsonData[0 : len(jsonData)-1]
+	// the keys of a marshalled map are sorted so the config is stable across reconciles
+	jsonData, err := json.Marshal(topicConfig)
+	if err != nil {
+		return err
 	}
-	jsonData += "}"
 
 	var config apiextensions.JSON
 
-	err := config.UnmarshalJSON([]byte(jsonData))
-
-	if err != nil {
+	if err := config.UnmarshalJSON(jsonData); err != nil {
 		return err
-
 	}
 
 	k.Spec.Config = &config
@@ -1074,10 +1327,13 @@ func processTopicValues(
 		}
 	}
 
-	if env.Spec.Providers.Kafka.Cluster.Replicas < int32(1) {
-		k.Spec.Replicas = utils.Int32Ptr(1)
-	} else if env.Spec.Providers.Kafka.Cluster.Replicas < *k.Spec.Replicas {
-		k.Spec.Replicas = &env.Spec.Providers.Kafka.Cluster.Replicas
+	if k.Spec.Replicas == nil {
+		k.Spec.Replicas = utils.Int32Ptr(3)
+	}
+
+	if brokers := getTopicMaxReplicas(&env.Spec.Providers.Kafka.Cluster); brokers < *k.Spec.Replicas {
+		log.Info("Topic requests more replicas than there are brokers, capping to the broker count", "topic", topic.TopicName, "replicas", *k.Spec.Replicas, "brokers", brokers)
+		k.Spec.Replicas = utils.Int32Ptr(int(brokers))
 	}
 
 	return nil
@@ -0,0 +1,520 @@
+package kafka
+
+import (
+	"context"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func strimziTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, crd.AddToScheme(scheme))
+	assert.NoError(t, strimzi.AddToScheme(scheme))
+	return scheme
+}
+
+func strimziTestTopic(name string, appName string, appNamespace string) *strimzi.KafkaTopic {
+	return &strimzi.KafkaTopic{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "kafka",
+			Labels: map[string]string{
+				"env":                  "env",
+				TopicAppLabel:          appName,
+				TopicAppNamespaceLabel: appNamespace,
+			},
+		},
+	}
+}
+
+func strimziTestApp(name string, namespace string, topics ...string) *crd.ClowdApp {
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: crd.ClowdAppSpec{
+			EnvName: "env",
+		},
+	}
+	for _, topic := range topics {
+		app.Spec.KafkaTopics = append(app.Spec.KafkaTopics, crd.KafkaTopicSpec{TopicName: topic})
+	}
+	return app
+}
+
+func strimziTestEnv() *crd.ClowdEnvironment {
+	return &crd.ClowdEnvironment{
+		ObjectMeta: metav1.ObjectMeta{Name: "env"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{
+					Mode: "operator",
+					Cluster: crd.KafkaClusterConfig{
+						Name:      "kafka",
+						Namespace: "kafka",
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestFinalizeStrimziAppCrossNamespace(t *testing.T) {
+	deletedApp := strimziTestApp("deleted", "app-ns", "solo", "shared")
+	otherApp := strimziTestApp("other", "other-ns", "shared")
+
+	pClient := fake.NewClientBuilder().
+		WithScheme(strimziTestScheme(t)).
+		WithObjects(
+			otherApp,
+			strimziTestTopic("solo", "deleted", "app-ns"),
+			// the shared topic was last labelled by the app being deleted
+			strimziTestTopic("shared", "deleted", "app-ns"),
+			// no longer in the app spec, but still labelled as belonging to it
+			strimziTestTopic("stale", "deleted", "app-ns"),
+			strimziTestTopic("unrelated", "other", "other-ns"),
+		).
+		Build()
+
+	p := &providers.Provider{
+		Client: pClient,
+		Ctx:    context.Background(),
+		Log:    logr.Discard(),
+		Env:    strimziTestEnv(),
+	}
+
+	err := GetKafkaAppFinalize(p, deletedApp)
+	assert.NoError(t, err)
+
+	exists := func(name string) bool {
+		topic := strimzi.KafkaTopic{}
+		err := pClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "kafka"}, &topic)
+		if k8serr.IsNotFound(err) {
+			return false
+		}
+		assert.NoError(t, err)
+		return true
+	}
+
+	assert.False(t, exists("solo"), "topic only requested by the deleted app was not removed")
+	assert.False(t, exists("stale"), "topic labelled for the deleted app was not removed")
+	assert.True(t, exists("shared"), "topic still requested by another app was removed")
+	assert.True(t, exists("unrelated"), "topic belonging to another app was removed")
+
+	// Running the finalizer a second time must be a no-op
+	err = GetKafkaAppFinalize(p, deletedApp)
+	assert.NoError(t, err)
+
+	topics := strimzi.KafkaTopicList{}
+	assert.NoError(t, pClient.List(context.Background(), &topics, client.InNamespace("kafka")))
+	assert.Len(t, topics.Items, 2)
+}
+
+func TestFinalizeStrimziAppKafkaUser(t *testing.T) {
+	kafkaUser := func(name string) *strimzi.KafkaUser {
+		return &strimzi.KafkaUser{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "kafka"}}
+	}
+
+	deletedApp := strimziTestApp("deleted", "app-ns", "solo")
+	sharedApp := strimziTestApp("shared", "app-ns", "solo")
+
+	pClient := fake.NewClientBuilder().
+		WithScheme(strimziTestScheme(t)).
+		WithObjects(
+			// an app of the same name in another namespace uses the same user
+			strimziTestApp("shared", "other-ns"),
+			kafkaUser("env-deleted"),
+			kafkaUser("env-shared"),
+			kafkaUser("env-other"),
+		).
+		Build()
+
+	p := &providers.Provider{
+		Client: pClient,
+		Ctx:    context.Background(),
+		Log:    logr.Discard(),
+		Env:    strimziTestEnv(),
+	}
+
+	assert.NoError(t, GetKafkaAppFinalize(p, deletedApp))
+	assert.NoError(t, GetKafkaAppFinalize(p, sharedApp))
+
+	// a missing user is not an error
+	assert.NoError(t, GetKafkaAppFinalize(p, deletedApp))
+
+	users := strimzi.KafkaUserList{}
+	assert.NoError(t, pClient.List(context.Background(), &users, client.InNamespace("kafka")))
+
+	names := []string{}
+	for _, user := range users.Items {
+		names = append(names, user.Name)
+	}
+	assert.ElementsMatch(t, []string{"env-shared", "env-other"}, names)
+}
+
+func TestStrimziTopicAdoption(t *testing.T) {
+	oldApp := strimziTestApp("old", "app-ns", "orders", "audit")
+	newApp := strimziTestApp("new", "app-ns", "orders")
+	newApp.SetAnnotations(map[string]string{PreviousNamesAnnotation: "older, old"})
+
+	scheme := strimziTestScheme(t)
+	pClient := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			oldApp,
+			newApp,
+			strimziTestTopic("orders", "old", "app-ns"),
+			strimziTestTopic("audit", "old", "app-ns"),
+		).
+		Build()
+
+	p := &providers.Provider{
+		Client: pClient,
+		Ctx:    context.Background(),
+		Log:    logr.Discard(),
+		Env:    strimziTestEnv(),
+	}
+
+	// each reconcile starts with an empty resource cache
+	processTopics := func(app *crd.ClowdApp) error {
+		cache := rc.NewObjectCache(p.Ctx, pClient, &p.Log, rc.NewCacheConfig(scheme, nil, nil))
+		s := &strimziProvider{Provider: *p}
+		s.Cache = &cache
+		return s.processTopics(app, &config.KafkaConfig{})
+	}
+
+	getTopic := func(name string) (*strimzi.KafkaTopic, bool) {
+		topic := &strimzi.KafkaTopic{}
+		err := pClient.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "kafka"}, topic)
+		if k8serr.IsNotFound(err) {
+			return nil, false
+		}
+		assert.NoError(t, err)
+		return topic, true
+	}
+
+	// The renamed app adopts the existing topic rather than creating another one
+	assert.NoError(t, processTopics(newApp))
+	topic, ok := getTopic("orders")
+	assert.True(t, ok)
+	assert.Equal(t, "new", topic.GetLabels()[TopicAppLabel])
+
+	// The old app no longer takes the topic back while it is still around
+	assert.NoError(t, processTopics(oldApp))
+	topic, _ = getTopic("orders")
+	assert.Equal(t, "new", topic.GetLabels()[TopicAppLabel])
+
+	// Removing the old app only cleans up the topics it still owns
+	assert.NoError(t, pClient.Delete(context.Background(), oldApp))
+	assert.NoError(t, GetKafkaAppFinalize(p, oldApp))
+
+	_, ok = getTopic("orders")
+	assert.True(t, ok, "topic adopted by the renamed app was removed")
+	_, ok = getTopic("audit")
+	assert.False(t, ok, "topic only owned by the old app was not removed")
+
+	topics := strimzi.KafkaTopicList{}
+	assert.NoError(t, pClient.List(context.Background(), &topics, client.InNamespace("kafka")))
+	assert.Len(t, topics.Items, 1)
+}
+
+func TestKafkaUserConsumerGroupACLs(t *testing.T) {
+	scheme := strimziTestScheme(t)
+	pClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	env := strimziTestEnv()
+	env.Spec.Providers.Kafka.ConsumerGroupACLs = true
+
+	p := &providers.Provider{
+		Client: pClient,
+		Ctx:    context.Background(),
+		Log:    logr.Discard(),
+		Env:    env,
+	}
+
+	getGroupACLs := func(app *crd.ClowdApp) []string {
+		cache := rc.NewObjectCache(p.Ctx, pClient, &p.Log, rc.NewCacheConfig(scheme, nil, nil))
+		s := &strimziProvider{Provider: *p}
+		s.Cache = &cache
+		assert.NoError(t, s.createKafkaUser(app))
+
+		ku := &strimzi.KafkaUser{}
+		nn := types.NamespacedName{Name: getKafkaUsername(env, app), Namespace: "kafka"}
+		assert.NoError(t, pClient.Get(context.Background(), nn, ku))
+
+		groups := []string{}
+		for _, acl := range ku.Spec.Authorization.Acls {
+			if acl.Resource.Type == strimzi.KafkaUserSpecAuthorizationAclsElemResourceTypeGroup {
+				groups = append(groups, *acl.Resource.Name)
+			}
+		}
+		return groups
+	}
+
+	// A consuming app without explicit groups gets a group named after it
+	consumer := strimziTestApp("consumer", "app-ns", "orders")
+	assert.Equal(t, []string{"env-consumer"}, getGroupACLs(consumer))
+
+	// Explicit groups replace the default one
+	grouped := strimziTestApp("grouped", "app-ns", "orders")
+	grouped.Spec.KafkaConsumerGroups = []string{"orders-processor", "orders-audit"}
+	assert.Equal(t, []string{"orders-processor", "orders-audit"}, getGroupACLs(grouped))
+
+	// Without restricted groups every group is allowed
+	env.Spec.Providers.Kafka.ConsumerGroupACLs = false
+	unrestricted := strimziTestApp("unrestricted", "app-ns", "orders")
+	assert.Equal(t, []string{"*"}, getGroupACLs(unrestricted))
+}
+
+func TestKeepLivePartitions(t *testing.T) {
+	three, five := int32(3), int32(5)
+
+	// new topics and increases are left to the topic operator
+	assert.Equal(t, &five, keepLivePartitions(nil, &five))
+	assert.Equal(t, &five, keepLivePartitions(&three, &five))
+	assert.Equal(t, &five, keepLivePartitions(&five, &five))
+
+	// a topic is never shrunk
+	assert.Equal(t, &five, keepLivePartitions(&five, &three))
+	assert.Equal(t, &five, keepLivePartitions(&five, nil))
+}
+
+func TestKafkaBrokerReplicas(t *testing.T) {
+	tests := []struct {
+		name     string
+		cluster  crd.KafkaClusterConfig
+		expected int32
+	}{
+		{"unset", crd.KafkaClusterConfig{}, 1},
+		{"replicas", crd.KafkaClusterConfig{Replicas: 5}, 5},
+		{"target", crd.KafkaClusterConfig{
+			Replicas:    5,
+			Autoscaling: &crd.KafkaClusterAutoscaling{MinReplicas: 2, MaxReplicas: 8, TargetReplicas: 7},
+		}, 7},
+		{"target defaults to replicas", crd.KafkaClusterConfig{
+			Replicas:    5,
+			Autoscaling: &crd.KafkaClusterAutoscaling{MinReplicas: 2, MaxReplicas: 8},
+		}, 5},
+		{"target below minimum", crd.KafkaClusterConfig{
+			Replicas:    1,
+			Autoscaling: &crd.KafkaClusterAutoscaling{MinReplicas: 3, MaxReplicas: 8, TargetReplicas: 2},
+		}, 3},
+		{"target above maximum", crd.KafkaClusterConfig{
+			Replicas:    5,
+			Autoscaling: &crd.KafkaClusterAutoscaling{MinReplicas: 3, MaxReplicas: 6, TargetReplicas: 9},
+		}, 6},
+		{"unbounded maximum", crd.KafkaClusterConfig{
+			Replicas:    5,
+			Autoscaling: &crd.KafkaClusterAutoscaling{MinReplicas: 3, TargetReplicas: 9},
+		}, 9},
+	}
+
+	for _, tt := range tests {
+		replicas, err := getKafkaBrokerReplicas(&tt.cluster)
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.expected, replicas, tt.name)
+	}
+
+	_, err := getKafkaBrokerReplicas(&crd.KafkaClusterConfig{
+		Autoscaling: &crd.KafkaClusterAutoscaling{MinReplicas: 5, MaxReplicas: 3},
+	})
+	assert.ErrorContains(t, err, "kafka autoscaling maxReplicas 3 is less than minReplicas 5")
+}
+
+func topicConfigTestApps(configs ...map[string]string) *crd.ClowdAppList {
+	appList := &crd.ClowdAppList{}
+	for _, config := range configs {
+		appList.Items = append(appList.Items, crd.ClowdApp{
+			Spec: crd.ClowdAppSpec{KafkaTopics: []crd.KafkaTopicSpec{{
+				TopicName: "inventory",
+				Config:    config,
+			}}},
+		})
+	}
+	return appList
+}
+
+func TestProcessTopicConfig(t *testing.T) {
+	env := &crd.ClowdEnvironment{}
+	topic := crd.KafkaTopicSpec{TopicName: "inventory"}
+	first := map[string]string{
+		"retention.ms":      "86400000",
+		"max.message.bytes": "1048576",
+		"segment.bytes":     "1073741824",
+		"cleanup.policy":    "delete",
+	}
+	second := map[string]string{
+		"retention.ms":      "604800000",
+		"max.message.bytes": "524288",
+		"cleanup.policy":    "compact",
+	}
+
+	// conflicting values resolve to the most conservative one, whatever the order of the apps
+	for _, appList := range []*crd.ClowdAppList{topicConfigTestApps(first, second), topicConfigTestApps(second, first)} {
+		k := &strimzi.KafkaTopic{Spec: &strimzi.KafkaTopicSpec{}}
+		assert.NoError(t, processTopicValues(logr.Discard(), k, env, appList, topic))
+		assert.JSONEq(t, `{
+			"cleanup.policy": "compact,delete",
+			"max.message.bytes": "1048576",
+			"retention.ms": "604800000",
+			"segment.bytes": "1073741824"
+		}`, string(k.Spec.Config.Raw))
+	}
+}
+
+func TestProcessTopicConfigUnlimitedRetention(t *testing.T) {
+	env := &crd.ClowdEnvironment{}
+	topic := crd.KafkaTopicSpec{TopicName: "inventory"}
+	unlimited := map[string]string{"retention.ms": "-1", "retention.bytes": "-1"}
+	finite := map[string]string{"retention.ms": "604800000", "retention.bytes": "1073741824"}
+
+	// -1 keeps the messages forever and wins over any finite retention
+	for _, appList := range []*crd.ClowdAppList{topicConfigTestApps(unlimited, finite), topicConfigTestApps(finite, unlimited)} {
+		k := &strimzi.KafkaTopic{Spec: &strimzi.KafkaTopicSpec{}}
+		assert.NoError(t, processTopicValues(logr.Discard(), k, env, appList, topic))
+		assert.JSONEq(t, `{"retention.ms": "-1", "retention.bytes": "-1"}`, string(k.Spec.Config.Raw))
+	}
+
+	k := &strimzi.KafkaTopic{Spec: &strimzi.KafkaTopicSpec{}}
+	err := processTopicValues(logr.Discard(), k, env, topicConfigTestApps(unlimited, map[string]string{"retention.ms": "forever"}), topic)
+	assert.ErrorContains(t, err, "invalid value for retention.ms on topic inventory")
+}
+
+func TestProcessTopicConfigInvalid(t *testing.T) {
+	env := &crd.ClowdEnvironment{}
+	topic := crd.KafkaTopicSpec{TopicName: "inventory"}
+
+	k := &strimzi.KafkaTopic{Spec: &strimzi.KafkaTopicSpec{}}
+	err := processTopicValues(logr.Discard(), k, env, topicConfigTestApps(map[string]string{"retention.ms": "forever"}), topic)
+	assert.ErrorContains(t, err, "invalid value for retention.ms on topic inventory")
+
+	err = processTopicValues(logr.Discard(), k, env, topicConfigTestApps(map[string]string{"unclean.leader.election.enable": "true"}), topic)
+	assert.ErrorContains(t, err, "no conversion type for unclean.leader.election.enable")
+}
+
+func TestProcessTopicReplicas(t *testing.T) {
+	topic := crd.KafkaTopicSpec{TopicName: "inventory"}
+	appList := func(replicas ...int32) *crd.ClowdAppList {
+		list := &crd.ClowdAppList{}
+		for _, r := range replicas {
+			list.Items = append(list.Items, crd.ClowdApp{
+				Spec: crd.ClowdAppSpec{KafkaTopics: []crd.KafkaTopicSpec{{
+					TopicName: "inventory",
+					Replicas:  r,
+				}}},
+			})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name     string
+		cluster  crd.KafkaClusterConfig
+		apps     *crd.ClowdAppList
+		expected int32
+	}{
+		{"fits the cluster", crd.KafkaClusterConfig{Replicas: 5}, appList(2, 3), 3},
+		{"capped to the brokers", crd.KafkaClusterConfig{Replicas: 3}, appList(5), 3},
+		{"default capped to the brokers", crd.KafkaClusterConfig{Replicas: 2}, appList(0), 2},
+		{"unset cluster", crd.KafkaClusterConfig{}, appList(3), 1},
+		{"capped to the autoscaling minimum", crd.KafkaClusterConfig{
+			Replicas:    5,
+			Autoscaling: &crd.KafkaClusterAutoscaling{MinReplicas: 2, MaxReplicas: 5},
+		}, appList(5), 2},
+	}
+
+	for _, tt := range tests {
+		env := &crd.ClowdEnvironment{}
+		env.Spec.Providers.Kafka.Cluster = tt.cluster
+
+		k := &strimzi.KafkaTopic{Spec: &strimzi.KafkaTopicSpec{}}
+		assert.NoError(t, processTopicValues(logr.Discard(), k, env, tt.apps, topic), tt.name)
+		assert.Equal(t, tt.expected, *k.Spec.Replicas, tt.name)
+	}
+}
+
+func TestStrimziTopicNamingStrategy(t *testing.T) {
+	for strategy, expected := range map[crd.KafkaTopicNamingStrategy]string{
+		"":              "inventory",
+		"app-interface": "inventory",
+		"namespaced":    "env-inventory",
+		"sharded":       "inventory-b77349bf",
+	} {
+		app := strimziTestApp("inventory", "app-ns", "inventory")
+		env := strimziTestEnv()
+		env.Spec.Providers.Kafka.TopicNamingStrategy = strategy
+
+		scheme := strimziTestScheme(t)
+		pClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(app).Build()
+		log := logr.Discard()
+		cache := rc.NewObjectCache(context.Background(), pClient, &log, rc.NewCacheConfig(scheme, nil, nil))
+
+		s := &strimziProvider{Provider: providers.Provider{
+			Client: pClient,
+			Ctx:    context.Background(),
+			Log:    log,
+			Env:    env,
+			Cache:  &cache,
+		}}
+		c := &config.KafkaConfig{}
+		assert.NoError(t, s.processTopics(app, c), strategy)
+		assert.NoError(t, cache.ApplyAll(), strategy)
+
+		topic := &strimzi.KafkaTopic{}
+		assert.NoError(t, pClient.Get(context.Background(), types.NamespacedName{Name: expected, Namespace: "kafka"}, topic), strategy)
+		assert.Equal(t, []config.TopicConfig{{Name: expected, RequestedName: "inventory"}}, c.Topics, strategy)
+	}
+}
+
+func TestStrimziTopicPartitionsAreMinimums(t *testing.T) {
+	small := strimziTestApp("small", "app-ns", "inventory")
+	small.Spec.KafkaTopics[0].Partitions = 3
+	large := strimziTestApp("large", "other-ns", "inventory")
+	large.Spec.KafkaTopics[0].Partitions = 7
+
+	scheme := strimziTestScheme(t)
+	pClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(small, large).Build()
+	log := logr.Discard()
+
+	processTopics := func(app *crd.ClowdApp) *strimzi.KafkaTopic {
+		cache := rc.NewObjectCache(context.Background(), pClient, &log, rc.NewCacheConfig(scheme, nil, nil))
+		env := strimziTestEnv()
+		env.Spec.Providers.Kafka.Cluster.Replicas = 3
+		s := &strimziProvider{Provider: providers.Provider{Client: pClient, Ctx: context.Background(), Log: log, Env: env, Cache: &cache}}
+		assert.NoError(t, s.processTopics(app, &config.KafkaConfig{}))
+		assert.NoError(t, cache.ApplyAll())
+
+		topic := &strimzi.KafkaTopic{}
+		assert.NoError(t, pClient.Get(context.Background(), types.NamespacedName{Name: "inventory", Namespace: "kafka"}, topic))
+		return topic
+	}
+
+	// whichever app is reconciled, the topic gets the largest of the requested partitions
+	assert.Equal(t, int32(7), *processTopics(small).Spec.Partitions)
+	assert.Equal(t, int32(7), *processTopics(large).Spec.Partitions)
+
+	// the request of the app being reconciled counts even before the listed copy catches up
+	small.Spec.KafkaTopics[0].Partitions = 9
+	assert.Equal(t, int32(9), *processTopics(small).Spec.Partitions)
+
+	// once the app requesting the most is gone the topic keeps its partitions, and the remaining
+	// apps still reconcile
+	assert.NoError(t, pClient.Delete(context.Background(), large))
+	small.Spec.KafkaTopics[0].Partitions = 3
+	assert.NoError(t, pClient.Update(context.Background(), small))
+	assert.Equal(t, int32(9), *processTopics(small).Spec.Partitions)
+}
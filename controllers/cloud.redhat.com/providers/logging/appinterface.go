@@ -7,6 +7,7 @@ import (
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 
 	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
 )
 
@@ -36,9 +37,16 @@ func setCloudwatchSecret(ns string, p *providers.Provider, c *config.LoggingConf
 	}
 
 	secret := core.Secret{}
-	err := p.Client.Get(p.Ctx, name, &secret)
-
-	if err != nil {
+	err := p.GetShared(name, &secret)
+
+	if k8serr.IsNotFound(err) {
+		missingSecrets := errors.MakeMissingSecrets(errors.MissingSecret{
+			Provider:  "logging",
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		})
+		return &missingSecrets
+	} else if err != nil {
 		return errors.Wrap("Failed to fetch cloudwatch secret", err)
 	}
 
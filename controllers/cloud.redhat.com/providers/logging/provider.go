@@ -13,6 +13,8 @@ func GetLogging(c *providers.Provider) (providers.ClowderProvider, error) {
 	switch logMode {
 	case "app-interface":
 		return NewAppInterfaceLogging(c), nil
+	case "splunk":
+		return NewSplunkLogging(c), nil
 	case "none", "null", "":
 		return NewNoneLogging(c), nil
 	default:
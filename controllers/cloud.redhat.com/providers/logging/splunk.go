@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type splunkLoggingProvider struct {
+	providers.Provider
+}
+
+// NewSplunkLogging returns a new splunk logging provider object.
+func NewSplunkLogging(p *providers.Provider) providers.ClowderProvider {
+	return &splunkLoggingProvider{Provider: *p}
+}
+
+func (a *splunkLoggingProvider) EnvProvide() error {
+	return nil
+}
+
+func (a *splunkLoggingProvider) Provide(app *crd.ClowdApp) error {
+	ref := a.Env.Spec.Providers.Logging.SecretRef
+	name := types.NamespacedName{
+		Name:      ref.Name,
+		Namespace: ref.Namespace,
+	}
+
+	secret := core.Secret{}
+	err := a.Client.Get(a.Ctx, name, &secret)
+
+	if k8serr.IsNotFound(err) {
+		missingSecrets := errors.MakeMissingSecrets(errors.MissingSecret{
+			Provider:  "logging",
+			Name:      name.Name,
+			Namespace: name.Namespace,
+		})
+		return &missingSecrets
+	} else if err != nil {
+		return errors.Wrap("Failed to fetch splunk secret", err)
+	}
+
+	splunkConfig, err := genSplunkConfig(&secret, app.Name)
+
+	if err != nil {
+		return err
+	}
+
+	a.Config.Logging = config.LoggingConfig{
+		Splunk: splunkConfig,
+		Type:   "splunk",
+	}
+
+	return nil
+}
+
+// genSplunkConfig builds the splunk config from the secret holding the HEC credentials, the
+// source defaults to the name of the app if the secret does not set one.
+func genSplunkConfig(secret *core.Secret, source string) (*config.SplunkConfig, error) {
+	missing := []string{}
+	for _, key := range []string{"endpoint", "token"} {
+		if len(strings.TrimSpace(string(secret.Data[key]))) == 0 {
+			missing = append(missing, key)
+		}
+	}
+
+	if len(missing) > 0 {
+		msg := fmt.Sprintf(
+			"Splunk secret [%s/%s] is missing required keys: %s",
+			secret.Namespace, secret.Name, strings.Join(missing, ", "),
+		)
+		return nil, errors.NewClowderError(msg)
+	}
+
+	endpoint, err := normalizeSplunkEndpoint(string(secret.Data["endpoint"]))
+
+	if err != nil {
+		return nil, err
+	}
+
+	splunkConfig := &config.SplunkConfig{
+		Endpoint: endpoint,
+		Token:    strings.TrimSpace(string(secret.Data["token"])),
+		Source:   source,
+	}
+
+	if index := strings.TrimSpace(string(secret.Data["index"])); index != "" {
+		splunkConfig.Index = &index
+	}
+
+	if s := strings.TrimSpace(string(secret.Data["source"])); s != "" {
+		splunkConfig.Source = s
+	}
+
+	return splunkConfig, nil
+}
+
+// normalizeSplunkEndpoint turns a bare hostname into an https URL, full URLs are passed through.
+func normalizeSplunkEndpoint(endpoint string) (string, error) {
+	endpoint = strings.TrimSpace(endpoint)
+
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+
+	u, err := url.Parse(endpoint)
+
+	if err != nil {
+		return "", errors.Wrap("Failed to parse splunk endpoint", err)
+	}
+
+	if u.Scheme != "https" || u.Host == "" {
+		return "", errors.NewClowderError(fmt.Sprintf("Splunk endpoint [%s] is not a valid https URL", endpoint))
+	}
+
+	return u.String(), nil
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func splunkSecret(data map[string]string) *core.Secret {
+	secret := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk", Namespace: "clowder"},
+		Data:       map[string][]byte{},
+	}
+	for k, v := range data {
+		secret.Data[k] = []byte(v)
+	}
+	return secret
+}
+
+func TestSplunkConfig(t *testing.T) {
+	secret := splunkSecret(map[string]string{
+		"endpoint": "https://hec.example.com:8088/services/collector",
+		"token":    "token",
+		"index":    "insights",
+	})
+
+	splunkConfig, err := genSplunkConfig(secret, "my-app")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://hec.example.com:8088/services/collector", splunkConfig.Endpoint)
+	assert.Equal(t, "token", splunkConfig.Token)
+	assert.Equal(t, "insights", *splunkConfig.Index)
+	assert.Equal(t, "my-app", splunkConfig.Source)
+
+	secret.Data["source"] = []byte("other")
+	delete(secret.Data, "index")
+	splunkConfig, err = genSplunkConfig(secret, "my-app")
+	assert.NoError(t, err)
+	assert.Nil(t, splunkConfig.Index)
+	assert.Equal(t, "other", splunkConfig.Source)
+}
+
+func TestSplunkConfigMissingToken(t *testing.T) {
+	secret := splunkSecret(map[string]string{
+		"endpoint": "hec.example.com",
+	})
+
+	_, err := genSplunkConfig(secret, "my-app")
+	assert.ErrorContains(t, err, "Splunk secret [clowder/splunk] is missing required keys: token")
+}
+
+func TestNormalizeSplunkEndpoint(t *testing.T) {
+	endpoint, err := normalizeSplunkEndpoint("hec.example.com\n")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://hec.example.com", endpoint)
+
+	endpoint, err = normalizeSplunkEndpoint("https://hec.example.com:8088")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://hec.example.com:8088", endpoint)
+
+	_, err = normalizeSplunkEndpoint("http://hec.example.com")
+	assert.Error(t, err)
+}
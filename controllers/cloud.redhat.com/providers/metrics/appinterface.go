@@ -24,8 +24,12 @@ func (m *appinterfaceMetricsProvider) Provide(app *crd.ClowdApp) error {
 		return err
 	}
 
+	if err := createAppDashboard(m.Cache, m.Env, app); err != nil {
+		return err
+	}
+
 	if clowderconfig.LoadedConfig.Features.CreateServiceMonitor {
-		if err := createServiceMonitorObjects(m.Cache, m.Env, app, "app-sre", "openshift-customer-monitoring"); err != nil {
+		if err := createServiceMonitorObjects(m.Cache, m.Env, app, "openshift-customer-monitoring", m.Env, map[string]string{"prometheus": "app-sre"}); err != nil {
 			return err
 		}
 	}
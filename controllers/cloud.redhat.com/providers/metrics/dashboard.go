@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	webProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/web"
+
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+)
+
+// MetricsDashboard is the ident referring to the Grafana dashboard ConfigMap of an app.
+var MetricsDashboard = rc.NewSingleResourceIdent(ProvName, "metrics_dashboard", &core.ConfigMap{})
+
+const defaultDashboardLabel = "grafana_dashboard"
+const defaultDashboardLabelValue = "1"
+
+// dashboardTemplate renders a Grafana dashboard with a row of panels for every deployment of an
+// app, selecting the scrape targets of the deployment's metrics service by the metrics port.
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`{
+  "title": "{{ .Namespace }} / {{ .App }}",
+  "tags": ["clowder", "{{ .App }}"],
+  "timezone": "browser",
+  "schemaVersion": 36,
+  "refresh": "30s",
+  "time": {"from": "now-6h", "to": "now"},
+  "panels": [{{ range $i, $d := .Deployments }}{{ if $i }},{{ end }}
+    {
+      "id": {{ $d.UpPanelID }},
+      "type": "timeseries",
+      "title": "{{ $d.Name }} targets up",
+      "gridPos": {"h": 8, "w": 12, "x": 0, "y": {{ $d.Y }}},
+      "targets": [{"refId": "A", "expr": "sum(up{namespace=\"{{ $.Namespace }}\", service=\"{{ $d.Name }}\", instance=~\".*:{{ $.Port }}\"})"}]
+    },
+    {
+      "id": {{ $d.CPUPanelID }},
+      "type": "timeseries",
+      "title": "{{ $d.Name }} CPU",
+      "gridPos": {"h": 8, "w": 12, "x": 12, "y": {{ $d.Y }}},
+      "targets": [{"refId": "A", "expr": "sum by (pod) (rate(process_cpu_seconds_total{namespace=\"{{ $.Namespace }}\", service=\"{{ $d.Name }}\", instance=~\".*:{{ $.Port }}\"}[5m]))"}]
+    }{{ end }}
+  ]
+}
+`))
+
+type dashboardDeployment struct {
+	Name       string
+	Y          int
+	UpPanelID  int
+	CPUPanelID int
+}
+
+type dashboardValues struct {
+	App         string
+	Namespace   string
+	Port        int32
+	Deployments []dashboardDeployment
+}
+
+// renderDashboard renders the dashboard of the app for the deployments which have a metrics
+// service, the returned bool is false if there are none.
+func renderDashboard(env *crd.ClowdEnvironment, app *crd.ClowdApp) (string, bool, error) {
+	values := dashboardValues{
+		App:       app.Name,
+		Namespace: app.Namespace,
+		Port:      env.Spec.Providers.Metrics.Port,
+	}
+
+	for _, deployment := range app.Spec.Deployments {
+		innerDeployment := deployment
+		if webProvider.SkipService(&innerDeployment, env) {
+			continue
+		}
+		i := len(values.Deployments)
+		values.Deployments = append(values.Deployments, dashboardDeployment{
+			Name:       app.GetDeploymentNamespacedName(&innerDeployment).Name,
+			Y:          i * 8,
+			UpPanelID:  i*2 + 1,
+			CPUPanelID: i*2 + 2,
+		})
+	}
+
+	if len(values.Deployments) == 0 {
+		return "", false, nil
+	}
+
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, values); err != nil {
+		return "", false, err
+	}
+	return buf.String(), true, nil
+}
+
+// createAppDashboard creates the Grafana dashboard ConfigMap, owned by the app, in the namespace of
+// the app when dashboards are enabled for the environment.
+func createAppDashboard(cache *rc.ObjectCache, env *crd.ClowdEnvironment, app *crd.ClowdApp) error {
+	config := env.Spec.Providers.Metrics.Dashboards
+	if !config.Enabled {
+		return nil
+	}
+
+	dashboard, ok, err := renderDashboard(env, app)
+	if err != nil || !ok {
+		return err
+	}
+
+	nn := types.NamespacedName{
+		Name:      fmt.Sprintf("%s-grafana-dashboard", app.Name),
+		Namespace: app.Namespace,
+	}
+
+	cm := &core.ConfigMap{}
+	if err := cache.Create(MetricsDashboard, nn, cm); err != nil {
+		return err
+	}
+
+	label := config.Label
+	if label == "" {
+		label = defaultDashboardLabel
+	}
+	labelValue := config.LabelValue
+	if labelValue == "" {
+		labelValue = defaultDashboardLabelValue
+	}
+
+	labeler := utils.GetCustomLabeler(map[string]string{label: labelValue}, nn, app)
+	labeler(cm)
+
+	cm.Data = map[string]string{
+		fmt.Sprintf("%s.json", nn.Name): dashboard,
+	}
+
+	return cache.Update(MetricsDashboard, cm)
+}
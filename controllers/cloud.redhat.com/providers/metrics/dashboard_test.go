@@ -0,0 +1,93 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func dashboardTestApp() *crd.ClowdApp {
+	return &crd.ClowdApp{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "cloud.redhat.com/v1alpha1", Kind: "ClowdApp"},
+		ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test", UID: "1234"},
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{{Name: "processor"}, {Name: "api"}},
+		},
+	}
+}
+
+func TestAppDashboard(t *testing.T) {
+	scheme := serviceMonitorTestScheme(t)
+	log := logr.Discard()
+	cache := rc.NewObjectCache(context.Background(), fake.NewClientBuilder().WithScheme(scheme).Build(), &log, rc.NewCacheConfig(scheme, nil, nil))
+
+	env := scrapeTokenTestEnv(crd.MetricsScrapeTokenConfig{})
+	env.Spec.Providers.Metrics.Dashboards = crd.MetricsDashboardsConfig{Enabled: true}
+
+	assert.NoError(t, createAppDashboard(&cache, env, dashboardTestApp()))
+
+	cm := &core.ConfigMap{}
+	assert.NoError(t, cache.Get(MetricsDashboard, cm, types.NamespacedName{Name: "puptoo-grafana-dashboard", Namespace: "test"}))
+	assert.Equal(t, "1", cm.Labels["grafana_dashboard"])
+	assert.Equal(t, "puptoo", cm.Labels["app"])
+	if assert.Len(t, cm.GetOwnerReferences(), 1) {
+		assert.Equal(t, types.UID("1234"), cm.GetOwnerReferences()[0].UID)
+	}
+
+	dashboard := cm.Data["puptoo-grafana-dashboard.json"]
+	assert.True(t, json.Valid([]byte(dashboard)), dashboard)
+
+	parsed := struct {
+		Title  string `json:"title"`
+		Panels []struct {
+			ID      int `json:"id"`
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(dashboard), &parsed))
+	assert.Equal(t, "test / puptoo", parsed.Title)
+	if assert.Len(t, parsed.Panels, 4) {
+		assert.Equal(t, `sum(up{namespace="test", service="puptoo-processor", instance=~".*:9000"})`, parsed.Panels[0].Targets[0].Expr)
+		assert.Contains(t, parsed.Panels[3].Targets[0].Expr, `service="puptoo-api", instance=~".*:9000"`)
+		assert.Equal(t, 4, parsed.Panels[3].ID)
+	}
+}
+
+func TestAppDashboardLabel(t *testing.T) {
+	scheme := serviceMonitorTestScheme(t)
+	log := logr.Discard()
+	cache := rc.NewObjectCache(context.Background(), fake.NewClientBuilder().WithScheme(scheme).Build(), &log, rc.NewCacheConfig(scheme, nil, nil))
+
+	env := scrapeTokenTestEnv(crd.MetricsScrapeTokenConfig{})
+	env.Spec.Providers.Metrics.Dashboards = crd.MetricsDashboardsConfig{Enabled: true, Label: "dashboards", LabelValue: "clowder"}
+
+	assert.NoError(t, createAppDashboard(&cache, env, dashboardTestApp()))
+
+	cm := &core.ConfigMap{}
+	assert.NoError(t, cache.Get(MetricsDashboard, cm, types.NamespacedName{Name: "puptoo-grafana-dashboard", Namespace: "test"}))
+	assert.Equal(t, "clowder", cm.Labels["dashboards"])
+	assert.NotContains(t, cm.Labels, "grafana_dashboard")
+}
+
+func TestAppDashboardDisabled(t *testing.T) {
+	scheme := serviceMonitorTestScheme(t)
+	log := logr.Discard()
+	cache := rc.NewObjectCache(context.Background(), fake.NewClientBuilder().WithScheme(scheme).Build(), &log, rc.NewCacheConfig(scheme, nil, nil))
+
+	env := scrapeTokenTestEnv(crd.MetricsScrapeTokenConfig{})
+	assert.NoError(t, createAppDashboard(&cache, env, dashboardTestApp()))
+
+	cm := &core.ConfigMap{}
+	assert.Error(t, cache.Get(MetricsDashboard, cm, types.NamespacedName{Name: "puptoo-grafana-dashboard", Namespace: "test"}))
+}
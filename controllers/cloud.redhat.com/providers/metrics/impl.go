@@ -2,23 +2,34 @@ package metrics
 
 import (
 	"fmt"
+	"path"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	webProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/web"
 
 	prom "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
-	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 )
 
+// ScrapeTokenMountPath is the directory projected scrape tokens are mounted into in the
+// Prometheus pod.
+const ScrapeTokenMountPath = "/var/run/secrets/clowder/scrape-token"
+
+// ScrapeTokenPath returns the path of the projected scrape token issued for the audience.
+func ScrapeTokenPath(audience string) string {
+	return path.Join(ScrapeTokenMountPath, audience)
+}
+
 func makeMetrics(cache *rc.ObjectCache, deployment *crd.Deployment, app *crd.ClowdApp, port int32) error {
 
 	s := &core.Service{}
@@ -27,12 +38,6 @@ func makeMetrics(cache *rc.ObjectCache, deployment *crd.Deployment, app *crd.Clo
 		return err
 	}
 
-	d := &apps.Deployment{}
-
-	if err := cache.Get(deployProvider.CoreDeployment, d, app.GetDeploymentNamespacedName(deployment)); err != nil {
-		return err
-	}
-
 	appProtocol := "http"
 	metricsPort := core.ServicePort{
 		Name:        "metrics",
@@ -44,27 +49,36 @@ func makeMetrics(cache *rc.ObjectCache, deployment *crd.Deployment, app *crd.Clo
 
 	s.Spec.Ports = append(s.Spec.Ports, metricsPort)
 
-	d.Spec.Template.Spec.Containers[0].Ports = append(d.Spec.Template.Spec.Containers[0].Ports,
-		core.ContainerPort{
-			Name:          "metrics",
-			ContainerPort: port,
-			Protocol:      core.ProtocolTCP,
-		},
-	)
-
 	if err := cache.Update(webProvider.CoreService, s); err != nil {
 		return err
 	}
 
-	return cache.Update(deployProvider.CoreDeployment, d)
+	return deployProvider.EditPodTemplate(cache, app, deployment, func(t *core.PodTemplateSpec) error {
+		t.Spec.Containers[0].Ports = append(t.Spec.Containers[0].Ports,
+			core.ContainerPort{
+				Name:          "metrics",
+				ContainerPort: port,
+				Protocol:      core.ProtocolTCP,
+			},
+		)
+		return nil
+	})
 }
 
 func createMetricsOnDeployments(cache *rc.ObjectCache, env *crd.ClowdEnvironment, app *crd.ClowdApp, c *config.AppConfig) error {
 	c.MetricsPort = int(env.Spec.Providers.Metrics.Port)
 	c.MetricsPath = env.Spec.Providers.Metrics.Path
 
+	// without a metrics port there is no port to add to the services
+	if env.Spec.Providers.Metrics.Port == 0 {
+		return nil
+	}
+
 	for _, deployment := range app.Spec.Deployments {
 		innerDeployment := deployment
+		if webProvider.SkipService(&innerDeployment, env) {
+			continue
+		}
 		if err := makeMetrics(cache, &innerDeployment, app, env.Spec.Providers.Metrics.Port); err != nil {
 			return err
 		}
@@ -73,8 +87,16 @@ func createMetricsOnDeployments(cache *rc.ObjectCache, env *crd.ClowdEnvironment
 	return nil
 }
 
-func createServiceMonitorObjects(cache *rc.ObjectCache, env *crd.ClowdEnvironment, app *crd.ClowdApp, promLabel string, namespace string) error {
+// createServiceMonitorObjects creates a ServiceMonitor in the namespace for every deployment of the
+// app which has a metrics service, selecting the service by its pod label. The ServiceMonitors
+// are owned by the owner and carry its labels along with the given ones.
+func createServiceMonitorObjects(cache *rc.ObjectCache, env *crd.ClowdEnvironment, app *crd.ClowdApp, namespace string, owner client.Object, labels map[string]string) error {
 	for _, deployment := range app.Spec.Deployments {
+		innerDeployment := deployment
+		if webProvider.SkipService(&innerDeployment, env) {
+			continue
+		}
+
 		sm := &prom.ServiceMonitor{}
 		name := fmt.Sprintf("%s-%s", app.Name, deployment.Name)
 
@@ -86,11 +108,12 @@ func createServiceMonitorObjects(cache *rc.ObjectCache, env *crd.ClowdEnvironmen
 		if err := cache.Create(MetricsServiceMonitor, nn, sm); err != nil {
 			return err
 		}
-		sm.Spec.Endpoints = []prom.Endpoint{{
-			Interval: "15s",
-			Path:     env.Spec.Providers.Metrics.Path,
-			Port:     "metrics",
-		}}
+
+		endpoint, err := makeServiceMonitorEndpoint(env)
+		if err != nil {
+			return err
+		}
+		sm.Spec.Endpoints = []prom.Endpoint{endpoint}
 
 		sm.Spec.NamespaceSelector = prom.NamespaceSelector{
 			MatchNames: []string{app.Namespace},
@@ -102,7 +125,7 @@ func createServiceMonitorObjects(cache *rc.ObjectCache, env *crd.ClowdEnvironmen
 			},
 		}
 
-		labeler := utils.GetCustomLabeler(map[string]string{"prometheus": promLabel}, nn, env)
+		labeler := utils.GetCustomLabeler(labels, nn, owner)
 		labeler(sm)
 
 		sm.SetNamespace(namespace)
@@ -113,3 +136,26 @@ func createServiceMonitorObjects(cache *rc.ObjectCache, env *crd.ClowdEnvironmen
 	}
 	return nil
 }
+
+func makeServiceMonitorEndpoint(env *crd.ClowdEnvironment) (prom.Endpoint, error) {
+	endpoint := prom.Endpoint{
+		Interval: "15s",
+		Path:     env.Spec.Providers.Metrics.Path,
+		Port:     "metrics",
+	}
+
+	scrapeToken := env.Spec.Providers.Metrics.ScrapeToken
+	if !scrapeToken.Enabled {
+		return endpoint, nil
+	}
+
+	if scrapeToken.Audience == "" {
+		return endpoint, errors.NewClowderError("metrics scrape token is enabled but no audience is set")
+	}
+
+	// The token file is named after the audience so that the ServiceMonitor
+	// shows which audience the endpoint is expected to accept.
+	endpoint.BearerTokenFile = ScrapeTokenPath(scrapeToken.Audience)
+
+	return endpoint, nil
+}
@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	prom "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrapeTokenTestEnv(scrapeToken crd.MetricsScrapeTokenConfig) *crd.ClowdEnvironment {
+	return &crd.ClowdEnvironment{
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Metrics: crd.MetricsConfig{
+					Port:        9000,
+					Path:        "/metrics",
+					Mode:        "operator",
+					ScrapeToken: scrapeToken,
+				},
+			},
+		},
+	}
+}
+
+func TestServiceMonitorScrapeTokenAudience(t *testing.T) {
+	env := scrapeTokenTestEnv(crd.MetricsScrapeTokenConfig{Enabled: true, Audience: "metrics-scraper"})
+
+	endpoint, err := makeServiceMonitorEndpoint(env)
+	assert.NoError(t, err)
+	assert.Equal(t, "/var/run/secrets/clowder/scrape-token/metrics-scraper", endpoint.BearerTokenFile)
+
+	promObj := &prom.Prometheus{}
+	setScrapeTokenVolume(env, promObj)
+	assert.Len(t, promObj.Spec.Volumes, 1)
+	assert.Equal(t, "metrics-scraper", promObj.Spec.Volumes[0].Projected.Sources[0].ServiceAccountToken.Audience)
+	assert.Equal(t, ScrapeTokenMountPath, promObj.Spec.VolumeMounts[0].MountPath)
+}
+
+func TestServiceMonitorScrapeTokenDisabled(t *testing.T) {
+	env := scrapeTokenTestEnv(crd.MetricsScrapeTokenConfig{Audience: "metrics-scraper"})
+
+	endpoint, err := makeServiceMonitorEndpoint(env)
+	assert.NoError(t, err)
+	assert.Equal(t, prom.Endpoint{Interval: "15s", Path: "/metrics", Port: "metrics"}, endpoint)
+
+	promObj := &prom.Prometheus{}
+	setScrapeTokenVolume(env, promObj)
+	assert.Empty(t, promObj.Spec.Volumes)
+}
+
+func TestServiceMonitorScrapeTokenMissingAudience(t *testing.T) {
+	env := scrapeTokenTestEnv(crd.MetricsScrapeTokenConfig{Enabled: true})
+
+	_, err := makeServiceMonitorEndpoint(env)
+	assert.ErrorContains(t, err, "no audience is set")
+}
@@ -80,6 +80,8 @@ func (m *metricsProvider) EnvProvide() error {
 		},
 	}
 
+	setScrapeTokenVolume(m.Env, promObj)
+
 	labeler := utils.GetCustomLabeler(map[string]string{"env": m.Env.Name}, nn, m.Env)
 	labeler(promObj)
 
@@ -96,8 +98,12 @@ func (m *metricsProvider) Provide(app *crd.ClowdApp) error {
 		return err
 	}
 
+	if err := createAppDashboard(m.Cache, m.Env, app); err != nil {
+		return err
+	}
+
 	if clowderconfig.LoadedConfig.Features.CreateServiceMonitor {
-		if err := createServiceMonitorObjects(m.Cache, m.Env, app, m.Env.Name, m.Env.Status.TargetNamespace); err != nil {
+		if err := createServiceMonitorObjects(m.Cache, m.Env, app, m.Env.Status.TargetNamespace, m.Env, map[string]string{"prometheus": m.Env.Name}); err != nil {
 			return err
 		}
 
@@ -109,6 +115,34 @@ func (m *metricsProvider) Provide(app *crd.ClowdApp) error {
 	return nil
 }
 
+// setScrapeTokenVolume mounts a service account token, projected for the configured
+// audience, into the Prometheus pod for use when scraping secured metrics endpoints.
+func setScrapeTokenVolume(env *crd.ClowdEnvironment, promObj *prom.Prometheus) {
+	scrapeToken := env.Spec.Providers.Metrics.ScrapeToken
+	if !scrapeToken.Enabled || scrapeToken.Audience == "" {
+		return
+	}
+
+	promObj.Spec.Volumes = []core.Volume{{
+		Name: "scrape-token",
+		VolumeSource: core.VolumeSource{
+			Projected: &core.ProjectedVolumeSource{
+				Sources: []core.VolumeProjection{{
+					ServiceAccountToken: &core.ServiceAccountTokenProjection{
+						Audience: scrapeToken.Audience,
+						Path:     scrapeToken.Audience,
+					},
+				}},
+			},
+		},
+	}}
+	promObj.Spec.VolumeMounts = []core.VolumeMount{{
+		Name:      "scrape-token",
+		MountPath: ScrapeTokenMountPath,
+		ReadOnly:  true,
+	}}
+}
+
 func createPrometheusServiceAccount(cache *rc.ObjectCache, env *crd.ClowdEnvironment) error {
 
 	cr := &core.ServiceAccount{}
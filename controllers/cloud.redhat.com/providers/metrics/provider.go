@@ -17,8 +17,19 @@ var ProvName = "metrics"
 
 // GetEnd returns the correct end provider.
 func GetMetrics(c *providers.Provider) (providers.ClowderProvider, error) {
+	// ServiceMonitors are only cleaned up where the Prometheus Operator is installed, listing
+	// them would fail everywhere else
+	installed, err := serviceMonitorsInstalled(c.Client, c.Cache.GetScheme())
+	if err != nil {
+		return nil, err
+	}
+	if installed {
+		c.Cache.AddPossibleGVKFromIdent(
+			MetricsServiceMonitor,
+		)
+	}
 	c.Cache.AddPossibleGVKFromIdent(
-		MetricsServiceMonitor,
+		MetricsDashboard,
 	)
 	metricsMode := c.Env.Spec.Providers.Metrics.Mode
 	switch metricsMode {
@@ -28,6 +39,8 @@ func GetMetrics(c *providers.Provider) (providers.ClowderProvider, error) {
 		return NewMetricsProvider(c)
 	case "app-interface":
 		return NewAppInterfaceMetrics(c)
+	case "service-monitor":
+		return NewServiceMonitorMetricsProvider(c)
 	default:
 		errStr := fmt.Sprintf("No matching metrics mode for %s", metricsMode)
 		return nil, errors.New(errStr)
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
+
+	prom "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+)
+
+// serviceMonitorMetricsProvider sets up the metrics ports like the other modes and creates a
+// ServiceMonitor next to each metrics service, leaving the Prometheus instance scraping them to
+// the cluster.
+type serviceMonitorMetricsProvider struct {
+	providers.Provider
+	installed bool
+}
+
+// NewServiceMonitorMetricsProvider returns a new service-monitor metrics provider object.
+func NewServiceMonitorMetricsProvider(p *providers.Provider) (providers.ClowderProvider, error) {
+	installed, err := serviceMonitorsInstalled(p.Client, p.Cache.GetScheme())
+	if err != nil {
+		return nil, err
+	}
+	return &serviceMonitorMetricsProvider{Provider: *p, installed: installed}, nil
+}
+
+func (m *serviceMonitorMetricsProvider) EnvProvide() error {
+	return nil
+}
+
+func (m *serviceMonitorMetricsProvider) Provide(app *crd.ClowdApp) error {
+
+	if err := createMetricsOnDeployments(m.Cache, m.Env, app, m.Config); err != nil {
+		return err
+	}
+
+	if err := createAppDashboard(m.Cache, m.Env, app); err != nil {
+		return err
+	}
+
+	if !m.installed {
+		m.Log.Info("ServiceMonitor CRD is not installed, skipping ServiceMonitors", "app", app.Name)
+		return nil
+	}
+
+	return createAppServiceMonitors(m.Cache, m.Env, app)
+}
+
+// createAppServiceMonitors creates a ServiceMonitor, owned by the app, in the namespace of the
+// app for every deployment which has a metrics service.
+func createAppServiceMonitors(cache *rc.ObjectCache, env *crd.ClowdEnvironment, app *crd.ClowdApp) error {
+	return createServiceMonitorObjects(cache, env, app, app.Namespace, app, nil)
+}
+
+// serviceMonitorsInstalled reports whether the ServiceMonitor CRD of the Prometheus Operator is
+// installed in the cluster, clusters without it would reject any request for ServiceMonitors.
+func serviceMonitorsInstalled(pClient client.Client, scheme *runtime.Scheme) (bool, error) {
+	gvk, err := utils.GetKindFromObj(scheme, &prom.ServiceMonitor{})
+	if err != nil {
+		return false, err
+	}
+	return provutils.KindInstalled(pClient.RESTMapper(), gvk)
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/go-logr/logr"
+	prom "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func serviceMonitorTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, crd.AddToScheme(scheme))
+	assert.NoError(t, prom.AddToScheme(scheme))
+	return scheme
+}
+
+func TestServiceMonitorsInstalled(t *testing.T) {
+	scheme := serviceMonitorTestScheme(t)
+
+	// the default mapper of the fake client knows no kinds at all
+	installed, err := serviceMonitorsInstalled(fake.NewClientBuilder().WithScheme(scheme).Build(), scheme)
+	assert.NoError(t, err)
+	assert.False(t, installed)
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{prom.SchemeGroupVersion})
+	mapper.Add(prom.SchemeGroupVersion.WithKind("ServiceMonitor"), meta.RESTScopeNamespace)
+
+	installed, err = serviceMonitorsInstalled(fake.NewClientBuilder().WithScheme(scheme).WithRESTMapper(mapper).Build(), scheme)
+	assert.NoError(t, err)
+	assert.True(t, installed)
+}
+
+func TestAppServiceMonitors(t *testing.T) {
+	scheme := serviceMonitorTestScheme(t)
+	log := logr.Discard()
+	cache := rc.NewObjectCache(context.Background(), fake.NewClientBuilder().WithScheme(scheme).Build(), &log, rc.NewCacheConfig(scheme, nil, nil))
+
+	env := scrapeTokenTestEnv(crd.MetricsScrapeTokenConfig{})
+	env.Spec.Providers.Metrics.Mode = "service-monitor"
+
+	app := &crd.ClowdApp{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "cloud.redhat.com/v1alpha1", Kind: "ClowdApp"},
+		ObjectMeta: metav1.ObjectMeta{Name: "puptoo", Namespace: "test", UID: "1234"},
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{
+				{Name: "processor", WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true}}},
+			},
+		},
+	}
+
+	assert.NoError(t, createAppServiceMonitors(&cache, env, app))
+
+	sm := &prom.ServiceMonitor{}
+	assert.NoError(t, cache.Get(MetricsServiceMonitor, sm, types.NamespacedName{Name: "puptoo-processor", Namespace: "test"}))
+	assert.Equal(t, map[string]string{"pod": "puptoo-processor"}, sm.Spec.Selector.MatchLabels)
+	assert.Equal(t, []string{"test"}, sm.Spec.NamespaceSelector.MatchNames)
+	if assert.Len(t, sm.Spec.Endpoints, 1) {
+		assert.Equal(t, "/metrics", sm.Spec.Endpoints[0].Path)
+		assert.Equal(t, "metrics", sm.Spec.Endpoints[0].Port)
+	}
+	if assert.Len(t, sm.GetOwnerReferences(), 1) {
+		assert.Equal(t, types.UID("1234"), sm.GetOwnerReferences()[0].UID)
+		assert.Equal(t, "ClowdApp", sm.GetOwnerReferences()[0].Kind)
+	}
+}
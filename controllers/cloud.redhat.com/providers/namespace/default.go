@@ -5,16 +5,22 @@ import (
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	utils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
 
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	rhcutils "github.com/RedHatInsights/rhc-osdk-utils/utils"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+// CoreLimitRange is the LimitRange kept in the target namespace of the environment.
+var CoreLimitRange = rc.NewSingleResourceIdent(ProvName, "core_limit_range", &core.LimitRange{})
+
 type namespaceProvider struct {
 	providers.Provider
 }
 
 // NewNamespaceProvider returns a new Namespace provider.
 func NewNamespaceProvider(p *providers.Provider) (providers.ClowderProvider, error) {
+	p.Cache.AddPossibleGVKFromIdent(CoreLimitRange)
 	return &namespaceProvider{Provider: *p}, nil
 }
 
@@ -26,7 +32,15 @@ func (nsp *namespaceProvider) EnvProvide() error {
 		_ = setLabelOnNamespace(&nsp.Provider, clowderNs)
 	}
 
-	return setLabelOnNamespace(&nsp.Provider, nsp.Env.Status.TargetNamespace)
+	if err := setLabelOnNamespace(&nsp.Provider, nsp.Env.Status.TargetNamespace); err != nil {
+		return err
+	}
+
+	if nsp.Env.Spec.LimitRange.Enabled {
+		return createLimitRange(&nsp.Provider)
+	}
+
+	return nil
 }
 
 func (nsp *namespaceProvider) Provide(app *crd.ClowdApp) error {
@@ -55,3 +69,32 @@ func setLabelOnNamespace(p *providers.Provider, ns string) error {
 
 	return nil
 }
+
+// createLimitRange applies the container resource defaults and maximums configured on the
+// environment to its target namespace.
+func createLimitRange(p *providers.Provider) error {
+	nn := types.NamespacedName{
+		Name:      p.Env.GetClowdName(),
+		Namespace: p.Env.Status.TargetNamespace,
+	}
+
+	limitRange := &core.LimitRange{}
+	if err := p.Cache.Create(CoreLimitRange, nn, limitRange); err != nil {
+		return err
+	}
+
+	labeler := rhcutils.GetCustomLabeler(nil, nn, p.Env)
+	labeler(limitRange)
+
+	config := p.Env.Spec.LimitRange
+	limitRange.Spec = core.LimitRangeSpec{
+		Limits: []core.LimitRangeItem{{
+			Type:           core.LimitTypeContainer,
+			Default:        config.Default,
+			DefaultRequest: config.DefaultRequest,
+			Max:            config.Max,
+		}},
+	}
+
+	return p.Cache.Update(CoreLimitRange, limitRange)
+}
@@ -84,8 +84,24 @@ func resolveBucketDeps(requestedBuckets []string, c *config.ObjectStoreConfig) e
 }
 
 func genObjStoreConfig(secrets []core.Secret) (*config.ObjectStoreConfig, error) {
+	buckets, hostname := extractBuckets(secrets)
+	objectStoreConfig := config.ObjectStoreConfig{Port: 443, Hostname: hostname}
+
+	if len(buckets) > 0 && objectStoreConfig.Hostname == "" {
+		err := errors.NewClowderError("Could not find object store hostname from secrets")
+		return nil, err
+	}
+
+	objectStoreConfig.Buckets = buckets
+	objectStoreConfig.Tls = true
+	return &objectStoreConfig, nil
+}
+
+// extractBuckets returns the buckets, along with their credentials, described by the given
+// secrets and the object store endpoint, if any of the secrets define one.
+func extractBuckets(secrets []core.Secret) ([]config.ObjectStoreBucket, string) {
 	buckets := []config.ObjectStoreBucket{}
-	objectStoreConfig := config.ObjectStoreConfig{Port: 443}
+	hostname := ""
 
 	extractFn := func(secret *core.Secret, bucket string) {
 		bucketConfig := config.ObjectStoreBucket{
@@ -96,7 +112,7 @@ func genObjStoreConfig(secrets []core.Secret) (*config.ObjectStoreConfig, error)
 		}
 
 		if endpoint, ok := secret.Data["endpoint"]; ok {
-			objectStoreConfig.Hostname = string(endpoint)
+			hostname = string(endpoint)
 		}
 
 		buckets = append(buckets, bucketConfig)
@@ -112,12 +128,5 @@ func genObjStoreConfig(secrets []core.Secret) (*config.ObjectStoreConfig, error)
 	keys = append(keys, "bucket")
 	providers.ExtractSecretData(secrets, extractFnNoAnno, keys...)
 
-	if len(buckets) > 0 && objectStoreConfig.Hostname == "" {
-		err := errors.NewClowderError("Could not find object store hostname from secrets")
-		return nil, err
-	}
-
-	objectStoreConfig.Buckets = buckets
-	objectStoreConfig.Tls = true
-	return &objectStoreConfig, nil
+	return buckets, hostname
 }
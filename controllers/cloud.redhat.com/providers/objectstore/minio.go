@@ -2,8 +2,11 @@ package objectstore
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"strconv"
+	"strings"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
@@ -19,6 +22,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
@@ -126,31 +130,36 @@ func (m *minioProvider) Provide(app *crd.ClowdApp) error {
 		Buckets:   []config.ObjectStoreBucket{},
 	}
 
-	for _, bucket := range app.Spec.ObjectStore {
-		found, err := m.BucketHandler.Exists(m.Ctx, bucket)
-
-		if err != nil {
-			return newBucketError(bucketCheckErrorMsg, bucket, err)
-		}
+	bucketCreds, err := m.getBucketCredentials(app)
+	if err != nil {
+		return err
+	}
 
-		if !found {
-			err = m.BucketHandler.Make(m.Ctx, bucket)
+	for _, bucket := range app.Spec.ObjectStore {
+		bucketName := getMinioBucketName(m.Env, app, bucket)
 
-			if err != nil {
-				return newBucketError(bucketCreateErrorMsg, bucket, err)
+		// Planning an app must not reach MinIO, the bucket is made when the app is reconciled
+		if !m.DryRun {
+			if err := m.ensureBucket(bucketName); err != nil {
+				return err
 			}
 		}
 
 		newBucket := config.ObjectStoreBucket{
-			Name:          bucket,
+			Name:          bucketName,
 			RequestedName: bucket,
 		}
 
-		if string(secret.Data["accessKey"]) != "" {
-			newBucket.AccessKey = m.Config.ObjectStore.AccessKey
-		}
-		if string(secret.Data["secretKey"]) != "" {
-			newBucket.SecretKey = m.Config.ObjectStore.SecretKey
+		if creds, ok := bucketCreds[bucket]; ok {
+			newBucket.AccessKey = creds.AccessKey
+			newBucket.SecretKey = creds.SecretKey
+		} else {
+			if string(secret.Data["accessKey"]) != "" {
+				newBucket.AccessKey = m.Config.ObjectStore.AccessKey
+			}
+			if string(secret.Data["secretKey"]) != "" {
+				newBucket.SecretKey = m.Config.ObjectStore.SecretKey
+			}
 		}
 
 		m.Config.ObjectStore.Buckets = append(m.Config.ObjectStore.Buckets, newBucket)
@@ -159,6 +168,65 @@ func (m *minioProvider) Provide(app *crd.ClowdApp) error {
 	return nil
 }
 
+// ensureBucket makes the bucket in MinIO when it doesn't exist yet.
+func (m *minioProvider) ensureBucket(bucketName string) error {
+	found, err := m.BucketHandler.Exists(m.Ctx, bucketName)
+
+	if err != nil {
+		return newBucketError(bucketCheckErrorMsg, bucketName, err)
+	}
+
+	if !found {
+		err = m.BucketHandler.Make(m.Ctx, bucketName)
+
+		if err != nil {
+			return newBucketError(bucketCreateErrorMsg, bucketName, err)
+		}
+	}
+
+	return nil
+}
+
+// maxBucketNameLength is the longest bucket name S3, and so MinIO, accepts.
+const maxBucketNameLength = 63
+
+// getMinioBucketName returns the name the bucket requested by the app is created with. When the
+// environment prefixes buckets, the namespace of the app is prepended and names that get too long
+// are truncated, keeping them unique with a hash of the full name.
+func getMinioBucketName(env *crd.ClowdEnvironment, app *crd.ClowdApp, bucket string) string {
+	if !env.Spec.Providers.ObjectStore.PrefixBuckets {
+		return bucket
+	}
+
+	name := fmt.Sprintf("%s-%s", app.Namespace, bucket)
+	if len(name) <= maxBucketNameLength {
+		return name
+	}
+
+	h := sha256.Sum256([]byte(name))
+	hash := hex.EncodeToString(h[:])[:8]
+	prefix := strings.TrimRight(name[:maxBucketNameLength-len(hash)-1], "-.")
+	return fmt.Sprintf("%s-%s", prefix, hash)
+}
+
+// getBucketCredentials returns the per-bucket credentials defined by secrets in the app's
+// namespace, using the same secret layout as the app-interface mode. Buckets without their own
+// credentials use the MinIO credentials.
+func (m *minioProvider) getBucketCredentials(app *crd.ClowdApp) (map[string]config.ObjectStoreBucket, error) {
+	secrets := core.SecretList{}
+	if err := m.Client.List(m.Ctx, &secrets, client.InNamespace(app.Namespace)); err != nil {
+		return nil, errors.Wrap(fmt.Sprintf("Failed to list secrets in %s", app.Namespace), err)
+	}
+
+	buckets, _ := extractBuckets(secrets.Items)
+
+	bucketCreds := map[string]config.ObjectStoreBucket{}
+	for _, bucket := range buckets {
+		bucketCreds[bucket.Name] = bucket
+	}
+	return bucketCreds, nil
+}
+
 const bucketCheckErrorMsg = "failed to check if bucket exists"
 const bucketCreateErrorMsg = "failed to create bucket"
 
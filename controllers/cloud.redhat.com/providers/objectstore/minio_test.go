@@ -157,6 +157,28 @@ func (fc *FakeClient) List(_ context.Context, _ client.ObjectList, _ ...client.L
 	return nil
 }
 
+// FakeSecretClient serves the MinIO credentials and a set of secrets to list from the app namespace.
+type FakeSecretClient struct {
+	FakeClient
+	Secrets []core.Secret
+}
+
+func (fc *FakeSecretClient) Get(_ context.Context, _ client.ObjectKey, obj client.Object, _ ...client.GetOption) error {
+	p, _ := obj.(*core.Secret)
+	p.Data = map[string][]byte{
+		"port":      []byte("2345"),
+		"accessKey": []byte("minio-access"),
+		"secretKey": []byte("minio-secret"),
+	}
+	return nil
+}
+
+func (fc *FakeSecretClient) List(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+	p, _ := list.(*core.SecretList)
+	p.Items = fc.Secrets
+	return nil
+}
+
 func (fc *FakeClient) Scheme() *runtime.Scheme {
 	return nil
 }
@@ -409,4 +431,84 @@ func TestMinio(t *testing.T) {
 		wantBucketConfig := config.ObjectStoreBucket{Name: b1, RequestedName: b1}
 		assert.Contains(mp.Config.ObjectStore.Buckets, wantBucketConfig)
 	})
+	t.Run("createBucketsPerBucketCredentials", func(t *testing.T) {
+		b1, b2, b3 := "testBucket1", "testBucket2", "testBucket3"
+
+		mockBuckets := []mockBucket{
+			{Name: b1, Exists: false},
+			{Name: b2, Exists: true},
+			{Name: b3, Exists: false},
+		}
+
+		_, app, mp := setupBucketTest(t, mockBuckets)
+		mp.Client = &FakeSecretClient{
+			Secrets: []core.Secret{{
+				ObjectMeta: v1.ObjectMeta{Name: "bucket-one"},
+				Data: map[string][]byte{
+					"aws_access_key_id":     []byte("access-one"),
+					"aws_secret_access_key": []byte("secret-one"),
+					"bucket":                []byte(b1),
+				},
+			}, {
+				ObjectMeta: v1.ObjectMeta{
+					Name:        "bucket-two",
+					Annotations: map[string]string{"clowder/bucket-names": b2},
+				},
+				Data: map[string][]byte{
+					"aws_access_key_id":     []byte("access-two"),
+					"aws_secret_access_key": []byte("secret-two"),
+				},
+			}},
+		}
+
+		gotErr := mp.Provide(app)
+		assert.NoError(gotErr)
+		assert.Len(mp.Config.ObjectStore.Buckets, 3)
+
+		wantCreds := map[string][2]string{
+			b1: {"access-one", "secret-one"},
+			b2: {"access-two", "secret-two"},
+			b3: {"minio-access", "minio-secret"},
+		}
+		for _, bucket := range mp.Config.ObjectStore.Buckets {
+			assert.Equal(wantCreds[bucket.Name][0], *bucket.AccessKey, bucket.Name)
+			assert.Equal(wantCreds[bucket.Name][1], *bucket.SecretKey, bucket.Name)
+		}
+	})
+
+	t.Run("createPrefixedBuckets", func(t *testing.T) {
+		mockBuckets := []mockBucket{
+			{Name: "test-ns-reports", Exists: true},
+			{Name: "test-ns-uploads", Exists: false},
+		}
+
+		handler, _, mp := setupBucketTest(t, mockBuckets)
+		mp.Env.Spec.Providers.ObjectStore.PrefixBuckets = true
+		app := &crd.ClowdApp{
+			ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "test-ns"},
+			Spec:       crd.ClowdAppSpec{ObjectStore: []string{"reports", "uploads"}},
+		}
+
+		gotErr := mp.Provide(app)
+		assert.NoError(gotErr)
+		assert.Equal([]string{"test-ns-reports", "test-ns-uploads"}, handler.ExistsCalls)
+		assert.Equal([]string{"test-ns-uploads"}, handler.MakeCalls)
+		assert.Contains(mp.Config.ObjectStore.Buckets, config.ObjectStoreBucket{Name: "test-ns-reports", RequestedName: "reports"})
+		assert.Contains(mp.Config.ObjectStore.Buckets, config.ObjectStoreBucket{Name: "test-ns-uploads", RequestedName: "uploads"})
+	})
+}
+
+func TestMinioBucketName(t *testing.T) {
+	env := &crd.ClowdEnvironment{}
+	app := &crd.ClowdApp{ObjectMeta: v1.ObjectMeta{Name: "app", Namespace: "ephemeral-abc123"}}
+
+	assert.Equal(t, "reports", getMinioBucketName(env, app, "reports"))
+
+	env.Spec.Providers.ObjectStore.PrefixBuckets = true
+	assert.Equal(t, "ephemeral-abc123-reports", getMinioBucketName(env, app, "reports"))
+
+	long := "a-very-long-bucket-name-that-only-just-fits-on-its-own-x"
+	name := getMinioBucketName(env, app, long)
+	assert.Len(t, name, maxBucketNameLength)
+	assert.NotEqual(t, name, getMinioBucketName(env, app, long+"y"), "truncated names collided")
 }
@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/envcache"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/hashcache"
 	obj "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/object"
@@ -29,6 +31,7 @@ var RootSecret = rc.NewSingleResourceIdent("root", "root_env", &core.Secret{})
 
 type providerAccessor struct {
 	FinalizeProvider func(c *Provider) error
+	FinalizeApp      func(c *Provider, app *crd.ClowdApp) error
 	SetupProvider    func(c *Provider) (ClowderProvider, error)
 	Order            int
 	Name             string
@@ -68,6 +71,18 @@ func (p *providersRegistration) Register(
 	sort.Sort(p)
 }
 
+// RegisterAppFinalizer attaches a function to an already registered provider which is run when a
+// ClowdApp is deleted. It is intended for resources that cannot be garbage collected via owner
+// references, such as those living in a different namespace to the ClowdApp.
+func (p *providersRegistration) RegisterAppFinalizer(name string, finalizeApp func(c *Provider, app *crd.ClowdApp) error) {
+	for i := range p.Registry {
+		if p.Registry[i].Name == name {
+			p.Registry[i].FinalizeApp = finalizeApp
+			return
+		}
+	}
+}
+
 // ProvidersRegistration is an instance of the provider registration system. It is responsible for
 // adding new providers to the registry so that they can be executed in the correct order.
 var ProvidersRegistration providersRegistration
@@ -84,6 +99,59 @@ type Provider struct {
 	Log       logr.Logger
 	Config    *config.AppConfig
 	HashCache *hashcache.HashCache
+	// EnvCache, when set, holds the objects shared by the apps of the environment that were read
+	// earlier in the current reconcile cycle, see GetShared.
+	EnvCache *envcache.Cache
+	// DryRun is set when the providers are run to plan the objects of an app rather than to
+	// reconcile it. The client then only records writes, providers must skip any writes they make
+	// to services other than Kubernetes.
+	DryRun bool
+	// SecretRefs, when set, records the secrets read with GetShared, so that a change to one of
+	// them reconciles the object the providers were run for.
+	SecretRefs *SecretRefs
+}
+
+// SecretRefs is the set of secrets, not owned by Clowder, that the providers read while
+// reconciling an object. It is shared by the copies of the Provider handed to each provider.
+type SecretRefs struct {
+	mu   sync.Mutex
+	refs map[types.NamespacedName]bool
+}
+
+// NewSecretRefs returns an empty set of secret references.
+func NewSecretRefs() *SecretRefs {
+	return &SecretRefs{refs: map[types.NamespacedName]bool{}}
+}
+
+// Add records a reference to the secret.
+func (s *SecretRefs) Add(nn types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refs[nn] = true
+}
+
+// List returns the referenced secrets.
+func (s *SecretRefs) List() []types.NamespacedName {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refs := make([]types.NamespacedName, 0, len(s.refs))
+	for nn := range s.refs {
+		refs = append(refs, nn)
+	}
+	return refs
+}
+
+// GetShared reads an object that is the same for all the apps of the environment, reusing the copy
+// read earlier in the current reconcile cycle if the provider has an EnvCache. Secrets are recorded
+// in the SecretRefs of the provider, whether they exist or not.
+func (prov *Provider) GetShared(nn types.NamespacedName, obj client.Object) error {
+	if _, ok := obj.(*core.Secret); ok && prov.SecretRefs != nil {
+		prov.SecretRefs.Add(nn)
+	}
+	if prov.EnvCache == nil {
+		return prov.Client.Get(prov.Ctx, nn, obj)
+	}
+	return prov.EnvCache.Get(prov.Ctx, prov.Client, prov.Env, nn, obj)
 }
 
 func (prov *Provider) GetClient() client.Client {
@@ -70,17 +70,20 @@ func (ps *pullsecretProvider) Provide(app *crd.ClowdApp) error {
 		}
 	}
 
-	saList := &core.ServiceAccountList{}
-	if err := ps.Cache.List(serviceaccount.CoreDeploymentServiceAccount, saList); err != nil {
-		return err
-	}
+	// Service accounts named by the deployments get the secrets only if Clowder created them
+	for _, ident := range []rc.ResourceIdentMulti{serviceaccount.CoreDeploymentServiceAccount, serviceaccount.CoreNamedServiceAccount} {
+		saList := &core.ServiceAccountList{}
+		if err := ps.Cache.List(ident, saList); err != nil {
+			return err
+		}
 
-	for _, sa := range saList.Items {
-		innerSA := sa
-		addAllSecrets(secList, &innerSA)
+		for _, sa := range saList.Items {
+			innerSA := sa
+			addAllSecrets(secList, &innerSA)
 
-		if err := ps.Cache.Update(serviceaccount.CoreDeploymentServiceAccount, &innerSA); err != nil {
-			return err
+			if err := ps.Cache.Update(ident, &innerSA); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -101,7 +104,7 @@ func copyPullSecrets(prov *providers.Provider, namespace string, obj object.Clow
 	for _, pullSecretName := range prov.Env.Spec.Providers.PullSecrets {
 
 		sourcePullSecObj := &core.Secret{}
-		if err := prov.Client.Get(prov.Ctx, types.NamespacedName{
+		if err := prov.GetShared(types.NamespacedName{
 			Name:      pullSecretName.Name,
 			Namespace: pullSecretName.Namespace,
 		}, sourcePullSecObj); err != nil {
@@ -0,0 +1,85 @@
+package readinessgate
+
+import (
+	"fmt"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
+	core "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type readinessGateProvider struct {
+	providers.Provider
+}
+
+func NewReadinessGateProvider(p *providers.Provider) (providers.ClowderProvider, error) {
+	return &readinessGateProvider{Provider: *p}, nil
+}
+
+func (rg *readinessGateProvider) EnvProvide() error {
+	return nil
+}
+
+func (rg *readinessGateProvider) Provide(app *crd.ClowdApp) error {
+	for _, deployment := range app.Spec.Deployments {
+		innerDeployment := deployment
+		if len(innerDeployment.ReadinessGates) == 0 {
+			continue
+		}
+		if err := rg.gateDeployment(app, &innerDeployment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rg *readinessGateProvider) gateDeployment(app *crd.ClowdApp, deployment *crd.Deployment) error {
+	for _, gate := range deployment.ReadinessGates {
+		if (gate.TCP == "") == (gate.HTTP == "") {
+			return errors.NewClowderError(
+				fmt.Sprintf("readiness gate [%s] of deployment [%s] must set exactly one of tcp or http", gate.Name, deployment.Name),
+			)
+		}
+	}
+
+	nn := app.GetDeploymentNamespacedName(deployment)
+
+	err := deployProvider.EditPodTemplate(rg.Cache, app, deployment, func(t *core.PodTemplateSpec) error {
+		addReadinessGates(t, deployment.ReadinessGates)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The pods of the current rollout are given the result of the checks, pods started later are
+	// picked up when the app is next reconciled
+	podList := core.PodList{}
+	if err := rg.Client.List(rg.Ctx, &podList, client.InNamespace(nn.Namespace), client.MatchingLabels{"pod": nn.Name}); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, gate := range deployment.ReadinessGates {
+		checkErr := checkDependency(rg.Ctx, gate)
+		if checkErr != nil {
+			rg.Log.Info("Dependency of readiness gate is unhealthy", "deployment", deployment.Name, "gate", gate.Name, "err", checkErr)
+		}
+
+		for i := range podList.Items {
+			pod := &podList.Items[i]
+			if !setGateCondition(pod, gate, checkErr, now) {
+				continue
+			}
+			if err := rg.Client.Status().Update(rg.Ctx, pod); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
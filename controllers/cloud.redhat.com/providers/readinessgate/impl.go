@@ -0,0 +1,94 @@
+package readinessgate
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkTimeout bounds each dependency check so that an unreachable dependency can't hold up the
+// reconciliation of the app.
+const checkTimeout = 5 * time.Second
+
+// GetConditionType returns the pod condition that a readiness gate waits on.
+func GetConditionType(gate crd.DependencyReadinessGate) core.PodConditionType {
+	return core.PodConditionType(fmt.Sprintf("cloud.redhat.com/dependency-%s", gate.Name))
+}
+
+func addReadinessGates(t *core.PodTemplateSpec, gates []crd.DependencyReadinessGate) {
+	for _, gate := range gates {
+		t.Spec.ReadinessGates = append(t.Spec.ReadinessGates, core.PodReadinessGate{
+			ConditionType: GetConditionType(gate),
+		})
+	}
+}
+
+// checkDependency returns an error if the dependency checked by the gate is unhealthy.
+func checkDependency(ctx context.Context, gate crd.DependencyReadinessGate) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	if gate.TCP != "" {
+		dialer := net.Dialer{}
+		conn, err := dialer.DialContext(ctx, "tcp", gate.TCP)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gate.HTTP, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s responded with status %d", gate.HTTP, resp.StatusCode)
+	}
+	return nil
+}
+
+// setGateCondition records the result of a dependency check on the pod's gate condition, it
+// returns whether the pod status changed.
+func setGateCondition(pod *core.Pod, gate crd.DependencyReadinessGate, checkErr error, now time.Time) bool {
+	condition := core.PodCondition{
+		Type:   GetConditionType(gate),
+		Status: core.ConditionTrue,
+		Reason: "DependencyHealthy",
+	}
+	if checkErr != nil {
+		condition.Status = core.ConditionFalse
+		condition.Reason = "DependencyUnhealthy"
+		condition.Message = checkErr.Error()
+	}
+
+	for i, existing := range pod.Status.Conditions {
+		if existing.Type != condition.Type {
+			continue
+		}
+		if existing.Status == condition.Status && existing.Message == condition.Message {
+			return false
+		}
+		condition.LastTransitionTime = existing.LastTransitionTime
+		if existing.Status != condition.Status {
+			condition.LastTransitionTime = metav1.NewTime(now)
+		}
+		pod.Status.Conditions[i] = condition
+		return true
+	}
+
+	condition.LastTransitionTime = metav1.NewTime(now)
+	pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	return true
+}
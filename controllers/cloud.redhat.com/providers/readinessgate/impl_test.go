@@ -0,0 +1,96 @@
+package readinessgate
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+)
+
+// podReady mirrors how the kubelet sets a pod's Ready condition: the containers must be ready and
+// every readiness gate condition must be True. Only Ready pods are added to the service endpoints.
+func podReady(pod *core.Pod, containersReady bool) bool {
+	if !containersReady {
+		return false
+	}
+	for _, gate := range pod.Spec.ReadinessGates {
+		found := false
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == gate.ConditionType {
+				found = condition.Status == core.ConditionTrue
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func gatedPod(gates []crd.DependencyReadinessGate) *core.Pod {
+	t := &core.PodTemplateSpec{}
+	addReadinessGates(t, gates)
+	return &core.Pod{Spec: t.Spec}
+}
+
+func TestReadinessGateTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	gate := crd.DependencyReadinessGate{Name: "db", TCP: listener.Addr().String()}
+	pod := gatedPod([]crd.DependencyReadinessGate{gate})
+
+	assert.Equal(t, []core.PodReadinessGate{{ConditionType: "cloud.redhat.com/dependency-db"}}, pod.Spec.ReadinessGates)
+
+	// until the dependency has been checked the pod is held back
+	assert.False(t, podReady(pod, true))
+
+	now := time.Now()
+	assert.True(t, setGateCondition(pod, gate, checkDependency(context.Background(), gate), now))
+	assert.True(t, podReady(pod, true))
+
+	// checking again without a change leaves the pod status alone
+	assert.False(t, setGateCondition(pod, gate, checkDependency(context.Background(), gate), now.Add(time.Minute)))
+
+	assert.NoError(t, listener.Close())
+
+	assert.True(t, setGateCondition(pod, gate, checkDependency(context.Background(), gate), now.Add(2*time.Minute)))
+	assert.False(t, podReady(pod, true))
+	assert.Equal(t, core.ConditionFalse, pod.Status.Conditions[0].Status)
+	assert.Equal(t, "DependencyUnhealthy", pod.Status.Conditions[0].Reason)
+	assert.Equal(t, now.Add(2*time.Minute).Unix(), pod.Status.Conditions[0].LastTransitionTime.Unix())
+}
+
+func TestReadinessGateHTTP(t *testing.T) {
+	unhealthy := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&unhealthy) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	gates := []crd.DependencyReadinessGate{
+		{Name: "api", HTTP: server.URL + "/healthz"},
+		{Name: "other", HTTP: server.URL + "/ready"},
+	}
+	pod := gatedPod(gates)
+
+	for _, gate := range gates {
+		setGateCondition(pod, gate, checkDependency(context.Background(), gate), time.Now())
+	}
+	assert.True(t, podReady(pod, true))
+
+	// a single unhealthy dependency is enough to take the pod out of the endpoints
+	atomic.StoreInt32(&unhealthy, 1)
+	setGateCondition(pod, gates[0], checkDependency(context.Background(), gates[0]), time.Now())
+	assert.False(t, podReady(pod, true))
+	assert.Contains(t, pod.Status.Conditions[0].Message, "responded with status 503")
+}
@@ -0,0 +1,17 @@
+package readinessgate
+
+import (
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+)
+
+// ProvName sets the provider name identifier
+var ProvName = "readinessgate"
+
+// GetReadinessGate returns the readiness gate provider.
+func GetReadinessGate(c *providers.Provider) (providers.ClowderProvider, error) {
+	return NewReadinessGateProvider(c)
+}
+
+func init() {
+	providers.ProvidersRegistration.Register(GetReadinessGate, 11, ProvName)
+}
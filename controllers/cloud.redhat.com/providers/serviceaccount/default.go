@@ -5,15 +5,19 @@ import (
 	"strings"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/database"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/featureflags"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/inmemorydb"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/objectstore"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	rbac "k8s.io/api/rbac/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
 	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
@@ -32,6 +36,13 @@ var CoreAppServiceAccount = rc.NewSingleResourceIdent(ProvName, "core_app_servic
 // CoreEnvServiceAccount is the serviceaccount for the env.
 var CoreEnvServiceAccount = rc.NewSingleResourceIdent(ProvName, "core_env_service_account", &core.ServiceAccount{})
 
+// CoreNamedServiceAccount is a serviceaccount named by the pod spec of a deployment that Clowder
+// creates.
+var CoreNamedServiceAccount = rc.NewMultiResourceIdent(ProvName, "core_named_service_account", &core.ServiceAccount{})
+
+// CoreNamedRoleBinding is the rolebinding for a serviceaccount named by a deployment.
+var CoreNamedRoleBinding = rc.NewMultiResourceIdent(ProvName, "core_named_role_binding", &rbac.RoleBinding{})
+
 // IQEServiceAccount is the serviceaccount for the iqe testing.
 var IQEServiceAccount = rc.NewMultiResourceIdent(ProvName, "iqe_service_account", &core.ServiceAccount{})
 
@@ -48,6 +59,8 @@ func NewServiceAccountProvider(p *providers.Provider) (providers.ClowderProvider
 		CoreDeploymentServiceAccount,
 		CoreAppServiceAccount,
 		CoreEnvServiceAccount,
+		CoreNamedServiceAccount,
+		CoreNamedRoleBinding,
 		IQEServiceAccount,
 		IQERoleBinding,
 	)
@@ -113,38 +126,102 @@ func (sa *serviceaccountProvider) Provide(app *crd.ClowdApp) error {
 		}
 	}
 
+	provided := map[string]bool{}
+
 	for _, dep := range app.Spec.Deployments {
-		d := &apps.Deployment{}
 		innerDeployment := dep
 		nn := app.GetDeploymentNamespacedName(&innerDeployment)
 
-		if err := sa.Cache.Get(deployment.CoreDeployment, d, nn); err != nil {
-			return err
-		}
+		saName := nn.Name
 
-		labeler := utils.GetCustomLabeler(nil, nn, app)
+		if innerDeployment.PodSpec.ServiceAccountName != "" {
+			saName = innerDeployment.PodSpec.ServiceAccountName
+			if err := sa.provideNamedServiceAccount(app, &innerDeployment.PodSpec, provided); err != nil {
+				return err
+			}
+		} else {
+			labeler := utils.GetCustomLabeler(nil, nn, app)
 
-		if err := CreateServiceAccount(sa.Cache, CoreDeploymentServiceAccount, nn, labeler); err != nil {
-			return err
+			if err := CreateServiceAccount(sa.Cache, CoreDeploymentServiceAccount, nn, labeler); err != nil {
+				return err
+			}
+
+			if err := CreateRoleBinding(sa.Cache, CoreDeploymentRoleBinding, nn, labeler, innerDeployment.K8sAccessLevel); err != nil {
+				return err
+			}
 		}
 
-		d.Spec.Template.Spec.ServiceAccountName = nn.Name
-		if err := sa.Cache.Update(deployment.CoreDeployment, d); err != nil {
+		err := deployment.EditPodTemplate(sa.Cache, app, &innerDeployment, func(t *core.PodTemplateSpec) error {
+			t.Spec.ServiceAccountName = saName
+			return nil
+		})
+		if err != nil {
 			return err
 		}
+	}
 
-		if err := CreateRoleBinding(sa.Cache, CoreDeploymentRoleBinding, nn, labeler, innerDeployment.K8sAccessLevel); err != nil {
-			return err
+	return nil
+}
+
+// provideNamedServiceAccount creates the service account named by the pod spec, and its role
+// binding, when createServiceAccount is set. A service account of that name that exists and isn't
+// owned by the app is left untouched. Several deployments may share a service account, provided
+// holds the names that were already handled.
+func (sa *serviceaccountProvider) provideNamedServiceAccount(app *crd.ClowdApp, podSpec *crd.PodSpec, provided map[string]bool) error {
+	nn := types.NamespacedName{
+		Name:      podSpec.ServiceAccountName,
+		Namespace: app.Namespace,
+	}
+
+	if provided[nn.Name] {
+		return nil
+	}
+	provided[nn.Name] = true
+
+	existing := &core.ServiceAccount{}
+	err := sa.Client.Get(sa.Ctx, nn, existing)
+	if err != nil && !k8serr.IsNotFound(err) {
+		return errors.Wrap(fmt.Sprintf("get service account [%s]", nn.Name), err)
+	}
+	found := err == nil
+
+	if found && !isOwnedBy(existing, app) {
+		return nil
+	}
+
+	if !podSpec.CreateServiceAccount {
+		if found {
+			// Clowder created the service account before createServiceAccount was unset, it is
+			// removed with the other objects that are no longer provided.
+			return nil
 		}
+		cerr := errors.NewClowderError(fmt.Sprintf("service account [%s] not found in namespace [%s]", nn.Name, nn.Namespace))
+		cerr.Requeue = true
+		return cerr
+	}
+
+	labeler := utils.GetCustomLabeler(nil, nn, app)
 
+	if err := CreateServiceAccount(sa.Cache, CoreNamedServiceAccount, nn, labeler); err != nil {
+		return err
 	}
 
-	return nil
+	return CreateRoleBinding(sa.Cache, CoreNamedRoleBinding, nn, labeler, podSpec.ServiceAccountAccessLevel)
+}
+
+// isOwnedBy returns true if the app is one of the owners of the object.
+func isOwnedBy(obj v1.Object, app *crd.ClowdApp) bool {
+	for _, owner := range obj.GetOwnerReferences() {
+		if owner.UID == app.UID {
+			return true
+		}
+	}
+	return false
 }
 
 func createIQEServiceAccounts(p *providers.Provider, app *crd.ClowdApp) error {
 
-	accessLevel := p.Env.Spec.Providers.Testing.K8SAccessLevel
+	accessLevel := provutils.GetTestingK8SAccessLevel(p.Env)
 
 	nn := types.NamespacedName{
 		Name:      fmt.Sprintf("iqe-%s", p.Env.Name),
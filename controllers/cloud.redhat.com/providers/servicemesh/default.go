@@ -6,7 +6,7 @@ import (
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
-	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
 
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 )
@@ -29,23 +29,16 @@ func (ch *servicemeshProvider) Provide(_ *crd.ClowdApp) error {
 		return nil
 	}
 
-	dList := apps.DeploymentList{}
-	if err := ch.Cache.List(deployProvider.CoreDeployment, &dList); err != nil {
-		return err
-	}
-
-	for _, deployment := range dList.Items {
-		innerDeployment := deployment
+	err := deployProvider.EditPodTemplates(ch.Cache, func(t *core.PodTemplateSpec) error {
 		annotations := map[string]string{
 			"sidecar.istio.io/inject":                       "true",
 			"traffic.sidecar.istio.io/excludeOutboundPorts": "443,9093,5432,10000",
 		}
-		utils.UpdateAnnotations(&innerDeployment.Spec.Template, annotations)
-
-		err := ch.Cache.Update(deployProvider.CoreDeployment, &innerDeployment)
-		if err != nil {
-			return fmt.Errorf("could not update annotations: %w", err)
-		}
+		utils.UpdateAnnotations(t, annotations)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not update annotations: %w", err)
 	}
 
 	return nil
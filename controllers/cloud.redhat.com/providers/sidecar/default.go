@@ -2,16 +2,18 @@ package sidecar
 
 import (
 	"fmt"
+	"path"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	cronjobProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/cronjob"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 
-	apps "k8s.io/api/apps/v1"
 	batch "k8s.io/api/batch/v1"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 )
 
 type sidecarProvider struct {
@@ -29,27 +31,11 @@ func (sc *sidecarProvider) EnvProvide() error {
 func (sc *sidecarProvider) Provide(app *crd.ClowdApp) error {
 	for _, deployment := range app.Spec.Deployments {
 		innerDeployment := deployment
-		d := &apps.Deployment{}
 
-		if err := sc.Cache.Get(deployProvider.CoreDeployment, d, app.GetDeploymentNamespacedName(&innerDeployment)); err != nil {
-			return err
-		}
-
-		for _, sidecar := range innerDeployment.PodSpec.Sidecars {
-			switch sidecar.Name {
-			case "token-refresher":
-				if sidecar.Enabled && sc.Env.Spec.Providers.Sidecars.TokenRefresher.Enabled {
-					cont := getTokenRefresher(app.Name)
-					if cont != nil {
-						d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, *cont)
-					}
-				}
-			default:
-				return fmt.Errorf("%s is not a valid sidecar name", sidecar.Name)
-			}
-		}
-
-		if err := sc.Cache.Update(deployProvider.CoreDeployment, d); err != nil {
+		err := deployProvider.EditPodTemplate(sc.Cache, app, &innerDeployment, func(t *core.PodTemplateSpec) error {
+			return sc.addSidecars(app, &t.Spec, innerDeployment.PodSpec.Sidecars)
+		})
+		if err != nil {
 			return err
 		}
 	}
@@ -65,18 +51,8 @@ func (sc *sidecarProvider) Provide(app *crd.ClowdApp) error {
 			return err
 		}
 
-		for _, sidecar := range innerCronJob.PodSpec.Sidecars {
-			switch sidecar.Name {
-			case "token-refresher":
-				if sidecar.Enabled && sc.Env.Spec.Providers.Sidecars.TokenRefresher.Enabled {
-					cont := getTokenRefresher(app.Name)
-					if cont != nil {
-						cj.Spec.JobTemplate.Spec.Template.Spec.Containers = append(cj.Spec.JobTemplate.Spec.Template.Spec.Containers, *cont)
-					}
-				}
-			default:
-				return fmt.Errorf("%s is not a valid sidecar name", sidecar.Name)
-			}
+		if err := sc.addSidecars(app, &cj.Spec.JobTemplate.Spec.Template.Spec, innerCronJob.PodSpec.Sidecars); err != nil {
+			return err
 		}
 
 		if err := sc.Cache.Update(cronjobProvider.CoreCronJob, cj); err != nil {
@@ -87,6 +63,88 @@ func (sc *sidecarProvider) Provide(app *crd.ClowdApp) error {
 	return nil
 }
 
+// addSidecars appends the containers of the sidecars that are enabled both on the pod and in the
+// environment to the pod spec, along with any volumes they need.
+func (sc *sidecarProvider) addSidecars(app *crd.ClowdApp, pod *core.PodSpec, sidecars []crd.Sidecar) error {
+	for _, sidecar := range sidecars {
+		switch sidecar.Name {
+		case "token-refresher":
+			if sidecar.Enabled && sc.Env.Spec.Providers.Sidecars.TokenRefresher.Enabled {
+				cont := getTokenRefresher(app.Name)
+				if cont != nil {
+					pod.Containers = append(pod.Containers, *cont)
+				}
+			}
+		case "otel-collector":
+			if sidecar.Enabled && sc.Env.Spec.Providers.Sidecars.OtelCollector.Enabled {
+				pod.Containers = append(pod.Containers, getOtelCollector(sc.Env.Spec.Providers.Sidecars.OtelCollector.Image))
+				pod.Volumes = append(pod.Volumes, getOtelCollectorVolume(app.Name))
+			}
+		default:
+			return fmt.Errorf("%s is not a valid sidecar name", sidecar.Name)
+		}
+	}
+	return nil
+}
+
+// getOtelCollector returns the OpenTelemetry collector container, it reads its configuration from
+// the otel-config volume and shares the config secret of the app.
+func getOtelCollector(image string) core.Container {
+	if image == "" {
+		image = DefaultImageSideCarOtelCollector
+	}
+
+	return core.Container{
+		Name:  "otel-collector",
+		Image: image,
+		Args: []string{
+			"--config=/etc/otel/config.yaml",
+		},
+		VolumeMounts: []core.VolumeMount{{
+			Name:      "otel-config",
+			MountPath: "/etc/otel/",
+			ReadOnly:  true,
+		}, {
+			Name:      deployProvider.ConfigSecretVolumeName,
+			MountPath: deployProvider.DefaultConfigMountPath,
+			ReadOnly:  true,
+		}},
+		Env: []core.EnvVar{{
+			Name:  deployProvider.ConfigEnvVar,
+			Value: path.Join(deployProvider.DefaultConfigMountPath, deployProvider.DefaultConfigFileName),
+		}},
+		TerminationMessagePath:   "/dev/termination-log",
+		TerminationMessagePolicy: core.TerminationMessageReadFile,
+		ImagePullPolicy:          core.PullIfNotPresent,
+		Resources: core.ResourceRequirements{
+			Limits: core.ResourceList{
+				"cpu":    resource.MustParse("200m"),
+				"memory": resource.MustParse("256Mi"),
+			},
+			Requests: core.ResourceList{
+				"cpu":    resource.MustParse("50m"),
+				"memory": resource.MustParse("128Mi"),
+			},
+		},
+	}
+}
+
+// getOtelCollectorVolume returns the volume holding the collector configuration from the
+// <app>-otel-config ConfigMap.
+func getOtelCollectorVolume(appName string) core.Volume {
+	return core.Volume{
+		Name: "otel-config",
+		VolumeSource: core.VolumeSource{
+			ConfigMap: &core.ConfigMapVolumeSource{
+				LocalObjectReference: core.LocalObjectReference{
+					Name: fmt.Sprintf("%s-otel-config", appName),
+				},
+				DefaultMode: utils.Int32Ptr(420),
+			},
+		},
+	}
+}
+
 func getTokenRefresher(appName string) *core.Container {
 	cont := core.Container{}
 
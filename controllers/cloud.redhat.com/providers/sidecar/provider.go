@@ -6,6 +6,8 @@ import (
 
 var DefaultImageSideCarTokenRefresher = "quay.io/observatorium/token-refresher:master-2022-10-21-a99ce82" // nolint:gosec
 
+var DefaultImageSideCarOtelCollector = "otel/opentelemetry-collector-contrib:0.70.0"
+
 // ProvName sets the provider name identifier
 var ProvName = "sidecar"
 
@@ -3,10 +3,12 @@ package providers
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	obj "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/object"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/sizing"
@@ -15,7 +17,9 @@ import (
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 
@@ -25,6 +29,7 @@ import (
 var DefaultImageCaddySideCar = "quay.io/cloudservices/crc-caddy-plugin:1c4882e"
 var DefaultImageMBOP = "quay.io/cloudservices/mbop:bb071db"
 var DefaultImageMocktitlements = "quay.io/cloudservices/mocktitlements:e24820c"
+var DefaultImagePgBouncer = "quay.io/cloudservices/pgbouncer:1.18.0"
 var DefaultKeyCloakVersion = "15.0.2"
 var DefaultImageKeyCloak = fmt.Sprintf("quay.io/keycloak/keycloak:%s", DefaultKeyCloakVersion)
 
@@ -212,6 +217,65 @@ func GetMockBOPImage(env *crd.ClowdEnvironment) string {
 	return DefaultImageMBOP
 }
 
+// GetConfigValidatorImage returns the config validation image to use in a given environment. There
+// is no default, as the validator must run the same Clowder build as the operator, the deployment
+// template sets it to the operator image in the Clowder config. An empty string is returned when
+// neither the environment nor the Clowder config set an image.
+func GetConfigValidatorImage(env *crd.ClowdEnvironment) string {
+	if env.Spec.Providers.Deployment.ConfigValidation.Image != "" {
+		return env.Spec.Providers.Deployment.ConfigValidation.Image
+	}
+	return clowderconfig.LoadedConfig.Images.ConfigValidator
+}
+
+// GetPgBouncerImage returns the PgBouncer sidecar image to use
+func GetPgBouncerImage() string {
+	if clowderconfig.LoadedConfig.Images.PgBouncer != "" {
+		return clowderconfig.LoadedConfig.Images.PgBouncer
+	}
+	return DefaultImagePgBouncer
+}
+
+// RewriteImage applies the first of the image registry overrides of the environment whose prefix
+// matches the image, an image that matches none of them is returned unchanged.
+func RewriteImage(env *crd.ClowdEnvironment, image string) string {
+	for _, override := range env.Spec.ImageRegistryOverrides {
+		if override.Prefix != "" && strings.HasPrefix(image, override.Prefix) {
+			return override.Replacement + strings.TrimPrefix(image, override.Prefix)
+		}
+	}
+	return image
+}
+
+// RewriteImages applies the image registry overrides of the environment to the containers and
+// init containers of the pod spec.
+func RewriteImages(env *crd.ClowdEnvironment, spec *core.PodSpec) {
+	for i := range spec.InitContainers {
+		spec.InitContainers[i].Image = RewriteImage(env, spec.InitContainers[i].Image)
+	}
+	for i := range spec.Containers {
+		spec.Containers[i].Image = RewriteImage(env, spec.Containers[i].Image)
+	}
+}
+
+// GetTestingK8SAccessLevel returns the k8s access level of testing pods in a given environment,
+// falling back to the cluster wide default when the environment does not set one
+func GetTestingK8SAccessLevel(env *crd.ClowdEnvironment) crd.K8sAccessLevel {
+	if env.Spec.Providers.Testing.K8SAccessLevel != "" {
+		return env.Spec.Providers.Testing.K8SAccessLevel
+	}
+	return crd.K8sAccessLevel(clowderconfig.LoadedConfig.Defaults.Testing.K8SAccessLevel)
+}
+
+// GetTestingConfigAccess returns the config access mode of testing pods in a given environment,
+// falling back to the cluster wide default when the environment does not set one
+func GetTestingConfigAccess(env *crd.ClowdEnvironment) crd.ConfigAccessMode {
+	if env.Spec.Providers.Testing.ConfigAccess != "" {
+		return env.Spec.Providers.Testing.ConfigAccess
+	}
+	return crd.ConfigAccessMode(clowderconfig.LoadedConfig.Defaults.Testing.ConfigAccess)
+}
+
 // GetKeycloakVersion returns the keycloak version to use in a given environment
 func GetKeycloakVersion(env *crd.ClowdEnvironment) string {
 	if env.Spec.Providers.Web.KeycloakVersion != "" {
@@ -244,13 +308,26 @@ var KubeLinterAnnotations = map[string]string{
 
 const RCharSet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
 
-func AddCertVolume(d *v1.PodSpec, dnn string) {
+// TLSCAConfigMapName returns the name of the ConfigMap holding the CA of the serving certs of the
+// TLS sidecars, the OpenShift service CA unless Clowder provisions the certs of the app itself.
+func TLSCAConfigMapName(env *crd.ClowdEnvironment, appName string) string {
+	switch env.Spec.Providers.Web.TLS.Mode {
+	case "self-signed", "cert-manager":
+		return fmt.Sprintf("%s-tls-ca", appName)
+	default:
+		return "openshift-service-ca.crt"
+	}
+}
+
+// AddCertVolume mounts the CA ConfigMap into the container named dnn and all the init containers
+// of the pod.
+func AddCertVolume(d *v1.PodSpec, dnn string, caConfigMap string) {
 	d.Volumes = append(d.Volumes, v1.Volume{
 		Name: "tls-ca",
 		VolumeSource: v1.VolumeSource{
 			ConfigMap: &v1.ConfigMapVolumeSource{
 				LocalObjectReference: v1.LocalObjectReference{
-					Name: "openshift-service-ca.crt",
+					Name: caConfigMap,
 				},
 			},
 		},
@@ -277,3 +354,97 @@ func AddCertVolume(d *v1.PodSpec, dnn string) {
 		d.InitContainers[i].VolumeMounts = vms
 	}
 }
+
+// clampReplicas brings the replica count within the minReplicas and maxReplicas of the
+// environment. A count of 0, used for a manual scale down or by autoscalers scaling to zero, is
+// left alone.
+func clampReplicas(env *crd.ClowdEnvironment, replicas int32) int32 {
+	if replicas == 0 {
+		return 0
+	}
+	if env.Spec.MinReplicas != nil && replicas < *env.Spec.MinReplicas {
+		replicas = *env.Spec.MinReplicas
+	}
+	if env.Spec.MaxReplicas != nil && replicas > *env.Spec.MaxReplicas {
+		replicas = *env.Spec.MaxReplicas
+	}
+	return replicas
+}
+
+// ApplyReplicaGuardrails returns a copy of the deployment with the replica settings of the
+// environment applied. A deployment that doesn't set replicas gets the defaultReplicas of the
+// environment, and its replicas and the minimum and maximum of its autoscaler are kept within the
+// minReplicas and maxReplicas of the environment. In the clamp policy a warning is returned for
+// each value that was out of range, in the reject policy an InvalidReplicas error is returned
+// instead. Values Clowder defaults are clamped silently, singletons always run one replica and are
+// left alone.
+func ApplyReplicaGuardrails(env *crd.ClowdEnvironment, deployment *crd.Deployment) (*crd.Deployment, []string, error) {
+	d := deployment.DeepCopy()
+	if d.Singleton {
+		return d, nil, nil
+	}
+
+	problems := []string{}
+	check := func(field string, value *int32, explicit bool) {
+		allowed := clampReplicas(env, *value)
+		if allowed == *value {
+			return
+		}
+		if explicit {
+			bound := "minimum"
+			if allowed < *value {
+				bound = "maximum"
+			}
+			problems = append(problems, fmt.Sprintf("%s is set to %d, the %s is %d", field, *value, bound, allowed))
+		}
+		*value = allowed
+	}
+
+	if d.Replicas == nil && d.MinReplicas == nil {
+		replicas := int32(1)
+		if env.Spec.DefaultReplicas != nil {
+			replicas = *env.Spec.DefaultReplicas
+		}
+		d.Replicas = &replicas
+		check("replicas", d.Replicas, false)
+	} else {
+		replicas := *d.GetReplicaCount()
+		d.Replicas = &replicas
+		check("replicas", d.Replicas, true)
+	}
+
+	if d.AutoScaler != nil {
+		// The autoscalers are rendered with a minimum of 1 and a maximum of 10 if they are unset
+		explicitMin, explicitMax := d.AutoScaler.MinReplicaCount != nil, d.AutoScaler.MaxReplicaCount != nil
+		if !explicitMin {
+			d.AutoScaler.MinReplicaCount = utils.Int32Ptr(1)
+		}
+		if !explicitMax {
+			d.AutoScaler.MaxReplicaCount = utils.Int32Ptr(10)
+		}
+		check("autoScaler.minReplicaCount", d.AutoScaler.MinReplicaCount, explicitMin)
+		check("autoScaler.maxReplicaCount", d.AutoScaler.MaxReplicaCount, explicitMax)
+	}
+
+	if d.AutoScalerSimple != nil {
+		check("autoScalerSimple.replicas.min", &d.AutoScalerSimple.Replicas.Min, true)
+		check("autoScalerSimple.replicas.max", &d.AutoScalerSimple.Replicas.Max, true)
+	}
+
+	if len(problems) > 0 && env.Spec.ReplicaPolicy == "reject" {
+		return nil, nil, &errors.InvalidReplicas{Deployment: d.Name, Problems: problems}
+	}
+	return d, problems, nil
+}
+
+// KindInstalled reports whether the CRD of the kind is installed in the cluster. The kinds of
+// optional operators, such as keda or cert-manager, can only be listed where they are installed.
+func KindInstalled(mapper meta.RESTMapper, gvk schema.GroupVersionKind) (bool, error) {
+	if _, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version); err != nil {
+		if meta.IsNoMatchError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,151 @@
+package providers
+
+import (
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func TestTestingConfigDefaults(t *testing.T) {
+	oldDefaults := clowderconfig.LoadedConfig.Defaults
+	defer func() { clowderconfig.LoadedConfig.Defaults = oldDefaults }()
+
+	clowderconfig.LoadedConfig.Defaults.Testing.K8SAccessLevel = "view"
+	clowderconfig.LoadedConfig.Defaults.Testing.ConfigAccess = "environment"
+
+	// an environment without testing config inherits the cluster default...
+	env := &crd.ClowdEnvironment{}
+	assert.Equal(t, crd.K8sAccessLevel("view"), GetTestingK8SAccessLevel(env))
+	assert.Equal(t, crd.ConfigAccessMode("environment"), GetTestingConfigAccess(env))
+
+	// ...while explicit environment values win
+	env.Spec.Providers.Testing = crd.TestingConfig{
+		K8SAccessLevel: "edit",
+		ConfigAccess:   "app",
+	}
+	assert.Equal(t, crd.K8sAccessLevel("edit"), GetTestingK8SAccessLevel(env))
+	assert.Equal(t, crd.ConfigAccessMode("app"), GetTestingConfigAccess(env))
+}
+
+func TestRewriteImage(t *testing.T) {
+	env := &crd.ClowdEnvironment{
+		Spec: crd.ClowdEnvironmentSpec{
+			ImageRegistryOverrides: []crd.ImageRegistryOverride{
+				{Prefix: "quay.io/cloudservices/iqe-", Replacement: "registry.internal/iqe/"},
+				{Prefix: "quay.io/cloudservices/", Replacement: "registry.internal/cloudservices/"},
+			},
+		},
+	}
+
+	assert.Equal(t, "registry.internal/cloudservices/puptoo:abc123", RewriteImage(env, "quay.io/cloudservices/puptoo:abc123"))
+	// the first matching rule wins
+	assert.Equal(t, "registry.internal/iqe/tests:latest", RewriteImage(env, "quay.io/cloudservices/iqe-tests:latest"))
+	// images that match no rule are left alone
+	assert.Equal(t, "quay.io/observatorium/token-refresher:master", RewriteImage(env, "quay.io/observatorium/token-refresher:master"))
+	assert.Equal(t, "docker.io/quay.io/cloudservices/puptoo", RewriteImage(env, "docker.io/quay.io/cloudservices/puptoo"))
+
+	spec := &core.PodSpec{
+		InitContainers: []core.Container{{Name: "init", Image: "quay.io/cloudservices/puptoo:abc123"}},
+		Containers: []core.Container{
+			{Name: "puptoo", Image: "quay.io/cloudservices/puptoo:abc123"},
+			{Name: "otel-collector", Image: "otel/opentelemetry-collector-contrib:0.70.0"},
+		},
+	}
+	RewriteImages(env, spec)
+	assert.Equal(t, "registry.internal/cloudservices/puptoo:abc123", spec.InitContainers[0].Image)
+	assert.Equal(t, "registry.internal/cloudservices/puptoo:abc123", spec.Containers[0].Image)
+	assert.Equal(t, "otel/opentelemetry-collector-contrib:0.70.0", spec.Containers[1].Image)
+
+	// without overrides every image is kept
+	assert.Equal(t, "quay.io/cloudservices/puptoo:abc123", RewriteImage(&crd.ClowdEnvironment{}, "quay.io/cloudservices/puptoo:abc123"))
+}
+
+func replicaEnv(policy crd.ReplicaPolicy) *crd.ClowdEnvironment {
+	return &crd.ClowdEnvironment{
+		Spec: crd.ClowdEnvironmentSpec{
+			DefaultReplicas: utils.Int32Ptr(2),
+			MinReplicas:     utils.Int32Ptr(1),
+			MaxReplicas:     utils.Int32Ptr(20),
+			ReplicaPolicy:   policy,
+		},
+	}
+}
+
+func TestReplicaGuardrailsDefault(t *testing.T) {
+	// a deployment that omits replicas gets the default of the environment
+	deployment := &crd.Deployment{Name: "api"}
+	guarded, warnings, err := ApplyReplicaGuardrails(replicaEnv(""), deployment)
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	assert.Equal(t, int32(2), *guarded.GetReplicaCount())
+	assert.Nil(t, deployment.Replicas, "the deployment of the app must not be changed")
+
+	// without a default it runs a single replica
+	guarded, _, err = ApplyReplicaGuardrails(&crd.ClowdEnvironment{}, deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *guarded.GetReplicaCount())
+
+	// the deprecated minReplicas is not overridden by the default
+	deployment.MinReplicas = utils.Int32Ptr(4)
+	guarded, _, err = ApplyReplicaGuardrails(replicaEnv(""), deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), *guarded.GetReplicaCount())
+}
+
+func TestReplicaGuardrailsClamp(t *testing.T) {
+	deployment := &crd.Deployment{Name: "api", Replicas: utils.Int32Ptr(3200)}
+	guarded, warnings, err := ApplyReplicaGuardrails(replicaEnv(""), deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(20), *guarded.GetReplicaCount())
+	assert.Equal(t, []string{"replicas is set to 3200, the maximum is 20"}, warnings)
+	assert.Equal(t, int32(3200), *deployment.Replicas)
+
+	// the autoscalers are clamped too, including the maximum Clowder defaults
+	deployment = &crd.Deployment{
+		Name: "api",
+		AutoScaler: &crd.AutoScaler{
+			MinReplicaCount: utils.Int32Ptr(50),
+		},
+	}
+	env := replicaEnv("clamp")
+	env.Spec.MaxReplicas = utils.Int32Ptr(5)
+	guarded, warnings, err = ApplyReplicaGuardrails(env, deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(5), *guarded.AutoScaler.MinReplicaCount)
+	assert.Equal(t, int32(5), *guarded.AutoScaler.MaxReplicaCount)
+	assert.Equal(t, []string{"autoScaler.minReplicaCount is set to 50, the maximum is 5"}, warnings)
+
+	deployment = &crd.Deployment{
+		Name:             "api",
+		AutoScalerSimple: &crd.AutoScalerSimple{Replicas: crd.SimpleAutoScalerReplicas{Min: 2, Max: 40}},
+	}
+	guarded, warnings, err = ApplyReplicaGuardrails(replicaEnv(""), deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), guarded.AutoScalerSimple.Replicas.Min)
+	assert.Equal(t, int32(20), guarded.AutoScalerSimple.Replicas.Max)
+	assert.Len(t, warnings, 1)
+}
+
+func TestReplicaGuardrailsReject(t *testing.T) {
+	deployment := &crd.Deployment{Name: "api", Replicas: utils.Int32Ptr(3200)}
+	_, _, err := ApplyReplicaGuardrails(replicaEnv("reject"), deployment)
+	assert.IsType(t, &errors.InvalidReplicas{}, err)
+	assert.True(t, errors.IsPermanent(err))
+
+	// a manual scale down and singletons are always allowed
+	deployment.Replicas = utils.Int32Ptr(0)
+	guarded, _, err := ApplyReplicaGuardrails(replicaEnv("reject"), deployment)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(0), *guarded.GetReplicaCount())
+
+	env := replicaEnv("reject")
+	env.Spec.MinReplicas = utils.Int32Ptr(3)
+	guarded, _, err = ApplyReplicaGuardrails(env, &crd.Deployment{Name: "lock", Singleton: true})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), *guarded.GetReplicaCount())
+}
@@ -7,8 +7,8 @@ import (
 	provCronjob "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/cronjob"
 	provDeploy "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
-	apps "k8s.io/api/apps/v1"
 	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
 
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
 )
@@ -21,11 +21,20 @@ func NewWebProvider(p *providers.Provider) (providers.ClowderProvider, error) {
 	p.Cache.AddPossibleGVKFromIdent(
 		CoreService,
 		CoreEnvoyConfigMap,
+		CorePodDisruptionBudget,
 	)
+	tlsIdents, err := tlsPossibleGVKs(p)
+	if err != nil {
+		return nil, err
+	}
+	p.Cache.AddPossibleGVKFromIdent(tlsIdents...)
 	return &webProvider{Provider: *p}, nil
 }
 
 func (web *webProvider) EnvProvide() error {
+	if err := provideTLSCA(&web.Provider); err != nil {
+		return errors.Wrap("providing tls ca", err)
+	}
 	return nil
 }
 
@@ -38,28 +47,35 @@ func (web *webProvider) Provide(app *crd.ClowdApp) error {
 		privatePort = 10000
 	}
 	web.Config.PrivatePort = utils.IntPtr(int(privatePort))
+	web.Config.ExtraPorts = extraPortConfigs(app)
+	web.Config.WebProtocols = webProtocolConfigs(app)
 
 	if err := web.populateCA(); err != nil {
 		return errors.Wrap("populating ca", err)
 	}
 
+	if err := provideServingCerts(&web.Provider, app); err != nil {
+		return errors.Wrap("providing serving certs", err)
+	}
+
 	for _, deployment := range app.Spec.Deployments {
 		innerDeployment := deployment
 		if err := makeService(web.Cache, &innerDeployment, app, web.Env); err != nil {
 			return errors.Wrap("making service", err)
 		}
 
+		if err := makePodDisruptionBudget(web.Cache, web.Env, &innerDeployment, app); err != nil {
+			return errors.Wrap("making pod disruption budget", err)
+		}
+
 		if web.Env.Spec.Providers.Web.TLS.Enabled {
-			d := &apps.Deployment{}
 			dnn := app.GetDeploymentNamespacedName(&innerDeployment)
 
-			if err := web.Cache.Get(provDeploy.CoreDeployment, d, dnn); err != nil {
-				return errors.Wrap("getting core deployment", err)
-			}
-
-			provutils.AddCertVolume(&d.Spec.Template.Spec, dnn.Name)
-
-			if err := web.Cache.Update(provDeploy.CoreDeployment, d); err != nil {
+			err := provDeploy.EditPodTemplate(web.Cache, app, &innerDeployment, func(t *core.PodTemplateSpec) error {
+				provutils.AddCertVolume(&t.Spec, dnn.Name, provutils.TLSCAConfigMapName(web.Env, app.Name))
+				return nil
+			})
+			if err != nil {
 				return errors.Wrap("updating core deployment", err)
 			}
 		}
@@ -74,7 +90,7 @@ func (web *webProvider) Provide(app *crd.ClowdApp) error {
 
 		for _, item := range d.Items {
 			innerItem := item
-			provutils.AddCertVolume(&innerItem.Spec.JobTemplate.Spec.Template.Spec, innerItem.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Name)
+			provutils.AddCertVolume(&innerItem.Spec.JobTemplate.Spec.Template.Spec, innerItem.Spec.JobTemplate.Spec.Template.Spec.Containers[0].Name, provutils.TLSCAConfigMapName(web.Env, app.Name))
 
 			if err := web.Cache.Update(provCronjob.CoreCronJob, &innerItem); err != nil {
 				return err
@@ -88,7 +104,9 @@ func (web *webProvider) Provide(app *crd.ClowdApp) error {
 
 func (web *webProvider) populateCA() error {
 	if web.Env.Spec.Providers.Web.TLS.Enabled {
-		web.Config.TlsCAPath = utils.StringPtr("/cdapp/certs/service-ca.crt")
+		web.Config.TlsCAPath = utils.StringPtr(tlsCAPath(web.Env, "/cdapp/certs/service-ca.crt"))
+		web.Config.TlsPort = utils.IntPtr(int(web.Env.Spec.Providers.Web.TLS.Port))
+		web.Config.TlsPrivatePort = utils.IntPtr(int(web.Env.Spec.Providers.Web.TLS.PrivatePort))
 	}
 	return nil
 }
@@ -7,6 +7,7 @@ import (
 
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
 	obj "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/object"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	deployProvider "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
@@ -30,8 +31,62 @@ var CoreService = rc.NewMultiResourceIdent(ProvName, "core_service", &core.Servi
 
 var CoreEnvoyConfigMap = rc.NewMultiResourceIdent(ProvName, "core_envoy_config_map", &core.ConfigMap{}, rc.ResourceOptions{WriteNow: true})
 
+// SkipService returns true if the deployment gets no service because it exposes neither a web, a
+// metrics nor an extra port, unless its service policy is 'always'.
+func SkipService(deployment *crd.Deployment, env *crd.ClowdEnvironment) bool {
+	if deployment.ServicePolicy == "always" {
+		return false
+	}
+	web := bool(deployment.Web) || deployment.WebServices.Public.Enabled || deployment.WebServices.Private.Enabled
+	return !web && env.Spec.Providers.Metrics.Port == 0 && len(deployment.ExtraPorts) == 0
+}
+
+// extraPortConfigs lists the extra ports of the deployments of the app for its config.
+func extraPortConfigs(app *crd.ClowdApp) []config.ExtraPortConfig {
+	ports := []config.ExtraPortConfig{}
+	for _, deployment := range app.Spec.Deployments {
+		for _, port := range deployment.ExtraPorts {
+			ports = append(ports, config.ExtraPortConfig{
+				Deployment: deployment.Name,
+				Name:       port.Name,
+				Port:       int(port.ContainerPort),
+				Protocol:   string(extraPortProtocol(port)),
+			})
+		}
+	}
+	return ports
+}
+
+// webProtocolConfigs lists the protocol served on the public port of each deployment of the app
+// with a public web service for its config.
+func webProtocolConfigs(app *crd.ClowdApp) []config.WebProtocolConfig {
+	protocols := []config.WebProtocolConfig{}
+	for _, deployment := range app.Spec.Deployments {
+		innerDeployment := deployment
+		if bool(innerDeployment.Web) || innerDeployment.WebServices.Public.Enabled {
+			protocols = append(protocols, config.WebProtocolConfig{
+				Deployment: innerDeployment.Name,
+				Protocol:   string(innerDeployment.GetPublicProtocol()),
+			})
+		}
+	}
+	return protocols
+}
+
+// extraPortProtocol returns the protocol of an extra port, defaulting to TCP.
+func extraPortProtocol(port crd.DeploymentPort) core.Protocol {
+	if port.Protocol == "" {
+		return core.ProtocolTCP
+	}
+	return port.Protocol
+}
+
 func makeService(cache *rc.ObjectCache, deployment *crd.Deployment, app *crd.ClowdApp, env *crd.ClowdEnvironment) error {
 
+	if SkipService(deployment, env) {
+		return nil
+	}
+
 	s := &core.Service{}
 	nn := app.GetDeploymentNamespacedName(deployment)
 
@@ -41,22 +96,17 @@ func makeService(cache *rc.ObjectCache, deployment *crd.Deployment, app *crd.Clo
 		return err
 	}
 
-	d := &apps.Deployment{}
-
-	if err := cache.Get(deployProvider.CoreDeployment, d, app.GetDeploymentNamespacedName(deployment)); err != nil {
-		return err
-	}
-
 	servicePorts := []core.ServicePort{}
 	containerPorts := []core.ContainerPort{}
 
 	if bool(deployment.Web) || deployment.WebServices.Public.Enabled {
 		// Create the core service port
+		publicProtocol := string(deployment.GetPublicProtocol())
 		webPort := core.ServicePort{
 			Name:        "public",
 			Port:        env.Spec.Providers.Web.Port,
 			Protocol:    "TCP",
-			AppProtocol: &appProtocol,
+			AppProtocol: &publicProtocol,
 			TargetPort:  intstr.FromInt(int(env.Spec.Providers.Web.Port)),
 		}
 
@@ -118,6 +168,21 @@ func makeService(cache *rc.ObjectCache, deployment *crd.Deployment, app *crd.Clo
 		)
 	}
 
+	for _, port := range deployment.ExtraPorts {
+		protocol := extraPortProtocol(port)
+		servicePorts = append(servicePorts, core.ServicePort{
+			Name:       port.Name,
+			Port:       port.ContainerPort,
+			Protocol:   protocol,
+			TargetPort: intstr.FromInt(int(port.ContainerPort)),
+		})
+		containerPorts = append(containerPorts, core.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.ContainerPort,
+			Protocol:      protocol,
+		})
+	}
+
 	var pub, priv bool
 	var pubPort, privPort uint32
 	if env.Spec.Providers.Web.TLS.Enabled {
@@ -157,20 +222,46 @@ func makeService(cache *rc.ObjectCache, deployment *crd.Deployment, app *crd.Clo
 			if err := generateEnvoyConfigMap(cache, nn, app, pub, priv, pubPort, privPort); err != nil {
 				return err
 			}
-			populateSideCar(d, nn.Name, env.Spec.Providers.Web.TLS.Port, env.Spec.Providers.Web.TLS.PrivatePort, pub, priv)
-			setServiceTLSAnnotations(s, nn.Name)
+			// In the other modes Clowder provisions the serving cert, the service CA would
+			// overwrite it
+			if tlsMode(env) == "none" {
+				setServiceTLSAnnotations(s, nn.Name)
+			}
 		}
 	}
 
 	utils.MakeService(s, nn, map[string]string{"pod": nn.Name}, servicePorts, app, env.IsNodePort())
-
-	d.Spec.Template.Spec.Containers[0].Ports = containerPorts
+	deployProvider.SetCustomMetadata(s, env, deployment)
+	setSessionAffinity(s, deployment)
 
 	if err := cache.Update(CoreService, s); err != nil {
 		return err
 	}
 
-	return cache.Update(deployProvider.CoreDeployment, d)
+	return deployProvider.EditPodTemplate(cache, app, deployment, func(t *core.PodTemplateSpec) error {
+		t.Spec.Containers[0].Ports = containerPorts
+		if priv || pub {
+			populateSideCar(t, nn.Name, env.Spec.Providers.Web.TLS.Port, env.Spec.Providers.Web.TLS.PrivatePort, pub, priv)
+		}
+		return nil
+	})
+}
+
+// setSessionAffinity applies the deployment's session affinity to its service, services without
+// one configured get the default of None.
+func setSessionAffinity(s *core.Service, deployment *crd.Deployment) {
+	if deployment.SessionAffinity == nil || deployment.SessionAffinity.Type != "ClientIP" {
+		s.Spec.SessionAffinity = core.ServiceAffinityNone
+		s.Spec.SessionAffinityConfig = nil
+		return
+	}
+
+	s.Spec.SessionAffinity = core.ServiceAffinityClientIP
+	s.Spec.SessionAffinityConfig = &core.SessionAffinityConfig{
+		ClientIP: &core.ClientIPConfig{
+			TimeoutSeconds: deployment.SessionAffinity.TimeoutSeconds,
+		},
+	}
 }
 
 func generateEnvoyConfigMap(cache *rc.ObjectCache, nn types.NamespacedName, app *crd.ClowdApp, pub bool, priv bool, pubPort uint32, privPort uint32) error {
@@ -200,7 +291,7 @@ func generateEnvoyConfigMap(cache *rc.ObjectCache, nn types.NamespacedName, app
 	return cache.Update(CoreEnvoyConfigMap, cm)
 }
 
-func populateSideCar(d *apps.Deployment, name string, port int32, privatePort int32, pub bool, priv bool) {
+func populateSideCar(t *core.PodTemplateSpec, name string, port int32, privatePort int32, pub bool, priv bool) {
 	ports := []core.ContainerPort{}
 	if pub {
 		ports = append(ports, core.ContainerPort{
@@ -255,13 +346,13 @@ func populateSideCar(d *apps.Deployment, name string, port int32, privatePort in
 		VolumeSource: core.VolumeSource{
 			ConfigMap: &core.ConfigMapVolumeSource{
 				LocalObjectReference: core.LocalObjectReference{
-					Name: envoyConfigName(d.Name),
+					Name: envoyConfigName(name),
 				},
 			},
 		},
 	}
-	d.Spec.Template.Spec.Containers = append(d.Spec.Template.Spec.Containers, container)
-	d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, envoyConfigVol, envoyTLSVol)
+	t.Spec.Containers = append(t.Spec.Containers, container)
+	t.Spec.Volumes = append(t.Spec.Volumes, envoyConfigVol, envoyTLSVol)
 }
 
 func setServiceTLSAnnotations(s *core.Service, name string) {
@@ -0,0 +1,123 @@
+package web
+
+import (
+	"testing"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+)
+
+func TestSessionAffinityDefault(t *testing.T) {
+	s := &core.Service{}
+	setSessionAffinity(s, &crd.Deployment{Name: "web"})
+	assert.Equal(t, core.ServiceAffinityNone, s.Spec.SessionAffinity)
+	assert.Nil(t, s.Spec.SessionAffinityConfig)
+}
+
+func TestSessionAffinityClientIP(t *testing.T) {
+	s := &core.Service{}
+	setSessionAffinity(s, &crd.Deployment{
+		Name: "web",
+		SessionAffinity: &crd.SessionAffinity{
+			Type:           "ClientIP",
+			TimeoutSeconds: utils.Int32Ptr(600),
+		},
+	})
+	assert.Equal(t, core.ServiceAffinityClientIP, s.Spec.SessionAffinity)
+	assert.Equal(t, int32(600), *s.Spec.SessionAffinityConfig.ClientIP.TimeoutSeconds)
+
+	// switching back to None clears the ClientIP config from the service
+	setSessionAffinity(s, &crd.Deployment{
+		Name:            "web",
+		SessionAffinity: &crd.SessionAffinity{Type: "None"},
+	})
+	assert.Equal(t, core.ServiceAffinityNone, s.Spec.SessionAffinity)
+	assert.Nil(t, s.Spec.SessionAffinityConfig)
+}
+
+func TestSkipService(t *testing.T) {
+	env := &crd.ClowdEnvironment{}
+	worker := &crd.Deployment{Name: "worker"}
+
+	// by default a deployment without ports gets no service
+	assert.True(t, SkipService(worker, env))
+
+	worker.ServicePolicy = "always"
+	assert.False(t, SkipService(worker, env))
+
+	worker.ServicePolicy = "exposed-ports"
+	assert.True(t, SkipService(worker, env))
+
+	worker.WebServices.Private.Enabled = true
+	assert.False(t, SkipService(worker, env))
+
+	// every deployment exposes the metrics port when one is configured
+	worker.WebServices.Private.Enabled = false
+	env.Spec.Providers.Metrics.Port = 9000
+	assert.False(t, SkipService(worker, env))
+
+	env.Spec.Providers.Metrics.Port = 0
+	worker.ExtraPorts = []crd.DeploymentPort{{Name: "grpc", ContainerPort: 9090}}
+	assert.False(t, SkipService(worker, env))
+}
+
+func TestExtraPortConfigs(t *testing.T) {
+	app := &crd.ClowdApp{
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{{
+				Name: "api",
+				ExtraPorts: []crd.DeploymentPort{
+					{Name: "grpc", ContainerPort: 9090},
+					{Name: "stats", ContainerPort: 8125, Protocol: core.ProtocolUDP},
+				},
+			}, {
+				Name: "worker",
+			}},
+		},
+	}
+
+	assert.Equal(t, []config.ExtraPortConfig{
+		{Deployment: "api", Name: "grpc", Port: 9090, Protocol: "TCP"},
+		{Deployment: "api", Name: "stats", Port: 8125, Protocol: "UDP"},
+	}, extraPortConfigs(app))
+}
+
+func TestWebProtocolConfigs(t *testing.T) {
+	app := &crd.ClowdApp{
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{{
+				Name:        "api",
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true}},
+			}, {
+				Name:        "grpc",
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true, Protocol: "grpc"}},
+			}, {
+				Name: "worker",
+			}},
+		},
+	}
+
+	assert.Equal(t, []config.WebProtocolConfig{
+		{Deployment: "api", Protocol: "http"},
+		{Deployment: "grpc", Protocol: "grpc"},
+	}, webProtocolConfigs(app))
+}
+
+func TestMinimumReplicas(t *testing.T) {
+	deployment := &crd.Deployment{Name: "api"}
+	assert.Equal(t, int32(1), minimumReplicas(deployment))
+
+	deployment.Replicas = utils.Int32Ptr(3)
+	assert.Equal(t, int32(3), minimumReplicas(deployment))
+
+	// the autoscaler decides the replica count
+	deployment.AutoScalerSimple = &crd.AutoScalerSimple{Replicas: crd.SimpleAutoScalerReplicas{Min: 2, Max: 5}}
+	assert.Equal(t, int32(2), minimumReplicas(deployment))
+
+	deployment.AutoScalerSimple = nil
+	deployment.Singleton = true
+	assert.Equal(t, int32(1), minimumReplicas(deployment))
+}
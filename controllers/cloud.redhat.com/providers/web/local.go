@@ -79,11 +79,21 @@ func NewLocalWebProvider(p *providers.Provider) (providers.ClowderProvider, erro
 		WebIngress,
 		CoreEnvoyConfigMap,
 		CoreService,
+		CorePodDisruptionBudget,
 	)
+	tlsIdents, err := tlsPossibleGVKs(p)
+	if err != nil {
+		return nil, err
+	}
+	p.Cache.AddPossibleGVKFromIdent(tlsIdents...)
 	return &localWebProvider{Provider: *p}, nil
 }
 
 func (web *localWebProvider) EnvProvide() error {
+	if err := provideTLSCA(&web.Provider); err != nil {
+		return err
+	}
+
 	if web.Env.Status.Hostname == "" {
 		web.Env.Status.Hostname = web.Env.GenerateHostname(web.Ctx, web.Client, web.Log, !clowderconfig.LoadedConfig.Features.DisableRandomRoutes)
 		err := web.Client.Status().Update(web.Ctx, web.Env)
@@ -187,17 +197,27 @@ func (web *localWebProvider) Provide(app *crd.ClowdApp) error {
 		privatePort = 10000
 	}
 	web.Config.PrivatePort = utils.IntPtr(int(privatePort))
+	web.Config.ExtraPorts = extraPortConfigs(app)
+	web.Config.WebProtocols = webProtocolConfigs(app)
 
 	if err := web.populateCA(); err != nil {
 		return err
 	}
 
+	if err := provideServingCerts(&web.Provider, app); err != nil {
+		return err
+	}
+
 	for _, deployment := range app.Spec.Deployments {
 		innerDeployment := deployment
 		if err := makeService(web.Cache, &innerDeployment, app, web.Env); err != nil {
 			return err
 		}
 
+		if err := makePodDisruptionBudget(web.Cache, web.Env, &innerDeployment, app); err != nil {
+			return err
+		}
+
 		if err := web.createIngress(app, &innerDeployment); err != nil {
 			return err
 		}
@@ -232,23 +252,21 @@ func (web *localWebProvider) Provide(app *crd.ClowdApp) error {
 		h.Write([]byte(jsonData))
 		hash := fmt.Sprintf("%x", h.Sum(nil))
 
-		d := &apps.Deployment{}
 		dnn := app.GetDeploymentNamespacedName(&innerDeployment)
-		if err := web.Cache.Get(provDeploy.CoreDeployment, d, dnn); err != nil {
-			return err
-		}
 
-		if web.Env.Spec.Providers.Web.TLS.Enabled {
-			provutils.AddCertVolume(&d.Spec.Template.Spec, dnn.Name)
-		}
-
-		annotations := map[string]string{
-			"clowder/authsidecar-confighash": hash,
-		}
+		err = provDeploy.EditPodTemplate(web.Cache, app, &innerDeployment, func(t *core.PodTemplateSpec) error {
+			if web.Env.Spec.Providers.Web.TLS.Enabled {
+				provutils.AddCertVolume(&t.Spec, dnn.Name, provutils.TLSCAConfigMapName(web.Env, app.Name))
+			}
 
-		utils.UpdateAnnotations(&d.Spec.Template, annotations)
+			annotations := map[string]string{
+				"clowder/authsidecar-confighash": hash,
+			}
 
-		if err := web.Cache.Update(provDeploy.CoreDeployment, d); err != nil {
+			utils.UpdateAnnotations(t, annotations)
+			return nil
+		})
+		if err != nil {
 			return err
 		}
 
@@ -263,7 +281,9 @@ func (web *localWebProvider) Provide(app *crd.ClowdApp) error {
 
 func (web *localWebProvider) populateCA() error {
 	if web.Env.Spec.Providers.Web.TLS.Enabled {
-		web.Config.TlsCAPath = utils.StringPtr("/cdapp/certs/openshift-service-ca.crt")
+		web.Config.TlsCAPath = utils.StringPtr(tlsCAPath(web.Env, "/cdapp/certs/openshift-service-ca.crt"))
+		web.Config.TlsPort = utils.IntPtr(int(web.Env.Spec.Providers.Web.TLS.Port))
+		web.Config.TlsPrivatePort = utils.IntPtr(int(web.Env.Spec.Providers.Web.TLS.PrivatePort))
 	}
 	return nil
 }
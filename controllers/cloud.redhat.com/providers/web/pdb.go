@@ -0,0 +1,80 @@
+package web
+
+import (
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
+
+	policy "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+)
+
+// CorePodDisruptionBudget is the PodDisruptionBudget of the public web deployments of an app.
+var CorePodDisruptionBudget = rc.NewMultiResourceIdent(ProvName, "core_pdb", &policy.PodDisruptionBudget{})
+
+const defaultMinAvailable = 1
+
+// minimumReplicas returns the smallest number of replicas the deployment runs with, taking its
+// autoscaler into account.
+func minimumReplicas(deployment *crd.Deployment) int32 {
+	if deployment.Singleton {
+		return 1
+	}
+	if deployment.AutoScalerSimple != nil {
+		return deployment.AutoScalerSimple.Replicas.Min
+	}
+	if deployment.AutoScaler != nil && deployment.AutoScaler.MinReplicaCount != nil {
+		return *deployment.AutoScaler.MinReplicaCount
+	}
+	return *deployment.GetReplicaCount()
+}
+
+// makePodDisruptionBudget creates a PodDisruptionBudget, owned by the app, for a public web
+// deployment running more than one replica. MinAvailable is capped so that at least one pod can
+// always be evicted and the budget never blocks a node drain. The replicas are counted after the
+// replica guardrails of the environment are applied.
+func makePodDisruptionBudget(cache *rc.ObjectCache, env *crd.ClowdEnvironment, deployment *crd.Deployment, app *crd.ClowdApp) error {
+	if !(bool(deployment.Web) || deployment.WebServices.Public.Enabled) {
+		return nil
+	}
+
+	guarded, _, err := provutils.ApplyReplicaGuardrails(env, deployment)
+	if err != nil {
+		return err
+	}
+
+	replicas := minimumReplicas(guarded)
+	if replicas <= 1 {
+		return nil
+	}
+
+	minAvailable := int32(defaultMinAvailable)
+	if deployment.MinAvailable != nil {
+		minAvailable = *deployment.MinAvailable
+	}
+	if minAvailable >= replicas {
+		minAvailable = replicas - 1
+	}
+
+	nn := app.GetDeploymentNamespacedName(deployment)
+
+	pdb := &policy.PodDisruptionBudget{}
+	if err := cache.Create(CorePodDisruptionBudget, nn, pdb); err != nil {
+		return err
+	}
+
+	// The selector must match the labels of the pod template set by the deployment provider
+	labels := app.GetLabels()
+	labels["pod"] = nn.Name
+	app.SetObjectMeta(pdb, crd.Name(nn.Name), crd.Labels(labels))
+
+	available := intstr.FromInt(int(minAvailable))
+	pdb.Spec = policy.PodDisruptionBudgetSpec{
+		MinAvailable: &available,
+		Selector:     &metav1.LabelSelector{MatchLabels: labels},
+	}
+
+	return cache.Update(CorePodDisruptionBudget, pdb)
+}
@@ -13,6 +13,10 @@ var ProvName = "web"
 // GetEnd returns the correct end provider.
 func GetWeb(c *providers.Provider) (providers.ClowderProvider, error) {
 
+	if err := validateTLS(c.Env); err != nil {
+		return nil, err
+	}
+
 	webMode := c.Env.Spec.Providers.Web.Mode
 	switch webMode {
 	case "none", "operator":
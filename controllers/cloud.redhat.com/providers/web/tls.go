@@ -0,0 +1,454 @@
+package web
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+)
+
+// WebTLSCASecret is the CA of an environment in self-signed TLS mode
+var WebTLSCASecret = rc.NewSingleResourceIdent(ProvName, "web_tls_ca_secret", &core.Secret{})
+
+// WebTLSCAConfigMap is the CA of the serving certs of an app, mounted into its pods
+var WebTLSCAConfigMap = rc.NewSingleResourceIdent(ProvName, "web_tls_ca_config_map", &core.ConfigMap{})
+
+// WebServingCertSecret is the serving cert of the TLS sidecar of a deployment in self-signed TLS mode
+var WebServingCertSecret = rc.NewMultiResourceIdent(ProvName, "web_serving_cert_secret", &core.Secret{})
+
+// WebServingCertificate is the cert-manager Certificate of the TLS sidecar of a deployment
+var WebServingCertificate = rc.NewMultiResourceIdent(ProvName, "web_serving_certificate", newCertificate())
+
+// certificateGVK is the kind of the cert-manager Certificates, which are handled as unstructured
+// objects so that Clowder does not depend on the cert-manager API.
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+const (
+	// caValidity is how long the CA of an environment in self-signed TLS mode is valid for
+	caValidity = 10 * 365 * 24 * time.Hour
+	// servingCertValidity is how long a self-signed serving cert is valid for
+	servingCertValidity = 365 * 24 * time.Hour
+	// certRenewBefore is how long before they expire certs are regenerated
+	certRenewBefore = 30 * 24 * time.Hour
+	// caConfigMapKey is the key of the CA in the ConfigMap mounted at /cdapp/certs
+	caConfigMapKey = "service-ca.crt"
+)
+
+func newCertificate() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(certificateGVK)
+	return u
+}
+
+func tlsMode(env *crd.ClowdEnvironment) crd.TLSMode {
+	if env.Spec.Providers.Web.TLS.Mode == "" {
+		return "none"
+	}
+	return env.Spec.Providers.Web.TLS.Mode
+}
+
+// validateTLS checks that the TLS config of the environment is complete for its mode.
+func validateTLS(env *crd.ClowdEnvironment) error {
+	tls := env.Spec.Providers.Web.TLS
+	if !tls.Enabled {
+		return nil
+	}
+	if tlsMode(env) == "cert-manager" && (tls.IssuerRef == nil || tls.IssuerRef.Name == "") {
+		return &errors.InvalidTLSConfig{Mode: "cert-manager", Reason: "requires an issuerRef"}
+	}
+	return nil
+}
+
+// tlsPossibleGVKs returns the idents of the TLS resources the web provider may create. Certificates
+// are added whenever cert-manager is installed, whatever the mode of the environment, so that they
+// are removed once the environment leaves cert-manager mode. Listing them fails on clusters
+// without cert-manager.
+func tlsPossibleGVKs(p *providers.Provider) ([]rc.ResourceIdent, error) {
+	idents := []rc.ResourceIdent{WebTLSCASecret, WebTLSCAConfigMap, WebServingCertSecret}
+	installed, err := CertManagerInstalled(p.Client.RESTMapper())
+	if err != nil {
+		return nil, err
+	}
+	if installed {
+		idents = append(idents, WebServingCertificate)
+	}
+	return idents, nil
+}
+
+// CertManagerInstalled reports whether the Certificate CRD of cert-manager is installed in the
+// cluster, clusters without it would reject any request for Certificates.
+func CertManagerInstalled(mapper meta.RESTMapper) (bool, error) {
+	return provutils.KindInstalled(mapper, certificateGVK)
+}
+
+// servesTLS returns true if the TLS sidecar terminates TLS for the deployment.
+func servesTLS(deployment *crd.Deployment) bool {
+	if deployment.WebServices.Public.Enabled {
+		return true
+	}
+	return deployment.WebServices.Private.Enabled && (deployment.WebServices.Private.AppProtocol == "" || deployment.WebServices.Private.AppProtocol == "http")
+}
+
+// tlsCAPath returns the path of the CA in the config of the app, fallback is used in none mode
+// where the OpenShift service CA is mounted.
+func tlsCAPath(env *crd.ClowdEnvironment, fallback string) string {
+	if tlsMode(env) == "none" {
+		return fallback
+	}
+	return "/cdapp/certs/" + caConfigMapKey
+}
+
+// provideTLSCA generates the CA of an environment in self-signed TLS mode, an existing CA is kept
+// until it is about to expire.
+func provideTLSCA(p *providers.Provider) error {
+	if !p.Env.Spec.Providers.Web.TLS.Enabled || tlsMode(p.Env) != "self-signed" {
+		return nil
+	}
+
+	nn := providers.GetNamespacedName(p.Env, "tls-ca")
+	secret := &core.Secret{}
+	if err := p.Cache.Create(WebTLSCASecret, nn, secret); err != nil {
+		return err
+	}
+
+	if !certValid(secret.Data[core.TLSCertKey], nil, nil, time.Now()) {
+		certPEM, keyPEM, err := generateCA(fmt.Sprintf("clowder-%s-ca", p.Env.Name), time.Now())
+		if err != nil {
+			return errors.Wrap("generating tls ca", err)
+		}
+		secret.Data = map[string][]byte{
+			core.TLSCertKey:       certPEM,
+			core.TLSPrivateKeyKey: keyPEM,
+		}
+	}
+
+	secret.Name = nn.Name
+	secret.Namespace = nn.Namespace
+	secret.Type = core.SecretTypeTLS
+	secret.OwnerReferences = []metav1.OwnerReference{p.Env.MakeOwnerReference()}
+
+	return p.Cache.Update(WebTLSCASecret, secret)
+}
+
+// provideServingCerts provisions the serving certs of the TLS sidecars of the app and the
+// ConfigMap with their CA, unless the OpenShift service CA issues them.
+func provideServingCerts(p *providers.Provider, app *crd.ClowdApp) error {
+	if !p.Env.Spec.Providers.Web.TLS.Enabled {
+		return nil
+	}
+
+	var caPEM []byte
+	switch tlsMode(p.Env) {
+	case "self-signed":
+		ca := &core.Secret{}
+		if err := p.GetShared(providers.GetNamespacedName(p.Env, "tls-ca"), ca); err != nil {
+			return errors.Wrap("getting tls ca", err)
+		}
+		caPEM = ca.Data[core.TLSCertKey]
+
+		for _, deployment := range app.Spec.Deployments {
+			innerDeployment := deployment
+			if !servesTLS(&innerDeployment) {
+				continue
+			}
+			if err := makeSelfSignedCert(p, app, &innerDeployment, ca); err != nil {
+				return err
+			}
+		}
+	case "cert-manager":
+		for _, deployment := range app.Spec.Deployments {
+			innerDeployment := deployment
+			if !servesTLS(&innerDeployment) {
+				continue
+			}
+			if err := makeCertificate(p, app, &innerDeployment); err != nil {
+				return err
+			}
+			// The CA is copied from the first cert cert-manager has issued, until then the
+			// ConfigMap stays empty and is filled when an update of the Certificate reconciles
+			// the app
+			if caPEM == nil {
+				issued, err := issuedCA(p, app, &innerDeployment)
+				if err != nil {
+					return errors.Wrap("getting issued tls ca", err)
+				}
+				caPEM = issued
+			}
+		}
+		// The app is not pointed at a CA that isn't there yet
+		if caPEM == nil {
+			p.Config.TlsCAPath = nil
+		}
+	default:
+		return nil
+	}
+
+	nn := types.NamespacedName{Name: provutils.TLSCAConfigMapName(p.Env, app.Name), Namespace: app.Namespace}
+	cm := &core.ConfigMap{}
+	if err := p.Cache.Create(WebTLSCAConfigMap, nn, cm); err != nil {
+		return err
+	}
+
+	cm.Name = nn.Name
+	cm.Namespace = nn.Namespace
+	cm.OwnerReferences = []metav1.OwnerReference{app.MakeOwnerReference()}
+	if caPEM != nil {
+		cm.Data = map[string]string{caConfigMapKey: string(caPEM)}
+	}
+
+	return p.Cache.Update(WebTLSCAConfigMap, cm)
+}
+
+// servingCertDNSNames returns the names the serving cert of a deployment is valid for, the names
+// of its service.
+func servingCertDNSNames(nn types.NamespacedName) []string {
+	return []string{
+		nn.Name,
+		fmt.Sprintf("%s.%s", nn.Name, nn.Namespace),
+		fmt.Sprintf("%s.%s.svc", nn.Name, nn.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", nn.Name, nn.Namespace),
+	}
+}
+
+// makeSelfSignedCert signs the serving cert of the deployment with the CA of the environment, an
+// existing cert is kept until it is about to expire or was signed by another CA.
+func makeSelfSignedCert(p *providers.Provider, app *crd.ClowdApp, deployment *crd.Deployment, ca *core.Secret) error {
+	dnn := app.GetDeploymentNamespacedName(deployment)
+	nn := types.NamespacedName{Name: certSecretName(dnn.Name), Namespace: dnn.Namespace}
+	dnsNames := servingCertDNSNames(dnn)
+
+	secret := &core.Secret{}
+	if err := p.Cache.Create(WebServingCertSecret, nn, secret); err != nil {
+		return err
+	}
+
+	if !certValid(secret.Data[core.TLSCertKey], ca.Data[core.TLSCertKey], dnsNames, time.Now()) {
+		certPEM, keyPEM, err := generateServingCert(ca.Data[core.TLSCertKey], ca.Data[core.TLSPrivateKeyKey], dnsNames, time.Now())
+		if err != nil {
+			return errors.Wrap("generating serving cert", err)
+		}
+		secret.Data = map[string][]byte{
+			core.TLSCertKey:       certPEM,
+			core.TLSPrivateKeyKey: keyPEM,
+		}
+	}
+	secret.Data["ca.crt"] = ca.Data[core.TLSCertKey]
+
+	secret.Name = nn.Name
+	secret.Namespace = nn.Namespace
+	secret.Type = core.SecretTypeTLS
+	secret.OwnerReferences = []metav1.OwnerReference{app.MakeOwnerReference()}
+
+	return p.Cache.Update(WebServingCertSecret, secret)
+}
+
+// makeCertificate requests the serving cert of the deployment from the cert-manager issuer of the
+// environment, cert-manager writes it to the secret mounted by the TLS sidecar.
+func makeCertificate(p *providers.Provider, app *crd.ClowdApp, deployment *crd.Deployment) error {
+	dnn := app.GetDeploymentNamespacedName(deployment)
+	nn := types.NamespacedName{Name: certSecretName(dnn.Name), Namespace: dnn.Namespace}
+
+	cert := newCertificate()
+	if err := p.Cache.Create(WebServingCertificate, nn, cert); err != nil {
+		return err
+	}
+
+	issuerRef := p.Env.Spec.Providers.Web.TLS.IssuerRef
+	kind := issuerRef.Kind
+	if kind == "" {
+		kind = "Issuer"
+	}
+	group := issuerRef.Group
+	if group == "" {
+		group = "cert-manager.io"
+	}
+
+	dnsNames := []interface{}{}
+	for _, name := range servingCertDNSNames(dnn) {
+		dnsNames = append(dnsNames, name)
+	}
+
+	cert.SetName(nn.Name)
+	cert.SetNamespace(nn.Namespace)
+	cert.SetOwnerReferences([]metav1.OwnerReference{app.MakeOwnerReference()})
+	cert.Object["spec"] = map[string]interface{}{
+		"secretName": nn.Name,
+		"commonName": dnn.Name,
+		"dnsNames":   dnsNames,
+		"usages":     []interface{}{"server auth"},
+		"issuerRef": map[string]interface{}{
+			"name":  issuerRef.Name,
+			"kind":  kind,
+			"group": group,
+		},
+	}
+
+	return p.Cache.Update(WebServingCertificate, cert)
+}
+
+// issuedCA returns the CA of the serving cert cert-manager issued for the deployment, or nil if it
+// has not been issued yet. The secret is written by cert-manager rather than Clowder, so it is
+// recorded in the SecretRefs of the provider and a renewal with a new CA reconciles the app.
+func issuedCA(p *providers.Provider, app *crd.ClowdApp, deployment *crd.Deployment) ([]byte, error) {
+	dnn := app.GetDeploymentNamespacedName(deployment)
+	nn := types.NamespacedName{Name: certSecretName(dnn.Name), Namespace: dnn.Namespace}
+	if p.SecretRefs != nil {
+		p.SecretRefs.Add(nn)
+	}
+
+	secret := &core.Secret{}
+	if err := p.Client.Get(p.Ctx, nn, secret); err != nil {
+		if k8serr.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return secret.Data["ca.crt"], nil
+}
+
+// generateCA returns a new self-signed CA cert and its key, PEM encoded.
+func generateCA(commonName string, now time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCert(der, key)
+}
+
+// generateServingCert returns a new serving cert for the DNS names signed by the CA and its key,
+// PEM encoded.
+func generateServingCert(caCertPEM []byte, caKeyPEM []byte, dnsNames []string, now time.Time) ([]byte, []byte, error) {
+	caCert, err := parseCert(caCertPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, _ := pem.Decode(caKeyPEM)
+	if block == nil {
+		return nil, nil, fmt.Errorf("no PEM data in ca key")
+	}
+	caKey, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0]},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(servingCertValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return encodeCert(der, key)
+}
+
+func encodeCert(der []byte, key *ecdsa.PrivateKey) ([]byte, []byte, error) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+func parseCert(certPEM []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in cert")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// certValid returns true if the cert is not about to expire, and if given, was signed by the CA
+// and is valid for exactly the DNS names.
+func certValid(certPEM []byte, caCertPEM []byte, dnsNames []string, now time.Time) bool {
+	cert, err := parseCert(certPEM)
+	if err != nil {
+		return false
+	}
+
+	if now.Add(certRenewBefore).After(cert.NotAfter) {
+		return false
+	}
+
+	if caCertPEM != nil {
+		caCert, err := parseCert(caCertPEM)
+		if err != nil || !bytes.Equal(cert.RawIssuer, caCert.RawSubject) || cert.CheckSignatureFrom(caCert) != nil {
+			return false
+		}
+	}
+
+	if dnsNames != nil {
+		if len(cert.DNSNames) != len(dnsNames) {
+			return false
+		}
+		for i, name := range dnsNames {
+			if cert.DNSNames[i] != name {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,176 @@
+package web
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
+	provutils "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/utils"
+	rc "github.com/RedHatInsights/rhc-osdk-utils/resourceCache"
+	"github.com/RedHatInsights/rhc-osdk-utils/utils"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func tlsEnv(mode crd.TLSMode, issuerRef *crd.TLSIssuerRef) *crd.ClowdEnvironment {
+	env := &crd.ClowdEnvironment{}
+	env.Spec.Providers.Web.Mode = "operator"
+	env.Spec.Providers.Web.TLS = crd.TLS{
+		Enabled:   true,
+		Port:      8800,
+		Mode:      mode,
+		IssuerRef: issuerRef,
+	}
+	return env
+}
+
+func TestValidateTLS(t *testing.T) {
+	assert.Nil(t, validateTLS(tlsEnv("", nil)))
+	assert.Nil(t, validateTLS(tlsEnv("self-signed", nil)))
+	assert.Nil(t, validateTLS(tlsEnv("cert-manager", &crd.TLSIssuerRef{Name: "issuer"})))
+
+	for _, issuerRef := range []*crd.TLSIssuerRef{nil, {Kind: "ClusterIssuer"}} {
+		err := validateTLS(tlsEnv("cert-manager", issuerRef))
+		assert.IsType(t, &errors.InvalidTLSConfig{}, err)
+		assert.True(t, errors.IsPermanent(err))
+	}
+
+	// disabled TLS is not validated
+	env := tlsEnv("cert-manager", nil)
+	env.Spec.Providers.Web.TLS.Enabled = false
+	assert.Nil(t, validateTLS(env))
+}
+
+func TestGetWebInvalidTLS(t *testing.T) {
+	_, err := GetWeb(&providers.Provider{Env: tlsEnv("cert-manager", nil)})
+	assert.IsType(t, &errors.InvalidTLSConfig{}, err)
+}
+
+func TestSelfSignedCerts(t *testing.T) {
+	now := time.Now()
+	dnsNames := []string{"app-web", "app-web.ns", "app-web.ns.svc", "app-web.ns.svc.cluster.local"}
+
+	caCert, caKey, err := generateCA("clowder-env-ca", now)
+	assert.Nil(t, err)
+	assert.True(t, certValid(caCert, nil, nil, now))
+
+	cert, key, err := generateServingCert(caCert, caKey, dnsNames, now)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, key)
+	assert.True(t, certValid(cert, caCert, dnsNames, now))
+
+	// the cert is renewed a month before it expires
+	assert.True(t, certValid(cert, caCert, dnsNames, now.Add(servingCertValidity-certRenewBefore-time.Hour)))
+	assert.False(t, certValid(cert, caCert, dnsNames, now.Add(servingCertValidity-certRenewBefore+time.Hour)))
+
+	// a cert for other names or signed by another CA is replaced
+	assert.False(t, certValid(cert, caCert, dnsNames[:2], now))
+	otherCA, _, err := generateCA("clowder-other-ca", now)
+	assert.Nil(t, err)
+	assert.False(t, certValid(cert, otherCA, dnsNames, now))
+
+	assert.False(t, certValid(nil, caCert, dnsNames, now))
+}
+
+func TestTLSCAPath(t *testing.T) {
+	assert.Equal(t, "/cdapp/certs/service-ca.crt", tlsCAPath(tlsEnv("", nil), "/cdapp/certs/service-ca.crt"))
+	assert.Equal(t, "/cdapp/certs/openshift-service-ca.crt", tlsCAPath(tlsEnv("none", nil), "/cdapp/certs/openshift-service-ca.crt"))
+	assert.Equal(t, "/cdapp/certs/service-ca.crt", tlsCAPath(tlsEnv("self-signed", nil), "/cdapp/certs/openshift-service-ca.crt"))
+}
+
+func certManagerTestProvider(t *testing.T, objs ...client.Object) *providers.Provider {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, crd.AddToScheme(scheme))
+	pClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+
+	p := &providers.Provider{
+		Client:     pClient,
+		Ctx:        context.Background(),
+		Log:        logr.Discard(),
+		Config:     &config.AppConfig{TlsCAPath: utils.StringPtr("/cdapp/certs/service-ca.crt")},
+		Env:        tlsEnv("cert-manager", &crd.TLSIssuerRef{Name: "issuer"}),
+		SecretRefs: providers.NewSecretRefs(),
+	}
+	cache := rc.NewObjectCache(p.Ctx, pClient, &p.Log, rc.NewCacheConfig(scheme, nil, nil))
+	p.Cache = &cache
+	return p
+}
+
+func certManagerTestApp() *crd.ClowdApp {
+	app := &crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "app-ns"},
+		Spec: crd.ClowdAppSpec{
+			Deployments: []crd.Deployment{{Name: "api"}},
+		},
+	}
+	app.Spec.Deployments[0].WebServices.Public.Enabled = true
+	return app
+}
+
+func TestCertManagerInstalled(t *testing.T) {
+	installed, err := CertManagerInstalled(meta.NewDefaultRESTMapper(nil))
+	assert.NoError(t, err)
+	assert.False(t, installed)
+
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{certificateGVK.GroupVersion()})
+	mapper.Add(certificateGVK, meta.RESTScopeNamespace)
+	installed, err = CertManagerInstalled(mapper)
+	assert.NoError(t, err)
+	assert.True(t, installed)
+}
+
+func TestIssuedCA(t *testing.T) {
+	app := certManagerTestApp()
+	nn := types.NamespacedName{Name: certSecretName("app-api"), Namespace: "app-ns"}
+
+	// the secret doesn't exist until cert-manager has issued the cert
+	p := certManagerTestProvider(t)
+	ca, err := issuedCA(p, app, &app.Spec.Deployments[0])
+	assert.NoError(t, err)
+	assert.Nil(t, ca)
+	assert.Equal(t, []types.NamespacedName{nn}, p.SecretRefs.List())
+
+	p = certManagerTestProvider(t, &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Data:       map[string][]byte{"ca.crt": []byte("ca")},
+	})
+	ca, err = issuedCA(p, app, &app.Spec.Deployments[0])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("ca"), ca)
+}
+
+func TestCertManagerCAPathWaitsForIssuedCA(t *testing.T) {
+	app := certManagerTestApp()
+
+	p := certManagerTestProvider(t)
+	assert.NoError(t, provideServingCerts(p, app))
+	assert.Nil(t, p.Config.TlsCAPath)
+
+	p = certManagerTestProvider(t, &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: certSecretName("app-api"), Namespace: "app-ns"},
+		Data:       map[string][]byte{"ca.crt": []byte("ca")},
+	})
+	assert.NoError(t, provideServingCerts(p, app))
+	if assert.NotNil(t, p.Config.TlsCAPath) {
+		assert.Equal(t, "/cdapp/certs/service-ca.crt", *p.Config.TlsCAPath)
+	}
+
+	cm := &core.ConfigMap{}
+	nn := types.NamespacedName{Name: provutils.TLSCAConfigMapName(p.Env, app.Name), Namespace: app.Namespace}
+	assert.NoError(t, p.Cache.Get(WebTLSCAConfigMap, cm, nn))
+	assert.Equal(t, "ca", cm.Data[caConfigMapKey])
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"sync"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// appResyncBufferSize bounds the number of distinct ClowdApps waiting to be handed to the
+// controller after a resync was requested through the API.
+const appResyncBufferSize = 128
+
+// appResyncs is the source of the ClowdApp controller for resyncs requested through the API.
+var appResyncs = make(chan event.GenericEvent, appResyncBufferSize)
+
+var appResyncMu sync.Mutex
+
+// pendingAppResyncs holds the apps with a requested resync whose reconcile has not started yet,
+// further requests for them are dropped.
+var pendingAppResyncs = map[types.NamespacedName]bool{}
+
+// requestAppResync queues a reconcile of the app. It returns false if too many resyncs are already
+// waiting, a request for an app that is already waiting is accepted without queueing it again.
+// The provider backoff of an accepted app is cleared, so that the forced reconcile isn't skipped.
+func requestAppResync(app *crd.ClowdApp) bool {
+	appResyncMu.Lock()
+	defer appResyncMu.Unlock()
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	if pendingAppResyncs[nn] {
+		appProviderBackoffs.clear(nn)
+		return true
+	}
+
+	select {
+	case appResyncs <- event.GenericEvent{Object: app}:
+		pendingAppResyncs[nn] = true
+		appProviderBackoffs.clear(nn)
+		return true
+	default:
+		return false
+	}
+}
+
+// clearAppResync is called when a reconcile of the app starts, a resync requested after this
+// point queues the app again.
+func clearAppResync(nn types.NamespacedName) {
+	appResyncMu.Lock()
+	defer appResyncMu.Unlock()
+	delete(pendingAppResyncs, nn)
+}
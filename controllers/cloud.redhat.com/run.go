@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"os"
+	"time"
 
 	sub "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/metrics/subscriptions"
 	cyndi "github.com/RedHatInsights/cyndi-operator/api/v1alpha1"
@@ -15,6 +16,7 @@ import (
 	"k8s.io/apimachinery/pkg/runtime/schema"
 
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/envcache"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/hashcache"
 	"github.com/prometheus/client_golang/prometheus"
 
@@ -22,7 +24,7 @@ import (
 	core "k8s.io/api/core/v1"
 	"k8s.io/client-go/rest"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
@@ -40,6 +42,9 @@ var (
 	CacheConfig   *rc.CacheConfig
 	DebugOptions  rc.DebugOptions
 	ProtectedGVKs = make(map[schema.GroupVersionKind]bool)
+	// sharedEnvObjects holds the objects read by the providers of every app in an environment,
+	// they are reused by the apps reconciled in the same cycle.
+	sharedEnvObjects = envcache.NewCache(envcache.DefaultWindow)
 )
 
 func init() {
@@ -77,6 +82,40 @@ func printConfig() error {
 	return nil
 }
 
+// setClientRateLimits overrides the client-go request rate limits of the manager's clients with the
+// ones from the Clowder config, if set.
+func setClientRateLimits(config *rest.Config) {
+	settings := clowderconfig.LoadedConfig.Settings
+	if settings.ClientQPS > 0 {
+		config.QPS = settings.ClientQPS
+	}
+	if settings.ClientBurst > 0 {
+		config.Burst = settings.ClientBurst
+	}
+	setupLog.Info("Client rate limits", "qps", config.QPS, "burst", config.Burst)
+}
+
+// resyncPeriod returns the configured resync period of the informers, nil leaves the
+// controller-runtime default in place.
+func resyncPeriod() *time.Duration {
+	seconds := clowderconfig.LoadedConfig.Settings.ResyncPeriodSeconds
+	if seconds <= 0 {
+		return nil
+	}
+	period := time.Duration(seconds) * time.Second
+	setupLog.Info("Resync period", "period", period.String())
+	return &period
+}
+
+// maxConcurrentReconciles returns the configured number of parallel reconciles of a controller,
+// which is at least 1.
+func maxConcurrentReconciles(configured int) int {
+	if configured < 1 {
+		return 1
+	}
+	return configured
+}
+
 // Run inits the manager and controllers and then starts the manager
 func Run(signalHandler context.Context, metricsAddr string, probeAddr string, enableLeaderElection bool, config *rest.Config, enableWebHooks bool) {
 	err := printConfig()
@@ -85,8 +124,21 @@ func Run(signalHandler context.Context, metricsAddr string, probeAddr string, en
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := setupTracing(clowderconfig.TracingEndpoint(), config)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
+
 	clowderVersion.With(prometheus.Labels{"version": Version}).Inc()
 
+	setClientRateLimits(config)
+
 	mgr, err := ctrl.NewManager(config, ctrl.Options{
 		Scheme:                 Scheme,
 		MetricsBindAddress:     metricsAddr,
@@ -94,6 +146,7 @@ func Run(signalHandler context.Context, metricsAddr string, probeAddr string, en
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "068b0003.cloud.redhat.com",
+		SyncPeriod:             resyncPeriod(),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to create manager")
@@ -104,18 +157,16 @@ func Run(signalHandler context.Context, metricsAddr string, probeAddr string, en
 		os.Exit(1)
 	}
 
+	setAPIClient(mgr.GetClient())
+
 	// +kubebuilder:scaffold:builder
 
 	if err := setupWebhooks(mgr, enableWebHooks); err != nil {
 		os.Exit(1)
 	}
 
-	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
-	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+	if err := addProbes(mgr); err != nil {
+		setupLog.Error(err, "unable to set up health and ready checks")
 		os.Exit(1)
 	}
 
@@ -131,20 +182,31 @@ func addControllersToManager(mgr manager.Manager) error {
 	AppHashCache := hashcache.NewHashCache()
 	EnvHashCache := hashcache.NewHashCache()
 
+	var referencedSecretCache cache.Cache
+	if clowderconfig.LoadedConfig.Features.WatchReferencedSecrets {
+		var err error
+		if referencedSecretCache, err = newReferencedSecretCache(mgr); err != nil {
+			setupLog.Error(err, "unable to create the cache of referenced secrets")
+			return err
+		}
+	}
+
 	if err := (&ClowdAppReconciler{
-		Client:    mgr.GetClient(),
-		Log:       ctrl.Log.WithName("controllers").WithName("ClowdApp"),
-		Scheme:    mgr.GetScheme(),
-		HashCache: &AppHashCache,
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("ClowdApp"),
+		Scheme:            mgr.GetScheme(),
+		HashCache:         &AppHashCache,
+		ReferencedSecrets: referencedSecretCache,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ClowdApp")
 		return err
 	}
 	if err := (&ClowdEnvironmentReconciler{
-		Client:    mgr.GetClient(),
-		Log:       ctrl.Log.WithName("controllers").WithName("ClowdEnvironment"),
-		Scheme:    mgr.GetScheme(),
-		HashCache: &EnvHashCache,
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("ClowdEnvironment"),
+		Scheme:            mgr.GetScheme(),
+		HashCache:         &EnvHashCache,
+		ReferencedSecrets: referencedSecretCache,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ClowdEnvironment")
 		return err
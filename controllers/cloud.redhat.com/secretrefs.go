@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"sync"
+
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// referencedSecretLabel is applied to the secrets that are read by an app or an environment but
+// not owned by Clowder, only the secrets carrying it are watched for changes.
+const referencedSecretLabel = "cloud.redhat.com/clowder-referenced"
+
+// secretRefIndex records which ClowdApps and ClowdEnvironments read which secrets, such as the
+// cloudwatch secret or the managed kafka secret. These secrets are not owned by Clowder, so a
+// change to one of them would otherwise only be picked up by the next resync. The references of
+// an object are replaced every time its providers run.
+type secretRefIndex struct {
+	mu sync.Mutex
+	// appRefs and envRefs map each secret to the objects reading it
+	appRefs map[types.NamespacedName]map[types.NamespacedName]bool
+	envRefs map[types.NamespacedName]map[string]bool
+	// appSecrets and envSecrets map each object to the secrets it read
+	appSecrets map[types.NamespacedName][]types.NamespacedName
+	envSecrets map[string][]types.NamespacedName
+}
+
+func newSecretRefIndex() *secretRefIndex {
+	return &secretRefIndex{
+		appRefs:    map[types.NamespacedName]map[types.NamespacedName]bool{},
+		envRefs:    map[types.NamespacedName]map[string]bool{},
+		appSecrets: map[types.NamespacedName][]types.NamespacedName{},
+		envSecrets: map[string][]types.NamespacedName{},
+	}
+}
+
+var referencedSecrets = newSecretRefIndex()
+
+// setApp replaces the secrets read by the app, an empty list forgets the app.
+func (s *secretRefIndex) setApp(app types.NamespacedName, secrets []types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, secret := range s.appSecrets[app] {
+		delete(s.appRefs[secret], app)
+		if len(s.appRefs[secret]) == 0 {
+			delete(s.appRefs, secret)
+		}
+	}
+	delete(s.appSecrets, app)
+
+	if len(secrets) == 0 {
+		return
+	}
+	s.appSecrets[app] = secrets
+	for _, secret := range secrets {
+		if s.appRefs[secret] == nil {
+			s.appRefs[secret] = map[types.NamespacedName]bool{}
+		}
+		s.appRefs[secret][app] = true
+	}
+}
+
+// setEnv replaces the secrets read by the environment, an empty list forgets the environment.
+func (s *secretRefIndex) setEnv(env string, secrets []types.NamespacedName) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, secret := range s.envSecrets[env] {
+		delete(s.envRefs[secret], env)
+		if len(s.envRefs[secret]) == 0 {
+			delete(s.envRefs, secret)
+		}
+	}
+	delete(s.envSecrets, env)
+
+	if len(secrets) == 0 {
+		return
+	}
+	s.envSecrets[env] = secrets
+	for _, secret := range secrets {
+		if s.envRefs[secret] == nil {
+			s.envRefs[secret] = map[string]bool{}
+		}
+		s.envRefs[secret][env] = true
+	}
+}
+
+// referenced reports whether any app or environment read the secret.
+func (s *secretRefIndex) referenced(secret types.NamespacedName) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.appRefs[secret]) > 0 || len(s.envRefs[secret]) > 0
+}
+
+// appRequests returns a request for each app that read the secret.
+func (s *secretRefIndex) appRequests(secret types.NamespacedName) []reconcile.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs := []reconcile.Request{}
+	for app := range s.appRefs[secret] {
+		reqs = append(reqs, reconcile.Request{NamespacedName: app})
+	}
+	return reqs
+}
+
+// envRequests returns a request for each environment that read the secret.
+func (s *secretRefIndex) envRequests(secret types.NamespacedName) []reconcile.Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reqs := []reconcile.Request{}
+	for env := range s.envRefs[secret] {
+		reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: env}})
+	}
+	return reqs
+}
+
+// referencedSecretPredicate only passes the events of secrets that an app or an environment read,
+// the events of every other secret in the cluster are dropped before they are mapped.
+func referencedSecretPredicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(o client.Object) bool {
+		if _, ok := o.(*core.Secret); !ok {
+			return false
+		}
+		return referencedSecrets.referenced(types.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()})
+	})
+}
+
+// appsReadingSecret maps a changed secret to the apps that read it. The secret is dropped from the
+// objects shared by the apps of an environment first, so that the apps read the new version, and
+// the provider backoff of the apps is cleared, as the change may fix the failing provider.
+func appsReadingSecret(o client.Object) []reconcile.Request {
+	sharedEnvObjects.InvalidateObject(o)
+	reqs := referencedSecrets.appRequests(types.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()})
+	for _, req := range reqs {
+		appProviderBackoffs.clear(req.NamespacedName)
+	}
+	return reqs
+}
+
+// envsReadingSecret maps a changed secret to the environments that read it.
+func envsReadingSecret(o client.Object) []reconcile.Request {
+	sharedEnvObjects.InvalidateObject(o)
+	return referencedSecrets.envRequests(types.NamespacedName{Name: o.GetName(), Namespace: o.GetNamespace()})
+}
+
+// newReferencedSecretCache returns a cache holding only the secrets labelled as referenced, so that
+// they are watched without watching every secret in the cluster. The cache is started by the
+// manager.
+func newReferencedSecretCache(mgr manager.Manager) (cache.Cache, error) {
+	secretCache, err := cache.New(mgr.GetConfig(), cache.Options{
+		Scheme: mgr.GetScheme(),
+		Mapper: mgr.GetRESTMapper(),
+		SelectorsByObject: cache.SelectorsByObject{
+			&core.Secret{}: {Label: labels.SelectorFromSet(labels.Set{referencedSecretLabel: "true"})},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := mgr.Add(secretCache); err != nil {
+		return nil, err
+	}
+	return secretCache, nil
+}
+
+// labelReferencedSecrets labels the secrets read by the providers so that they are watched. A
+// secret that doesn't exist yet can't be labelled, its creation is picked up when the object that
+// reads it is next retried.
+func labelReferencedSecrets(ctx context.Context, pClient client.Client, secrets []types.NamespacedName) error {
+	for _, nn := range secrets {
+		secret := &core.Secret{}
+		if err := pClient.Get(ctx, nn, secret); err != nil {
+			if k8serr.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+		if secret.Labels[referencedSecretLabel] == "true" {
+			continue
+		}
+
+		patch := client.MergeFrom(secret.DeepCopy())
+		if secret.Labels == nil {
+			secret.Labels = map[string]string{}
+		}
+		secret.Labels[referencedSecretLabel] = "true"
+		if err := pClient.Patch(ctx, secret, patch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSecretRefIndex(t *testing.T) {
+	index := newSecretRefIndex()
+
+	cloudwatch := types.NamespacedName{Name: "cloudwatch", Namespace: "test"}
+	managedKafka := types.NamespacedName{Name: "managed-kafka", Namespace: "kafka-secrets"}
+	puptoo := types.NamespacedName{Name: "puptoo", Namespace: "test"}
+	inventory := types.NamespacedName{Name: "inventory", Namespace: "test"}
+
+	index.setApp(puptoo, []types.NamespacedName{cloudwatch, managedKafka})
+	index.setApp(inventory, []types.NamespacedName{cloudwatch})
+	index.setEnv("env", []types.NamespacedName{managedKafka})
+
+	assert.ElementsMatch(t, []reconcile.Request{{NamespacedName: puptoo}, {NamespacedName: inventory}}, index.appRequests(cloudwatch))
+	assert.Empty(t, index.envRequests(cloudwatch))
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "env"}}}, index.envRequests(managedKafka))
+
+	// the references of an app are replaced when its providers run again
+	index.setApp(puptoo, []types.NamespacedName{cloudwatch})
+	assert.Empty(t, index.appRequests(managedKafka))
+	assert.True(t, index.referenced(managedKafka), "still read by the environment")
+
+	// forgetting the objects drops the secrets nobody reads any more
+	index.setEnv("env", nil)
+	assert.False(t, index.referenced(managedKafka))
+	index.setApp(puptoo, nil)
+	index.setApp(inventory, nil)
+	assert.False(t, index.referenced(cloudwatch))
+	assert.False(t, index.referenced(types.NamespacedName{Name: "unrelated", Namespace: "test"}))
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	apps "k8s.io/api/apps/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// EnvStats are the counts of the resources Clowder has provisioned for a ClowdEnvironment, as
+// exposed by the internal API.
+type EnvStats struct {
+	ClowdApps   int `json:"clowdApps"`
+	Deployments int `json:"deployments"`
+	Services    int `json:"services"`
+	KafkaTopics int `json:"kafkaTopics"`
+	Secrets     int `json:"secrets"`
+}
+
+// getEnvStats counts the resources owned by the ClowdEnvironment or by any of its ClowdApps.
+func getEnvStats(ctx context.Context, pClient client.Client, env *crd.ClowdEnvironment) (EnvStats, error) {
+	stats := EnvStats{}
+
+	envApps, namespaces, owners, err := getEnvScope(ctx, pClient, env)
+	if err != nil {
+		return stats, err
+	}
+	stats.ClowdApps = len(envApps)
+
+	counts := []struct {
+		count *int
+		list  client.ObjectList
+	}{
+		{&stats.Deployments, &apps.DeploymentList{}},
+		{&stats.Services, &core.ServiceList{}},
+		{&stats.Secrets, &core.SecretList{}},
+		{&stats.KafkaTopics, &strimzi.KafkaTopicList{}},
+	}
+
+	for _, c := range counts {
+		if *c.count, err = countOwned(ctx, pClient, c.list, namespaces, owners); err != nil {
+			return stats, err
+		}
+	}
+
+	return stats, nil
+}
+
+// getEnvScope returns the ClowdApps of the ClowdEnvironment, the namespaces holding the resources
+// provisioned for it and the UIDs of the environment and its apps, which own those resources.
+func getEnvScope(ctx context.Context, pClient client.Client, env *crd.ClowdEnvironment) ([]crd.ClowdApp, []string, map[types.UID]bool, error) {
+	appList, err := env.GetAppsInEnv(ctx, pClient)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	envApps := []crd.ClowdApp{}
+	owners := map[types.UID]bool{env.GetUID(): true}
+	for _, app := range appList.Items {
+		// the field selector is not honoured by every client, so check the env again
+		if app.Spec.EnvName != env.Name {
+			continue
+		}
+		owners[app.GetUID()] = true
+		envApps = append(envApps, app)
+	}
+
+	envNamespaces, err := env.GetNamespacesInEnv(ctx, pClient)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// listing with an empty namespace would list across all namespaces
+	seen := map[string]bool{"": true}
+	namespaces := []string{}
+	for _, namespace := range envNamespaces {
+		if !seen[namespace] {
+			seen[namespace] = true
+			namespaces = append(namespaces, namespace)
+		}
+	}
+
+	return envApps, namespaces, owners, nil
+}
+
+// countOwned counts the objects of the given list type in the namespaces that are owned by any of
+// the owners. A kind that is not installed in the cluster, like KafkaTopics without Strimzi, is
+// counted as zero.
+func countOwned(ctx context.Context, pClient client.Client, list client.ObjectList, namespaces []string, owners map[types.UID]bool) (int, error) {
+	count := 0
+	err := eachOwned(ctx, pClient, list, namespaces, owners, func(o client.Object) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// eachOwned calls fn for each object of the given list type in the namespaces that is owned by any
+// of the owners. A kind that is not installed in the cluster is skipped.
+func eachOwned(ctx context.Context, pClient client.Client, list client.ObjectList, namespaces []string, owners map[types.UID]bool, fn func(client.Object) error) error {
+	for _, namespace := range namespaces {
+		if err := pClient.List(ctx, list, client.InNamespace(namespace)); err != nil {
+			if meta.IsNoMatchError(err) {
+				return nil
+			}
+			return err
+		}
+
+		err := meta.EachListItem(list, func(obj runtime.Object) error {
+			o, ok := obj.(client.Object)
+			if !ok {
+				return nil
+			}
+			for _, owner := range o.GetOwnerReferences() {
+				if owners[owner.UID] {
+					return fn(o)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	errlib "errors"
 	"fmt"
 	"reflect"
 	"sort"
@@ -12,10 +13,12 @@ import (
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/object"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/kafka"
 	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -39,6 +42,23 @@ func deploymentStatusChecker(deployment apps.Deployment) bool {
 	return false
 }
 
+// statefulSetStatusChecker returns true once all the replicas of the current revision of the
+// statefulset are ready.
+func statefulSetStatusChecker(statefulSet apps.StatefulSet) bool {
+	if statefulSet.Generation > statefulSet.Status.ObservedGeneration {
+		// The status on this resource needs to update
+		return false
+	}
+
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	return statefulSet.Status.UpdateRevision == statefulSet.Status.CurrentRevision &&
+		statefulSet.Status.ReadyReplicas >= replicas
+}
+
 func kafkaStatusChecker(kafka strimzi.Kafka) bool {
 	// nil checks needed since these are all pointers in strimzi-client-go
 	if kafka.Status == nil {
@@ -141,6 +161,50 @@ func countDeployments(ctx context.Context, pClient client.Client, o object.Clowd
 	return managedDeployments, readyDeployments, msg, nil
 }
 
+// countStatefulSets counts the statefulsets rendered for deployments of the statefulset kind,
+// which are reported alongside the other deployments of the app.
+func countStatefulSets(ctx context.Context, pClient client.Client, o object.ClowdObject, namespaces []string) (int32, int32, string, error) {
+	var managedStatefulSets int32
+	var readyStatefulSets int32
+	var brokenStatefulSets []string
+	var msg = ""
+
+	statefulSets := []apps.StatefulSet{}
+	for _, namespace := range namespaces {
+		opts := []client.ListOption{
+			client.InNamespace(namespace),
+		}
+		tmpStatefulSets := apps.StatefulSetList{}
+		err := pClient.List(ctx, &tmpStatefulSets, opts...)
+		if err != nil {
+			return 0, 0, "", err
+		}
+		statefulSets = append(statefulSets, tmpStatefulSets.Items...)
+	}
+
+	// filter for resources owned by the ClowdObject and check their status
+	for _, statefulSet := range statefulSets {
+		for _, owner := range statefulSet.GetOwnerReferences() {
+			if owner.UID == o.GetUID() {
+				managedStatefulSets++
+				if ok := statefulSetStatusChecker(statefulSet); ok {
+					readyStatefulSets++
+				} else {
+					brokenStatefulSets = append(brokenStatefulSets, fmt.Sprintf("%s/%s", statefulSet.Name, statefulSet.Namespace))
+				}
+				break
+			}
+		}
+	}
+
+	if len(brokenStatefulSets) > 0 {
+		sort.Strings(brokenStatefulSets)
+		msg = fmt.Sprintf("broken statefulsets: [%s]", strings.Join(brokenStatefulSets, ", "))
+	}
+
+	return managedStatefulSets, readyStatefulSets, msg, nil
+}
+
 func countKafkas(ctx context.Context, pClient client.Client, o object.ClowdObject, namespaces []string) (int32, int32, string, error) {
 	var managedKafkas int32
 	var readyKafka int32
@@ -317,16 +381,6 @@ func GetEnvResourceFigures(ctx context.Context, client client.Client, o *crd.Clo
 			msgs = append(msgs, msg)
 		}
 
-		managedDeployments, readyDeployments, msg, err = countKafkaConnects(ctx, client, o, namespaces)
-		if err != nil {
-			return crd.EnvResourceStatus{}, "", err
-		}
-		totalManagedDeployments += managedDeployments
-		totalReadyDeployments += readyDeployments
-		if msg != "" {
-			msgs = append(msgs, msg)
-		}
-
 		managedTopics, readyTopics, msg, err := countKafkaTopics(ctx, client, o, namespaces)
 		if err != nil {
 			return crd.EnvResourceStatus{}, "", err
@@ -394,12 +448,269 @@ func GetAppResourceFigures(ctx context.Context, client client.Client, o *crd.Clo
 		msgs = append(msgs, msg)
 	}
 
+	managedStatefulSets, readyStatefulSets, msg, err := countStatefulSets(ctx, client, o, namespaces)
+	if err != nil {
+		return crd.AppResourceStatus{}, "", errors.Wrap("count statefulsets: ", err)
+	}
+	totalManagedDeployments += managedStatefulSets
+	totalReadyDeployments += readyStatefulSets
+	if msg != "" {
+		msgs = append(msgs, msg)
+	}
+
 	msg = fmt.Sprintf("dependency failure: [%s]", strings.Join(msgs, ","))
 	deploymentStats.ManagedDeployments = totalManagedDeployments
 	deploymentStats.ReadyDeployments = totalReadyDeployments
 	return deploymentStats, msg, nil
 }
 
+// GetEnvKafkaConnectStatus reports whether the environment is still waiting on its KafkaConnect
+// cluster. Connect readiness is tracked apart from the other resources of the environment, as
+// topics and broker configuration do not depend on it and apps should not be held back by it.
+func GetEnvKafkaConnectStatus(ctx context.Context, client client.Client, o *crd.ClowdEnvironment) (bool, string, error) {
+	switch o.Spec.Providers.Kafka.Mode {
+	case "operator", "managed-ephem":
+	default:
+		return false, "", nil
+	}
+
+	namespaces, err := o.GetNamespacesInEnv(ctx, client)
+	if err != nil {
+		return false, "", err
+	}
+
+	managedConnects, readyConnects, msg, err := countKafkaConnects(ctx, client, o, namespaces)
+	if err != nil {
+		return false, "", err
+	}
+	return managedConnects != readyConnects, msg, nil
+}
+
+// GetAppBackendStatus reports whether the backends that Clowder provisions for the app are ready,
+// that is the Kafka cluster and the topics of the app in operator mode and the local database of
+// the app. Backends of the other modes are not provisioned by Clowder and are not waited on.
+func GetAppBackendStatus(ctx context.Context, pClient client.Client, app *crd.ClowdApp, env *crd.ClowdEnvironment) (bool, string, error) {
+	var waiting []string
+
+	if env.Spec.Providers.Kafka.Mode == "operator" {
+		nn := kafka.ClusterNamespacedName(env)
+
+		cluster := strimzi.Kafka{}
+		if err := pClient.Get(ctx, nn, &cluster); err != nil && !k8serr.IsNotFound(err) {
+			return false, "", err
+		}
+		if !kafkaStatusChecker(cluster) {
+			waiting = append(waiting, fmt.Sprintf("kafka %s/%s", nn.Name, nn.Namespace))
+		}
+
+		topics, err := GetAppKafkaTopicStatus(ctx, pClient, app, env, nil, v1.Now())
+		if err != nil {
+			return false, "", err
+		}
+
+		for _, topic := range topics {
+			if !topic.Ready {
+				waiting = append(waiting, fmt.Sprintf("topic %s/%s", topic.Name, nn.Namespace))
+			}
+		}
+	}
+
+	if env.Spec.Providers.Database.Mode == "local" && (app.Spec.Database.Name != "" || app.Spec.Database.SharedDBAppName != "") {
+		dbApp := app
+		if app.Spec.Database.SharedDBAppName != "" {
+			refApp, err := crd.GetAppForDBInSameEnv(ctx, pClient, app)
+			if err != nil {
+				return false, "", err
+			}
+			dbApp = refApp
+		}
+
+		nn := types.NamespacedName{
+			Name:      fmt.Sprintf("%s-db", dbApp.Name),
+			Namespace: dbApp.Namespace,
+		}
+
+		deployment := apps.Deployment{}
+		if err := pClient.Get(ctx, nn, &deployment); err != nil && !k8serr.IsNotFound(err) {
+			return false, "", err
+		}
+		if !deploymentStatusChecker(deployment) {
+			waiting = append(waiting, fmt.Sprintf("database %s/%s", nn.Name, nn.Namespace))
+		}
+	}
+
+	if len(waiting) > 0 {
+		return false, fmt.Sprintf("waiting on backends: [%s]", strings.Join(waiting, ", ")), nil
+	}
+	return true, "", nil
+}
+
+// topicProvisioningTimeout is how long the topics of an app may stay not ready before the app
+// stops being requeued for them, the topic operator may never report on a topic.
+const topicProvisioningTimeout = 10 * time.Minute
+
+// The bounds of the delay before an app with topics that are not ready is checked again, the
+// delay grows with the time the topics have been pending.
+const (
+	minTopicRequeueDelay = 5 * time.Second
+	maxTopicRequeueDelay = 2 * time.Minute
+)
+
+// TopicProvisioningTimeoutReason is the reason of the KafkaTopicsReady condition of a ClowdApp
+// whose topics were not marked ready within the topicProvisioningTimeout.
+const TopicProvisioningTimeoutReason = "TopicProvisioningTimeout"
+
+// TopicsNotReadyReason is the reason of the KafkaTopicsReady condition of a ClowdApp whose topics
+// are still being provisioned.
+const TopicsNotReadyReason = "TopicsNotReady"
+
+// GetAppKafkaTopicStatus reads back the status of the KafkaTopics created for the topics of the
+// app in operator mode. The time a topic was first seen not ready is carried over from the
+// previous status of the app.
+func GetAppKafkaTopicStatus(ctx context.Context, pClient client.Client, app *crd.ClowdApp, env *crd.ClowdEnvironment, previous []crd.KafkaTopicStatus, now v1.Time) ([]crd.KafkaTopicStatus, error) {
+	if env.Spec.Providers.Kafka.Mode != "operator" || len(app.Spec.KafkaTopics) == 0 {
+		return nil, nil
+	}
+
+	pendingSince := map[string]*v1.Time{}
+	for _, status := range previous {
+		if status.PendingSince != nil {
+			pendingSince[status.Name] = status.PendingSince
+		}
+	}
+
+	namespace := kafka.ClusterNamespacedName(env).Namespace
+
+	statuses := []crd.KafkaTopicStatus{}
+	for _, topic := range app.Spec.KafkaTopics {
+		status := crd.KafkaTopicStatus{
+			TopicName: topic.TopicName,
+			Name:      kafka.GetTopicName(topic, *env, app.Namespace),
+		}
+
+		kafkaTopic := strimzi.KafkaTopic{}
+		err := pClient.Get(ctx, types.NamespacedName{Name: status.Name, Namespace: namespace}, &kafkaTopic)
+		if err != nil && !k8serr.IsNotFound(err) {
+			return nil, err
+		}
+
+		if err != nil {
+			status.Message = "KafkaTopic has not been created"
+		} else if status.Ready = kafkaTopicStatusChecker(kafkaTopic); !status.Ready {
+			status.Message = kafkaTopicNotReadyMessage(kafkaTopic)
+		}
+
+		if !status.Ready {
+			status.PendingSince = pendingSince[status.Name]
+			if status.PendingSince == nil {
+				status.PendingSince = now.DeepCopy()
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// kafkaTopicNotReadyMessage returns the message of the Ready condition of a KafkaTopic that is not
+// ready, if the topic operator has set one.
+func kafkaTopicNotReadyMessage(topic strimzi.KafkaTopic) string {
+	if topic.Status != nil {
+		for _, condition := range topic.Status.Conditions {
+			if condition.Type != nil && *condition.Type == "Ready" && condition.Message != nil && *condition.Message != "" {
+				return *condition.Message
+			}
+		}
+	}
+	return "Waiting for the topic operator"
+}
+
+// kafkaTopicsReady returns true if every topic of the statuses is ready.
+func kafkaTopicsReady(statuses []crd.KafkaTopicStatus) bool {
+	for _, status := range statuses {
+		if !status.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// kafkaTopicsTimedOut returns the topics of the statuses that have been pending for longer than
+// the topicProvisioningTimeout.
+func kafkaTopicsTimedOut(statuses []crd.KafkaTopicStatus, now v1.Time) []string {
+	timedOut := []string{}
+	for _, status := range statuses {
+		if !status.Ready && status.PendingSince != nil && now.Sub(status.PendingSince.Time) >= topicProvisioningTimeout {
+			timedOut = append(timedOut, status.Name)
+		}
+	}
+	return timedOut
+}
+
+// kafkaTopicsCondition aggregates the statuses of the topics of an app into its KafkaTopicsReady
+// condition.
+func kafkaTopicsCondition(statuses []crd.KafkaTopicStatus, now v1.Time) clusterv1.Condition {
+	condition := clusterv1.Condition{
+		Type:               crd.KafkaTopicsReady,
+		Status:             core.ConditionTrue,
+		Message:            "All kafka topics ready",
+		LastTransitionTime: now,
+	}
+
+	if kafkaTopicsReady(statuses) {
+		return condition
+	}
+
+	pending := []string{}
+	for _, status := range statuses {
+		if !status.Ready {
+			pending = append(pending, fmt.Sprintf("%s (%s)", status.Name, status.Message))
+		}
+	}
+
+	condition.Status = core.ConditionFalse
+	condition.Reason = TopicsNotReadyReason
+	condition.Message = fmt.Sprintf("topics not ready: [%s]", strings.Join(pending, ", "))
+
+	if timedOut := kafkaTopicsTimedOut(statuses, now); len(timedOut) > 0 {
+		condition.Reason = TopicProvisioningTimeoutReason
+		condition.Message = fmt.Sprintf("topics not ready after %s: [%s]", topicProvisioningTimeout, strings.Join(timedOut, ", "))
+	}
+	return condition
+}
+
+// kafkaTopicRequeueDelay returns how long to wait before checking on the pending topics of the
+// statuses again, zero if no topic is pending or all of them have timed out. The delay is the
+// time the topics have been pending, so that the checks back off exponentially.
+func kafkaTopicRequeueDelay(statuses []crd.KafkaTopicStatus, now v1.Time) time.Duration {
+	delay := time.Duration(0)
+	for _, status := range statuses {
+		if status.Ready || status.PendingSince == nil {
+			continue
+		}
+
+		pending := now.Sub(status.PendingSince.Time)
+		if pending >= topicProvisioningTimeout {
+			continue
+		}
+
+		topicDelay := pending
+		if topicDelay < minTopicRequeueDelay {
+			topicDelay = minTopicRequeueDelay
+		} else if topicDelay > maxTopicRequeueDelay {
+			topicDelay = maxTopicRequeueDelay
+		}
+		if remaining := topicProvisioningTimeout - pending; topicDelay > remaining {
+			topicDelay = remaining
+		}
+
+		if delay == 0 || topicDelay < delay {
+			delay = topicDelay
+		}
+	}
+	return delay
+}
+
 func GetEnvResourceStatus(ctx context.Context, client client.Client, o *crd.ClowdEnvironment) (bool, string, error) {
 	stats, msg, err := GetEnvResourceFigures(ctx, client, o)
 	if err != nil {
@@ -431,6 +742,23 @@ func SetClowdEnvConditions(ctx context.Context, client client.Client, o *crd.Clo
 		conditions = append(conditions, *condition)
 	}
 
+	connectWaiting, connectMsg, err := GetEnvKafkaConnectStatus(ctx, client, o)
+	if err != nil {
+		return err
+	}
+
+	connectCondition := &clusterv1.Condition{}
+	connectCondition.Type = crd.WaitingForKafkaConnect
+	connectCondition.Status = core.ConditionFalse
+
+	if connectWaiting {
+		connectCondition.Status = core.ConditionTrue
+		connectCondition.Reason = connectMsg
+	}
+
+	connectCondition.LastTransitionTime = v1.Now()
+	conditions = append(conditions, *connectCondition)
+
 	deploymentStatus, msg, err := GetEnvResourceStatus(ctx, client, o)
 	if err != nil {
 		return err
@@ -488,11 +816,116 @@ func SetClowdAppConditions(ctx context.Context, client client.Client, o *crd.Clo
 		conditions = append(conditions, *condition)
 	}
 
+	secretCondition := &clusterv1.Condition{}
+	secretCondition.Type = crd.SecretNotFound
+	secretCondition.Status = core.ConditionFalse
+
+	var missingSecrets *errors.MissingSecrets
+	if errlib.As(err, &missingSecrets) {
+		secretCondition.Status = core.ConditionTrue
+		secretCondition.Reason = missingSecrets.Error()
+	}
+
+	secretCondition.LastTransitionTime = v1.Now()
+	conditions = append(conditions, *secretCondition)
+
+	configMapCondition := &clusterv1.Condition{}
+	configMapCondition.Type = crd.ConfigMapNotFound
+	configMapCondition.Status = core.ConditionFalse
+
+	var missingConfigMaps *errors.MissingConfigMaps
+	if errlib.As(err, &missingConfigMaps) {
+		configMapCondition.Status = core.ConditionTrue
+		configMapCondition.Reason = missingConfigMaps.Error()
+	}
+
+	configMapCondition.LastTransitionTime = v1.Now()
+	conditions = append(conditions, *configMapCondition)
+
+	triggerCondition := &clusterv1.Condition{}
+	triggerCondition.Type = crd.AutoScalerTriggersInvalid
+	triggerCondition.Status = core.ConditionFalse
+
+	var invalidTriggers *errors.InvalidAutoScalerTriggers
+	if errlib.As(err, &invalidTriggers) {
+		triggerCondition.Status = core.ConditionTrue
+		triggerCondition.Reason = invalidTriggers.Error()
+	}
+
+	triggerCondition.LastTransitionTime = v1.Now()
+	conditions = append(conditions, *triggerCondition)
+
+	partitionCondition := &clusterv1.Condition{}
+	partitionCondition.Type = crd.KafkaTopicPartitionsDecreased
+	partitionCondition.Status = core.ConditionFalse
+
+	var partitionDecrease *errors.TopicPartitionDecrease
+	if errlib.As(err, &partitionDecrease) {
+		partitionCondition.Status = core.ConditionTrue
+		partitionCondition.Reason = partitionDecrease.Error()
+	}
+
+	partitionCondition.LastTransitionTime = v1.Now()
+	conditions = append(conditions, *partitionCondition)
+
+	singletonCondition := &clusterv1.Condition{}
+	singletonCondition.Type = crd.SingletonConflict
+	singletonCondition.Status = core.ConditionFalse
+
+	var invalidSingleton *errors.InvalidSingleton
+	if errlib.As(err, &invalidSingleton) {
+		singletonCondition.Status = core.ConditionTrue
+		singletonCondition.Reason = invalidSingleton.Error()
+	}
+
+	singletonCondition.LastTransitionTime = v1.Now()
+	conditions = append(conditions, *singletonCondition)
+
+	providerCondition := &clusterv1.Condition{}
+	providerCondition.Type = crd.ProviderFailed
+	providerCondition.Status = core.ConditionFalse
+
+	var providerErr *errors.ProviderError
+	if errlib.As(err, &providerErr) {
+		providerCondition.Status = core.ConditionTrue
+		providerCondition.Reason = TransientProviderErrorReason
+		if providerErr.Permanent {
+			providerCondition.Reason = PermanentProviderErrorReason
+		}
+		providerCondition.Message = providerErr.Error()
+	}
+
+	providerCondition.LastTransitionTime = v1.Now()
+	conditions = append(conditions, *providerCondition)
+
+	// The dependencies are only known to be present once the providers have run to completion
+	var missingDeps *errors.MissingDependencies
+	if errlib.As(err, &missingDeps) {
+		conditions = append(conditions, clusterv1.Condition{
+			Type:               crd.DependenciesReady,
+			Status:             core.ConditionFalse,
+			Reason:             MissingDependencyReason,
+			Message:            missingDependencyMessage(missingDeps),
+			LastTransitionTime: v1.Now(),
+		})
+	} else if state == crd.ReconciliationSuccessful {
+		conditions = append(conditions, clusterv1.Condition{
+			Type:               crd.DependenciesReady,
+			Status:             core.ConditionTrue,
+			Message:            "All dependencies present",
+			LastTransitionTime: v1.Now(),
+		})
+	}
+
 	deploymentStatus, err := GetAppResourceStatus(ctx, client, o)
 	if err != nil {
 		return err
 	}
 
+	if err := setAppHealth(ctx, client, o); err != nil {
+		return err
+	}
+
 	condition := &clusterv1.Condition{}
 
 	condition.Status = core.ConditionFalse
@@ -510,14 +943,25 @@ func SetClowdAppConditions(ctx context.Context, client client.Client, o *crd.Clo
 
 	conditions = append(conditions, *condition)
 
+	if len(o.Status.KafkaTopics) > 0 {
+		conditions = append(conditions, kafkaTopicsCondition(o.Status.KafkaTopics, v1.Now()))
+	} else {
+		cond.Delete(o, crd.KafkaTopicsReady)
+		deleteAppConditionMetric(o.GetIdent(), crd.KafkaTopicsReady)
+	}
+
 	for _, condition := range conditions {
 		innerCondition := condition
 		cond.Set(o, &innerCondition)
 	}
 
-	o.Status.Ready = deploymentStatus
+	setAppConditionMetrics(o)
 
-	if !equality.Semantic.DeepEqual(*oldStatus, o.Status) {
+	o.Status.Ready = deploymentStatus && kafkaTopicsReady(o.Status.KafkaTopics)
+
+	now := v1.Now()
+	if !equality.Semantic.DeepEqual(*oldStatus, o.Status) || reconcileTimesStale(&o.Status, state, now) {
+		setReconcileTimes(&o.Status, state, now)
 		if err := client.Status().Update(ctx, o); err != nil {
 			return err
 		}
@@ -525,6 +969,49 @@ func SetClowdAppConditions(ctx context.Context, client client.Client, o *crd.Clo
 	return nil
 }
 
+// MissingDependencyReason is the reason of the DependenciesReady condition of a ClowdApp whose
+// dependencies are not all present in the environment.
+const MissingDependencyReason = "MissingDependency"
+
+// TransientProviderErrorReason and PermanentProviderErrorReason are the reasons of the
+// ProviderFailed condition of a ClowdApp, a permanent failure needs a change to the config.
+const (
+	TransientProviderErrorReason = "TransientProviderError"
+	PermanentProviderErrorReason = "PermanentProviderError"
+)
+
+func missingDependencyMessage(missingDeps *errors.MissingDependencies) string {
+	details := []string{}
+	for _, dep := range missingDeps.MissingDeps {
+		details = append(details, dep.Details)
+	}
+	return fmt.Sprintf("Dependencies not present in the environment: %s", strings.Join(details, ", "))
+}
+
+// reconcileTimeResolution is how old the reconcile times may get before they are refreshed by a
+// reconcile that changes nothing else in the status. Refreshing them on every reconcile would
+// make every status update trigger yet another reconcile.
+const reconcileTimeResolution = time.Minute
+
+// reconcileTimesStale returns true if the reconcile time that a reconcile ending in the given
+// state would set is missing or older than the reconcileTimeResolution.
+func reconcileTimesStale(status *crd.ClowdAppStatus, state clusterv1.ConditionType, now v1.Time) bool {
+	last := status.LastReconcileTime
+	if state == crd.ReconciliationSuccessful {
+		last = status.LastSuccessfulReconcileTime
+	}
+	return last == nil || now.Sub(last.Time) >= reconcileTimeResolution
+}
+
+// setReconcileTimes records a reconcile ending in the given state, only successful reconciles
+// update the LastSuccessfulReconcileTime.
+func setReconcileTimes(status *crd.ClowdAppStatus, state clusterv1.ConditionType, now v1.Time) {
+	status.LastReconcileTime = now.DeepCopy()
+	if state == crd.ReconciliationSuccessful {
+		status.LastSuccessfulReconcileTime = now.DeepCopy()
+	}
+}
+
 func SetClowdJobInvocationConditions(ctx context.Context, client client.Client, o *crd.ClowdJobInvocation, state clusterv1.ConditionType, err error) error {
 	oldStatus := o.Status.DeepCopy()
 	conditions := []clusterv1.Condition{}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
+	strimzi "github.com/RedHatInsights/strimzi-client-go/apis/kafka.strimzi.io/v1beta2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	core "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestSetReconcileTimes(t *testing.T) {
+	status := &crd.ClowdAppStatus{}
+	start := v1.NewTime(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+
+	assert.True(t, reconcileTimesStale(status, crd.ReconciliationSuccessful, start))
+	setReconcileTimes(status, crd.ReconciliationSuccessful, start)
+	assert.Equal(t, start, *status.LastReconcileTime)
+	assert.Equal(t, start, *status.LastSuccessfulReconcileTime)
+
+	// A failing provider only moves the last reconcile time on
+	failed := v1.NewTime(start.Add(2 * time.Minute))
+	assert.True(t, reconcileTimesStale(status, crd.ReconciliationFailed, failed))
+	setReconcileTimes(status, crd.ReconciliationFailed, failed)
+	assert.Equal(t, failed, *status.LastReconcileTime)
+	assert.Equal(t, start, *status.LastSuccessfulReconcileTime)
+}
+
+func TestReconcileTimesStale(t *testing.T) {
+	status := &crd.ClowdAppStatus{}
+	start := v1.NewTime(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+	setReconcileTimes(status, crd.ReconciliationFailed, start)
+
+	assert.False(t, reconcileTimesStale(status, crd.ReconciliationFailed, v1.NewTime(start.Add(time.Second))))
+	assert.True(t, reconcileTimesStale(status, crd.ReconciliationFailed, v1.NewTime(start.Add(time.Minute))))
+	// there has never been a successful reconcile
+	assert.True(t, reconcileTimesStale(status, crd.ReconciliationSuccessful, v1.NewTime(start.Add(time.Second))))
+}
+
+func TestAppConditionMetrics(t *testing.T) {
+	app := &crd.ClowdApp{
+		ObjectMeta: v1.ObjectMeta{Name: "puptoo", Namespace: "test"},
+		Spec:       crd.ClowdAppSpec{EnvName: "env"},
+		Status: crd.ClowdAppStatus{
+			Conditions: []clusterv1.Condition{
+				{Type: crd.SecretNotFound, Status: core.ConditionTrue},
+				{Type: crd.ReconciliationSuccessful, Status: core.ConditionFalse},
+			},
+		},
+	}
+
+	conditionMetric := func(conditionType clusterv1.ConditionType, status core.ConditionStatus) float64 {
+		return testutil.ToFloat64(appConditionMetrics.With(prometheus.Labels{
+			"app":    "env.puptoo",
+			"type":   string(conditionType),
+			"status": string(status),
+		}))
+	}
+
+	setAppConditionMetrics(app)
+	assert.Equal(t, 1.0, conditionMetric(crd.SecretNotFound, core.ConditionTrue))
+	assert.Equal(t, 0.0, conditionMetric(crd.SecretNotFound, core.ConditionFalse))
+	assert.Equal(t, 0.0, conditionMetric(crd.ReconciliationSuccessful, core.ConditionTrue))
+	assert.Equal(t, 1.0, conditionMetric(crd.ReconciliationSuccessful, core.ConditionFalse))
+
+	// the secret turning up flips the series rather than adding another
+	app.Status.Conditions[0].Status = core.ConditionFalse
+	setAppConditionMetrics(app)
+	assert.Equal(t, 0.0, conditionMetric(crd.SecretNotFound, core.ConditionTrue))
+	assert.Equal(t, 1.0, conditionMetric(crd.SecretNotFound, core.ConditionFalse))
+
+	// a condition dropped from the status takes its series along
+	deleteAppConditionMetric("env.puptoo", crd.SecretNotFound)
+	assert.Zero(t, appConditionMetrics.DeletePartialMatch(prometheus.Labels{"app": "env.puptoo", "type": string(crd.SecretNotFound)}))
+	assert.Equal(t, 1.0, conditionMetric(crd.ReconciliationSuccessful, core.ConditionFalse))
+
+	deleteAppConditionMetrics("env.puptoo")
+	assert.Zero(t, appConditionMetrics.DeletePartialMatch(prometheus.Labels{"app": "env.puptoo"}), "series of the deleted app were left behind")
+}
+
+func TestReconcileErrorReason(t *testing.T) {
+	singleton := &errors.InvalidSingleton{Deployment: "lock", Problems: []string{"replicas is set to 2"}}
+	assert.Equal(t, "InvalidSingleton", reconcileErrorReason(errors.Wrap("runprov: deployment", singleton)))
+
+	conflict := k8serr.NewConflict(schema.GroupResource{Resource: "clowdapps"}, "puptoo", nil)
+	assert.Equal(t, "Conflict", reconcileErrorReason(conflict))
+
+	assert.Equal(t, "Unknown", reconcileErrorReason(errors.NewClowderError("something broke")))
+}
+
+func TestAppKafkaTopicStatus(t *testing.T) {
+	env := &crd.ClowdEnvironment{
+		ObjectMeta: v1.ObjectMeta{Name: "env"},
+		Spec: crd.ClowdEnvironmentSpec{
+			Providers: crd.ProvidersConfig{
+				Kafka: crd.KafkaConfig{
+					Mode:    "operator",
+					Cluster: crd.KafkaClusterConfig{Name: "kafka", Namespace: "kafka"},
+				},
+			},
+		},
+	}
+	app := &crd.ClowdApp{
+		ObjectMeta: v1.ObjectMeta{Name: "puptoo", Namespace: "test"},
+		Spec: crd.ClowdAppSpec{
+			EnvName:     "env",
+			KafkaTopics: []crd.KafkaTopicSpec{{TopicName: "ready"}, {TopicName: "pending"}, {TopicName: "missing"}},
+		},
+	}
+
+	readyType, readyStatus := "Ready", "True"
+	notReadyStatus, notReadyMessage := "False", "Invalid config"
+	ready := &strimzi.KafkaTopic{
+		ObjectMeta: v1.ObjectMeta{Name: "ready", Namespace: "kafka"},
+		Status: &strimzi.KafkaTopicStatus{
+			Conditions: []strimzi.KafkaTopicStatusConditionsElem{{Type: &readyType, Status: &readyStatus}},
+		},
+	}
+	pending := &strimzi.KafkaTopic{
+		ObjectMeta: v1.ObjectMeta{Name: "pending", Namespace: "kafka"},
+		Status: &strimzi.KafkaTopicStatus{
+			Conditions: []strimzi.KafkaTopicStatusConditionsElem{{Type: &readyType, Status: &notReadyStatus, Message: &notReadyMessage}},
+		},
+	}
+
+	cl := fake.NewClientBuilder().WithScheme(Scheme).WithObjects(ready, pending).Build()
+
+	start := v1.NewTime(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+	statuses, err := GetAppKafkaTopicStatus(context.Background(), cl, app, env, nil, start)
+	assert.NoError(t, err)
+	if !assert.Len(t, statuses, 3) {
+		return
+	}
+	assert.Equal(t, crd.KafkaTopicStatus{TopicName: "ready", Name: "ready", Ready: true}, statuses[0])
+	assert.False(t, statuses[1].Ready)
+	assert.Equal(t, notReadyMessage, statuses[1].Message)
+	assert.Equal(t, start, *statuses[1].PendingSince)
+	assert.False(t, statuses[2].Ready)
+	assert.Equal(t, "KafkaTopic has not been created", statuses[2].Message)
+
+	// the time a topic was first seen pending is kept across reconciles
+	later := v1.NewTime(start.Add(time.Minute))
+	statuses, err = GetAppKafkaTopicStatus(context.Background(), cl, app, env, statuses, later)
+	assert.NoError(t, err)
+	assert.Equal(t, start, *statuses[1].PendingSince)
+	assert.Equal(t, start, *statuses[2].PendingSince)
+
+	env.Spec.Providers.Kafka.Mode = "app-interface"
+	statuses, err = GetAppKafkaTopicStatus(context.Background(), cl, app, env, statuses, later)
+	assert.NoError(t, err)
+	assert.Empty(t, statuses)
+}
+
+func TestKafkaTopicsCondition(t *testing.T) {
+	start := v1.NewTime(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+	statuses := []crd.KafkaTopicStatus{
+		{TopicName: "ready", Name: "ready", Ready: true},
+		{TopicName: "pending", Name: "pending", Message: "Invalid config", PendingSince: &start},
+	}
+
+	condition := kafkaTopicsCondition(statuses[:1], start)
+	assert.Equal(t, core.ConditionTrue, condition.Status)
+	assert.Equal(t, crd.KafkaTopicsReady, condition.Type)
+
+	condition = kafkaTopicsCondition(statuses, v1.NewTime(start.Add(time.Minute)))
+	assert.Equal(t, core.ConditionFalse, condition.Status)
+	assert.Equal(t, TopicsNotReadyReason, condition.Reason)
+	assert.Contains(t, condition.Message, "pending (Invalid config)")
+
+	condition = kafkaTopicsCondition(statuses, v1.NewTime(start.Add(topicProvisioningTimeout)))
+	assert.Equal(t, core.ConditionFalse, condition.Status)
+	assert.Equal(t, TopicProvisioningTimeoutReason, condition.Reason)
+}
+
+func TestKafkaTopicRequeueDelay(t *testing.T) {
+	start := v1.NewTime(time.Date(2022, 10, 1, 12, 0, 0, 0, time.UTC))
+	statuses := []crd.KafkaTopicStatus{
+		{TopicName: "ready", Name: "ready", Ready: true},
+		{TopicName: "pending", Name: "pending", PendingSince: &start},
+	}
+
+	at := func(d time.Duration) v1.Time { return v1.NewTime(start.Add(d)) }
+
+	assert.Equal(t, time.Duration(0), kafkaTopicRequeueDelay(statuses[:1], start))
+	assert.Equal(t, minTopicRequeueDelay, kafkaTopicRequeueDelay(statuses, start))
+	assert.Equal(t, 30*time.Second, kafkaTopicRequeueDelay(statuses, at(30*time.Second)))
+	assert.Equal(t, maxTopicRequeueDelay, kafkaTopicRequeueDelay(statuses, at(5*time.Minute)))
+	assert.Equal(t, time.Minute, kafkaTopicRequeueDelay(statuses, at(topicProvisioningTimeout-time.Minute)))
+	// once the topics have timed out the app is no longer requeued for them
+	assert.Equal(t, time.Duration(0), kafkaTopicRequeueDelay(statuses, at(topicProvisioningTimeout)))
+}
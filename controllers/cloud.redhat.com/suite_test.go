@@ -26,19 +26,34 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	prom "github.com/prometheus-operator/prometheus-operator/pkg/apis/monitoring/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2/clientcredentials"
 	apps "k8s.io/api/apps/v1"
 	core "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1"
+	rbac "k8s.io/api/rbac/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	cond "sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
@@ -47,6 +62,7 @@ import (
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/errors"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/kafka"
 	"github.com/RedHatInsights/rhc-osdk-utils/utils"
@@ -58,6 +74,7 @@ import (
 var k8sClient client.Client
 var testEnv *envtest.Environment
 var logger *zap.Logger
+var spanExporter *tracetest.InMemoryExporter
 
 type TestSuite struct {
 	suite.Suite
@@ -119,11 +136,21 @@ func (suite *TestSuite) SetupSuite() {
 	err = k8sClient.Create(ctx, nsSpec)
 	assert.NoError(suite.T(), err, "error creating namespace")
 
+	// Record the spans of the reconciles in memory, no tracing endpoint is configured so Run leaves
+	// this provider in place
+	spanExporter = tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spanExporter)))
+
+	// Reconcile different objects in parallel, as is done in larger clusters
+	clowderconfig.LoadedConfig.Settings.MaxConcurrentAppReconciles = 4
+	clowderconfig.LoadedConfig.Settings.MaxConcurrentEnvReconciles = 2
+	clowderconfig.LoadedConfig.Features.ValidateAppConfig = true
+
 	go Run(ctx, ":8080", ":8081", false, testEnv.Config, false)
 	go runAPITestServer()
 
 	for i := 1; i <= 50; i++ {
-		resp, err := http.Get("http://localhost:8080/metrics")
+		resp, err := http.Get("http://localhost:8080/readyz")
 
 		if err == nil && resp.StatusCode == 200 {
 			logger.Info("Manager ready", zap.Int("duration", 100*i))
@@ -135,10 +162,10 @@ func (suite *TestSuite) SetupSuite() {
 
 		if i == 50 {
 			if err != nil {
-				logger.Fatal("Failed to fetch to metrics for manager after 5s", zap.Error(err))
+				logger.Fatal("Failed to fetch readiness of manager after 5s", zap.Error(err))
 			}
 
-			logger.Fatal("Failed to get 200 result for metrics", zap.Int("status", resp.StatusCode))
+			logger.Fatal("Failed to get 200 result for readiness", zap.Int("status", resp.StatusCode))
 		}
 
 		time.Sleep(100 * time.Millisecond)
@@ -157,13 +184,9 @@ func (suite *TestSuite) TearDownSuite() {
 	}
 }
 
-func applyKafkaStatus(t *testing.T, ch chan int) {
+func applyKafkaStatus(t *testing.T, ch chan int, nn types.NamespacedName, connectReady bool) {
 	ctx := context.Background()
-	nn := types.NamespacedName{
-		Name:      "kafka",
-		Namespace: "kafka",
-	}
-	host := "kafka-bootstrap.kafka.svc"
+	host := fmt.Sprintf("kafka-bootstrap.%s.svc", nn.Namespace)
 	listenerType := "plain"
 	kport := int32(9092)
 
@@ -205,12 +228,12 @@ func applyKafkaStatus(t *testing.T, ch chan int) {
 			continue
 		}
 
+		if !connectReady {
+			break
+		}
+
 		// set a mock status on strimzi KafkaConnect cluster
 		connectCluster := strimzi.KafkaConnect{}
-		nn := types.NamespacedName{
-			Name:      "kafka",
-			Namespace: "kafka",
-		}
 		err = k8sClient.Get(ctx, nn, &connectCluster)
 
 		if err != nil {
@@ -304,9 +327,32 @@ func createClowdEnvironment(objMeta metav1.ObjectMeta) crd.ClowdEnvironment {
 	return env
 }
 
+// createTestEnvironment creates the namespace of nn and a ClowdEnvironment named after nn that
+// targets it, with the kafka and logging providers disabled. The setup func, if any, adjusts the
+// environment before it is created.
+func createTestEnvironment(t *testing.T, nn types.NamespacedName, setup func(env *crd.ClowdEnvironment)) crd.ClowdEnvironment {
+	ctx := context.Background()
+
+	err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: nn.Namespace}})
+	assert.NoError(t, err)
+
+	env := createClowdEnvironment(metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace})
+	env.Spec.Providers.Kafka = crd.KafkaConfig{Mode: "none"}
+	env.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
+	if setup != nil {
+		setup(&env)
+	}
+
+	err = k8sClient.Create(ctx, &env)
+	assert.NoError(t, err)
+	return env
+}
+
 func createClowdApp(env crd.ClowdEnvironment, objMeta metav1.ObjectMeta) (crd.ClowdApp, error) {
+	return createClowdAppFrom(env, makeClowdApp(env, objMeta))
+}
 
-	ctx := context.Background()
+func makeClowdApp(env crd.ClowdEnvironment, objMeta metav1.ObjectMeta) crd.ClowdApp {
 
 	replicas := int32(32)
 	maxReplicas := int32(64)
@@ -354,6 +400,13 @@ func createClowdApp(env crd.ClowdEnvironment, objMeta metav1.ObjectMeta) (crd.Cl
 		},
 	}
 
+	return app
+}
+
+func createClowdAppFrom(env crd.ClowdEnvironment, app crd.ClowdApp) (crd.ClowdApp, error) {
+
+	ctx := context.Background()
+
 	err := k8sClient.Create(ctx, &env)
 
 	if err != nil {
@@ -399,7 +452,12 @@ func createManagedKafkaClowderStack(name types.NamespacedName, secretNme string)
 		},
 	}
 
-	app, err := createClowdApp(env, objMeta)
+	// a single partition leaves room to increase the partitions of the topic, as they are capped
+	// to three in managed-ephem mode
+	app := makeClowdApp(env, objMeta)
+	app.Spec.KafkaTopics[0].Partitions = 1
+
+	app, err := createClowdAppFrom(env, app)
 
 	return &env, &app, err
 }
@@ -440,7 +498,7 @@ func (suite *TestSuite) TestCreateClowdApp() {
 
 	ch := make(chan int)
 
-	go applyKafkaStatus(suite.T(), ch)
+	go applyKafkaStatus(suite.T(), ch, types.NamespacedName{Name: "kafka", Namespace: "kafka"}, true)
 
 	env, app, err := createCRs(clowdAppNN)
 
@@ -504,8 +562,54 @@ func (suite *TestSuite) TestCreateClowdApp() {
 
 	kafkaValidation(suite.T(), env, app, jsonContent)
 
+	topicAllowListValidation(suite.T(), app)
+
 	clowdWatchValidation(suite.T(), jsonContent, cwData)
 
+	// a change to the cloudwatch secret is picked up without waiting for a resync
+	cwSecret := core.Secret{}
+	err = k8sClient.Get(context.Background(), types.NamespacedName{Name: "cloudwatch", Namespace: clowdAppNN.Namespace}, &cwSecret)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "true", cwSecret.Labels[referencedSecretLabel], "the cloudwatch secret was not labelled for the watch")
+	cwSecret.Data["log_group_name"] = []byte("rotated")
+	err = k8sClient.Update(context.Background(), &cwSecret)
+	assert.NoError(suite.T(), err)
+	assert.Eventually(suite.T(), func() bool {
+		jsonContent, err := fetchConfig(clowdAppNN)
+		return err == nil && jsonContent.Logging.Cloudwatch != nil && jsonContent.Logging.Cloudwatch.LogGroup == "rotated"
+	}, time.Second*15, time.Second*1, "config was not updated with the rotated cloudwatch secret")
+	cwSecret.Data["log_group_name"] = []byte(cwData["log_group_name"])
+	err = k8sClient.Update(context.Background(), &cwSecret)
+	assert.NoError(suite.T(), err)
+	assert.Eventually(suite.T(), func() bool {
+		jsonContent, err := fetchConfig(clowdAppNN)
+		return err == nil && jsonContent.Logging.Cloudwatch != nil && jsonContent.Logging.Cloudwatch.LogGroup == cwData["log_group_name"]
+	}, time.Second*15, time.Second*1, "config was not updated with the restored cloudwatch secret")
+
+	// the change also reaches an app whose provider is backing off, without waiting for the backoff
+	backingOffApp := crd.ClowdApp{}
+	err = k8sClient.Get(context.Background(), clowdAppNN, &backingOffApp)
+	assert.NoError(suite.T(), err)
+	backingOffEnv := crd.ClowdEnvironment{}
+	err = k8sClient.Get(context.Background(), types.NamespacedName{Name: env.Name}, &backingOffEnv)
+	assert.NoError(suite.T(), err)
+	appProviderBackoffs.record(&backingOffApp, &backingOffEnv, &errors.ProviderError{Provider: "logging", Permanent: true, Err: fmt.Errorf("failed")})
+	_, _, backingOff := appProviderBackoffs.remaining(&backingOffApp, &backingOffEnv)
+	assert.True(suite.T(), backingOff)
+
+	err = k8sClient.Get(context.Background(), types.NamespacedName{Name: "cloudwatch", Namespace: clowdAppNN.Namespace}, &cwSecret)
+	assert.NoError(suite.T(), err)
+	cwSecret.Data["log_group_name"] = []byte("rotated-in-backoff")
+	err = k8sClient.Update(context.Background(), &cwSecret)
+	assert.NoError(suite.T(), err)
+	assert.Eventually(suite.T(), func() bool {
+		jsonContent, err := fetchConfig(clowdAppNN)
+		return err == nil && jsonContent.Logging.Cloudwatch != nil && jsonContent.Logging.Cloudwatch.LogGroup == "rotated-in-backoff"
+	}, time.Second*15, time.Second*1, "config of the app in backoff was not updated with the rotated cloudwatch secret")
+	cwSecret.Data["log_group_name"] = []byte(cwData["log_group_name"])
+	err = k8sClient.Update(context.Background(), &cwSecret)
+	assert.NoError(suite.T(), err)
+
 	scaler := keda.ScaledObject{}
 
 	err = fetchWithDefaults(appnn, &scaler)
@@ -514,6 +618,14 @@ func (suite *TestSuite) TestCreateClowdApp() {
 
 	scaledObjectValidation(suite.T(), &scaler)
 
+	assert.Eventually(suite.T(), func() bool {
+		fetchedApp := crd.ClowdApp{}
+		if err := k8sClient.Get(context.Background(), clowdAppNN, &fetchedApp); err != nil {
+			return false
+		}
+		return fetchedApp.Status.LastReconcileTime != nil && fetchedApp.Status.LastSuccessfulReconcileTime != nil
+	}, time.Second*30, time.Second*1, "reconcile times were not set")
+
 	resp, err := http.Get("http://127.0.0.1:2019/config/")
 	assert.NoError(suite.T(), err, "failed test because get failed")
 	defer resp.Body.Close()
@@ -533,183 +645,2508 @@ func (suite *TestSuite) TestCreateClowdApp() {
 
 	assert.NoError(suite.T(), err, "failed to unmarshal")
 	assert.Contains(suite.T(), capps, "test.test", "app not present in API call")
-}
 
-func metadataValidation(t *testing.T, app *crd.ClowdApp, jsonContent *config.AppConfig) {
-	assert.Equal(t, *jsonContent.Metadata.Name, app.Name)
-	assert.Equal(t, *jsonContent.Metadata.EnvName, app.Spec.EnvName)
+	stats := map[string]EnvStats{}
+	resp, err = http.Get(fmt.Sprintf("http://127.0.0.1:2019/stats/?env=%s", env.Name))
+	assert.NoError(suite.T(), err, "failed test because get failed")
+	defer resp.Body.Close()
+	sData, _ = io.ReadAll(resp.Body)
+	err = json.Unmarshal(sData, &stats)
 
-	for _, deployment := range app.Spec.Deployments {
-		expected := config.DeploymentMetadata{
-			Name:  deployment.Name,
-			Image: deployment.PodSpec.Image,
+	assert.NoError(suite.T(), err, "failed to unmarshal")
+	if assert.Contains(suite.T(), stats, env.Name, "env not present in stats") {
+		assert.NotZero(suite.T(), stats[env.Name].ClowdApps)
+		assert.NotZero(suite.T(), stats[env.Name].Deployments)
+		assert.NotZero(suite.T(), stats[env.Name].Secrets)
+	}
+
+	resp, err = http.Get("http://127.0.0.1:2019/stats/?env=not-an-env")
+	assert.NoError(suite.T(), err, "failed test because get failed")
+	defer resp.Body.Close()
+	assert.Equal(suite.T(), http.StatusNotFound, resp.StatusCode)
+
+	health := AppHealth{}
+	fetchHealth := func() bool {
+		resp, err := http.Get("http://127.0.0.1:2019/clowdapps/health/test.test")
+		if err != nil {
+			return false
 		}
-		assert.Contains(t, jsonContent.Metadata.Deployments, expected)
+		defer resp.Body.Close()
+		sData, _ := io.ReadAll(resp.Body)
+		return json.Unmarshal(sData, &health) == nil
 	}
-	assert.Len(t, jsonContent.Metadata.Deployments, len(app.Spec.Deployments))
+
+	// Nothing runs the pods in the test env so the deployment never becomes available by itself
+	assert.True(suite.T(), fetchHealth(), "app health not available in API call")
+	assert.Equal(suite.T(), AppUnavailable, health.Status)
+	assert.Equal(suite.T(), map[string]bool{"testpod": false}, health.Deployments)
+
+	assert.Eventually(suite.T(), func() bool {
+		if err := k8sClient.Get(context.Background(), appnn, &d); err != nil {
+			return false
+		}
+		d.Status = apps.DeploymentStatus{
+			ObservedGeneration: d.Generation,
+			Replicas:           1,
+			ReadyReplicas:      1,
+			AvailableReplicas:  1,
+			Conditions: []apps.DeploymentCondition{{
+				Type:   apps.DeploymentAvailable,
+				Status: core.ConditionTrue,
+			}},
+		}
+		return k8sClient.Status().Update(context.Background(), &d) == nil
+	}, time.Second*30, time.Second*1, "could not make the deployment available")
+
+	assert.Eventually(suite.T(), func() bool {
+		return fetchHealth() && health.Status == AppHealthy && health.Deployments["testpod"]
+	}, time.Second*30, time.Second*1, "app health did not become healthy")
 }
 
-func kafkaValidation(t *testing.T, env *crd.ClowdEnvironment, app *crd.ClowdApp, jsonContent *config.AppConfig) {
-	// Kafka validation
+func (suite *TestSuite) TestMissingCloudwatchSecret() {
+	logger.Info("Creating ClowdApp with a missing cloudwatch secret")
 
-	topicWithPartitionsReplicasName := "inventory"
-	topicWithPartitionsReplicasNamespacedName := types.NamespacedName{
-		Namespace: env.Spec.Providers.Kafka.Cluster.Namespace,
-		Name:      topicWithPartitionsReplicasName,
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "missing-secret",
+		Namespace: "missing-secret",
 	}
 
-	topicNoPartitionsReplicasName := "inventory-default-values"
-	topicNoPartitionsReplicasNamespacedName := types.NamespacedName{
-		Namespace: env.Spec.Providers.Kafka.Cluster.Namespace,
-		Name:      topicNoPartitionsReplicasName,
+	err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: nn.Namespace}})
+	assert.NoError(suite.T(), err)
+
+	objMeta := metav1.ObjectMeta{
+		Name:      nn.Name,
+		Namespace: nn.Namespace,
 	}
 
-	for i, kafkaTopic := range app.Spec.KafkaTopics {
-		assert.Equal(t, kafkaTopic.TopicName, jsonContent.Kafka.Topics[i].RequestedName, "wrong topic name set on app's config")
-		assert.Equal(t, kafkaTopic.TopicName, jsonContent.Kafka.Topics[i].Name, "wrong generated topic name set on app's config")
+	env := createClowdEnvironment(objMeta)
+	env.Spec.MissingSecretPolicy = "skip-provider"
+	env.Spec.Providers.Kafka = crd.KafkaConfig{Mode: "none"}
+
+	app := crd.ClowdApp{
+		ObjectMeta: objMeta,
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "testpod",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+				},
+			}},
+		},
 	}
 
-	assert.NotEqual(t, len(jsonContent.Kafka.Brokers[0].Hostname), 0, "kafka broker hostname is not set")
+	err = k8sClient.Create(ctx, &env)
+	assert.NoError(suite.T(), err)
 
-	for _, topic := range []types.NamespacedName{topicWithPartitionsReplicasNamespacedName, topicNoPartitionsReplicasNamespacedName} {
-		fetchedTopic := strimzi.KafkaTopic{}
+	err = k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
 
-		// fetch topic, make sure it was provisioned
-		err := fetchWithDefaults(topic, &fetchedTopic)
-		assert.NoError(t, err, "error fetching topic")
-		assert.NotNil(t, fetchedTopic.Spec, "kafkaTopic '%s' not provisioned in namespace", topic.Name)
+	// The providers that don't need the secret must still render
+	d := apps.Deployment{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "missing-secret-testpod", Namespace: nn.Namespace}, &d)
+	assert.NoError(suite.T(), err)
 
-		// check that configured partitions/replicas matches
-		expectedReplicas := int32(0)
-		expectedPartitions := int32(0)
-		if topic.Name == topicWithPartitionsReplicasName {
-			expectedReplicas = int32(5)
-			expectedPartitions = int32(5)
+	jsonContent, err := fetchConfig(nn)
+	assert.NoError(suite.T(), err)
+	assert.Nil(suite.T(), jsonContent.Logging.Cloudwatch, "cloudwatch config rendered without a secret")
+
+	assert.Eventually(suite.T(), func() bool {
+		fetchedApp := crd.ClowdApp{}
+		if err := k8sClient.Get(ctx, nn, &fetchedApp); err != nil {
+			return false
 		}
-		if topic.Name == topicNoPartitionsReplicasName {
-			expectedReplicas = int32(3)
-			expectedPartitions = int32(3)
+		for _, condition := range fetchedApp.Status.Conditions {
+			if condition.Type == crd.SecretNotFound {
+				return condition.Status == core.ConditionTrue && strings.Contains(condition.Reason, "missing-secret/cloudwatch")
+			}
 		}
-
-		assert.Equal(t, *fetchedTopic.Spec.Replicas, expectedReplicas, "bad topic replica count for '%s': %d; expected %d", topic.Name, fetchedTopic.Spec.Replicas, expectedReplicas)
-		assert.Equal(t, *fetchedTopic.Spec.Partitions, expectedPartitions, "bad topic replica count for '%s': %d; expected %d", topic.Name, fetchedTopic.Spec.Partitions, expectedPartitions)
+		return false
+	}, time.Second*30, time.Second*1)
+
+	conditionMetric := func(status core.ConditionStatus) float64 {
+		return testutil.ToFloat64(appConditionMetrics.With(prometheus.Labels{
+			"app":    app.GetIdent(),
+			"type":   string(crd.SecretNotFound),
+			"status": string(status),
+		}))
 	}
+	assert.Equal(suite.T(), 1.0, conditionMetric(core.ConditionTrue))
+	assert.Equal(suite.T(), 0.0, conditionMetric(core.ConditionFalse))
 }
 
-func clowdWatchValidation(t *testing.T, jsonContent *config.AppConfig, cwData map[string]string) {
-	// Cloudwatch validation
-	cwConfigVals := map[string]string{
-		"aws_access_key_id":     jsonContent.Logging.Cloudwatch.AccessKeyId,
-		"aws_secret_access_key": jsonContent.Logging.Cloudwatch.SecretAccessKey,
-		"log_group_name":        jsonContent.Logging.Cloudwatch.LogGroup,
-		"aws_region":            jsonContent.Logging.Cloudwatch.Region,
+// scrapeMetrics returns the exposition of the manager's metrics endpoint.
+func scrapeMetrics() (string, error) {
+	resp, err := http.Get("http://localhost:8080/metrics")
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
 
-	for key, val := range cwData {
-		assert.Equal(t, val, cwConfigVals[key], "wrong cloudwatch config value")
-	}
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
 }
 
-func scaledObjectValidation(t *testing.T, scaler *keda.ScaledObject) {
-	// Scaled object validation
-	expectTarget := keda.ScaleTarget{
-		Kind: "Deployment",
-		Name: "test-testpod",
+func (suite *TestSuite) TestEnvironmentReadyMetric() {
+	logger.Info("Scraping the environment ready and reconcile error metrics")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "env-ready-metric",
+		Namespace: "env-ready-metric",
 	}
-	expectedTrigger := keda.ScaleTriggers{
-		Type: "cpu",
-		Metadata: map[string]string{
-			"type":  "Utilization",
-			"value": "50",
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "good", Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}},
 		},
 	}
-	for _, trigger := range scaler.Spec.Triggers {
-		assert.Equal(t, expectedTrigger.Type, trigger.Type)
-		assert.Equal(t, expectedTrigger.Metadata, trigger.Metadata)
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	readySeries := func(value int) string {
+		return fmt.Sprintf("clowder_environment_ready{env=%q} %d", env.Name, value)
 	}
 
-	assert.Equal(t, expectTarget.Kind, scaler.Spec.ScaleTargetRef.Kind)
-	assert.Equal(t, expectTarget.Name, scaler.Spec.ScaleTargetRef.Name)
-}
+	assert.Eventually(suite.T(), func() bool {
+		metrics, err := scrapeMetrics()
+		return err == nil && strings.Contains(metrics, readySeries(1))
+	}, time.Second*30, time.Second*1, "environment was not reported ready")
 
-func fetchWithDefaults(name types.NamespacedName, resource client.Object) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+	// A singleton with more replicas fails to reconcile and the environment is no longer ready
+	broken := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "broken", Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:      "lock",
+				Singleton: true,
+				Replicas:  utils.Int32Ptr(2),
+				PodSpec:   crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
 
-	return fetch(ctx, name, resource, 20*6, 500*time.Millisecond)
+	err = k8sClient.Create(ctx, &broken)
+	assert.NoError(suite.T(), err)
+
+	errorSeries := `clowder_reconcile_errors_total{controller="clowdapp",reason="InvalidSingleton"}`
+
+	assert.Eventually(suite.T(), func() bool {
+		metrics, err := scrapeMetrics()
+		return err == nil && strings.Contains(metrics, readySeries(0)) && strings.Contains(metrics, errorSeries)
+	}, time.Second*30, time.Second*1, "environment was not reported unready")
+
+	err = k8sClient.Delete(ctx, &env)
+	assert.NoError(suite.T(), err)
+
+	assert.Eventually(suite.T(), func() bool {
+		metrics, err := scrapeMetrics()
+		return err == nil && !strings.Contains(metrics, fmt.Sprintf("clowder_environment_ready{env=%q}", env.Name))
+	}, time.Second*30, time.Second*1, "environment ready series was not removed")
 }
 
-func fetch(ctx context.Context, name types.NamespacedName, resource client.Object, retryCount int, sleepTime time.Duration) error {
-	var err error
+func (suite *TestSuite) TestConfigSecretUpdateStrategy() {
+	logger.Info("Updating the config of ClowdApps with different config secret update strategies")
 
-	for i := 1; i <= retryCount; i++ {
-		err = k8sClient.Get(ctx, name, resource)
+	ctx := context.Background()
 
-		if err == nil {
-			return nil
-		} else if !k8serr.IsNotFound(err) {
-			return err
-		}
+	namespace := "secret-strategy"
 
-		time.Sleep(sleepTime)
-	}
+	err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}})
+	assert.NoError(suite.T(), err)
 
-	return err
-}
+	env := createClowdEnvironment(metav1.ObjectMeta{Name: namespace, Namespace: namespace})
+	env.Spec.Providers.Kafka = crd.KafkaConfig{Mode: "none"}
+	env.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
 
-func createEphemeralManagedSecret(name string, namespace string, cwData map[string]string) error {
-	secret := core.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: namespace,
-		},
-		StringData: cwData,
+	err = k8sClient.Create(ctx, &env)
+	assert.NoError(suite.T(), err)
+
+	strategies := map[string]crd.ConfigSecretUpdateStrategy{
+		"secret-patch":    "",
+		"secret-recreate": "recreate",
 	}
 
-	return k8sClient.Create(context.Background(), &secret)
-}
+	uids := map[string]types.UID{}
+	for name, strategy := range strategies {
+		app := crd.ClowdApp{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: crd.ClowdAppSpec{
+				EnvName: env.Name,
+				Deployments: []crd.Deployment{{
+					Name: "testpod",
+					PodSpec: crd.PodSpec{
+						Image: "test:v1",
+					},
+				}},
+				ConfigSecretUpdateStrategy: strategy,
+			},
+		}
 
-var ephemManagedKafkaHTTPLog = []map[string]string{}
+		err = k8sClient.Create(ctx, &app)
+		assert.NoError(suite.T(), err)
 
-func (suite *TestSuite) TestManagedKafkaConnectBuilderCreate() {
-	logger.Info("Starting ephemeral managed kafka e2e test")
+		secret := core.Secret{}
+		err = fetchWithDefaults(types.NamespacedName{Name: name, Namespace: namespace}, &secret)
+		assert.NoError(suite.T(), err)
+		uids[name] = secret.UID
+	}
 
-	nn := types.NamespacedName{
-		Name:      "managed-ephemeral-kafka",
-		Namespace: "default",
+	// A new image changes the config's metadata
+	for name := range strategies {
+		nn := types.NamespacedName{Name: name, Namespace: namespace}
+		assert.Eventually(suite.T(), func() bool {
+			app := crd.ClowdApp{}
+			if err := k8sClient.Get(ctx, nn, &app); err != nil {
+				return false
+			}
+			app.Spec.Deployments[0].PodSpec.Image = "test:v2"
+			return k8sClient.Update(ctx, &app) == nil
+		}, time.Second*30, time.Second*1)
 	}
 
-	secretData := make(map[string]string)
-	secretData["client.id"] = "username"
-	secretData["client.secret"] = "password"
-	secretData["hostname"] = "hostname:443"
-	secretData["admin.url"] = "https://admin.url"
-	secretData["token.url"] = "https://token.url"
-	secName := "managed-ephem-secret"
-	err := createEphemeralManagedSecret(secName, nn.Namespace, secretData)
-	assert.Nil(suite.T(), err)
+	for name, strategy := range strategies {
+		nn := types.NamespacedName{Name: name, Namespace: namespace}
+		secret := core.Secret{}
+		assert.Eventually(suite.T(), func() bool {
+			if err := k8sClient.Get(ctx, nn, &secret); err != nil {
+				return false
+			}
+			return strings.Contains(string(secret.Data["cdappconfig.json"]), "test:v2")
+		}, time.Second*30, time.Second*1)
 
-	cwData := map[string]string{
-		"aws_access_key_id":     "key_id",
-		"aws_secret_access_key": "secret",
-		"log_group_name":        "default",
-		"aws_region":            "us-east-1",
+		if strategy == "recreate" {
+			assert.NotEqual(suite.T(), uids[name], secret.UID, "config secret of %s was not recreated", name)
+		} else {
+			assert.Equal(suite.T(), uids[name], secret.UID, "config secret of %s was not patched in place", name)
+		}
 	}
+}
 
-	_ = createCloudwatchSecret(&cwData)
+func (suite *TestSuite) TestPausedApp() {
+	logger.Info("Hand-editing the Deployment of a paused ClowdApp")
 
-	mockClient := MockEphemManagedKafkaHTTPClient{topicList: make(map[string]bool)}
+	ctx := context.Background()
 
-	kafka.ClientCreator = func(provider *providers.Provider, clientCred clientcredentials.Config) kafka.HTTPClient {
-		return &mockClient
+	nn := types.NamespacedName{
+		Name:      "paused-app",
+		Namespace: "paused-app",
 	}
 
-	env, app, err := createManagedKafkaClowderStack(nn, secName)
+	env := createTestEnvironment(suite.T(), nn, nil)
 
-	assert.Nil(suite.T(), err)
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
 
-	assert.NotNil(suite.T(), app)
-	assert.NotNil(suite.T(), env)
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	dnn := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(dnn, &d)
+	assert.NoError(suite.T(), err, "deployment was not created")
+
+	setPaused := func(paused bool) {
+		assert.Eventually(suite.T(), func() bool {
+			app := crd.ClowdApp{}
+			if err := k8sClient.Get(ctx, nn, &app); err != nil {
+				return false
+			}
+			annotations := app.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			if paused {
+				annotations[PausedAnnotation] = "true"
+			} else {
+				delete(annotations, PausedAnnotation)
+			}
+			app.SetAnnotations(annotations)
+			return k8sClient.Update(ctx, &app) == nil
+		}, time.Second*30, time.Second*1)
+	}
+
+	setPaused(true)
+
+	assert.Eventually(suite.T(), func() bool {
+		app := crd.ClowdApp{}
+		if err := k8sClient.Get(ctx, nn, &app); err != nil {
+			return false
+		}
+		return cond.IsTrue(&app, crd.Paused)
+	}, time.Second*30, time.Second*1, "app was not marked paused")
+
+	assert.GreaterOrEqual(suite.T(), testutil.ToFloat64(pausedObjectsMetric.With(prometheus.Labels{"type": "app"})), 1.0)
+
+	// Clowder must not revert the hand-edited image while the app is paused
+	assert.Eventually(suite.T(), func() bool {
+		d := apps.Deployment{}
+		if err := k8sClient.Get(ctx, dnn, &d); err != nil {
+			return false
+		}
+		d.Spec.Template.Spec.Containers[0].Image = "test:hand-edited"
+		return k8sClient.Update(ctx, &d) == nil
+	}, time.Second*30, time.Second*1)
+
+	assert.Never(suite.T(), func() bool {
+		d := apps.Deployment{}
+		if err := k8sClient.Get(ctx, dnn, &d); err != nil {
+			return false
+		}
+		return d.Spec.Template.Spec.Containers[0].Image != "test:hand-edited"
+	}, time.Second*5, time.Second*1, "paused deployment was reverted")
+
+	// Once unpaused the Deployment is reconciled again
+	setPaused(false)
+
+	assert.Eventually(suite.T(), func() bool {
+		d := apps.Deployment{}
+		if err := k8sClient.Get(ctx, dnn, &d); err != nil {
+			return false
+		}
+		return d.Spec.Template.Spec.Containers[0].Image == "test:test"
+	}, time.Second*30, time.Second*1, "unpaused deployment was not reconciled")
+
+	app = crd.ClowdApp{}
+	err = k8sClient.Get(ctx, nn, &app)
+	if assert.NoError(suite.T(), err) {
+		assert.False(suite.T(), cond.Has(&app, crd.Paused))
+	}
+}
+
+func (suite *TestSuite) TestServicePolicy() {
+	logger.Info("Creating ClowdApp with deployments that only get a service for exposed ports")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "service-policy",
+		Namespace: "service-policy",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.Providers.Metrics.Port = 0
+	})
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:    "worker",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}, {
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+				WebServices: crd.WebServices{
+					Public: crd.PublicWebService{Enabled: true},
+				},
+			}, {
+				Name:          "legacy",
+				PodSpec:       crd.PodSpec{Image: "test:test"},
+				ServicePolicy: "always",
+			}, {
+				Name:       "grpc",
+				PodSpec:    crd.PodSpec{Image: "test:test"},
+				ExtraPorts: []crd.DeploymentPort{{Name: "grpc", ContainerPort: 9090}},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	apiNN := app.GetDeploymentNamespacedName(&app.Spec.Deployments[1])
+	s := &core.Service{}
+	err = fetchWithDefaults(apiNN, s)
+	if assert.NoError(suite.T(), err, "service was not created for the deployment with a web port") {
+		assert.Len(suite.T(), s.Spec.Ports, 1)
+	}
+
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[2]), &core.Service{})
+	assert.NoError(suite.T(), err, "service was not created for the deployment with the always policy")
+
+	grpcNN := app.GetDeploymentNamespacedName(&app.Spec.Deployments[3])
+	err = fetchWithDefaults(grpcNN, &core.Service{})
+	assert.NoError(suite.T(), err, "service was not created for the deployment with an extra port")
+
+	workerNN := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+	err = fetchWithDefaults(workerNN, &apps.Deployment{})
+	assert.NoError(suite.T(), err)
+
+	err = k8sClient.Get(ctx, workerNN, &core.Service{})
+	assert.True(suite.T(), k8serr.IsNotFound(err), "service was created for the portless deployment")
+
+	// the service of a deployment that no longer exposes any port is removed
+	assert.Eventually(suite.T(), func() bool {
+		app := crd.ClowdApp{}
+		if err := k8sClient.Get(ctx, nn, &app); err != nil {
+			return false
+		}
+		app.Spec.Deployments[3].ExtraPorts = nil
+		return k8sClient.Update(ctx, &app) == nil
+	}, time.Second*30, time.Second*1)
+
+	assert.Eventually(suite.T(), func() bool {
+		return k8serr.IsNotFound(k8sClient.Get(ctx, grpcNN, &core.Service{}))
+	}, time.Second*30, time.Second*1, "service was kept for the deployment without ports")
+}
+
+func (suite *TestSuite) TestServiceMonitorMode() {
+	logger.Info("Creating ClowdApp in an environment generating ServiceMonitors")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "service-monitor",
+		Namespace: "service-monitor",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.Providers.Metrics.Mode = "service-monitor"
+	})
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	sm := &prom.ServiceMonitor{}
+	apiNN := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+	err = fetchWithDefaults(apiNN, sm)
+	if !assert.NoError(suite.T(), err, "service monitor was not created") {
+		return
+	}
+
+	assert.Equal(suite.T(), map[string]string{"pod": apiNN.Name}, sm.Spec.Selector.MatchLabels)
+	if assert.Len(suite.T(), sm.Spec.Endpoints, 1) {
+		assert.Equal(suite.T(), "/metrics", sm.Spec.Endpoints[0].Path)
+		assert.Equal(suite.T(), "metrics", sm.Spec.Endpoints[0].Port)
+	}
+	if assert.Len(suite.T(), sm.GetOwnerReferences(), 1) {
+		assert.Equal(suite.T(), app.Name, sm.GetOwnerReferences()[0].Name)
+	}
+
+	// the service selected by the ServiceMonitor carries the matching labels
+	svc := &core.Service{}
+	err = fetchWithDefaults(apiNN, svc)
+	assert.NoError(suite.T(), err)
+	for k, v := range sm.Spec.Selector.MatchLabels {
+		assert.Equal(suite.T(), v, svc.Labels[k])
+	}
+}
+
+func (suite *TestSuite) TestSchedulingDefaults() {
+	logger.Info("Creating ClowdApp in an environment with default tolerations and node selector")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "scheduling",
+		Namespace: "scheduling",
+	}
+
+	envToleration := core.Toleration{Key: "dedicated", Operator: core.TolerationOpEqual, Value: "clowder", Effect: core.TaintEffectNoSchedule}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.Providers.Deployment.Tolerations = []core.Toleration{envToleration}
+		env.Spec.Providers.Deployment.NodeSelector = map[string]string{"node-role": "apps", "zone": "a"}
+	})
+
+	podToleration := core.Toleration{Key: "gpu", Operator: core.TolerationOpExists, Effect: core.TaintEffectNoSchedule}
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}, {
+				Name: "worker",
+				PodSpec: crd.PodSpec{
+					Image:        "test:test",
+					Tolerations:  []core.Toleration{podToleration},
+					NodeSelector: map[string]string{"zone": "b", "disk": "ssd"},
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	api := &apps.Deployment{}
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[0]), api)
+	if !assert.NoError(suite.T(), err, "api deployment was not created") {
+		return
+	}
+
+	assert.Equal(suite.T(), []core.Toleration{envToleration}, api.Spec.Template.Spec.Tolerations)
+	assert.Equal(suite.T(), map[string]string{"node-role": "apps", "zone": "a"}, api.Spec.Template.Spec.NodeSelector)
+
+	worker := &apps.Deployment{}
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[1]), worker)
+	if !assert.NoError(suite.T(), err, "worker deployment was not created") {
+		return
+	}
+
+	assert.Equal(suite.T(), []core.Toleration{envToleration, podToleration}, worker.Spec.Template.Spec.Tolerations)
+	assert.Equal(suite.T(), map[string]string{"node-role": "apps", "zone": "b", "disk": "ssd"}, worker.Spec.Template.Spec.NodeSelector)
+}
+
+func (suite *TestSuite) TestConcurrentReconciles() {
+	logger.Info("Creating many ClowdApps in one environment at once")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "concurrent",
+		Namespace: "concurrent",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	const appCount = 25
+
+	start := time.Now()
+	for i := 0; i < appCount; i++ {
+		app := crd.ClowdApp{
+			ObjectMeta: metav1.ObjectMeta{Name: fmt.Sprintf("app-%d", i), Namespace: nn.Namespace},
+			Spec: crd.ClowdAppSpec{
+				EnvName: env.Name,
+				Deployments: []crd.Deployment{{
+					Name:    "api",
+					PodSpec: crd.PodSpec{Image: "test:test"},
+				}},
+			},
+		}
+		err := k8sClient.Create(ctx, &app)
+		assert.NoError(suite.T(), err)
+	}
+
+	reconciled := func(app *crd.ClowdApp) bool {
+		for _, condition := range app.Status.Conditions {
+			if condition.Type == crd.ReconciliationSuccessful && condition.Status == core.ConditionTrue {
+				return true
+			}
+		}
+		return false
+	}
+
+	pending := appCount
+	for i := 0; i < 120 && pending > 0; i++ {
+		time.Sleep(500 * time.Millisecond)
+
+		appList := crd.ClowdAppList{}
+		err := k8sClient.List(ctx, &appList, client.InNamespace(nn.Namespace))
+		if !assert.NoError(suite.T(), err) {
+			return
+		}
+
+		pending = appCount
+		for j := range appList.Items {
+			if reconciled(&appList.Items[j]) {
+				pending--
+			}
+		}
+	}
+
+	assert.Zero(suite.T(), pending, "not all apps were reconciled")
+	logger.Info("ClowdApps reconciled", zap.Int("apps", appCount), zap.Duration("duration", time.Since(start)))
+
+	for i := 0; i < appCount; i++ {
+		d := &apps.Deployment{}
+		err := fetchWithDefaults(types.NamespacedName{Name: fmt.Sprintf("app-%d-api", i), Namespace: nn.Namespace}, d)
+		assert.NoError(suite.T(), err, "deployment of app-%d was not created", i)
+	}
+}
+
+func (suite *TestSuite) TestPodDisruptionBudget() {
+	logger.Info("Creating ClowdApp with replicated web deployments")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "pdb",
+		Namespace: "pdb",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	public := crd.WebServices{Public: crd.PublicWebService{Enabled: true}}
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:         "api",
+				Replicas:     utils.Int32Ptr(3),
+				MinAvailable: utils.Int32Ptr(2),
+				WebServices:  public,
+				PodSpec:      crd.PodSpec{Image: "test:test"},
+			}, {
+				Name:        "single",
+				WebServices: public,
+				PodSpec:     crd.PodSpec{Image: "test:test"},
+			}, {
+				Name:     "worker",
+				Replicas: utils.Int32Ptr(3),
+				PodSpec:  crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	apiName := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+
+	pdb := &policy.PodDisruptionBudget{}
+	err = fetchWithDefaults(apiName, pdb)
+	if !assert.NoError(suite.T(), err, "pod disruption budget was not created") {
+		return
+	}
+
+	api := &apps.Deployment{}
+	err = fetchWithDefaults(apiName, api)
+	if !assert.NoError(suite.T(), err, "api deployment was not created") {
+		return
+	}
+
+	assert.Equal(suite.T(), api.Spec.Selector, pdb.Spec.Selector)
+	assert.Equal(suite.T(), api.Spec.Template.Labels, pdb.Spec.Selector.MatchLabels)
+	assert.Equal(suite.T(), intstr.FromInt(2), *pdb.Spec.MinAvailable)
+	if assert.Len(suite.T(), pdb.GetOwnerReferences(), 1) {
+		assert.Equal(suite.T(), app.UID, pdb.GetOwnerReferences()[0].UID)
+	}
+
+	for _, deployment := range app.Spec.Deployments[1:] {
+		innerDeployment := deployment
+		err = k8sClient.Get(ctx, app.GetDeploymentNamespacedName(&innerDeployment), &policy.PodDisruptionBudget{})
+		assert.True(suite.T(), k8serr.IsNotFound(err), "%s got a pod disruption budget", deployment.Name)
+	}
+}
+
+func (suite *TestSuite) TestReplicaGuardrails() {
+	logger.Info("Creating ClowdApp in an environment with replica guardrails")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "replica-guardrails",
+		Namespace: "replica-guardrails",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.DefaultReplicas = utils.Int32Ptr(2)
+		env.Spec.MaxReplicas = utils.Int32Ptr(4)
+	})
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:     "typo",
+				Replicas: utils.Int32Ptr(3200),
+				PodSpec:  crd.PodSpec{Image: "test:test"},
+			}, {
+				Name:    "omitted",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	for i, expected := range []int32{4, 2} {
+		d := apps.Deployment{}
+		err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[i]), &d)
+		if assert.NoError(suite.T(), err, "deployment was not created") {
+			assert.Equal(suite.T(), expected, *d.Spec.Replicas, app.Spec.Deployments[i].Name)
+		}
+	}
+}
+
+func (suite *TestSuite) TestDeploymentStrategies() {
+	logger.Info("Creating ClowdApp with deployment strategies")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "strategies",
+		Namespace: "strategies",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	for name, mode := range map[string]core.PersistentVolumeAccessMode{
+		"data-rwo": core.ReadWriteOnce,
+		"data-rwx": core.ReadWriteMany,
+	} {
+		pvc := &core.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: nn.Namespace},
+			Spec: core.PersistentVolumeClaimSpec{
+				AccessModes: []core.PersistentVolumeAccessMode{mode},
+				Resources: core.ResourceRequirements{
+					Requests: core.ResourceList{core.ResourceStorage: resource.MustParse("1Gi")},
+				},
+			},
+		}
+		err := k8sClient.Create(ctx, pvc)
+		assert.NoError(suite.T(), err)
+	}
+
+	volume := func(claimName string) crd.PodSpec {
+		return crd.PodSpec{
+			Image: "test:test",
+			Volumes: []core.Volume{{
+				Name: "data",
+				VolumeSource: core.VolumeSource{
+					PersistentVolumeClaim: &core.PersistentVolumeClaimVolumeSource{ClaimName: claimName},
+				},
+			}},
+		}
+	}
+
+	surge, unavailable := intstr.FromInt(1), intstr.FromInt(0)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:               "recreate",
+				Replicas:           utils.Int32Ptr(2),
+				DeploymentStrategy: &crd.DeploymentStrategy{Type: apps.RecreateDeploymentStrategyType},
+				PodSpec:            crd.PodSpec{Image: "test:test"},
+			}, {
+				Name: "rolling",
+				DeploymentStrategy: &crd.DeploymentStrategy{
+					Type:           apps.RollingUpdateDeploymentStrategyType,
+					MaxSurge:       &surge,
+					MaxUnavailable: &unavailable,
+				},
+				PodSpec: volume("data-rwo"),
+			}, {
+				Name:    "rwo",
+				PodSpec: volume("data-rwo"),
+			}, {
+				Name:     "rwo-replicas",
+				Replicas: utils.Int32Ptr(3),
+				PodSpec:  volume("data-rwo"),
+			}, {
+				Name:    "rwo-autoscaled",
+				PodSpec: volume("data-rwo"),
+				AutoScaler: &crd.AutoScaler{
+					MaxReplicaCount: utils.Int32Ptr(3),
+					Triggers: []keda.ScaleTriggers{{
+						Type:     "cpu",
+						Metadata: map[string]string{"type": "Utilization", "value": "50"},
+					}},
+				},
+			}, {
+				Name:    "rwx",
+				PodSpec: volume("data-rwx"),
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	strategies := map[string]apps.DeploymentStrategyType{
+		"recreate":       apps.RecreateDeploymentStrategyType,
+		"rolling":        apps.RollingUpdateDeploymentStrategyType,
+		"rwo":            apps.RecreateDeploymentStrategyType,
+		"rwo-replicas":   apps.RecreateDeploymentStrategyType,
+		"rwo-autoscaled": apps.RecreateDeploymentStrategyType,
+		"rwx":            apps.RollingUpdateDeploymentStrategyType,
+	}
+
+	for i := range app.Spec.Deployments {
+		deployment := app.Spec.Deployments[i]
+
+		d := &apps.Deployment{}
+		err = fetchWithDefaults(app.GetDeploymentNamespacedName(&deployment), d)
+		if !assert.NoError(suite.T(), err, "%s deployment was not created", deployment.Name) {
+			continue
+		}
+
+		assert.Equal(suite.T(), strategies[deployment.Name], d.Spec.Strategy.Type, deployment.Name)
+		if d.Spec.Strategy.Type == apps.RecreateDeploymentStrategyType {
+			assert.Nil(suite.T(), d.Spec.Strategy.RollingUpdate, deployment.Name)
+		}
+		if deployment.Name == "rolling" {
+			assert.Equal(suite.T(), &surge, d.Spec.Strategy.RollingUpdate.MaxSurge)
+			assert.Equal(suite.T(), &unavailable, d.Spec.Strategy.RollingUpdate.MaxUnavailable)
+		}
+	}
+}
+
+func (suite *TestSuite) TestSidecars() {
+	logger.Info("Creating ClowdApp with sidecars")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "sidecars",
+		Namespace: "sidecars",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.Providers.Sidecars.OtelCollector = crd.OtelCollectorConfig{Enabled: true, Image: "otel:test"}
+	})
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "api",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+					Sidecars: []crd.Sidecar{
+						{Name: "otel-collector", Enabled: true},
+						// not enabled in the environment
+						{Name: "token-refresher", Enabled: true},
+					},
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	d := &apps.Deployment{}
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[0]), d)
+	if !assert.NoError(suite.T(), err, "deployment was not created") {
+		return
+	}
+
+	containers := d.Spec.Template.Spec.Containers
+	if !assert.Len(suite.T(), containers, 2) {
+		return
+	}
+	assert.Equal(suite.T(), "sidecars-api", containers[0].Name)
+
+	collector := containers[1]
+	assert.Equal(suite.T(), "otel-collector", collector.Name)
+	assert.Equal(suite.T(), "otel:test", collector.Image)
+
+	mounts := map[string]string{}
+	for _, mount := range collector.VolumeMounts {
+		mounts[mount.Name] = mount.MountPath
+	}
+	assert.Equal(suite.T(), map[string]string{"otel-config": "/etc/otel/", "config-secret": "/cdapp/"}, mounts)
+
+	volumes := map[string]core.Volume{}
+	for _, volume := range d.Spec.Template.Spec.Volumes {
+		volumes[volume.Name] = volume
+	}
+	if assert.Contains(suite.T(), volumes, "otel-config") {
+		assert.Equal(suite.T(), "sidecars-otel-config", volumes["otel-config"].ConfigMap.Name)
+	}
+	assert.Contains(suite.T(), volumes, "config-secret")
+}
+
+func (suite *TestSuite) TestIgnoredKinds() {
+	logger.Info("Creating ClowdApp that ignores services")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "ignored-kinds",
+		Namespace: "ignored-kinds",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName:      env.Name,
+			IgnoredKinds: []string{"Service"},
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+				WebServices: crd.WebServices{
+					Public: crd.PublicWebService{Enabled: true},
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	// Services are applied before deployments, so by the time the deployment exists the
+	// service would have been too
+	apiNN := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+	err = fetchWithDefaults(apiNN, &apps.Deployment{})
+	assert.NoError(suite.T(), err, "deployment was not applied")
+
+	err = k8sClient.Get(ctx, apiNN, &core.Service{})
+	assert.True(suite.T(), k8serr.IsNotFound(err), "service of an ignored kind was applied")
+}
+
+func (suite *TestSuite) TestMissingDependencyCondition() {
+	logger.Info("Creating ClowdApp with a missing dependency")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "missing-dependency",
+		Namespace: "missing-dependency",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName:      env.Name,
+			Dependencies: []string{"not-there"},
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	getCondition := func(conditionType clusterv1.ConditionType) *clusterv1.Condition {
+		fetchedApp := crd.ClowdApp{}
+		if err := k8sClient.Get(ctx, nn, &fetchedApp); err != nil {
+			return nil
+		}
+		for _, condition := range fetchedApp.Status.Conditions {
+			if condition.Type == conditionType {
+				return &condition
+			}
+		}
+		return nil
+	}
+
+	assert.Eventually(suite.T(), func() bool {
+		condition := getCondition(crd.DependenciesReady)
+		return condition != nil && condition.Status == core.ConditionFalse
+	}, time.Second*30, time.Second*1)
+
+	condition := getCondition(crd.DependenciesReady)
+	if assert.NotNil(suite.T(), condition) {
+		assert.Equal(suite.T(), MissingDependencyReason, condition.Reason)
+		assert.Contains(suite.T(), condition.Message, "not-there")
+	}
+
+	condition = getCondition(crd.ReconciliationSuccessful)
+	if assert.NotNil(suite.T(), condition) {
+		assert.Equal(suite.T(), core.ConditionFalse, condition.Status)
+	}
+
+	condition = getCondition(crd.DeploymentsReady)
+	if assert.NotNil(suite.T(), condition) {
+		assert.Equal(suite.T(), core.ConditionFalse, condition.Status)
+	}
+}
+
+func (suite *TestSuite) TestOptionalDependencies() {
+	logger.Info("Creating ClowdApp with optional dependencies")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "optional-dependency",
+		Namespace: "optional-dependency",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	provider := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: "optional-provider", Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+				WebServices: crd.WebServices{
+					Public:  crd.PublicWebService{Enabled: true},
+					Private: crd.PrivateWebService{Enabled: true},
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &provider)
+	assert.NoError(suite.T(), err)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName:              env.Name,
+			OptionalDependencies: []string{provider.Name, "not-there"},
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
+
+	err = k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[0]), &apps.Deployment{})
+	assert.NoError(suite.T(), err, "deployment was blocked by a missing optional dependency")
+
+	var jsonContent *config.AppConfig
+	assert.Eventually(suite.T(), func() bool {
+		jsonContent, err = fetchConfig(nn)
+		return err == nil && len(jsonContent.Endpoints) > 0
+	}, time.Second*30, time.Second*1)
+
+	// Only the optional dependency that exists gets endpoints
+	hostname := fmt.Sprintf("%s-api.%s.svc", provider.Name, nn.Namespace)
+	if assert.Len(suite.T(), jsonContent.Endpoints, 1) {
+		assert.Equal(suite.T(), provider.Name, jsonContent.Endpoints[0].App)
+		assert.Equal(suite.T(), hostname, jsonContent.Endpoints[0].Hostname)
+	}
+	if assert.Len(suite.T(), jsonContent.PrivateEndpoints, 1) {
+		assert.Equal(suite.T(), provider.Name, jsonContent.PrivateEndpoints[0].App)
+		assert.Equal(suite.T(), hostname, jsonContent.PrivateEndpoints[0].Hostname)
+	}
+
+	fetchedApp := crd.ClowdApp{}
+	assert.Eventually(suite.T(), func() bool {
+		if err := k8sClient.Get(ctx, nn, &fetchedApp); err != nil {
+			return false
+		}
+		for _, condition := range fetchedApp.Status.Conditions {
+			if condition.Type == crd.DependenciesReady {
+				return condition.Status == core.ConditionTrue
+			}
+		}
+		return false
+	}, time.Second*30, time.Second*1)
+}
+
+func (suite *TestSuite) TestAntiAffinityModes() {
+	logger.Info("Creating ClowdApp with anti-affinity modes")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "anti-affinity",
+		Namespace: "anti-affinity",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	deployments := map[string]*crd.AntiAffinity{
+		"default":       nil,
+		"preferred":     {Mode: "preferred"},
+		"required":      {Mode: "required"},
+		"required-zone": {Mode: "required", TopologyKey: "topology.kubernetes.io/zone"},
+		"none":          {Mode: "none"},
+	}
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec:       crd.ClowdAppSpec{EnvName: env.Name},
+	}
+	for name, antiAffinity := range deployments {
+		app.Spec.Deployments = append(app.Spec.Deployments, crd.Deployment{
+			Name:         name,
+			PodSpec:      crd.PodSpec{Image: "test:test"},
+			AntiAffinity: antiAffinity,
+		})
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	fetchAffinity := func(name string) *core.Affinity {
+		d := apps.Deployment{}
+		nn := types.NamespacedName{Name: fmt.Sprintf("%s-%s", app.Name, name), Namespace: app.Namespace}
+		err := fetchWithDefaults(nn, &d)
+		assert.NoError(suite.T(), err, "deployment %s was not created", name)
+		return d.Spec.Template.Spec.Affinity
+	}
+
+	// Deployments without a mode keep the preferred rules
+	for _, name := range []string{"default", "preferred"} {
+		affinity := fetchAffinity(name)
+		if assert.NotNil(suite.T(), affinity, name) {
+			assert.Len(suite.T(), affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, 2, name)
+			assert.Empty(suite.T(), affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution, name)
+		}
+	}
+
+	topologyKeys := map[string]string{
+		"required":      "kubernetes.io/hostname",
+		"required-zone": "topology.kubernetes.io/zone",
+	}
+	for name, topologyKey := range topologyKeys {
+		affinity := fetchAffinity(name)
+		if assert.NotNil(suite.T(), affinity, name) {
+			assert.Empty(suite.T(), affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution, name)
+			terms := affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+			if assert.Len(suite.T(), terms, 1, name) {
+				assert.Equal(suite.T(), topologyKey, terms[0].TopologyKey, name)
+				assert.Equal(suite.T(), fmt.Sprintf("%s-%s", app.Name, name), terms[0].LabelSelector.MatchLabels["pod"], name)
+			}
+		}
+	}
+
+	assert.Nil(suite.T(), fetchAffinity("none"))
+}
+
+func (suite *TestSuite) TestStatefulSets() {
+	logger.Info("Creating ClowdApp with a statefulset deployment")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "statefulset",
+		Namespace: "statefulset",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "scheduler",
+				Kind: "statefulset",
+				PodSpec: crd.PodSpec{
+					Image:        "test:test",
+					VolumeMounts: []core.VolumeMount{{Name: "data", MountPath: "/data"}},
+				},
+				VolumeClaimTemplates: []crd.VolumeClaimTemplate{{
+					Name: "data",
+					Size: resource.MustParse("1Gi"),
+				}},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	podName := fmt.Sprintf("%s-scheduler", app.Name)
+
+	s := apps.StatefulSet{}
+	err = fetchWithDefaults(types.NamespacedName{Name: podName, Namespace: app.Namespace}, &s)
+	if !assert.NoError(suite.T(), err, "statefulset was not created") {
+		return
+	}
+
+	assert.Equal(suite.T(), podName+"-headless", s.Spec.ServiceName)
+	if assert.Len(suite.T(), s.Spec.VolumeClaimTemplates, 1) {
+		claim := s.Spec.VolumeClaimTemplates[0]
+		assert.Equal(suite.T(), "data", claim.Name)
+		assert.Equal(suite.T(), []core.PersistentVolumeAccessMode{core.ReadWriteOnce}, claim.Spec.AccessModes)
+		storage := claim.Spec.Resources.Requests[core.ResourceStorage]
+		assert.Equal(suite.T(), "1Gi", storage.String())
+	}
+
+	// The pods are set up the same as those of a deployment
+	podSpec := s.Spec.Template.Spec
+	assert.Equal(suite.T(), podName, podSpec.ServiceAccountName)
+	assert.NotNil(suite.T(), podSpec.Affinity)
+	assert.Contains(suite.T(), podSpec.Containers[0].Env, core.EnvVar{Name: "ACG_CONFIG", Value: "/cdapp/cdappconfig.json"})
+	hasConfigVolume := false
+	for _, volume := range podSpec.Volumes {
+		if volume.Name == "config-secret" {
+			hasConfigVolume = true
+		}
+	}
+	assert.True(suite.T(), hasConfigVolume, "config secret is not mounted")
+	assert.NotEmpty(suite.T(), s.Spec.Template.Annotations["configHash"])
+
+	service := core.Service{}
+	err = fetchWithDefaults(types.NamespacedName{Name: podName + "-headless", Namespace: app.Namespace}, &service)
+	if assert.NoError(suite.T(), err, "headless service was not created") {
+		assert.Equal(suite.T(), core.ClusterIPNone, service.Spec.ClusterIP)
+		assert.Equal(suite.T(), podName, service.Spec.Selector["pod"])
+	}
+
+	// No deployment is created next to the statefulset
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: podName, Namespace: app.Namespace}, &apps.Deployment{})
+	assert.True(suite.T(), k8serr.IsNotFound(err))
+}
+
+func (suite *TestSuite) TestServiceAccountNames() {
+	logger.Info("Creating ClowdApp with deployments that name their service accounts")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "named-sa",
+		Namespace: "named-sa",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	existing := core.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing", Namespace: nn.Namespace},
+	}
+	err := k8sClient.Create(ctx, &existing)
+	assert.NoError(suite.T(), err)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "api",
+				PodSpec: crd.PodSpec{
+					Image:              "test:test",
+					ServiceAccountName: "existing",
+				},
+			}, {
+				Name: "worker",
+				PodSpec: crd.PodSpec{
+					Image:                     "test:test",
+					ServiceAccountName:        "reader",
+					CreateServiceAccount:      true,
+					ServiceAccountAccessLevel: "view",
+				},
+			}},
+		},
+	}
+
+	err = k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "named-sa-api", Namespace: app.Namespace}, &d)
+	if assert.NoError(suite.T(), err, "api deployment was not created") {
+		assert.Equal(suite.T(), "existing", d.Spec.Template.Spec.ServiceAccountName)
+	}
+
+	d = apps.Deployment{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "named-sa-worker", Namespace: app.Namespace}, &d)
+	if assert.NoError(suite.T(), err, "worker deployment was not created") {
+		assert.Equal(suite.T(), "reader", d.Spec.Template.Spec.ServiceAccountName)
+	}
+
+	// The service account that was created is owned by the app and bound to its access level
+	sa := core.ServiceAccount{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "reader", Namespace: app.Namespace}, &sa)
+	if assert.NoError(suite.T(), err, "service account was not created") {
+		if assert.Len(suite.T(), sa.OwnerReferences, 1) {
+			assert.Equal(suite.T(), app.UID, sa.OwnerReferences[0].UID)
+		}
+	}
+
+	rb := rbac.RoleBinding{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "reader", Namespace: app.Namespace}, &rb)
+	if assert.NoError(suite.T(), err, "role binding was not created") {
+		assert.Equal(suite.T(), "view", rb.RoleRef.Name)
+		assert.Equal(suite.T(), []rbac.Subject{{Kind: "ServiceAccount", Name: "reader", Namespace: app.Namespace}}, rb.Subjects)
+	}
+
+	// The service account that existed is left alone
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "existing", Namespace: app.Namespace}, &existing)
+	if assert.NoError(suite.T(), err) {
+		assert.Empty(suite.T(), existing.OwnerReferences)
+		assert.Empty(suite.T(), existing.Labels)
+	}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "existing", Namespace: app.Namespace}, &rbac.RoleBinding{})
+	assert.True(suite.T(), k8serr.IsNotFound(err))
+
+	// Clowder's own service account isn't created for deployments that name one
+	for _, name := range []string{"named-sa-api", "named-sa-worker"} {
+		err = k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: app.Namespace}, &core.ServiceAccount{})
+		assert.True(suite.T(), k8serr.IsNotFound(err), name)
+	}
+}
+
+func (suite *TestSuite) TestInitContainers() {
+	logger.Info("Creating ClowdApp with init containers")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "init-containers",
+		Namespace: "init-containers",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	inheritVolumeMounts := false
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "api",
+				PodSpec: crd.PodSpec{
+					Image: "app:v1",
+					Volumes: []core.Volume{{
+						Name:         "scratch",
+						VolumeSource: core.VolumeSource{EmptyDir: &core.EmptyDirVolumeSource{}},
+					}},
+					VolumeMounts: []core.VolumeMount{{Name: "scratch", MountPath: "/scratch"}},
+					InitContainers: []crd.InitContainer{{
+						Name:       "migrate",
+						Command:    []string{"./migrate"},
+						InheritEnv: true,
+					}, {
+						Name:                "seed",
+						Image:               "seed:v1",
+						Args:                []string{"--once"},
+						InheritVolumeMounts: &inheritVolumeMounts,
+					}},
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[0]), &d)
+	if !assert.NoError(suite.T(), err, "deployment was not created") {
+		return
+	}
+
+	mountNames := func(c core.Container) []string {
+		names := []string{}
+		for _, mount := range c.VolumeMounts {
+			names = append(names, mount.Name)
+		}
+		return names
+	}
+
+	ics := d.Spec.Template.Spec.InitContainers
+	if !assert.Len(suite.T(), ics, 2) {
+		return
+	}
+
+	assert.Equal(suite.T(), "migrate-init", ics[0].Name)
+	assert.Equal(suite.T(), "app:v1", ics[0].Image, "init container did not default to the main image")
+	assert.Equal(suite.T(), []string{"./migrate"}, ics[0].Command)
+	assert.Contains(suite.T(), mountNames(ics[0]), "scratch")
+	assert.Contains(suite.T(), mountNames(ics[0]), "config-secret")
+
+	assert.Equal(suite.T(), "seed-init", ics[1].Name)
+	assert.Equal(suite.T(), "seed:v1", ics[1].Image)
+	assert.Equal(suite.T(), []string{"--once"}, ics[1].Args)
+	assert.Equal(suite.T(), []string{"config-secret"}, mountNames(ics[1]))
+}
+
+func (suite *TestSuite) TestProbes() {
+	logger.Info("Creating ClowdApp with default and custom probes")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "probes",
+		Namespace: "probes",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	customProbe := core.Probe{
+		ProbeHandler: core.ProbeHandler{
+			HTTPGet: &core.HTTPGetAction{
+				Path:   "/ready",
+				Port:   intstr.FromInt(8000),
+				Scheme: core.URISchemeHTTP,
+			},
+		},
+		InitialDelaySeconds: 5,
+		TimeoutSeconds:      3,
+		PeriodSeconds:       15,
+		SuccessThreshold:    1,
+		FailureThreshold:    6,
+	}
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:        "default",
+				PodSpec:     crd.PodSpec{Image: "test:test"},
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true}},
+			}, {
+				Name: "custom",
+				PodSpec: crd.PodSpec{
+					Image:          "test:test",
+					ReadinessProbe: &customProbe,
+					LivenessProbe: &core.Probe{
+						ProbeHandler: core.ProbeHandler{
+							Exec: &core.ExecAction{Command: []string{"./alive"}},
+						},
+						TimeoutSeconds:   1,
+						PeriodSeconds:    10,
+						SuccessThreshold: 1,
+						FailureThreshold: 3,
+					},
+				},
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true}},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[0]), &d)
+	if assert.NoError(suite.T(), err, "default deployment was not created") {
+		c := d.Spec.Template.Spec.Containers[0]
+		if assert.NotNil(suite.T(), c.LivenessProbe) && assert.NotNil(suite.T(), c.ReadinessProbe) {
+			assert.Equal(suite.T(), "/healthz", c.LivenessProbe.HTTPGet.Path)
+			assert.Equal(suite.T(), int32(8000), c.LivenessProbe.HTTPGet.Port.IntVal)
+			assert.Equal(suite.T(), int32(45), c.ReadinessProbe.InitialDelaySeconds)
+		}
+	}
+
+	d = apps.Deployment{}
+	err = fetchWithDefaults(app.GetDeploymentNamespacedName(&app.Spec.Deployments[1]), &d)
+	if assert.NoError(suite.T(), err, "custom deployment was not created") {
+		c := d.Spec.Template.Spec.Containers[0]
+		assert.Equal(suite.T(), &customProbe, c.ReadinessProbe)
+		if assert.NotNil(suite.T(), c.LivenessProbe) {
+			assert.Nil(suite.T(), c.LivenessProbe.HTTPGet)
+			assert.Equal(suite.T(), []string{"./alive"}, c.LivenessProbe.Exec.Command)
+		}
+	}
+
+	badNN := types.NamespacedName{Name: "probes-bad-port", Namespace: nn.Namespace}
+	badApp := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: badNN.Name, Namespace: badNN.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "api",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+					LivenessProbe: &core.Probe{
+						ProbeHandler: core.ProbeHandler{
+							HTTPGet: &core.HTTPGetAction{Path: "/healthz", Port: intstr.FromInt(8123)},
+						},
+					},
+				},
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true}},
+			}},
+		},
+	}
+
+	err = k8sClient.Create(ctx, &badApp)
+	assert.NoError(suite.T(), err)
+
+	assert.Eventually(suite.T(), func() bool {
+		fetchedApp := crd.ClowdApp{}
+		if err := k8sClient.Get(ctx, badNN, &fetchedApp); err != nil {
+			return false
+		}
+		for _, condition := range fetchedApp.Status.Conditions {
+			if condition.Type == crd.ReconciliationFailed {
+				return condition.Status == core.ConditionTrue && strings.Contains(condition.Reason, "probe on port [8123]")
+			}
+		}
+		return false
+	}, time.Second*30, time.Second*1)
+
+	err = k8sClient.Get(ctx, badApp.GetDeploymentNamespacedName(&badApp.Spec.Deployments[0]), &apps.Deployment{})
+	assert.True(suite.T(), k8serr.IsNotFound(err), "deployment was created with an invalid probe port")
+}
+
+func (suite *TestSuite) TestExtraPorts() {
+	logger.Info("Creating ClowdApp with extra ports")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "extra-ports",
+		Namespace: "extra-ports",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:        "api",
+				PodSpec:     crd.PodSpec{Image: "test:test"},
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true}},
+				ExtraPorts: []crd.DeploymentPort{
+					{Name: "grpc", ContainerPort: 9090},
+					{Name: "stats", ContainerPort: 8125, Protocol: core.ProtocolUDP},
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	dnn := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(dnn, &d)
+	if assert.NoError(suite.T(), err, "deployment was not created") {
+		ports := map[string]core.ContainerPort{}
+		for _, port := range d.Spec.Template.Spec.Containers[0].Ports {
+			ports[port.Name] = port
+		}
+		assert.Equal(suite.T(), int32(8000), ports["web"].ContainerPort)
+		assert.Equal(suite.T(), int32(9000), ports["metrics"].ContainerPort)
+		assert.Equal(suite.T(), int32(9090), ports["grpc"].ContainerPort)
+		assert.Equal(suite.T(), core.ProtocolUDP, ports["stats"].Protocol)
+	}
+
+	s := core.Service{}
+	err = fetchWithDefaults(dnn, &s)
+	if assert.NoError(suite.T(), err, "service was not created") {
+		ports := map[string]core.ServicePort{}
+		for _, port := range s.Spec.Ports {
+			ports[port.Name] = port
+		}
+		assert.Equal(suite.T(), int32(8000), ports["public"].Port)
+		assert.Equal(suite.T(), int32(9000), ports["metrics"].Port)
+		assert.Equal(suite.T(), int32(9090), ports["grpc"].Port)
+		assert.Equal(suite.T(), int32(8125), ports["stats"].Port)
+		assert.Equal(suite.T(), core.ProtocolUDP, ports["stats"].Protocol)
+	}
+
+	jsonContent, err := fetchConfig(nn)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), []config.ExtraPortConfig{
+			{Deployment: "api", Name: "grpc", Port: 9090, Protocol: "TCP"},
+			{Deployment: "api", Name: "stats", Port: 8125, Protocol: "UDP"},
+		}, jsonContent.ExtraPorts)
+	}
+}
+
+func (suite *TestSuite) TestGRPCWebProtocol() {
+	logger.Info("Creating ClowdApp serving grpc on its public port")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "grpc-protocol",
+		Namespace: "grpc-protocol",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:        "api",
+				PodSpec:     crd.PodSpec{Image: "test:test"},
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true, Protocol: "grpc"}},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	dnn := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(dnn, &d)
+	if assert.NoError(suite.T(), err, "deployment was not created") {
+		c := d.Spec.Template.Spec.Containers[0]
+		if assert.NotNil(suite.T(), c.LivenessProbe.GRPC) {
+			assert.Equal(suite.T(), int32(8000), c.LivenessProbe.GRPC.Port)
+		}
+		if assert.NotNil(suite.T(), c.ReadinessProbe.GRPC) {
+			assert.Equal(suite.T(), int32(8000), c.ReadinessProbe.GRPC.Port)
+		}
+		assert.Nil(suite.T(), c.LivenessProbe.HTTPGet)
+	}
+
+	s := core.Service{}
+	err = fetchWithDefaults(dnn, &s)
+	if assert.NoError(suite.T(), err, "service was not created") {
+		for _, port := range s.Spec.Ports {
+			if port.Name == "public" && assert.NotNil(suite.T(), port.AppProtocol) {
+				assert.Equal(suite.T(), "grpc", *port.AppProtocol)
+			}
+		}
+	}
+
+	jsonContent, err := fetchConfig(nn)
+	if assert.NoError(suite.T(), err) {
+		assert.Equal(suite.T(), []config.WebProtocolConfig{
+			{Deployment: "api", Protocol: "grpc"},
+		}, jsonContent.WebProtocols)
+	}
+}
+
+func (suite *TestSuite) TestSelfSignedTLS() {
+	logger.Info("Creating ClowdApp with a self-signed TLS sidecar")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "self-signed-tls",
+		Namespace: "self-signed-tls",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.Providers.Web.TLS = crd.TLS{
+			Enabled:     true,
+			Port:        8800,
+			PrivatePort: 10800,
+			Mode:        "self-signed",
+		}
+	})
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name:        "api",
+				PodSpec:     crd.PodSpec{Image: "test:test"},
+				WebServices: crd.WebServices{Public: crd.PublicWebService{Enabled: true}},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	dnn := app.GetDeploymentNamespacedName(&app.Spec.Deployments[0])
+
+	secret := core.Secret{}
+	err = fetchWithDefaults(types.NamespacedName{Name: dnn.Name + "-serving-cert", Namespace: dnn.Namespace}, &secret)
+	if assert.NoError(suite.T(), err, "serving cert secret was not created") {
+		assert.Equal(suite.T(), core.SecretTypeTLS, secret.Type)
+		assert.NotEmpty(suite.T(), secret.Data[core.TLSCertKey])
+		assert.NotEmpty(suite.T(), secret.Data[core.TLSPrivateKeyKey])
+	}
+
+	ca := core.ConfigMap{}
+	err = fetchWithDefaults(types.NamespacedName{Name: nn.Name + "-tls-ca", Namespace: nn.Namespace}, &ca)
+	if assert.NoError(suite.T(), err, "tls ca config map was not created") {
+		assert.NotEmpty(suite.T(), ca.Data["service-ca.crt"])
+	}
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(dnn, &d)
+	if assert.NoError(suite.T(), err, "deployment was not created") {
+		volumes := map[string]core.Volume{}
+		for _, v := range d.Spec.Template.Spec.Volumes {
+			volumes[v.Name] = v
+		}
+		if assert.NotNil(suite.T(), volumes["envoy-tls"].Secret) {
+			assert.Equal(suite.T(), dnn.Name+"-serving-cert", volumes["envoy-tls"].Secret.SecretName)
+		}
+		if assert.NotNil(suite.T(), volumes["tls-ca"].ConfigMap) {
+			assert.Equal(suite.T(), nn.Name+"-tls-ca", volumes["tls-ca"].ConfigMap.Name)
+		}
+	}
+
+	s := core.Service{}
+	err = fetchWithDefaults(dnn, &s)
+	if assert.NoError(suite.T(), err, "service was not created") {
+		ports := map[string]int32{}
+		for _, port := range s.Spec.Ports {
+			ports[port.Name] = port.Port
+		}
+		assert.Equal(suite.T(), int32(8800), ports["tls"])
+		assert.NotContains(suite.T(), s.Annotations, "service.beta.openshift.io/serving-cert-secret-name")
+	}
+
+	jsonContent, err := fetchConfig(nn)
+	if assert.NoError(suite.T(), err) {
+		if assert.NotNil(suite.T(), jsonContent.TlsPort) {
+			assert.Equal(suite.T(), 8800, *jsonContent.TlsPort)
+		}
+		if assert.NotNil(suite.T(), jsonContent.TlsCAPath) {
+			assert.Equal(suite.T(), "/cdapp/certs/service-ca.crt", *jsonContent.TlsCAPath)
+		}
+	}
+}
+
+func (suite *TestSuite) TestInvalidAutoScalerTrigger() {
+	logger.Info("Creating ClowdApp with an invalid autoscaler trigger")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "invalid-trigger",
+		Namespace: "invalid-trigger",
+	}
+
+	objMeta := metav1.ObjectMeta{
+		Name:      nn.Name,
+		Namespace: nn.Namespace,
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: objMeta,
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "testpod",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+				},
+				AutoScaler: &crd.AutoScaler{
+					Triggers: []keda.ScaleTriggers{{
+						Type: "cpu",
+						Metadata: map[string]string{
+							"type": "Utilization",
+						},
+					}},
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	assert.Eventually(suite.T(), func() bool {
+		fetchedApp := crd.ClowdApp{}
+		if err := k8sClient.Get(ctx, nn, &fetchedApp); err != nil {
+			return false
+		}
+		for _, condition := range fetchedApp.Status.Conditions {
+			if condition.Type == crd.AutoScalerTriggersInvalid {
+				return condition.Status == core.ConditionTrue && strings.Contains(condition.Reason, "missing metadata key [value]")
+			}
+		}
+		return false
+	}, time.Second*30, time.Second*1)
+
+	// The failed reconcile is recorded, but the app has never reconciled successfully
+	fetchedApp := crd.ClowdApp{}
+	err = k8sClient.Get(ctx, nn, &fetchedApp)
+	assert.NoError(suite.T(), err)
+	assert.NotNil(suite.T(), fetchedApp.Status.LastReconcileTime)
+	assert.Nil(suite.T(), fetchedApp.Status.LastSuccessfulReconcileTime)
+
+	scaler := keda.ScaledObject{}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "invalid-trigger-testpod", Namespace: nn.Namespace}, &scaler)
+	assert.True(suite.T(), k8serr.IsNotFound(err), "scaled object was created for an invalid trigger")
+}
+
+func (suite *TestSuite) TestAppNamespaceResolution() {
+	logger.Info("Creating ClowdApp outside of the environment's target namespace")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "tenant",
+		Namespace: "tenant-app",
+	}
+	envNamespace := "tenant-env"
+	kafkaNN := types.NamespacedName{
+		Name:      "tenant-kafka",
+		Namespace: "tenant-kafka",
+	}
+
+	for _, ns := range []string{nn.Namespace, envNamespace, kafkaNN.Namespace} {
+		err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+		assert.NoError(suite.T(), err)
+	}
+
+	objMeta := metav1.ObjectMeta{
+		Name:      nn.Name,
+		Namespace: nn.Namespace,
+	}
+
+	env := createClowdEnvironment(objMeta)
+	env.Spec.TargetNamespace = envNamespace
+	env.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
+	env.Spec.Providers.Kafka.Cluster.Name = kafkaNN.Name
+	env.Spec.Providers.Kafka.Cluster.Namespace = kafkaNN.Namespace
+
+	app := crd.ClowdApp{
+		ObjectMeta: objMeta,
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "testpod",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+				},
+			}},
+			KafkaTopics: []crd.KafkaTopicSpec{{
+				TopicName: "tenant-topic",
+			}},
+		},
+	}
+
+	ch := make(chan int)
+
+	go applyKafkaStatus(suite.T(), ch, kafkaNN, true)
+
+	err := k8sClient.Create(ctx, &env)
+	assert.NoError(suite.T(), err)
+
+	err = k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	<-ch // wait for kafka status to be applied
+
+	// The app's resources land in the app's own namespace...
+	d := apps.Deployment{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "tenant-testpod", Namespace: nn.Namespace}, &d)
+	assert.NoError(suite.T(), err)
+
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "tenant-testpod", Namespace: envNamespace}, &apps.Deployment{})
+	assert.True(suite.T(), k8serr.IsNotFound(err), "deployment was created in the environment's target namespace")
+
+	// ...while the topics are created alongside the shared kafka cluster
+	topic := strimzi.KafkaTopic{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "tenant-topic", Namespace: kafkaNN.Namespace}, &topic)
+	assert.NoError(suite.T(), err)
+
+	jsonContent, err := fetchConfig(nn)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "kafka-bootstrap.tenant-kafka.svc", jsonContent.Kafka.Brokers[0].Hostname)
+}
+
+func (suite *TestSuite) TestReconcileTracing() {
+	logger.Info("Creating ClowdApp and checking the spans of its reconcile")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "traced",
+		Namespace: "traced",
+	}
+
+	objMeta := metav1.ObjectMeta{
+		Name:      nn.Name,
+		Namespace: nn.Namespace,
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: objMeta,
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "testpod",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+				},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	d := apps.Deployment{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "traced-testpod", Namespace: nn.Namespace}, &d)
+	assert.NoError(suite.T(), err)
+
+	// The provider spans are children of the span of the app's reconcile
+	assert.Eventually(suite.T(), func() bool {
+		spans := spanExporter.GetSpans()
+		for _, span := range spans {
+			if span.Name != "ClowdApp.Reconcile" {
+				continue
+			}
+			attrs := attribute.NewSet(span.Attributes...)
+			if name, ok := attrs.Value("app"); !ok || name.AsString() != nn.Name {
+				continue
+			}
+			if namespace, ok := attrs.Value("namespace"); !ok || namespace.AsString() != nn.Namespace {
+				continue
+			}
+			for _, child := range spans {
+				if child.Name == "provider deployment" && child.Parent.SpanID() == span.SpanContext.SpanID() {
+					return true
+				}
+			}
+		}
+		return false
+	}, time.Second*30, time.Second*1)
+}
+
+func (suite *TestSuite) TestKafkaConnectNotReady() {
+	logger.Info("Creating ClowdApp while the KafkaConnect cluster never becomes ready")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "connect-waiting",
+		Namespace: "connect-waiting",
+	}
+	kafkaNN := types.NamespacedName{
+		Name:      "connect-waiting-kafka",
+		Namespace: "connect-waiting-kafka",
+	}
+
+	for _, ns := range []string{nn.Namespace, kafkaNN.Namespace} {
+		err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: ns}})
+		assert.NoError(suite.T(), err)
+	}
+
+	objMeta := metav1.ObjectMeta{
+		Name:      nn.Name,
+		Namespace: nn.Namespace,
+	}
+
+	env := createClowdEnvironment(objMeta)
+	env.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
+	env.Spec.Providers.Kafka.Cluster.Name = kafkaNN.Name
+	env.Spec.Providers.Kafka.Cluster.Namespace = kafkaNN.Namespace
+
+	app := crd.ClowdApp{
+		ObjectMeta: objMeta,
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "testpod",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+				},
+			}},
+			KafkaTopics: []crd.KafkaTopicSpec{{
+				TopicName: "connect-waiting-topic",
+			}},
+		},
+	}
+
+	ch := make(chan int)
+
+	// only the kafka cluster is marked ready, the connect cluster never is
+	go applyKafkaStatus(suite.T(), ch, kafkaNN, false)
+
+	err := k8sClient.Create(ctx, &env)
+	assert.NoError(suite.T(), err)
+
+	err = k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	<-ch // wait for kafka status to be applied
+
+	// Topics and the app config do not depend on the connect cluster
+	topic := strimzi.KafkaTopic{}
+	err = fetchWithDefaults(types.NamespacedName{Name: "connect-waiting-topic", Namespace: kafkaNN.Namespace}, &topic)
+	assert.NoError(suite.T(), err)
+
+	jsonContent, err := fetchConfig(nn)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "kafka-bootstrap.connect-waiting-kafka.svc", jsonContent.Kafka.Brokers[0].Hostname)
+
+	assert.Eventually(suite.T(), func() bool {
+		fetchedEnv := crd.ClowdEnvironment{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: env.Name}, &fetchedEnv); err != nil {
+			return false
+		}
+		for _, condition := range fetchedEnv.Status.Conditions {
+			if condition.Type == crd.WaitingForKafkaConnect {
+				return condition.Status == core.ConditionTrue && strings.Contains(condition.Reason, kafkaNN.Name)
+			}
+		}
+		return false
+	}, time.Second*30, time.Second*1)
+}
+
+func (suite *TestSuite) TestKafkaClusterAutoscaling() {
+	logger.Info("Creating ClowdEnvironment with a kafka cluster autoscaling target")
+
+	ctx := context.Background()
+
+	kafkaNN := types.NamespacedName{
+		Name:      "kafka-autoscaling",
+		Namespace: "kafka-autoscaling",
+	}
+
+	err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: kafkaNN.Namespace}})
+	assert.NoError(suite.T(), err)
+
+	env := createClowdEnvironment(metav1.ObjectMeta{Name: kafkaNN.Name, Namespace: kafkaNN.Namespace})
+	env.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
+	env.Spec.Providers.Kafka.Cluster.Name = kafkaNN.Name
+	env.Spec.Providers.Kafka.Cluster.Namespace = kafkaNN.Namespace
+	env.Spec.Providers.Kafka.Cluster.Autoscaling = &crd.KafkaClusterAutoscaling{
+		MinReplicas:    3,
+		MaxReplicas:    9,
+		TargetReplicas: 7,
+	}
+
+	err = k8sClient.Create(ctx, &env)
+	assert.NoError(suite.T(), err)
+
+	// Brokers follow the autoscaling target, zookeeper keeps the cluster's replicas
+	cluster := strimzi.Kafka{}
+	assert.Eventually(suite.T(), func() bool {
+		return k8sClient.Get(ctx, kafkaNN, &cluster) == nil
+	}, time.Second*30, time.Second*1)
+
+	assert.Equal(suite.T(), int32(7), cluster.Spec.Kafka.Replicas)
+	assert.Equal(suite.T(), int32(5), cluster.Spec.Zookeeper.Replicas)
+
+	// Raising the target beyond the maximum only scales up to the maximum
+	assert.Eventually(suite.T(), func() bool {
+		fetchedEnv := crd.ClowdEnvironment{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: env.Name}, &fetchedEnv); err != nil {
+			return false
+		}
+		fetchedEnv.Spec.Providers.Kafka.Cluster.Autoscaling.TargetReplicas = 12
+		return k8sClient.Update(ctx, &fetchedEnv) == nil
+	}, time.Second*30, time.Second*1)
+
+	assert.Eventually(suite.T(), func() bool {
+		if err := k8sClient.Get(ctx, kafkaNN, &cluster); err != nil {
+			return false
+		}
+		return cluster.Spec.Kafka.Replicas == 9
+	}, time.Second*30, time.Second*1)
+}
+
+func (suite *TestSuite) TestTargetNamespaceMetadata() {
+	logger.Info("Creating ClowdEnvironments with target namespace metadata")
+
+	ctx := context.Background()
+
+	metadata := crd.TargetNamespaceMetadata{
+		Labels:      map[string]string{"cost-center": "1234"},
+		Annotations: map[string]string{"policy.example.com/tier": "gold"},
+	}
+
+	// A namespace created by Clowder always carries the metadata
+	generated := createClowdEnvironment(metav1.ObjectMeta{Name: "ns-metadata-generated"})
+	generated.Spec.TargetNamespace = ""
+	generated.Spec.TargetNamespaceMetadata = metadata
+	generated.Spec.Providers.Kafka = crd.KafkaConfig{Mode: "none"}
+	generated.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
+
+	err := k8sClient.Create(ctx, &generated)
+	assert.NoError(suite.T(), err)
+
+	// A pre-existing namespace only carries it when merging is requested
+	existingNamespaces := map[string]bool{"ns-metadata-merged": true, "ns-metadata-untouched": false}
+	for name, merge := range existingNamespaces {
+		err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"owner": "team"},
+		}})
+		assert.NoError(suite.T(), err)
+
+		env := createClowdEnvironment(metav1.ObjectMeta{Name: name, Namespace: name})
+		env.Spec.TargetNamespaceMetadata = metadata
+		env.Spec.TargetNamespaceMetadata.MergeExisting = merge
+		env.Spec.Providers.Kafka = crd.KafkaConfig{Mode: "none"}
+		env.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
+
+		err = k8sClient.Create(ctx, &env)
+		assert.NoError(suite.T(), err)
+	}
+
+	hasMetadata := func(namespace string) bool {
+		ns := core.Namespace{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+			return false
+		}
+		return ns.Labels["cost-center"] == "1234" && ns.Annotations["policy.example.com/tier"] == "gold"
+	}
+
+	assert.Eventually(suite.T(), func() bool {
+		fetchedEnv := crd.ClowdEnvironment{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: generated.Name}, &fetchedEnv); err != nil {
+			return false
+		}
+		return fetchedEnv.Status.TargetNamespace != "" && hasMetadata(fetchedEnv.Status.TargetNamespace)
+	}, time.Second*30, time.Second*1)
+
+	assert.Eventually(suite.T(), func() bool {
+		return hasMetadata("ns-metadata-merged")
+	}, time.Second*30, time.Second*1)
+
+	merged := core.Namespace{}
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "ns-metadata-merged"}, &merged)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "team", merged.Labels["owner"], "existing namespace label was not kept")
+
+	// wait for the untouched env to have finished a reconcile before checking its namespace
+	assert.Eventually(suite.T(), func() bool {
+		fetchedEnv := crd.ClowdEnvironment{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: "ns-metadata-untouched"}, &fetchedEnv); err != nil {
+			return false
+		}
+		for _, condition := range fetchedEnv.Status.Conditions {
+			if condition.Type == crd.ReconciliationSuccessful || condition.Type == crd.ReconciliationFailed {
+				return true
+			}
+		}
+		return false
+	}, time.Second*30, time.Second*1)
+	assert.False(suite.T(), hasMetadata("ns-metadata-untouched"), "metadata was merged onto an existing namespace")
+}
+
+func (suite *TestSuite) TestLimitRange() {
+	logger.Info("Creating ClowdEnvironment with a LimitRange")
+
+	ctx := context.Background()
+
+	name := "limit-range"
+
+	err := k8sClient.Create(ctx, &core.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	assert.NoError(suite.T(), err)
+
+	env := createClowdEnvironment(metav1.ObjectMeta{Name: name, Namespace: name})
+	env.Spec.Providers.Kafka = crd.KafkaConfig{Mode: "none"}
+	env.Spec.Providers.Logging = crd.LoggingConfig{Mode: "none"}
+	env.Spec.LimitRange = crd.LimitRangeConfig{
+		Enabled: true,
+		Default: core.ResourceList{
+			core.ResourceCPU:    resource.MustParse("200m"),
+			core.ResourceMemory: resource.MustParse("256Mi"),
+		},
+		DefaultRequest: core.ResourceList{
+			core.ResourceCPU:    resource.MustParse("100m"),
+			core.ResourceMemory: resource.MustParse("128Mi"),
+		},
+		Max: core.ResourceList{
+			core.ResourceCPU:    resource.MustParse("2"),
+			core.ResourceMemory: resource.MustParse("4Gi"),
+		},
+	}
+
+	err = k8sClient.Create(ctx, &env)
+	assert.NoError(suite.T(), err)
+
+	limitRange := core.LimitRange{}
+	err = fetchWithDefaults(types.NamespacedName{Name: name, Namespace: name}, &limitRange)
+	if !assert.NoError(suite.T(), err, "limit range was not created") {
+		return
+	}
+
+	if assert.Len(suite.T(), limitRange.Spec.Limits, 1) {
+		limits := limitRange.Spec.Limits[0]
+		assert.Equal(suite.T(), core.LimitTypeContainer, limits.Type)
+		assert.Equal(suite.T(), "200m", limits.Default.Cpu().String())
+		assert.Equal(suite.T(), "256Mi", limits.Default.Memory().String())
+		assert.Equal(suite.T(), "100m", limits.DefaultRequest.Cpu().String())
+		assert.Equal(suite.T(), "128Mi", limits.DefaultRequest.Memory().String())
+		assert.Equal(suite.T(), "2", limits.Max.Cpu().String())
+		assert.Equal(suite.T(), "4Gi", limits.Max.Memory().String())
+	}
+
+	if assert.Len(suite.T(), limitRange.GetOwnerReferences(), 1) {
+		assert.Equal(suite.T(), "ClowdEnvironment", limitRange.GetOwnerReferences()[0].Kind)
+	}
+}
+
+func (suite *TestSuite) TestRedisConfig() {
+	logger.Info("Creating ClowdApp with an in-memory DB sized by the environment")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "redis-config",
+		Namespace: "redis-config",
+	}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.Providers.InMemoryDB = crd.InMemoryDBConfig{
+			Mode:            "redis",
+			MaxMemory:       "100mb",
+			MaxMemoryPolicy: "allkeys-lru",
+		}
+	})
+
+	app := crd.ClowdApp{
+		ObjectMeta: metav1.ObjectMeta{Name: nn.Name, Namespace: nn.Namespace},
+		Spec: crd.ClowdAppSpec{
+			EnvName:    env.Name,
+			InMemoryDB: true,
+			Deployments: []crd.Deployment{{
+				Name:    "api",
+				PodSpec: crd.PodSpec{Image: "test:test"},
+			}},
+		},
+	}
+
+	err := k8sClient.Create(ctx, &app)
+	assert.NoError(suite.T(), err)
+
+	redisNN := types.NamespacedName{Name: "redis-config-redis", Namespace: nn.Namespace}
+	cm := &core.ConfigMap{}
+	err = fetchWithDefaults(redisNN, cm)
+	if assert.NoError(suite.T(), err, "redis config map was not created") {
+		assert.Equal(suite.T(), "stop-writes-on-bgsave-error no\nmaxmemory 100mb\nmaxmemory-policy allkeys-lru\n", cm.Data["redis.conf"])
+	}
+
+	d := &apps.Deployment{}
+	err = fetchWithDefaults(redisNN, d)
+	if assert.NoError(suite.T(), err, "redis deployment was not created") {
+		resources := d.Spec.Template.Spec.Containers[0].Resources
+		assert.Equal(suite.T(), int64(100*1024*1024), resources.Requests.Memory().Value())
+		assert.Equal(suite.T(), int64(125*1024*1024), resources.Limits.Memory().Value())
+	}
+
+	// a change of the environment is rendered into the config of the apps
+	assert.Eventually(suite.T(), func() bool {
+		env := crd.ClowdEnvironment{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: nn.Name}, &env); err != nil {
+			return false
+		}
+		env.Spec.Providers.InMemoryDB.MaxMemoryPolicy = "volatile-lru"
+		return k8sClient.Update(ctx, &env) == nil
+	}, time.Second*30, time.Second*1)
+
+	assert.Eventually(suite.T(), func() bool {
+		cm := &core.ConfigMap{}
+		if err := k8sClient.Get(ctx, redisNN, cm); err != nil {
+			return false
+		}
+		return cm.Data["redis.conf"] == "stop-writes-on-bgsave-error no\nmaxmemory 100mb\nmaxmemory-policy volatile-lru\n"
+	}, time.Second*30, time.Second*1, "redis config was not updated with the environment")
+}
+
+func metadataValidation(t *testing.T, app *crd.ClowdApp, jsonContent *config.AppConfig) {
+	assert.Equal(t, *jsonContent.Metadata.Name, app.Name)
+	assert.Equal(t, *jsonContent.Metadata.EnvName, app.Spec.EnvName)
+
+	images := map[string]string{}
+	for _, deployment := range jsonContent.Metadata.Deployments {
+		images[deployment.Name] = deployment.Image
+		assert.NotNil(t, deployment.Resources, "resources missing from metadata of %s", deployment.Name)
+		if assert.NotNil(t, deployment.ImageTag, "image tag missing from metadata of %s", deployment.Name) {
+			assert.Equal(t, "test", *deployment.ImageTag)
+		}
+	}
+	for _, deployment := range app.Spec.Deployments {
+		assert.Equal(t, deployment.PodSpec.Image, images[deployment.Name])
+	}
+	assert.Len(t, jsonContent.Metadata.Deployments, len(app.Spec.Deployments))
+}
+
+func kafkaValidation(t *testing.T, env *crd.ClowdEnvironment, app *crd.ClowdApp, jsonContent *config.AppConfig) {
+	// Kafka validation
+
+	topicWithPartitionsReplicasName := "inventory"
+	topicWithPartitionsReplicasNamespacedName := types.NamespacedName{
+		Namespace: env.Spec.Providers.Kafka.Cluster.Namespace,
+		Name:      topicWithPartitionsReplicasName,
+	}
+
+	topicNoPartitionsReplicasName := "inventory-default-values"
+	topicNoPartitionsReplicasNamespacedName := types.NamespacedName{
+		Namespace: env.Spec.Providers.Kafka.Cluster.Namespace,
+		Name:      topicNoPartitionsReplicasName,
+	}
+
+	for i, kafkaTopic := range app.Spec.KafkaTopics {
+		assert.Equal(t, kafkaTopic.TopicName, jsonContent.Kafka.Topics[i].RequestedName, "wrong topic name set on app's config")
+		assert.Equal(t, kafkaTopic.TopicName, jsonContent.Kafka.Topics[i].Name, "wrong generated topic name set on app's config")
+	}
+
+	assert.NotEqual(t, len(jsonContent.Kafka.Brokers[0].Hostname), 0, "kafka broker hostname is not set")
+
+	for _, topic := range []types.NamespacedName{topicWithPartitionsReplicasNamespacedName, topicNoPartitionsReplicasNamespacedName} {
+		fetchedTopic := strimzi.KafkaTopic{}
+
+		// fetch topic, make sure it was provisioned
+		err := fetchWithDefaults(topic, &fetchedTopic)
+		assert.NoError(t, err, "error fetching topic")
+		assert.NotNil(t, fetchedTopic.Spec, "kafkaTopic '%s' not provisioned in namespace", topic.Name)
+
+		// check that configured partitions/replicas matches
+		expectedReplicas := int32(0)
+		expectedPartitions := int32(0)
+		if topic.Name == topicWithPartitionsReplicasName {
+			expectedReplicas = int32(5)
+			expectedPartitions = int32(5)
+		}
+		if topic.Name == topicNoPartitionsReplicasName {
+			expectedReplicas = int32(3)
+			expectedPartitions = int32(3)
+		}
+
+		assert.Equal(t, *fetchedTopic.Spec.Replicas, expectedReplicas, "bad topic replica count for '%s': %d; expected %d", topic.Name, fetchedTopic.Spec.Replicas, expectedReplicas)
+		assert.Equal(t, *fetchedTopic.Spec.Partitions, expectedPartitions, "bad topic replica count for '%s': %d; expected %d", topic.Name, fetchedTopic.Spec.Partitions, expectedPartitions)
+	}
+}
+
+func topicAllowListValidation(t *testing.T, app *crd.ClowdApp) {
+	cm := core.ConfigMap{}
+	err := fetchWithDefaults(kafka.GetTopicAllowListName(app), &cm)
+	assert.NoError(t, err, "error fetching topic allow-list")
+
+	allowList := []string{}
+	err = json.Unmarshal([]byte(cm.Data[kafka.TopicAllowListKey]), &allowList)
+	assert.NoError(t, err, "topic allow-list is not valid json")
+
+	assert.Len(t, allowList, len(app.Spec.KafkaTopics))
+	for _, kafkaTopic := range app.Spec.KafkaTopics {
+		assert.Contains(t, allowList, kafkaTopic.TopicName, "declared topic missing from allow-list")
+	}
+}
+
+func clowdWatchValidation(t *testing.T, jsonContent *config.AppConfig, cwData map[string]string) {
+	// Cloudwatch validation
+	cwConfigVals := map[string]string{
+		"aws_access_key_id":     jsonContent.Logging.Cloudwatch.AccessKeyId,
+		"aws_secret_access_key": jsonContent.Logging.Cloudwatch.SecretAccessKey,
+		"log_group_name":        jsonContent.Logging.Cloudwatch.LogGroup,
+		"aws_region":            jsonContent.Logging.Cloudwatch.Region,
+	}
+
+	for key, val := range cwData {
+		assert.Equal(t, val, cwConfigVals[key], "wrong cloudwatch config value")
+	}
+}
+
+func scaledObjectValidation(t *testing.T, scaler *keda.ScaledObject) {
+	// Scaled object validation
+	expectTarget := keda.ScaleTarget{
+		Kind: "Deployment",
+		Name: "test-testpod",
+	}
+	expectedTrigger := keda.ScaleTriggers{
+		Type: "cpu",
+		Metadata: map[string]string{
+			"type":  "Utilization",
+			"value": "50",
+		},
+	}
+	for _, trigger := range scaler.Spec.Triggers {
+		assert.Equal(t, expectedTrigger.Type, trigger.Type)
+		assert.Equal(t, expectedTrigger.Metadata, trigger.Metadata)
+	}
+
+	assert.Equal(t, expectTarget.Kind, scaler.Spec.ScaleTargetRef.Kind)
+	assert.Equal(t, expectTarget.Name, scaler.Spec.ScaleTargetRef.Name)
+}
+
+func fetchWithDefaults(name types.NamespacedName, resource client.Object) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	return fetch(ctx, name, resource, 20*6, 500*time.Millisecond)
+}
+
+func fetch(ctx context.Context, name types.NamespacedName, resource client.Object, retryCount int, sleepTime time.Duration) error {
+	var err error
+
+	for i := 1; i <= retryCount; i++ {
+		err = k8sClient.Get(ctx, name, resource)
+
+		if err == nil {
+			return nil
+		} else if !k8serr.IsNotFound(err) {
+			return err
+		}
+
+		time.Sleep(sleepTime)
+	}
+
+	return err
+}
+
+func createEphemeralManagedSecret(name string, namespace string, cwData map[string]string) error {
+	secret := core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		StringData: cwData,
+	}
+
+	return k8sClient.Create(context.Background(), &secret)
+}
+
+var ephemManagedKafkaHTTPLog = []map[string]string{}
+
+func (suite *TestSuite) TestManagedKafkaConnectBuilderCreate() {
+	logger.Info("Starting ephemeral managed kafka e2e test")
+
+	nn := types.NamespacedName{
+		Name:      "managed-ephemeral-kafka",
+		Namespace: "default",
+	}
+
+	secretData := make(map[string]string)
+	secretData["client.id"] = "username"
+	secretData["client.secret"] = "password"
+	secretData["hostname"] = "hostname:443"
+	secretData["admin.url"] = "https://admin.url"
+	secretData["token.url"] = "https://token.url"
+	secName := "managed-ephem-secret"
+	err := createEphemeralManagedSecret(secName, nn.Namespace, secretData)
+	assert.Nil(suite.T(), err)
+
+	cwData := map[string]string{
+		"aws_access_key_id":     "key_id",
+		"aws_secret_access_key": "secret",
+		"log_group_name":        "default",
+		"aws_region":            "us-east-1",
+	}
+
+	_ = createCloudwatchSecret(&cwData)
+
+	mockClient := MockEphemManagedKafkaHTTPClient{
+		topicList:  make(map[string]bool),
+		partitions: make(map[string]int),
+	}
+
+	kafka.ClientCreator = func(provider *providers.Provider, clientCred clientcredentials.Config) kafka.HTTPClient {
+		return &mockClient
+	}
+
+	env, app, err := createManagedKafkaClowderStack(nn, secName)
+
+	assert.Nil(suite.T(), err)
+
+	assert.NotNil(suite.T(), app)
+	assert.NotNil(suite.T(), env)
 
 	mockClient.createStaticTopic("ephemeral-dont-delete")
 	mockClient.createStaticTopic("ephemeral.managed.kafka.name.inventory")
@@ -718,38 +3155,96 @@ func (suite *TestSuite) TestManagedKafkaConnectBuilderCreate() {
 	assert.Equal(suite.T(), secName, ephemManagedSecret.Name)
 	assert.Equal(suite.T(), nn.Namespace, ephemManagedSecret.Namespace)
 	assert.Eventually(suite.T(), func() bool {
-		return assert.Contains(suite.T(), mockClient.topicList, "ephemeral-managed-kafka-name-inventory")
+		return mockClient.hasTopic("ephemeral-managed-kafka-name-inventory")
 	}, time.Second*15, time.Second*1)
 	assert.Eventually(suite.T(), func() bool {
-		return assert.Contains(suite.T(), mockClient.topicList, "ephemeral-managed-kafka-name-inventory-default-values")
+		return mockClient.hasTopic("ephemeral-managed-kafka-name-inventory-default-values")
 	}, time.Second*15, time.Second*1)
+	assert.Equal(suite.T(), 1, mockClient.topicPartitions("ephemeral-managed-kafka-name-inventory"))
 
 	ctx := context.Background()
+
+	// increasing the partitions of a topic patches the existing topic
+	assert.Eventually(suite.T(), func() bool {
+		fetched := crd.ClowdApp{}
+		if err := k8sClient.Get(ctx, types.NamespacedName{Name: app.Name, Namespace: app.Namespace}, &fetched); err != nil {
+			return false
+		}
+		fetched.Spec.KafkaTopics[0].Partitions = 3
+		return k8sClient.Update(ctx, &fetched) == nil
+	}, time.Second*15, time.Second*1)
+	assert.Eventually(suite.T(), func() bool {
+		patches := mockClient.topicPatches("ephemeral-managed-kafka-name-inventory")
+		return len(patches) > 0 && patches[len(patches)-1].NumPartitions == 3
+	}, time.Second*15, time.Second*1)
+	assert.Equal(suite.T(), 3, mockClient.topicPartitions("ephemeral-managed-kafka-name-inventory"))
+
+	// deleting an app only deletes the topics that no other app requests
+	other := makeClowdApp(*env, metav1.ObjectMeta{Name: "ephemeral-managed-kafka-other", Namespace: nn.Namespace})
+	other.Spec.KafkaTopics = []crd.KafkaTopicSpec{{TopicName: "inventory"}, {TopicName: "other"}}
+	err = k8sClient.Create(ctx, &other)
+	assert.NoError(suite.T(), err)
+	assert.Eventually(suite.T(), func() bool {
+		return mockClient.hasTopic("ephemeral-managed-kafka-name-other")
+	}, time.Second*15, time.Second*1)
+
+	err = k8sClient.Delete(ctx, &other)
+	assert.NoError(suite.T(), err, "couldn't delete resource")
+	assert.Eventually(suite.T(), func() bool {
+		return !mockClient.hasTopic("ephemeral-managed-kafka-name-other")
+	}, time.Second*15, time.Second*1)
+	assert.True(suite.T(), mockClient.hasTopic("ephemeral-managed-kafka-name-inventory"))
+	assert.True(suite.T(), mockClient.hasTopic("ephemeral-managed-kafka-name-inventory-default-values"))
+
 	err = k8sClient.Delete(ctx, env)
 	assert.NoError(suite.T(), err, "couldn't delete resource")
 
 	assert.Eventually(suite.T(), func() bool {
-		return assert.NotContains(suite.T(), mockClient.topicList, "ephemeral-managed-kafka-name-inventory")
+		return !mockClient.hasTopic("ephemeral-managed-kafka-name-inventory")
 	}, time.Second*15, time.Second*1)
 	assert.Eventually(suite.T(), func() bool {
-		return assert.NotContains(suite.T(), mockClient.topicList, "ephemeral-managed-kafka-name-inventory-default-values")
+		return !mockClient.hasTopic("ephemeral-managed-kafka-name-inventory-default-values")
 	}, time.Second*15, time.Second*1)
 	assert.Eventually(suite.T(), func() bool {
-		return assert.NotContains(suite.T(), mockClient.topicList, "ephemeral.managed.kafka.name.inventory")
+		return !mockClient.hasTopic("ephemeral.managed.kafka.name.inventory")
 	}, time.Second*15, time.Second*1)
 	assert.Eventually(suite.T(), func() bool {
-		return assert.Contains(suite.T(), mockClient.topicList, "ephemeral-dont-delete")
+		return mockClient.hasTopic("ephemeral-dont-delete")
 	}, time.Second*15, time.Second*1)
 }
 
+// MockEphemManagedKafkaHTTPClient is safe for concurrent use, as topics are deleted concurrently
 type MockEphemManagedKafkaHTTPClient struct {
-	topicList map[string]bool
+	mu         sync.Mutex
+	topicList  map[string]bool
+	partitions map[string]int
+	patches    map[string][]kafka.Settings
 }
 
 func (m *MockEphemManagedKafkaHTTPClient) createStaticTopic(topicName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.topicList[topicName] = true
 }
 
+func (m *MockEphemManagedKafkaHTTPClient) hasTopic(topicName string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.topicList[topicName]
+}
+
+func (m *MockEphemManagedKafkaHTTPClient) topicPartitions(topicName string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.partitions[topicName]
+}
+
+func (m *MockEphemManagedKafkaHTTPClient) topicPatches(topicName string) []kafka.Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]kafka.Settings{}, m.patches[topicName]...)
+}
+
 func (m *MockEphemManagedKafkaHTTPClient) makeResp(body string, code int) http.Response {
 	readBody := io.NopCloser(strings.NewReader(body))
 	resp := http.Response{
@@ -787,9 +3282,21 @@ func (m *MockEphemManagedKafkaHTTPClient) logResponse(resp *http.Response) {
 }
 
 func (m *MockEphemManagedKafkaHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	url := req.URL.String()
 	var resp *http.Response
 	if req.Method == "PATCH" {
+		items := strings.Split(url, "/")
+		settings := kafka.Settings{}
+		if err := json.NewDecoder(req.Body).Decode(&settings); err != nil {
+			return nil, err
+		}
+		if m.patches == nil {
+			m.patches = map[string][]kafka.Settings{}
+		}
+		m.patches[items[len(items)-1]] = append(m.patches[items[len(items)-1]], settings)
+		m.partitions[items[len(items)-1]] = settings.NumPartitions
 		r := m.makeResp(`{"msg":"topic patched"}`, 200)
 		resp = &r
 	} else if req.Method == "DELETE" {
@@ -803,6 +3310,8 @@ func (m *MockEphemManagedKafkaHTTPClient) Do(req *http.Request) (*http.Response,
 }
 
 func (m *MockEphemManagedKafkaHTTPClient) Get(url string) (*http.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	var resp http.Response
 	items := strings.Split(url, "/")
 	if len(items) == 6 {
@@ -816,13 +3325,20 @@ func (m *MockEphemManagedKafkaHTTPClient) Get(url string) (*http.Response, error
 		}
 		resp = m.makeResp(string(body), 200)
 	} else if len(items) == 7 {
-		for k := range m.topicList {
-			if items[len(items)-1] == k {
-				resp = m.makeResp(`{"msg":"topic found"}`, 200)
-				break
+		name := items[len(items)-1]
+		if m.topicList[name] {
+			topic := kafka.Topic{Name: name}
+			for i := 0; i < m.partitions[name]; i++ {
+				topic.Partitions = append(topic.Partitions, kafka.TopicPartition{Partition: i})
+			}
+			body, err := json.Marshal(topic)
+			if err != nil {
+				return nil, fmt.Errorf("can not get topic: %s", err)
 			}
+			resp = m.makeResp(string(body), 200)
+		} else {
+			resp = m.makeResp(`{"msg":"topic not found"}`, 404)
 		}
-		resp = m.makeResp(`{"msg":"topic not found"}`, 404)
 	}
 
 	m.logResponse(&resp)
@@ -836,12 +3352,177 @@ func (m *MockEphemManagedKafkaHTTPClient) Post(_, _ string, body io.Reader) (*ht
 	if err != nil {
 		return nil, err
 	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.topicList[kafkaObj.Name] = true
+	m.partitions[kafkaObj.Name] = kafkaObj.Settings.NumPartitions
 	resp := m.makeResp(`{"msg":"topic created"}`, 200)
 	m.logResponse(&resp)
 	return &resp, nil
 }
 
+func (suite *TestSuite) TestPlanMakesNoExternalCalls() {
+	logger.Info("Planning a ClowdApp against MinIO and a shared database")
+
+	ctx := context.Background()
+
+	nn := types.NamespacedName{
+		Name:      "plan-external",
+		Namespace: "plan-external",
+	}
+
+	objMeta := metav1.ObjectMeta{
+		Name:      nn.Name,
+		Namespace: nn.Namespace,
+	}
+
+	env := createTestEnvironment(suite.T(), nn, func(env *crd.ClowdEnvironment) {
+		env.Spec.Providers.Database = crd.DatabaseConfig{Mode: "shared"}
+		env.Spec.Providers.ObjectStore = crd.ObjectStoreConfig{Mode: "minio"}
+	})
+
+	// The MinIO credentials are created by the environment
+	err := fetchWithDefaults(types.NamespacedName{Name: fmt.Sprintf("%s-minio", env.Name), Namespace: nn.Namespace}, &core.Secret{})
+	assert.NoError(suite.T(), err)
+
+	// The shared database server of an environment is only deployed once an app asks for it, so
+	// its credentials are created here
+	err = k8sClient.Create(ctx, &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-db-v12", env.Name),
+			Namespace: nn.Namespace,
+		},
+		StringData: map[string]string{
+			"hostname": fmt.Sprintf("%s-db-v12.%s.svc", env.Name, nn.Namespace),
+			"port":     "5432",
+			"username": "user",
+			"password": "password",
+			"pgPass":   "pgPass",
+			"name":     env.Name,
+		},
+	})
+	assert.NoError(suite.T(), err)
+
+	app := crd.ClowdApp{
+		ObjectMeta: objMeta,
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "testpod",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+				},
+			}},
+			Database: crd.DatabaseSpec{
+				Name: "plan-external",
+			},
+			ObjectStore: []string{"plan-external-bucket"},
+		},
+	}
+
+	body, err := json.Marshal(PlanRequest{App: app})
+	assert.NoError(suite.T(), err)
+
+	// Neither MinIO nor the database server run in the test environment and their hostnames don't
+	// resolve, so a plan that reached either of them would fail
+	resp, err := http.Post("http://127.0.0.1:2019/plan/", "application/json", strings.NewReader(string(body)))
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode, string(respBody))
+
+	objects := []PlannedObject{}
+	assert.NoError(suite.T(), json.Unmarshal(respBody, &objects))
+
+	var planned bool
+	for _, object := range objects {
+		metadata, _ := object.Object["metadata"].(map[string]interface{})
+		if object.Object["kind"] == "Secret" && metadata["name"] == "plan-external-db" {
+			planned = true
+		}
+	}
+	assert.True(suite.T(), planned, "the database secret of the app was not planned")
+
+	// Nothing was written to the cluster
+	err = k8sClient.Get(ctx, types.NamespacedName{Name: "plan-external-db", Namespace: nn.Namespace}, &core.Secret{})
+	assert.True(suite.T(), k8serr.IsNotFound(err))
+}
+
+func (suite *TestSuite) TestPlanRedactsLocalDBPasswords() {
+	logger.Info("Planning a ClowdApp with a local database")
+
+	nn := types.NamespacedName{
+		Name:      "plan-local-db",
+		Namespace: "plan-local-db",
+	}
+
+	objMeta := metav1.ObjectMeta{
+		Name:      nn.Name,
+		Namespace: nn.Namespace,
+	}
+
+	env := createTestEnvironment(suite.T(), nn, nil)
+
+	app := crd.ClowdApp{
+		ObjectMeta: objMeta,
+		Spec: crd.ClowdAppSpec{
+			EnvName: env.Name,
+			Deployments: []crd.Deployment{{
+				Name: "testpod",
+				PodSpec: crd.PodSpec{
+					Image: "test:test",
+				},
+			}},
+			Database: crd.DatabaseSpec{
+				Name: "plan-local-db",
+			},
+		},
+	}
+
+	body, err := json.Marshal(PlanRequest{App: app})
+	assert.NoError(suite.T(), err)
+
+	resp, err := http.Post("http://127.0.0.1:2019/plan/", "application/json", strings.NewReader(string(body)))
+	if !assert.NoError(suite.T(), err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), http.StatusOK, resp.StatusCode, string(respBody))
+
+	objects := []PlannedObject{}
+	assert.NoError(suite.T(), json.Unmarshal(respBody, &objects))
+
+	var dbDeployment *apps.Deployment
+	for _, object := range objects {
+		metadata, _ := object.Object["metadata"].(map[string]interface{})
+		if object.Object["kind"] == "Deployment" && metadata["name"] == "plan-local-db-db" {
+			dbDeployment = &apps.Deployment{}
+			err = runtime.DefaultUnstructuredConverter.FromUnstructured(object.Object, dbDeployment)
+			assert.NoError(suite.T(), err)
+		}
+	}
+	if !assert.NotNil(suite.T(), dbDeployment, "the database deployment of the app was not planned") {
+		return
+	}
+
+	// The passwords of the database are set as literal env vars of its deployment
+	passwords := 0
+	for _, envVar := range dbDeployment.Spec.Template.Spec.Containers[0].Env {
+		if strings.Contains(envVar.Name, "PASSWORD") {
+			passwords++
+			assert.Equal(suite.T(), redactedValue, envVar.Value, envVar.Name)
+		}
+	}
+	assert.Equal(suite.T(), 3, passwords)
+}
+
 func TestSuiteRun(t *testing.T) {
 	suite.Run(t, new(TestSuite))
 }
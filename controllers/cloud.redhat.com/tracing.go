@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/client-go/rest"
+)
+
+// tracerName names the tracer of the spans emitted by Clowder.
+const tracerName = "github.com/RedHatInsights/clowder"
+
+// setupTracing exports the spans of reconciles to the Jaeger collector at the endpoint and traces
+// the requests made to the Kubernetes API with the config. When no endpoint is configured the
+// global tracer provider is left as the no-op provider of OpenTelemetry, so the spans cost
+// nothing. The returned function flushes the spans that are still buffered.
+func setupTracing(endpoint string, config *rest.Config) (func(context.Context) error, error) {
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(endpoint)))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String("clowder"))),
+	)
+	otel.SetTracerProvider(provider)
+
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return otelhttp.NewTransport(rt)
+	})
+
+	return provider.Shutdown, nil
+}
+
+// startSpan starts a span for a step of a reconcile, as a child of the span in the context.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// startProviderSpan starts the span of a provider run, the source is either clowdapp or clowdenv
+// as in the provider metrics.
+func startProviderSpan(ctx context.Context, name string, source string) (context.Context, trace.Span) {
+	return startSpan(ctx, "provider "+name, attribute.String("provider", name), attribute.String("source", source))
+}
+
+// endSpan records the error of a step, if it failed, and ends its span.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -20,6 +20,7 @@ import (
 	"flag"
 	"fmt"
 	"net/http"
+	"os"
 	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -38,6 +39,8 @@ import (
 	crd "github.com/RedHatInsights/clowder/apis/cloud.redhat.com/v1alpha1"
 	controllers "github.com/RedHatInsights/clowder/controllers/cloud.redhat.com"
 	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/clowderconfig"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/config"
+	"github.com/RedHatInsights/clowder/controllers/cloud.redhat.com/providers/deployment"
 	"github.com/RedHatInsights/rhc-osdk-utils/logging"
 	//+kubebuilder:scaffold:imports
 )
@@ -67,7 +70,25 @@ func runAPIServer() {
 	_ = server.ListenAndServe()
 }
 
+// validateAppConfig checks the app config pointed at by ACG_CONFIG against the app config schema,
+// it is run by the config validation init container of the deployments.
+func validateAppConfig() error {
+	data, err := os.ReadFile(os.Getenv(deployment.ConfigEnvVar))
+	if err != nil {
+		return err
+	}
+	return config.Validate(data)
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == deployment.ConfigValidationCommand {
+		if err := validateAppConfig(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string